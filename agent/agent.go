@@ -0,0 +1,268 @@
+// Package agent implements `zam worker`: a sidecar that runs next to a local runtime
+// (Ollama, llama.cpp server or any OpenAI-compatible server), reports its real VRAM to the gateway
+// and proxies the gateway's requests to the runtime. Agents the gateway cannot reach (behind NAT)
+// run in reverse mode instead and receive requests over a WebSocket they dial themselves
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"zam/core"
+)
+
+// Config configures the worker agent
+type Config struct {
+	// GatewayURL is the base URL of the ZAM gateway, e.g. "http://gateway:8080"
+	GatewayURL string
+	// Token authenticates heartbeats (the gateway's WORKER_TOKEN)
+	Token    string
+	WorkerID string
+	// RuntimeURL is the base URL of the local runtime, e.g. "http://127.0.0.1:11434"
+	RuntimeURL string
+	// Listen is the address the proxy listens on; Endpoint is how the gateway reaches it
+	Listen   string
+	Endpoint string
+	MaxTasks int
+	// Interval is the initial heartbeat interval; the gateway may change it through directives
+	Interval time.Duration
+	Region   string
+	Zone     string
+	// Reverse dials the gateway over a WebSocket and receives requests through it instead of
+	// listening for them; Listen and Endpoint are then unused
+	Reverse bool
+}
+
+// Agent proxies gateway requests to the local runtime and heartbeats its profile
+type Agent struct {
+	cfg         Config
+	sampler     VRAMSampler
+	client      *http.Client
+	proxy       *httputil.ReverseProxy
+	incarnation uint64
+	active      atomic.Int64
+	// draining is set by a gateway drain directive; the agent stops accepting new requests
+	draining atomic.Bool
+}
+
+// heartbeat is the payload sent to the gateway; Endpoint tells it where the agent's proxy listens
+type heartbeat struct {
+	core.WorkerProfile
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// heartbeatResponse is the part of the gateway's heartbeat response the agent acts on
+type heartbeatResponse struct {
+	Directives *core.WorkerDirectives `json:"directives"`
+}
+
+// New creates an Agent
+func New(cfg Config, sampler VRAMSampler) (*Agent, error) {
+	if cfg.GatewayURL == "" || cfg.WorkerID == "" || cfg.RuntimeURL == "" {
+		return nil, errors.New("gateway URL, worker ID and runtime URL are required")
+	}
+	runtime, err := url.Parse(strings.TrimRight(cfg.RuntimeURL, "/"))
+	if err != nil || runtime.Host == "" {
+		return nil, fmt.Errorf("invalid runtime URL %q", cfg.RuntimeURL)
+	}
+	cfg.GatewayURL = strings.TrimRight(cfg.GatewayURL, "/")
+	if cfg.MaxTasks <= 0 {
+		cfg.MaxTasks = 4
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = core.DefaultHeartbeatInterval
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(runtime)
+	// SSE 需要逐块刷新，不能缓冲
+	proxy.FlushInterval = -1
+	return &Agent{
+		cfg:         cfg,
+		sampler:     sampler,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		proxy:       proxy,
+		incarnation: uint64(time.Now().UnixNano()),
+	}, nil
+}
+
+// ServeHTTP proxies a request to the runtime, tracking it as an active task
+func (a *Agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.draining.Load() {
+		http.Error(w, "worker is draining", http.StatusServiceUnavailable)
+		return
+	}
+	a.active.Add(1)
+	defer a.active.Add(-1)
+	a.proxy.ServeHTTP(w, r)
+}
+
+// Profile builds the current profile from the runtime's model list and a VRAM sample
+func (a *Agent) Profile(ctx context.Context) (core.WorkerProfile, error) {
+	models, err := a.runtimeModels(ctx)
+	if err != nil {
+		return core.WorkerProfile{}, err
+	}
+
+	profile := core.WorkerProfile{
+		WorkerID:    a.cfg.WorkerID,
+		Supported:   models,
+		ActiveTasks: int(a.active.Load()),
+		MaxTasks:    a.cfg.MaxTasks,
+		Incarnation: a.incarnation,
+		Region:      a.cfg.Region,
+		Zone:        a.cfg.Zone,
+	}
+	if a.draining.Load() {
+		profile.MaxTasks = 0
+	}
+	if a.sampler != nil {
+		stats, err := a.sampler.Sample(ctx)
+		if err != nil {
+			return core.WorkerProfile{}, fmt.Errorf("failed to sample VRAM: %w", err)
+		}
+		profile.TotalVRAM = stats.TotalVRAM
+		profile.AvailableVRAM = stats.AvailableVRAM
+		profile.GPUUtilization = stats.Utilization
+		profile.TemperatureC = stats.TemperatureC
+	}
+	return profile, nil
+}
+
+// Run serves the proxy and heartbeats until ctx is cancelled, then deregisters from the gateway
+// In reverse mode it holds the connection to the gateway instead
+func (a *Agent) Run(ctx context.Context) error {
+	if a.cfg.Reverse {
+		return a.runReverse(ctx)
+	}
+
+	srv := &http.Server{Addr: a.cfg.Listen, Handler: a}
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("agent proxying", "worker_id", a.cfg.WorkerID, "listen", a.cfg.Listen, "runtime_url", a.cfg.RuntimeURL)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	interval := a.cfg.Interval
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := a.deregister(shutdownCtx); err != nil {
+				slog.Error("agent deregister failed", "worker_id", a.cfg.WorkerID, "error", err)
+			}
+			return srv.Shutdown(shutdownCtx)
+		case err := <-serveErr:
+			return err
+		case <-timer.C:
+			d, err := a.SendHeartbeat(ctx)
+			if err != nil {
+				slog.Warn("agent heartbeat failed", "worker_id", a.cfg.WorkerID, "error", err)
+			} else if d != nil && d.HeartbeatIntervalSeconds > 0 {
+				interval = time.Duration(d.HeartbeatIntervalSeconds) * time.Second
+			}
+			timer.Reset(interval)
+		}
+	}
+}
+
+// SendHeartbeat reports the current profile and applies the directives returned by the gateway
+func (a *Agent) SendHeartbeat(ctx context.Context) (*core.WorkerDirectives, error) {
+	profile, err := a.Profile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(heartbeat{WorkerProfile: profile, Endpoint: a.cfg.Endpoint})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal heartbeat: %w", err)
+	}
+
+	resp, err := a.gatewayRequest(ctx, http.MethodPost, "/v1/workers/heartbeat", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gateway rejected heartbeat: status %d", resp.StatusCode)
+	}
+
+	var hr heartbeatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&hr); err != nil {
+		return nil, fmt.Errorf("failed to decode heartbeat response: %w", err)
+	}
+	if hr.Directives != nil && hr.Directives.Drain {
+		a.draining.Store(true)
+	}
+	return hr.Directives, nil
+}
+
+func (a *Agent) deregister(ctx context.Context) error {
+	resp, err := a.gatewayRequest(ctx, http.MethodDelete, "/v1/workers/"+url.PathEscape(a.cfg.WorkerID), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (a *Agent) gatewayRequest(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, method, a.cfg.GatewayURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if a.cfg.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+a.cfg.Token)
+	}
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach gateway: %w", err)
+	}
+	return resp, nil
+}
+
+// runtimeModels lists the runtime's models through its OpenAI-compatible /v1/models endpoint,
+// which both Ollama and llama.cpp server expose
+func (a *Agent) runtimeModels(ctx context.Context) ([]string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(a.cfg.RuntimeURL, "/")+"/v1/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("runtime unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code from runtime: %d", resp.StatusCode)
+	}
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode runtime models: %w", err)
+	}
+	models := make([]string, 0, len(list.Data))
+	for _, m := range list.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
@@ -0,0 +1,190 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"zam/api"
+	"zam/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseNVMLQuery(t *testing.T) {
+	stats, err := parseNVMLQuery("24564, 20000, 35, 61\n24564, 1000, [N/A], 70\n")
+	if err != nil {
+		t.Fatalf("parseNVMLQuery failed: %v", err)
+	}
+	if stats.TotalVRAM != 2*24564*1024*1024 || stats.AvailableVRAM != 21000*1024*1024 {
+		t.Errorf("unexpected VRAM: %+v", stats)
+	}
+	if stats.Utilization != 35 || stats.TemperatureC != 70 {
+		t.Errorf("expected max utilization/temperature, got %+v", stats)
+	}
+
+	if _, err := parseNVMLQuery(""); err == nil {
+		t.Error("expected error when no GPUs are reported")
+	}
+}
+
+func TestAgent_HeartbeatAndProxy(t *testing.T) {
+	runtime := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			w.Write([]byte(`{"object":"list","data":[{"id":"llama3:8b"},{"id":"qwen2.5:7b"}]}`))
+		case "/v1/chat/completions":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: [DONE]\n\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer runtime.Close()
+
+	var received heartbeat
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     "ok",
+			"directives": core.WorkerDirectives{HeartbeatIntervalSeconds: 2, Drain: true},
+		})
+	}))
+	defer gateway.Close()
+
+	a, err := New(Config{
+		GatewayURL: gateway.URL,
+		Token:      "secret",
+		WorkerID:   "gpu-box",
+		RuntimeURL: runtime.URL,
+		Endpoint:   "http://gpu-box:9090/v1/chat/completions",
+	}, StaticSampler{VRAM: 24 << 30})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	proxy := httptest.NewServer(a)
+	defer proxy.Close()
+	resp, err := http.Post(proxy.URL+"/v1/chat/completions", "application/json", nil)
+	if err != nil {
+		t.Fatalf("proxy request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "data: [DONE]\n\n" {
+		t.Errorf("expected runtime response through proxy, got %q", body)
+	}
+
+	d, err := a.SendHeartbeat(context.Background())
+	if err != nil {
+		t.Fatalf("SendHeartbeat failed: %v", err)
+	}
+	if d == nil || d.HeartbeatIntervalSeconds != 2 {
+		t.Errorf("expected directives from gateway, got %+v", d)
+	}
+	if received.WorkerID != "gpu-box" || len(received.Supported) != 2 || received.AvailableVRAM != 24<<30 {
+		t.Errorf("unexpected heartbeat: %+v", received)
+	}
+	if received.Endpoint != "http://gpu-box:9090/v1/chat/completions" || received.Incarnation == 0 {
+		t.Errorf("expected endpoint and incarnation in heartbeat, got %+v", received)
+	}
+
+	// 收到 drain 指令后不再接收新请求
+	resp, err = http.Post(proxy.URL+"/v1/chat/completions", "application/json", nil)
+	if err != nil {
+		t.Fatalf("proxy request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while draining, got %d", resp.StatusCode)
+	}
+}
+
+func TestAgent_Reverse(t *testing.T) {
+	runtime := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			w.Write([]byte(`{"object":"list","data":[{"id":"llama3:8b"}]}`))
+		case "/v1/chat/completions":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n"))
+			w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n"))
+			w.Write([]byte("data: [DONE]\n\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer runtime.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	registry := core.NewInMemoryRegistry(ctx)
+	workerAPI := api.NewWorkerAPI(registry)
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/v1/workers/connect", api.RequireWorkerToken("secret"), workerAPI.HandleConnect)
+	gateway := httptest.NewServer(engine)
+	defer gateway.Close()
+
+	a, err := New(Config{
+		GatewayURL: gateway.URL,
+		Token:      "secret",
+		WorkerID:   "nat-box",
+		RuntimeURL: runtime.URL,
+		Reverse:    true,
+	}, StaticSampler{VRAM: 24 << 30})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	agentCtx, stop := context.WithCancel(ctx)
+	stopped := make(chan error, 1)
+	go func() { stopped <- a.Run(agentCtx) }()
+
+	var w core.Worker
+	for deadline := time.Now().Add(5 * time.Second); w == nil && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if workers := registry.GetAvailableWorkers(); len(workers) == 1 {
+			w = workers[0]
+		}
+	}
+	if w == nil || w.ID() != "nat-box" {
+		t.Fatalf("expected the agent to register over the reverse connection, got %v", w)
+	}
+	if profile, ok := registry.Profile("nat-box"); !ok || len(profile.Supported) != 1 || profile.Endpoint != "" {
+		t.Errorf("unexpected registered profile: %+v", profile)
+	}
+
+	var content, finish string
+	err = w.Execute(ctx, &core.InferenceRequest{Model: "llama3:8b"}, func(chunk core.StreamChunk) error {
+		content += chunk.Content
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute over reverse connection failed: %v", err)
+	}
+	if content != "hi" || finish != "stop" {
+		t.Errorf("expected streamed chunks from the runtime, got %q (finish %q)", content, finish)
+	}
+
+	// Agent 退出时关闭连接，网关随之注销该 Worker
+	stop()
+	if err := <-stopped; err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, ok := registry.Profile("nat-box"); !ok {
+			return
+		}
+	}
+	t.Error("expected the worker to be deregistered after the connection closed")
+}
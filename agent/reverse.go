@@ -0,0 +1,266 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"zam/core"
+	"zam/worker"
+
+	"golang.org/x/net/websocket"
+)
+
+// maxReconnectBackoff caps the wait between reverse connection attempts
+const maxReconnectBackoff = 30 * time.Second
+
+// reverseSession is one reverse connection to the gateway and the jobs running on it
+type reverseSession struct {
+	agent   *Agent
+	conn    *websocket.Conn
+	runtime core.Worker
+	// writeMu serializes frames; a WebSocket connection allows one writer at a time
+	writeMu sync.Mutex
+
+	mu   sync.Mutex
+	jobs map[string]context.CancelFunc
+	wg   sync.WaitGroup
+	// interval is the heartbeat period in nanoseconds, updated by gateway directives
+	interval atomic.Int64
+}
+
+// runReverse keeps a reverse connection to the gateway open until ctx is cancelled,
+// reconnecting with exponential backoff whenever it drops
+func (a *Agent) runReverse(ctx context.Context) error {
+	backoff := time.Second
+	for {
+		connected, err := a.serveReverse(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if connected {
+			backoff = time.Second
+		}
+		slog.Warn("reverse connection lost", "worker_id", a.cfg.WorkerID, "error", err, "retry_in", backoff)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+	}
+}
+
+// serveReverse dials the gateway, registers and serves jobs until the connection fails or ctx is
+// cancelled; connected reports whether the gateway accepted the registration
+func (a *Agent) serveReverse(ctx context.Context) (connected bool, err error) {
+	profile, err := a.Profile(ctx)
+	if err != nil {
+		return false, err
+	}
+	conn, err := a.dialGateway(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	s := &reverseSession{
+		agent:   a,
+		conn:    conn,
+		runtime: worker.NewHTTPWorker(a.cfg.WorkerID, strings.TrimRight(a.cfg.RuntimeURL, "/")+"/v1/chat/completions"),
+		jobs:    make(map[string]context.CancelFunc),
+	}
+	s.interval.Store(int64(a.cfg.Interval))
+	if err := s.send(worker.ReverseFrame{Type: worker.ReverseRegister, Profile: &profile}); err != nil {
+		return false, fmt.Errorf("failed to register: %w", err)
+	}
+	slog.Info("agent connected to gateway", "worker_id", a.cfg.WorkerID, "gateway_url", a.cfg.GatewayURL, "runtime_url", a.cfg.RuntimeURL)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.heartbeats(ctx, stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.shutdown()
+		case <-stop:
+		}
+	}()
+
+	err = s.readFrames(ctx)
+	s.cancelJobs()
+	// 注册被拒绝时不重置重连退避
+	return !errors.Is(err, errRegistrationRejected), err
+}
+
+// errRegistrationRejected is returned when the gateway answers the register frame with an error frame
+var errRegistrationRejected = errors.New("gateway rejected registration")
+
+// dialGateway opens the WebSocket to /v1/workers/connect, authenticating with the worker token
+func (a *Agent) dialGateway(ctx context.Context) (*websocket.Conn, error) {
+	location := a.cfg.GatewayURL + "/v1/workers/connect"
+	switch {
+	case strings.HasPrefix(location, "https://"):
+		location = "wss://" + strings.TrimPrefix(location, "https://")
+	case strings.HasPrefix(location, "http://"):
+		location = "ws://" + strings.TrimPrefix(location, "http://")
+	}
+	config, err := websocket.NewConfig(location, a.cfg.GatewayURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gateway URL: %w", err)
+	}
+	if a.cfg.Token != "" {
+		config.Header.Set("Authorization", "Bearer "+a.cfg.Token)
+	}
+	conn, err := config.DialContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach gateway: %w", err)
+	}
+	return conn, nil
+}
+
+// readFrames handles the gateway's frames until the connection fails
+func (s *reverseSession) readFrames(ctx context.Context) error {
+	for {
+		var frame worker.ReverseFrame
+		if err := websocket.JSON.Receive(s.conn, &frame); err != nil {
+			return err
+		}
+
+		switch frame.Type {
+		case worker.ReverseError:
+			return fmt.Errorf("%w: %s", errRegistrationRejected, frame.Error)
+		case worker.ReverseDirectives:
+			if d := frame.Directives; d != nil {
+				if d.Drain {
+					s.agent.draining.Store(true)
+				}
+				if d.HeartbeatIntervalSeconds > 0 {
+					s.interval.Store(int64(time.Duration(d.HeartbeatIntervalSeconds) * time.Second))
+				}
+			}
+		case worker.ReverseExecute:
+			if frame.Request == nil {
+				continue
+			}
+			s.start(ctx, frame.ID, frame.Request.InferenceRequest())
+		case worker.ReverseCancel:
+			s.mu.Lock()
+			if cancel, ok := s.jobs[frame.ID]; ok {
+				cancel()
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// start runs a job on the local runtime, streaming its chunks back and finishing with a done frame
+func (s *reverseSession) start(ctx context.Context, id string, req *core.InferenceRequest) {
+	if s.agent.draining.Load() {
+		s.send(worker.ReverseFrame{Type: worker.ReverseDone, ID: id, Error: "worker is draining"})
+		return
+	}
+
+	// 任务不随 Agent 退出立即取消，由 shutdown 等待其完成
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	jobCtx = context.WithValue(jobCtx, core.TraceKey, req.TraceID)
+	s.mu.Lock()
+	s.jobs[id] = cancel
+	s.mu.Unlock()
+	s.wg.Add(1)
+	s.agent.active.Add(1)
+
+	go func() {
+		defer func() {
+			s.agent.active.Add(-1)
+			s.mu.Lock()
+			delete(s.jobs, id)
+			s.mu.Unlock()
+			cancel()
+			s.wg.Done()
+		}()
+
+		// 运行时的 SSE 逐块转发回网关
+		req.Stream = true
+		err := s.runtime.Execute(jobCtx, req, func(chunk core.StreamChunk) error {
+			return s.send(worker.ReverseFrame{Type: worker.ReverseChunk, ID: id, Chunk: worker.NewReverseStreamChunk(chunk)})
+		})
+		done := worker.ReverseFrame{Type: worker.ReverseDone, ID: id}
+		if err != nil {
+			done.Error = err.Error()
+		}
+		if err := s.send(done); err != nil {
+			slog.Warn("failed to report job result", "worker_id", s.agent.cfg.WorkerID, "job_id", id, "error", err)
+		}
+	}()
+}
+
+// heartbeats reports the profile over the connection until stop is closed
+func (s *reverseSession) heartbeats(ctx context.Context, stop <-chan struct{}) {
+	timer := time.NewTimer(time.Duration(s.interval.Load()))
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+			if err := s.sendHeartbeat(ctx); err != nil {
+				slog.Warn("agent heartbeat failed", "worker_id", s.agent.cfg.WorkerID, "error", err)
+			}
+			timer.Reset(time.Duration(s.interval.Load()))
+		}
+	}
+}
+
+func (s *reverseSession) sendHeartbeat(ctx context.Context) error {
+	// Agent 退出时 ctx 已取消，仍需上报排空状态
+	profile, err := s.agent.Profile(context.WithoutCancel(ctx))
+	if err != nil {
+		return err
+	}
+	return s.send(worker.ReverseFrame{Type: worker.ReverseHeartbeat, Profile: &profile})
+}
+
+// shutdown drains the session: the gateway is told to stop routing here, running jobs get up to
+// 30s to finish, then the connection is closed, which deregisters the worker
+func (s *reverseSession) shutdown() {
+	s.agent.draining.Store(true)
+	if err := s.sendHeartbeat(context.Background()); err != nil {
+		slog.Warn("agent heartbeat failed", "worker_id", s.agent.cfg.WorkerID, "error", err)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(30 * time.Second):
+		slog.Warn("agent stopping with jobs still running", "worker_id", s.agent.cfg.WorkerID)
+	}
+	s.conn.Close()
+}
+
+// cancelJobs aborts the jobs of a closed connection; the gateway has already failed them
+func (s *reverseSession) cancelJobs() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cancel := range s.jobs {
+		cancel()
+	}
+}
+
+func (s *reverseSession) send(frame worker.ReverseFrame) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return websocket.JSON.Send(s.conn, frame)
+}
@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// GPUStats is one VRAM/telemetry sample of the local GPUs, summed across devices
+type GPUStats struct {
+	TotalVRAM     uint64
+	AvailableVRAM uint64
+	// Utilization and TemperatureC are the maximum across devices
+	Utilization  float64
+	TemperatureC float64
+}
+
+// VRAMSampler reads the current GPU state of the host
+type VRAMSampler interface {
+	Sample(ctx context.Context) (GPUStats, error)
+}
+
+// NVMLSampler samples NVIDIA GPUs through nvidia-smi, which reads NVML
+// This avoids linking libnvidia-ml via cgo so the agent stays a static binary
+type NVMLSampler struct {
+	// Path of the nvidia-smi binary; defaults to "nvidia-smi" on PATH
+	Path string
+}
+
+// Sample queries every visible GPU
+func (s NVMLSampler) Sample(ctx context.Context) (GPUStats, error) {
+	path := s.Path
+	if path == "" {
+		path = "nvidia-smi"
+	}
+	out, err := exec.CommandContext(ctx, path,
+		"--query-gpu=memory.total,memory.free,utilization.gpu,temperature.gpu",
+		"--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return GPUStats{}, fmt.Errorf("nvidia-smi: %w", err)
+	}
+	return parseNVMLQuery(string(out))
+}
+
+// parseNVMLQuery parses nvidia-smi CSV output; memory is reported in MiB
+func parseNVMLQuery(out string) (GPUStats, error) {
+	var stats GPUStats
+	devices := 0
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 4 {
+			return GPUStats{}, fmt.Errorf("unexpected nvidia-smi output %q", line)
+		}
+		values := make([]float64, len(fields))
+		for i, f := range fields {
+			f = strings.TrimSpace(f)
+			// 部分型号不支持某些字段，输出 "[N/A]"
+			if strings.HasPrefix(f, "[") {
+				continue
+			}
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return GPUStats{}, fmt.Errorf("unexpected nvidia-smi value %q", f)
+			}
+			values[i] = v
+		}
+		stats.TotalVRAM += uint64(values[0]) * 1024 * 1024
+		stats.AvailableVRAM += uint64(values[1]) * 1024 * 1024
+		if values[2] > stats.Utilization {
+			stats.Utilization = values[2]
+		}
+		if values[3] > stats.TemperatureC {
+			stats.TemperatureC = values[3]
+		}
+		devices++
+	}
+	if devices == 0 {
+		return GPUStats{}, fmt.Errorf("nvidia-smi reported no GPUs")
+	}
+	return stats, nil
+}
+
+// StaticSampler reports a fixed amount of VRAM, for hosts without NVIDIA GPUs (e.g. Apple silicon)
+type StaticSampler struct {
+	VRAM uint64
+}
+
+// Sample always reports the configured VRAM as free
+func (s StaticSampler) Sample(ctx context.Context) (GPUStats, error) {
+	return GPUStats{TotalVRAM: s.VRAM, AvailableVRAM: s.VRAM}, nil
+}
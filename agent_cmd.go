@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"zam/agent"
+)
+
+// runWorkerAgent implements `zam worker`; every flag defaults to an environment variable
+func runWorkerAgent(args []string) {
+	hostname, _ := os.Hostname()
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	gateway := fs.String("gateway", envOr("ZAM_GATEWAY_URL", "http://127.0.0.1:8080"), "gateway base URL")
+	token := fs.String("token", os.Getenv("ZAM_WORKER_TOKEN"), "worker token (gateway WORKER_TOKEN)")
+	id := fs.String("id", envOr("ZAM_WORKER_ID", hostname), "worker ID")
+	runtime := fs.String("runtime", envOr("ZAM_RUNTIME_URL", "http://127.0.0.1:11434"), "local runtime base URL (Ollama, llama.cpp server)")
+	listen := fs.String("listen", envOr("ZAM_AGENT_LISTEN", ":9090"), "proxy listen address")
+	endpoint := fs.String("endpoint", os.Getenv("ZAM_AGENT_ENDPOINT"), "chat completions URL the gateway uses to reach this agent")
+	maxTasks := fs.Int("max-tasks", envInt("ZAM_MAX_TASKS", 4), "maximum concurrent requests")
+	interval := fs.Duration("interval", 5*time.Second, "initial heartbeat interval")
+	vramGB := fs.Float64("vram-gb", 0, "report a fixed VRAM size instead of sampling NVIDIA GPUs")
+	reverse := fs.Bool("reverse", os.Getenv("ZAM_AGENT_REVERSE") == "true", "dial the gateway over a WebSocket instead of listening (for hosts behind NAT)")
+	fs.Parse(args)
+
+	var sampler agent.VRAMSampler = agent.NVMLSampler{}
+	if *vramGB > 0 {
+		sampler = agent.StaticSampler{VRAM: uint64(*vramGB * 1024 * 1024 * 1024)}
+	}
+
+	a, err := agent.New(agent.Config{
+		GatewayURL: *gateway,
+		Token:      *token,
+		WorkerID:   *id,
+		RuntimeURL: *runtime,
+		Listen:     *listen,
+		Endpoint:   *endpoint,
+		MaxTasks:   *maxTasks,
+		Interval:   *interval,
+		Region:     os.Getenv("ZAM_REGION"),
+		Zone:       os.Getenv("ZAM_ZONE"),
+		Reverse:    *reverse,
+	}, sampler)
+	if err != nil {
+		log.Fatalf("Invalid worker config: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := a.Run(ctx); err != nil {
+		log.Fatalf("Worker agent stopped: %v", err)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return fallback
+}
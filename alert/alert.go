@@ -0,0 +1,345 @@
+// Package alert is a small built-in alerting engine for operators without a monitoring stack:
+// it watches worker liveness, the fallback rate and the error rate and notifies webhooks or Slack
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zam/core"
+)
+
+// Kind identifies what an alert rule watches
+type Kind string
+
+const (
+	// KindWorkerDown fires when a known worker has been gone or quarantined longer than the threshold (seconds)
+	KindWorkerDown Kind = "worker_down"
+	// KindFallbackRate fires when the share of requests served by the fallback exceeds the threshold (percent)
+	KindFallbackRate Kind = "fallback_rate"
+	// KindErrorRate fires when the share of failed requests exceeds the threshold (percent)
+	KindErrorRate Kind = "error_rate"
+)
+
+// Rule is one alert condition
+type Rule struct {
+	Kind Kind
+	// Threshold is in seconds for worker_down and in percent for the rate rules
+	Threshold float64
+}
+
+// Name returns the rule in its configuration syntax, e.g. "error_rate>5%"
+func (r Rule) Name() string {
+	if r.Kind == KindWorkerDown {
+		return fmt.Sprintf("%s>%gs", r.Kind, r.Threshold)
+	}
+	return fmt.Sprintf("%s>%g%%", r.Kind, r.Threshold)
+}
+
+// ParseRules parses rules in the format "worker_down>60s;fallback_rate>20%;error_rate>5%"
+func ParseRules(spec string) ([]Rule, error) {
+	var rules []Rule
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kind, value, ok := strings.Cut(entry, ">")
+		if !ok {
+			return nil, fmt.Errorf("invalid alert rule %q: expected kind>threshold", entry)
+		}
+		rule := Rule{Kind: Kind(strings.TrimSpace(kind))}
+		value = strings.TrimSpace(value)
+
+		switch rule.Kind {
+		case KindWorkerDown:
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid alert rule %q: threshold must be a positive duration", entry)
+			}
+			rule.Threshold = d.Seconds()
+		case KindFallbackRate, KindErrorRate:
+			pct, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+			if err != nil || pct <= 0 || pct > 100 {
+				return nil, fmt.Errorf("invalid alert rule %q: threshold must be a percentage in (0, 100]", entry)
+			}
+			rule.Threshold = pct
+		default:
+			return nil, fmt.Errorf("invalid alert rule %q: unknown kind %q", entry, rule.Kind)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Status is the state an alert notification reports
+type Status string
+
+const (
+	StatusFiring   Status = "firing"
+	StatusResolved Status = "resolved"
+)
+
+// Alert is one notification sent when a rule starts or stops firing
+type Alert struct {
+	Rule   string `json:"rule"`
+	Status Status `json:"status"`
+	// WorkerID is set for worker_down alerts
+	WorkerID string    `json:"worker_id,omitempty"`
+	Value    float64   `json:"value"`
+	Message  string    `json:"message"`
+	StartsAt time.Time `json:"starts_at"`
+	Time     time.Time `json:"time"`
+}
+
+// Notifier delivers alerts
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// ProfileSource lists the currently registered workers
+type ProfileSource interface {
+	Profiles() []core.WorkerProfile
+}
+
+// Options tune the rate rules
+type Options struct {
+	// Window is the sliding window the rates are computed over
+	Window time.Duration
+	// MinRequests is the number of requests in the window below which rate rules stay silent
+	MinRequests int
+	// Interval is how often rules are evaluated
+	Interval time.Duration
+}
+
+// DefaultOptions returns a 5-minute window, 20 requests minimum and 10s evaluation
+func DefaultOptions() Options {
+	return Options{Window: 5 * time.Minute, MinRequests: 20, Interval: 10 * time.Second}
+}
+
+// bucketSpan is the resolution of the sliding request window
+const bucketSpan = 10 * time.Second
+
+type requestBucket struct {
+	start    time.Time
+	total    int
+	fallback int
+	failed   int
+}
+
+// Engine evaluates alert rules and notifies on state changes
+type Engine struct {
+	rules     []Rule
+	opts      Options
+	workers   ProfileSource
+	notifiers []Notifier
+
+	mu          sync.Mutex
+	buckets     []requestBucket
+	lastSeen    map[string]time.Time // workers ever seen -> last time they were registered and not quarantined
+	quarantined map[string]bool
+	firing      map[string]Alert // rule name (+ worker) -> active alert
+	now         func() time.Time
+}
+
+// NewEngine creates an Engine; call Subscribe so quarantined workers count as down and deregistered ones are forgotten
+func NewEngine(rules []Rule, opts Options, workers ProfileSource, notifiers ...Notifier) *Engine {
+	def := DefaultOptions()
+	if opts.Window <= 0 {
+		opts.Window = def.Window
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = def.MinRequests
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = def.Interval
+	}
+	return &Engine{
+		rules:       rules,
+		opts:        opts,
+		workers:     workers,
+		notifiers:   notifiers,
+		lastSeen:    make(map[string]time.Time),
+		quarantined: make(map[string]bool),
+		firing:      make(map[string]Alert),
+		now:         time.Now,
+	}
+}
+
+// Subscribe tracks worker quarantine and deregistration events from the bus
+func (e *Engine) Subscribe(bus *core.EventBus) {
+	bus.Subscribe(func(ev core.Event) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		switch ev.Type {
+		case core.EventWorkerQuarantined:
+			e.quarantined[ev.WorkerID] = true
+		case core.EventWorkerReadmitted:
+			delete(e.quarantined, ev.WorkerID)
+		case core.EventWorkerDeregistered:
+			// 主动注销的 Worker 不算宕机
+			delete(e.lastSeen, ev.WorkerID)
+			delete(e.quarantined, ev.WorkerID)
+		}
+	})
+}
+
+// RecordRequest counts a finished request for the rate rules; a nil Engine records nothing
+func (e *Engine) RecordRequest(fallback, failed bool) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	if n := len(e.buckets); n == 0 || now.Sub(e.buckets[n-1].start) >= bucketSpan {
+		e.buckets = append(e.buckets, requestBucket{start: now})
+	}
+	b := &e.buckets[len(e.buckets)-1]
+	b.total++
+	if fallback {
+		b.fallback++
+	}
+	if failed {
+		b.failed++
+	}
+}
+
+// Run evaluates the rules every Options.Interval until ctx is cancelled
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Evaluate(ctx)
+		}
+	}
+}
+
+// Evaluate checks every rule once and sends notifications for alerts that started or stopped firing
+func (e *Engine) Evaluate(ctx context.Context) {
+	var profiles []core.WorkerProfile
+	if e.workers != nil {
+		profiles = e.workers.Profiles()
+	}
+
+	e.mu.Lock()
+	now := e.now()
+	total, fallback, failed := e.windowLocked(now)
+	active := make(map[string]Alert)
+	for _, rule := range e.rules {
+		switch rule.Kind {
+		case KindWorkerDown:
+			for id, down := range e.downWorkersLocked(profiles, now) {
+				if down.Seconds() > rule.Threshold {
+					active[rule.Name()+"/"+id] = Alert{
+						Rule:     rule.Name(),
+						WorkerID: id,
+						Value:    down.Seconds(),
+						Message:  fmt.Sprintf("worker %s has been down for %s", id, down.Round(time.Second)),
+					}
+				}
+			}
+		case KindFallbackRate, KindErrorRate:
+			if total < e.opts.MinRequests {
+				continue
+			}
+			count, what := fallback, "served by the fallback"
+			if rule.Kind == KindErrorRate {
+				count, what = failed, "failed"
+			}
+			if pct := float64(count) / float64(total) * 100; pct > rule.Threshold {
+				active[rule.Name()] = Alert{
+					Rule:    rule.Name(),
+					Value:   pct,
+					Message: fmt.Sprintf("%.1f%% of %d requests in the last %s were %s", pct, total, e.opts.Window, what),
+				}
+			}
+		}
+	}
+
+	// 只在状态切换时通知：新触发 / 已恢复
+	var notify []Alert
+	for key, a := range active {
+		if prev, ok := e.firing[key]; ok {
+			a.StartsAt = prev.StartsAt
+			e.firing[key] = a
+			continue
+		}
+		a.Status, a.StartsAt, a.Time = StatusFiring, now, now
+		e.firing[key] = a
+		notify = append(notify, a)
+	}
+	for key, a := range e.firing {
+		if _, ok := active[key]; ok {
+			continue
+		}
+		delete(e.firing, key)
+		a.Status, a.Time = StatusResolved, now
+		a.Message = "resolved: " + a.Message
+		notify = append(notify, a)
+	}
+	e.mu.Unlock()
+
+	for _, a := range notify {
+		for _, n := range e.notifiers {
+			if err := n.Notify(ctx, a); err != nil {
+				slog.Error("failed to deliver alert", "rule", a.Rule, "status", a.Status, "error", err)
+			}
+		}
+	}
+}
+
+// Firing returns the alerts currently firing
+func (e *Engine) Firing() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	alerts := make([]Alert, 0, len(e.firing))
+	for _, a := range e.firing {
+		alerts = append(alerts, a)
+	}
+	return alerts
+}
+
+// windowLocked drops buckets outside the window and sums the rest; caller must hold e.mu
+func (e *Engine) windowLocked(now time.Time) (total, fallback, failed int) {
+	cutoff := now.Add(-e.opts.Window)
+	kept := e.buckets[:0]
+	for _, b := range e.buckets {
+		if b.start.After(cutoff) {
+			kept = append(kept, b)
+			total += b.total
+			fallback += b.fallback
+			failed += b.failed
+		}
+	}
+	e.buckets = kept
+	return total, fallback, failed
+}
+
+// downWorkersLocked returns how long each known worker has been missing from the registry or quarantined;
+// caller must hold e.mu
+func (e *Engine) downWorkersLocked(profiles []core.WorkerProfile, now time.Time) map[string]time.Duration {
+	for _, p := range profiles {
+		if !e.quarantined[p.WorkerID] {
+			e.lastSeen[p.WorkerID] = now
+		}
+	}
+	down := make(map[string]time.Duration)
+	for id, seen := range e.lastSeen {
+		if d := now.Sub(seen); d > 0 {
+			down[id] = d
+		}
+	}
+	return down
+}
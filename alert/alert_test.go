@@ -0,0 +1,138 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"zam/core"
+)
+
+type staticProfiles struct {
+	mu       sync.Mutex
+	profiles []core.WorkerProfile
+}
+
+func (s *staticProfiles) Profiles() []core.WorkerProfile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.profiles
+}
+
+type recorder struct {
+	alerts []Alert
+}
+
+func (r *recorder) Notify(ctx context.Context, a Alert) error {
+	r.alerts = append(r.alerts, a)
+	return nil
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("worker_down>60s; fallback_rate>20%;error_rate>5")
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+	if len(rules) != 3 || rules[0].Threshold != 60 || rules[1].Threshold != 20 || rules[2].Kind != KindErrorRate {
+		t.Errorf("unexpected rules: %+v", rules)
+	}
+	if rules[0].Name() != "worker_down>60s" || rules[1].Name() != "fallback_rate>20%" {
+		t.Errorf("unexpected names: %s, %s", rules[0].Name(), rules[1].Name())
+	}
+
+	for _, bad := range []string{"worker_down>abc", "error_rate>150%", "latency>1s", "error_rate"} {
+		if _, err := ParseRules(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestEngine_WorkerDown(t *testing.T) {
+	profiles := &staticProfiles{profiles: []core.WorkerProfile{{WorkerID: "gpu-1"}, {WorkerID: "gpu-2"}}}
+	rec := &recorder{}
+	bus := core.NewEventBus()
+	engine := NewEngine([]Rule{{Kind: KindWorkerDown, Threshold: 30}}, Options{}, profiles, rec)
+	engine.Subscribe(bus)
+
+	now := time.Now()
+	engine.now = func() time.Time { return now }
+	engine.Evaluate(context.Background())
+
+	// gpu-1 掉线，gpu-2 被隔离
+	profiles.profiles = []core.WorkerProfile{{WorkerID: "gpu-2"}}
+	bus.Publish(core.Event{Type: core.EventWorkerQuarantined, WorkerID: "gpu-2"})
+	now = now.Add(20 * time.Second)
+	engine.Evaluate(context.Background())
+	if len(rec.alerts) != 0 {
+		t.Fatalf("expected no alert before the threshold, got %+v", rec.alerts)
+	}
+
+	now = now.Add(20 * time.Second)
+	engine.Evaluate(context.Background())
+	if len(rec.alerts) != 2 || rec.alerts[0].Status != StatusFiring {
+		t.Fatalf("expected both workers to fire, got %+v", rec.alerts)
+	}
+
+	// 再次评估不重复通知；gpu-2 恢复后发送 resolved，gpu-1 注销后也不再告警
+	engine.Evaluate(context.Background())
+	bus.Publish(core.Event{Type: core.EventWorkerReadmitted, WorkerID: "gpu-2"})
+	bus.Publish(core.Event{Type: core.EventWorkerDeregistered, WorkerID: "gpu-1"})
+	engine.Evaluate(context.Background())
+	if len(rec.alerts) != 4 || rec.alerts[2].Status != StatusResolved || rec.alerts[3].Status != StatusResolved {
+		t.Fatalf("expected two resolved notifications, got %+v", rec.alerts)
+	}
+	if len(engine.Firing()) != 0 {
+		t.Errorf("expected nothing firing, got %+v", engine.Firing())
+	}
+}
+
+func TestEngine_Rates(t *testing.T) {
+	rec := &recorder{}
+	engine := NewEngine([]Rule{{Kind: KindFallbackRate, Threshold: 20}, {Kind: KindErrorRate, Threshold: 5}}, Options{MinRequests: 10, Window: time.Minute}, nil, rec)
+	now := time.Now()
+	engine.now = func() time.Time { return now }
+
+	for i := 0; i < 9; i++ {
+		engine.RecordRequest(true, true)
+	}
+	engine.Evaluate(context.Background())
+	if len(rec.alerts) != 0 {
+		t.Fatalf("expected silence below MinRequests, got %+v", rec.alerts)
+	}
+
+	for i := 0; i < 11; i++ {
+		engine.RecordRequest(false, false)
+	}
+	engine.Evaluate(context.Background())
+	if len(rec.alerts) != 2 {
+		t.Fatalf("expected fallback and error rate alerts, got %+v", rec.alerts)
+	}
+
+	// 窗口滑过后恢复
+	now = now.Add(2 * time.Minute)
+	engine.RecordRequest(false, false)
+	engine.Evaluate(context.Background())
+	if len(rec.alerts) != 4 || rec.alerts[3].Status != StatusResolved {
+		t.Fatalf("expected alerts to resolve after the window, got %+v", rec.alerts)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	err := NewSlackNotifier(server.URL).Notify(context.Background(), Alert{Rule: "error_rate>5%", Status: StatusFiring, Message: "7.0% failed"})
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if got["text"] != ":rotating_light: [ZAM firing] error_rate>5%: 7.0% failed" {
+		t.Errorf("unexpected Slack message: %q", got["text"])
+	}
+}
@@ -0,0 +1,74 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookNotifier POSTs each alert as JSON to a URL
+type WebhookNotifier struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// NewWebhookNotifier creates a WebhookNotifier; token, if set, is sent as a Bearer Authorization header
+func NewWebhookNotifier(url, token string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Token: token, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Notify sends the alert
+func (n *WebhookNotifier) Notify(ctx context.Context, a Alert) error {
+	return postJSON(ctx, n.Client, n.URL, n.Token, a)
+}
+
+// SlackNotifier posts alerts to a Slack incoming webhook
+type SlackNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewSlackNotifier creates a SlackNotifier for the incoming webhook URL
+func NewSlackNotifier(url string) *SlackNotifier {
+	return &SlackNotifier{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Notify sends the alert as a Slack message
+func (n *SlackNotifier) Notify(ctx context.Context, a Alert) error {
+	icon := ":rotating_light:"
+	if a.Status == StatusResolved {
+		icon = ":white_check_mark:"
+	}
+	return postJSON(ctx, n.Client, n.URL, "", map[string]string{
+		"text": fmt.Sprintf("%s [ZAM %s] %s: %s", icon, a.Status, a.Rule, a.Message),
+	})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url, token string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+
+	"zam/openai"
+	"zam/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouterTuner exposes the runtime-tunable routing weights and model profiles
+type RouterTuner interface {
+	Weights() router.Weights
+	SetWeights(w router.Weights) error
+	ModelProfiles() router.ModelProfiles
+	SetModelProfile(model string, profile router.ModelProfile) error
+	DeleteModelProfile(model string) bool
+}
+
+// AdminAPI handles operator-facing admin endpoints
+type AdminAPI struct {
+	tuner RouterTuner
+}
+
+// NewAdminAPI creates a new AdminAPI
+func NewAdminAPI(tuner RouterTuner) *AdminAPI {
+	return &AdminAPI{
+		tuner: tuner,
+	}
+}
+
+// HandleGetWeights returns the current router weights
+func (api *AdminAPI) HandleGetWeights(c *gin.Context) {
+	c.JSON(http.StatusOK, api.tuner.Weights())
+}
+
+// HandlePutWeights validates and swaps the router weights
+// Fields omitted from the body keep their current values
+func (api *AdminAPI) HandlePutWeights(c *gin.Context) {
+	weights := api.tuner.Weights()
+	if err := c.ShouldBindJSON(&weights); err != nil {
+		WriteError(c, openai.NewInvalidRequestError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	if err := api.tuner.SetWeights(weights); err != nil {
+		WriteError(c, openai.NewInvalidRequestError(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.tuner.Weights())
+}
+
+// HandleListModelProfiles returns the configured per-model VRAM and context requirements
+func (api *AdminAPI) HandleListModelProfiles(c *gin.Context) {
+	c.JSON(http.StatusOK, api.tuner.ModelProfiles())
+}
+
+// HandlePutModelProfile adds or replaces the profile of one model
+func (api *AdminAPI) HandlePutModelProfile(c *gin.Context) {
+	var profile router.ModelProfile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		WriteError(c, openai.NewInvalidRequestError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	if err := api.tuner.SetModelProfile(c.Param("model"), profile); err != nil {
+		WriteError(c, openai.NewInvalidRequestError(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// HandleDeleteModelProfile removes the profile of one model, which falls back to the name heuristic
+func (api *AdminAPI) HandleDeleteModelProfile(c *gin.Context) {
+	if !api.tuner.DeleteModelProfile(c.Param("model")) {
+		WriteError(c, openai.NewNotFoundError("No profile for model '"+c.Param("model")+"'").WithParam("model"))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
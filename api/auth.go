@@ -0,0 +1,39 @@
+package api
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdminToken guards admin endpoints with a static bearer token
+// An empty token disables the check (local development only)
+func RequireAdminToken(token string) gin.HandlerFunc {
+	return requireBearer(token, "Invalid admin token")
+}
+
+// RequireWorkerToken guards the worker heartbeat endpoints with a shared bearer token
+// An empty token disables the check
+func RequireWorkerToken(token string) gin.HandlerFunc {
+	return requireBearer(token, "Invalid worker token")
+}
+
+func requireBearer(token, message string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			AbortWithError(c, openai.NewAuthenticationError(message))
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+
+	"zam/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AutoscaleAPI exposes gateway pressure to autoscalers of GPU worker fleets
+type AutoscaleAPI struct {
+	demand *core.DemandMonitor
+}
+
+// NewAutoscaleAPI creates a new AutoscaleAPI
+func NewAutoscaleAPI(demand *core.DemandMonitor) *AutoscaleAPI {
+	return &AutoscaleAPI{demand: demand}
+}
+
+// HandleSignals returns the demand signals as flat JSON, as read by the KEDA metrics-api scaler
+func (api *AutoscaleAPI) HandleSignals(c *gin.Context) {
+	c.JSON(http.StatusOK, api.demand.Signals())
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"zam/core"
+	"zam/openai"
+	"zam/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BillingAPI serves itemized usage per organization
+type BillingAPI struct {
+	ledger     *usage.Ledger
+	keys       *core.KeyDirectory
+	adminToken string
+}
+
+// NewBillingAPI creates a new BillingAPI
+// Callers are authorized with an API key of the organization or with adminToken
+func NewBillingAPI(ledger *usage.Ledger, keys *core.KeyDirectory, adminToken string) *BillingAPI {
+	return &BillingAPI{
+		ledger:     ledger,
+		keys:       keys,
+		adminToken: adminToken,
+	}
+}
+
+// HandleBilling returns the usage of an organization itemized by model and key
+// The period is selected with ?period=YYYY-MM, or ?start=&end= (RFC 3339); it defaults to the current UTC month
+func (api *BillingAPI) HandleBilling(c *gin.Context) {
+	org := c.Param("id")
+	if !api.authorized(c, org) {
+		WriteError(c, openai.NewPermissionError("API key does not belong to organization "+org))
+		return
+	}
+
+	start, end, err := billingPeriod(c, time.Now().UTC())
+	if err != nil {
+		WriteError(c, openai.NewInvalidRequestError(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.ledger.Summary(org, start, end))
+}
+
+// authorized accepts the admin token when one is configured, or an API key owned by org
+// Unlike /admin/*, an unset admin token does not open the endpoint: billing is per-tenant data
+func (api *BillingAPI) authorized(c *gin.Context, org string) bool {
+	provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if provided == "" {
+		return false
+	}
+	if api.adminToken != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(api.adminToken)) == 1 {
+		return true
+	}
+	owner := api.keys.Lookup(provided).Org
+	return owner != "" && owner == org
+}
+
+// billingPeriod parses the requested period from the query string
+func billingPeriod(c *gin.Context, now time.Time) (time.Time, time.Time, error) {
+	if period := c.Query("period"); period != "" {
+		start, err := time.Parse("2006-01", period)
+		if err != nil {
+			return time.Time{}, time.Time{}, errInvalidPeriod
+		}
+		return start, start.AddDate(0, 1, 0), nil
+	}
+
+	if c.Query("start") != "" || c.Query("end") != "" {
+		start, err1 := time.Parse(time.RFC3339, c.Query("start"))
+		end, err2 := time.Parse(time.RFC3339, c.Query("end"))
+		if err1 != nil || err2 != nil || !start.Before(end) {
+			return time.Time{}, time.Time{}, errInvalidPeriod
+		}
+		return start, end, nil
+	}
+
+	start := core.ResetMonthly.Start(now)
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// errInvalidPeriod is returned for malformed billing periods
+var errInvalidPeriod = errors.New("invalid billing period: use period=YYYY-MM or start/end in RFC 3339 with start before end")
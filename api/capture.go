@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"zam/core"
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CaptureAPI exposes sampled request/response payloads to operators
+type CaptureAPI struct {
+	capture *core.PayloadCapture
+}
+
+// NewCaptureAPI creates a new CaptureAPI
+func NewCaptureAPI(capture *core.PayloadCapture) *CaptureAPI {
+	return &CaptureAPI{capture: capture}
+}
+
+// HandleList returns the captured payloads, newest first (?limit=N)
+func (api *CaptureAPI) HandleList(c *gin.Context) {
+	limit := 0
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			WriteError(c, openai.NewInvalidRequestError("limit must be a non-negative integer"))
+			return
+		}
+		limit = n
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sample_rate": api.capture.Rate(),
+		"captures":    api.capture.List(limit),
+	})
+}
+
+// HandleGet returns one captured payload by request trace ID
+func (api *CaptureAPI) HandleGet(c *gin.Context) {
+	p, ok := api.capture.Get(c.Param("id"))
+	if !ok {
+		WriteError(c, openai.NewNotFoundError("Capture not found: "+c.Param("id")))
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// HandleClear drops every captured payload
+func (api *CaptureAPI) HandleClear(c *gin.Context) {
+	api.capture.Clear()
+	c.JSON(http.StatusOK, gin.H{"status": "cleared"})
+}
+
+// captureConfig is the body of PUT /admin/captures/config
+type captureConfig struct {
+	SampleRate *float64 `json:"sample_rate"`
+}
+
+// HandlePutConfig changes the sample rate at runtime, e.g. to capture everything while reproducing a report
+func (api *CaptureAPI) HandlePutConfig(c *gin.Context) {
+	var cfg captureConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil || cfg.SampleRate == nil || *cfg.SampleRate < 0 || *cfg.SampleRate > 1 {
+		WriteError(c, openai.NewInvalidRequestError("sample_rate must be a number between 0 and 1"))
+		return
+	}
+	api.capture.SetRate(*cfg.SampleRate)
+	c.JSON(http.StatusOK, gin.H{"sample_rate": api.capture.Rate()})
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChaosConfig describes the faults injected by ChaosMiddleware; rates are fractions in [0, 1]
+type ChaosConfig struct {
+	// Latency is added before the request is handled, on LatencyRate of requests
+	Latency     time.Duration
+	LatencyRate float64
+	// ErrorRate of requests fail immediately with a random 5xx
+	ErrorRate float64
+	// DisconnectRate of responses are cut off by closing the connection mid-response
+	DisconnectRate float64
+}
+
+// Enabled reports whether any fault is configured
+func (c ChaosConfig) Enabled() bool {
+	return (c.Latency > 0 && c.LatencyRate > 0) || c.ErrorRate > 0 || c.DisconnectRate > 0
+}
+
+// ParseChaos parses a fault list of the form "latency=2s@10%;error=5%;disconnect=5%"
+func ParseChaos(spec string) (ChaosConfig, error) {
+	var cfg ChaosConfig
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kind, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return cfg, fmt.Errorf("invalid chaos fault %q: expected kind=value", entry)
+		}
+		var err error
+		switch strings.TrimSpace(kind) {
+		case "latency":
+			d, rate, found := strings.Cut(value, "@")
+			if !found {
+				return cfg, fmt.Errorf("invalid chaos fault %q: expected latency=duration@rate", entry)
+			}
+			if cfg.Latency, err = time.ParseDuration(strings.TrimSpace(d)); err != nil || cfg.Latency <= 0 {
+				return cfg, fmt.Errorf("invalid chaos fault %q: latency must be a positive duration", entry)
+			}
+			cfg.LatencyRate, err = parseChaosRate(rate)
+		case "error":
+			cfg.ErrorRate, err = parseChaosRate(value)
+		case "disconnect":
+			cfg.DisconnectRate, err = parseChaosRate(value)
+		default:
+			return cfg, fmt.Errorf("invalid chaos fault %q: unknown kind %q", entry, kind)
+		}
+		if err != nil {
+			return cfg, fmt.Errorf("invalid chaos fault %q: %w", entry, err)
+		}
+	}
+	return cfg, nil
+}
+
+// parseChaosRate parses a percentage such as "5%" into a fraction
+func parseChaosRate(value string) (float64, error) {
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil || pct < 0 || pct > 100 {
+		return 0, fmt.Errorf("rate must be a percentage in [0, 100]")
+	}
+	return pct / 100, nil
+}
+
+// chaosStatuses are the upstream-style failures injected by the error fault
+var chaosStatuses = []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}
+
+// errChaosDisconnect is returned to the handler once its connection has been cut
+var errChaosDisconnect = errors.New("chaos: connection closed")
+
+// ChaosMiddleware injects latency, 5xx errors and mid-response disconnects so clients can
+// validate their retry behavior. Injected faults are tagged with an X-Chaos-Fault header
+// Meant for test environments only
+func ChaosMiddleware(cfg ChaosConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.Latency > 0 && rand.Float64() < cfg.LatencyRate {
+			c.Header("X-Chaos-Fault", "latency")
+			select {
+			case <-time.After(cfg.Latency):
+			case <-c.Request.Context().Done():
+				c.Abort()
+				return
+			}
+		}
+
+		if rand.Float64() < cfg.ErrorRate {
+			status := chaosStatuses[rand.Intn(len(chaosStatuses))]
+			c.Header("X-Chaos-Fault", "error")
+			AbortWithError(c, openai.NewError(status, openai.ServerErrorType, fmt.Sprintf("Injected fault: %s", http.StatusText(status))))
+			return
+		}
+
+		if rand.Float64() < cfg.DisconnectRate {
+			// 流式响应在若干个分片之后断开，非流式响应在首次写入时截断
+			c.Writer = &chaosWriter{ResponseWriter: c.Writer, cutAt: 1 + rand.Intn(8)}
+		}
+		c.Next()
+	}
+}
+
+// chaosWriter closes the underlying connection partway through the response
+type chaosWriter struct {
+	gin.ResponseWriter
+	// cutAt is the 1-based write on which event streams are cut
+	cutAt  int
+	writes int
+	closed bool
+}
+
+// Write passes data through until the cut point, then sends half of the write and drops the connection
+func (w *chaosWriter) Write(data []byte) (int, error) {
+	if w.closed {
+		return 0, errChaosDisconnect
+	}
+	w.writes++
+	stream := strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream")
+	if stream && w.writes < w.cutAt {
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.closed = true
+	n, _ := w.ResponseWriter.Write(data[:len(data)/2])
+	w.ResponseWriter.Flush()
+	if conn, _, err := w.ResponseWriter.Hijack(); err == nil {
+		conn.Close()
+	}
+	return n, errChaosDisconnect
+}
+
+// WriteString routes through Write so string writes are cut as well
+func (w *chaosWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush is a no-op once the connection has been cut
+func (w *chaosWriter) Flush() {
+	if !w.closed {
+		w.ResponseWriter.Flush()
+	}
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+
+	"zam/core"
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DrainMiddleware tracks client requests in d so shutdown can wait for active streams
+// While the gateway drains, new requests get 503 with Connection: close so clients reconnect to another replica
+func DrainMiddleware(d *core.Drainer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !d.Enter() {
+			c.Header("Connection", "close")
+			c.Header("Retry-After", "1")
+			AbortWithError(c, openai.NewServerError(http.StatusServiceUnavailable,
+				"The gateway is shutting down; please retry the request").WithCode("gateway_shutting_down"))
+			return
+		}
+		defer d.Exit()
+		c.Next()
+	}
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WriteError renders an OpenAI-compatible error response tagged with the request ID
+func WriteError(c *gin.Context, err *openai.Error) {
+	c.JSON(err.Status, ErrorBody(c, err))
+}
+
+// AbortWithError renders an OpenAI-compatible error response and stops the middleware chain
+func AbortWithError(c *gin.Context, err *openai.Error) {
+	c.AbortWithStatusJSON(err.Status, ErrorBody(c, err))
+}
+
+// ErrorBody wraps err in its envelope with the request ID, for HTTP responses and SSE error events
+func ErrorBody(c *gin.Context, err *openai.Error) openai.ErrorBody {
+	body := err.Body()
+	body.RequestID = RequestID(c)
+	return body
+}
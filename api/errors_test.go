@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWriteError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestIDMiddleware())
+	r.GET("/quota", func(c *gin.Context) {
+		WriteError(c, openai.NewQuotaError("Insufficient quota or invalid API key"))
+	})
+	r.GET("/auth", func(c *gin.Context) {
+		AbortWithError(c, openai.NewAuthenticationError("Missing or invalid Authorization header"))
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/quota", http.StatusTooManyRequests, `{"error":{"message":"Insufficient quota or invalid API key","type":"insufficient_quota","param":null,"code":"insufficient_quota"},"request_id":"req-1"}`},
+		{"/auth", http.StatusUnauthorized, `{"error":{"message":"Missing or invalid Authorization header","type":"authentication_error","param":null,"code":null},"request_id":"req-1"}`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set(RequestIDHeader, "req-1")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		// 所有错误使用同一个信封：param 与 code 未设置时为 null，并附带请求 ID
+		if rec.Code != tt.status || rec.Body.String() != tt.body {
+			t.Errorf("%s: got %d %s, want %d %s", tt.path, rec.Code, rec.Body, tt.status, tt.body)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"zam/core"
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultEventLimit caps event queries that do not pass a limit
+const defaultEventLimit = 100
+
+// EventsAPI exposes the persisted event log for incident reconstruction
+type EventsAPI struct {
+	log *core.EventLog
+}
+
+// NewEventsAPI creates a new EventsAPI
+func NewEventsAPI(log *core.EventLog) *EventsAPI {
+	return &EventsAPI{log: log}
+}
+
+// HandleList returns events newest first, filtered by ?type=, ?worker_id=, ?since= / ?until= (RFC3339) and ?limit=
+func (api *EventsAPI) HandleList(c *gin.Context) {
+	q := core.EventQuery{
+		Type:     core.EventType(c.Query("type")),
+		WorkerID: c.Query("worker_id"),
+		Limit:    defaultEventLimit,
+	}
+
+	var err error
+	if v := c.Query("since"); v != "" {
+		if q.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			api.badRequest(c, "since must be an RFC3339 timestamp")
+			return
+		}
+	}
+	if v := c.Query("until"); v != "" {
+		if q.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			api.badRequest(c, "until must be an RFC3339 timestamp")
+			return
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 {
+			api.badRequest(c, "limit must be a positive integer")
+			return
+		}
+	}
+
+	events := api.log.Query(q)
+	if events == nil {
+		events = []core.Event{}
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+func (api *EventsAPI) badRequest(c *gin.Context, message string) {
+	WriteError(c, openai.NewInvalidRequestError(message))
+}
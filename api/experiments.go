@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+
+	"zam/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExperimentsAPI exposes the per-arm metrics of A/B experiments
+type ExperimentsAPI struct {
+	experiments *core.Experiments
+}
+
+// NewExperimentsAPI creates a new ExperimentsAPI
+func NewExperimentsAPI(experiments *core.Experiments) *ExperimentsAPI {
+	return &ExperimentsAPI{experiments: experiments}
+}
+
+// HandleList compares the arms of every experiment
+func (api *ExperimentsAPI) HandleList(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"experiments": api.experiments.Stats()})
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+
+	"zam/core"
+	"zam/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProfileSource provides a snapshot of registered worker profiles
+type ProfileSource interface {
+	Profiles() []core.WorkerProfile
+}
+
+// FederationAPI exposes this gateway's local capacity to peer gateways
+type FederationAPI struct {
+	gatewayID  string
+	profiles   ProfileSource
+	quarantine *core.Quarantine
+	brownout   *core.Brownout
+}
+
+// NewFederationAPI creates a new FederationAPI
+func NewFederationAPI(gatewayID string, profiles ProfileSource) *FederationAPI {
+	return &FederationAPI{
+		gatewayID: gatewayID,
+		profiles:  profiles,
+	}
+}
+
+// HandleProfile returns the aggregated profile of local workers
+// Peers and fallbacks are excluded so capacity is never advertised twice across a federation
+func (api *FederationAPI) HandleProfile(c *gin.Context) {
+	c.JSON(http.StatusOK, router.AggregateProfile(api.gatewayID, api.profiles.Profiles()))
+}
+
+// SetQuarantine excludes quarantined workers from the advertised capacity
+func (api *FederationAPI) SetQuarantine(quarantine *core.Quarantine) {
+	api.quarantine = quarantine
+}
+
+// SetBrownout advertises a zero weight while the gateway is shedding load
+func (api *FederationAPI) SetBrownout(brownout *core.Brownout) {
+	api.brownout = brownout
+}
+
+// HandleCapacity reports the healthy local capacity and a suggested weight for an upstream L4/L7 balancer
+// It answers 503 when no local worker can take a request, so plain HTTP health checks drain the gateway
+func (api *FederationAPI) HandleCapacity(c *gin.Context) {
+	capacity := api.Capacity()
+	status := http.StatusOK
+	if !capacity.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, capacity)
+}
+
+// Capacity summarizes the local workers that are not quarantined
+func (api *FederationAPI) Capacity() router.GatewayCapacity {
+	var available func(workerID string) bool
+	if api.quarantine != nil {
+		available = func(workerID string) bool { return api.quarantine.State(workerID) != core.BreakerOpen }
+	}
+	return router.LocalCapacity(api.gatewayID, api.profiles.Profiles(), available, api.brownout.Active())
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"zam/core"
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobsAPI accepts async chat completion jobs and serves their results
+// Jobs are executed through the regular chat handler, so validation, routing and billing are the same
+type JobsAPI struct {
+	queue      *core.JobQueue
+	engine     *gin.Engine
+	priorities *core.PriorityPolicy
+}
+
+// NewJobsAPI creates a JobsAPI executing jobs with the chat completion handler
+func NewJobsAPI(queue *core.JobQueue, chat gin.HandlerFunc) *JobsAPI {
+	engine := gin.New()
+	engine.Use(RequestIDMiddleware())
+	engine.POST("/v1/chat/completions", chat)
+	return &JobsAPI{queue: queue, engine: engine}
+}
+
+// SetPriorities checks the X-Priority header of submissions against the key's maximum
+func (api *JobsAPI) SetPriorities(policy *core.PriorityPolicy) {
+	api.priorities = policy
+}
+
+// HandleSubmit queues a chat completion request and returns the job immediately (202)
+// An optional top-level "callback_url" receives the finished job
+// Jobs are low priority unless X-Priority asks otherwise; priority orders the queue and is kept on execution
+func (api *JobsAPI) HandleSubmit(c *gin.Context) {
+	key := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if key == "" {
+		WriteError(c, openai.NewAuthenticationError("Missing or invalid Authorization header"))
+		return
+	}
+
+	priority, perr := RequestPriority(c, api.priorities, key, core.PriorityLow)
+	if perr != nil {
+		WriteError(c, perr)
+		return
+	}
+
+	raw, err := c.GetRawData()
+	var req openai.ChatCompletionRequest
+	var extra struct {
+		CallbackURL string `json:"callback_url"`
+	}
+	if err == nil {
+		err = json.Unmarshal(raw, &req)
+	}
+	if err == nil {
+		err = json.Unmarshal(raw, &extra)
+	}
+	if err != nil {
+		WriteError(c, openai.NewInvalidRequestError("Invalid request body: "+err.Error()))
+		return
+	}
+	if extra.CallbackURL != "" {
+		if err := core.ValidateCallbackURL(extra.CallbackURL); err != nil {
+			WriteError(c, openai.NewInvalidRequestError(err.Error()).WithParam("callback_url"))
+			return
+		}
+	}
+	if req.Model == "" {
+		WriteError(c, openai.NewInvalidRequestError("model is required").WithParam("model"))
+		return
+	}
+	if len(req.Messages) == 0 {
+		WriteError(c, openai.NewInvalidRequestError("messages is required").WithParam("messages"))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		WriteError(c, err)
+		return
+	}
+	// 任务结果整体返回，不支持流式
+	req.Stream = false
+	body, err := json.Marshal(req)
+	if err != nil {
+		WriteError(c, openai.NewServerError(http.StatusInternalServerError, "Failed to encode job: "+err.Error()))
+		return
+	}
+
+	job, err := api.queue.Submit(key, body, extra.CallbackURL, priority)
+	if errors.Is(err, core.ErrJobQueueFull) {
+		c.Header("Retry-After", "60")
+		WriteError(c, openai.NewServerError(http.StatusServiceUnavailable, "Too many queued jobs, please retry later").WithCode("job_queue_full"))
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+// HandleGet returns a job with its result or error once finished
+func (api *JobsAPI) HandleGet(c *gin.Context) {
+	key := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	job, ok := api.queue.Get(key, c.Param("id"))
+	if !ok {
+		WriteError(c, openai.NewNotFoundError("Job not found: "+c.Param("id")))
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// Execute runs a job through the chat completion handler; the job ID is used as its request ID
+func (api *JobsAPI) Execute(ctx context.Context, job core.Job) (int, []byte) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/v1/chat/completions", bytes.NewReader(job.Request))
+	if err != nil {
+		return http.StatusInternalServerError, nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+job.Key)
+	req.Header.Set(RequestIDHeader, job.ID)
+	req.Header.Set(core.PriorityHeader, job.Priority.String())
+
+	w := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+	api.engine.ServeHTTP(w, req)
+	return w.status, w.body.Bytes()
+}
+
+// bufferedResponse collects a handler's response in memory
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponse) Header() http.Header         { return w.header }
+func (w *bufferedResponse) Write(b []byte) (int, error) { return w.body.Write(b) }
+func (w *bufferedResponse) WriteHeader(status int)      { w.status = status }
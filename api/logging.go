@@ -0,0 +1,70 @@
+package api
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// logFieldsKey stores the fields handlers attach to the access log in the gin context
+const logFieldsKey = "zam.log_fields"
+
+// SetLogFields attaches key-value pairs (as in slog.Info) to the request's access log record,
+// replacing earlier values of the same keys, e.g. the worker a failed-over request ended on
+func SetLogFields(c *gin.Context, args ...any) {
+	fields, _ := c.Get(logFieldsKey)
+	attrs, _ := fields.([]slog.Attr)
+	// slog.Record 按 slog.Info 的规则解析 args（"key", value 对或 slog.Attr）
+	record := slog.NewRecord(time.Time{}, slog.LevelInfo, "", 0)
+	record.Add(args...)
+	record.Attrs(func(a slog.Attr) bool {
+		for i := range attrs {
+			if attrs[i].Key == a.Key {
+				attrs[i] = a
+				return true
+			}
+		}
+		attrs = append(attrs, a)
+		return true
+	})
+	c.Set(logFieldsKey, attrs)
+}
+
+// AccessLog writes one structured record per request with its request ID, status, latency and
+// the fields handlers attached via SetLogFields. 5xx responses are logged at error level, 4xx at warn
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		if !slog.Default().Enabled(c.Request.Context(), level) {
+			return
+		}
+
+		attrs := []slog.Attr{
+			slog.String("request_id", RequestID(c)),
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if fields, ok := c.Get(logFieldsKey); ok {
+			attrs = append(attrs, fields.([]slog.Attr)...)
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
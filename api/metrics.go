@@ -0,0 +1,24 @@
+package api
+
+import (
+	"strings"
+
+	"zam/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyMetricsMiddleware records per-API-key request counts, errors and concurrency
+// Keys are hashed before they become label values
+func KeyMetricsMiddleware(m *metrics.KeyMetrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := ""
+		if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			key = strings.TrimPrefix(auth, "Bearer ")
+		}
+
+		done := m.Start(key)
+		defer func() { done(c.Writer.Status()) }()
+		c.Next()
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"zam/core"
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModelsAPI lists the models served by the alive workers in the OpenAI format
+type ModelsAPI struct {
+	registry   core.WorkerRegistry
+	profiles   ProfileSource
+	quarantine *core.Quarantine
+	aliases    atomic.Pointer[core.ModelAliases]
+	// created is reported as the creation time of every model: the gateway does not know when a model was built
+	created int64
+}
+
+// NewModelsAPI creates a new ModelsAPI; profiles provides the Supported lists of the registry's workers
+func NewModelsAPI(registry core.WorkerRegistry, profiles ProfileSource) *ModelsAPI {
+	return &ModelsAPI{
+		registry: registry,
+		profiles: profiles,
+		created:  time.Now().Unix(),
+	}
+}
+
+// SetQuarantine leaves quarantined workers out of the listed servers
+func (api *ModelsAPI) SetQuarantine(quarantine *core.Quarantine) {
+	api.quarantine = quarantine
+}
+
+// SetModelAliases lists every alias whose target model is served, next to the deployed models
+// Safe to call at runtime
+func (api *ModelsAPI) SetModelAliases(aliases core.ModelAliases) {
+	api.aliases.Store(&aliases)
+}
+
+// HandleList returns the union of the models supported by the alive workers
+func (api *ModelsAPI) HandleList(c *gin.Context) {
+	if !hasBearer(c) {
+		WriteError(c, openai.NewAuthenticationError("Missing or invalid Authorization header"))
+		return
+	}
+	c.JSON(http.StatusOK, openai.ModelList{Object: "list", Data: api.Models()})
+}
+
+// HandleGet returns one model, matched case-insensitively like routing does
+func (api *ModelsAPI) HandleGet(c *gin.Context) {
+	if !hasBearer(c) {
+		WriteError(c, openai.NewAuthenticationError("Missing or invalid Authorization header"))
+		return
+	}
+	id := c.Param("id")
+	for _, model := range api.Models() {
+		if strings.EqualFold(model.ID, id) {
+			c.JSON(http.StatusOK, model)
+			return
+		}
+	}
+	WriteError(c, openai.NewNotFoundError("The model '"+id+"' does not exist or is not served by any worker").WithParam("model").WithCode("model_not_found"))
+}
+
+// Models collects the models of the schedulable, non-quarantined workers and their aliases, sorted by ID
+// Names differing only in case are merged under the spelling of the first worker by ID
+func (api *ModelsAPI) Models() []openai.Model {
+	// 只读取熔断状态，Filter 会占用半开探测名额
+	alive := make(map[string]bool)
+	for _, w := range api.registry.GetAvailableWorkers() {
+		if api.quarantine == nil || api.quarantine.State(w.ID()) != core.BreakerOpen {
+			alive[w.ID()] = true
+		}
+	}
+
+	profiles := api.profiles.Profiles()
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].WorkerID < profiles[j].WorkerID })
+	byName := make(map[string]*openai.Model)
+	for _, profile := range profiles {
+		if !alive[profile.WorkerID] {
+			continue
+		}
+		for _, name := range profile.Supported {
+			// "*"（如云端 Fallback）表示可服务任意模型，不是一个模型 ID
+			if name == "*" {
+				continue
+			}
+			key := strings.ToLower(name)
+			model, ok := byName[key]
+			if !ok {
+				model = &openai.Model{ID: name, Object: "model", Created: api.created, OwnedBy: "zam"}
+				byName[key] = model
+			}
+			if n := len(model.Workers); n == 0 || model.Workers[n-1] != profile.WorkerID {
+				model.Workers = append(model.Workers, profile.WorkerID)
+			}
+		}
+	}
+
+	// 别名随目标模型一起列出，目标无 Worker 服务时不列出
+	var aliases core.ModelAliases
+	if p := api.aliases.Load(); p != nil {
+		aliases = *p
+	}
+	for alias, target := range aliases {
+		base, _ := core.SplitAdapterModel(target)
+		served, ok := byName[strings.ToLower(base)]
+		key := strings.ToLower(alias)
+		if _, exists := byName[key]; !ok || exists {
+			continue
+		}
+		byName[key] = &openai.Model{ID: alias, Object: "model", Created: api.created, OwnedBy: "zam", Workers: served.Workers, AliasOf: target}
+	}
+
+	models := make([]openai.Model, 0, len(byName))
+	for _, model := range byName {
+		models = append(models, *model)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return models
+}
+
+// hasBearer reports whether the request carries a bearer API key
+func hasBearer(c *gin.Context) bool {
+	key, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return ok && key != ""
+}
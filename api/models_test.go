@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"zam/core"
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stubWorker is a worker known only by its ID; the tests read profiles from the registry
+type stubWorker struct{ id string }
+
+func (w *stubWorker) ID() string { return w.id }
+
+func (w *stubWorker) Heartbeat(ctx context.Context) (core.WorkerProfile, error) {
+	return core.WorkerProfile{WorkerID: w.id}, nil
+}
+
+func (w *stubWorker) Execute(ctx context.Context, req *core.InferenceRequest, sender func(core.StreamChunk) error) error {
+	return nil
+}
+
+func TestModelsAPI_HandleList(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	registry := core.NewInMemoryRegistry(ctx)
+	registry.RegisterWorker(&stubWorker{id: "gpu-a"}, core.WorkerProfile{WorkerID: "gpu-a", Supported: []string{"llama-8b", "Qwen-7B"}, MaxTasks: 4})
+	registry.RegisterWorker(&stubWorker{id: "gpu-b"}, core.WorkerProfile{WorkerID: "gpu-b", Supported: []string{"LLAMA-8B"}, MaxTasks: 4})
+	registry.RegisterWorker(&stubWorker{id: "cloud-fallback"}, core.WorkerProfile{WorkerID: "cloud-fallback", Supported: []string{"*"}, MaxTasks: 4})
+
+	models := NewModelsAPI(registry, registry)
+	models.SetModelAliases(core.ModelAliases{"GPT-4": "llama-8b", "QWEN-7B": "llama-8b", "gpt-3.5-turbo": "mistral-7b"})
+	r := gin.New()
+	r.GET("/v1/models", models.HandleList)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without an API key, got %d", rec.Code)
+	}
+
+	req.Header.Set("Authorization", "Bearer test-key-123")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	var list openai.ModelList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected a model list, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// 通配符不是模型；大小写不同的名称与别名合并为一项；目标无人服务的别名不列出
+	got := make(map[string][]string)
+	var ids []string
+	for _, m := range list.Data {
+		ids = append(ids, m.ID)
+		got[m.ID] = m.Workers
+	}
+	if want := []string{"GPT-4", "Qwen-7B", "llama-8b"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("model IDs = %v, want %v", ids, want)
+	}
+	if want := []string{"gpu-a", "gpu-b"}; !reflect.DeepEqual(got["llama-8b"], want) || !reflect.DeepEqual(got["GPT-4"], want) {
+		t.Errorf("expected llama-8b and its alias served by %v, got %v and %v", want, got["llama-8b"], got["GPT-4"])
+	}
+}
@@ -0,0 +1,351 @@
+package api
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Security schemes of the gateway endpoints
+const (
+	SecurityAPIKey      = "apiKey"
+	SecurityAdminToken  = "adminToken"
+	SecurityWorkerToken = "workerToken"
+)
+
+// Operation documents one endpoint; Request and Response are zero values of the types the handler
+// binds and renders, and are reflected into JSON schemas
+type Operation struct {
+	ID       string
+	Summary  string
+	Tag      string
+	Security string
+	// Query and Headers list the accepted query parameters and request headers
+	Query    []string
+	Headers  []string
+	Request  interface{}
+	Response interface{}
+	// Status is the success status code (200 when unset)
+	Status int
+	// Stream is the event type of endpoints that answer with server-sent events when the request sets "stream"
+	Stream interface{}
+}
+
+// OpenAPI generates an OpenAPI 3 document for the routes registered on a gin engine
+// Only registered routes are listed, so the document always matches the served surface;
+// routes without an Operation are listed with a generic response
+type OpenAPI struct {
+	title   string
+	version string
+	ops     map[string]Operation
+
+	once sync.Once
+	doc  map[string]interface{}
+}
+
+// NewOpenAPI creates an empty OpenAPI description
+func NewOpenAPI(title, version string) *OpenAPI {
+	return &OpenAPI{title: title, version: version, ops: make(map[string]Operation)}
+}
+
+// Describe documents the route registered for method and path (gin syntax, e.g. /v1/jobs/:id)
+func (o *OpenAPI) Describe(method, path string, op Operation) {
+	o.ops[method+" "+path] = op
+}
+
+// Handler serves the document; it is generated on the first request, once every route is registered
+func (o *OpenAPI) Handler(engine *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		o.once.Do(func() {
+			o.doc = o.Document(engine.Routes())
+		})
+		c.JSON(http.StatusOK, o.doc)
+	}
+}
+
+// Document builds the OpenAPI document for routes
+func (o *OpenAPI) Document(routes gin.RoutesInfo) map[string]interface{} {
+	g := newSchemaGenerator()
+	errorSchema := g.schema(reflect.TypeOf(openai.ErrorBody{}))
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	paths := make(map[string]interface{})
+	for _, route := range routes {
+		op, ok := o.ops[route.Method+" "+route.Path]
+		if !ok {
+			op = Operation{Summary: "Undocumented endpoint"}
+		}
+		path, params := openAPIPath(route.Path)
+		for _, q := range op.Query {
+			params = append(params, map[string]interface{}{
+				"name": q, "in": "query", "required": false, "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, h := range op.Headers {
+			params = append(params, map[string]interface{}{
+				"name": h, "in": "header", "required": false, "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+
+		operation := map[string]interface{}{
+			"operationId": op.ID,
+			"summary":     op.Summary,
+			"responses": map[string]interface{}{
+				"default": map[string]interface{}{
+					"description": "Error",
+					"content":     jsonContent(errorSchema),
+				},
+			},
+		}
+		if op.ID == "" {
+			operation["operationId"] = operationID(route.Method, route.Path)
+		}
+		if op.Tag != "" {
+			operation["tags"] = []string{op.Tag}
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if op.Security != "" {
+			operation["security"] = []map[string][]string{{op.Security: {}}}
+		}
+		if op.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(g.schema(reflect.TypeOf(op.Request))),
+			}
+		}
+
+		response := map[string]interface{}{"description": "Success"}
+		content := map[string]interface{}{}
+		if op.Response != nil {
+			content = jsonContent(g.schema(reflect.TypeOf(op.Response)))
+		}
+		if op.Stream != nil {
+			// 每个 data: 事件是一个 JSON 对象，以 data: [DONE] 结束
+			content["text/event-stream"] = map[string]interface{}{"schema": g.schema(reflect.TypeOf(op.Stream))}
+		}
+		if len(content) > 0 {
+			response["content"] = content
+		}
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		operation["responses"].(map[string]interface{})[strconv.Itoa(status)] = response
+
+		item, _ := paths[path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = operation
+	}
+
+	bearer := func(description string) map[string]interface{} {
+		return map[string]interface{}{"type": "http", "scheme": "bearer", "description": description}
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": o.title, "version": o.version},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": g.components,
+			"securitySchemes": map[string]interface{}{
+				SecurityAPIKey:      bearer("Client API key"),
+				SecurityAdminToken:  bearer("ADMIN_TOKEN (or FEDERATION_TOKEN / METRICS_TOKEN for their endpoints)"),
+				SecurityWorkerToken: bearer("WORKER_TOKEN shared by workers"),
+			},
+		},
+	}
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// openAPIPath converts gin path parameters (:id, *path) to OpenAPI templates
+func openAPIPath(path string) (string, []map[string]interface{}) {
+	var params []map[string]interface{}
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			name := s[1:]
+			segments[i] = "{" + name + "}"
+			params = append(params, map[string]interface{}{
+				"name": name, "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID derives an ID such as "getV1JobsId" for undescribed routes
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, s := range strings.FieldsFunc(path, func(r rune) bool { return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') }) {
+		id += strings.ToUpper(s[:1]) + s[1:]
+	}
+	return id
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	messageType       = reflect.TypeOf(openai.Message{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaGenerator reflects Go types into JSON schemas following encoding/json rules
+// Named structs become components referenced with $ref
+type schemaGenerator struct {
+	components map[string]interface{}
+	names      map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{components: make(map[string]interface{}), names: make(map[reflect.Type]string)}
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "description": "duration in nanoseconds"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+	// 实现了 TextMarshaler 的类型按字符串编码
+	if t.Implements(textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := g.schema(t.Elem())
+		if _, ref := s["$ref"]; ref {
+			return map[string]interface{}{"allOf": []interface{}{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.componentName(t)
+			g.names[t] = name
+			// 先占位，支持递归类型
+			g.components[name] = map[string]interface{}{}
+			object := g.object(t)
+			if t == messageType {
+				// content 既可以是字符串，也可以是 text / image_url 片段数组
+				object["properties"].(map[string]interface{})["content"] = map[string]interface{}{
+					"oneOf": []interface{}{
+						map[string]interface{}{"type": "string", "nullable": true},
+						map[string]interface{}{"type": "array", "items": g.schema(reflect.TypeOf(openai.ContentPart{}))},
+					},
+				}
+			}
+			g.components[name] = object
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// componentName is the type name, qualified with its package when two packages share it
+func (g *schemaGenerator) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := g.components[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + name
+}
+
+func (g *schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	g.fields(t, properties, &required)
+	s := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
+
+func (g *schemaGenerator) fields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		// 嵌入的结构体字段提升到外层
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, properties, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s := g.schema(f.Type)
+		if strings.Contains(opts, "string") {
+			s = map[string]interface{}{"type": "string"}
+		}
+		properties[name] = s
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
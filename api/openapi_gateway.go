@@ -0,0 +1,238 @@
+package api
+
+import (
+	"net/http"
+
+	"zam/core"
+	"zam/openai"
+	"zam/router"
+	"zam/usage"
+)
+
+// Response bodies rendered with gin.H by the handlers
+
+type heartbeatResponse struct {
+	Status     string                 `json:"status"`
+	WorkerID   string                 `json:"worker_id"`
+	Directives *core.WorkerDirectives `json:"directives,omitempty"`
+}
+
+type batchHeartbeatResponse struct {
+	Status     string                           `json:"status"`
+	Host       string                           `json:"host"`
+	WorkerIDs  []string                         `json:"worker_ids"`
+	Directives map[string]core.WorkerDirectives `json:"directives,omitempty"`
+}
+
+type workerAckResponse struct {
+	Status   string `json:"status"`
+	WorkerID string `json:"worker_id"`
+}
+
+type workerListResponse struct {
+	Workers []WorkerStatus `json:"workers"`
+}
+
+type drainResponse struct {
+	Status   string `json:"status"`
+	WorkerID string `json:"worker_id"`
+	InFlight int    `json:"in_flight"`
+}
+
+type captureListResponse struct {
+	SampleRate float64                `json:"sample_rate"`
+	Captures   []core.CapturedPayload `json:"captures"`
+}
+
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+type eventListResponse struct {
+	Events []core.Event `json:"events"`
+}
+
+type experimentListResponse struct {
+	Experiments []core.ExperimentStats `json:"experiments"`
+}
+
+type telemetryResponse struct {
+	Workers []core.WorkerProfile `json:"workers"`
+}
+
+type healthResponse struct {
+	Status   string               `json:"status"`
+	Workers  int                  `json:"workers"`
+	Brownout *core.BrownoutStatus `json:"brownout,omitempty"`
+}
+
+// jobSubmitRequest is a chat completion request with the job-only callback_url
+type jobSubmitRequest struct {
+	openai.ChatCompletionRequest
+	CallbackURL string `json:"callback_url,omitempty"`
+}
+
+// DescribeGateway documents the endpoints served by the gateway
+func DescribeGateway(o *OpenAPI) {
+	// OpenAI 兼容端点
+	o.Describe(http.MethodPost, "/v1/chat/completions", Operation{
+		ID: "createChatCompletion", Summary: "Create a chat completion", Tag: "chat", Security: SecurityAPIKey,
+		Headers: []string{core.PriorityHeader, core.RequestTimeoutHeader},
+		Request: openai.ChatCompletionRequest{}, Response: openai.ChatCompletionResponse{},
+		Stream: openai.ChatCompletionStreamResponse{},
+	})
+	o.Describe(http.MethodPost, "/v1/jobs/completions", Operation{
+		ID: "createCompletionJob", Summary: "Queue a chat completion to run on idle capacity", Tag: "jobs", Security: SecurityAPIKey,
+		Headers: []string{core.PriorityHeader},
+		Request: jobSubmitRequest{}, Response: core.Job{}, Status: http.StatusAccepted,
+	})
+	o.Describe(http.MethodGet, "/v1/jobs/:id", Operation{
+		ID: "getCompletionJob", Summary: "Get a completion job and its result", Tag: "jobs", Security: SecurityAPIKey,
+		Response: core.Job{},
+	})
+	o.Describe(http.MethodPost, "/v1/route/preview", Operation{
+		ID: "previewRoute", Summary: "Show the worker a request would be routed to", Tag: "chat", Security: SecurityAPIKey,
+		Headers: []string{core.PriorityHeader},
+		Request: openai.ChatCompletionRequest{}, Response: core.RouteDecision{},
+	})
+	o.Describe(http.MethodPost, "/v1/tokenize", Operation{
+		ID: "tokenize", Summary: "Count the tokens of a conversation", Tag: "chat", Security: SecurityAPIKey,
+		Request: openai.TokenizeRequest{}, Response: openai.TokenizeResponse{},
+	})
+	o.Describe(http.MethodPost, "/v1/embeddings", Operation{
+		ID: "createEmbedding", Summary: "Embed inputs with an embedding model on a local worker", Tag: "embeddings", Security: SecurityAPIKey,
+		Request: openai.EmbeddingRequest{}, Response: openai.EmbeddingResponse{},
+	})
+	o.Describe(http.MethodGet, "/v1/models", Operation{
+		ID: "listModels", Summary: "List the models served by the alive workers", Tag: "chat", Security: SecurityAPIKey,
+		Response: openai.ModelList{},
+	})
+	o.Describe(http.MethodGet, "/v1/models/:id", Operation{
+		ID: "getModel", Summary: "Get a model and the workers serving it", Tag: "chat", Security: SecurityAPIKey,
+		Response: openai.Model{},
+	})
+	o.Describe(http.MethodGet, "/v1/organizations/:id/billing", Operation{
+		ID: "getBilling", Summary: "Get the itemized usage of an organization", Tag: "billing", Security: SecurityAPIKey,
+		Query: []string{"period", "start", "end"}, Response: usage.BillingSummary{},
+	})
+
+	// Worker 端点
+	o.Describe(http.MethodPost, "/v1/workers/register", Operation{
+		ID: "registerWorker", Summary: "Register a remote worker by its endpoint", Tag: "workers", Security: SecurityWorkerToken,
+		Headers: []string{core.RegistrationTokenHeader},
+		Request: RegisterRequest{}, Response: RegisterResponse{},
+	})
+	o.Describe(http.MethodPost, "/v1/workers/heartbeat", Operation{
+		ID: "workerHeartbeat", Summary: "Report a worker profile", Tag: "workers", Security: SecurityWorkerToken,
+		Request: core.WorkerProfile{}, Response: heartbeatResponse{},
+	})
+	o.Describe(http.MethodPost, "/v1/workers/heartbeat/batch", Operation{
+		ID: "workerBatchHeartbeat", Summary: "Report the profiles of a multi-GPU host", Tag: "workers", Security: SecurityWorkerToken,
+		Request: BatchHeartbeatRequest{}, Response: batchHeartbeatResponse{},
+	})
+	o.Describe(http.MethodGet, "/v1/workers/connect", Operation{
+		ID: "connectWorker", Summary: "Open a reverse WebSocket connection for a worker behind NAT", Tag: "workers", Security: SecurityWorkerToken,
+		Status: http.StatusSwitchingProtocols,
+	})
+	o.Describe(http.MethodDelete, "/v1/workers/:id", Operation{
+		ID: "deregisterWorker", Summary: "Remove a worker from the registry", Tag: "workers", Security: SecurityWorkerToken,
+		Response: workerAckResponse{},
+	})
+	o.Describe(http.MethodGet, "/v1/federation/profile", Operation{
+		ID: "getFederationProfile", Summary: "Get the aggregated capacity of local workers", Tag: "workers", Security: SecurityAdminToken,
+		Response: core.WorkerProfile{},
+	})
+
+	// Admin 端点
+	o.Describe(http.MethodGet, "/admin/router/weights", Operation{
+		ID: "getRouterWeights", Summary: "Get the routing weights", Tag: "admin", Security: SecurityAdminToken,
+		Response: router.Weights{},
+	})
+	o.Describe(http.MethodPut, "/admin/router/weights", Operation{
+		ID: "putRouterWeights", Summary: "Update the routing weights", Tag: "admin", Security: SecurityAdminToken,
+		Request: router.Weights{}, Response: router.Weights{},
+	})
+	o.Describe(http.MethodGet, "/admin/router/models", Operation{
+		ID: "listModelProfiles", Summary: "List the per-model VRAM and context profiles", Tag: "admin", Security: SecurityAdminToken,
+		Response: router.ModelProfiles{},
+	})
+	o.Describe(http.MethodPut, "/admin/router/models/:model", Operation{
+		ID: "putModelProfile", Summary: "Add or replace the profile of a model", Tag: "admin", Security: SecurityAdminToken,
+		Request: router.ModelProfile{}, Response: router.ModelProfile{},
+	})
+	o.Describe(http.MethodDelete, "/admin/router/models/:model", Operation{
+		ID: "deleteModelProfile", Summary: "Remove the profile of a model", Tag: "admin", Security: SecurityAdminToken,
+		Status: http.StatusNoContent,
+	})
+	o.Describe(http.MethodGet, "/admin/events", Operation{
+		ID: "listEvents", Summary: "Query the event log", Tag: "admin", Security: SecurityAdminToken,
+		Query: []string{"type", "worker_id", "since", "until", "limit"}, Response: eventListResponse{},
+	})
+	o.Describe(http.MethodGet, "/admin/captures", Operation{
+		ID: "listCaptures", Summary: "List captured payloads", Tag: "admin", Security: SecurityAdminToken,
+		Query: []string{"limit"}, Response: captureListResponse{},
+	})
+	o.Describe(http.MethodGet, "/admin/captures/:id", Operation{
+		ID: "getCapture", Summary: "Get a captured payload", Tag: "admin", Security: SecurityAdminToken,
+		Response: core.CapturedPayload{},
+	})
+	o.Describe(http.MethodDelete, "/admin/captures", Operation{
+		ID: "clearCaptures", Summary: "Drop every captured payload", Tag: "admin", Security: SecurityAdminToken,
+		Response: statusResponse{},
+	})
+	o.Describe(http.MethodPut, "/admin/captures/config", Operation{
+		ID: "putCaptureConfig", Summary: "Change the capture sample rate", Tag: "admin", Security: SecurityAdminToken,
+		Request: captureConfig{}, Response: captureConfig{},
+	})
+	o.Describe(http.MethodGet, "/admin/workers", Operation{
+		ID: "listWorkers", Summary: "List registered workers with their health, breaker and in-flight requests", Tag: "admin", Security: SecurityAdminToken,
+		Response: workerListResponse{},
+	})
+	o.Describe(http.MethodGet, "/admin/workers/:id", Operation{
+		ID: "getWorker", Summary: "Get the status of a registered worker", Tag: "admin", Security: SecurityAdminToken,
+		Response: WorkerStatus{},
+	})
+	o.Describe(http.MethodPost, "/admin/workers/:id/drain", Operation{
+		ID: "drainWorker", Summary: "Take a worker out of routing while its requests finish", Tag: "admin", Security: SecurityAdminToken,
+		Response: drainResponse{},
+	})
+	o.Describe(http.MethodPost, "/admin/workers/:id/uncordon", Operation{
+		ID: "uncordonWorker", Summary: "Return a drained worker to routing", Tag: "admin", Security: SecurityAdminToken,
+		Response: workerAckResponse{},
+	})
+	o.Describe(http.MethodGet, "/admin/workers/:id/directives", Operation{
+		ID: "getWorkerDirectives", Summary: "Get the pending directives of a worker", Tag: "admin", Security: SecurityAdminToken,
+		Response: core.WorkerDirectives{},
+	})
+	o.Describe(http.MethodPut, "/admin/workers/:id/directives", Operation{
+		ID: "putWorkerDirectives", Summary: "Set the directives delivered with a worker's heartbeats", Tag: "admin", Security: SecurityAdminToken,
+		Request: core.WorkerDirectives{}, Response: core.WorkerDirectives{},
+	})
+	o.Describe(http.MethodGet, "/admin/experiments", Operation{
+		ID: "listExperiments", Summary: "Compare the arms of A/B experiments", Tag: "admin", Security: SecurityAdminToken,
+		Response: experimentListResponse{},
+	})
+	o.Describe(http.MethodGet, "/admin/workers/telemetry", Operation{
+		ID: "getWorkerTelemetry", Summary: "Get the profiles of every registered worker", Tag: "admin", Security: SecurityAdminToken,
+		Response: telemetryResponse{},
+	})
+
+	// 运维端点
+	o.Describe(http.MethodGet, "/metrics", Operation{
+		ID: "getMetrics", Summary: "Prometheus metrics (text exposition format)", Tag: "ops", Security: SecurityAdminToken,
+	})
+	o.Describe(http.MethodGet, "/health", Operation{
+		ID: "getHealth", Summary: "Gateway health", Tag: "ops", Response: healthResponse{},
+	})
+	o.Describe(http.MethodGet, "/capacity", Operation{
+		ID: "getCapacity", Summary: "Get the healthy local capacity and suggested weight for an upstream load balancer", Tag: "ops", Security: SecurityAdminToken,
+		Response: router.GatewayCapacity{},
+	})
+	o.Describe(http.MethodGet, "/autoscale", Operation{
+		ID: "getAutoscaleSignals", Summary: "Get demand signals (queue depth, wait, shed rate, per-model backlog) for autoscalers", Tag: "ops", Security: SecurityAdminToken,
+		Response: core.DemandSignals{},
+	})
+	o.Describe(http.MethodGet, "/openapi.json", Operation{
+		ID: "getOpenAPI", Summary: "This OpenAPI document", Tag: "ops",
+	})
+}
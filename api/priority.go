@@ -0,0 +1,24 @@
+package api
+
+import (
+	"errors"
+
+	"zam/core"
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestPriority reads the X-Priority header of a request and checks it against the key's maximum
+// Requests without the header get def, capped at that maximum
+func RequestPriority(c *gin.Context, policy *core.PriorityPolicy, apiKey string, def core.Priority) (core.Priority, *openai.Error) {
+	priority, err := policy.Resolve(apiKey, c.GetHeader(core.PriorityHeader), def)
+	var notAllowed *core.PriorityNotAllowedError
+	switch {
+	case errors.As(err, &notAllowed):
+		return priority, openai.NewPermissionError(err.Error()).WithCode("priority_not_allowed")
+	case err != nil:
+		return priority, openai.NewInvalidRequestError(err.Error()).WithCode("invalid_priority")
+	}
+	return priority, nil
+}
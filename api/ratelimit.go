@@ -0,0 +1,46 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"zam/core"
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetRateLimitHeaders reports a key's request and token windows in the X-RateLimit-* headers used by OpenAI
+// Dimensions without a limit are left out
+func SetRateLimitHeaders(c *gin.Context, s core.RateLimitStatus) {
+	if s.RequestLimit > 0 {
+		c.Header("X-RateLimit-Limit-Requests", strconv.FormatInt(s.RequestLimit, 10))
+		c.Header("X-RateLimit-Remaining-Requests", strconv.FormatInt(s.RequestRemaining, 10))
+		c.Header("X-RateLimit-Reset-Requests", formatReset(s.RequestReset))
+	}
+	if s.TokenLimit > 0 {
+		c.Header("X-RateLimit-Limit-Tokens", strconv.FormatInt(s.TokenLimit, 10))
+		c.Header("X-RateLimit-Remaining-Tokens", strconv.FormatInt(s.TokenRemaining, 10))
+		c.Header("X-RateLimit-Reset-Tokens", formatReset(s.TokenReset))
+	}
+}
+
+// WriteRateLimitError answers a request refused by a RPM/TPM window with 429, the X-RateLimit-* headers and Retry-After
+func WriteRateLimitError(c *gin.Context, err *core.RateLimitError) {
+	SetRateLimitHeaders(c, err.Status)
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	WriteError(c, openai.NewError(http.StatusTooManyRequests, openai.RateLimitErrorType,
+		fmt.Sprintf("Rate limit reached: limit of %d %s per minute; please try again in %s", err.Limit, err.Window, formatReset(err.RetryAfter))).
+		WithCode("rate_limit_exceeded"))
+}
+
+// formatReset renders a reset duration the way OpenAI does, e.g. "1s", "6m0s" or "120ms"
+func formatReset(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
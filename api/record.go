@@ -0,0 +1,37 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+
+	"zam/openai"
+	"zam/replay"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecordMiddleware writes an anonymized trace of every chat completion request, including the
+// ones later refused by the limiter, so `zam replay` can reproduce the traffic
+// Bodies that do not parse are skipped and left for the handler to reject
+func RecordMiddleware(rec *replay.Recorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Next()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var req openai.ChatCompletionRequest
+		if json.Unmarshal(body, &req) == nil {
+			key := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if err := rec.Record(key, req); err != nil {
+				slog.Error("failed to record trace", "request_id", RequestID(c), "error", err)
+			}
+		}
+		c.Next()
+	}
+}
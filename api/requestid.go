@@ -0,0 +1,47 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-Id"
+
+// requestIDKey stores the request ID in the gin context
+const requestIDKey = "zam.request_id"
+
+// maxRequestIDLength bounds client-supplied request IDs
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware honors a well-formed incoming X-Request-Id or generates one, and echoes it
+// in the response headers. Handlers use it as the request's TraceID
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestID returns the ID assigned by RequestIDMiddleware, or "" when it is not installed
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// validRequestID accepts non-empty IDs of printable ASCII without spaces, so they are safe in logs and headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
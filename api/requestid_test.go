@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestIDMiddleware())
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, RequestID(c))
+	})
+
+	tests := []struct {
+		name    string
+		inbound string
+		// echoed is the expected ID, "" when the gateway must generate one
+		echoed string
+	}{
+		{name: "回显客户端提供的 ID", inbound: "req-abc_123", echoed: "req-abc_123"},
+		{name: "缺失时生成", inbound: ""},
+		{name: "含空格时重新生成", inbound: "bad id"},
+		{name: "过长时重新生成", inbound: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.inbound != "" {
+				req.Header.Set(RequestIDHeader, tt.inbound)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(RequestIDHeader)
+			if tt.echoed != "" && id != tt.echoed {
+				t.Errorf("expected %q echoed, got %q", tt.echoed, id)
+			}
+			if tt.echoed == "" {
+				if _, err := uuid.Parse(id); err != nil {
+					t.Errorf("expected a generated UUID, got %q", id)
+				}
+			}
+			// 处理器看到的 ID 与响应头一致
+			if rec.Body.String() != id {
+				t.Errorf("expected handlers to see %q, got %q", id, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"zam/core"
+	"zam/openai"
+	"zam/worker"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// reverseRegisterTimeout bounds how long a new reverse connection may take to send its register frame
+const reverseRegisterTimeout = 10 * time.Second
+
+// HandleConnect upgrades GET /v1/workers/connect to a WebSocket for workers that cannot be dialed
+// (behind NAT or a firewall). The worker sends a register frame with its profile, then heartbeat
+// frames; the gateway pushes jobs over the same connection. The worker is deregistered when the
+// connection closes, and a new connection for the same worker ID replaces the old one
+func (api *WorkerAPI) HandleConnect(c *gin.Context) {
+	registrar, ok := api.registry.(workerRegistrar)
+	if !ok {
+		WriteError(c, openai.NewError(http.StatusNotImplemented, openai.ServerErrorType, "Worker registration is not enabled"))
+		return
+	}
+	// 不校验 Origin：调用方是 Worker 进程而非浏览器，鉴权由 WORKER_TOKEN 完成
+	websocket.Server{Handler: func(conn *websocket.Conn) {
+		api.serveReverse(conn, registrar)
+	}}.ServeHTTP(c.Writer, c.Request)
+}
+
+// serveReverse registers the worker behind conn and serves it until the connection closes
+func (api *WorkerAPI) serveReverse(conn *websocket.Conn, registrar workerRegistrar) {
+	defer conn.Close()
+	reject := func(message string) {
+		websocket.JSON.Send(conn, worker.ReverseFrame{Type: worker.ReverseError, Error: message})
+	}
+
+	var hello worker.ReverseFrame
+	conn.SetReadDeadline(time.Now().Add(reverseRegisterTimeout))
+	if err := websocket.JSON.Receive(conn, &hello); err != nil {
+		slog.Warn("reverse connection closed before registering", "remote_addr", conn.Request().RemoteAddr, "error", err)
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	// 验证必需字段
+	switch {
+	case hello.Type != worker.ReverseRegister || hello.Profile == nil:
+		reject("the first frame must be a register frame carrying the worker profile")
+		return
+	case hello.Profile.WorkerID == "":
+		reject("worker_id is required")
+		return
+	case len(hello.Profile.Supported) == 0:
+		reject("supported models must not be empty")
+		return
+	case hello.Profile.MaxTasks <= 0:
+		reject("max_tasks must be a positive integer")
+		return
+	}
+	profile := *hello.Profile
+	// 反向连接的 Worker 没有可拨入的地址
+	profile.Endpoint = ""
+	workerID := profile.WorkerID
+	rw := worker.NewReverseWorker(conn, profile)
+
+	api.reverseMu.Lock()
+	previous, reconnect := api.reverse[workerID]
+	if !reconnect && api.alive(workerID) {
+		api.reverseMu.Unlock()
+		reject("Worker " + workerID + " is already registered")
+		return
+	}
+	api.reverse[workerID] = rw
+	api.reverseMu.Unlock()
+	// 同一 Worker 重连时断开旧连接，旧连接上的任务随之失败
+	if previous != nil {
+		previous.Close()
+	}
+
+	api.applyDirectives(&profile)
+	if err := registrar.RegisterWorker(rw, profile); err != nil {
+		reject("Failed to register worker: " + err.Error())
+		api.dropReverse(rw)
+		return
+	}
+	api.queue.Notify()
+	slog.Info("reverse worker connected", "worker_id", workerID, "remote_addr", conn.Request().RemoteAddr)
+
+	// 首个指令帧告知 Worker 心跳间隔
+	if d := api.takeDirectives(workerID); d != nil {
+		if err := rw.Send(worker.ReverseFrame{Type: worker.ReverseDirectives, Directives: d}); err != nil {
+			slog.Warn("failed to send directives to reverse worker", "worker_id", workerID, "error", err)
+		}
+	}
+
+	err := rw.Serve(func(profile core.WorkerProfile) *core.WorkerDirectives {
+		profile.Endpoint = ""
+		api.applyDirectives(&profile)
+		if err := api.registry.Heartbeat(profile); err != nil {
+			slog.Warn("reverse worker heartbeat rejected", "worker_id", workerID, "error", err)
+			return nil
+		}
+		api.queue.Notify()
+		return api.takeDirectives(workerID)
+	})
+	slog.Info("reverse worker disconnected", "worker_id", workerID, "error", err)
+
+	if api.dropReverse(rw) {
+		api.registry.Deregister(workerID)
+		api.events.Publish(core.Event{
+			Type:     core.EventWorkerDeregistered,
+			WorkerID: workerID,
+			Message:  "reverse connection closed",
+		})
+	}
+}
+
+// dropReverse forgets rw unless a newer connection of the same worker replaced it, reporting whether it was current
+func (api *WorkerAPI) dropReverse(rw *worker.ReverseWorker) bool {
+	api.reverseMu.Lock()
+	defer api.reverseMu.Unlock()
+	if api.reverse[rw.ID()] != rw {
+		return false
+	}
+	delete(api.reverse, rw.ID())
+	return true
+}
+
+// takeDirectives returns the directives to deliver to a worker, or nil when directives are disabled
+func (api *WorkerAPI) takeDirectives(workerID string) *core.WorkerDirectives {
+	if api.directives == nil {
+		return nil
+	}
+	d := api.directives.Take(workerID)
+	return &d
+}
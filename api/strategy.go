@@ -0,0 +1,24 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"zam/core"
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestStrategy reads the X-Zam-Router header of a request naming its router strategy
+// An empty name leaves the choice to the router's default; unknown names are rejected
+func RequestStrategy(c *gin.Context, router core.Router) (string, *openai.Error) {
+	name := strings.ToLower(strings.TrimSpace(c.GetHeader(core.StrategyHeader)))
+	if name == "" {
+		return "", nil
+	}
+	if strategies, ok := router.(core.StrategyRouter); !ok || !strategies.HasStrategy(name) {
+		return "", openai.NewInvalidRequestError(fmt.Sprintf("Unknown routing strategy %q in %s header", name, core.StrategyHeader)).WithCode("invalid_router_strategy")
+	}
+	return name, nil
+}
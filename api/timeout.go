@@ -0,0 +1,20 @@
+package api
+
+import (
+	"time"
+
+	"zam/core"
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestTimeout reads the X-Request-Timeout-Ms header of a request, bounded by the server policy
+// Zero means the request has no deadline
+func RequestTimeout(c *gin.Context, policy core.TimeoutPolicy) (time.Duration, *openai.Error) {
+	timeout, err := policy.Resolve(c.GetHeader(core.RequestTimeoutHeader))
+	if err != nil {
+		return 0, openai.NewInvalidRequestError(err.Error()).WithCode("invalid_request_timeout")
+	}
+	return timeout, nil
+}
@@ -1,23 +1,95 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
 
 	"zam/core"
+	"zam/openai"
+	"zam/worker"
 
 	"github.com/gin-gonic/gin"
 )
 
 // WorkerAPI handles worker-related API endpoints
 type WorkerAPI struct {
-	registry core.WorkerRegistry
+	registry   core.WorkerRegistry
+	directives *core.DirectiveStore
+	events     *core.EventBus
+	tokens     *core.RegistrationTokens
+	newWorker  func(profile core.WorkerProfile, authToken string) core.Worker
+	quarantine *core.Quarantine
+	inflight   *core.InflightTracker
+	queue      *core.WaitQueue
+
+	reverseMu sync.Mutex
+	// reverse holds the live connection of each worker connected through /v1/workers/connect
+	reverse map[string]*worker.ReverseWorker
 }
 
 // NewWorkerAPI creates a new WorkerAPI
 func NewWorkerAPI(registry core.WorkerRegistry) *WorkerAPI {
 	return &WorkerAPI{
 		registry: registry,
+		reverse:  make(map[string]*worker.ReverseWorker),
+	}
+}
+
+// SetDirectives enables returning gateway directives in heartbeat responses
+func (api *WorkerAPI) SetDirectives(store *core.DirectiveStore) {
+	api.directives = store
+}
+
+// SetEvents enables publishing worker lifecycle events
+func (api *WorkerAPI) SetEvents(bus *core.EventBus) {
+	api.events = bus
+}
+
+// SetRegistration enables POST /v1/workers/register; newWorker builds the worker the gateway
+// calls, authenticating with the token the worker registered with
+func (api *WorkerAPI) SetRegistration(newWorker func(profile core.WorkerProfile, authToken string) core.Worker) {
+	api.newWorker = newWorker
+	api.tokens = core.NewRegistrationTokens()
+}
+
+// SetHealthSources adds breaker states, error rates and in-flight counts to the admin worker listing
+func (api *WorkerAPI) SetHealthSources(quarantine *core.Quarantine, inflight *core.InflightTracker) {
+	api.quarantine = quarantine
+	api.inflight = inflight
+}
+
+// SetWaitQueue wakes queued requests to retry routing whenever a worker reports fresh capacity
+func (api *WorkerAPI) SetWaitQueue(queue *core.WaitQueue) {
+	api.queue = queue
+}
+
+// applyDirectives enforces the standing directives on a reported profile before it is stored,
+// so routing honors them even if the worker has not acted on them yet
+func (api *WorkerAPI) applyDirectives(profile *core.WorkerProfile) {
+	if api.directives == nil {
+		return
+	}
+	d := api.directives.Get(profile.WorkerID)
+	if d.MaxTasks > 0 {
+		profile.MaxTasks = d.MaxTasks
+	}
+	// 排空中的 Worker 不再接收新请求
+	if d.Drain {
+		profile.MaxTasks = 0
+	}
+}
+
+// validEndpoint reports whether a reported endpoint is an absolute http(s) URL; workers without one are accepted
+func validEndpoint(endpoint string) bool {
+	if endpoint == "" {
+		return true
 	}
+	u, err := url.Parse(endpoint)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
 }
 
 // HandleHeartbeat handles worker heartbeat requests
@@ -25,40 +97,439 @@ func (api *WorkerAPI) HandleHeartbeat(c *gin.Context) {
 	// 解析 Worker Profile
 	var profile core.WorkerProfile
 	if err := c.ShouldBindJSON(&profile); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"message": "Invalid request body: " + err.Error(),
-				"type":    "invalid_request_error",
-			},
-		})
+		WriteError(c, openai.NewInvalidRequestError("Invalid request body: "+err.Error()))
 		return
 	}
 
 	// 验证必需字段
 	if profile.WorkerID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"message": "worker_id is required",
-				"type":    "invalid_request_error",
-			},
-		})
+		WriteError(c, openai.NewInvalidRequestError("worker_id is required"))
+		return
+	}
+	if !validEndpoint(profile.Endpoint) {
+		WriteError(c, openai.NewInvalidRequestError("endpoint must be an absolute http or https URL").WithParam("endpoint"))
+		return
+	}
+	if !api.tokens.Verify(profile.WorkerID, c.GetHeader(core.RegistrationTokenHeader)) {
+		WriteError(c, openai.NewAuthenticationError("Invalid registration token for worker "+profile.WorkerID))
 		return
 	}
 
 	// 更新注册中心
+	api.applyDirectives(&profile)
 	if err := api.registry.Heartbeat(profile); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": "Failed to update registry: " + err.Error(),
-				"type":    "server_error",
-			},
-		})
+		if errors.Is(err, core.ErrStaleIncarnation) {
+			WriteError(c, openai.NewError(http.StatusConflict, openai.InvalidRequestErrorType, "Heartbeat from a stale worker incarnation; restart with a higher incarnation to rejoin").WithCode("stale_incarnation"))
+			return
+		}
+
+		WriteError(c, openai.NewServerError(http.StatusInternalServerError, "Failed to update registry: "+err.Error()))
 		return
 	}
+	api.queue.Notify()
 
-	// 返回成功响应
-	c.JSON(http.StatusOK, gin.H{
-		"status": "ok",
+	// 返回成功响应，附带网关下发的指令
+	resp := gin.H{
+		"status":    "ok",
 		"worker_id": profile.WorkerID,
+	}
+	if api.directives != nil {
+		resp["directives"] = api.directives.Take(profile.WorkerID)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// HandleDeregister removes a worker from the registry
+// Its current incarnation is tombstoned so in-flight heartbeats cannot resurrect it
+func (api *WorkerAPI) HandleDeregister(c *gin.Context) {
+	workerID := c.Param("id")
+	if !api.tokens.Verify(workerID, c.GetHeader(core.RegistrationTokenHeader)) {
+		WriteError(c, openai.NewAuthenticationError("Invalid registration token for worker "+workerID))
+		return
+	}
+	if err := api.registry.Deregister(workerID); err != nil {
+		if errors.Is(err, core.ErrWorkerNotFound) {
+			WriteError(c, openai.NewNotFoundError("Worker not found: "+workerID))
+			return
+		}
+		WriteError(c, openai.NewServerError(http.StatusInternalServerError, "Failed to deregister worker: "+err.Error()))
+		return
+	}
+
+	if api.directives != nil {
+		api.directives.Delete(workerID)
+	}
+	api.tokens.Revoke(workerID)
+	api.events.Publish(core.Event{
+		Type:     core.EventWorkerDeregistered,
+		WorkerID: workerID,
+		Message:  "worker deregistered",
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "deregistered",
+		"worker_id": workerID,
+	})
+}
+
+// RegisterRequest is the body of POST /v1/workers/register
+type RegisterRequest struct {
+	WorkerID string `json:"worker_id"`
+	// Endpoint is the OpenAI-compatible chat completions URL the gateway sends requests to
+	Endpoint string `json:"endpoint"`
+	// AuthToken is sent by the gateway as a bearer token with every request to the endpoint
+	AuthToken   string   `json:"auth_token,omitempty"`
+	Models      []string `json:"models"`
+	MaxTasks    int      `json:"max_tasks"`
+	Incarnation uint64   `json:"incarnation,omitempty"`
+	// TotalVRAM is the worker's VRAM in bytes; later heartbeats report the available share
+	TotalVRAM    uint64            `json:"total_vram,omitempty"`
+	Capabilities core.Capabilities `json:"capabilities,omitempty"`
+}
+
+// RegisterResponse tells a registered worker how to keep its registration alive
+type RegisterResponse struct {
+	Status   string `json:"status"`
+	WorkerID string `json:"worker_id"`
+	// RegistrationToken must be sent in the X-Zam-Registration-Token header of later heartbeats
+	RegistrationToken        string `json:"registration_token"`
+	HeartbeatIntervalSeconds int    `json:"heartbeat_interval_seconds"`
+}
+
+// workerRegistrar is implemented by registries accepting explicitly constructed workers
+type workerRegistrar interface {
+	RegisterWorker(worker core.Worker, profile core.WorkerProfile) error
+}
+
+// HandleRegister registers a remote worker: the gateway builds its HTTP worker from the endpoint and auth token,
+// stores it in the registry and issues the registration token its heartbeats must carry from then on
+// While the worker is alive, registering the same ID again requires its current token
+func (api *WorkerAPI) HandleRegister(c *gin.Context) {
+	registrar, ok := api.registry.(workerRegistrar)
+	if api.newWorker == nil || !ok {
+		WriteError(c, openai.NewError(http.StatusNotImplemented, openai.ServerErrorType, "Worker registration is not enabled"))
+		return
+	}
+
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		WriteError(c, openai.NewInvalidRequestError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	// 验证必需字段
+	switch {
+	case req.WorkerID == "":
+		WriteError(c, openai.NewInvalidRequestError("worker_id is required").WithParam("worker_id"))
+		return
+	case req.Endpoint == "" || !validEndpoint(req.Endpoint):
+		WriteError(c, openai.NewInvalidRequestError("endpoint must be an absolute http or https URL").WithParam("endpoint"))
+		return
+	case len(req.Models) == 0:
+		WriteError(c, openai.NewInvalidRequestError("models must not be empty").WithParam("models"))
+		return
+	case req.MaxTasks <= 0:
+		WriteError(c, openai.NewInvalidRequestError("max_tasks must be a positive integer").WithParam("max_tasks"))
+		return
+	}
+	if api.alive(req.WorkerID) && !api.tokens.Verify(req.WorkerID, c.GetHeader(core.RegistrationTokenHeader)) {
+		WriteError(c, openai.NewError(http.StatusConflict, openai.InvalidRequestErrorType, "Worker "+req.WorkerID+" is already registered").WithCode("worker_already_registered"))
+		return
+	}
+
+	profile := core.WorkerProfile{
+		WorkerID:      req.WorkerID,
+		Incarnation:   req.Incarnation,
+		Endpoint:      req.Endpoint,
+		Supported:     req.Models,
+		MaxTasks:      req.MaxTasks,
+		TotalVRAM:     req.TotalVRAM,
+		AvailableVRAM: req.TotalVRAM,
+		Capabilities:  req.Capabilities,
+	}
+	api.applyDirectives(&profile)
+	if err := registrar.RegisterWorker(api.newWorker(profile, req.AuthToken), profile); err != nil {
+		WriteError(c, openai.NewServerError(http.StatusInternalServerError, "Failed to register worker: "+err.Error()))
+		return
+	}
+	api.queue.Notify()
+	token, err := api.tokens.Issue(req.WorkerID)
+	if err != nil {
+		WriteError(c, openai.NewServerError(http.StatusInternalServerError, "Failed to issue registration token: "+err.Error()))
+		return
+	}
+
+	interval := int(core.DefaultHeartbeatInterval / time.Second)
+	if api.directives != nil {
+		interval = api.directives.Get(req.WorkerID).HeartbeatIntervalSeconds
+	}
+	c.JSON(http.StatusOK, RegisterResponse{
+		Status:                   "registered",
+		WorkerID:                 req.WorkerID,
+		RegistrationToken:        token,
+		HeartbeatIntervalSeconds: interval,
+	})
+}
+
+// alive reports whether a worker is currently schedulable
+func (api *WorkerAPI) alive(workerID string) bool {
+	for _, w := range api.registry.GetAvailableWorkers() {
+		if w.ID() == workerID {
+			return true
+		}
+	}
+	return false
+}
+
+// BatchHeartbeatRequest is the body of a bulk heartbeat from a multi-GPU host
+type BatchHeartbeatRequest struct {
+	Host    string               `json:"host"`
+	Workers []core.WorkerProfile `json:"workers"`
+}
+
+// HandleBatchHeartbeat handles one heartbeat carrying a profile per GPU
+// The registry update is atomic: a single invalid profile rejects the whole batch
+func (api *WorkerAPI) HandleBatchHeartbeat(c *gin.Context) {
+	var req BatchHeartbeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		WriteError(c, openai.NewInvalidRequestError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	// 验证必需字段
+	if len(req.Workers) == 0 {
+		WriteError(c, openai.NewInvalidRequestError("workers must not be empty"))
+		return
+	}
+	seen := make(map[string]bool, len(req.Workers))
+	workerIDs := make([]string, 0, len(req.Workers))
+	for _, profile := range req.Workers {
+		if profile.WorkerID == "" || seen[profile.WorkerID] {
+			WriteError(c, openai.NewInvalidRequestError("every worker needs a unique worker_id"))
+			return
+		}
+		if !validEndpoint(profile.Endpoint) {
+			WriteError(c, openai.NewInvalidRequestError("endpoint of worker "+profile.WorkerID+" must be an absolute http or https URL").WithParam("endpoint"))
+			return
+		}
+		if !api.tokens.Verify(profile.WorkerID, c.GetHeader(core.RegistrationTokenHeader)) {
+			WriteError(c, openai.NewAuthenticationError("Invalid registration token for worker "+profile.WorkerID))
+			return
+		}
+		seen[profile.WorkerID] = true
+		workerIDs = append(workerIDs, profile.WorkerID)
+	}
+
+	// 原子更新注册中心
+	for i := range req.Workers {
+		api.applyDirectives(&req.Workers[i])
+	}
+	if err := api.registry.HeartbeatBatch(req.Workers); err != nil {
+		status, errType := http.StatusInternalServerError, openai.ServerErrorType
+		if errors.Is(err, core.ErrStaleIncarnation) {
+			status, errType = http.StatusConflict, openai.InvalidRequestErrorType
+		}
+		WriteError(c, openai.NewError(status, errType, "Failed to update registry: "+err.Error()))
+		return
+	}
+	api.queue.Notify()
+
+	resp := gin.H{
+		"status":     "ok",
+		"host":       req.Host,
+		"worker_ids": workerIDs,
+	}
+	if api.directives != nil {
+		directives := make(map[string]core.WorkerDirectives, len(workerIDs))
+		for _, id := range workerIDs {
+			directives[id] = api.directives.Take(id)
+		}
+		resp["directives"] = directives
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// HandleGetDirectives returns the pending directives of a worker
+func (api *WorkerAPI) HandleGetDirectives(c *gin.Context) {
+	if api.directives == nil {
+		WriteError(c, openai.NewNotFoundError("Heartbeat directives are not enabled"))
+		return
+	}
+	c.JSON(http.StatusOK, api.directives.Get(c.Param("id")))
+}
+
+// HandlePutDirectives replaces the directives delivered with a worker's next heartbeats
+func (api *WorkerAPI) HandlePutDirectives(c *gin.Context) {
+	if api.directives == nil {
+		WriteError(c, openai.NewNotFoundError("Heartbeat directives are not enabled"))
+		return
+	}
+
+	var d core.WorkerDirectives
+	if err := c.ShouldBindJSON(&d); err != nil {
+		WriteError(c, openai.NewInvalidRequestError("Invalid request body: "+err.Error()))
+		return
+	}
+	if d.MaxTasks < 0 || d.HeartbeatIntervalSeconds < 0 {
+		WriteError(c, openai.NewInvalidRequestError("max_tasks and heartbeat_interval_seconds must not be negative"))
+		return
+	}
+
+	workerID := c.Param("id")
+	api.directives.Set(workerID, d)
+	c.JSON(http.StatusOK, api.directives.Get(workerID))
+}
+
+// WorkerStatus is an operator's view of one registered worker: what it reported, when, and how routing sees it
+type WorkerStatus struct {
+	WorkerID string             `json:"worker_id"`
+	Profile  core.WorkerProfile `json:"profile"`
+	LastSeen time.Time          `json:"last_seen"`
+	// Health is "degraded" once heartbeats are late
+	Health core.HealthState `json:"health"`
+	// Schedulable is false for cordoned workers and for workers known only from heartbeats without an endpoint
+	Schedulable bool               `json:"schedulable"`
+	Cordoned    bool               `json:"cordoned"`
+	InFlight    int                `json:"in_flight"`
+	Breaker     core.BreakerReport `json:"breaker"`
+}
+
+// workerLister is implemented by registries that can list their workers with heartbeat times
+type workerLister interface {
+	Registered() []core.RegisteredWorker
+	WorkerState(workerID string) core.WorkerState
+	Cordoned(workerID string) bool
+}
+
+// HandleList returns every registered worker with its profile, last heartbeat, in-flight requests,
+// breaker state and rolling error rate, sorted by ID
+func (api *WorkerAPI) HandleList(c *gin.Context) {
+	lister, ok := api.registry.(workerLister)
+	if !ok {
+		WriteError(c, openai.NewError(http.StatusNotImplemented, openai.ServerErrorType, "Registry does not support listing workers"))
+		return
+	}
+	registered := lister.Registered()
+	sort.Slice(registered, func(i, j int) bool { return registered[i].Profile.WorkerID < registered[j].Profile.WorkerID })
+
+	workers := make([]WorkerStatus, 0, len(registered))
+	for _, rw := range registered {
+		workers = append(workers, api.status(lister, rw))
+	}
+	c.JSON(http.StatusOK, gin.H{"workers": workers})
+}
+
+// HandleGet returns the status of one registered worker
+func (api *WorkerAPI) HandleGet(c *gin.Context) {
+	lister, ok := api.registry.(workerLister)
+	if !ok {
+		WriteError(c, openai.NewError(http.StatusNotImplemented, openai.ServerErrorType, "Registry does not support listing workers"))
+		return
+	}
+	workerID := c.Param("id")
+	for _, rw := range lister.Registered() {
+		if rw.Profile.WorkerID == workerID {
+			c.JSON(http.StatusOK, api.status(lister, rw))
+			return
+		}
+	}
+	WriteError(c, openai.NewNotFoundError("Worker not found: "+workerID))
+}
+
+func (api *WorkerAPI) status(lister workerLister, rw core.RegisteredWorker) WorkerStatus {
+	id := rw.Profile.WorkerID
+	s := WorkerStatus{
+		WorkerID:    id,
+		Profile:     rw.Profile,
+		LastSeen:    rw.LastSeen,
+		Health:      lister.WorkerState(id).Health,
+		Schedulable: rw.Worker != nil && !lister.Cordoned(id),
+		Cordoned:    lister.Cordoned(id),
+		Breaker:     api.quarantine.Report(id),
+	}
+	if api.inflight != nil {
+		s.InFlight = api.inflight.WorkerCount(id)
+	}
+	return s
+}
+
+// workerCordoner is implemented by registries that can take workers out of routing without removing them
+type workerCordoner interface {
+	Cordon(workerID string) error
+	Uncordon(workerID string) (bool, error)
+}
+
+// HandleDrain cordons a worker: it is excluded from routing candidates while the requests and streams
+// already running on it finish. The worker is also told to drain through its heartbeat directives
+// Poll GET /admin/workers/:id until in_flight reaches 0 before taking the node down
+func (api *WorkerAPI) HandleDrain(c *gin.Context) {
+	cordoner, ok := api.registry.(workerCordoner)
+	if !ok {
+		WriteError(c, openai.NewError(http.StatusNotImplemented, openai.ServerErrorType, "Registry does not support draining workers"))
+		return
+	}
+	workerID := c.Param("id")
+	if err := cordoner.Cordon(workerID); err != nil {
+		if errors.Is(err, core.ErrWorkerNotFound) {
+			WriteError(c, openai.NewNotFoundError("Worker not found: "+workerID))
+			return
+		}
+		WriteError(c, openai.NewServerError(http.StatusInternalServerError, "Failed to drain worker: "+err.Error()))
+		return
+	}
+	if api.directives != nil {
+		d := api.directives.Get(workerID)
+		d.Drain = true
+		api.directives.Set(workerID, d)
+	}
+	api.events.Publish(core.Event{
+		Type:     core.EventWorkerDrained,
+		WorkerID: workerID,
+		Message:  "worker cordoned for draining",
+	})
+
+	inFlight := 0
+	if api.inflight != nil {
+		inFlight = api.inflight.WorkerCount(workerID)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "draining",
+		"worker_id": workerID,
+		"in_flight": inFlight,
+	})
+}
+
+// HandleUncordon returns a drained worker to routing and withdraws its drain directive
+func (api *WorkerAPI) HandleUncordon(c *gin.Context) {
+	cordoner, ok := api.registry.(workerCordoner)
+	if !ok {
+		WriteError(c, openai.NewError(http.StatusNotImplemented, openai.ServerErrorType, "Registry does not support draining workers"))
+		return
+	}
+	workerID := c.Param("id")
+	uncordoned, err := cordoner.Uncordon(workerID)
+	if err != nil {
+		WriteError(c, openai.NewServerError(http.StatusInternalServerError, "Failed to uncordon worker: "+err.Error()))
+		return
+	}
+	if !uncordoned {
+		WriteError(c, openai.NewNotFoundError("Worker is not cordoned: "+workerID))
+		return
+	}
+	if api.directives != nil {
+		d := api.directives.Get(workerID)
+		d.Drain = false
+		api.directives.Set(workerID, d)
+	}
+	api.events.Publish(core.Event{
+		Type:     core.EventWorkerUncordoned,
+		WorkerID: workerID,
+		Message:  "worker returned to routing",
+	})
+	api.queue.Notify()
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "ok",
+		"worker_id": workerID,
 	})
 }
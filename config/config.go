@@ -0,0 +1,494 @@
+// Package config loads the gateway configuration: a YAML or TOML file named by --config
+// on top of built-in defaults, with the matching environment variables taking precedence.
+// A Reloader applies edits to the file while the gateway runs
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"zam/core"
+	"zam/router"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the gateway configuration; every field has a default, so a file only lists what differs
+type Config struct {
+	Server     ServerConfig    `yaml:"server" toml:"server"`
+	Router     RouterConfig    `yaml:"router" toml:"router"`
+	RateLimits RateLimitConfig `yaml:"rate_limits" toml:"rate_limits"`
+	// ModelAliases maps client-facing model names to deployed models, e.g. {"gpt-4": "llama-3-70b-q4"}
+	ModelAliases map[string]string `yaml:"model_aliases" toml:"model_aliases"`
+	// MockWorkers are simulated workers registered at startup for local development
+	MockWorkers []MockWorker `yaml:"mock_workers" toml:"mock_workers"`
+}
+
+// ServerConfig holds the listen port and the gateway's timeouts
+type ServerConfig struct {
+	Port int `yaml:"port" toml:"port"`
+	// ShutdownTimeout bounds how long shutdown waits for in-flight requests and streams
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	// RequestTimeout is the default request deadline (0 = none); RequestTimeoutMax caps X-Request-Timeout-Ms
+	RequestTimeout    Duration `yaml:"request_timeout" toml:"request_timeout"`
+	RequestTimeoutMax Duration `yaml:"request_timeout_max" toml:"request_timeout_max"`
+	// QueueSize and QueueTimeout bound the per-model wait queue; a zero timeout disables queueing
+	QueueSize    int      `yaml:"queue_size" toml:"queue_size"`
+	QueueTimeout Duration `yaml:"queue_timeout" toml:"queue_timeout"`
+	// HeartbeatInterval is the heartbeat period the gateway asks workers to use
+	HeartbeatInterval Duration `yaml:"heartbeat_interval" toml:"heartbeat_interval"`
+}
+
+// RouterConfig is the default routing strategy and the ScoreRouter's weights and thresholds
+type RouterConfig struct {
+	Strategy          string        `yaml:"strategy" toml:"strategy"`
+	Weights           WeightsConfig `yaml:"weights" toml:"weights"`
+	VRAMHeadroomGB    float64       `yaml:"vram_headroom_gb" toml:"vram_headroom_gb"`
+	VRAMHeadroomRatio float64       `yaml:"vram_headroom_ratio" toml:"vram_headroom_ratio"`
+	KVCacheSaturation float64       `yaml:"kv_cache_saturation" toml:"kv_cache_saturation"`
+	MaxLoad           float64       `yaml:"max_load" toml:"max_load"`
+	DegradedPenalty   float64       `yaml:"degraded_penalty" toml:"degraded_penalty"`
+	PriorityReserve   float64       `yaml:"priority_reserve" toml:"priority_reserve"`
+}
+
+// WeightsConfig mirrors router.Weights
+type WeightsConfig struct {
+	VRAM    float64 `yaml:"vram" toml:"vram"`
+	Load    float64 `yaml:"load" toml:"load"`
+	Adapter float64 `yaml:"adapter" toml:"adapter"`
+	Latency float64 `yaml:"latency" toml:"latency"`
+	Cost    float64 `yaml:"cost" toml:"cost"`
+}
+
+// RateLimitConfig holds the initial token balances and the per-plan request/token windows
+type RateLimitConfig struct {
+	// Balances are the token balances API keys start with
+	Balances map[string]int `yaml:"balances" toml:"balances"`
+	// Plans limit requests and tokens per minute by the plan of the key's owner ("default" covers the rest)
+	Plans map[string]PlanLimit `yaml:"plans" toml:"plans"`
+}
+
+// PlanLimit is the per-minute request and token limit of a plan; 0 leaves a dimension unlimited
+type PlanLimit struct {
+	RPM int64 `yaml:"rpm" toml:"rpm"`
+	TPM int64 `yaml:"tpm" toml:"tpm"`
+}
+
+// MockWorker declares a simulated worker
+type MockWorker struct {
+	ID       string   `yaml:"id" toml:"id"`
+	Models   []string `yaml:"models" toml:"models"`
+	VRAMGB   float64  `yaml:"vram_gb" toml:"vram_gb"`
+	MaxTasks int      `yaml:"max_tasks" toml:"max_tasks"`
+	Fallback bool     `yaml:"fallback" toml:"fallback"`
+}
+
+// Duration is a time.Duration written as a string such as "30s" or "5m"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: expected e.g. 500ms, 30s or 5m", text)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// UnmarshalYAML reports the line of an invalid duration, which yaml.v3 omits for text unmarshalers
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	if err := d.UnmarshalText([]byte(node.Value)); err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Default returns the configuration used without a config file
+func Default() Config {
+	weights := router.DefaultWeights()
+	rc := router.DefaultConfig()
+	return Config{
+		Server: ServerConfig{
+			Port:              8080,
+			ShutdownTimeout:   Duration(core.DefaultShutdownTimeout),
+			RequestTimeoutMax: Duration(core.DefaultMaxRequestTimeout),
+			QueueSize:         core.DefaultQueueSize,
+			QueueTimeout:      Duration(core.DefaultQueueTimeout),
+			HeartbeatInterval: Duration(core.DefaultHeartbeatInterval),
+		},
+		Router: RouterConfig{
+			Strategy: router.StrategyScore,
+			Weights: WeightsConfig{
+				VRAM:    weights.VRAM,
+				Load:    weights.Load,
+				Adapter: weights.Adapter,
+				Latency: weights.Latency,
+				Cost:    weights.Cost,
+			},
+			KVCacheSaturation: rc.KVCacheSaturation,
+			MaxLoad:           rc.MaxLoad,
+			DegradedPenalty:   rc.DegradedPenalty,
+		},
+		RateLimits: RateLimitConfig{
+			// 测试账户：test-key-123，初始余额 100 个 Token
+			Balances: map[string]int{"test-key-123": 100},
+		},
+		MockWorkers: []MockWorker{
+			// 模拟 4070TiS Worker (12GB VRAM)
+			{ID: "gpu-4070tis-01", Models: []string{"gpt-3.5-turbo", "gpt-4", "llama-7b", "llama-13b"}, VRAMGB: 12, MaxTasks: 2},
+			// 模拟 2060 Worker (6GB VRAM)
+			{ID: "gpu-2060-01", Models: []string{"gpt-3.5-turbo", "llama-7b"}, VRAMGB: 6, MaxTasks: 1},
+			// 模拟 Fallback Cloud Worker，支持所有模型
+			{ID: "cloud-fallback", Models: []string{"*"}, MaxTasks: 100, Fallback: true},
+		},
+	}
+}
+
+// Load builds the configuration: Default, then the file at path (skipped when empty), then the
+// environment variables read through getenv. The result is validated
+func Load(path string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if cfg, err = Parse(data, filepath.Ext(path)); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := cfg.ApplyEnv(getenv); err != nil {
+		return Config{}, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// Parse decodes a config document on top of Default; format is the file extension
+// (".yaml", ".yml" or ".toml"). Keys that match no setting are rejected, so a typo fails
+// loudly instead of silently keeping the default
+// Sections replace their defaults key by key, but lists and maps (mock_workers, balances, plans) are replaced whole
+func Parse(data []byte, format string) (Config, error) {
+	cfg := Default()
+	// 文件中出现的列表与映射整体替换默认值，而不是与之合并
+	var present map[string]interface{}
+
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "yaml", "yml":
+		if err := yaml.Unmarshal(data, &present); err != nil {
+			return Config{}, fmt.Errorf("invalid YAML: %w", err)
+		}
+		clearReplaced(&cfg, present)
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return Config{}, fmt.Errorf("invalid YAML: %w", err)
+		}
+	case "toml":
+		if err := toml.Unmarshal(data, &present); err != nil {
+			return Config{}, tomlError(err)
+		}
+		clearReplaced(&cfg, present)
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			return Config{}, tomlError(err)
+		}
+	default:
+		return Config{}, fmt.Errorf("unsupported config format %q: use .yaml, .yml or .toml", format)
+	}
+	return cfg, nil
+}
+
+// clearReplaced empties the default lists and maps the document sets, so decoding replaces them
+func clearReplaced(cfg *Config, present map[string]interface{}) {
+	if _, ok := present["mock_workers"]; ok {
+		cfg.MockWorkers = nil
+	}
+	if _, ok := present["model_aliases"]; ok {
+		cfg.ModelAliases = nil
+	}
+	limits, _ := present["rate_limits"].(map[string]interface{})
+	if _, ok := limits["balances"]; ok {
+		cfg.RateLimits.Balances = nil
+	}
+	if _, ok := limits["plans"]; ok {
+		cfg.RateLimits.Plans = nil
+	}
+}
+
+// tomlError expands go-toml's errors with the offending position and keys
+func tomlError(err error) error {
+	var decodeErr *toml.DecodeError
+	if errors.As(err, &decodeErr) {
+		row, col := decodeErr.Position()
+		return fmt.Errorf("invalid TOML: line %d, column %d: %s", row, col, decodeErr.Error())
+	}
+	var strictErr *toml.StrictMissingError
+	if errors.As(err, &strictErr) {
+		var keys []string
+		for _, e := range strictErr.Errors {
+			row, _ := e.Position()
+			keys = append(keys, fmt.Sprintf("%s (line %d)", strings.Join(e.Key(), "."), row))
+		}
+		return fmt.Errorf("invalid TOML: unknown keys %s", strings.Join(keys, ", "))
+	}
+	return fmt.Errorf("invalid TOML: %w", err)
+}
+
+// ApplyEnv overrides the configuration with the environment variables that predate the config file,
+// so existing deployments keep working: PORT, SHUTDOWN_TIMEOUT, REQUEST_TIMEOUT, REQUEST_TIMEOUT_MAX,
+// QUEUE_SIZE, QUEUE_TIMEOUT, HEARTBEAT_INTERVAL, ROUTER_CONFIG, ROUTER_STRATEGY, RATE_LIMITS and MODEL_ALIASES
+func (c *Config) ApplyEnv(getenv func(string) string) error {
+	if v := getenv("PORT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("PORT must be a port number")
+		}
+		c.Server.Port = n
+	}
+	durations := []struct {
+		env string
+		dst *Duration
+	}{
+		{"SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout},
+		{"REQUEST_TIMEOUT", &c.Server.RequestTimeout},
+		{"REQUEST_TIMEOUT_MAX", &c.Server.RequestTimeoutMax},
+		{"QUEUE_TIMEOUT", &c.Server.QueueTimeout},
+		{"HEARTBEAT_INTERVAL", &c.Server.HeartbeatInterval},
+	}
+	for _, d := range durations {
+		if v := getenv(d.env); v != "" {
+			if err := d.dst.UnmarshalText([]byte(v)); err != nil {
+				return fmt.Errorf("%s: %w", d.env, err)
+			}
+		}
+	}
+	if v := getenv("QUEUE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("QUEUE_SIZE must be a positive integer")
+		}
+		c.Server.QueueSize = n
+	}
+
+	if v := getenv("ROUTER_CONFIG"); v != "" {
+		rc, err := router.ParseConfigOn(c.Router.ScoreConfig(), v)
+		if err != nil {
+			return fmt.Errorf("ROUTER_CONFIG: %w", err)
+		}
+		c.Router.setScoreConfig(rc)
+	}
+	if v := getenv("ROUTER_STRATEGY"); v != "" {
+		c.Router.Strategy = v
+	}
+
+	if v := getenv("RATE_LIMITS"); v != "" {
+		policies, err := core.ParseRateLimitPolicies(v)
+		if err != nil {
+			return fmt.Errorf("RATE_LIMITS: %w", err)
+		}
+		c.RateLimits.Plans = make(map[string]PlanLimit, len(policies))
+		for plan, p := range policies {
+			c.RateLimits.Plans[plan] = PlanLimit{RPM: p.RPM, TPM: p.TPM}
+		}
+	}
+
+	if v := getenv("MODEL_ALIASES"); v != "" {
+		aliases, err := core.ParseModelAliases(v)
+		if err != nil {
+			return fmt.Errorf("MODEL_ALIASES: %w", err)
+		}
+		c.ModelAliases = aliases
+	}
+	return nil
+}
+
+// Validate checks every setting and reports all problems at once, each prefixed with its key
+func (c Config) Validate() error {
+	var problems []error
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	s := c.Server
+	if s.Port < 1 || s.Port > 65535 {
+		fail("server.port must be between 1 and 65535, got %d", s.Port)
+	}
+	if s.ShutdownTimeout <= 0 {
+		fail("server.shutdown_timeout must be a positive duration")
+	}
+	if s.RequestTimeout < 0 {
+		fail("server.request_timeout must not be negative")
+	}
+	if s.RequestTimeoutMax < 0 {
+		fail("server.request_timeout_max must not be negative")
+	}
+	if s.QueueSize <= 0 {
+		fail("server.queue_size must be a positive integer, got %d", s.QueueSize)
+	}
+	if s.QueueTimeout < 0 {
+		fail("server.queue_timeout must not be negative (0 disables queueing)")
+	}
+	if time.Duration(s.HeartbeatInterval) < time.Second {
+		fail("server.heartbeat_interval must be at least 1s")
+	}
+
+	if !knownStrategy(c.Router.Strategy) {
+		fail("router.strategy %q is unknown: expected one of %s", c.Router.Strategy, strings.Join(strategies, ", "))
+	}
+	if math.IsNaN(c.Router.VRAMHeadroomGB) || c.Router.VRAMHeadroomGB < 0 {
+		fail("router.vram_headroom_gb must not be negative")
+	}
+	if err := c.Router.ScoreConfig().Validate(); err != nil {
+		fail("router: %v", err)
+	}
+
+	for key := range c.RateLimits.Balances {
+		if key == "" {
+			fail("rate_limits.balances: API keys must not be empty")
+		}
+	}
+	for _, plan := range sortedKeys(c.RateLimits.Plans) {
+		p := c.RateLimits.Plans[plan]
+		if plan == "" {
+			fail("rate_limits.plans: plan names must not be empty")
+		}
+		if p.RPM < 0 || p.TPM < 0 {
+			fail("rate_limits.plans.%s: rpm and tpm must not be negative", plan)
+		}
+		if p.RPM == 0 && p.TPM == 0 {
+			fail("rate_limits.plans.%s: set rpm, tpm or both", plan)
+		}
+	}
+
+	aliases := make(map[string]string, len(c.ModelAliases))
+	for _, alias := range sortedKeys(c.ModelAliases) {
+		target := strings.TrimSpace(c.ModelAliases[alias])
+		name := strings.ToLower(strings.TrimSpace(alias))
+		switch {
+		case name == "" || target == "":
+			fail("model_aliases: alias %q and its model must not be empty", alias)
+		case strings.EqualFold(name, target):
+			fail("model_aliases.%s maps to itself", alias)
+		case aliases[name] != "":
+			fail("model_aliases.%s is declared twice (aliases are case-insensitive)", alias)
+		}
+		aliases[name] = target
+	}
+
+	seen := make(map[string]bool, len(c.MockWorkers))
+	for i, w := range c.MockWorkers {
+		switch {
+		case w.ID == "":
+			fail("mock_workers[%d].id is required", i)
+		case seen[w.ID]:
+			fail("mock_workers[%d].id %q is declared twice", i, w.ID)
+		}
+		seen[w.ID] = true
+		if len(w.Models) == 0 {
+			fail("mock_workers[%d].models must not be empty", i)
+		}
+		if w.MaxTasks <= 0 {
+			fail("mock_workers[%d].max_tasks must be a positive integer", i)
+		}
+		if math.IsNaN(w.VRAMGB) || w.VRAMGB < 0 {
+			fail("mock_workers[%d].vram_gb must not be negative", i)
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// ScoreConfig converts the router section to the ScoreRouter's configuration
+func (r RouterConfig) ScoreConfig() router.Config {
+	return router.Config{
+		Weights: router.Weights{
+			VRAM:    r.Weights.VRAM,
+			Load:    r.Weights.Load,
+			Adapter: r.Weights.Adapter,
+			Latency: r.Weights.Latency,
+			Cost:    r.Weights.Cost,
+		},
+		VRAMHeadroom:      uint64(r.VRAMHeadroomGB * 1024 * 1024 * 1024),
+		VRAMHeadroomRatio: r.VRAMHeadroomRatio,
+		KVCacheSaturation: r.KVCacheSaturation,
+		MaxLoad:           r.MaxLoad,
+		DegradedPenalty:   r.DegradedPenalty,
+		PriorityReserve:   r.PriorityReserve,
+	}
+}
+
+func (r *RouterConfig) setScoreConfig(rc router.Config) {
+	r.Weights = WeightsConfig{
+		VRAM:    rc.Weights.VRAM,
+		Load:    rc.Weights.Load,
+		Adapter: rc.Weights.Adapter,
+		Latency: rc.Weights.Latency,
+		Cost:    rc.Weights.Cost,
+	}
+	r.VRAMHeadroomGB = float64(rc.VRAMHeadroom) / (1024 * 1024 * 1024)
+	r.VRAMHeadroomRatio = rc.VRAMHeadroomRatio
+	r.KVCacheSaturation = rc.KVCacheSaturation
+	r.MaxLoad = rc.MaxLoad
+	r.DegradedPenalty = rc.DegradedPenalty
+	r.PriorityReserve = rc.PriorityReserve
+}
+
+// Aliases converts the model aliases to the lower-cased form the chat handler resolves
+func (c Config) Aliases() core.ModelAliases {
+	aliases := make(core.ModelAliases, len(c.ModelAliases))
+	for alias, target := range c.ModelAliases {
+		aliases[strings.ToLower(strings.TrimSpace(alias))] = strings.TrimSpace(target)
+	}
+	return aliases
+}
+
+// RateLimitPolicies converts the plans to the window limiter's policies
+func (r RateLimitConfig) RateLimitPolicies() map[string]core.RateLimitPolicy {
+	policies := make(map[string]core.RateLimitPolicy, len(r.Plans))
+	for plan, p := range r.Plans {
+		policies[plan] = core.RateLimitPolicy{RPM: p.RPM, TPM: p.TPM}
+	}
+	return policies
+}
+
+// strategies are the built-in routing strategies a config may name
+var strategies = []string{
+	router.StrategyScore,
+	router.StrategyRoundRobin,
+	router.StrategyLeastLoaded,
+	router.StrategyRandom,
+	router.StrategyConsistentHash,
+}
+
+func knownStrategy(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, s := range strategies {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func noEnv(string) string { return "" }
+
+func TestLoad_ExampleMatchesDefaults(t *testing.T) {
+	cfg, err := Load(filepath.Join("..", "config.example.yaml"), noEnv)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if want := Default(); !reflect.DeepEqual(cfg, want) {
+		t.Errorf("config.example.yaml drifted from Default:\n got %+v\nwant %+v", cfg, want)
+	}
+}
+
+func TestParse_YAML(t *testing.T) {
+	cfg, err := Parse([]byte(`
+server:
+  port: 9000
+  request_timeout: 2m
+router:
+  strategy: least_loaded
+  weights: {vram: 2, latency: 0.5}
+rate_limits:
+  plans:
+    default: {rpm: 60, tpm: 40000}
+mock_workers:
+  - id: mock-a
+    models: [llama-8b]
+    vram_gb: 24
+    max_tasks: 4
+`), ".yaml")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if cfg.Server.Port != 9000 || time.Duration(cfg.Server.RequestTimeout) != 2*time.Minute {
+		t.Errorf("unexpected server section: %+v", cfg.Server)
+	}
+	// 未出现的键保留默认值
+	if time.Duration(cfg.Server.ShutdownTimeout) != 30*time.Second || cfg.Router.Weights.Load != 1 {
+		t.Errorf("expected unset keys to keep their defaults, got %+v / %+v", cfg.Server, cfg.Router)
+	}
+	if cfg.Router.Strategy != "least_loaded" || cfg.Router.Weights.VRAM != 2 || cfg.Router.Weights.Latency != 0.5 {
+		t.Errorf("unexpected router section: %+v", cfg.Router)
+	}
+	// 列表整体替换默认的 Mock Workers，未设置的 balances 保留默认
+	if len(cfg.MockWorkers) != 1 || cfg.MockWorkers[0].ID != "mock-a" {
+		t.Errorf("expected mock_workers to replace the defaults, got %+v", cfg.MockWorkers)
+	}
+	if cfg.RateLimits.Balances["test-key-123"] != 100 || cfg.RateLimits.Plans["default"].TPM != 40000 {
+		t.Errorf("unexpected rate limits: %+v", cfg.RateLimits)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestParse_TOML(t *testing.T) {
+	cfg, err := Parse([]byte(`
+[server]
+port = 9001
+queue_timeout = "0s"
+
+[rate_limits.balances]
+sk-team = 5000
+
+[[mock_workers]]
+id = "mock-b"
+models = ["qwen-7b"]
+max_tasks = 2
+fallback = true
+`), ".toml")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if cfg.Server.Port != 9001 || cfg.Server.QueueTimeout != 0 {
+		t.Errorf("unexpected server section: %+v", cfg.Server)
+	}
+	if !reflect.DeepEqual(cfg.RateLimits.Balances, map[string]int{"sk-team": 5000}) {
+		t.Errorf("expected balances to replace the defaults, got %+v", cfg.RateLimits.Balances)
+	}
+	if len(cfg.MockWorkers) != 1 || !cfg.MockWorkers[0].Fallback {
+		t.Errorf("unexpected mock workers: %+v", cfg.MockWorkers)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	cases := []struct {
+		name, format, doc, want string
+	}{
+		{"unknown YAML key", ".yaml", "server:\n  prot: 8080\n", "line 2: field prot not found"},
+		{"bad YAML duration", ".yaml", "server:\n  shutdown_timeout: 30\n", `line 2: invalid duration "30"`},
+		{"unknown TOML key", ".toml", "[router]\nstrategy = \"score\"\nweight = 1\n", "router.weight (line 3)"},
+		{"unsupported format", ".json", "{}", "unsupported config format"},
+	}
+	for _, tc := range cases {
+		_, err := Parse([]byte(tc.doc), tc.format)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Port = 0
+	cfg.Router.Strategy = "fastest"
+	cfg.Router.Weights = WeightsConfig{}
+	cfg.RateLimits.Plans = map[string]PlanLimit{"free": {}}
+	cfg.MockWorkers = append(cfg.MockWorkers, MockWorker{ID: "gpu-2060-01", MaxTasks: 1})
+	cfg.ModelAliases = map[string]string{"llama-8b": "LLAMA-8B", "gpt-4": "llama-70b", "GPT-4": "llama-8b"}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation to fail")
+	}
+	for _, want := range []string{
+		"server.port must be between 1 and 65535",
+		`router.strategy "fastest" is unknown`,
+		"router: at least one weight must be positive",
+		"rate_limits.plans.free: set rpm, tpm or both",
+		`mock_workers[3].id "gpu-2060-01" is declared twice`,
+		"mock_workers[3].models must not be empty",
+		"model_aliases.llama-8b maps to itself",
+		"model_aliases.gpt-4 is declared twice",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestLoad_EnvironmentOverridesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zam.yaml")
+	doc := "server:\n  port: 9000\nrouter:\n  weights: {vram: 3}\n"
+	if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"PORT":          "9100",
+		"ROUTER_CONFIG": "load=2",
+		"RATE_LIMITS":   "pro=rpm:600",
+		"MODEL_ALIASES": "gpt-4=llama-3-70b",
+	}
+	cfg, err := Load(path, func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Port != 9100 {
+		t.Errorf("expected PORT to win over the file, got %d", cfg.Server.Port)
+	}
+	// ROUTER_CONFIG 叠加在文件的 router 段之上
+	if cfg.Router.Weights.VRAM != 3 || cfg.Router.Weights.Load != 2 {
+		t.Errorf("expected ROUTER_CONFIG on top of the file, got %+v", cfg.Router.Weights)
+	}
+	if cfg.RateLimits.Plans["pro"].RPM != 600 {
+		t.Errorf("expected RATE_LIMITS plans, got %+v", cfg.RateLimits.Plans)
+	}
+	if target, _ := cfg.Aliases().Resolve("GPT-4"); target != "llama-3-70b" {
+		t.Errorf("expected MODEL_ALIASES, got %+v", cfg.ModelAliases)
+	}
+
+	env["HEARTBEAT_INTERVAL"] = "500ms"
+	if _, err := Load(path, func(key string) string { return env[key] }); err == nil || !strings.Contains(err.Error(), "server.heartbeat_interval") {
+		t.Errorf("expected heartbeat interval validation error, got %v", err)
+	}
+}
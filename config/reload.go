@@ -0,0 +1,136 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Reloader re-reads the config file while the gateway runs (on SIGHUP) and hands the settings that
+// changed to the running components. A file that fails to load or validate leaves the running
+// configuration untouched; the server itself keeps running, so open streams are not interrupted
+type Reloader struct {
+	path   string
+	getenv func(string) string
+	// apply updates the running components from old to next; next has already been validated
+	apply func(old, next Config)
+
+	mu      sync.Mutex
+	current Config
+}
+
+// NewReloader creates a Reloader for the file at path; current is the configuration the gateway started with
+func NewReloader(path string, getenv func(string) string, current Config, apply func(old, next Config)) *Reloader {
+	return &Reloader{path: path, getenv: getenv, apply: apply, current: current}
+}
+
+// Current returns the configuration in effect
+func (r *Reloader) Current() Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload loads the file again and applies it. The server section (port, timeouts, queue, heartbeat)
+// is only read at startup: its changes are kept out of the applied configuration and returned as
+// restart, so the caller can report them
+func (r *Reloader) Reload() (restart []string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := Load(r.path, r.getenv)
+	if err != nil {
+		return nil, err
+	}
+	restart = RestartRequired(r.current, next)
+	next.Server = r.current.Server
+	r.apply(r.current, next)
+	r.current = next
+	return restart, nil
+}
+
+// Run reloads the configuration every time signals delivers, until ctx is cancelled
+func (r *Reloader) Run(ctx context.Context, signals <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			restart, err := r.Reload()
+			if err != nil {
+				slog.Error("config reload failed, keeping the running config", "path", r.path, "error", err)
+				continue
+			}
+			slog.Info("config reloaded", "path", r.path)
+			if len(restart) > 0 {
+				slog.Warn("config changes take effect after a restart", "keys", restart)
+			}
+		}
+	}
+}
+
+// RestartRequired lists the server settings that differ between old and next
+func RestartRequired(old, next Config) []string {
+	var keys []string
+	o, n := old.Server, next.Server
+	for _, f := range []struct {
+		key     string
+		changed bool
+	}{
+		{"server.port", o.Port != n.Port},
+		{"server.shutdown_timeout", o.ShutdownTimeout != n.ShutdownTimeout},
+		{"server.request_timeout", o.RequestTimeout != n.RequestTimeout},
+		{"server.request_timeout_max", o.RequestTimeoutMax != n.RequestTimeoutMax},
+		{"server.queue_size", o.QueueSize != n.QueueSize},
+		{"server.queue_timeout", o.QueueTimeout != n.QueueTimeout},
+		{"server.heartbeat_interval", o.HeartbeatInterval != n.HeartbeatInterval},
+	} {
+		if f.changed {
+			keys = append(keys, f.key)
+		}
+	}
+	return keys
+}
+
+// MockWorkerChanges compares two mock worker declarations: upserted are the workers that are new
+// or whose declaration changed, removed the IDs no longer declared
+func MockWorkerChanges(old, next []MockWorker) (upserted []MockWorker, removed []string) {
+	previous := make(map[string]MockWorker, len(old))
+	for _, w := range old {
+		previous[w.ID] = w
+	}
+	for _, w := range next {
+		if p, ok := previous[w.ID]; !ok || !reflect.DeepEqual(p, w) {
+			upserted = append(upserted, w)
+		}
+		delete(previous, w.ID)
+	}
+	for _, w := range old {
+		if _, ok := previous[w.ID]; ok {
+			removed = append(removed, w.ID)
+		}
+	}
+	return upserted, removed
+}
+
+// BalanceChanges compares two balance declarations: changed are the balances that are new or differ
+// from old, removed the keys no longer declared, sorted. Balances are spent as keys are used, so a
+// reload only resets the keys whose configured balance was edited
+func BalanceChanges(old, next map[string]int) (changed map[string]int, removed []string) {
+	changed = make(map[string]int)
+	for key, tokens := range next {
+		if previous, ok := old[key]; !ok || previous != tokens {
+			changed[key] = tokens
+		}
+	}
+	for key := range old {
+		if _, ok := next[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+	return changed, removed
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"zam/core"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zam.yaml")
+	write := func(doc string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("model_aliases: {gpt-4: llama-70b}\nmock_workers: [{id: a, models: [llama-8b], max_tasks: 1}]\n")
+	start, err := Load(path, noEnv)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	var previous, applied []Config
+	reloader := NewReloader(path, noEnv, start, func(old, next Config) {
+		previous = append(previous, old)
+		applied = append(applied, next)
+	})
+
+	write(`
+server: {port: 9000}
+router: {weights: {vram: 2}}
+model_aliases: {GPT-4: llama-8b}
+mock_workers: [{id: b, models: [llama-8b], max_tasks: 1}]
+`)
+	restart, err := reloader.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !reflect.DeepEqual(restart, []string{"server.port"}) {
+		t.Errorf("restart = %v, want [server.port]", restart)
+	}
+	cur := reloader.Current()
+	// 服务器段只在启动时读取
+	if cur.Server.Port != 8080 || cur.Router.Weights.VRAM != 2 {
+		t.Errorf("unexpected config after reload: %+v", cur)
+	}
+	if target, _ := cur.Aliases().Resolve("gpt-4"); target != "llama-8b" {
+		t.Errorf("expected the alias to follow the file, got %q", target)
+	}
+	if len(applied) != 1 || !reflect.DeepEqual(previous[0], start) || !reflect.DeepEqual(applied[0], cur) {
+		t.Fatalf("expected one apply from the startup config to the current one, got %d", len(applied))
+	}
+
+	// 无效的文件不生效，保留当前配置
+	write("router: {strategy: fastest}\n")
+	if _, err := reloader.Reload(); err == nil {
+		t.Fatal("expected an invalid file to fail the reload")
+	}
+	if len(applied) != 1 || !reflect.DeepEqual(reloader.Current(), cur) {
+		t.Error("expected a failed reload to keep the running config")
+	}
+}
+
+func TestReloader_RemovedBalance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zam.yaml")
+	if err := os.WriteFile(path, []byte("rate_limits: {balances: {sk-keep: 100, sk-revoked: 100}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	start, err := Load(path, noEnv)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	balances := core.NewInMemoryRateLimiter()
+	balances.SetBalances(start.RateLimits.Balances)
+	// 与 main 中的 apply 相同：更新改动的余额，删除移除的 Key
+	reloader := NewReloader(path, noEnv, start, func(old, next Config) {
+		changed, removed := BalanceChanges(old.RateLimits.Balances, next.RateLimits.Balances)
+		for key, tokens := range changed {
+			balances.SetBalance(key, tokens)
+		}
+		for _, key := range removed {
+			balances.RemoveBalance(key)
+		}
+	})
+
+	if err := os.WriteFile(path, []byte("rate_limits: {balances: {sk-keep: 100}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	ctx := context.Background()
+	if ok, _ := balances.Allow(ctx, "sk-revoked"); ok {
+		t.Error("expected a key removed from the file to be refused after the reload")
+	}
+	if ok, _ := balances.Allow(ctx, "sk-keep"); !ok {
+		t.Error("expected the remaining key to keep working")
+	}
+}
+
+func TestReloader_Run(t *testing.T) {
+	reloaded := make(chan Config, 1)
+	reloader := NewReloader("", noEnv, Default(), func(_, next Config) { reloaded <- next })
+	signals := make(chan os.Signal, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Run(ctx, signals)
+
+	signals <- syscall.SIGHUP
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("expected SIGHUP to trigger a reload")
+	}
+}
+
+func TestMockWorkerChanges(t *testing.T) {
+	old := []MockWorker{
+		{ID: "keep", Models: []string{"llama-8b"}, MaxTasks: 1},
+		{ID: "resize", Models: []string{"llama-8b"}, MaxTasks: 1},
+		{ID: "drop", Models: []string{"llama-8b"}, MaxTasks: 1},
+	}
+	next := []MockWorker{
+		{ID: "keep", Models: []string{"llama-8b"}, MaxTasks: 1},
+		{ID: "resize", Models: []string{"llama-8b"}, MaxTasks: 4},
+		{ID: "new", Models: []string{"qwen-7b"}, MaxTasks: 2},
+	}
+	upserted, removed := MockWorkerChanges(old, next)
+	if len(upserted) != 2 || upserted[0].ID != "resize" || upserted[1].ID != "new" {
+		t.Errorf("upserted = %+v, want resize and new", upserted)
+	}
+	if !reflect.DeepEqual(removed, []string{"drop"}) {
+		t.Errorf("removed = %v, want [drop]", removed)
+	}
+}
+
+func TestBalanceChanges(t *testing.T) {
+	changed, removed := BalanceChanges(
+		map[string]int{"same": 100, "edited": 100, "gone": 5, "dropped": 1},
+		map[string]int{"same": 100, "edited": 500, "added": 10},
+	)
+	if want := map[string]int{"edited": 500, "added": 10}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+	if want := []string{"dropped", "gone"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+}
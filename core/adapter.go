@@ -0,0 +1,27 @@
+package core
+
+import "strings"
+
+// AdapterSeparator separates the base model from the LoRA adapter in a model name,
+// e.g. "llama-8b@sql-lora"
+const AdapterSeparator = "@"
+
+// SplitAdapterModel splits a "base@adapter" model name into its base model and adapter.
+// Plain model names return an empty adapter.
+func SplitAdapterModel(model string) (base, adapter string) {
+	idx := strings.LastIndex(model, AdapterSeparator)
+	if idx <= 0 || idx == len(model)-1 {
+		return model, ""
+	}
+	return model[:idx], model[idx+1:]
+}
+
+// HasAdapter reports whether the adapter is listed in the loaded adapters (case-insensitive)
+func HasAdapter(loaded []string, adapter string) bool {
+	for _, a := range loaded {
+		if strings.EqualFold(a, adapter) {
+			return true
+		}
+	}
+	return false
+}
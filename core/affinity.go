@@ -0,0 +1,121 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"zam/openai"
+)
+
+// SessionHeader lets clients name the conversation a request belongs to for session affinity
+const SessionHeader = "X-Session-ID"
+
+// DefaultAffinityTTL is how long a session stays bound to a worker after its last request
+const DefaultAffinityTTL = 30 * time.Minute
+
+// affinityPrefix separates affinity bindings from other sessions in a shared store
+const affinityPrefix = "affinity:"
+
+// AffinityMode selects how requests are grouped into sessions for affinity routing
+type AffinityMode string
+
+const (
+	// AffinityOff disables session affinity
+	AffinityOff AffinityMode = "off"
+	// AffinityHeader groups requests carrying the same X-Session-ID
+	AffinityHeader AffinityMode = "header"
+	// AffinityPrefix also groups requests without the header by their conversation prefix
+	AffinityPrefix AffinityMode = "prefix"
+)
+
+// ParseAffinityMode parses "off" (default when empty), "header" or "prefix"
+func ParseAffinityMode(s string) (AffinityMode, error) {
+	switch mode := AffinityMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return AffinityOff, nil
+	case AffinityOff, AffinityHeader, AffinityPrefix:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unsupported affinity mode %q: expected off, header or prefix", s)
+}
+
+// SessionAffinity binds sessions to the worker that served their previous turns, so follow-up turns
+// land where the conversation's KV-cache prefix is still resident. Bindings are kept in a SessionStore
+// and shared by every gateway instance when the store is Redis
+type SessionAffinity struct {
+	mode  AffinityMode
+	store SessionStore
+	ttl   time.Duration
+}
+
+// NewSessionAffinity creates an affinity table in store; ttl <= 0 uses DefaultAffinityTTL
+func NewSessionAffinity(mode AffinityMode, store SessionStore, ttl time.Duration) *SessionAffinity {
+	if ttl <= 0 {
+		ttl = DefaultAffinityTTL
+	}
+	return &SessionAffinity{mode: mode, store: store, ttl: ttl}
+}
+
+// Key derives the affinity key of a request from the client's session ID or, in prefix mode, from
+// the system prompt and first user message, which stay the same on every turn of a conversation
+// Keys are scoped to the tenant and hashed. An empty key disables affinity for the request;
+// a nil affinity always returns one
+func (a *SessionAffinity) Key(tenant, sessionID, model string, messages []openai.Message) string {
+	if a == nil || a.mode == AffinityOff {
+		return ""
+	}
+	h := sha256.New()
+	h.Write([]byte(tenant + "\x00"))
+	switch {
+	case sessionID != "":
+		h.Write([]byte("session\x00" + sessionID))
+	case a.mode == AffinityPrefix:
+		h.Write([]byte("prefix\x00" + strings.ToLower(model)))
+		found := false
+		for _, m := range messages {
+			h.Write([]byte("\x00" + m.Role + "\x00" + m.Content))
+			if m.Role == "user" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ""
+		}
+	default:
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// Worker returns the worker ID a session is bound to, empty when unbound
+// Store errors are logged and treated as unbound so affinity never fails a request
+func (a *SessionAffinity) Worker(ctx context.Context, key string) string {
+	if a == nil || key == "" {
+		return ""
+	}
+	value, ok, err := a.store.Get(ctx, affinityPrefix+key)
+	if err != nil {
+		slog.Warn("session affinity lookup failed", "error", err)
+		return ""
+	}
+	if !ok {
+		return ""
+	}
+	return string(value)
+}
+
+// Bind records that workerID served the session, extending the binding's TTL
+func (a *SessionAffinity) Bind(ctx context.Context, key, workerID string) {
+	if a == nil || key == "" {
+		return
+	}
+	if err := a.store.Put(ctx, affinityPrefix+key, []byte(workerID), a.ttl); err != nil {
+		slog.Warn("session affinity update failed", "worker_id", workerID, "error", err)
+	}
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"zam/openai"
+)
+
+func TestSessionAffinity(t *testing.T) {
+	if _, err := ParseAffinityMode("sticky"); err == nil {
+		t.Error("expected an unknown mode to be rejected")
+	}
+	if mode, _ := ParseAffinityMode(""); mode != AffinityOff {
+		t.Errorf("ParseAffinityMode(\"\") = %q, want off", mode)
+	}
+
+	turn1 := []openai.Message{{Role: "system", Content: "be brief"}, {Role: "user", Content: "hi"}}
+	turn2 := append(append([]openai.Message(nil), turn1...), openai.Message{Role: "assistant", Content: "hello"}, openai.Message{Role: "user", Content: "how are you"})
+
+	header := NewSessionAffinity(AffinityHeader, NewMemorySessionStore(SessionLimits{}), 0)
+	if header.Key("key-a", "", "llama", turn1) != "" {
+		t.Error("header mode should not group requests without a session ID")
+	}
+	if header.Key("key-a", "s1", "llama", turn1) == header.Key("key-b", "s1", "llama", turn1) {
+		t.Error("expected session keys to be scoped to the tenant")
+	}
+
+	// 前缀模式：同一对话的各轮次得到相同的 Key
+	prefix := NewSessionAffinity(AffinityPrefix, NewMemorySessionStore(SessionLimits{}), 0)
+	key := prefix.Key("key-a", "", "llama", turn1)
+	if key == "" || prefix.Key("key-a", "", "llama", turn2) != key {
+		t.Error("expected every turn of a conversation to share its prefix key")
+	}
+	if prefix.Key("key-a", "", "llama", []openai.Message{{Role: "user", Content: "other"}}) == key {
+		t.Error("expected another conversation to get another key")
+	}
+
+	ctx := context.Background()
+	if prefix.Worker(ctx, key) != "" {
+		t.Error("expected an unbound session")
+	}
+	prefix.Bind(ctx, key, "gpu-1")
+	if got := prefix.Worker(ctx, key); got != "gpu-1" {
+		t.Errorf("Worker() = %q, want gpu-1", got)
+	}
+
+	var none *SessionAffinity
+	if none.Key("key-a", "s1", "llama", turn1) != "" || none.Worker(ctx, key) != "" {
+		t.Error("expected a nil affinity to be disabled")
+	}
+	none.Bind(ctx, key, "gpu-1")
+}
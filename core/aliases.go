@@ -0,0 +1,44 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ModelAliases maps lower-cased client-facing model names to the models deployed on workers,
+// e.g. {"gpt-4": "llama-3-70b-q4"}, so clients written against OpenAI model names reach local models
+type ModelAliases map[string]string
+
+// ParseModelAliases parses "alias=model;..." e.g. "gpt-4=llama-3-70b-q4;gpt-3.5-turbo=llama-3-8b"
+// The target may name a LoRA adapter ("base@adapter"); aliases are not chained
+func ParseModelAliases(spec string) (ModelAliases, error) {
+	aliases := make(ModelAliases)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		alias, model, ok := strings.Cut(entry, "=")
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		model = strings.TrimSpace(model)
+		if !ok || alias == "" || model == "" {
+			return nil, fmt.Errorf("invalid model alias %q: expected alias=model", entry)
+		}
+		if strings.EqualFold(alias, model) {
+			return nil, fmt.Errorf("invalid model alias %q: alias maps to itself", entry)
+		}
+		if _, dup := aliases[alias]; dup {
+			return nil, fmt.Errorf("duplicate model alias %q", alias)
+		}
+		aliases[alias] = model
+	}
+	return aliases, nil
+}
+
+// Resolve returns the deployed model of an alias, matched case-insensitively like routing does,
+// and false for names that are not aliases
+func (a ModelAliases) Resolve(model string) (string, bool) {
+	target, ok := a[strings.ToLower(model)]
+	return target, ok
+}
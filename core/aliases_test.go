@@ -0,0 +1,30 @@
+package core
+
+import "testing"
+
+func TestParseModelAliases(t *testing.T) {
+	aliases, err := ParseModelAliases("GPT-4=llama-3-70b-q4; gpt-3.5-turbo=llama-3-8b@support")
+	if err != nil {
+		t.Fatalf("ParseModelAliases: %v", err)
+	}
+	if target, ok := aliases.Resolve("gpt-4"); !ok || target != "llama-3-70b-q4" {
+		t.Errorf("Resolve(gpt-4) = %q, %v", target, ok)
+	}
+	// 与路由一致，不区分大小写
+	if target, ok := aliases.Resolve("GPT-3.5-Turbo"); !ok || target != "llama-3-8b@support" {
+		t.Errorf("Resolve(GPT-3.5-Turbo) = %q, %v", target, ok)
+	}
+	if _, ok := aliases.Resolve("llama-3-8b"); ok {
+		t.Error("deployed model names should not resolve")
+	}
+	var none ModelAliases
+	if _, ok := none.Resolve("gpt-4"); ok {
+		t.Error("a nil ModelAliases should resolve nothing")
+	}
+
+	for _, spec := range []string{"gpt-4", "=llama", "gpt-4=", "gpt-4=GPT-4", "gpt-4=a;GPT-4=b"} {
+		if _, err := ParseModelAliases(spec); err == nil {
+			t.Errorf("ParseModelAliases(%q) should fail", spec)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// shedWindow is the window the shed rate is averaged over
+const shedWindow = time.Minute
+
+// ModelDemand is the pressure on one model
+type ModelDemand struct {
+	// InFlight counts requests the gateway is currently running for the model
+	InFlight int `json:"in_flight"`
+	// QueuedJobs counts async jobs waiting for capacity
+	QueuedJobs int `json:"queued_jobs"`
+	// Backlog is InFlight plus QueuedJobs: the demand a fleet serving the model has to absorb
+	Backlog int `json:"backlog"`
+	// Capacity is the concurrency local workers offer the model (MaxTasks, capped by model slots)
+	Capacity int `json:"capacity"`
+}
+
+// DemandSignals are aggregate demand metrics for autoscalers such as the KEDA metrics-api scaler,
+// e.g. valueLocation "queue_depth" or "models.llama-3-8b.backlog"
+type DemandSignals struct {
+	// QueueDepth is everything waiting for a slot: queues reported by workers plus queued jobs
+	QueueDepth       int `json:"queue_depth"`
+	WorkerQueueDepth int `json:"worker_queue_depth"`
+	QueuedJobs       int `json:"queued_jobs"`
+	// OldestJobWaitSeconds is how long the oldest queued job has been waiting
+	OldestJobWaitSeconds float64 `json:"oldest_job_wait_seconds"`
+	InFlight             int     `json:"in_flight"`
+	Capacity             int     `json:"capacity"`
+	// Utilization is the cluster load used by brownout, 0-1
+	Utilization float64 `json:"utilization"`
+	// ShedTotal counts requests rejected because no worker could take them
+	ShedTotal int64 `json:"shed_total"`
+	// ShedPerSecond is the shed rate over the last minute
+	ShedPerSecond float64                `json:"shed_per_second"`
+	Models        map[string]ModelDemand `json:"models"`
+}
+
+// DemandMonitor aggregates gateway pressure for autoscaling GPU worker fleets
+type DemandMonitor struct {
+	profiles func() []WorkerProfile
+	inflight *InflightTracker
+	jobs     *JobQueue
+
+	mu        sync.Mutex
+	shedTotal int64
+	shed      []time.Time
+	now       func() time.Time
+}
+
+// NewDemandMonitor creates a DemandMonitor; inflight and jobs may be nil
+func NewDemandMonitor(profiles func() []WorkerProfile, inflight *InflightTracker, jobs *JobQueue) *DemandMonitor {
+	return &DemandMonitor{profiles: profiles, inflight: inflight, jobs: jobs, now: time.Now}
+}
+
+// ObserveEvent counts shed requests; subscribe it to the EventBus
+func (m *DemandMonitor) ObserveEvent(e Event) {
+	if e.Type != EventRequestShed {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.shedTotal++
+	m.shed = append(m.shed, m.now())
+	m.pruneLocked()
+}
+
+// pruneLocked drops shed timestamps older than the window; caller must hold m.mu
+func (m *DemandMonitor) pruneLocked() {
+	cutoff := m.now().Add(-shedWindow)
+	i := 0
+	for i < len(m.shed) && !m.shed[i].After(cutoff) {
+		i++
+	}
+	m.shed = m.shed[i:]
+}
+
+// Signals returns the current demand metrics
+func (m *DemandMonitor) Signals() DemandSignals {
+	signals := DemandSignals{Models: make(map[string]ModelDemand)}
+	models := signals.Models
+	demand := func(model string) ModelDemand { return models[strings.ToLower(model)] }
+
+	profiles := m.profiles()
+	signals.Utilization = ClusterLoad(profiles)
+	for _, p := range profiles {
+		// 与 ClusterLoad 一致：对端网关与无显存的云端兜底不算本地机群
+		if p.Peer || p.TotalVRAM == 0 {
+			continue
+		}
+		signals.WorkerQueueDepth += p.QueueLength
+		signals.Capacity += p.MaxTasks
+		for _, model := range p.Supported {
+			slots := p.MaxTasks
+			if n, ok := p.ModelSlots[model]; ok && n < slots {
+				slots = n
+			}
+			d := demand(model)
+			d.Capacity += slots
+			models[strings.ToLower(model)] = d
+		}
+	}
+
+	if m.inflight != nil {
+		for model, n := range m.inflight.ModelTotals() {
+			d := demand(model)
+			d.InFlight += n
+			models[strings.ToLower(model)] = d
+			signals.InFlight += n
+		}
+	}
+	if m.jobs != nil {
+		backlog := m.jobs.Backlog()
+		signals.QueuedJobs = backlog.Queued
+		signals.OldestJobWaitSeconds = backlog.OldestWait.Seconds()
+		for model, n := range backlog.ByModel {
+			d := demand(model)
+			d.QueuedJobs += n
+			models[strings.ToLower(model)] = d
+		}
+	}
+	for model, d := range models {
+		d.Backlog = d.InFlight + d.QueuedJobs
+		models[model] = d
+	}
+	signals.QueueDepth = signals.WorkerQueueDepth + signals.QueuedJobs
+
+	m.mu.Lock()
+	m.pruneLocked()
+	signals.ShedTotal = m.shedTotal
+	signals.ShedPerSecond = float64(len(m.shed)) / shedWindow.Seconds()
+	m.mu.Unlock()
+	return signals
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDemandMonitor(t *testing.T) {
+	profiles := []WorkerProfile{
+		{WorkerID: "gpu-1", Supported: []string{"llama-3-8b", "llama-70b"}, TotalVRAM: 24, MaxTasks: 8, ActiveTasks: 4, QueueLength: 3, ModelSlots: map[string]int{"llama-70b": 1}},
+		{WorkerID: "gpu-2", Supported: []string{"llama-3-8b"}, TotalVRAM: 24, MaxTasks: 4, QueueLength: 1},
+		{WorkerID: "cloud-fallback", Supported: []string{"llama-3-8b"}, MaxTasks: 100, QueueLength: 50},
+	}
+	inflight := NewInflightTracker()
+	release := inflight.AcquireModel("gpu-1", "key-a", "llama-3-8b")
+	defer release()
+	inflight.AcquireModel("gpu-1", "key-b", "llama-70b")
+
+	jobs := NewJobQueue(0, 0)
+	start := time.Now()
+	jobs.now = func() time.Time { return start }
+	if _, err := jobs.Submit("key-a", json.RawMessage(`{"model":"llama-3-8b"}`), "", PriorityLow); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	jobs.now = func() time.Time { return start.Add(90 * time.Second) }
+
+	m := NewDemandMonitor(func() []WorkerProfile { return profiles }, inflight, jobs)
+	now := start
+	m.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		m.ObserveEvent(Event{Type: EventRequestShed})
+	}
+	m.ObserveEvent(Event{Type: EventWorkerJoined})
+	now = now.Add(shedWindow)
+	for i := 0; i < 6; i++ {
+		m.ObserveEvent(Event{Type: EventRequestShed})
+	}
+
+	s := m.Signals()
+	if s.WorkerQueueDepth != 4 || s.QueuedJobs != 1 || s.QueueDepth != 5 {
+		t.Errorf("queue depth = %d (workers %d, jobs %d), want 5 (4, 1)", s.QueueDepth, s.WorkerQueueDepth, s.QueuedJobs)
+	}
+	if s.OldestJobWaitSeconds != 90 || s.InFlight != 2 || s.Capacity != 12 {
+		t.Errorf("signals = %+v, want 90s wait, 2 in flight, capacity 12", s)
+	}
+	// 窗口外的拒绝只计入总数
+	if s.ShedTotal != 9 || s.ShedPerSecond != 0.1 {
+		t.Errorf("shed = %d total, %v/s, want 9 total, 0.1/s", s.ShedTotal, s.ShedPerSecond)
+	}
+	want := map[string]ModelDemand{
+		"llama-3-8b": {InFlight: 1, QueuedJobs: 1, Backlog: 2, Capacity: 12},
+		"llama-70b":  {InFlight: 1, Backlog: 1, Capacity: 1},
+	}
+	for model, d := range want {
+		if s.Models[model] != d {
+			t.Errorf("models[%s] = %+v, want %+v", model, s.Models[model], d)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BrownoutPolicy decides when the gateway degrades and which features it sheds
+type BrownoutPolicy struct {
+	// EnterLoad is the cluster load (0..1) above which overload is counted
+	EnterLoad float64
+	// ExitLoad is the load below which the brownout ends; lower than EnterLoad to avoid flapping
+	ExitLoad float64
+	// Sustain is how long load must stay above EnterLoad before the brownout starts
+	Sustain time.Duration
+	// RejectModels are case-insensitive model name fragments (e.g. "70b") refused during a brownout
+	RejectModels []string
+	// MaxTokens caps max_tokens during a brownout (0 = no cap)
+	MaxTokens int
+}
+
+// DefaultBrownoutPolicy enters at 90% load sustained for 30s and exits below 70%
+func DefaultBrownoutPolicy() BrownoutPolicy {
+	return BrownoutPolicy{
+		EnterLoad: 0.9,
+		ExitLoad:  0.7,
+		Sustain:   30 * time.Second,
+	}
+}
+
+// ParseBrownoutPolicy parses "enter=90%;exit=70%;sustain=30s;reject=70b|72b;max_tokens=512"
+// on top of base; omitted fields keep their base value
+func ParseBrownoutPolicy(spec string, base BrownoutPolicy) (BrownoutPolicy, error) {
+	policy := base
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return policy, fmt.Errorf("invalid brownout setting %q: expected key=value", entry)
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "enter", "exit":
+			pct, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+			if err != nil || pct <= 0 || pct > 100 {
+				return policy, fmt.Errorf("invalid brownout setting %q: expected a percentage in (0, 100]", entry)
+			}
+			if key == "enter" {
+				policy.EnterLoad = pct / 100
+			} else {
+				policy.ExitLoad = pct / 100
+			}
+		case "sustain":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return policy, fmt.Errorf("invalid brownout setting %q: expected a duration", entry)
+			}
+			policy.Sustain = d
+		case "reject":
+			policy.RejectModels = nil
+			for _, fragment := range strings.Split(value, "|") {
+				if fragment = strings.ToLower(strings.TrimSpace(fragment)); fragment != "" {
+					policy.RejectModels = append(policy.RejectModels, fragment)
+				}
+			}
+		case "max_tokens":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return policy, fmt.Errorf("invalid brownout setting %q: expected a non-negative integer", entry)
+			}
+			policy.MaxTokens = n
+		default:
+			return policy, fmt.Errorf("invalid brownout setting %q: unknown key %q", entry, key)
+		}
+	}
+	if policy.ExitLoad > policy.EnterLoad {
+		return policy, fmt.Errorf("brownout exit load %.0f%% is above enter load %.0f%%", policy.ExitLoad*100, policy.EnterLoad*100)
+	}
+	return policy, nil
+}
+
+// BrownoutStatus is the brownout state reported by /health
+type BrownoutStatus struct {
+	Active bool `json:"active"`
+	// Load is the cluster load seen at the last evaluation
+	Load  float64    `json:"load"`
+	Since *time.Time `json:"since,omitempty"`
+	// Shedding lists the degradations in effect while active
+	RejectModels []string `json:"reject_models,omitempty"`
+	MaxTokens    int      `json:"max_tokens,omitempty"`
+}
+
+// Brownout degrades expensive features under sustained overload so small-model traffic keeps flowing
+type Brownout struct {
+	policy BrownoutPolicy
+
+	mu        sync.RWMutex
+	active    bool
+	load      float64
+	overSince time.Time
+	since     time.Time
+	events    *EventBus
+	now       func() time.Time
+}
+
+// NewBrownout creates an inactive Brownout; state changes are published to events when non-nil
+func NewBrownout(policy BrownoutPolicy, events *EventBus) *Brownout {
+	return &Brownout{policy: policy, events: events, now: time.Now}
+}
+
+// ClusterLoad returns the share of local task slots in use (active plus queued requests)
+// Peer gateways and workers without VRAM (cloud fallbacks) do not count as local capacity
+func ClusterLoad(profiles []WorkerProfile) float64 {
+	used, capacity := 0, 0
+	for _, p := range profiles {
+		if p.Peer || p.TotalVRAM == 0 || p.MaxTasks <= 0 {
+			continue
+		}
+		used += p.ActiveTasks + p.QueueLength
+		capacity += p.MaxTasks
+	}
+	if capacity == 0 {
+		return 0
+	}
+	return float64(used) / float64(capacity)
+}
+
+// Observe feeds one load sample and enters or leaves the brownout with hysteresis
+func (b *Brownout) Observe(load float64) {
+	b.mu.Lock()
+	now := b.now()
+	b.load = load
+	changed := false
+	switch {
+	case !b.active && load >= b.policy.EnterLoad:
+		if b.overSince.IsZero() {
+			b.overSince = now
+		}
+		if now.Sub(b.overSince) >= b.policy.Sustain {
+			b.active, b.since, changed = true, now, true
+		}
+	case !b.active:
+		b.overSince = time.Time{}
+	case load < b.policy.ExitLoad:
+		b.active, b.overSince, changed = false, time.Time{}, true
+	}
+	active := b.active
+	b.mu.Unlock()
+
+	if changed {
+		eventType, message := EventBrownoutEnded, fmt.Sprintf("brownout ended at %.0f%% cluster load", load*100)
+		if active {
+			eventType, message = EventBrownoutStarted, fmt.Sprintf("brownout started after sustained %.0f%% cluster load", load*100)
+		}
+		b.events.Publish(Event{Type: eventType, Message: message})
+	}
+}
+
+// Run samples the cluster load every interval until ctx is cancelled
+func (b *Brownout) Run(ctx context.Context, profiles func() []WorkerProfile, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.Observe(ClusterLoad(profiles()))
+		}
+	}
+}
+
+// Active reports whether the gateway is browned out; a nil Brownout never is
+func (b *Brownout) Active() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.active
+}
+
+// Status returns the current state for /health
+func (b *Brownout) Status() BrownoutStatus {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	status := BrownoutStatus{Active: b.active, Load: b.load}
+	if b.active {
+		since := b.since
+		status.Since = &since
+		status.RejectModels = b.policy.RejectModels
+		status.MaxTokens = b.policy.MaxTokens
+	}
+	return status
+}
+
+// Admit applies the brownout to a request: it reports whether the model may be served and
+// returns maxTokens capped to the brownout limit (0 means the backend default, which is capped too)
+func (b *Brownout) Admit(model string, maxTokens int) (bool, int) {
+	if !b.Active() {
+		return true, maxTokens
+	}
+	modelLower := strings.ToLower(model)
+	for _, fragment := range b.policy.RejectModels {
+		if strings.Contains(modelLower, fragment) {
+			return false, maxTokens
+		}
+	}
+	if limit := b.policy.MaxTokens; limit > 0 && (maxTokens == 0 || maxTokens > limit) {
+		maxTokens = limit
+	}
+	return true, maxTokens
+}
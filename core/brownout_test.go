@@ -0,0 +1,77 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBrownout_SustainedOverload(t *testing.T) {
+	policy, err := ParseBrownoutPolicy("enter=90%;exit=70%;sustain=30s;reject=70b|72b;max_tokens=512", DefaultBrownoutPolicy())
+	if err != nil {
+		t.Fatalf("ParseBrownoutPolicy() error = %v", err)
+	}
+	bus := NewEventBus()
+	var events []EventType
+	bus.Subscribe(func(e Event) { events = append(events, e.Type) })
+
+	b := NewBrownout(policy, bus)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	// 短暂的尖峰不触发降级
+	b.Observe(0.95)
+	now = now.Add(10 * time.Second)
+	b.Observe(0.5)
+	now = now.Add(10 * time.Second)
+	b.Observe(0.95)
+	now = now.Add(20 * time.Second)
+	b.Observe(0.95)
+	if b.Active() {
+		t.Fatal("expected no brownout before load was sustained for 30s")
+	}
+	now = now.Add(10 * time.Second)
+	b.Observe(0.92)
+	if !b.Active() {
+		t.Fatal("expected brownout after 30s of sustained overload")
+	}
+
+	if ok, _ := b.Admit("llama-70b", 0); ok {
+		t.Error("expected 70B requests to be rejected")
+	}
+	if ok, maxTokens := b.Admit("llama-8b", 2048); !ok || maxTokens != 512 {
+		t.Errorf("expected small models admitted with max_tokens capped to 512, got %v, %d", ok, maxTokens)
+	}
+	if _, maxTokens := b.Admit("llama-8b", 0); maxTokens != 512 {
+		t.Errorf("expected the backend default to be capped too, got %d", maxTokens)
+	}
+
+	// 滞回：介于 exit 与 enter 之间保持降级
+	b.Observe(0.8)
+	if !b.Active() {
+		t.Error("expected brownout to hold between exit and enter load")
+	}
+	b.Observe(0.6)
+	if b.Active() {
+		t.Error("expected brownout to end below exit load")
+	}
+	if len(events) != 2 || events[0] != EventBrownoutStarted || events[1] != EventBrownoutEnded {
+		t.Errorf("expected started/ended events, got %v", events)
+	}
+
+	var disabled *Brownout
+	if ok, maxTokens := disabled.Admit("llama-70b", 0); !ok || maxTokens != 0 {
+		t.Error("nil Brownout must admit everything unchanged")
+	}
+}
+
+func TestClusterLoad(t *testing.T) {
+	profiles := []WorkerProfile{
+		{WorkerID: "gpu-1", TotalVRAM: 24 << 30, ActiveTasks: 3, QueueLength: 1, MaxTasks: 4},
+		{WorkerID: "gpu-2", TotalVRAM: 24 << 30, ActiveTasks: 2, MaxTasks: 4},
+		{WorkerID: "cloud-fallback", ActiveTasks: 0, MaxTasks: 100},
+		{WorkerID: "peer-eu", TotalVRAM: 80 << 30, MaxTasks: 16, Peer: true},
+	}
+	if load := ClusterLoad(profiles); load != 0.75 {
+		t.Errorf("expected 75%% local load, got %v", load)
+	}
+}
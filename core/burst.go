@@ -0,0 +1,141 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BurstPolicy is a per-plan burst credit bucket: Tokens of credit, refilled continuously over Refill
+type BurstPolicy struct {
+	Tokens int64
+	Refill time.Duration
+}
+
+// burstBucket is the credit level of one key
+type burstBucket struct {
+	level   float64
+	updated time.Time
+}
+
+// BurstLimiter lets keys keep going on burst credits once the wrapped limiter refuses them
+// Usage while the key is running on credits is charged to its bucket instead of the wrapped limiter,
+// so small users get headroom without raising their steady-state limits
+type BurstLimiter struct {
+	next     RateLimiter
+	keys     *KeyDirectory
+	policies map[string]BurstPolicy // plan -> policy
+
+	mu      sync.Mutex
+	buckets map[string]*burstBucket
+	now     func() time.Time
+}
+
+// NewBurstLimiter wraps next with burst credits per plan; keys resolves API keys to plans
+func NewBurstLimiter(next RateLimiter, keys *KeyDirectory, policies map[string]BurstPolicy) *BurstLimiter {
+	return &BurstLimiter{
+		next:     next,
+		keys:     keys,
+		policies: policies,
+		buckets:  make(map[string]*burstBucket),
+		now:      time.Now,
+	}
+}
+
+// Allow admits the request if the wrapped limiter does, or if the key has at least one token of burst credit
+func (l *BurstLimiter) Allow(ctx context.Context, apiKey string) (bool, error) {
+	ok, err := l.next.Allow(ctx, apiKey)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	policy, hasPolicy := l.policy(apiKey)
+	if !hasPolicy {
+		return false, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.levelLocked(apiKey, policy) >= 1, nil
+}
+
+// Consume charges the wrapped limiter while it still admits the key, and the burst bucket otherwise
+// The bucket may go negative; the debt is paid back by the refill
+func (l *BurstLimiter) Consume(ctx context.Context, apiKey string, actualTokens int) error {
+	policy, hasPolicy := l.policy(apiKey)
+	if hasPolicy {
+		if ok, err := l.next.Allow(ctx, apiKey); err == nil && !ok {
+			l.mu.Lock()
+			l.levelLocked(apiKey, policy)
+			l.buckets[apiKey].level -= float64(actualTokens)
+			l.mu.Unlock()
+			return nil
+		}
+	}
+	return l.next.Consume(ctx, apiKey, actualTokens)
+}
+
+// Credits returns the burst credit currently available to a key
+func (l *BurstLimiter) Credits(apiKey string) int64 {
+	policy, ok := l.policy(apiKey)
+	if !ok {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int64(l.levelLocked(apiKey, policy))
+}
+
+func (l *BurstLimiter) policy(apiKey string) (BurstPolicy, bool) {
+	policy, ok := l.policies[l.keys.Lookup(apiKey).Plan]
+	return policy, ok
+}
+
+// levelLocked refills the key's bucket up to now and returns its level; caller must hold l.mu
+// New keys start with a full bucket
+func (l *BurstLimiter) levelLocked(apiKey string, policy BurstPolicy) float64 {
+	now := l.now()
+	b, ok := l.buckets[apiKey]
+	if !ok {
+		b = &burstBucket{level: float64(policy.Tokens), updated: now}
+		l.buckets[apiKey] = b
+	}
+
+	if elapsed := now.Sub(b.updated); elapsed > 0 && policy.Refill > 0 {
+		b.level += float64(policy.Tokens) * elapsed.Seconds() / policy.Refill.Seconds()
+		if b.level > float64(policy.Tokens) {
+			b.level = float64(policy.Tokens)
+		}
+	}
+	b.updated = now
+	return b.level
+}
+
+// ParseBurstPolicies parses "plan=tokens/refill;..." e.g. "free=10000/1h"
+func ParseBurstPolicies(spec string) (map[string]BurstPolicy, error) {
+	policies := make(map[string]BurstPolicy)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		plan, rule, ok := strings.Cut(entry, "=")
+		tokens, refill, ok2 := strings.Cut(rule, "/")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid burst policy %q: expected plan=tokens/refill", entry)
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(tokens), 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid burst policy %q: tokens must be a positive integer", entry)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(refill))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid burst policy %q: refill must be a positive duration", entry)
+		}
+		policies[strings.TrimSpace(plan)] = BurstPolicy{Tokens: n, Refill: d}
+	}
+	return policies, nil
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBurstLimiter_CreditsAndRefill(t *testing.T) {
+	ctx := context.Background()
+	keys, _ := ParseKeyDirectory("test-key-123=acme/free")
+	policies, err := ParseBurstPolicies("free=1000/1h")
+	if err != nil {
+		t.Fatalf("ParseBurstPolicies failed: %v", err)
+	}
+
+	base := NewInMemoryRateLimiter()
+	limiter := NewBurstLimiter(base, keys, policies)
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	// 用尽基础余额（100）
+	limiter.Consume(ctx, "test-key-123", 100)
+	if ok, _ := base.Allow(ctx, "test-key-123"); ok {
+		t.Fatal("Expected base balance to be exhausted")
+	}
+
+	// 依靠突发额度继续服务，且不再扣减基础余额
+	if ok, _ := limiter.Allow(ctx, "test-key-123"); !ok {
+		t.Fatal("Expected burst credits to admit the request")
+	}
+	limiter.Consume(ctx, "test-key-123", 1000)
+	if ok, _ := limiter.Allow(ctx, "test-key-123"); ok {
+		t.Error("Expected request to be refused once burst credits are used up")
+	}
+	if base.balances["test-key-123"] != 0 {
+		t.Errorf("Expected burst usage not to touch the base balance, got %d", base.balances["test-key-123"])
+	}
+
+	// 半小时后恢复一半额度
+	now = now.Add(30 * time.Minute)
+	if credits := limiter.Credits("test-key-123"); credits != 500 {
+		t.Errorf("Expected 500 credits after half the refill window, got %d", credits)
+	}
+
+	// 无突发策略的计划不受影响
+	if ok, _ := limiter.Allow(ctx, "unknown-key"); ok {
+		t.Error("Expected keys without a burst plan to be refused")
+	}
+}
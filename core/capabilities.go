@@ -0,0 +1,35 @@
+package core
+
+// Capabilities describes the optional API features a worker's backend supports
+// The zero value is a plain chat backend: no tools, no images, no JSON mode, no embeddings
+type Capabilities struct {
+	// Tools means the backend emits tool_call deltas
+	Tools bool `json:"tools,omitempty"`
+	// Vision means the backend accepts image_url message parts
+	Vision bool `json:"vision,omitempty"`
+	// JSONMode means the backend honors response_format
+	JSONMode   bool `json:"json_mode,omitempty"`
+	Embeddings bool `json:"embeddings,omitempty"`
+	// MaxBatchSize is the largest embeddings input array the worker accepts per call (0 = not reported)
+	MaxBatchSize int `json:"max_batch_size,omitempty"`
+	// MaxContext is the context window in tokens (0 = not reported, treated as unlimited)
+	MaxContext int `json:"max_context,omitempty"`
+}
+
+// Missing returns the name of the first capability required by need that c lacks, or "" if c satisfies need
+// need.MaxContext is the context length the request needs (prompt plus requested completion)
+func (c Capabilities) Missing(need Capabilities) string {
+	switch {
+	case need.Tools && !c.Tools:
+		return "tools"
+	case need.Vision && !c.Vision:
+		return "vision"
+	case need.JSONMode && !c.JSONMode:
+		return "json_mode"
+	case need.Embeddings && !c.Embeddings:
+		return "embeddings"
+	case need.MaxContext > 0 && c.MaxContext > 0 && need.MaxContext > c.MaxContext:
+		return "max_context"
+	}
+	return ""
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// DefaultCaptureSize is the number of payloads kept by a PayloadCapture
+const DefaultCaptureSize = 100
+
+// CapturedPayload is one sampled request with the full response the worker produced
+type CapturedPayload struct {
+	ID       string          `json:"id"`
+	Time     time.Time       `json:"time"`
+	Key      string          `json:"key"` // masked API key
+	User     string          `json:"user,omitempty"`
+	Model    string          `json:"model"`
+	WorkerID string          `json:"worker_id"`
+	Request  json.RawMessage `json:"request"`
+	Response string          `json:"response"`
+	// Reasoning is the thinking channel of reasoning models
+	Reasoning    string `json:"reasoning,omitempty"`
+	FinishReason string `json:"finish_reason,omitempty"`
+	Error        string `json:"error,omitempty"`
+	DurationMs   int64  `json:"duration_ms"`
+}
+
+// PayloadCapture keeps a sample of full request/response payloads in a ring buffer
+// for reproducing bad model output without always-on audit logging
+type PayloadCapture struct {
+	mu    sync.Mutex
+	rate  float64
+	ring  []*CapturedPayload
+	next  int
+	count int
+}
+
+// NewPayloadCapture creates a capture keeping the last size payloads, sampling the given fraction (0-1) of requests
+func NewPayloadCapture(rate float64, size int) *PayloadCapture {
+	if size <= 0 {
+		size = DefaultCaptureSize
+	}
+	c := &PayloadCapture{ring: make([]*CapturedPayload, size)}
+	c.SetRate(rate)
+	return c
+}
+
+// SetRate changes the sampled fraction of requests at runtime; values are clamped to 0-1
+func (c *PayloadCapture) SetRate(rate float64) {
+	if rate < 0 {
+		rate = 0
+	}
+	if rate > 1 {
+		rate = 1
+	}
+	c.mu.Lock()
+	c.rate = rate
+	c.mu.Unlock()
+}
+
+// Rate returns the sampled fraction of requests
+func (c *PayloadCapture) Rate() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rate
+}
+
+// Sample decides whether the current request is captured; a nil capture never samples
+func (c *PayloadCapture) Sample() bool {
+	if c == nil {
+		return false
+	}
+	rate := c.Rate()
+	return rate > 0 && rand.Float64() < rate
+}
+
+// Add stores a payload, evicting the oldest one when the buffer is full
+func (c *PayloadCapture) Add(p *CapturedPayload) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ring[c.next] = p
+	c.next = (c.next + 1) % len(c.ring)
+	if c.count < len(c.ring) {
+		c.count++
+	}
+}
+
+// List returns up to limit payloads, newest first (limit <= 0 returns all)
+func (c *PayloadCapture) List(limit int) []CapturedPayload {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if limit <= 0 || limit > c.count {
+		limit = c.count
+	}
+	out := make([]CapturedPayload, 0, limit)
+	for i := 1; i <= limit; i++ {
+		out = append(out, *c.ring[(c.next-i+len(c.ring))%len(c.ring)])
+	}
+	return out
+}
+
+// Get returns the payload with the given ID (the request's trace ID)
+func (c *PayloadCapture) Get(id string) (CapturedPayload, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range c.ring {
+		if p != nil && p.ID == id {
+			return *p, true
+		}
+	}
+	return CapturedPayload{}, false
+}
+
+// Clear drops every captured payload
+func (c *PayloadCapture) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.ring {
+		c.ring[i] = nil
+	}
+	c.next, c.count = 0, 0
+}
+
+// CaptureWorker wraps a worker so everything it streams for one request is recorded into a payload
+type CaptureWorker struct {
+	Worker
+	capture *PayloadCapture
+	payload *CapturedPayload
+}
+
+// NewCaptureWorker records the response of the next Execute into payload and stores it in capture
+func NewCaptureWorker(w Worker, capture *PayloadCapture, payload *CapturedPayload) *CaptureWorker {
+	return &CaptureWorker{Worker: w, capture: capture, payload: payload}
+}
+
+// Execute forwards to the wrapped worker, recording every chunk and the final error
+func (w *CaptureWorker) Execute(ctx context.Context, req *InferenceRequest, sender func(chunk StreamChunk) error) error {
+	start := time.Now()
+	err := w.Worker.Execute(ctx, req, func(chunk StreamChunk) error {
+		w.payload.Response += chunk.Content
+		w.payload.Reasoning += chunk.Reasoning
+		if chunk.FinishReason != "" {
+			w.payload.FinishReason = chunk.FinishReason
+		}
+		return sender(chunk)
+	})
+	if err != nil {
+		w.payload.Error = err.Error()
+	}
+	w.payload.WorkerID = w.Worker.ID()
+	w.payload.DurationMs = time.Since(start).Milliseconds()
+	w.capture.Add(w.payload)
+	return err
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+type captureTestWorker struct{}
+
+func (captureTestWorker) ID() string { return "w1" }
+
+func (captureTestWorker) Heartbeat(ctx context.Context) (WorkerProfile, error) {
+	return WorkerProfile{WorkerID: "w1"}, nil
+}
+
+func (captureTestWorker) Execute(ctx context.Context, req *InferenceRequest, sender func(chunk StreamChunk) error) error {
+	if err := sender(StreamChunk{Content: "Hel"}); err != nil {
+		return err
+	}
+	if err := sender(StreamChunk{Content: "lo", FinishReason: "stop"}); err != nil {
+		return err
+	}
+	if req.Model == "broken" {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func TestPayloadCapture_Ring(t *testing.T) {
+	c := NewPayloadCapture(1, 3)
+	for i := 0; i < 5; i++ {
+		c.Add(&CapturedPayload{ID: strconv.Itoa(i)})
+	}
+
+	list := c.List(0)
+	if len(list) != 3 || list[0].ID != "4" || list[2].ID != "2" {
+		t.Errorf("expected newest 3 payloads, got %+v", list)
+	}
+	if _, ok := c.Get("1"); ok {
+		t.Error("expected evicted payload to be gone")
+	}
+	if len(c.List(2)) != 2 {
+		t.Error("expected limit to be honored")
+	}
+
+	c.Clear()
+	if len(c.List(0)) != 0 {
+		t.Error("expected empty capture after Clear")
+	}
+}
+
+func TestPayloadCapture_Sample(t *testing.T) {
+	var nilCapture *PayloadCapture
+	if nilCapture.Sample() {
+		t.Error("nil capture must not sample")
+	}
+	if NewPayloadCapture(0, 1).Sample() {
+		t.Error("rate 0 must not sample")
+	}
+	if !NewPayloadCapture(1, 1).Sample() {
+		t.Error("rate 1 must always sample")
+	}
+}
+
+func TestCaptureWorker(t *testing.T) {
+	c := NewPayloadCapture(1, 10)
+
+	var streamed string
+	w := NewCaptureWorker(captureTestWorker{}, c, &CapturedPayload{ID: "trace-1"})
+	err := w.Execute(context.Background(), &InferenceRequest{Model: "m"}, func(chunk StreamChunk) error {
+		streamed += chunk.Content
+		return nil
+	})
+	if err != nil || streamed != "Hello" {
+		t.Fatalf("expected chunks to pass through, got %q, %v", streamed, err)
+	}
+
+	w = NewCaptureWorker(captureTestWorker{}, c, &CapturedPayload{ID: "trace-2"})
+	_ = w.Execute(context.Background(), &InferenceRequest{Model: "broken"}, func(StreamChunk) error { return nil })
+
+	p, ok := c.Get("trace-1")
+	if !ok || p.Response != "Hello" || p.FinishReason != "stop" || p.WorkerID != "w1" {
+		t.Errorf("unexpected capture: %+v", p)
+	}
+	if p, _ := c.Get("trace-2"); p.Error != "boom" {
+		t.Errorf("expected error to be captured, got %+v", p)
+	}
+}
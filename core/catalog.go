@@ -0,0 +1,127 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CatalogEntry describes the request limits of one model
+type CatalogEntry struct {
+	// MaxTokens caps max_tokens; requests without max_tokens get this limit too (0 = no limit)
+	MaxTokens int
+	// MaxTemperature caps temperature (0 = no limit below the API maximum)
+	MaxTemperature float32
+	// Reject refuses out-of-range values instead of clamping them
+	Reject bool
+}
+
+// ModelCatalog maps lower-cased model names to their entries; "*" applies to models without an entry
+type ModelCatalog map[string]CatalogEntry
+
+// ParamLimitError reports a request parameter above its model's catalog limit
+type ParamLimitError struct {
+	Model string
+	Param string
+	Value float64
+	Limit float64
+}
+
+func (e *ParamLimitError) Error() string {
+	// 按 float32 精度输出，避免 0.7 显示为 0.699999988
+	value := strconv.FormatFloat(e.Value, 'g', -1, 32)
+	limit := strconv.FormatFloat(e.Limit, 'g', -1, 32)
+	return fmt.Sprintf("%s of %s exceeds the limit of %s for model %s", e.Param, value, limit, e.Model)
+}
+
+// ParseModelCatalog parses "model=key:value,...;..." with keys max_tokens, temperature and mode (clamp or reject)
+// e.g. "llama-3-8b=max_tokens:4096,temperature:1.5;*=max_tokens:8192,mode:reject"
+func ParseModelCatalog(spec string) (ModelCatalog, error) {
+	catalog := make(ModelCatalog)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		model, rules, ok := strings.Cut(entry, "=")
+		model = strings.ToLower(strings.TrimSpace(model))
+		if !ok || model == "" {
+			return nil, fmt.Errorf("invalid catalog entry %q: expected model=key:value,...", entry)
+		}
+		var e CatalogEntry
+		for _, rule := range strings.Split(rules, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(rule), ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid catalog entry %q: expected key:value, got %q", entry, rule)
+			}
+			value = strings.TrimSpace(value)
+			switch strings.TrimSpace(key) {
+			case "max_tokens":
+				n, err := strconv.Atoi(value)
+				if err != nil || n <= 0 {
+					return nil, fmt.Errorf("invalid catalog entry %q: max_tokens must be a positive integer", entry)
+				}
+				e.MaxTokens = n
+			case "temperature":
+				t, err := strconv.ParseFloat(value, 32)
+				if err != nil || t <= 0 {
+					return nil, fmt.Errorf("invalid catalog entry %q: temperature must be a positive number", entry)
+				}
+				e.MaxTemperature = float32(t)
+			case "mode":
+				switch value {
+				case "clamp":
+					e.Reject = false
+				case "reject":
+					e.Reject = true
+				default:
+					return nil, fmt.Errorf("invalid catalog entry %q: mode must be clamp or reject", entry)
+				}
+			default:
+				return nil, fmt.Errorf("invalid catalog entry %q: unknown key %q", entry, key)
+			}
+		}
+		catalog[model] = e
+	}
+	return catalog, nil
+}
+
+// Lookup returns the entry of a model, falling back to "*"
+func (c ModelCatalog) Lookup(model string) (CatalogEntry, bool) {
+	if e, ok := c[strings.ToLower(model)]; ok {
+		return e, true
+	}
+	e, ok := c["*"]
+	return e, ok
+}
+
+// Enforce applies the model's limits to req: out-of-range values are clamped, or refused with a
+// *ParamLimitError when the entry is in reject mode. It returns the names of the clamped parameters
+func (c ModelCatalog) Enforce(req *InferenceRequest) ([]string, error) {
+	e, ok := c.Lookup(req.Model)
+	if !ok {
+		return nil, nil
+	}
+
+	var clamped []string
+	if e.MaxTokens > 0 && req.MaxTokens > e.MaxTokens {
+		if e.Reject {
+			return nil, &ParamLimitError{Model: req.Model, Param: "max_tokens", Value: float64(req.MaxTokens), Limit: float64(e.MaxTokens)}
+		}
+		req.MaxTokens = e.MaxTokens
+		clamped = append(clamped, "max_tokens")
+	}
+	if e.MaxTokens > 0 && req.MaxTokens == 0 {
+		// 未指定 max_tokens 时使用目录上限，避免后端默认值超出模型能力
+		req.MaxTokens = e.MaxTokens
+	}
+	if e.MaxTemperature > 0 && req.Temperature > e.MaxTemperature {
+		if e.Reject {
+			return nil, &ParamLimitError{Model: req.Model, Param: "temperature", Value: float64(req.Temperature), Limit: float64(e.MaxTemperature)}
+		}
+		req.Temperature = e.MaxTemperature
+		clamped = append(clamped, "temperature")
+	}
+	return clamped, nil
+}
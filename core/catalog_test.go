@@ -0,0 +1,63 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseModelCatalog(t *testing.T) {
+	catalog, err := ParseModelCatalog("Llama-3-8B=max_tokens:4096,temperature:1.5; *=max_tokens:8192,mode:reject")
+	if err != nil {
+		t.Fatalf("ParseModelCatalog: %v", err)
+	}
+	want := ModelCatalog{
+		"llama-3-8b": {MaxTokens: 4096, MaxTemperature: 1.5},
+		"*":          {MaxTokens: 8192, Reject: true},
+	}
+	if !reflect.DeepEqual(catalog, want) {
+		t.Fatalf("catalog = %+v, want %+v", catalog, want)
+	}
+
+	for _, spec := range []string{"llama", "llama=max_tokens", "llama=max_tokens:0", "llama=temperature:x", "llama=mode:strict", "llama=top_k:5"} {
+		if _, err := ParseModelCatalog(spec); err == nil {
+			t.Errorf("ParseModelCatalog(%q) should fail", spec)
+		}
+	}
+}
+
+func TestModelCatalog_Enforce(t *testing.T) {
+	catalog := ModelCatalog{
+		"llama-3-8b": {MaxTokens: 4096, MaxTemperature: 1.5},
+		"*":          {MaxTokens: 8192, Reject: true},
+	}
+
+	req := &InferenceRequest{Model: "Llama-3-8B", MaxTokens: 10000, Temperature: 1.9}
+	clamped, err := catalog.Enforce(req)
+	if err != nil {
+		t.Fatalf("Enforce: %v", err)
+	}
+	if !reflect.DeepEqual(clamped, []string{"max_tokens", "temperature"}) || req.MaxTokens != 4096 || req.Temperature != 1.5 {
+		t.Errorf("clamp: got %v, max_tokens=%d temperature=%v", clamped, req.MaxTokens, req.Temperature)
+	}
+
+	// 未指定 max_tokens 时补上目录上限，但不算作截断
+	req = &InferenceRequest{Model: "llama-3-8b"}
+	if clamped, _ := catalog.Enforce(req); len(clamped) != 0 || req.MaxTokens != 4096 {
+		t.Errorf("default: got %v, max_tokens=%d", clamped, req.MaxTokens)
+	}
+
+	req = &InferenceRequest{Model: "qwen-72b", MaxTokens: 9000}
+	_, err = catalog.Enforce(req)
+	var limitErr *ParamLimitError
+	if !errors.As(err, &limitErr) || limitErr.Param != "max_tokens" || limitErr.Limit != 8192 {
+		t.Fatalf("reject: err = %v", err)
+	}
+	if req.MaxTokens != 9000 {
+		t.Errorf("rejected request should be left unchanged, max_tokens=%d", req.MaxTokens)
+	}
+
+	if _, err := (ModelCatalog{}).Enforce(&InferenceRequest{Model: "any", MaxTokens: 1 << 20}); err != nil {
+		t.Errorf("empty catalog should accept everything: %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// DefaultClusterPrefix is the prefix of the Redis keys holding shared cluster state
+const DefaultClusterPrefix = "zam:cluster:"
+
+// ClusterState shares in-flight counts and open breakers between gateway replicas through Redis,
+// so per-key concurrency, worker load and quarantine hold when the gateway is scaled out
+// Each replica publishes its own counts under a hash field with an expiry, and sums the others'
+type ClusterState struct {
+	client     *RedisClient
+	prefix     string
+	nodeID     string
+	inflight   *InflightTracker
+	quarantine *Quarantine
+	now        func() time.Time
+}
+
+// clusterNode is the state one replica publishes; Expires lets the others drop replicas that died
+type clusterNode struct {
+	Expires  int64            `json:"expires"` // unix milliseconds
+	Inflight InflightSnapshot `json:"inflight"`
+}
+
+// NewClusterState creates the shared state of the replica nodeID; either tracker may be nil
+func NewClusterState(client *RedisClient, nodeID string, inflight *InflightTracker, quarantine *Quarantine) *ClusterState {
+	return &ClusterState{
+		client:     client,
+		prefix:     DefaultClusterPrefix,
+		nodeID:     nodeID,
+		inflight:   inflight,
+		quarantine: quarantine,
+		now:        time.Now,
+	}
+}
+
+// Sync publishes the state of this replica for ttl and merges the state of the other live replicas
+func (s *ClusterState) Sync(ctx context.Context, ttl time.Duration) error {
+	now := s.now()
+	if s.inflight != nil {
+		node, err := json.Marshal(clusterNode{Expires: now.Add(ttl).UnixMilli(), Inflight: s.inflight.Snapshot()})
+		if err != nil {
+			return err
+		}
+		if _, err := s.client.Do(ctx, "HSET", s.prefix+"nodes", s.nodeID, string(node)); err != nil {
+			return err
+		}
+
+		nodes, err := s.hgetall(ctx, "nodes")
+		if err != nil {
+			return err
+		}
+		var remote InflightSnapshot
+		for id, value := range nodes {
+			if id == s.nodeID {
+				continue
+			}
+			var node clusterNode
+			if err := json.Unmarshal([]byte(value), &node); err != nil || node.Expires <= now.UnixMilli() {
+				// 副本已下线或数据损坏，顺带清理
+				_, _ = s.client.Do(ctx, "HDEL", s.prefix+"nodes", id)
+				continue
+			}
+			remote.Add(node.Inflight)
+		}
+		s.inflight.SetRemote(remote)
+	}
+
+	if s.quarantine != nil {
+		for workerID, until := range s.quarantine.Opened() {
+			if _, err := s.client.Do(ctx, "HSET", s.prefix+"breakers", workerID, strconv.FormatInt(until.UnixMilli(), 10)); err != nil {
+				return err
+			}
+		}
+
+		breakers, err := s.hgetall(ctx, "breakers")
+		if err != nil {
+			return err
+		}
+		for workerID, value := range breakers {
+			ms, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || ms <= now.UnixMilli() {
+				// 冷却已结束：各副本各自进入半开探测
+				_, _ = s.client.Do(ctx, "HDEL", s.prefix+"breakers", workerID)
+				continue
+			}
+			s.quarantine.Open(workerID, time.UnixMilli(ms))
+		}
+	}
+	return nil
+}
+
+// Leave removes this replica's in-flight counts from the shared state
+func (s *ClusterState) Leave(ctx context.Context) error {
+	_, err := s.client.Do(ctx, "HDEL", s.prefix+"nodes", s.nodeID)
+	return err
+}
+
+// Run syncs every interval until ctx is cancelled, then leaves the cluster
+func (s *ClusterState) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// 留出三个周期的余量，偶发的同步失败不会让其他副本丢掉本副本的计数
+	ttl := 3 * interval
+	for {
+		if err := s.Sync(ctx, ttl); err != nil && ctx.Err() == nil {
+			slog.Warn("cluster sync failed", "node_id", s.nodeID, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := s.Leave(shutdown); err != nil {
+				slog.Error("cluster leave failed", "node_id", s.nodeID, "error", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// hgetall reads a hash of the cluster state as a map
+func (s *ClusterState) hgetall(ctx context.Context, key string) (map[string]string, error) {
+	reply, err := s.client.Do(ctx, "HGETALL", s.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected HGETALL reply %T", reply)
+	}
+	fields := make(map[string]string, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		field, _ := items[i].([]byte)
+		value, _ := items[i+1].([]byte)
+		fields[string(field)] = string(value)
+	}
+	return fields, nil
+}
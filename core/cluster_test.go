@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClusterStateSharesInflightAndBreakers(t *testing.T) {
+	srv, url := startFakeRedis(t)
+	ctx := context.Background()
+	newReplica := func(id string) (*ClusterState, *InflightTracker, *Quarantine) {
+		client, err := NewRedisClient(url)
+		if err != nil {
+			t.Fatalf("NewRedisClient: %v", err)
+		}
+		inflight := NewInflightTracker()
+		quarantine := NewQuarantine(QuarantinePolicy{MaxFailures: 1, CoolDown: time.Minute, ProbeSuccesses: 1}, nil)
+		return NewClusterState(client, id, inflight, quarantine), inflight, quarantine
+	}
+	a, inflightA, quarantineA := newReplica("gw-a")
+	b, inflightB, quarantineB := newReplica("gw-b")
+
+	release := inflightA.AcquireModel("gpu-1", "sk-tenant", "llama-3-8b")
+	inflightB.AcquireModel("gpu-1", "sk-tenant", "llama-3-8b")
+	inflightA.TryAcquireTenant("sk-tenant", 2)
+	quarantineA.RecordFailure("gpu-2")
+	for _, s := range []*ClusterState{a, b} {
+		if err := s.Sync(ctx, time.Minute); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+	}
+
+	if n := inflightB.WorkerCount("gpu-1"); n != 2 {
+		t.Errorf("expected 2 in-flight requests on gpu-1 across replicas, got %d", n)
+	}
+	if n := inflightB.ModelCount("gpu-1", "llama-3-8b"); n != 2 {
+		t.Errorf("expected 2 in-flight llama-3-8b requests across replicas, got %d", n)
+	}
+	if n := inflightB.TenantCount("sk-tenant"); n != 2 {
+		t.Errorf("expected 2 in-flight requests of the tenant across replicas, got %d", n)
+	}
+	// 准入名额同样跨副本计算
+	if _, ok := inflightB.TryAcquireTenant("sk-tenant", 1); ok {
+		t.Error("expected gw-a's admitted request to count against the key's limit on gw-b")
+	}
+	if _, ok := inflightB.TryAcquireTenant("sk-tenant", 2); !ok {
+		t.Error("expected a second admission under a limit of 2")
+	}
+	if quarantineB.State("gpu-2") != BreakerOpen {
+		t.Errorf("expected the breaker tripped on gw-a to be open on gw-b, got %s", quarantineB.State("gpu-2"))
+	}
+	// API Key 不以明文写入 Redis
+	srv.mu.Lock()
+	for _, value := range srv.hashes[DefaultClusterPrefix+"nodes"] {
+		if strings.Contains(value, "sk-tenant") {
+			t.Errorf("tenant leaked into the shared state: %s", value)
+		}
+	}
+	srv.mu.Unlock()
+
+	// 请求结束后计数随下一次同步消失
+	release()
+	a.Sync(ctx, time.Minute)
+	b.Sync(ctx, time.Minute)
+	if n := inflightB.WorkerCount("gpu-1"); n != 1 {
+		t.Errorf("expected only gw-b's request after gw-a released, got %d", n)
+	}
+
+	// 过期的副本被忽略并清理
+	inflightA.Acquire("gpu-1", "sk-other")
+	a.Sync(ctx, time.Minute)
+	b.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	b.Sync(ctx, time.Minute)
+	if n := inflightB.WorkerCount("gpu-1"); n != 1 {
+		t.Errorf("expected the expired replica to be ignored, got %d", n)
+	}
+	srv.mu.Lock()
+	_, stale := srv.hashes[DefaultClusterPrefix+"nodes"]["gw-a"]
+	srv.mu.Unlock()
+	if stale {
+		t.Error("expected the expired replica to be removed")
+	}
+
+	if err := b.Leave(ctx); err != nil {
+		t.Fatalf("Leave: %v", err)
+	}
+}
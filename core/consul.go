@@ -0,0 +1,142 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ConsulService describes how the gateway registers itself in the Consul catalog
+type ConsulService struct {
+	ID      string
+	Name    string
+	Address string
+	Port    int
+	Tags    []string
+}
+
+// ConsulPublisher keeps the gateway registered in a local Consul agent with a weight following its
+// free capacity; Consul's DNS interface then serves the weight in SRV records
+// A TTL check is passed while the gateway has capacity and set to warning otherwise, so it gets the
+// warning weight of 1 instead of disappearing from the catalog
+type ConsulPublisher struct {
+	addr    string
+	token   string
+	service ConsulService
+	client  *http.Client
+}
+
+// NewConsulPublisher creates a publisher for the agent at addr, e.g. "http://127.0.0.1:8500"
+// token is sent as X-Consul-Token when non-empty
+func NewConsulPublisher(addr, token string, service ConsulService) *ConsulPublisher {
+	return &ConsulPublisher{
+		addr:    strings.TrimRight(addr, "/"),
+		token:   token,
+		service: service,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+type consulRegistration struct {
+	ID      string           `json:"ID"`
+	Name    string           `json:"Name"`
+	Address string           `json:"Address,omitempty"`
+	Port    int              `json:"Port,omitempty"`
+	Tags    []string         `json:"Tags,omitempty"`
+	Weights consulWeights    `json:"Weights"`
+	Check   consulServiceTTL `json:"Check"`
+}
+
+type consulWeights struct {
+	Passing int `json:"Passing"`
+	Warning int `json:"Warning"`
+}
+
+type consulServiceTTL struct {
+	TTL                            string `json:"TTL"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter"`
+}
+
+// Publish registers the service with weight and updates its TTL check; Consul requires a passing
+// weight of at least 1, so a zero weight is published as a warning instead
+func (p *ConsulPublisher) Publish(ctx context.Context, weight int, ttl time.Duration) error {
+	passing := weight
+	if passing < 1 {
+		passing = 1
+	}
+	reg := consulRegistration{
+		ID:      p.service.ID,
+		Name:    p.service.Name,
+		Address: p.service.Address,
+		Port:    p.service.Port,
+		Tags:    p.service.Tags,
+		Weights: consulWeights{Passing: passing, Warning: 1},
+		Check: consulServiceTTL{
+			TTL:                            ttl.String(),
+			DeregisterCriticalServiceAfter: (10 * ttl).String(),
+		},
+	}
+	body, _ := json.Marshal(reg)
+	if err := p.put(ctx, "/v1/agent/service/register", body); err != nil {
+		return err
+	}
+
+	status := "pass"
+	if weight <= 0 {
+		status = "warn"
+	}
+	return p.put(ctx, "/v1/agent/check/"+status+"/service:"+p.service.ID, nil)
+}
+
+// Deregister removes the service from the agent, e.g. on shutdown
+func (p *ConsulPublisher) Deregister(ctx context.Context) error {
+	return p.put(ctx, "/v1/agent/service/deregister/"+p.service.ID, nil)
+}
+
+// Run publishes the weight returned by weight every interval until ctx is cancelled, then deregisters
+func (p *ConsulPublisher) Run(ctx context.Context, weight func() int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// TTL 留出三个周期的余量，避免一次发布失败就被判定为 critical
+	ttl := 3 * interval
+	for {
+		if err := p.Publish(ctx, weight(), ttl); err != nil && ctx.Err() == nil {
+			slog.Warn("consul publish failed", "service_id", p.service.ID, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := p.Deregister(shutdown); err != nil {
+				slog.Error("consul deregister failed", "service_id", p.service.ID, "error", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *ConsulPublisher) put(ctx context.Context, path string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, p.addr+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if p.token != "" {
+		req.Header.Set("X-Consul-Token", p.token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("consul %s returned %s", path, resp.Status)
+	}
+	return nil
+}
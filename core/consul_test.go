@@ -0,0 +1,63 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConsulPublisher(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	var reg consulRegistration
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method != http.MethodPut || r.Header.Get("X-Consul-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/v1/agent/service/register" {
+			body, _ := io.ReadAll(r.Body)
+			json.Unmarshal(body, &reg)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewConsulPublisher(srv.URL+"/", "secret", ConsulService{ID: "gw-a", Name: "zam-gateway", Port: 8080})
+	if err := p.Publish(context.Background(), 12, 30*time.Second); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := p.Publish(context.Background(), 0, 30*time.Second); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := p.Deregister(context.Background()); err != nil {
+		t.Fatalf("Deregister: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{
+		"/v1/agent/service/register", "/v1/agent/check/pass/service:gw-a",
+		"/v1/agent/service/register", "/v1/agent/check/warn/service:gw-a",
+		"/v1/agent/service/deregister/gw-a",
+	}
+	if len(paths) != len(want) {
+		t.Fatalf("paths = %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("path %d = %s, want %s", i, paths[i], want[i])
+		}
+	}
+	// 零权重以 warning 状态发布，Consul 要求 Passing 至少为 1
+	if reg.Weights.Passing != 1 || reg.Weights.Warning != 1 || reg.Check.TTL != "30s" {
+		t.Errorf("registration = %+v, want passing weight 1 and a 30s TTL", reg)
+	}
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultHeartbeatInterval is the heartbeat period the gateway asks workers to use
+// It stays well below DefaultDegradedAfter so a single lost heartbeat does not degrade a worker
+const DefaultHeartbeatInterval = 5 * time.Second
+
+// WorkerDirectives are instructions returned to a worker in its heartbeat response
+type WorkerDirectives struct {
+	// Drain asks the worker to finish in-flight requests and stop accepting new ones
+	Drain bool `json:"drain,omitempty"`
+	// MaxTasks overrides the concurrency limit the worker reports (0 = no override)
+	MaxTasks int `json:"max_tasks,omitempty"`
+	// Preload and Unload list models to load into / evict from VRAM; delivered once
+	Preload []string `json:"preload,omitempty"`
+	Unload  []string `json:"unload,omitempty"`
+	// HeartbeatIntervalSeconds is the heartbeat period the worker should use
+	HeartbeatIntervalSeconds int `json:"heartbeat_interval_seconds"`
+}
+
+// DirectiveStore holds pending directives per worker
+// Drain and MaxTasks persist until changed; Preload and Unload are cleared once delivered
+type DirectiveStore struct {
+	mu       sync.Mutex
+	pending  map[string]WorkerDirectives
+	interval time.Duration
+}
+
+// NewDirectiveStore creates a store that asks workers to heartbeat every interval
+func NewDirectiveStore(interval time.Duration) *DirectiveStore {
+	if interval <= 0 {
+		interval = DefaultHeartbeatInterval
+	}
+	return &DirectiveStore{
+		pending:  make(map[string]WorkerDirectives),
+		interval: interval,
+	}
+}
+
+// Set replaces the directives for a worker
+// A zero HeartbeatIntervalSeconds keeps the store's default interval
+func (s *DirectiveStore) Set(workerID string, d WorkerDirectives) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[workerID] = d
+}
+
+// Get returns the directives for a worker without consuming one-shot entries
+func (s *DirectiveStore) Get(workerID string) WorkerDirectives {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.withDefaults(s.pending[workerID])
+}
+
+// Take returns the directives to deliver with a heartbeat and clears the one-shot entries
+func (s *DirectiveStore) Take(workerID string) WorkerDirectives {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.pending[workerID]
+	if ok && (len(d.Preload) > 0 || len(d.Unload) > 0) {
+		rest := d
+		rest.Preload, rest.Unload = nil, nil
+		s.pending[workerID] = rest
+	}
+	return s.withDefaults(d)
+}
+
+// Delete forgets the directives of a worker, e.g. after it is deregistered
+func (s *DirectiveStore) Delete(workerID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, workerID)
+}
+
+// withDefaults fills in the store-wide heartbeat interval
+func (s *DirectiveStore) withDefaults(d WorkerDirectives) WorkerDirectives {
+	if d.HeartbeatIntervalSeconds <= 0 {
+		d.HeartbeatIntervalSeconds = int(s.interval / time.Second)
+	}
+	return d
+}
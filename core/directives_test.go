@@ -0,0 +1,24 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDirectiveStore_OneShotEntries(t *testing.T) {
+	store := NewDirectiveStore(0)
+	store.Set("worker-1", WorkerDirectives{Drain: true, Preload: []string{"llama-8b"}})
+
+	first := store.Take("worker-1")
+	if !first.Drain || len(first.Preload) != 1 {
+		t.Fatalf("Expected drain and preload on first delivery, got %+v", first)
+	}
+	if first.HeartbeatIntervalSeconds != int(DefaultHeartbeatInterval/time.Second) {
+		t.Errorf("Expected default heartbeat interval, got %d", first.HeartbeatIntervalSeconds)
+	}
+
+	second := store.Take("worker-1")
+	if !second.Drain || len(second.Preload) != 0 {
+		t.Errorf("Expected drain to persist and preload to be consumed, got %+v", second)
+	}
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultShutdownTimeout bounds how long a shutting-down gateway waits for in-flight requests
+const DefaultShutdownTimeout = 30 * time.Second
+
+// Drainer counts in-flight client requests so shutdown can let active streams finish
+// Once Drain starts, Enter refuses new requests while the admitted ones run to completion
+type Drainer struct {
+	mu       sync.Mutex
+	draining bool
+	inflight int
+	idle     chan struct{} // 排空期间在最后一个请求结束时关闭
+}
+
+// NewDrainer creates a drainer that admits requests
+func NewDrainer() *Drainer {
+	return &Drainer{}
+}
+
+// Enter admits a request, returning false once the gateway is draining
+// Every admitted request must call Exit; a nil drainer admits everything
+func (d *Drainer) Enter() bool {
+	if d == nil {
+		return true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return false
+	}
+	d.inflight++
+	return true
+}
+
+// Exit marks an admitted request as finished
+func (d *Drainer) Exit() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inflight--
+	if d.inflight == 0 && d.idle != nil {
+		close(d.idle)
+		d.idle = nil
+	}
+}
+
+// Draining reports whether new requests are being refused
+func (d *Drainer) Draining() bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
+
+// InFlight returns the number of admitted requests that have not finished
+func (d *Drainer) InFlight() int {
+	if d == nil {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.inflight
+}
+
+// Drain stops admitting requests and waits until the admitted ones finish or ctx is done,
+// in which case it returns ctx.Err() with requests still in flight
+func (d *Drainer) Drain(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	d.draining = true
+	if d.inflight == 0 {
+		d.mu.Unlock()
+		return nil
+	}
+	if d.idle == nil {
+		d.idle = make(chan struct{})
+	}
+	idle := d.idle
+	d.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDrainer(t *testing.T) {
+	d := NewDrainer()
+	if !d.Enter() || !d.Enter() {
+		t.Fatal("expected requests to be admitted before draining")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- d.Drain(context.Background()) }()
+
+	// 排空开始后拒绝新请求，进行中的请求继续
+	deadline := time.Now().Add(time.Second)
+	for !d.Draining() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if d.Enter() {
+		t.Fatal("expected new requests to be refused while draining")
+	}
+	d.Exit()
+	select {
+	case err := <-done:
+		t.Fatalf("Drain returned %v with a request in flight", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	d.Exit()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Drain = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Drain did not return after the last request finished")
+	}
+}
+
+func TestDrainer_Timeout(t *testing.T) {
+	d := NewDrainer()
+	d.Enter()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := d.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Drain = %v, want deadline exceeded", err)
+	}
+	if d.InFlight() != 1 {
+		t.Errorf("InFlight = %d, want 1", d.InFlight())
+	}
+	// 无进行中请求时立即返回
+	if err := NewDrainer().Drain(context.Background()); err != nil {
+		t.Errorf("Drain of an idle drainer = %v", err)
+	}
+}
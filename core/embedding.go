@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// EmbedRequest is a request to embed a list of inputs
+type EmbedRequest struct {
+	TraceID string
+	Tenant  string
+	Model   string
+	Inputs  []string
+	// Dimensions asks models that support it to shorten their vectors (0 = model default)
+	Dimensions int
+}
+
+// Embedder is implemented by workers that serve embedding models
+// It returns one vector per input, in input order
+type Embedder interface {
+	Embed(ctx context.Context, req *EmbedRequest) ([][]float32, error)
+}
+
+// DefaultEmbedBatchSize is used for workers that do not report Capabilities.MaxBatchSize
+const DefaultEmbedBatchSize = 64
+
+// EmbedBatchOptions controls how a large embeddings request is split
+type EmbedBatchOptions struct {
+	// BatchSize caps the inputs per worker call (0 = DefaultEmbedBatchSize); a smaller worker limit wins
+	BatchSize int
+	// Parallelism is the number of batches in flight at once (0 = 4)
+	Parallelism int
+	// AllowPartial returns the vectors of successful batches when others fail instead of failing the request
+	AllowPartial bool
+}
+
+// EmbedPicker chooses the worker for the next batch and reports the batch size it accepts (0 = unknown)
+// exclude lists workers that already failed this batch
+type EmbedPicker func(ctx context.Context, batch *EmbedRequest, exclude map[string]bool) (Worker, int, error)
+
+// BatchFailure describes inputs [Start, End) that could not be embedded
+type BatchFailure struct {
+	Start int
+	End   int
+	Err   error
+}
+
+// EmbedResult holds merged vectors in input order; failed inputs have nil vectors
+type EmbedResult struct {
+	Vectors  [][]float32
+	Failures []BatchFailure
+}
+
+// ErrEmbedBatchFailed is wrapped by EmbedBatched when a batch fails and partial results are not allowed
+var ErrEmbedBatchFailed = errors.New("embedding batch failed")
+
+// EmbedBatched splits req into worker-sized batches, dispatches them in parallel and merges the
+// vectors back in input order
+// Partial-failure semantics: a failed batch is retried once on a different worker; if it still fails,
+// the whole request fails with ErrEmbedBatchFailed unless opts.AllowPartial is set, in which case the
+// result carries nil vectors for the failed range and lists it in Failures
+func EmbedBatched(ctx context.Context, req *EmbedRequest, pick EmbedPicker, opts EmbedBatchOptions) (*EmbedResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultEmbedBatchSize
+	}
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = 4
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	result := &EmbedResult{Vectors: make([][]float32, len(req.Inputs))}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, opts.Parallelism)
+	)
+	fail := func(f BatchFailure) {
+		mu.Lock()
+		defer mu.Unlock()
+		result.Failures = append(result.Failures, f)
+		if !opts.AllowPartial {
+			// 不允许部分成功时，尽早取消其余批次
+			cancel()
+		}
+	}
+
+	for start := 0; start < len(req.Inputs); {
+		if err := ctx.Err(); err != nil {
+			// 已取消：剩余输入整体记为失败
+			fail(BatchFailure{Start: start, End: len(req.Inputs), Err: err})
+			break
+		}
+		batch := &EmbedRequest{TraceID: req.TraceID, Tenant: req.Tenant, Model: req.Model, Dimensions: req.Dimensions}
+
+		// 先选 Worker，再按其能力决定批大小
+		worker, limit, err := pick(ctx, batch, nil)
+		size := opts.BatchSize
+		if limit > 0 && limit < size {
+			size = limit
+		}
+		end := start + size
+		if end > len(req.Inputs) {
+			end = len(req.Inputs)
+		}
+		batch.Inputs = req.Inputs[start:end]
+		if err != nil {
+			fail(BatchFailure{Start: start, End: end, Err: err})
+			start = end
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(start, end int, batch *EmbedRequest, worker Worker) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			vectors, err := embedOnce(ctx, worker, batch)
+			if err != nil && ctx.Err() == nil {
+				// 换一个 Worker 重试一次
+				if retry, _, pickErr := pick(ctx, batch, map[string]bool{worker.ID(): true}); pickErr == nil {
+					vectors, err = embedOnce(ctx, retry, batch)
+				}
+			}
+			if err != nil {
+				fail(BatchFailure{Start: start, End: end, Err: err})
+				return
+			}
+			copy(result.Vectors[start:end], vectors)
+		}(start, end, batch, worker)
+		start = end
+	}
+	wg.Wait()
+
+	if len(result.Failures) > 0 && !opts.AllowPartial {
+		f := result.Failures[0]
+		return nil, fmt.Errorf("%w: inputs [%d, %d): %w", ErrEmbedBatchFailed, f.Start, f.End, f.Err)
+	}
+	return result, nil
+}
+
+// embedOnce runs one batch on a worker and checks the vector count
+func embedOnce(ctx context.Context, worker Worker, batch *EmbedRequest) ([][]float32, error) {
+	embedder, ok := worker.(Embedder)
+	if !ok {
+		return nil, fmt.Errorf("worker %s does not serve embeddings", worker.ID())
+	}
+	vectors, err := embedder.Embed(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(batch.Inputs) {
+		return nil, fmt.Errorf("worker %s returned %d vectors for %d inputs", worker.ID(), len(vectors), len(batch.Inputs))
+	}
+	return vectors, nil
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// embedWorker returns the input index encoded in a one-dimensional vector
+type embedWorker struct {
+	MockWorker
+	mu      sync.Mutex
+	calls   int
+	failing bool
+}
+
+func (w *embedWorker) Embed(ctx context.Context, req *EmbedRequest) ([][]float32, error) {
+	w.mu.Lock()
+	w.calls++
+	w.mu.Unlock()
+	if w.failing {
+		return nil, fmt.Errorf("worker %s is down", w.id)
+	}
+	vectors := make([][]float32, len(req.Inputs))
+	for i, input := range req.Inputs {
+		var n int
+		fmt.Sscanf(input, "in-%d", &n)
+		vectors[i] = []float32{float32(n)}
+	}
+	return vectors, nil
+}
+
+func TestEmbedBatched_OrderAndFailover(t *testing.T) {
+	inputs := make([]string, 25)
+	for i := range inputs {
+		inputs[i] = fmt.Sprintf("in-%d", i)
+	}
+	healthy := &embedWorker{MockWorker: MockWorker{id: "healthy"}}
+	broken := &embedWorker{MockWorker: MockWorker{id: "broken"}, failing: true}
+
+	// 轮流选择 Worker，坏节点上的批次应在健康节点上重试
+	var mu sync.Mutex
+	turn := 0
+	pick := func(ctx context.Context, batch *EmbedRequest, exclude map[string]bool) (Worker, int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		turn++
+		if turn%2 == 0 && !exclude["broken"] {
+			return broken, 4, nil
+		}
+		return healthy, 8, nil
+	}
+
+	result, err := EmbedBatched(context.Background(), &EmbedRequest{Model: "bge", Inputs: inputs}, pick, EmbedBatchOptions{BatchSize: 10})
+	if err != nil {
+		t.Fatalf("EmbedBatched failed: %v", err)
+	}
+	for i, v := range result.Vectors {
+		if len(v) != 1 || int(v[0]) != i {
+			t.Fatalf("vector %d out of order: %v", i, v)
+		}
+	}
+	if broken.calls == 0 {
+		t.Error("expected some batches to be dispatched to the broken worker")
+	}
+}
+
+func TestEmbedBatched_PartialFailure(t *testing.T) {
+	inputs := []string{"in-0", "in-1", "in-2", "in-3"}
+	healthy := &embedWorker{MockWorker: MockWorker{id: "healthy"}}
+	broken := &embedWorker{MockWorker: MockWorker{id: "broken"}, failing: true}
+	// 第一批落在健康节点，第二批及其重试都落在故障节点
+	picks := 0
+	pick := func(ctx context.Context, batch *EmbedRequest, exclude map[string]bool) (Worker, int, error) {
+		if exclude != nil {
+			return broken, 2, nil
+		}
+		picks++
+		if picks == 1 {
+			return healthy, 2, nil
+		}
+		return broken, 2, nil
+	}
+
+	_, err := EmbedBatched(context.Background(), &EmbedRequest{Inputs: inputs}, pick, EmbedBatchOptions{Parallelism: 1})
+	if !errors.Is(err, ErrEmbedBatchFailed) {
+		t.Fatalf("expected ErrEmbedBatchFailed without AllowPartial, got %v", err)
+	}
+
+	picks = 0
+	result, err := EmbedBatched(context.Background(), &EmbedRequest{Inputs: inputs}, pick, EmbedBatchOptions{Parallelism: 1, AllowPartial: true})
+	if err != nil {
+		t.Fatalf("expected partial result, got %v", err)
+	}
+	if result.Vectors[0] == nil || result.Vectors[1] == nil || result.Vectors[2] != nil || result.Vectors[3] != nil {
+		t.Errorf("unexpected partial vectors: %v", result.Vectors)
+	}
+	if len(result.Failures) != 1 || result.Failures[0].Start != 2 || result.Failures[0].End != 4 {
+		t.Errorf("unexpected failures: %+v", result.Failures)
+	}
+}
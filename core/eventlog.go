@@ -0,0 +1,196 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultEventRetention is how long the event log keeps events
+const DefaultEventRetention = 7 * 24 * time.Hour
+
+// EventQuery filters the event log; zero fields match everything
+type EventQuery struct {
+	Type     EventType
+	WorkerID string
+	Since    time.Time
+	Until    time.Time
+	// Limit caps the number of returned events (newest first); <= 0 means no cap
+	Limit int
+}
+
+// EventLog persists significant gateway events for postmortems
+// Events are appended to a JSON-lines file and replayed at startup; events older than the
+// retention are dropped from memory and compacted out of the file periodically
+type EventLog struct {
+	mu        sync.RWMutex
+	events    []Event // oldest first
+	retention time.Duration
+	path      string
+	file      *os.File
+}
+
+// NewEventLog opens the log at path ("" keeps events in memory only)
+func NewEventLog(path string, retention time.Duration) (*EventLog, error) {
+	if retention <= 0 {
+		retention = DefaultEventRetention
+	}
+	l := &EventLog{retention: retention, path: path}
+	if path == "" {
+		return l, nil
+	}
+
+	if err := l.replay(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %w", err)
+	}
+	l.file = f
+	return l, nil
+}
+
+// Record appends an event; it is meant to be subscribed to the EventBus
+func (l *EventLog) Record(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+
+	if l.file != nil {
+		line, err := json.Marshal(e)
+		if err == nil {
+			_, err = l.file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			slog.Error("failed to persist event", "type", e.Type, "error", err)
+		}
+	}
+}
+
+// Query returns the matching events, newest first
+func (l *EventLog) Query(q EventQuery) []Event {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var out []Event
+	for i := len(l.events) - 1; i >= 0; i-- {
+		e := l.events[i]
+		if (q.Type != "" && e.Type != q.Type) ||
+			(q.WorkerID != "" && e.WorkerID != q.WorkerID) ||
+			(!q.Since.IsZero() && e.Time.Before(q.Since)) ||
+			(!q.Until.IsZero() && !e.Time.Before(q.Until)) {
+			continue
+		}
+		out = append(out, e)
+		if q.Limit > 0 && len(out) >= q.Limit {
+			break
+		}
+	}
+	return out
+}
+
+// Run compacts the log every hour until ctx is cancelled
+func (l *EventLog) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Compact(); err != nil {
+				slog.Error("event log compaction failed", "error", err)
+			}
+		}
+	}
+}
+
+// Compact drops events older than the retention and rewrites the file without them
+func (l *EventLog) Compact() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := time.Now().Add(-l.retention)
+	kept := 0
+	for kept < len(l.events) && l.events[kept].Time.Before(cutoff) {
+		kept++
+	}
+	if kept == 0 {
+		return nil
+	}
+	l.events = append([]Event(nil), l.events[kept:]...)
+	if l.file == nil {
+		return nil
+	}
+
+	// 写临时文件后原子替换，崩溃时不会丢失旧日志
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	for _, e := range l.events {
+		line, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write event log: %w", err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to replace event log: %w", err)
+	}
+
+	l.file.Close()
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		l.file = nil
+		return fmt.Errorf("failed to reopen event log: %w", err)
+	}
+	l.file = f
+	return nil
+}
+
+// replay loads the events written by previous runs that are still within the retention
+func (l *EventLog) replay() error {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+	defer f.Close()
+
+	cutoff := time.Now().Add(-l.retention)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// 进程崩溃可能留下半行，跳过即可
+			continue
+		}
+		if e.Time.Before(cutoff) {
+			continue
+		}
+		l.events = append(l.events, e)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventLog_PersistAndQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	l, err := NewEventLog(path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewEventLog failed: %v", err)
+	}
+
+	now := time.Now()
+	l.Record(Event{Type: EventWorkerJoined, WorkerID: "gpu-1", Time: now.Add(-3 * time.Minute)})
+	l.Record(Event{Type: EventWorkerQuarantined, WorkerID: "gpu-1", Time: now.Add(-2 * time.Minute)})
+	l.Record(Event{Type: EventWorkerJoined, WorkerID: "gpu-2", Time: now.Add(-time.Minute)})
+
+	// 重新打开后从文件恢复
+	l, err = NewEventLog(path, time.Hour)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	all := l.Query(EventQuery{})
+	if len(all) != 3 || all[0].WorkerID != "gpu-2" {
+		t.Fatalf("expected 3 events newest first, got %+v", all)
+	}
+	if got := l.Query(EventQuery{Type: EventWorkerJoined}); len(got) != 2 {
+		t.Errorf("expected 2 joins, got %+v", got)
+	}
+	if got := l.Query(EventQuery{WorkerID: "gpu-1", Since: now.Add(-150 * time.Second)}); len(got) != 1 || got[0].Type != EventWorkerQuarantined {
+		t.Errorf("expected the quarantine event, got %+v", got)
+	}
+	if got := l.Query(EventQuery{Limit: 1}); len(got) != 1 {
+		t.Errorf("expected limit to be honored, got %+v", got)
+	}
+}
+
+func TestEventLog_Retention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	l, err := NewEventLog(path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewEventLog failed: %v", err)
+	}
+	l.Record(Event{Type: EventWorkerExpired, WorkerID: "old", Time: time.Now().Add(-2 * time.Hour)})
+	l.Record(Event{Type: EventWorkerJoined, WorkerID: "new"})
+
+	if err := l.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if got := l.Query(EventQuery{}); len(got) != 1 || got[0].WorkerID != "new" {
+		t.Errorf("expected only the recent event, got %+v", got)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), `"old"`) {
+		t.Error("expected expired event to be compacted out of the file")
+	}
+
+	// 压缩后仍可继续追加
+	l.Record(Event{Type: EventWorkerJoined, WorkerID: "newer"})
+	l, _ = NewEventLog(path, time.Hour)
+	if got := l.Query(EventQuery{}); len(got) != 2 {
+		t.Errorf("expected 2 events after reopen, got %+v", got)
+	}
+}
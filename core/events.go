@@ -0,0 +1,79 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies a significant gateway event
+type EventType string
+
+const (
+	// EventWorkerQuarantined is emitted when a worker is removed from routing after consecutive failures
+	EventWorkerQuarantined EventType = "worker_quarantined"
+	// EventWorkerReadmitted is emitted when a quarantined worker passes its probes and rejoins routing
+	EventWorkerReadmitted EventType = "worker_readmitted"
+	// EventWorkerDeregistered is emitted when a worker leaves the pool on purpose
+	EventWorkerDeregistered EventType = "worker_deregistered"
+	// EventWorkerJoined is emitted when a worker registers or heartbeats for the first time
+	EventWorkerJoined EventType = "worker_joined"
+	// EventWorkerExpired is emitted when a worker is removed after missing its heartbeats
+	EventWorkerExpired EventType = "worker_expired"
+	// EventWorkerDrained is emitted when an operator cordons a worker to drain it
+	EventWorkerDrained EventType = "worker_drained"
+	// EventWorkerUncordoned is emitted when a cordoned worker is returned to routing
+	EventWorkerUncordoned EventType = "worker_uncordoned"
+	// EventQuotaCutoff is emitted when a request is refused or cut off by a quota or spend cap
+	EventQuotaCutoff EventType = "quota_cutoff"
+	// EventRequestShed is emitted when a request is rejected because no worker could take it
+	EventRequestShed EventType = "request_shed"
+	// EventBrownoutStarted is emitted when sustained overload makes the gateway shed expensive features
+	EventBrownoutStarted EventType = "brownout_started"
+	// EventBrownoutEnded is emitted when the load drops and full service resumes
+	EventBrownoutEnded EventType = "brownout_ended"
+)
+
+// Event describes something operators may want to be alerted about
+type Event struct {
+	Type     EventType `json:"type"`
+	WorkerID string    `json:"worker_id,omitempty"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+// EventBus fans events out to subscribers synchronously
+// Subscribers must be fast and must not publish recursively
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers []func(Event)
+}
+
+// NewEventBus creates an EventBus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe registers fn to receive every published event
+func (b *EventBus) Subscribe(fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, fn)
+}
+
+// Publish delivers the event to all subscribers; a nil bus drops the event
+func (b *EventBus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+
+	for _, fn := range subscribers {
+		fn(e)
+	}
+}
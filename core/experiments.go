@@ -0,0 +1,314 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExperimentHeader tags responses with the experiment arm that served them: "<experiment>=<arm>"
+const ExperimentHeader = "X-Zam-Experiment"
+
+// experimentLatencySamples is how many recent latencies each arm keeps for percentiles
+const experimentLatencySamples = 1024
+
+// ExperimentArm is one side of an A/B experiment
+type ExperimentArm struct {
+	Name string `json:"name"`
+	// Model replaces the requested model for the arm's requests (empty keeps it); "base@adapter" selects a LoRA adapter
+	Model string `json:"model,omitempty"`
+	// Class restricts the arm to workers of a class, e.g. "vllm" (empty allows every worker)
+	Class string `json:"class,omitempty"`
+	// Percent is the share of the model's traffic assigned to the arm
+	Percent int `json:"percent"`
+}
+
+// Experiment splits the traffic of a model between two arms
+type Experiment struct {
+	Name  string           `json:"name"`
+	Model string           `json:"model"`
+	Arms  [2]ExperimentArm `json:"arms"`
+}
+
+// ExperimentAssignment is the arm a request was assigned to
+type ExperimentAssignment struct {
+	Experiment string
+	Arm        ExperimentArm
+}
+
+// Tag returns the ExperimentHeader value of the assignment
+func (a ExperimentAssignment) Tag() string {
+	return a.Experiment + "=" + a.Arm.Name
+}
+
+// Apply routes req as the arm dictates
+func (a ExperimentAssignment) Apply(req *InferenceRequest) {
+	if a.Arm.Model != "" {
+		req.Model, req.Adapter = SplitAdapterModel(a.Arm.Model)
+	}
+	req.WorkerClass = a.Arm.Class
+}
+
+// ParseExperiments parses "name:model=arm[:model][@class]/percent,arm[:model][@class]/percent;..."
+// e.g. "q4:llama-3-8b=control/90,quant:llama-3-8b-q4/10"; the two percentages must add up to 100
+func ParseExperiments(spec string) ([]Experiment, error) {
+	var experiments []Experiment
+	models := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		target, arms, ok := strings.Cut(entry, "=")
+		name, model, ok2 := strings.Cut(strings.TrimSpace(target), ":")
+		armSpecs := strings.Split(arms, ",")
+		if !ok || !ok2 || name == "" || model == "" || len(armSpecs) != 2 {
+			return nil, fmt.Errorf("invalid experiment %q: expected name:model=arm/percent,arm/percent", entry)
+		}
+		if models[strings.ToLower(model)] {
+			return nil, fmt.Errorf("invalid experiment %q: model %s already has an experiment", entry, model)
+		}
+		models[strings.ToLower(model)] = true
+
+		exp := Experiment{Name: name, Model: model}
+		for i, armSpec := range armSpecs {
+			arm, err := parseExperimentArm(strings.TrimSpace(armSpec))
+			if err != nil {
+				return nil, fmt.Errorf("invalid experiment %q: %w", entry, err)
+			}
+			exp.Arms[i] = arm
+		}
+		if exp.Arms[0].Name == exp.Arms[1].Name {
+			return nil, fmt.Errorf("invalid experiment %q: arm names must differ", entry)
+		}
+		if exp.Arms[0].Percent+exp.Arms[1].Percent != 100 {
+			return nil, fmt.Errorf("invalid experiment %q: arm percentages must add up to 100", entry)
+		}
+		experiments = append(experiments, exp)
+	}
+	return experiments, nil
+}
+
+func parseExperimentArm(spec string) (ExperimentArm, error) {
+	backend, percent, ok := strings.Cut(spec, "/")
+	p, err := strconv.Atoi(strings.TrimSpace(percent))
+	if !ok || err != nil || p < 0 || p > 100 {
+		return ExperimentArm{}, fmt.Errorf("arm %q needs a percentage between 0 and 100", spec)
+	}
+	arm := ExperimentArm{Percent: p}
+	backend, arm.Class, _ = strings.Cut(backend, "@")
+	arm.Name, arm.Model, _ = strings.Cut(backend, ":")
+	if arm.Name == "" {
+		return ExperimentArm{}, fmt.Errorf("arm %q has no name", spec)
+	}
+	return arm, nil
+}
+
+// ExperimentArmStats aggregates the outcomes of an arm
+type ExperimentArmStats struct {
+	ExperimentArm
+	Requests int `json:"requests"`
+	Errors   int `json:"errors"`
+	// Truncated counts completions cut off by max_tokens (finish_reason "length")
+	Truncated        int     `json:"truncated"`
+	ErrorRate        float64 `json:"error_rate"`
+	CompletionTokens int     `json:"completion_tokens"`
+	// Latencies are in milliseconds, over the most recent successful requests
+	MeanLatencyMs       float64 `json:"mean_latency_ms"`
+	P50LatencyMs        float64 `json:"p50_latency_ms"`
+	P95LatencyMs        float64 `json:"p95_latency_ms"`
+	MeanFirstTokenMs    float64 `json:"mean_first_token_ms"`
+	MeanTokensPerSecond float64 `json:"mean_tokens_per_second"`
+}
+
+// ExperimentStats is the comparison of the two arms of an experiment
+type ExperimentStats struct {
+	Name  string                `json:"name"`
+	Model string                `json:"model"`
+	Arms  [2]ExperimentArmStats `json:"arms"`
+}
+
+type armStats struct {
+	requests, errors, truncated, tokens int
+	succeeded                           int
+	latencyTotal, firstTokenTotal       time.Duration
+	firstTokens                         int
+	tokensPerSecond                     float64
+	latencies                           []time.Duration
+	next                                int
+}
+
+func (s *armStats) observe(latency, firstToken time.Duration, tokens int, finishReason string, err error) {
+	s.requests++
+	if err != nil {
+		s.errors++
+		return
+	}
+	s.succeeded++
+	s.tokens += tokens
+	if finishReason == "length" {
+		s.truncated++
+	}
+	s.latencyTotal += latency
+	if firstToken > 0 {
+		s.firstTokens++
+		s.firstTokenTotal += firstToken
+		if tokens > 1 && latency > firstToken {
+			s.tokensPerSecond += float64(tokens-1) / (latency - firstToken).Seconds()
+		}
+	}
+	if len(s.latencies) < experimentLatencySamples {
+		s.latencies = append(s.latencies, latency)
+	} else {
+		s.latencies[s.next] = latency
+		s.next = (s.next + 1) % experimentLatencySamples
+	}
+}
+
+func (s *armStats) snapshot(arm ExperimentArm) ExperimentArmStats {
+	out := ExperimentArmStats{
+		ExperimentArm:    arm,
+		Requests:         s.requests,
+		Errors:           s.errors,
+		Truncated:        s.truncated,
+		CompletionTokens: s.tokens,
+	}
+	if s.requests > 0 {
+		out.ErrorRate = float64(s.errors) / float64(s.requests)
+	}
+	if s.succeeded > 0 {
+		out.MeanLatencyMs = milliseconds(s.latencyTotal) / float64(s.succeeded)
+	}
+	if s.firstTokens > 0 {
+		out.MeanFirstTokenMs = milliseconds(s.firstTokenTotal) / float64(s.firstTokens)
+		out.MeanTokensPerSecond = s.tokensPerSecond / float64(s.firstTokens)
+	}
+	if len(s.latencies) > 0 {
+		sorted := append([]time.Duration(nil), s.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		out.P50LatencyMs = milliseconds(sorted[(len(sorted)-1)*50/100])
+		out.P95LatencyMs = milliseconds(sorted[(len(sorted)-1)*95/100])
+	}
+	return out
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+type experimentState struct {
+	Experiment
+	stats [2]armStats
+}
+
+// Experiments assigns requests to experiment arms and aggregates per-arm outcomes
+type Experiments struct {
+	mu      sync.Mutex
+	byModel map[string]*experimentState
+	order   []*experimentState
+}
+
+// NewExperiments creates the experiment set; each model has at most one experiment
+func NewExperiments(experiments []Experiment) *Experiments {
+	e := &Experiments{byModel: make(map[string]*experimentState)}
+	for _, exp := range experiments {
+		state := &experimentState{Experiment: exp}
+		e.byModel[strings.ToLower(exp.Model)] = state
+		e.order = append(e.order, state)
+	}
+	return e
+}
+
+// Assign picks the arm of a request for model; the same sticky key (e.g. key and end user)
+// always lands on the same arm. ok is false when the model has no experiment or e is nil
+func (e *Experiments) Assign(model, sticky string) (ExperimentAssignment, bool) {
+	if e == nil {
+		return ExperimentAssignment{}, false
+	}
+	state, ok := e.byModel[strings.ToLower(model)]
+	if !ok {
+		return ExperimentAssignment{}, false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(state.Name + "\x00" + sticky))
+	arm := state.Arms[1]
+	if int(h.Sum32()%100) < state.Arms[0].Percent {
+		arm = state.Arms[0]
+	}
+	return ExperimentAssignment{Experiment: state.Name, Arm: arm}, true
+}
+
+// Instrument wraps the worker serving an assigned request so its outcome is recorded for the arm
+func (e *Experiments) Instrument(w Worker, assignment ExperimentAssignment) Worker {
+	return &experimentWorker{Worker: w, experiments: e, assignment: assignment}
+}
+
+func (e *Experiments) observe(a ExperimentAssignment, latency, firstToken time.Duration, tokens int, finishReason string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, state := range e.order {
+		if state.Name != a.Experiment {
+			continue
+		}
+		for i, arm := range state.Arms {
+			if arm.Name == a.Arm.Name {
+				state.stats[i].observe(latency, firstToken, tokens, finishReason, err)
+			}
+		}
+	}
+}
+
+// Stats returns the per-arm comparison of every experiment
+func (e *Experiments) Stats() []ExperimentStats {
+	if e == nil {
+		return []ExperimentStats{}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stats := make([]ExperimentStats, 0, len(e.order))
+	for _, state := range e.order {
+		s := ExperimentStats{Name: state.Name, Model: state.Model}
+		for i := range state.Arms {
+			s.Arms[i] = state.stats[i].snapshot(state.Arms[i])
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// experimentWorker records the latency, throughput and outcome of one Execute call
+type experimentWorker struct {
+	Worker
+	experiments *Experiments
+	assignment  ExperimentAssignment
+}
+
+// Execute forwards to the wrapped worker; client disconnects are not recorded
+func (w *experimentWorker) Execute(ctx context.Context, req *InferenceRequest, sender func(chunk StreamChunk) error) error {
+	start := time.Now()
+	var firstToken time.Duration
+	tokens := 0
+	finishReason := ""
+	err := w.Worker.Execute(ctx, req, func(chunk StreamChunk) error {
+		if chunk.Content != "" || chunk.Reasoning != "" || len(chunk.ToolCalls) > 0 {
+			if tokens == 0 {
+				firstToken = time.Since(start)
+			}
+			tokens++
+		}
+		if chunk.FinishReason != "" {
+			finishReason = chunk.FinishReason
+		}
+		return sender(chunk)
+	})
+	if ctx.Err() == nil {
+		w.experiments.observe(w.assignment, time.Since(start), firstToken, tokens, finishReason, err)
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseExperiments(t *testing.T) {
+	experiments, err := ParseExperiments("q4:llama-3-8b=control/90,quant:llama-3-8b-q4@vllm/10")
+	if err != nil {
+		t.Fatalf("ParseExperiments: %v", err)
+	}
+	want := Experiment{Name: "q4", Model: "llama-3-8b", Arms: [2]ExperimentArm{
+		{Name: "control", Percent: 90},
+		{Name: "quant", Model: "llama-3-8b-q4", Class: "vllm", Percent: 10},
+	}}
+	if len(experiments) != 1 || experiments[0] != want {
+		t.Fatalf("ParseExperiments = %+v, want %+v", experiments, want)
+	}
+
+	for _, spec := range []string{
+		"q4:llama-3-8b=control/90",
+		"q4:llama-3-8b=a/50,b/40",
+		"q4:llama-3-8b=a/50,a/50",
+		"q4=a/50,b/50",
+		"a:m=x/50,y/50;b:m=x/50,y/50",
+	} {
+		if _, err := ParseExperiments(spec); err == nil {
+			t.Errorf("ParseExperiments(%q) should fail", spec)
+		}
+	}
+}
+
+func TestExperiments_Assign(t *testing.T) {
+	experiments, _ := ParseExperiments("q4:llama-3-8b=control/70,quant:llama-3-8b-q4/30")
+	e := NewExperiments(experiments)
+
+	if _, ok := e.Assign("gemma-2b", "k"); ok {
+		t.Fatal("models without an experiment should not be assigned")
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		a, ok := e.Assign("LLAMA-3-8B", fmt.Sprintf("req-%d", i))
+		if !ok {
+			t.Fatal("expected an assignment")
+		}
+		counts[a.Arm.Name]++
+	}
+	if counts["quant"] < 2700 || counts["quant"] > 3300 {
+		t.Errorf("expected about 30%% of traffic on quant, got %v", counts)
+	}
+
+	// 相同的粘滞键总是落在同一分组
+	first, _ := e.Assign("llama-3-8b", "key\x00alice")
+	for i := 0; i < 10; i++ {
+		if a, _ := e.Assign("llama-3-8b", "key\x00alice"); a.Arm != first.Arm {
+			t.Fatal("assignment should be sticky")
+		}
+	}
+
+	req := &InferenceRequest{Model: "llama-3-8b"}
+	quant := ExperimentAssignment{Experiment: "q4", Arm: experiments[0].Arms[1]}
+	quant.Apply(req)
+	if req.Model != "llama-3-8b-q4" || quant.Tag() != "q4=quant" {
+		t.Errorf("unexpected quant routing: model %s, tag %s", req.Model, quant.Tag())
+	}
+
+	var nilExperiments *Experiments
+	if _, ok := nilExperiments.Assign("llama-3-8b", "k"); ok {
+		t.Error("nil Experiments should not assign")
+	}
+}
+
+type experimentTestWorker struct {
+	chunks []StreamChunk
+	err    error
+}
+
+func (w *experimentTestWorker) ID() string { return "w" }
+
+func (w *experimentTestWorker) Heartbeat(ctx context.Context) (WorkerProfile, error) {
+	return WorkerProfile{}, nil
+}
+
+func (w *experimentTestWorker) Execute(ctx context.Context, req *InferenceRequest, sender func(chunk StreamChunk) error) error {
+	for _, chunk := range w.chunks {
+		if err := sender(chunk); err != nil {
+			return err
+		}
+	}
+	return w.err
+}
+
+func TestExperiments_Stats(t *testing.T) {
+	experiments, _ := ParseExperiments("q4:llama-3-8b=control/50,quant:llama-3-8b-q4/50")
+	e := NewExperiments(experiments)
+	control := ExperimentAssignment{Experiment: "q4", Arm: experiments[0].Arms[0]}
+	quant := ExperimentAssignment{Experiment: "q4", Arm: experiments[0].Arms[1]}
+
+	ok := &experimentTestWorker{chunks: []StreamChunk{{Content: "a"}, {Content: "b"}, {FinishReason: "length"}}}
+	failing := &experimentTestWorker{err: errors.New("boom")}
+	noop := func(StreamChunk) error { return nil }
+	e.Instrument(ok, control).Execute(context.Background(), &InferenceRequest{}, noop)
+	e.Instrument(failing, quant).Execute(context.Background(), &InferenceRequest{}, noop)
+	e.Instrument(ok, quant).Execute(context.Background(), &InferenceRequest{}, noop)
+
+	// 客户端断开不计入统计
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e.Instrument(failing, quant).Execute(ctx, &InferenceRequest{}, noop)
+
+	stats := e.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("expected one experiment, got %d", len(stats))
+	}
+	c, q := stats[0].Arms[0], stats[0].Arms[1]
+	if c.Requests != 1 || c.Errors != 0 || c.Truncated != 1 || c.CompletionTokens != 2 {
+		t.Errorf("unexpected control stats: %+v", c)
+	}
+	if q.Requests != 2 || q.Errors != 1 || q.ErrorRate != 0.5 {
+		t.Errorf("unexpected quant stats: %+v", q)
+	}
+}
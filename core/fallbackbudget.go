@@ -0,0 +1,144 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrFallbackBudgetExceeded is returned by routers when the fallback workers' spend cap is used up
+var ErrFallbackBudgetExceeded = errors.New("fallback budget exceeded")
+
+// FallbackBudgetError tells which fallback cap is used up and when it resets
+// It matches both ErrFallbackBudgetExceeded and ErrNoAvailableWorkers, so requests still queue for local capacity
+type FallbackBudgetError struct {
+	Period  ResetPeriod
+	Limit   float64
+	ResetAt time.Time
+}
+
+func (e *FallbackBudgetError) Error() string {
+	return fmt.Sprintf("%s fallback budget of %.2f exhausted, resets at %s", e.Period, e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+func (e *FallbackBudgetError) Unwrap() []error {
+	return []error{ErrFallbackBudgetExceeded, ErrNoAvailableWorkers}
+}
+
+// FallbackBudget caps the daily and monthly spend on paid fallback workers, priced by their
+// WorkerProfile.CostPer1KTokens. Spend is counted per calendar period in the configured time zone
+// and kept in this gateway process
+type FallbackBudget struct {
+	limits map[ResetPeriod]float64
+	loc    *time.Location
+
+	mu    sync.Mutex
+	spent map[ResetPeriod]*budgetUsage
+	now   func() time.Time
+}
+
+// budgetUsage is the spend accumulated in the current period of one cap
+type budgetUsage struct {
+	periodStart time.Time
+	spent       float64
+}
+
+// NewFallbackBudget creates a budget with the given daily and monthly caps (0 = no cap)
+func NewFallbackBudget(daily, monthly float64, loc *time.Location) *FallbackBudget {
+	if loc == nil {
+		loc = time.UTC
+	}
+	b := &FallbackBudget{
+		limits: make(map[ResetPeriod]float64),
+		loc:    loc,
+		spent:  make(map[ResetPeriod]*budgetUsage),
+		now:    time.Now,
+	}
+	if daily > 0 {
+		b.limits[ResetDaily] = daily
+	}
+	if monthly > 0 {
+		b.limits[ResetMonthly] = monthly
+	}
+	return b
+}
+
+// Check returns a *FallbackBudgetError once a cap is used up
+// A nil budget never runs out
+func (b *FallbackBudget) Check() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, period := range []ResetPeriod{ResetMonthly, ResetDaily} {
+		limit, ok := b.limits[period]
+		if ok && b.spentLocked(period) >= limit {
+			return &FallbackBudgetError{Period: period, Limit: limit, ResetAt: period.Next(b.now().In(b.loc))}
+		}
+	}
+	return nil
+}
+
+// Charge records the cost of a request served by a fallback worker
+func (b *FallbackBudget) Charge(cost float64) {
+	if b == nil || cost <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for period := range b.limits {
+		b.spentLocked(period)
+		b.spent[period].spent += cost
+	}
+}
+
+// Spent returns the spend of the current period of a cap
+func (b *FallbackBudget) Spent(period ResetPeriod) float64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.limits[period]; !ok {
+		return 0
+	}
+	return b.spentLocked(period)
+}
+
+// spentLocked returns the spend of the current period, starting a new period when a boundary was crossed
+// Caller must hold b.mu
+func (b *FallbackBudget) spentLocked(period ResetPeriod) float64 {
+	start := period.Start(b.now().In(b.loc))
+	u, ok := b.spent[period]
+	if !ok {
+		u = &budgetUsage{periodStart: start}
+		b.spent[period] = u
+	}
+	if !u.periodStart.Equal(start) {
+		u.periodStart, u.spent = start, 0
+	}
+	return u.spent
+}
+
+// ParseBudget parses a spend cap in currency units, e.g. "50" or "12.5"; empty means no cap
+func ParseBudget(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+		return 0, fmt.Errorf("budget must be a non-negative number")
+	}
+	return v, nil
+}
+
+// RequestCost prices the tokens of a request at costPer1K per 1,000 tokens
+func RequestCost(costPer1K float64, promptTokens, completionTokens int) float64 {
+	return costPer1K * float64(promptTokens+completionTokens) / 1000
+}
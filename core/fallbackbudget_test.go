@@ -0,0 +1,49 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFallbackBudget(t *testing.T) {
+	budget := NewFallbackBudget(1, 20, time.UTC)
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	budget.now = func() time.Time { return now }
+
+	if err := budget.Check(); err != nil {
+		t.Fatalf("Check() on a fresh budget = %v", err)
+	}
+	// 0.01 / 1K Token，两次 50K Token 的请求用尽日预算
+	budget.Charge(RequestCost(0.01, 40000, 10000))
+	if err := budget.Check(); err != nil {
+		t.Fatalf("Check() after half the daily budget = %v", err)
+	}
+	budget.Charge(RequestCost(0.01, 40000, 10000))
+	err := budget.Check()
+	var budgetErr *FallbackBudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.Period != ResetDaily || !budgetErr.ResetAt.Equal(time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the daily budget to be exhausted until midnight, got %v", err)
+	}
+	if !errors.Is(err, ErrFallbackBudgetExceeded) || !errors.Is(err, ErrNoAvailableWorkers) {
+		t.Error("expected the budget error to match ErrFallbackBudgetExceeded and ErrNoAvailableWorkers")
+	}
+
+	// 次日日预算重置，月度消费继续累计
+	now = now.AddDate(0, 0, 1)
+	if err := budget.Check(); err != nil {
+		t.Errorf("expected the daily budget to reset, got %v", err)
+	}
+	if spent := budget.Spent(ResetMonthly); spent < 0.999 || spent > 1.001 {
+		t.Errorf("Spent(monthly) = %v, want 1", spent)
+	}
+
+	if _, err := ParseBudget("-1"); err == nil {
+		t.Error("expected a negative budget to be rejected")
+	}
+	var none *FallbackBudget
+	none.Charge(5)
+	if none.Check() != nil {
+		t.Error("expected a nil budget never to run out")
+	}
+}
@@ -0,0 +1,33 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// FederationHeader marks requests forwarded between peer gateways to prevent routing loops
+const FederationHeader = "X-Zam-Federated"
+
+// RunHeartbeatProbe periodically pulls the profile of a worker that cannot push heartbeats itself
+// (peer gateways, adapters) and feeds it into the registry until ctx is cancelled
+func RunHeartbeatProbe(ctx context.Context, registry WorkerRegistry, worker Worker, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			profile, err := worker.Heartbeat(ctx)
+			if err != nil {
+				slog.Warn("worker probe heartbeat failed", "worker_id", worker.ID(), "error", err)
+				continue
+			}
+			if err := registry.Heartbeat(profile); err != nil {
+				slog.Error("worker probe registry update failed", "worker_id", worker.ID(), "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package core
+
+import "time"
+
+// HealthState is the liveness state of a registered worker
+type HealthState string
+
+const (
+	// WorkerHealthy means heartbeats are arriving on time
+	WorkerHealthy HealthState = "healthy"
+	// WorkerDegraded means heartbeats are late but the worker has not been removed yet;
+	// it stays routable with a scoring penalty
+	WorkerDegraded HealthState = "degraded"
+)
+
+// DefaultDegradedAfter is the heartbeat age after which a worker is considered degraded
+const DefaultDegradedAfter = 8 * time.Second
+
+// WorkerState is the registry's view of a worker's liveness
+type WorkerState struct {
+	Health HealthState `json:"health"`
+	// Staleness is the time since the last heartbeat
+	Staleness time.Duration `json:"staleness"`
+}
+
+// WorkerStateSource reports the liveness state of workers
+type WorkerStateSource interface {
+	WorkerState(workerID string) WorkerState
+}
@@ -0,0 +1,249 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultMaxImageBytes is the largest image accepted in a request (20 MB, as on the OpenAI API)
+const DefaultMaxImageBytes = 20 << 20
+
+// DefaultImageCacheSize is how many fetched images are kept for repeated URLs
+const DefaultImageCacheSize = 128
+
+// imageCacheTTL bounds how long a fetched image is reused
+const imageCacheTTL = 10 * time.Minute
+
+// AllowedImageTypes are the image formats vision backends accept
+var AllowedImageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// ErrInvalidImage is wrapped by every image validation or fetch failure
+var ErrInvalidImage = errors.New("invalid image")
+
+// ImagePolicy configures validation and fetching of image_url parts
+type ImagePolicy struct {
+	// MaxBytes is the largest decoded image accepted
+	MaxBytes int64
+	// Fetch downloads remote images at the gateway and forwards them inline,
+	// for LAN workers that cannot reach the internet
+	Fetch bool
+	// CacheSize is the number of fetched images cached by URL (0 disables the cache)
+	CacheSize int
+}
+
+// DefaultImagePolicy validates images without fetching them
+func DefaultImagePolicy() ImagePolicy {
+	return ImagePolicy{MaxBytes: DefaultMaxImageBytes, CacheSize: DefaultImageCacheSize}
+}
+
+type cachedImage struct {
+	dataURI string
+	expires time.Time
+}
+
+// ImageProxy validates image URLs and, when fetching is enabled, resolves remote URLs to base64 data URIs
+// Fetches only reach public addresses so clients cannot use the gateway to probe the internal network
+type ImageProxy struct {
+	policy ImagePolicy
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedImage
+	order []string
+	now   func() time.Time
+}
+
+// NewImageProxy creates an ImageProxy with the given policy
+func NewImageProxy(policy ImagePolicy) *ImageProxy {
+	if policy.MaxBytes <= 0 {
+		policy.MaxBytes = DefaultMaxImageBytes
+	}
+	return &ImageProxy{
+		policy: policy,
+		client: newImageClient(false),
+		cache:  make(map[string]cachedImage),
+		now:    time.Now,
+	}
+}
+
+// newImageClient builds the fetch client; redirects are re-checked by the public-only dialer
+func newImageClient(allowPrivate bool) *http.Client {
+	return &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: publicTransport(allowPrivate),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return fmt.Errorf("%w: too many redirects", ErrInvalidImage)
+			}
+			return checkImageScheme(req.URL)
+		},
+	}
+}
+
+// ErrNonPublicAddress is returned when an outbound request of the gateway on behalf of a client
+// (image fetch, job webhook) targets a non-public address
+var ErrNonPublicAddress = errors.New("address is not public")
+
+// publicTransport returns a transport whose dialer refuses non-public addresses after DNS resolution,
+// which also covers redirects and DNS rebinding, so clients cannot use the gateway to probe the internal network
+func publicTransport(allowPrivate bool) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); !allowPrivate && (ip == nil || !publicIP(ip)) {
+				return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+			}
+			return nil
+		},
+	}
+	return &http.Transport{DialContext: dialer.DialContext, Proxy: nil}
+}
+
+// publicIP reports whether ip is a globally routable unicast address
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() || ip.IsInterfaceLocalMulticast())
+}
+
+func checkImageScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: unsupported URL scheme %q", ErrInvalidImage, u.Scheme)
+	}
+	return nil
+}
+
+// Resolve validates an image URL and returns the URL to forward: data URIs are checked for type and size,
+// remote URLs are fetched and inlined when fetching is enabled and passed through otherwise
+func (p *ImageProxy) Resolve(ctx context.Context, imageURL string) (string, error) {
+	if strings.HasPrefix(imageURL, "data:") {
+		return imageURL, p.validateDataURI(imageURL)
+	}
+	u, err := url.Parse(imageURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: malformed URL", ErrInvalidImage)
+	}
+	if err := checkImageScheme(u); err != nil {
+		return "", err
+	}
+	if !p.policy.Fetch {
+		return imageURL, nil
+	}
+
+	if dataURI, ok := p.cached(imageURL); ok {
+		return dataURI, nil
+	}
+	dataURI, err := p.fetch(ctx, imageURL)
+	if err != nil {
+		return "", err
+	}
+	p.store(imageURL, dataURI)
+	return dataURI, nil
+}
+
+// validateDataURI checks the media type and decoded size of a base64 data URI
+func (p *ImageProxy) validateDataURI(dataURI string) error {
+	header, data, ok := strings.Cut(strings.TrimPrefix(dataURI, "data:"), ",")
+	mediaType, encoding, _ := strings.Cut(header, ";")
+	if !ok || encoding != "base64" {
+		return fmt.Errorf("%w: data URI must be base64-encoded", ErrInvalidImage)
+	}
+	if err := p.checkType(mediaType); err != nil {
+		return err
+	}
+	if int64(base64.StdEncoding.DecodedLen(len(data))) > p.policy.MaxBytes+2 {
+		return p.tooLarge()
+	}
+	return nil
+}
+
+func (p *ImageProxy) fetch(ctx context.Context, imageURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrInvalidImage) {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: failed to fetch %s: %w", ErrInvalidImage, imageURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: fetching %s returned status %d", ErrInvalidImage, imageURL, resp.StatusCode)
+	}
+	if resp.ContentLength > p.policy.MaxBytes {
+		return "", p.tooLarge()
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, p.policy.MaxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to read %s: %v", ErrInvalidImage, imageURL, err)
+	}
+	if int64(len(data)) > p.policy.MaxBytes {
+		return "", p.tooLarge()
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mediaType, "image/") {
+		mediaType = http.DetectContentType(data)
+	}
+	if err := p.checkType(mediaType); err != nil {
+		return "", err
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+func (p *ImageProxy) checkType(mediaType string) error {
+	for _, allowed := range AllowedImageTypes {
+		if strings.EqualFold(mediaType, allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unsupported image type %q, expected one of %s", ErrInvalidImage, mediaType, strings.Join(AllowedImageTypes, ", "))
+}
+
+func (p *ImageProxy) tooLarge() error {
+	return fmt.Errorf("%w: image exceeds the limit of %d bytes", ErrInvalidImage, p.policy.MaxBytes)
+}
+
+func (p *ImageProxy) cached(imageURL string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.cache[imageURL]
+	if !ok || p.now().After(entry.expires) {
+		return "", false
+	}
+	return entry.dataURI, true
+}
+
+// store caches a fetched image, evicting the oldest entries beyond CacheSize
+func (p *ImageProxy) store(imageURL, dataURI string) {
+	if p.policy.CacheSize <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.cache[imageURL]; !ok {
+		p.order = append(p.order, imageURL)
+	}
+	p.cache[imageURL] = cachedImage{dataURI: dataURI, expires: p.now().Add(imageCacheTTL)}
+	for len(p.order) > p.policy.CacheSize {
+		delete(p.cache, p.order[0])
+		p.order = p.order[1:]
+	}
+}
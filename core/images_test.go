@@ -0,0 +1,78 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// pngHeader is enough of a PNG for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestImageProxy_DataURI(t *testing.T) {
+	proxy := NewImageProxy(ImagePolicy{MaxBytes: 16})
+
+	valid := "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngHeader)
+	if got, err := proxy.Resolve(context.Background(), valid); err != nil || got != valid {
+		t.Fatalf("valid data URI: got %q, %v", got, err)
+	}
+
+	for name, uri := range map[string]string{
+		"type":     "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte("<svg/>")),
+		"encoding": "data:image/png,raw",
+		"size":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(make([]byte, 64)),
+	} {
+		if _, err := proxy.Resolve(context.Background(), uri); !errors.Is(err, ErrInvalidImage) {
+			t.Errorf("%s: expected ErrInvalidImage, got %v", name, err)
+		}
+	}
+}
+
+func TestImageProxy_RemoteURL(t *testing.T) {
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(pngHeader)
+	}))
+	defer server.Close()
+
+	// 未开启抓取时只校验 scheme，原样透传
+	passthrough := NewImageProxy(DefaultImagePolicy())
+	if got, err := passthrough.Resolve(context.Background(), server.URL+"/cat.png"); err != nil || got != server.URL+"/cat.png" {
+		t.Fatalf("passthrough: got %q, %v", got, err)
+	}
+	if _, err := passthrough.Resolve(context.Background(), "file:///etc/passwd"); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("expected non-http scheme to be rejected, got %v", err)
+	}
+
+	policy := DefaultImagePolicy()
+	policy.Fetch = true
+
+	// 回环地址上的服务不可被网关访问（SSRF 防护）
+	if _, err := NewImageProxy(policy).Resolve(context.Background(), server.URL+"/cat.png"); !errors.Is(err, ErrInvalidImage) {
+		t.Fatalf("expected loopback fetch to be refused, got %v", err)
+	}
+	if fetches != 0 {
+		t.Fatalf("refused fetch should not reach the server")
+	}
+
+	proxy := NewImageProxy(policy)
+	proxy.client = newImageClient(true)
+	for i := 0; i < 2; i++ {
+		got, err := proxy.Resolve(context.Background(), server.URL+"/cat.png")
+		if err != nil {
+			t.Fatalf("fetch: %v", err)
+		}
+		if !strings.HasPrefix(got, "data:image/png;base64,") {
+			t.Fatalf("expected sniffed PNG data URI, got %q", got)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("expected the second resolve to hit the cache, got %d fetches", fetches)
+	}
+}
@@ -0,0 +1,250 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// InflightTracker counts in-flight requests per worker, per tenant and per model
+// In cluster mode the counts of the other gateway replicas are added to the lookups (see SetRemote)
+type InflightTracker struct {
+	mu      sync.RWMutex
+	workers map[string]int
+	tenants map[string]map[string]int // workerID -> tenant -> count
+	models  map[string]map[string]int // workerID -> model -> count
+	// admitted counts the requests of each tenant from admission to completion, including queued ones
+	admitted map[string]int
+	remote   InflightSnapshot
+}
+
+// InflightSnapshot is a copy of in-flight counts exchanged between gateway replicas
+// Tenants are keyed by TenantDigest so API keys never leave the gateway
+type InflightSnapshot struct {
+	Workers map[string]int            `json:"workers,omitempty"`
+	Tenants map[string]map[string]int `json:"tenants,omitempty"` // workerID -> tenant digest -> count
+	Models  map[string]map[string]int `json:"models,omitempty"`
+	// Admitted counts the admitted requests per tenant digest, see TryAcquireTenant
+	Admitted map[string]int `json:"admitted,omitempty"`
+}
+
+// TenantDigest is the form of a tenant (API key) shared with other gateway replicas
+func TenantDigest(tenant string) string {
+	sum := sha256.Sum256([]byte(tenant))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Add merges the counts of other into s
+func (s *InflightSnapshot) Add(other InflightSnapshot) {
+	for workerID, n := range other.Workers {
+		if s.Workers == nil {
+			s.Workers = make(map[string]int)
+		}
+		s.Workers[workerID] += n
+	}
+	s.Tenants = addNested(s.Tenants, other.Tenants)
+	s.Models = addNested(s.Models, other.Models)
+	for digest, n := range other.Admitted {
+		if s.Admitted == nil {
+			s.Admitted = make(map[string]int)
+		}
+		s.Admitted[digest] += n
+	}
+}
+
+func addNested(dst, src map[string]map[string]int) map[string]map[string]int {
+	for workerID, byKey := range src {
+		for key, n := range byKey {
+			if dst == nil {
+				dst = make(map[string]map[string]int)
+			}
+			if dst[workerID] == nil {
+				dst[workerID] = make(map[string]int)
+			}
+			dst[workerID][key] += n
+		}
+	}
+	return dst
+}
+
+// NewInflightTracker creates an empty InflightTracker
+func NewInflightTracker() *InflightTracker {
+	return &InflightTracker{
+		workers:  make(map[string]int),
+		tenants:  make(map[string]map[string]int),
+		models:   make(map[string]map[string]int),
+		admitted: make(map[string]int),
+	}
+}
+
+// TryAcquireTenant admits a request from tenant unless the tenant already has limit admitted requests,
+// counting those of the other gateway replicas. The check and the increment are atomic, so the limit is
+// strict within this replica; remote counts are only as fresh as the last cluster sync, so concurrent
+// replicas can together overshoot it by up to one sync interval of traffic
+// Admitted requests count until release, also while they wait in a queue
+// The returned release func must be called exactly once when the request finishes
+func (t *InflightTracker) TryAcquireTenant(tenant string, limit int) (release func(), ok bool) {
+	t.mu.Lock()
+	if t.admitted[tenant]+t.remote.Admitted[TenantDigest(tenant)] >= limit {
+		t.mu.Unlock()
+		return nil, false
+	}
+	t.admitted[tenant]++
+	t.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.admitted[tenant]--; t.admitted[tenant] <= 0 {
+				delete(t.admitted, tenant)
+			}
+		})
+	}, true
+}
+
+// Acquire records a request from tenant running on workerID
+// The returned release func must be called exactly once when the request finishes
+func (t *InflightTracker) Acquire(workerID, tenant string) (release func()) {
+	return t.AcquireModel(workerID, tenant, "")
+}
+
+// AcquireModel is Acquire that also counts the request against model's concurrency slots on workerID
+func (t *InflightTracker) AcquireModel(workerID, tenant, model string) (release func()) {
+	t.mu.Lock()
+	t.workers[workerID]++
+	incr(t.tenants, workerID, tenant)
+	if model != "" {
+		incr(t.models, workerID, model)
+	}
+	t.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			if t.workers[workerID]--; t.workers[workerID] <= 0 {
+				delete(t.workers, workerID)
+			}
+			decr(t.tenants, workerID, tenant)
+			if model != "" {
+				decr(t.models, workerID, model)
+			}
+		})
+	}
+}
+
+func incr(counts map[string]map[string]int, workerID, key string) {
+	byKey, ok := counts[workerID]
+	if !ok {
+		byKey = make(map[string]int)
+		counts[workerID] = byKey
+	}
+	byKey[key]++
+}
+
+func decr(counts map[string]map[string]int, workerID, key string) {
+	byKey := counts[workerID]
+	if byKey == nil {
+		return
+	}
+	if byKey[key]--; byKey[key] <= 0 {
+		delete(byKey, key)
+	}
+	if len(byKey) == 0 {
+		delete(counts, workerID)
+	}
+}
+
+// Count returns the number of in-flight requests from tenant on workerID
+func (t *InflightTracker) Count(workerID, tenant string) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	n := t.tenants[workerID][tenant]
+	if t.remote.Tenants[workerID] != nil {
+		n += t.remote.Tenants[workerID][TenantDigest(tenant)]
+	}
+	return n
+}
+
+// TenantCount returns the number of in-flight requests from tenant across all workers
+func (t *InflightTracker) TenantCount(tenant string) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	n := 0
+	for _, byTenant := range t.tenants {
+		n += byTenant[tenant]
+	}
+	if len(t.remote.Tenants) > 0 {
+		digest := TenantDigest(tenant)
+		for _, byTenant := range t.remote.Tenants {
+			n += byTenant[digest]
+		}
+	}
+	return n
+}
+
+// WorkerCount returns the number of in-flight requests on workerID
+func (t *InflightTracker) WorkerCount(workerID string) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.workers[workerID] + t.remote.Workers[workerID]
+}
+
+// ModelCount returns the number of in-flight requests for model on workerID
+func (t *InflightTracker) ModelCount(workerID, model string) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.models[workerID][model] + t.remote.Models[workerID][model]
+}
+
+// Snapshot returns the in-flight counts of this gateway alone, with tenants digested
+func (t *InflightTracker) Snapshot() InflightSnapshot {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	s := InflightSnapshot{Models: addNested(nil, t.models)}
+	for workerID, n := range t.workers {
+		if s.Workers == nil {
+			s.Workers = make(map[string]int)
+		}
+		s.Workers[workerID] = n
+	}
+	for workerID, byTenant := range t.tenants {
+		digested := make(map[string]int, len(byTenant))
+		for tenant, n := range byTenant {
+			digested[TenantDigest(tenant)] += n
+		}
+		s.Tenants = addNested(s.Tenants, map[string]map[string]int{workerID: digested})
+	}
+	for tenant, n := range t.admitted {
+		if s.Admitted == nil {
+			s.Admitted = make(map[string]int)
+		}
+		s.Admitted[TenantDigest(tenant)] += n
+	}
+	return s
+}
+
+// SetRemote replaces the summed in-flight counts of the other gateway replicas
+func (t *InflightTracker) SetRemote(remote InflightSnapshot) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remote = remote
+}
+
+// ModelTotals returns the number of in-flight requests per model across all workers
+// Only this gateway's requests are counted, so replicas report their own share of the demand
+func (t *InflightTracker) ModelTotals() map[string]int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	totals := make(map[string]int)
+	for _, byModel := range t.models {
+		for model, n := range byModel {
+			totals[model] += n
+		}
+	}
+	return totals
+}
@@ -1,31 +1,147 @@
 package core
 
-import "context"
+import (
+	"context"
+
+	"zam/openai"
+)
 
 // WorkerProfile represents a worker's current state and capabilities
 type WorkerProfile struct {
-	WorkerID      string
-	Supported     []string
-	TotalVRAM     uint64
-	AvailableVRAM uint64
-	ActiveTasks   int
-	MaxTasks      int // Maximum concurrent tasks this worker can handle
+	WorkerID      string   `json:"worker_id"`
+	Supported     []string `json:"supported"`
+	TotalVRAM     uint64   `json:"total_vram"`
+	AvailableVRAM uint64   `json:"available_vram"`
+	ActiveTasks   int      `json:"active_tasks"`
+	MaxTasks      int      `json:"max_tasks"` // Maximum concurrent tasks this worker can handle
+	// ModelSlots caps concurrent requests per model on top of MaxTasks, e.g. {"gemma-2b": 4, "llama-70b": 1};
+	// models without an entry are only bounded by MaxTasks
+	ModelSlots map[string]int `json:"model_slots,omitempty"`
+	// ActiveByModel is the worker's own count of running requests per model
+	ActiveByModel map[string]int `json:"active_by_model,omitempty"`
+	// Incarnation identifies the worker process instance (e.g. its start time); a restarted worker
+	// reports a higher value so the registry can tell it apart from late heartbeats of the old one
+	Incarnation uint64 `json:"incarnation,omitempty"`
+	// LoadedAdapters lists the LoRA adapters currently resident in VRAM
+	LoadedAdapters []string `json:"loaded_adapters,omitempty"`
+	// Class groups workers sharing operational policies, e.g. "gpu" or "cloud"
+	Class string `json:"class,omitempty"`
+	// Peer marks a remote gateway registered as a "super worker" (federation)
+	Peer bool `json:"peer,omitempty"`
+	// IsFallback marks a worker (typically a paid cloud API) used only when no local worker can take a request
+	IsFallback bool `json:"is_fallback,omitempty"`
+	// Priority orders fallback workers; the highest is used first (default 0)
+	Priority int `json:"priority,omitempty"`
+	// CostPer1KTokens is the price of 1,000 prompt or completion tokens on the worker, charged
+	// against the fallback budget when a fallback worker serves a request (0 = free)
+	CostPer1KTokens float64 `json:"cost_per_1k_tokens,omitempty"`
+	// Region and Zone locate the worker in the fleet topology, e.g. "home" / "lan-1"
+	Region string `json:"region,omitempty"`
+	Zone   string `json:"zone,omitempty"`
+	// Capabilities lists the optional features of the worker's backend
+	Capabilities Capabilities `json:"capabilities"`
+	// Endpoint is the URL of the worker's OpenAI-compatible chat completions endpoint,
+	// e.g. "http://10.0.0.5:8000/v1/chat/completions"; heartbeats carrying it make the worker schedulable
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Telemetry reported by the worker; zero values mean "not reported"
+	GPUUtilization  float64 `json:"gpu_utilization,omitempty"`   // percent, 0-100
+	TemperatureC    float64 `json:"temperature_c,omitempty"`     // GPU temperature in Celsius
+	KVCacheUsage    float64 `json:"kv_cache_usage,omitempty"`    // fraction of KV-cache blocks in use, 0-1
+	QueueLength     int     `json:"queue_length,omitempty"`      // requests waiting inside the worker
+	TokensPerSecond float64 `json:"tokens_per_second,omitempty"` // recent generation throughput
 }
 
 // StreamChunk represents a single chunk of streaming response
 type StreamChunk struct {
-	Content      string
+	// Index is the choice the chunk belongs to when several completions are generated (n > 1)
+	Index int
+	// Role is set on the first chunk of a choice by backends that send a role delta
+	Role    string
+	Content string
+	// Reasoning carries thinking deltas of reasoning models, kept apart from the answer
+	Reasoning string
+	// ToolCalls carries tool call fragments; arguments arrive in pieces keyed by ToolCallDelta.Index
+	ToolCalls    []ToolCallDelta
 	FinishReason string
 	Error        error
+	// Raw is the upstream SSE data payload, set on the passthrough path so the gateway can forward it verbatim;
+	// the other fields still carry the parsed deltas for token counting
+	Raw []byte
+}
+
+// ToolCallDelta is a fragment of a tool call in a stream
+// ID, Type and Name come with the first fragment of a call; Arguments are concatenated across fragments
+type ToolCallDelta struct {
+	Index     int
+	ID        string
+	Type      string
+	Name      string
+	Arguments string
 }
 
 // InferenceRequest represents an inference request
 type InferenceRequest struct {
-	TraceID     string
-	Model       string
-	Messages    interface{}
-	Temperature float32
-	Stream      bool
+	TraceID string
+	// Tenant identifies the customer issuing the request (used for anti-affinity spreading)
+	Tenant string
+	// Strategy names the router strategy placing the request (empty uses the router's default)
+	Strategy string
+	// Session is the affinity key of the conversation; requests sharing it prefer the worker that
+	// served its previous turns (empty disables session affinity)
+	Session string
+	// User is the client-supplied end-user ID (OpenAI "user"), carried for abuse attribution
+	User string
+	// RequestedModel is the model name exactly as sent by the client, echoed back in responses
+	RequestedModel string
+	// Model is the base model used for routing and execution
+	Model string
+	// Adapter is the LoRA adapter requested on top of Model (empty for plain base-model requests)
+	Adapter string
+	// LoadAdapter is set by the router when the selected worker does not have Adapter resident yet
+	LoadAdapter bool
+	// Messages is the conversation, validated at the handler boundary
+	Messages []openai.Message
+	// PromptTokens is the gateway's estimate of the prompt length, used for KV-cache headroom checks
+	PromptTokens int
+	Temperature  float32
+	// Tools and ToolChoice are forwarded as-is to OpenAI-compatible backends
+	Tools      interface{}
+	ToolChoice interface{}
+	// MaxTokens caps the completion length (0 = backend default)
+	MaxTokens int
+	Stream    bool
+	// Sampling parameters forwarded to backends; zero values mean "backend default"
+	TopP             float32
+	N                int
+	Stop             []string
+	FrequencyPenalty float32
+	PresencePenalty  float32
+	// ResponseFormat requests JSON mode or a JSON schema (nil = plain text)
+	ResponseFormat *openai.ResponseFormat
+	// Needs is the set of capabilities a worker must have to serve the request
+	Needs Capabilities
+	// Speculative is set by the router when the request is served by a draft/verify model pair
+	Speculative *SpeculativePlan
+	// Federated marks requests forwarded by a peer gateway; they must not be forwarded again
+	Federated bool
+	// Fallback is set by the router when no local worker could serve the request and it overflowed to the fallback
+	Fallback bool
+	// Priority ranks the request among its tenant's traffic; low-priority requests never spill over
+	Priority Priority
+	// WorkerClass restricts routing to workers of a class, e.g. for an experiment arm (empty allows every worker)
+	WorkerClass string
+	// Passthrough is set when the stream needs no gateway transformation; OpenAI-native workers may then
+	// attach the upstream bytes to each chunk (StreamChunk.Raw) instead of having them re-encoded
+	Passthrough bool
+}
+
+// SpeculativePlan describes how a speculative decoding pair was placed
+// The worker executing the request runs the target model (InferenceRequest.Model)
+type SpeculativePlan struct {
+	DraftModel string `json:"draft_model"`
+	// DraftWorkerID is the worker hosting the draft model; equal to the executing worker when co-located
+	DraftWorkerID string `json:"draft_worker_id"`
 }
 
 // Worker defines the interface for inference workers
@@ -40,6 +156,39 @@ type Router interface {
 	Select(ctx context.Context, workers []Worker, req *InferenceRequest) (Worker, error)
 }
 
+// RouteCandidate describes how a worker fared in a routing decision
+type RouteCandidate struct {
+	WorkerID string  `json:"worker_id"`
+	Score    float64 `json:"score"`
+	Fallback bool    `json:"fallback,omitempty"`
+	// Excluded is the reason the worker was filtered out (empty for eligible workers)
+	Excluded string `json:"excluded,omitempty"`
+}
+
+// RouteDecision explains a dry-run routing pass
+type RouteDecision struct {
+	Selected    string           `json:"selected,omitempty"`
+	Model       string           `json:"model"`
+	LoadAdapter bool             `json:"load_adapter,omitempty"`
+	Speculative *SpeculativePlan `json:"speculative,omitempty"`
+	// Candidates lists eligible workers ranked by score, then peers, the fallback and excluded workers
+	Candidates []RouteCandidate `json:"candidates"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// StrategyHeader names the router strategy of a request, e.g. to compare scheduling strategies on live traffic
+const StrategyHeader = "X-Zam-Router"
+
+// StrategyRouter is implemented by routers offering several named placement strategies
+type StrategyRouter interface {
+	HasStrategy(name string) bool
+}
+
+// RoutePreviewer is implemented by routers that can explain a decision without dispatching
+type RoutePreviewer interface {
+	Preview(ctx context.Context, workers []Worker, req *InferenceRequest) RouteDecision
+}
+
 type RateLimiter interface {
 	Allow(ctx context.Context, apiKey string) (bool, error)
 	Consume(ctx context.Context, apiKey string, actualTokens int) error
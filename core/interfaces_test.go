@@ -4,6 +4,8 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	"zam/openai"
 )
 
 type mockExecutor struct{}
@@ -34,7 +36,7 @@ func TestExecuteContextCancellation(t *testing.T) {
 	req := &InferenceRequest{
 		TraceID:  "test-001",
 		Model:    "test-model",
-		Messages: []string{"hello"},
+		Messages: []openai.Message{{Role: "user", Content: "hello"}},
 	}
 
 	ch := executor.Execute(ctx, req)
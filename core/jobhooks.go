@@ -0,0 +1,137 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// JobSignatureHeader carries the HMAC signature of a job webhook: "t=<unix seconds>,v1=<hex>"
+// v1 is HMAC-SHA256 over "<t>.<body>" with the shared secret; receivers should reject stale timestamps
+const JobSignatureHeader = "X-Zam-Signature"
+
+// Webhook delivery retries: attempts are spaced by exponential backoff from the base delay up to the cap
+const (
+	DefaultWebhookAttempts = 6
+	webhookBaseDelay       = time.Second
+	webhookMaxDelay        = 5 * time.Minute
+)
+
+// JobWebhooks POSTs finished jobs to their callback URL with signed payloads, retrying with backoff
+// Callbacks only reach public addresses, like image fetches
+type JobWebhooks struct {
+	ctx      context.Context
+	secret   []byte
+	client   *http.Client
+	attempts int
+	delay    func(attempt int) time.Duration
+	now      func() time.Time
+}
+
+// NewJobWebhooks creates a notifier whose deliveries stop when ctx is cancelled
+// An empty secret sends unsigned payloads
+func NewJobWebhooks(ctx context.Context, secret string) *JobWebhooks {
+	return &JobWebhooks{
+		ctx:    ctx,
+		secret: []byte(secret),
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: publicTransport(false),
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		attempts: DefaultWebhookAttempts,
+		delay:    webhookBackoff,
+		now:      time.Now,
+	}
+}
+
+// webhookBackoff doubles the delay after every failed attempt: 1s, 2s, 4s, ... capped at 5m
+func webhookBackoff(attempt int) time.Duration {
+	d := webhookBaseDelay << attempt
+	if d <= 0 || d > webhookMaxDelay {
+		return webhookMaxDelay
+	}
+	return d
+}
+
+// ValidateCallbackURL checks that a callback URL is an absolute http(s) URL
+func ValidateCallbackURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("callback_url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// Notify delivers a finished job in the background; jobs without a callback URL are ignored
+func (w *JobWebhooks) Notify(job Job) {
+	if job.CallbackURL == "" {
+		return
+	}
+	body, err := json.Marshal(job)
+	if err != nil {
+		slog.Error("failed to encode job webhook", "job_id", job.ID, "error", err)
+		return
+	}
+	go w.deliver(job, body)
+}
+
+func (w *JobWebhooks) deliver(job Job, body []byte) {
+	for attempt := 0; attempt < w.attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-time.After(w.delay(attempt - 1)):
+			}
+		}
+		err := w.post(job, body)
+		if err == nil {
+			return
+		}
+		slog.Warn("job webhook failed", "job_id", job.ID, "attempt", attempt+1, "max_attempts", w.attempts, "error", err)
+	}
+	slog.Error("giving up on job webhook", "job_id", job.ID)
+}
+
+func (w *JobWebhooks) post(job Job, body []byte) error {
+	req, err := http.NewRequestWithContext(w.ctx, http.MethodPost, job.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Zam-Job-Id", job.ID)
+	if len(w.secret) > 0 {
+		req.Header.Set(JobSignatureHeader, SignJobWebhook(w.secret, w.now(), body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignJobWebhook returns the JobSignatureHeader value for body sent at t
+func SignJobWebhook(secret []byte, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJobWebhooks_SignedDeliveryWithRetry(t *testing.T) {
+	var attempts atomic.Int32
+	delivered := make(chan string, 1)
+	now := time.Unix(1790000000, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if want := SignJobWebhook([]byte("s3cret"), now, body); r.Header.Get(JobSignatureHeader) != want {
+			t.Errorf("signature = %q, want %q", r.Header.Get(JobSignatureHeader), want)
+		}
+		delivered <- r.Header.Get("X-Zam-Job-Id")
+	}))
+	defer server.Close()
+
+	hooks := NewJobWebhooks(context.Background(), "s3cret")
+	hooks.client.Transport = publicTransport(true)
+	hooks.delay = func(int) time.Duration { return time.Millisecond }
+	hooks.now = func() time.Time { return now }
+
+	hooks.Notify(Job{ID: "job-1", Status: JobSucceeded, CallbackURL: server.URL})
+	select {
+	case id := <-delivered:
+		if id != "job-1" || attempts.Load() != 2 {
+			t.Errorf("delivered %q after %d attempts, want job-1 after 2", id, attempts.Load())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+}
+
+func TestJobWebhooks_RefusesPrivateAddresses(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+
+	hooks := NewJobWebhooks(context.Background(), "")
+	if err := hooks.post(Job{ID: "job-1", CallbackURL: server.URL}, []byte(`{}`)); err == nil || hits.Load() != 0 {
+		t.Fatalf("expected loopback callback to be refused, err=%v hits=%d", err, hits.Load())
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	if webhookBackoff(0) != time.Second || webhookBackoff(3) != 8*time.Second || webhookBackoff(20) != webhookMaxDelay {
+		t.Errorf("unexpected backoff: %v %v %v", webhookBackoff(0), webhookBackoff(3), webhookBackoff(20))
+	}
+}
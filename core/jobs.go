@@ -0,0 +1,250 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobStatus is the lifecycle state of an async completion job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// DefaultMaxQueuedJobs bounds the number of jobs waiting for capacity
+const DefaultMaxQueuedJobs = 1000
+
+// DefaultJobRetention is how long finished jobs can still be fetched
+const DefaultJobRetention = 24 * time.Hour
+
+// ErrJobQueueFull is returned by Submit when too many jobs are waiting
+var ErrJobQueueFull = errors.New("job queue is full")
+
+// Job is an async chat completion executed when the cluster has spare capacity
+type Job struct {
+	ID          string     `json:"id"`
+	Object      string     `json:"object"`
+	Status      JobStatus  `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	// Result is the chat completion response of a succeeded job
+	Result json.RawMessage `json:"result,omitempty"`
+	// Error is the OpenAI error object of a failed job
+	Error json.RawMessage `json:"error,omitempty"`
+	// CallbackURL receives the finished job when set
+	CallbackURL string `json:"callback_url,omitempty"`
+	// Priority orders queued jobs; jobs of equal priority run in submission order
+	Priority Priority `json:"priority"`
+
+	// Key is the API key that submitted the job; only it may read the job and it is billed for it
+	Key string `json:"-"`
+	// Request is the chat completion request body
+	Request json.RawMessage `json:"-"`
+	// model is the requested model, kept for backlog reporting
+	model string
+}
+
+// JobExecutor runs a job's request and returns the HTTP status and body of the response
+type JobExecutor func(ctx context.Context, job Job) (int, []byte)
+
+// JobQueue holds async jobs in memory and runs them one at a time while the cluster is idle,
+// so they only use capacity interactive traffic leaves unused
+type JobQueue struct {
+	maxQueued int
+	retention time.Duration
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	pending []string
+	wake    chan struct{}
+	notify  func(Job)
+	now     func() time.Time
+}
+
+// NewJobQueue creates a JobQueue; non-positive arguments select the defaults
+func NewJobQueue(maxQueued int, retention time.Duration) *JobQueue {
+	if maxQueued <= 0 {
+		maxQueued = DefaultMaxQueuedJobs
+	}
+	if retention <= 0 {
+		retention = DefaultJobRetention
+	}
+	return &JobQueue{
+		maxQueued: maxQueued,
+		retention: retention,
+		jobs:      make(map[string]*Job),
+		wake:      make(chan struct{}, 1),
+		now:       time.Now,
+	}
+}
+
+// SetNotifier registers a function called with every finished job, e.g. JobWebhooks.Notify
+func (q *JobQueue) SetNotifier(notify func(Job)) {
+	q.notify = notify
+}
+
+// Submit queues a request for apiKey and returns the new job; callbackURL may be empty
+func (q *JobQueue) Submit(apiKey string, request json.RawMessage, callbackURL string, priority Priority) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) >= q.maxQueued {
+		return Job{}, ErrJobQueueFull
+	}
+	job := &Job{
+		ID:          "job-" + uuid.New().String(),
+		Object:      "chat.completion.job",
+		Status:      JobQueued,
+		CreatedAt:   q.now(),
+		Key:         apiKey,
+		Request:     request,
+		CallbackURL: callbackURL,
+		Priority:    priority,
+	}
+	var body struct {
+		Model string `json:"model"`
+	}
+	if json.Unmarshal(request, &body) == nil {
+		job.model = body.Model
+	}
+	q.jobs[job.ID] = job
+	// 插入到同优先级任务之后、低优先级任务之前
+	i := len(q.pending)
+	for i > 0 && q.jobs[q.pending[i-1]].Priority < priority {
+		i--
+	}
+	q.pending = append(q.pending, "")
+	copy(q.pending[i+1:], q.pending[i:])
+	q.pending[i] = job.ID
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return *job, nil
+}
+
+// JobBacklog summarizes the jobs waiting for capacity
+type JobBacklog struct {
+	Queued int
+	// OldestWait is how long the oldest queued job has been waiting
+	OldestWait time.Duration
+	// ByModel counts queued jobs per requested model
+	ByModel map[string]int
+}
+
+// Backlog returns the queued jobs, e.g. as an autoscaling signal
+func (q *JobQueue) Backlog() JobBacklog {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	backlog := JobBacklog{Queued: len(q.pending), ByModel: make(map[string]int)}
+	now := q.now()
+	for _, id := range q.pending {
+		job := q.jobs[id]
+		if wait := now.Sub(job.CreatedAt); wait > backlog.OldestWait {
+			backlog.OldestWait = wait
+		}
+		if job.model != "" {
+			backlog.ByModel[job.model]++
+		}
+	}
+	return backlog
+}
+
+// Get returns a job of apiKey; jobs of other keys are reported as missing
+func (q *JobQueue) Get(apiKey, id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok || job.Key != apiKey {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Run executes queued jobs until ctx is cancelled; a job starts only when idle reports spare
+// capacity, re-checked every poll interval
+func (q *JobQueue) Run(ctx context.Context, exec JobExecutor, idle func() bool, poll time.Duration) {
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		for idle() {
+			job, ok := q.next()
+			if !ok {
+				break
+			}
+			status, body := exec(ctx, job)
+			finished := q.finish(job.ID, status, body)
+			if q.notify != nil {
+				q.notify(finished)
+			}
+		}
+		q.expire()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// next pops the oldest queued job of the highest priority and marks it running
+func (q *JobQueue) next() (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		return Job{}, false
+	}
+	job := q.jobs[q.pending[0]]
+	q.pending = q.pending[1:]
+	now := q.now()
+	job.Status, job.StartedAt = JobRunning, &now
+	return *job, true
+}
+
+// finish records the response of a job: 200 bodies become the result, anything else the error
+// It returns the finished job
+func (q *JobQueue) finish(id string, status int, body []byte) Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job := q.jobs[id]
+	now := q.now()
+	job.CompletedAt = &now
+	if status == http.StatusOK {
+		job.Status, job.Result = JobSucceeded, body
+		return *job
+	}
+	job.Status = JobFailed
+	var envelope struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && len(envelope.Error) > 0 {
+		job.Error = envelope.Error
+	} else {
+		job.Error, _ = json.Marshal(map[string]interface{}{"message": string(body), "type": "server_error", "param": nil, "code": nil})
+	}
+	return *job
+}
+
+// expire drops finished jobs older than the retention
+func (q *JobQueue) expire() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	cutoff := q.now().Add(-q.retention)
+	for id, job := range q.jobs {
+		if job.CompletedAt != nil && job.CompletedAt.Before(cutoff) {
+			delete(q.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJobQueue_RunsWhenIdle(t *testing.T) {
+	queue := NewJobQueue(2, time.Hour)
+	ok, _ := queue.Submit("key-a", json.RawMessage(`{"model":"m"}`), "", PriorityNormal)
+	failing, _ := queue.Submit("key-a", json.RawMessage(`{"model":"bad"}`), "", PriorityNormal)
+	if _, err := queue.Submit("key-a", nil, "", PriorityNormal); !errors.Is(err, ErrJobQueueFull) {
+		t.Fatalf("expected ErrJobQueueFull, got %v", err)
+	}
+
+	var idle atomic.Bool
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx, func(ctx context.Context, job Job) (int, []byte) {
+		if string(job.Request) == `{"model":"bad"}` {
+			return http.StatusBadRequest, []byte(`{"error":{"message":"bad model","type":"invalid_request_error"}}`)
+		}
+		return http.StatusOK, []byte(`{"object":"chat.completion"}`)
+	}, idle.Load, 5*time.Millisecond)
+
+	// 集群繁忙时不执行
+	time.Sleep(20 * time.Millisecond)
+	if job, _ := queue.Get("key-a", ok.ID); job.Status != JobQueued {
+		t.Fatalf("job should wait while the cluster is busy, status %s", job.Status)
+	}
+
+	idle.Store(true)
+	deadline := time.Now().Add(time.Second)
+	for {
+		job, _ := queue.Get("key-a", failing.ID)
+		if job.Status == JobFailed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs did not finish, status %s", job.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	job, _ := queue.Get("key-a", ok.ID)
+	if job.Status != JobSucceeded || string(job.Result) != `{"object":"chat.completion"}` || job.StartedAt == nil || job.CompletedAt == nil {
+		t.Errorf("unexpected succeeded job: %+v", job)
+	}
+	job, _ = queue.Get("key-a", failing.ID)
+	if string(job.Error) != `{"message":"bad model","type":"invalid_request_error"}` {
+		t.Errorf("expected the error object of the response, got %s", job.Error)
+	}
+	if _, found := queue.Get("key-b", ok.ID); found {
+		t.Error("jobs must not be visible to other keys")
+	}
+}
+
+func TestJobQueue_Expire(t *testing.T) {
+	queue := NewJobQueue(0, time.Hour)
+	now := time.Now()
+	queue.now = func() time.Time { return now }
+	job, _ := queue.Submit("key-a", nil, "", PriorityNormal)
+	queue.next()
+	queue.finish(job.ID, http.StatusOK, []byte(`{}`))
+
+	now = now.Add(2 * time.Hour)
+	queue.expire()
+	if _, found := queue.Get("key-a", job.ID); found {
+		t.Error("finished job should expire after the retention")
+	}
+}
+
+func TestJobQueue_PriorityOrder(t *testing.T) {
+	queue := NewJobQueue(0, time.Hour)
+	low, _ := queue.Submit("key-a", nil, "", PriorityLow)
+	normal, _ := queue.Submit("key-a", nil, "", PriorityNormal)
+	high, _ := queue.Submit("key-a", nil, "", PriorityHigh)
+	normal2, _ := queue.Submit("key-a", nil, "", PriorityNormal)
+
+	for _, want := range []Job{high, normal, normal2, low} {
+		job, _ := queue.next()
+		if job.ID != want.ID {
+			t.Fatalf("next = %s (%s), want %s (%s)", job.ID, job.Priority, want.ID, want.Priority)
+		}
+	}
+}
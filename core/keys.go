@@ -0,0 +1,99 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// KeyInfo describes who owns an API key
+type KeyInfo struct {
+	Key string `json:"-"`
+	// Org is the organization billed for the key's usage (empty for standalone keys)
+	Org string `json:"org,omitempty"`
+	// Plan names the pricing plan the key is on, e.g. "free" or "pro"
+	Plan string `json:"plan,omitempty"`
+}
+
+// KeyDirectory maps API keys to their owners
+type KeyDirectory struct {
+	mu   sync.RWMutex
+	keys map[string]KeyInfo
+}
+
+// NewKeyDirectory creates an empty KeyDirectory
+func NewKeyDirectory() *KeyDirectory {
+	return &KeyDirectory{
+		keys: make(map[string]KeyInfo),
+	}
+}
+
+// Put adds or replaces a key
+func (d *KeyDirectory) Put(info KeyInfo) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.keys[info.Key] = info
+}
+
+// Lookup returns the owner of a key; unknown keys get a KeyInfo with only Key set
+func (d *KeyDirectory) Lookup(apiKey string) KeyInfo {
+	if d == nil {
+		return KeyInfo{Key: apiKey}
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if info, ok := d.keys[apiKey]; ok {
+		return info
+	}
+	return KeyInfo{Key: apiKey}
+}
+
+// Keys returns every known key of an organization
+func (d *KeyDirectory) Keys(org string) []KeyInfo {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var keys []KeyInfo
+	for _, info := range d.keys {
+		if info.Org == org {
+			keys = append(keys, info)
+		}
+	}
+	return keys
+}
+
+// Plan returns every known key on a plan
+func (d *KeyDirectory) Plan(plan string) []KeyInfo {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var keys []KeyInfo
+	for _, info := range d.keys {
+		if info.Plan == plan {
+			keys = append(keys, info)
+		}
+	}
+	return keys
+}
+
+// ParseKeyDirectory parses "key=org[/plan];..." into a KeyDirectory, e.g. "test-key-123=acme/free"
+func ParseKeyDirectory(spec string) (*KeyDirectory, error) {
+	dir := NewKeyDirectory()
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, owner, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid key entry %q: expected key=org[/plan]", entry)
+		}
+		org, plan, _ := strings.Cut(owner, "/")
+		dir.Put(KeyInfo{
+			Key:  key,
+			Org:  strings.TrimSpace(org),
+			Plan: strings.TrimSpace(plan),
+		})
+	}
+	return dir, nil
+}
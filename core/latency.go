@@ -0,0 +1,119 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyAlpha is the weight of the newest sample in the latency moving averages
+const DefaultLatencyAlpha = 0.2
+
+// WorkerLatency holds the exponentially weighted moving averages of a worker's latency
+type WorkerLatency struct {
+	// TTFT is the time from dispatch to the first token
+	TTFT time.Duration `json:"ttft"`
+	// Completion is the time from dispatch to the end of the response
+	Completion time.Duration `json:"completion"`
+	// Samples is the number of requests observed
+	Samples int `json:"samples"`
+}
+
+// LatencyTracker keeps an EWMA of the time-to-first-token and completion latency of each worker,
+// so routing can steer traffic away from GPUs that slow down, e.g. when thermally throttled
+type LatencyTracker struct {
+	alpha float64
+
+	mu      sync.RWMutex
+	workers map[string]WorkerLatency
+}
+
+// NewLatencyTracker creates a tracker whose averages give the newest sample the weight alpha (0 < alpha <= 1)
+func NewLatencyTracker(alpha float64) (*LatencyTracker, error) {
+	if !(alpha > 0 && alpha <= 1) {
+		return nil, fmt.Errorf("latency alpha must be in (0, 1]")
+	}
+	return &LatencyTracker{alpha: alpha, workers: make(map[string]WorkerLatency)}, nil
+}
+
+// Observe folds the latency of one successful request into the worker's averages
+// A nil tracker ignores the call
+func (t *LatencyTracker) Observe(workerID string, ttft, completion time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l, ok := t.workers[workerID]
+	if !ok {
+		// 首个样本直接作为初值，避免从 0 缓慢爬升
+		t.workers[workerID] = WorkerLatency{TTFT: ttft, Completion: completion, Samples: 1}
+		return
+	}
+	l.TTFT = ewma(l.TTFT, ttft, t.alpha)
+	l.Completion = ewma(l.Completion, completion, t.alpha)
+	l.Samples++
+	t.workers[workerID] = l
+}
+
+func ewma(avg, sample time.Duration, alpha float64) time.Duration {
+	return time.Duration(alpha*float64(sample) + (1-alpha)*float64(avg))
+}
+
+// Latency returns the averages of a worker, false before its first observed request
+func (t *LatencyTracker) Latency(workerID string) (WorkerLatency, bool) {
+	if t == nil {
+		return WorkerLatency{}, false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	l, ok := t.workers[workerID]
+	return l, ok
+}
+
+// Snapshot returns the averages of every observed worker
+func (t *LatencyTracker) Snapshot() map[string]WorkerLatency {
+	if t == nil {
+		return nil
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	snapshot := make(map[string]WorkerLatency, len(t.workers))
+	for id, l := range t.workers {
+		snapshot[id] = l
+	}
+	return snapshot
+}
+
+// Instrument wraps a worker so the latency of its Execute calls is observed
+// A nil tracker returns the worker unchanged
+func (t *LatencyTracker) Instrument(w Worker) Worker {
+	if t == nil {
+		return w
+	}
+	return &latencyWorker{Worker: w, tracker: t}
+}
+
+// latencyWorker measures Execute calls for a LatencyTracker
+type latencyWorker struct {
+	Worker
+	tracker *LatencyTracker
+}
+
+// Execute forwards to the wrapped worker; the first chunk with content marks the first token
+func (w *latencyWorker) Execute(ctx context.Context, req *InferenceRequest, sender func(chunk StreamChunk) error) error {
+	start := time.Now()
+	var first time.Time
+	err := w.Worker.Execute(ctx, req, func(chunk StreamChunk) error {
+		if first.IsZero() && (chunk.Content != "" || chunk.Reasoning != "" || len(chunk.ToolCalls) > 0) {
+			first = time.Now()
+		}
+		return sender(chunk)
+	})
+	// 只统计成功且有输出的请求，失败由隔离与熔断处理
+	if err == nil && !first.IsZero() {
+		w.tracker.Observe(w.Worker.ID(), first.Sub(start), time.Since(start))
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyTracker(t *testing.T) {
+	if _, err := NewLatencyTracker(0); err == nil {
+		t.Error("expected alpha 0 to be rejected")
+	}
+	tracker, err := NewLatencyTracker(0.5)
+	if err != nil {
+		t.Fatalf("NewLatencyTracker: %v", err)
+	}
+	if _, ok := tracker.Latency("gpu-1"); ok {
+		t.Error("expected no latency before the first request")
+	}
+
+	// 首个样本作为初值，之后按 alpha 加权
+	tracker.Observe("gpu-1", 100*time.Millisecond, time.Second)
+	tracker.Observe("gpu-1", 300*time.Millisecond, 3*time.Second)
+	l, ok := tracker.Latency("gpu-1")
+	if !ok || l.TTFT != 200*time.Millisecond || l.Completion != 2*time.Second || l.Samples != 2 {
+		t.Errorf("Latency() = %+v, %v; want 200ms / 2s over 2 samples", l, ok)
+	}
+	if len(tracker.Snapshot()) != 1 {
+		t.Errorf("Snapshot() = %v", tracker.Snapshot())
+	}
+
+	var none *LatencyTracker
+	none.Observe("gpu-1", time.Second, time.Second)
+	if _, ok := none.Latency("gpu-1"); ok {
+		t.Error("expected a nil tracker to report nothing")
+	}
+}
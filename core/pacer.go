@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxPacedBytes flushes a coalesced chunk early once it holds this much text
+const maxPacedBytes = 4096
+
+// PacedWorker coalesces the text deltas of a stream so they are flushed at most once per interval,
+// which smooths rendering in UIs and cuts write/flush syscalls on backends sending sub-token fragments
+// Role, tool call, finish, error and passthrough chunks are never delayed; buffered text is flushed ahead of them
+type PacedWorker struct {
+	Worker
+	interval time.Duration
+}
+
+// NewPacedWorker wraps w with a pacer flushing at most once per interval
+func NewPacedWorker(w Worker, interval time.Duration) *PacedWorker {
+	return &PacedWorker{Worker: w, interval: interval}
+}
+
+// Execute forwards to the wrapped worker through the pacer; buffered text is flushed before returning
+func (w *PacedWorker) Execute(ctx context.Context, req *InferenceRequest, sender func(chunk StreamChunk) error) error {
+	p := &pacer{send: sender, interval: w.interval}
+	err := w.Worker.Execute(ctx, req, p.add)
+	if flushErr := p.close(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// pacer buffers text deltas; sends happen under mu, from the worker or from the flush timer
+type pacer struct {
+	send     func(chunk StreamChunk) error
+	interval time.Duration
+
+	mu       sync.Mutex
+	pending  StreamChunk
+	buffered bool
+	last     time.Time
+	timer    *time.Timer
+	err      error
+}
+
+func (p *pacer) add(chunk StreamChunk) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+
+	// 直通分片携带原始字节，不能合并
+	textOnly := chunk.Error == nil && chunk.Role == "" && len(chunk.ToolCalls) == 0 && chunk.FinishReason == "" && chunk.Raw == nil
+	if !textOnly || (p.buffered && p.pending.Index != chunk.Index) {
+		p.flushLocked()
+	}
+	if !textOnly {
+		if p.err == nil {
+			p.err = p.send(chunk)
+			p.last = time.Now()
+		}
+		return p.err
+	}
+
+	p.pending.Index = chunk.Index
+	p.pending.Content += chunk.Content
+	p.pending.Reasoning += chunk.Reasoning
+	p.buffered = true
+	wait := p.interval - time.Since(p.last)
+	if wait <= 0 || len(p.pending.Content)+len(p.pending.Reasoning) >= maxPacedBytes {
+		p.flushLocked()
+		return p.err
+	}
+	// 后端停顿时由定时器把已缓冲的内容刷出
+	if p.timer == nil {
+		p.timer = time.AfterFunc(wait, p.tick)
+	}
+	return nil
+}
+
+func (p *pacer) tick() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.timer = nil
+	p.flushLocked()
+}
+
+func (p *pacer) flushLocked() {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	if !p.buffered || p.err != nil {
+		return
+	}
+	chunk := p.pending
+	p.pending, p.buffered = StreamChunk{}, false
+	p.err = p.send(chunk)
+	p.last = time.Now()
+}
+
+// close flushes the remaining text; the timer is stopped so nothing is sent afterwards
+func (p *pacer) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flushLocked()
+	return p.err
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pacerTestWorker sends its chunks back to back, sleeping pause wherever a nil-content marker sits
+type pacerTestWorker struct {
+	chunks []*StreamChunk
+	pause  time.Duration
+}
+
+func (w *pacerTestWorker) ID() string { return "w" }
+
+func (w *pacerTestWorker) Heartbeat(ctx context.Context) (WorkerProfile, error) {
+	return WorkerProfile{}, nil
+}
+
+func (w *pacerTestWorker) Execute(ctx context.Context, req *InferenceRequest, sender func(chunk StreamChunk) error) error {
+	for _, chunk := range w.chunks {
+		if chunk == nil {
+			time.Sleep(w.pause)
+			continue
+		}
+		if err := sender(*chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type pacerRecorder struct {
+	mu     sync.Mutex
+	chunks []StreamChunk
+}
+
+func (r *pacerRecorder) send(chunk StreamChunk) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.chunks = append(r.chunks, chunk)
+	return nil
+}
+
+func TestPacedWorker_CoalescesFragments(t *testing.T) {
+	w := &pacerTestWorker{chunks: []*StreamChunk{
+		{Role: "assistant"}, {Content: "He"}, {Content: "llo"}, {Content: " wor"}, {Content: "ld"},
+		{FinishReason: "stop"},
+	}}
+	rec := &pacerRecorder{}
+	if err := NewPacedWorker(w, time.Hour).Execute(context.Background(), &InferenceRequest{}, rec.send); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	// 角色分片立即发出，文本在结束分片之前合并为一个
+	want := []StreamChunk{{Role: "assistant"}, {Content: "Hello world"}, {FinishReason: "stop"}}
+	if len(rec.chunks) != len(want) {
+		t.Fatalf("chunks = %+v, want %+v", rec.chunks, want)
+	}
+	for i := range want {
+		if rec.chunks[i].Role != want[i].Role || rec.chunks[i].Content != want[i].Content || rec.chunks[i].FinishReason != want[i].FinishReason {
+			t.Errorf("chunk %d = %+v, want %+v", i, rec.chunks[i], want[i])
+		}
+	}
+}
+
+func TestPacedWorker_FlushesDuringStall(t *testing.T) {
+	w := &pacerTestWorker{pause: 200 * time.Millisecond, chunks: []*StreamChunk{
+		{Content: "a"}, {Content: "b"}, nil, {Content: "c"},
+	}}
+	rec := &pacerRecorder{}
+	if err := NewPacedWorker(w, 20*time.Millisecond).Execute(context.Background(), &InferenceRequest{}, rec.send); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	// "b" 由定时器在后端停顿期间刷出，而不是等到 "c" 到达
+	var got []string
+	for _, chunk := range rec.chunks {
+		got = append(got, chunk.Content)
+	}
+	if len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Errorf("contents = %q, want [a b c]", got)
+	}
+}
+
+func TestPacedWorker_SeparatesChoices(t *testing.T) {
+	w := &pacerTestWorker{chunks: []*StreamChunk{
+		{Content: "x"}, {Index: 1, Content: "y"}, {Index: 1, Content: "z"}, {Content: "w"},
+	}}
+	rec := &pacerRecorder{}
+	if err := NewPacedWorker(w, time.Hour).Execute(context.Background(), &InferenceRequest{}, rec.send); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	want := []StreamChunk{{Content: "x"}, {Index: 1, Content: "yz"}, {Content: "w"}}
+	if len(rec.chunks) != len(want) {
+		t.Fatalf("chunks = %+v, want %+v", rec.chunks, want)
+	}
+	for i := range want {
+		if rec.chunks[i].Index != want[i].Index || rec.chunks[i].Content != want[i].Content {
+			t.Errorf("chunk %d = %+v, want %+v", i, rec.chunks[i], want[i])
+		}
+	}
+}
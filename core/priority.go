@@ -0,0 +1,175 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PriorityHeader lets a client rank its own requests, e.g. interactive traffic above batch traffic
+const PriorityHeader = "X-Priority"
+
+// Priority orders requests of a tenant in queues and decides whether they may spill over
+// to peer gateways and fallback workers
+type Priority int
+
+// The zero value is PriorityNormal, so internally built requests keep the default behavior
+const (
+	// PriorityLow is batch traffic: shed first under brownout and never spilled over
+	PriorityLow Priority = -1
+	// PriorityNormal is the default of requests without the header
+	PriorityNormal Priority = 0
+	// PriorityHigh is interactive traffic, served ahead of lower priorities
+	PriorityHigh Priority = 1
+)
+
+var priorityNames = map[Priority]string{PriorityLow: "low", PriorityNormal: "normal", PriorityHigh: "high"}
+
+func (p Priority) String() string {
+	if name, ok := priorityNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("priority(%d)", int(p))
+}
+
+// MarshalText encodes the priority by name
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText decodes a priority name
+func (p *Priority) UnmarshalText(text []byte) error {
+	parsed, err := ParsePriority(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// ParsePriority parses "low", "normal" or "high" (case-insensitive)
+func ParsePriority(s string) (Priority, error) {
+	for _, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+		if strings.EqualFold(strings.TrimSpace(s), priorityNames[p]) {
+			return p, nil
+		}
+	}
+	return PriorityNormal, fmt.Errorf("invalid priority %q: expected low, normal or high", s)
+}
+
+// PriorityPolicy caps the priority each key may request and assigns each key a priority class,
+// the priority of its requests without a PriorityHeader
+// Keys without a rule may request up to PriorityNormal, so raising priority must be granted explicitly
+type PriorityPolicy struct {
+	keys    *KeyDirectory
+	rules   map[string]Priority
+	classes map[string]Priority
+}
+
+// ParsePriorityPolicy parses "scope:id=priority;..." with scope key, org or plan,
+// e.g. "plan:pro=high;key:test-key-123=high"; key rules win over org rules, which win over plan rules
+func ParsePriorityPolicy(spec string, keys *KeyDirectory) (*PriorityPolicy, error) {
+	rules, err := parsePriorityRules(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &PriorityPolicy{keys: keys, rules: rules}, nil
+}
+
+// SetClasses parses the priority classes of keys in the format of ParsePriorityPolicy,
+// e.g. "plan:enterprise=high;plan:free=low". A key may always request its class,
+// even above the maximum of its priority rules
+func (p *PriorityPolicy) SetClasses(spec string) error {
+	classes, err := parsePriorityRules(spec)
+	if err != nil {
+		return err
+	}
+	p.classes = classes
+	return nil
+}
+
+func parsePriorityRules(spec string) (map[string]Priority, error) {
+	rules := make(map[string]Priority)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		target, value, ok := strings.Cut(entry, "=")
+		scope, id, ok2 := strings.Cut(strings.TrimSpace(target), ":")
+		if !ok || !ok2 || (scope != "key" && scope != "org" && scope != "plan") || id == "" {
+			return nil, fmt.Errorf("invalid priority rule %q: expected key:<api-key>, org:<org> or plan:<plan>=priority", entry)
+		}
+		p, err := ParsePriority(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority rule %q: %w", entry, err)
+		}
+		rules[scope+":"+id] = p
+	}
+	return rules, nil
+}
+
+// lookup returns the most specific rule matching apiKey: key, then org, then plan
+func (p *PriorityPolicy) lookup(rules map[string]Priority, apiKey string) (Priority, bool) {
+	if priority, ok := rules["key:"+apiKey]; ok {
+		return priority, true
+	}
+	info := p.keys.Lookup(apiKey)
+	if priority, ok := rules["org:"+info.Org]; ok && info.Org != "" {
+		return priority, true
+	}
+	if priority, ok := rules["plan:"+info.Plan]; ok && info.Plan != "" {
+		return priority, true
+	}
+	return PriorityNormal, false
+}
+
+// Max returns the highest priority apiKey may request; a nil policy allows PriorityNormal
+func (p *PriorityPolicy) Max(apiKey string) Priority {
+	if p == nil {
+		return PriorityNormal
+	}
+	max, _ := p.lookup(p.rules, apiKey)
+	if class, ok := p.lookup(p.classes, apiKey); ok && class > max {
+		return class
+	}
+	return max
+}
+
+// Class returns the priority class of apiKey; keys without a class are PriorityNormal
+func (p *PriorityPolicy) Class(apiKey string) Priority {
+	if p == nil {
+		return PriorityNormal
+	}
+	class, _ := p.lookup(p.classes, apiKey)
+	return class
+}
+
+// Resolve validates a PriorityHeader value for apiKey; an empty header selects def, capped at the key's maximum
+func (p *PriorityPolicy) Resolve(apiKey, header string, def Priority) (Priority, error) {
+	max := p.Max(apiKey)
+	if header == "" {
+		if def > max {
+			return max, nil
+		}
+		return def, nil
+	}
+	priority, err := ParsePriority(header)
+	if err != nil {
+		return def, err
+	}
+	if priority > max {
+		return def, &PriorityNotAllowedError{Requested: priority, Max: max}
+	}
+	return priority, nil
+}
+
+// PriorityNotAllowedError is returned when a key requests a priority above its maximum
+type PriorityNotAllowedError struct {
+	Requested Priority
+	Max       Priority
+}
+
+func (e *PriorityNotAllowedError) Error() string {
+	return fmt.Sprintf("priority %s is not allowed for this API key (maximum %s)", e.Requested, e.Max)
+}
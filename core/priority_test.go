@@ -0,0 +1,70 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPriorityPolicy(t *testing.T) {
+	keys, _ := ParseKeyDirectory("sk-pro=acme/pro;sk-free=/free;sk-vip=/free")
+	policy, err := ParsePriorityPolicy("plan:pro=high;key:sk-vip=high;plan:free=low", keys)
+	if err != nil {
+		t.Fatalf("ParsePriorityPolicy: %v", err)
+	}
+
+	cases := []struct {
+		key, header string
+		want        Priority
+		notAllowed  bool
+	}{
+		{"sk-pro", "high", PriorityHigh, false},
+		{"sk-pro", "", PriorityNormal, false},
+		{"sk-vip", "HIGH", PriorityHigh, false},
+		// 计划上限为 low 时，默认优先级也被压到 low
+		{"sk-free", "", PriorityLow, false},
+		{"sk-free", "normal", PriorityNormal, true},
+		{"sk-unknown", "low", PriorityLow, false},
+		{"sk-unknown", "high", PriorityNormal, true},
+	}
+	for _, tc := range cases {
+		got, err := policy.Resolve(tc.key, tc.header, PriorityNormal)
+		var notAllowed *PriorityNotAllowedError
+		if errors.As(err, &notAllowed) != tc.notAllowed || (!tc.notAllowed && (err != nil || got != tc.want)) {
+			t.Errorf("Resolve(%s, %q) = (%s, %v), want %s (not allowed: %v)", tc.key, tc.header, got, err, tc.want, tc.notAllowed)
+		}
+	}
+
+	if _, err := policy.Resolve("sk-pro", "urgent", PriorityNormal); err == nil {
+		t.Error("unknown priority names should be rejected")
+	}
+	for _, spec := range []string{"pro=high", "plan:pro=urgent", "team:x=high"} {
+		if _, err := ParsePriorityPolicy(spec, keys); err == nil {
+			t.Errorf("ParsePriorityPolicy(%q) should fail", spec)
+		}
+	}
+}
+
+func TestPriorityPolicy_Classes(t *testing.T) {
+	keys, _ := ParseKeyDirectory("sk-ent=acme/enterprise;sk-free=/free")
+	policy, _ := ParsePriorityPolicy("", keys)
+	if err := policy.SetClasses("plan:enterprise=high;plan:free=low"); err != nil {
+		t.Fatalf("SetClasses: %v", err)
+	}
+
+	// 未携带请求头时使用 Key 的优先级等级，等级本身总是允许的
+	if got := policy.Class("sk-ent"); got != PriorityHigh {
+		t.Errorf("Class(sk-ent) = %s, want high", got)
+	}
+	if got, err := policy.Resolve("sk-ent", "", policy.Class("sk-ent")); err != nil || got != PriorityHigh {
+		t.Errorf("Resolve(sk-ent) = (%s, %v), want high", got, err)
+	}
+	if got, err := policy.Resolve("sk-free", "normal", policy.Class("sk-free")); err != nil || got != PriorityNormal {
+		t.Errorf("Resolve(sk-free, normal) = (%s, %v), want normal", got, err)
+	}
+	if got := policy.Class("sk-unknown"); got != PriorityNormal {
+		t.Errorf("Class(sk-unknown) = %s, want normal", got)
+	}
+	if err := policy.SetClasses("plan:free"); err == nil {
+		t.Error("expected an invalid class rule to be rejected")
+	}
+}
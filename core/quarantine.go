@@ -0,0 +1,393 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BreakerState is the quarantine state of a worker
+type BreakerState int
+
+const (
+	// BreakerClosed means the worker receives traffic normally
+	BreakerClosed BreakerState = iota
+	// BreakerOpen means the worker is quarantined and excluded from routing
+	BreakerOpen
+	// BreakerHalfOpen means the cool-down expired and the worker is being probed one request at a time
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// QuarantinePolicy configures when a worker is quarantined and how it is re-admitted
+type QuarantinePolicy struct {
+	// MaxFailures is the number of consecutive Execute failures that trips the quarantine
+	MaxFailures int
+	// CoolDown is how long a quarantined worker is excluded from routing
+	CoolDown time.Duration
+	// ProbeSuccesses is the number of consecutive successful probes required to re-admit the worker
+	ProbeSuccesses int
+}
+
+// DefaultQuarantinePolicy returns the policy used for worker classes without explicit configuration
+func DefaultQuarantinePolicy() QuarantinePolicy {
+	return QuarantinePolicy{
+		MaxFailures:    3,
+		CoolDown:       30 * time.Second,
+		ProbeSuccesses: 3,
+	}
+}
+
+// ErrorRateWindow is the span over which Quarantine reports each worker's rolling error rate
+const ErrorRateWindow = 5 * time.Minute
+
+// outcomeBucketSpan is the granularity of the rolling error rate
+const outcomeBucketSpan = 30 * time.Second
+
+// outcomeBucket counts the finished requests of one worker that started within one bucket span
+type outcomeBucket struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+// workerHealth tracks the breaker state of a single worker
+type workerHealth struct {
+	state     BreakerState
+	failures  int
+	successes int
+	openUntil time.Time
+	// probeUntil marks an in-flight probe; a new probe is admitted once it passes
+	probeUntil time.Time
+}
+
+// Quarantine removes workers from routing after consecutive Execute failures,
+// then re-admits them through slow-start probing once the cool-down expires
+type Quarantine struct {
+	mu       sync.Mutex
+	workers  map[string]*workerHealth
+	outcomes map[string][]outcomeBucket
+	policies map[string]QuarantinePolicy // worker class -> policy
+	fallback QuarantinePolicy
+	classOf  func(workerID string) string
+	events   *EventBus
+	now      func() time.Time
+}
+
+// NewQuarantine creates a Quarantine using policy for all worker classes
+func NewQuarantine(policy QuarantinePolicy, events *EventBus) *Quarantine {
+	return &Quarantine{
+		workers:  make(map[string]*workerHealth),
+		outcomes: make(map[string][]outcomeBucket),
+		policies: make(map[string]QuarantinePolicy),
+		fallback: policy,
+		classOf:  func(string) string { return "" },
+		events:   events,
+		now:      time.Now,
+	}
+}
+
+// SetClassPolicy overrides the policy for workers of the given class
+func (q *Quarantine) SetClassPolicy(class string, policy QuarantinePolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.policies[class] = policy
+}
+
+// SetClassifier sets the function resolving a worker ID to its class
+func (q *Quarantine) SetClassifier(classOf func(workerID string) string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.classOf = classOf
+}
+
+// policyFor returns the policy of the worker's class; caller must hold q.mu
+func (q *Quarantine) policyFor(workerID string) QuarantinePolicy {
+	if policy, ok := q.policies[q.classOf(workerID)]; ok {
+		return policy
+	}
+	return q.fallback
+}
+
+// health returns the tracked state of a worker, creating it if needed; caller must hold q.mu
+func (q *Quarantine) health(workerID string) *workerHealth {
+	h, ok := q.workers[workerID]
+	if !ok {
+		h = &workerHealth{}
+		q.workers[workerID] = h
+	}
+	return h
+}
+
+// Filter returns the workers that may receive traffic right now, without changing any state
+// A worker whose cool-down expired is kept only while no probe is in flight; the probe itself
+// is reserved by AcquireProbe once the worker is actually dispatched
+func (q *Quarantine) Filter(workers []Worker) []Worker {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	allowed := make([]Worker, 0, len(workers))
+	for _, w := range workers {
+		h, ok := q.workers[w.ID()]
+		if !ok || h.state == BreakerClosed {
+			allowed = append(allowed, w)
+			continue
+		}
+		if h.state == BreakerOpen && now.Before(h.openUntil) {
+			continue
+		}
+		if now.Before(h.probeUntil) {
+			continue
+		}
+		allowed = append(allowed, w)
+	}
+	return allowed
+}
+
+// AcquireProbe is called right before a request is dispatched to workerID. A worker that is not
+// quarantined is always dispatchable; a worker whose cool-down expired enters half-open and the
+// request becomes its single in-flight probe. false means the worker must not receive the request
+func (q *Quarantine) AcquireProbe(workerID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	h, ok := q.workers[workerID]
+	if !ok || h.state == BreakerClosed {
+		return true
+	}
+	now := q.now()
+	if h.state == BreakerOpen {
+		if now.Before(h.openUntil) {
+			return false
+		}
+		h.state = BreakerHalfOpen
+		h.successes = 0
+	}
+
+	// Half-open：同一时间只放行一个探测请求
+	if now.Before(h.probeUntil) {
+		return false
+	}
+	h.probeUntil = now.Add(q.policyFor(workerID).CoolDown)
+	return true
+}
+
+// RecordSuccess resets the failure streak and advances slow-start probing
+func (q *Quarantine) RecordSuccess(workerID string) {
+	q.mu.Lock()
+	q.recordOutcomeLocked(workerID, false)
+	h, ok := q.workers[workerID]
+	if !ok {
+		q.mu.Unlock()
+		return
+	}
+
+	h.failures = 0
+	readmitted := false
+	if h.state == BreakerHalfOpen {
+		h.successes++
+		h.probeUntil = time.Time{}
+		if h.successes >= q.policyFor(workerID).ProbeSuccesses {
+			h.state = BreakerClosed
+			readmitted = true
+		}
+	}
+	if h.state == BreakerClosed {
+		delete(q.workers, workerID)
+	}
+	q.mu.Unlock()
+
+	if readmitted {
+		q.events.Publish(Event{
+			Type:     EventWorkerReadmitted,
+			WorkerID: workerID,
+			Message:  "worker passed slow-start probes and rejoined routing",
+		})
+	}
+}
+
+// RecordFailure counts an Execute failure and quarantines the worker once the policy threshold is hit
+func (q *Quarantine) RecordFailure(workerID string) {
+	q.mu.Lock()
+	q.recordOutcomeLocked(workerID, true)
+	policy := q.policyFor(workerID)
+	h := q.health(workerID)
+	h.failures++
+
+	// 探测失败直接重新隔离；正常状态下连续失败达到阈值才隔离
+	tripped := h.state == BreakerHalfOpen || (h.state == BreakerClosed && h.failures >= policy.MaxFailures)
+	if tripped {
+		h.state = BreakerOpen
+		h.openUntil = q.now().Add(policy.CoolDown)
+		h.probeUntil = time.Time{}
+		h.successes = 0
+	}
+	failures := h.failures
+	q.mu.Unlock()
+
+	if tripped {
+		q.events.Publish(Event{
+			Type:     EventWorkerQuarantined,
+			WorkerID: workerID,
+			Message:  fmt.Sprintf("worker quarantined for %s after %d consecutive failures", policy.CoolDown, failures),
+		})
+	}
+}
+
+// Opened returns the quarantined workers with the end of their cool-down
+func (q *Quarantine) Opened() map[string]time.Time {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	opened := make(map[string]time.Time)
+	now := q.now()
+	for workerID, h := range q.workers {
+		if h.state == BreakerOpen && now.Before(h.openUntil) {
+			opened[workerID] = h.openUntil
+		}
+	}
+	return opened
+}
+
+// Open quarantines a worker until the given time, e.g. because another gateway replica tripped its breaker
+// Workers already quarantined or being probed keep their own state
+func (q *Quarantine) Open(workerID string, until time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.now().Before(until) {
+		return
+	}
+	h := q.health(workerID)
+	if h.state != BreakerClosed {
+		return
+	}
+	h.state = BreakerOpen
+	h.openUntil = until
+	h.probeUntil = time.Time{}
+	h.successes = 0
+}
+
+// State returns the breaker state of a worker
+func (q *Quarantine) State(workerID string) BreakerState {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if h, ok := q.workers[workerID]; ok {
+		return h.state
+	}
+	return BreakerClosed
+}
+
+// recordOutcomeLocked counts a finished request in the worker's rolling window; caller must hold q.mu
+func (q *Quarantine) recordOutcomeLocked(workerID string, failed bool) {
+	now := q.now()
+	buckets := q.trimOutcomesLocked(workerID, now)
+	if n := len(buckets); n == 0 || now.Sub(buckets[n-1].start) >= outcomeBucketSpan {
+		buckets = append(buckets, outcomeBucket{start: now})
+	}
+	b := &buckets[len(buckets)-1]
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	q.outcomes[workerID] = buckets
+}
+
+// trimOutcomesLocked drops the worker's buckets older than ErrorRateWindow; caller must hold q.mu
+func (q *Quarantine) trimOutcomesLocked(workerID string, now time.Time) []outcomeBucket {
+	buckets := q.outcomes[workerID]
+	i := 0
+	for i < len(buckets) && now.Sub(buckets[i].start) >= ErrorRateWindow {
+		i++
+	}
+	buckets = buckets[i:]
+	if len(buckets) == 0 {
+		delete(q.outcomes, workerID)
+	}
+	return buckets
+}
+
+// BreakerReport is the quarantine's view of one worker, as shown to operators
+type BreakerReport struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenUntil           *time.Time `json:"open_until,omitempty"`
+	// Requests and Failures count the outcomes of the last ErrorRateWindow
+	Requests  int     `json:"requests"`
+	Failures  int     `json:"failures"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// Report returns the breaker state and rolling error rate of a worker; a nil Quarantine reports a closed breaker
+func (q *Quarantine) Report(workerID string) BreakerReport {
+	report := BreakerReport{State: BreakerClosed.String()}
+	if q == nil {
+		return report
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if h, ok := q.workers[workerID]; ok {
+		report.State = h.state.String()
+		report.ConsecutiveFailures = h.failures
+		if h.state == BreakerOpen {
+			until := h.openUntil
+			report.OpenUntil = &until
+		}
+	}
+	for _, b := range q.trimOutcomesLocked(workerID, q.now()) {
+		report.Requests += b.requests
+		report.Failures += b.failures
+	}
+	if report.Requests > 0 {
+		report.ErrorRate = float64(report.Failures) / float64(report.Requests)
+	}
+	return report
+}
+
+// ParseQuarantinePolicies parses per-class policies of the form "cloud=5/60s;gpu=3/30s"
+// (max failures / cool-down); the probe count is taken from base
+func ParseQuarantinePolicies(spec string, base QuarantinePolicy) (map[string]QuarantinePolicy, error) {
+	policies := make(map[string]QuarantinePolicy)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		class, rule, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid quarantine policy %q: expected class=failures/cooldown", entry)
+		}
+		failures, coolDown, ok := strings.Cut(rule, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid quarantine policy %q: expected class=failures/cooldown", entry)
+		}
+
+		policy := base
+		n, err := strconv.Atoi(strings.TrimSpace(failures))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid quarantine policy %q: failures must be a positive integer", entry)
+		}
+		policy.MaxFailures = n
+		d, err := time.ParseDuration(strings.TrimSpace(coolDown))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid quarantine policy %q: cooldown must be a positive duration", entry)
+		}
+		policy.CoolDown = d
+
+		policies[strings.TrimSpace(class)] = policy
+	}
+	return policies, nil
+}
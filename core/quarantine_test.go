@@ -0,0 +1,114 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuarantine_TripAndReadmit(t *testing.T) {
+	events := NewEventBus()
+	var received []EventType
+	events.Subscribe(func(e Event) {
+		received = append(received, e.Type)
+	})
+
+	now := time.Now()
+	q := NewQuarantine(QuarantinePolicy{MaxFailures: 2, CoolDown: 10 * time.Second, ProbeSuccesses: 2}, events)
+	q.now = func() time.Time { return now }
+
+	workers := []Worker{&MockWorker{id: "worker-1"}, &MockWorker{id: "worker-2"}}
+
+	// 连续失败达到阈值后被隔离
+	q.RecordFailure("worker-1")
+	if got := len(q.Filter(workers)); got != 2 {
+		t.Fatalf("Expected 2 workers before threshold, got %d", got)
+	}
+	q.RecordFailure("worker-1")
+	if q.State("worker-1") != BreakerOpen {
+		t.Fatalf("Expected worker-1 to be quarantined, got %s", q.State("worker-1"))
+	}
+	if got := q.Filter(workers); len(got) != 1 || got[0].ID() != "worker-2" {
+		t.Fatalf("Expected only worker-2 during cool-down, got %d workers", len(got))
+	}
+
+	// 冷却结束：Filter 只读，探测名额在真正派发时占用，且每次只放行一个探测请求
+	now = now.Add(11 * time.Second)
+	for i := 0; i < 2; i++ {
+		if got := len(q.Filter(workers)); got != 2 {
+			t.Fatalf("Expected probe admission after cool-down, got %d workers", got)
+		}
+	}
+	if !q.AcquireProbe("worker-1") {
+		t.Fatal("Expected the first dispatch after cool-down to take the probe")
+	}
+	if got := len(q.Filter(workers)); got != 1 {
+		t.Fatalf("Expected a single concurrent probe, got %d workers", got)
+	}
+	if q.AcquireProbe("worker-1") {
+		t.Fatal("Expected a second probe to be refused while the first is in flight")
+	}
+	if !q.AcquireProbe("worker-2") {
+		t.Fatal("Expected a healthy worker to always be dispatchable")
+	}
+
+	// 连续探测成功后重新加入
+	q.RecordSuccess("worker-1")
+	if q.State("worker-1") != BreakerHalfOpen {
+		t.Fatalf("Expected half-open after first probe, got %s", q.State("worker-1"))
+	}
+	q.AcquireProbe("worker-1")
+	q.RecordSuccess("worker-1")
+	if q.State("worker-1") != BreakerClosed {
+		t.Fatalf("Expected worker-1 readmitted, got %s", q.State("worker-1"))
+	}
+
+	if len(received) != 2 || received[0] != EventWorkerQuarantined || received[1] != EventWorkerReadmitted {
+		t.Errorf("Expected quarantined+readmitted events, got %v", received)
+	}
+}
+
+func TestQuarantine_ProbeFailureReopens(t *testing.T) {
+	now := time.Now()
+	q := NewQuarantine(QuarantinePolicy{MaxFailures: 1, CoolDown: 10 * time.Second, ProbeSuccesses: 1}, nil)
+	q.now = func() time.Time { return now }
+
+	q.RecordFailure("worker-1")
+	now = now.Add(11 * time.Second)
+	q.AcquireProbe("worker-1")
+	q.RecordFailure("worker-1")
+
+	if q.State("worker-1") != BreakerOpen {
+		t.Errorf("Expected failed probe to re-open quarantine, got %s", q.State("worker-1"))
+	}
+}
+
+func TestQuarantine_Report(t *testing.T) {
+	now := time.Now()
+	q := NewQuarantine(QuarantinePolicy{MaxFailures: 2, CoolDown: 10 * time.Second, ProbeSuccesses: 1}, nil)
+	q.now = func() time.Time { return now }
+
+	q.RecordSuccess("worker-1")
+	q.RecordSuccess("worker-1")
+	q.RecordFailure("worker-1")
+	r := q.Report("worker-1")
+	if r.State != "closed" || r.ConsecutiveFailures != 1 || r.Requests != 3 || r.Failures != 1 || r.OpenUntil != nil {
+		t.Fatalf("unexpected report %+v", r)
+	}
+
+	q.RecordFailure("worker-1")
+	r = q.Report("worker-1")
+	if r.State != "open" || r.OpenUntil == nil || !r.OpenUntil.Equal(now.Add(10*time.Second)) || r.ErrorRate != 0.5 {
+		t.Fatalf("expected an open breaker at 50%% errors, got %+v", r)
+	}
+
+	// 滚动窗口之外的结果不再计入
+	now = now.Add(ErrorRateWindow)
+	if r := q.Report("worker-1"); r.Requests != 0 || r.ErrorRate != 0 {
+		t.Errorf("expected an empty window, got %+v", r)
+	}
+
+	var none *Quarantine
+	if r := none.Report("worker-1"); r.State != "closed" {
+		t.Errorf("expected a nil quarantine to report closed, got %+v", r)
+	}
+}
@@ -46,3 +46,34 @@ func (r *InMemoryRateLimiter) Consume(ctx context.Context, apiKey string, actual
 	r.balances[apiKey] -= actualTokens
 	return nil
 }
+
+// SetBalances replaces every balance, e.g. with the ones from the config file
+func (r *InMemoryRateLimiter) SetBalances(balances map[string]int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.balances = make(map[string]int, len(balances))
+	for key, tokens := range balances {
+		r.balances[key] = tokens
+	}
+}
+
+// SetBalance replaces the balance of a key
+func (r *InMemoryRateLimiter) SetBalance(apiKey string, tokens int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.balances[apiKey] = tokens
+}
+
+// RemoveBalance drops a key; its requests are then refused like those of an unknown key
+func (r *InMemoryRateLimiter) RemoveBalance(apiKey string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.balances, apiKey)
+}
+
+// AddBalance adds tokens to the balance of a key
+func (r *InMemoryRateLimiter) AddBalance(apiKey string, tokens int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.balances[apiKey] += tokens
+}
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisTimeout bounds a command when the context has no deadline
+const redisTimeout = 5 * time.Second
+
+// redisPoolSize is the number of idle connections kept per client
+const redisPoolSize = 8
+
+// RedisError is an error reply from the server
+type RedisError string
+
+func (e RedisError) Error() string { return "redis: " + string(e) }
+
+// RedisClient is a minimal RESP2 client for the few commands the gateway needs (GET/SET/DEL/SCAN...)
+// Connections are pooled and re-dialed on failure
+type RedisClient struct {
+	addr     string
+	password string
+	db       int
+	pool     chan *redisConn
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// NewRedisClient parses a redis://[:password@]host[:port][/db] URL
+func NewRedisClient(rawURL string) (*RedisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "redis" || u.Host == "" {
+		return nil, fmt.Errorf("invalid redis URL %q: expected redis://[:password@]host[:port][/db]", rawURL)
+	}
+	c := &RedisClient{addr: u.Host, pool: make(chan *redisConn, redisPoolSize)}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil || c.db < 0 {
+			return nil, fmt.Errorf("invalid redis URL %q: database must be a non-negative integer", rawURL)
+		}
+	}
+	return c, nil
+}
+
+// Do sends one command and returns its reply: string for status replies, int64, []byte or nil
+// for bulk strings, []interface{} for arrays; error replies are returned as RedisError
+func (c *RedisClient) Do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(ctx, args...)
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// 连接状态未知，直接丢弃
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case c.pool <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+// Close closes the pooled connections
+func (c *RedisClient) Close() error {
+	for {
+		select {
+		case conn := <-c.pool:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *RedisClient) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-c.pool:
+		return conn, nil
+	default:
+	}
+
+	var d net.Dialer
+	dialCtx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	nc, err := d.DialContext(dialCtx, "tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+	if c.password != "" {
+		if _, err := conn.do(ctx, "AUTH", c.password); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := conn.do(ctx, "SELECT", strconv.Itoa(c.db)); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (conn *redisConn) do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(conn, b.String()); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return readRESP(conn.r)
+}
+
+// readRESP reads one RESP2 reply
+func readRESP(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid integer reply %q", line)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRESP(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
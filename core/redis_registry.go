@@ -0,0 +1,377 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// DefaultRegistryPrefix is the prefix of the Redis keys holding the shared worker registry
+const DefaultRegistryPrefix = "zam:registry:"
+
+// DefaultWorkerTTL is how long a heartbeat keeps a worker alive, matching the in-memory cleanup
+const DefaultWorkerTTL = 15 * time.Second
+
+// RedisRegistry implements WorkerRegistry on Redis so gateway replicas behind a load balancer share one worker view
+// Every heartbeat is stored under a key expiring after the worker TTL, so liveness is decided by Redis rather
+// than by an in-process cleanup goroutine. Each replica mirrors the live profiles into a local InMemoryRegistry
+// through Sync, which keeps the process-local Worker instances and serves reads without a round trip
+type RedisRegistry struct {
+	*InMemoryRegistry
+	client *RedisClient
+	prefix string
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// registryRecord is the value of a worker's heartbeat key
+type registryRecord struct {
+	Profile  WorkerProfile `json:"profile"`
+	LastSeen int64         `json:"last_seen"` // unix milliseconds
+}
+
+// registryTombstone is the value of a removed worker's tombstone key
+type registryTombstone struct {
+	Incarnation  uint64 `json:"incarnation"`
+	Deregistered bool   `json:"deregistered"`
+}
+
+// NewRedisRegistry creates a registry whose workers expire ttl after their last heartbeat (DefaultWorkerTTL when 0)
+// Call Run to keep the local view in sync with the other replicas
+func NewRedisRegistry(client *RedisClient, ttl time.Duration) *RedisRegistry {
+	if ttl <= 0 {
+		ttl = DefaultWorkerTTL
+	}
+	return &RedisRegistry{
+		InMemoryRegistry: newInMemoryRegistry(),
+		client:           client,
+		prefix:           DefaultRegistryPrefix,
+		ttl:              ttl,
+		now:              time.Now,
+	}
+}
+
+// Heartbeat stores a worker's profile in Redis and applies it to the local view
+// Incarnations and tombstones are checked against the shared state, with the same rejoin semantics as InMemoryRegistry
+func (r *RedisRegistry) Heartbeat(profile WorkerProfile) error {
+	return r.heartbeat([]WorkerProfile{profile}, "worker joined via heartbeat")
+}
+
+// HeartbeatBatch checks every profile before storing any of them, so a stale one rejects the whole batch
+// The writes themselves are not transactional: another replica may observe part of the batch for one sync
+func (r *RedisRegistry) HeartbeatBatch(profiles []WorkerProfile) error {
+	return r.heartbeat(profiles, "worker joined via batch heartbeat")
+}
+
+func (r *RedisRegistry) heartbeat(profiles []WorkerProfile, message string) error {
+	ctx := context.Background()
+	for _, profile := range profiles {
+		if err := r.check(ctx, profile); err != nil {
+			if len(profiles) > 1 {
+				return fmt.Errorf("worker %s: %w", profile.WorkerID, err)
+			}
+			return err
+		}
+	}
+
+	now := r.now()
+	for _, profile := range profiles {
+		if err := r.store(ctx, profile, now); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	var joined []string
+	for _, profile := range profiles {
+		if r.applyHeartbeatLocked(profile, now) {
+			joined = append(joined, profile.WorkerID)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, id := range joined {
+		r.publishWorkerEvent(EventWorkerJoined, id, message)
+	}
+	return nil
+}
+
+// check validates a heartbeat against the shared incarnation and tombstone of the worker
+func (r *RedisRegistry) check(ctx context.Context, profile WorkerProfile) error {
+	record, ok, err := r.record(ctx, profile.WorkerID)
+	if err != nil {
+		return err
+	}
+	// 旧实例的迟到心跳不能覆盖新实例
+	if ok {
+		if profile.Incarnation < record.Profile.Incarnation {
+			return ErrStaleIncarnation
+		}
+		return nil
+	}
+
+	// 已被清理/注销的实例不能通过迟到心跳复活；墓碑键到期即失效
+	reply, err := r.client.Do(ctx, "GET", r.prefix+"tombstone:"+profile.WorkerID)
+	if err != nil {
+		return err
+	}
+	if value, ok := reply.([]byte); ok {
+		var tomb registryTombstone
+		if json.Unmarshal(value, &tomb) == nil {
+			if r.blockedByTombstone(tombstone{incarnation: tomb.Incarnation, deregistered: tomb.Deregistered}, profile.Incarnation) {
+				return ErrStaleIncarnation
+			}
+		}
+	}
+	return nil
+}
+
+// store writes a worker's heartbeat key with the worker TTL and lifts its tombstone
+func (r *RedisRegistry) store(ctx context.Context, profile WorkerProfile, now time.Time) error {
+	value, err := json.Marshal(registryRecord{Profile: profile, LastSeen: now.UnixMilli()})
+	if err != nil {
+		return err
+	}
+	if _, err := r.client.Do(ctx, "SET", r.workerKey(profile.WorkerID), string(value), "PX", strconv.FormatInt(r.ttl.Milliseconds(), 10)); err != nil {
+		return err
+	}
+	if _, err := r.client.Do(ctx, "SADD", r.prefix+"workers", profile.WorkerID); err != nil {
+		return err
+	}
+	_, err = r.client.Do(ctx, "DEL", r.prefix+"tombstone:"+profile.WorkerID)
+	return err
+}
+
+// bury leaves a tombstone for a removed incarnation; an existing tombstone is kept unless the worker was deregistered
+func (r *RedisRegistry) bury(ctx context.Context, workerID string, incarnation uint64, deregistered bool) error {
+	value, err := json.Marshal(registryTombstone{Incarnation: incarnation, Deregistered: deregistered})
+	if err != nil {
+		return err
+	}
+	args := []string{"SET", r.prefix + "tombstone:" + workerID, string(value), "PX", strconv.FormatInt(r.tombstoneTTL.Milliseconds(), 10)}
+	if !deregistered {
+		args = append(args, "NX")
+	}
+	_, err = r.client.Do(ctx, args...)
+	return err
+}
+
+// Deregister removes a worker from every replica and tombstones its current incarnation
+func (r *RedisRegistry) Deregister(workerID string) error {
+	ctx := context.Background()
+	record, ok, err := r.record(ctx, workerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		profile, exists := r.Profile(workerID)
+		if !exists {
+			return ErrWorkerNotFound
+		}
+		record.Profile = profile
+	}
+
+	if err := r.bury(ctx, workerID, record.Profile.Incarnation, true); err != nil {
+		return err
+	}
+	if _, err := r.client.Do(ctx, "DEL", r.workerKey(workerID)); err != nil {
+		return err
+	}
+	if _, err := r.client.Do(ctx, "SREM", r.prefix+"workers", workerID); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.buryLocked(workerID, true)
+	r.mu.Unlock()
+	return nil
+}
+
+// RegisterWorker registers a process-local worker implementation and shares its profile with the other replicas
+// Explicit registration always wins over tombstones
+func (r *RedisRegistry) RegisterWorker(worker Worker, profile WorkerProfile) error {
+	if err := r.InMemoryRegistry.RegisterWorker(worker, profile); err != nil {
+		return err
+	}
+	return r.store(context.Background(), profile, r.now())
+}
+
+// Cordon cordons a worker on every replica; the other replicas pick it up with their next sync
+func (r *RedisRegistry) Cordon(workerID string) error {
+	if err := r.InMemoryRegistry.Cordon(workerID); err != nil {
+		return err
+	}
+	_, err := r.client.Do(context.Background(), "SADD", r.prefix+"cordoned", workerID)
+	return err
+}
+
+// Uncordon returns a worker to routing on every replica
+func (r *RedisRegistry) Uncordon(workerID string) (bool, error) {
+	reply, err := r.client.Do(context.Background(), "SREM", r.prefix+"cordoned", workerID)
+	if err != nil {
+		return false, err
+	}
+	local, _ := r.InMemoryRegistry.Uncordon(workerID)
+	removed, _ := reply.(int64)
+	return removed > 0 || local, nil
+}
+
+// Sync mirrors the live workers stored in Redis into the local view
+// Workers whose heartbeat key expired or that were deregistered on another replica are removed locally
+func (r *RedisRegistry) Sync(ctx context.Context) error {
+	ids, err := r.members(ctx, r.prefix+"workers")
+	if err != nil {
+		return err
+	}
+	cordoned, err := r.members(ctx, r.prefix+"cordoned")
+	if err != nil {
+		return err
+	}
+	records := make(map[string]registryRecord, len(ids))
+	if len(ids) > 0 {
+		args := []string{"MGET"}
+		for _, id := range ids {
+			args = append(args, r.workerKey(id))
+		}
+		reply, err := r.client.Do(ctx, args...)
+		if err != nil {
+			return err
+		}
+		values, _ := reply.([]interface{})
+		for i, id := range ids {
+			var record registryRecord
+			if i < len(values) {
+				if value, ok := values[i].([]byte); ok && json.Unmarshal(value, &record) == nil {
+					records[id] = record
+					continue
+				}
+			}
+			// 心跳键已过期：从索引移除，并为该实例留下墓碑
+			if profile, ok := r.Profile(id); ok {
+				if err := r.bury(ctx, id, profile.Incarnation, false); err != nil {
+					return err
+				}
+			}
+			if _, err := r.client.Do(ctx, "SREM", r.prefix+"workers", id); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 在其他副本注销的 Worker 立即移除，无需等待 TTL
+	deregistered := make(map[string]bool)
+	for _, profile := range r.Profiles() {
+		if _, ok := records[profile.WorkerID]; ok {
+			continue
+		}
+		reply, err := r.client.Do(ctx, "GET", r.prefix+"tombstone:"+profile.WorkerID)
+		if err != nil {
+			return err
+		}
+		var tomb registryTombstone
+		if value, ok := reply.([]byte); ok && json.Unmarshal(value, &tomb) == nil && tomb.Deregistered {
+			deregistered[profile.WorkerID] = true
+		}
+	}
+
+	now := r.now()
+	r.mu.Lock()
+	r.cordoned = make(map[string]bool, len(cordoned))
+	for _, id := range cordoned {
+		r.cordoned[id] = true
+	}
+	var joined, expired []string
+	for id, record := range records {
+		lastSeen := time.UnixMilli(record.LastSeen)
+		if existing, ok := r.workers[id]; ok && !lastSeen.After(existing.LastSeen) {
+			continue
+		}
+		if r.applyHeartbeatLocked(record.Profile, lastSeen) {
+			joined = append(joined, id)
+		}
+	}
+	for id, rw := range r.workers {
+		if _, ok := records[id]; ok {
+			continue
+		}
+		if deregistered[id] {
+			r.buryLocked(id, true)
+			continue
+		}
+		// 刚在本副本注册、尚未出现在索引中的 Worker 在 TTL 内保留
+		if now.Sub(rw.LastSeen) > r.ttl {
+			r.buryLocked(id, false)
+			expired = append(expired, id)
+		}
+	}
+	r.pruneTombstonesLocked(now)
+	r.mu.Unlock()
+
+	for _, id := range joined {
+		r.publishWorkerEvent(EventWorkerJoined, id, "worker joined via another gateway replica")
+	}
+	for _, id := range expired {
+		r.publishWorkerEvent(EventWorkerExpired, id, "worker removed after missing heartbeats")
+	}
+	return nil
+}
+
+// Run syncs every interval until ctx is cancelled
+func (r *RedisRegistry) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Sync(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("registry sync failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// record reads the heartbeat key of a worker
+func (r *RedisRegistry) record(ctx context.Context, workerID string) (registryRecord, bool, error) {
+	reply, err := r.client.Do(ctx, "GET", r.workerKey(workerID))
+	if err != nil {
+		return registryRecord{}, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return registryRecord{}, false, nil
+	}
+	var record registryRecord
+	if err := json.Unmarshal(value, &record); err != nil {
+		return registryRecord{}, false, nil
+	}
+	return record, true, nil
+}
+
+// members lists the worker IDs of a set, such as the index of heartbeat keys
+func (r *RedisRegistry) members(ctx context.Context, key string) ([]string, error) {
+	reply, err := r.client.Do(ctx, "SMEMBERS", key)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected SMEMBERS reply %T", reply)
+	}
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		if id, ok := item.([]byte); ok {
+			ids = append(ids, string(id))
+		}
+	}
+	return ids, nil
+}
+
+func (r *RedisRegistry) workerKey(workerID string) string {
+	return r.prefix + "worker:" + workerID
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRedisRegistrySharesWorkers(t *testing.T) {
+	srv, url := startFakeRedis(t)
+	ctx := context.Background()
+	newReplica := func() *RedisRegistry {
+		client, err := NewRedisClient(url)
+		if err != nil {
+			t.Fatalf("NewRedisClient: %v", err)
+		}
+		registry := NewRedisRegistry(client, 10*time.Second)
+		registry.SetWorkerFactory(func(profile WorkerProfile) Worker {
+			return &MockWorker{id: profile.WorkerID}
+		})
+		return registry
+	}
+	a, b := newReplica(), newReplica()
+
+	profile := WorkerProfile{WorkerID: "gpu-box", Incarnation: 2, Endpoint: "http://10.0.0.5:8000/v1/chat/completions"}
+	if err := a.Heartbeat(profile); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	srv.mu.Lock()
+	px := srv.px["zam:registry:worker:gpu-box"]
+	srv.mu.Unlock()
+	if px != "10000" {
+		t.Errorf("expected the heartbeat key to expire after the TTL, got PX %q", px)
+	}
+
+	// 心跳打到副本 A，副本 B 同步后即可调度该 Worker
+	if err := b.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if got, ok := b.Profile("gpu-box"); !ok || got.Incarnation != 2 {
+		t.Fatalf("expected replica B to see gpu-box, got %+v, %v", got, ok)
+	}
+	if n := len(b.GetAvailableWorkers()); n != 1 {
+		t.Fatalf("expected 1 schedulable worker on replica B, got %d", n)
+	}
+
+	// 旧实例的迟到心跳在任意副本都被拒绝
+	if err := b.Heartbeat(WorkerProfile{WorkerID: "gpu-box", Incarnation: 1}); !errors.Is(err, ErrStaleIncarnation) {
+		t.Fatalf("expected ErrStaleIncarnation, got %v", err)
+	}
+
+	// 在副本 B 注销，副本 A 同步后移除，旧实例无法复活
+	if err := b.Deregister("gpu-box"); err != nil {
+		t.Fatalf("Deregister: %v", err)
+	}
+	if err := a.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if _, ok := a.Profile("gpu-box"); ok {
+		t.Fatal("expected replica A to drop the deregistered worker")
+	}
+	if err := a.Heartbeat(profile); !errors.Is(err, ErrStaleIncarnation) {
+		t.Fatalf("expected the tombstone to reject the old incarnation, got %v", err)
+	}
+	profile.Incarnation = 3
+	if err := a.Heartbeat(profile); err != nil {
+		t.Fatalf("expected a restarted worker to rejoin, got %v", err)
+	}
+	if err := b.Deregister("cpu-box"); !errors.Is(err, ErrWorkerNotFound) {
+		t.Fatalf("expected ErrWorkerNotFound, got %v", err)
+	}
+}
+
+func TestRedisRegistryExpiresWorkers(t *testing.T) {
+	srv, url := startFakeRedis(t)
+	ctx := context.Background()
+	client, err := NewRedisClient(url)
+	if err != nil {
+		t.Fatalf("NewRedisClient: %v", err)
+	}
+	registry := NewRedisRegistry(client, 10*time.Second)
+	now := time.Now()
+	registry.now = func() time.Time { return now }
+
+	if err := registry.Heartbeat(WorkerProfile{WorkerID: "gpu-box", Incarnation: 1}); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	if err := registry.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if _, ok := registry.Profile("gpu-box"); !ok {
+		t.Fatal("expected gpu-box to stay registered while its key is alive")
+	}
+
+	// 模拟心跳键过期
+	srv.mu.Lock()
+	delete(srv.data, "zam:registry:worker:gpu-box")
+	srv.mu.Unlock()
+	now = now.Add(11 * time.Second)
+	if err := registry.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if _, ok := registry.Profile("gpu-box"); ok {
+		t.Fatal("expected gpu-box to be removed after its key expired")
+	}
+	srv.mu.Lock()
+	_, indexed := srv.sets["zam:registry:workers"]["gpu-box"]
+	srv.mu.Unlock()
+	if indexed {
+		t.Error("expected the expired worker to be removed from the index")
+	}
+	if err := registry.Heartbeat(WorkerProfile{WorkerID: "gpu-box", Incarnation: 1}); !errors.Is(err, ErrStaleIncarnation) {
+		t.Fatalf("expected a late heartbeat of the expired incarnation to be rejected, got %v", err)
+	}
+	if err := registry.Heartbeat(WorkerProfile{WorkerID: "gpu-box", Incarnation: 2}); err != nil {
+		t.Fatalf("expected a restarted worker to rejoin, got %v", err)
+	}
+}
+
+func TestRedisRegistrySharesCordons(t *testing.T) {
+	_, url := startFakeRedis(t)
+	ctx := context.Background()
+	newReplica := func() *RedisRegistry {
+		client, err := NewRedisClient(url)
+		if err != nil {
+			t.Fatalf("NewRedisClient: %v", err)
+		}
+		registry := NewRedisRegistry(client, 10*time.Second)
+		registry.SetWorkerFactory(func(profile WorkerProfile) Worker {
+			return &MockWorker{id: profile.WorkerID}
+		})
+		return registry
+	}
+	a, b := newReplica(), newReplica()
+
+	profile := WorkerProfile{WorkerID: "gpu-box", Endpoint: "http://10.0.0.5:8000/v1/chat/completions"}
+	if err := a.Heartbeat(profile); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	if err := a.Cordon("gpu-box"); err != nil {
+		t.Fatalf("Cordon: %v", err)
+	}
+	if err := b.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if !b.Cordoned("gpu-box") || len(b.GetAvailableWorkers()) != 0 {
+		t.Fatal("expected replica B to exclude the worker cordoned on replica A")
+	}
+
+	if ok, err := b.Uncordon("gpu-box"); !ok || err != nil {
+		t.Fatalf("Uncordon = %v, %v", ok, err)
+	}
+	if err := a.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if a.Cordoned("gpu-box") || len(a.GetAvailableWorkers()) != 1 {
+		t.Fatal("expected replica A to route to the uncordoned worker again")
+	}
+}
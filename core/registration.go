@@ -0,0 +1,60 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"sync"
+)
+
+// RegistrationTokenHeader carries the token issued by POST /v1/workers/register on later heartbeats
+const RegistrationTokenHeader = "X-Zam-Registration-Token"
+
+// RegistrationTokens holds the tokens issued to workers registered through the API
+// Once a worker ID holds a token, only heartbeats presenting it may update that worker, so another
+// host sharing the worker token cannot take over its profile. Tokens live in this gateway process
+type RegistrationTokens struct {
+	mu     sync.RWMutex
+	tokens map[string]string
+}
+
+// NewRegistrationTokens creates an empty token store
+func NewRegistrationTokens() *RegistrationTokens {
+	return &RegistrationTokens{tokens: make(map[string]string)}
+}
+
+// Issue generates a new token for a worker, replacing any previous one
+func (t *RegistrationTokens) Issue(workerID string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokens[workerID] = token
+	return token, nil
+}
+
+// Verify reports whether a heartbeat of workerID may proceed: workers without a token are not checked
+// A nil store accepts everything
+func (t *RegistrationTokens) Verify(workerID, token string) bool {
+	if t == nil {
+		return true
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	issued, ok := t.tokens[workerID]
+	return !ok || subtle.ConstantTimeCompare([]byte(issued), []byte(token)) == 1
+}
+
+// Revoke forgets the token of a worker, e.g. after it is deregistered
+func (t *RegistrationTokens) Revoke(workerID string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.tokens, workerID)
+}
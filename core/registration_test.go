@@ -0,0 +1,33 @@
+package core
+
+import "testing"
+
+func TestRegistrationTokens(t *testing.T) {
+	tokens := NewRegistrationTokens()
+	if !tokens.Verify("gpu-box", "") {
+		t.Fatal("workers without a token should not be checked")
+	}
+
+	token, err := tokens.Issue("gpu-box")
+	if err != nil || len(token) != 64 {
+		t.Fatalf("Issue() = %q, %v", token, err)
+	}
+	if !tokens.Verify("gpu-box", token) || tokens.Verify("gpu-box", "") || tokens.Verify("gpu-box", token[1:]) {
+		t.Error("expected only the issued token to be accepted")
+	}
+
+	// 重新签发后旧 Token 失效；注销后不再校验
+	renewed, _ := tokens.Issue("gpu-box")
+	if renewed == token || tokens.Verify("gpu-box", token) {
+		t.Error("expected a renewed token to replace the old one")
+	}
+	tokens.Revoke("gpu-box")
+	if !tokens.Verify("gpu-box", "") {
+		t.Error("expected a revoked worker not to be checked")
+	}
+
+	var none *RegistrationTokens
+	if !none.Verify("gpu-box", "") {
+		t.Error("a nil store should accept everything")
+	}
+}
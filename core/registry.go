@@ -2,36 +2,75 @@ package core
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"sync"
 	"time"
 )
 
+var (
+	// ErrStaleIncarnation is returned when a heartbeat comes from an older incarnation of a worker
+	ErrStaleIncarnation = errors.New("stale worker incarnation")
+	// ErrWorkerNotFound is returned when the worker is not registered
+	ErrWorkerNotFound = errors.New("worker not found")
+)
+
+// DefaultTombstoneTTL is how long a removed worker's tombstone blocks its old incarnation
+const DefaultTombstoneTTL = 60 * time.Second
+
 // RegisteredWorker wraps WorkerProfile with last heartbeat time
 type RegisteredWorker struct {
 	Profile  WorkerProfile
 	Worker   Worker
 	LastSeen time.Time
+	// endpoint is the URL Worker was built for from heartbeats (empty for manually registered workers)
+	endpoint string
 }
 
 // WorkerRegistry defines the interface for dynamic worker registration
 type WorkerRegistry interface {
 	// Heartbeat registers or updates a worker's profile
 	Heartbeat(profile WorkerProfile) error
+	// HeartbeatBatch atomically registers or updates several workers reported by one host
+	HeartbeatBatch(profiles []WorkerProfile) error
 	// GetAvailableWorkers returns all alive workers for router scheduling
 	GetAvailableWorkers() []Worker
+	// Deregister removes a worker and tombstones its current incarnation
+	Deregister(workerID string) error
+}
+
+// ProfileSource serves the last profile each worker reported, kept current by worker heartbeats
+// and background probes, so routing does not have to call Heartbeat on every request
+type ProfileSource interface {
+	Profile(workerID string) (WorkerProfile, bool)
+}
+
+// tombstone remembers a removed worker so late heartbeats from the same incarnation are rejected
+type tombstone struct {
+	incarnation  uint64
+	deregistered bool
+	until        time.Time
 }
 
 // InMemoryRegistry implements WorkerRegistry with thread-safe in-memory storage
 type InMemoryRegistry struct {
-	mu      sync.RWMutex
-	workers map[string]*RegisteredWorker
+	mu         sync.RWMutex
+	workers    map[string]*RegisteredWorker
+	tombstones map[string]tombstone
+	// cordoned workers stay registered but are left out of GetAvailableWorkers until uncordoned
+	cordoned      map[string]bool
+	tombstoneTTL  time.Duration
+	degradedAfter time.Duration
+	// events receives worker joined/expired events; nil drops them
+	events *EventBus
+	// newWorker builds the worker of a heartbeat reporting an Endpoint; nil leaves such workers unschedulable
+	newWorker func(profile WorkerProfile) Worker
 }
 
 // NewInMemoryRegistry creates a new InMemoryRegistry with a cleanup goroutine
 func NewInMemoryRegistry(ctx context.Context) *InMemoryRegistry {
-	registry := &InMemoryRegistry{
-		workers: make(map[string]*RegisteredWorker),
-	}
+	registry := newInMemoryRegistry()
 
 	// 启动清理协程：每 5 秒清理一次超时 15 秒的僵尸节点
 	go registry.cleanupDeadWorkers(ctx)
@@ -39,42 +78,201 @@ func NewInMemoryRegistry(ctx context.Context) *InMemoryRegistry {
 	return registry
 }
 
+// newInMemoryRegistry creates an InMemoryRegistry without the cleanup goroutine
+func newInMemoryRegistry() *InMemoryRegistry {
+	return &InMemoryRegistry{
+		workers:       make(map[string]*RegisteredWorker),
+		tombstones:    make(map[string]tombstone),
+		cordoned:      make(map[string]bool),
+		tombstoneTTL:  DefaultTombstoneTTL,
+		degradedAfter: DefaultDegradedAfter,
+	}
+}
+
+// SetEvents enables publishing worker joined/expired events
+func (r *InMemoryRegistry) SetEvents(bus *EventBus) {
+	r.events = bus
+}
+
+// SetWorkerFactory enables building workers for heartbeats that report an Endpoint,
+// so remote backends can join the pool by heartbeating alone. Such heartbeats decide where prompts
+// are sent: only enable it when the heartbeat endpoints are authenticated. Not safe for concurrent use
+func (r *InMemoryRegistry) SetWorkerFactory(newWorker func(profile WorkerProfile) Worker) {
+	r.newWorker = newWorker
+}
+
+// publishWorkerEvent publishes outside r.mu so subscribers may query the registry
+func (r *InMemoryRegistry) publishWorkerEvent(t EventType, workerID, message string) {
+	r.events.Publish(Event{Type: t, WorkerID: workerID, Message: message})
+}
+
 // Heartbeat registers or updates a worker's profile
+// Rejoin semantics: a higher Incarnation replaces the current one (restarted worker),
+// a lower one is rejected with ErrStaleIncarnation, as is any incarnation covered by a tombstone
 func (r *InMemoryRegistry) Heartbeat(profile WorkerProfile) error {
 	r.mu.Lock()
-	defer r.mu.Unlock()
+	if err := r.checkHeartbeatLocked(profile); err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	joined := r.applyHeartbeatLocked(profile, time.Now())
+	r.mu.Unlock()
+
+	if joined {
+		r.publishWorkerEvent(EventWorkerJoined, profile.WorkerID, "worker joined via heartbeat")
+	}
+	return nil
+}
+
+// HeartbeatBatch applies the heartbeats of several workers (e.g. one per GPU of a host) atomically:
+// either every profile is accepted or none is
+func (r *InMemoryRegistry) HeartbeatBatch(profiles []WorkerProfile) error {
+	r.mu.Lock()
+	for _, profile := range profiles {
+		if err := r.checkHeartbeatLocked(profile); err != nil {
+			r.mu.Unlock()
+			return fmt.Errorf("worker %s: %w", profile.WorkerID, err)
+		}
+	}
 
-	// 查找已注册的 Worker
+	now := time.Now()
+	var joined []string
+	for _, profile := range profiles {
+		if r.applyHeartbeatLocked(profile, now) {
+			joined = append(joined, profile.WorkerID)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, id := range joined {
+		r.publishWorkerEvent(EventWorkerJoined, id, "worker joined via batch heartbeat")
+	}
+	return nil
+}
+
+// checkHeartbeatLocked validates a heartbeat against incarnations and tombstones; caller must hold r.mu
+func (r *InMemoryRegistry) checkHeartbeatLocked(profile WorkerProfile) error {
+	// 旧实例的迟到心跳不能覆盖新实例
 	if existing, exists := r.workers[profile.WorkerID]; exists {
-		// 更新 Profile 和 LastSeen
-		existing.Profile = profile
-		existing.LastSeen = time.Now()
+		if profile.Incarnation < existing.Profile.Incarnation {
+			return ErrStaleIncarnation
+		}
 		return nil
 	}
 
-	// Worker 不存在，但 Heartbeat 不负责创建 Worker 实例
-	// Worker 需要在首次注册时通过其他方式注入
-	// 这里只记录 Profile 和 LastSeen
-	r.workers[profile.WorkerID] = &RegisteredWorker{
+	// 已被清理/注销的实例不能通过迟到心跳复活
+	if tomb, ok := r.tombstones[profile.WorkerID]; ok {
+		if time.Now().Before(tomb.until) && r.blockedByTombstone(tomb, profile.Incarnation) {
+			return ErrStaleIncarnation
+		}
+	}
+	return nil
+}
+
+// applyHeartbeatLocked records an accepted heartbeat and reports whether the worker is new; caller must hold r.mu
+func (r *InMemoryRegistry) applyHeartbeatLocked(profile WorkerProfile, now time.Time) bool {
+	// 查找已注册的 Worker，更新 Profile 和 LastSeen
+	if existing, exists := r.workers[profile.WorkerID]; exists {
+		// 心跳不能改变已有 Worker 的 Endpoint，避免请求被引向他处；需要迁移时先注销再注册
+		if existing.endpoint != "" {
+			profile.Endpoint = existing.endpoint
+		}
+		existing.Profile = profile
+		existing.LastSeen = now
+		// 仅在首次上报 Endpoint 时创建 Worker；手动注册的 Worker 不会被替换
+		if existing.Worker == nil {
+			existing.Worker, existing.endpoint = r.dynamicWorker(profile)
+		}
+		return false
+	}
+
+	delete(r.tombstones, profile.WorkerID)
+
+	// 携带 Endpoint 的心跳由工厂创建 Worker 实例，否则只记录 Profile，Worker 需要通过 RegisterWorker 注入
+	rw := &RegisteredWorker{
 		Profile:  profile,
-		Worker:   nil, // 需要后续注入
-		LastSeen: time.Now(),
+		LastSeen: now,
+	}
+	rw.Worker, rw.endpoint = r.dynamicWorker(profile)
+	r.workers[profile.WorkerID] = rw
+	return true
+}
+
+// dynamicWorker builds the worker of a heartbeat-registered endpoint; nil without an endpoint or factory
+func (r *InMemoryRegistry) dynamicWorker(profile WorkerProfile) (Worker, string) {
+	if r.newWorker == nil || profile.Endpoint == "" {
+		return nil, ""
 	}
+	return r.newWorker(profile), profile.Endpoint
+}
 
+// releaseWorker closes a worker the registry built from heartbeats once it is dropped
+// Requests still running on it are not interrupted; manually registered workers belong to their caller
+func releaseWorker(rw *RegisteredWorker) {
+	if rw.endpoint == "" {
+		return
+	}
+	if closer, ok := rw.Worker.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// blockedByTombstone reports whether a heartbeat of the given incarnation is covered by the tombstone
+// Workers that do not report incarnations are only blocked after an explicit deregistration
+func (r *InMemoryRegistry) blockedByTombstone(tomb tombstone, incarnation uint64) bool {
+	if incarnation == 0 && tomb.incarnation == 0 {
+		return tomb.deregistered
+	}
+	return incarnation <= tomb.incarnation
+}
+
+// buryLocked removes a worker and leaves a tombstone; caller must hold r.mu
+func (r *InMemoryRegistry) buryLocked(workerID string, deregistered bool) {
+	rw, exists := r.workers[workerID]
+	if !exists {
+		return
+	}
+	delete(r.workers, workerID)
+	releaseWorker(rw)
+	r.tombstones[workerID] = tombstone{
+		incarnation:  rw.Profile.Incarnation,
+		deregistered: deregistered,
+		until:        time.Now().Add(r.tombstoneTTL),
+	}
+}
+
+// Deregister removes a worker and tombstones its current incarnation
+func (r *InMemoryRegistry) Deregister(workerID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.workers[workerID]; !exists {
+		return ErrWorkerNotFound
+	}
+	r.buryLocked(workerID, true)
 	return nil
 }
 
 // RegisterWorker manually registers a worker with its implementation
+// Explicit registration always wins over tombstones
 func (r *InMemoryRegistry) RegisterWorker(worker Worker, profile WorkerProfile) error {
 	r.mu.Lock()
-	defer r.mu.Unlock()
+	delete(r.tombstones, profile.WorkerID)
 
+	previous, existed := r.workers[profile.WorkerID]
+	if existed && previous.Worker != worker {
+		releaseWorker(previous)
+	}
 	r.workers[profile.WorkerID] = &RegisteredWorker{
 		Profile:  profile,
 		Worker:   worker,
 		LastSeen: time.Now(),
 	}
+	r.mu.Unlock()
 
+	if !existed {
+		r.publishWorkerEvent(EventWorkerJoined, profile.WorkerID, "worker registered")
+	}
 	return nil
 }
 
@@ -84,8 +282,8 @@ func (r *InMemoryRegistry) GetAvailableWorkers() []Worker {
 	defer r.mu.RUnlock()
 
 	var workers []Worker
-	for _, rw := range r.workers {
-		if rw.Worker != nil {
+	for id, rw := range r.workers {
+		if rw.Worker != nil && !r.cordoned[id] {
 			workers = append(workers, rw.Worker)
 		}
 	}
@@ -93,6 +291,94 @@ func (r *InMemoryRegistry) GetAvailableWorkers() []Worker {
 	return workers
 }
 
+// Cordon excludes a registered worker from GetAvailableWorkers, so it receives no new requests
+// while requests already running on it finish. The cordon outlives re-registration and restarts
+// of the worker, e.g. for a driver update, until Uncordon is called
+func (r *InMemoryRegistry) Cordon(workerID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.workers[workerID]; !exists {
+		return ErrWorkerNotFound
+	}
+	r.cordoned[workerID] = true
+	return nil
+}
+
+// Uncordon returns a cordoned worker to routing; it reports false if the worker was not cordoned
+func (r *InMemoryRegistry) Uncordon(workerID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.cordoned[workerID] {
+		return false, nil
+	}
+	delete(r.cordoned, workerID)
+	return true, nil
+}
+
+// Cordoned reports whether a worker is cordoned
+func (r *InMemoryRegistry) Cordoned(workerID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cordoned[workerID]
+}
+
+// Profile returns the last reported profile of a worker
+func (r *InMemoryRegistry) Profile(workerID string) (WorkerProfile, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rw, exists := r.workers[workerID]
+	if !exists {
+		return WorkerProfile{}, false
+	}
+	return rw.Profile, true
+}
+
+// Profiles returns a snapshot of all registered worker profiles, including telemetry
+func (r *InMemoryRegistry) Profiles() []WorkerProfile {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	profiles := make([]WorkerProfile, 0, len(r.workers))
+	for _, rw := range r.workers {
+		profiles = append(profiles, rw.Profile)
+	}
+	return profiles
+}
+
+// Registered returns a snapshot of every registered worker with its last heartbeat time
+// Entries without a Worker are known from heartbeats but cannot be scheduled yet
+func (r *InMemoryRegistry) Registered() []RegisteredWorker {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	registered := make([]RegisteredWorker, 0, len(r.workers))
+	for _, rw := range r.workers {
+		registered = append(registered, *rw)
+	}
+	return registered
+}
+
+// WorkerState reports whether a worker's heartbeats are on time or stale-but-usable
+// Unknown workers are reported healthy so statically wired workers are not penalized
+func (r *InMemoryRegistry) WorkerState(workerID string) WorkerState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rw, exists := r.workers[workerID]
+	if !exists {
+		return WorkerState{Health: WorkerHealthy}
+	}
+
+	staleness := time.Since(rw.LastSeen)
+	if staleness > r.degradedAfter {
+		return WorkerState{Health: WorkerDegraded, Staleness: staleness}
+	}
+	return WorkerState{Health: WorkerHealthy, Staleness: staleness}
+}
+
 // cleanupDeadWorkers removes workers that haven't sent heartbeat for > 15 seconds
 func (r *InMemoryRegistry) cleanupDeadWorkers(ctx context.Context) {
 	ticker := time.NewTicker(5 * time.Second)
@@ -106,13 +392,29 @@ func (r *InMemoryRegistry) cleanupDeadWorkers(ctx context.Context) {
 		case <-ticker.C:
 			r.mu.Lock()
 			now := time.Now()
+			var expired []string
 			for workerID, rw := range r.workers {
 				if now.Sub(rw.LastSeen) > 15*time.Second {
-					// 超过 15 秒未心跳，清理僵尸节点
-					delete(r.workers, workerID)
+					// 超过 15 秒未心跳，清理僵尸节点并留下墓碑
+					r.buryLocked(workerID, false)
+					expired = append(expired, workerID)
 				}
 			}
+			r.pruneTombstonesLocked(now)
 			r.mu.Unlock()
+
+			for _, workerID := range expired {
+				r.publishWorkerEvent(EventWorkerExpired, workerID, "worker removed after missing heartbeats")
+			}
+		}
+	}
+}
+
+// pruneTombstonesLocked drops tombstones past their TTL; caller must hold r.mu
+func (r *InMemoryRegistry) pruneTombstonesLocked(now time.Time) {
+	for workerID, tomb := range r.tombstones {
+		if now.After(tomb.until) {
+			delete(r.tombstones, workerID)
 		}
 	}
 }
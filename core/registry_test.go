@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -375,3 +376,231 @@ func TestInMemoryRegistry_UpdateNonExistentWorker(t *testing.T) {
 		t.Errorf("Expected 0 available workers, got %d", len(workers))
 	}
 }
+
+func TestInMemoryRegistry_TelemetryStored(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := NewInMemoryRegistry(ctx)
+
+	// 心跳携带遥测字段
+	profile := WorkerProfile{
+		WorkerID:        "worker-1",
+		Supported:       []string{"gpt-3.5-turbo"},
+		MaxTasks:        2,
+		GPUUtilization:  87.5,
+		TemperatureC:    71,
+		KVCacheUsage:    0.42,
+		QueueLength:     3,
+		TokensPerSecond: 55.1,
+	}
+	if err := registry.Heartbeat(profile); err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+
+	stored, ok := registry.Profile("worker-1")
+	if !ok {
+		t.Fatal("Expected worker-1 profile to be stored")
+	}
+	if stored.GPUUtilization != 87.5 || stored.KVCacheUsage != 0.42 || stored.QueueLength != 3 {
+		t.Errorf("Telemetry not stored as reported: %+v", stored)
+	}
+	if got := len(registry.Profiles()); got != 1 {
+		t.Errorf("Expected 1 profile in snapshot, got %d", got)
+	}
+}
+
+func TestInMemoryRegistry_TombstoneRejoin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := NewInMemoryRegistry(ctx)
+
+	profile := WorkerProfile{
+		WorkerID:    "worker-1",
+		Supported:   []string{"gpt-3.5-turbo"},
+		MaxTasks:    2,
+		Incarnation: 100,
+	}
+	if err := registry.RegisterWorker(&MockWorker{id: "worker-1"}, profile); err != nil {
+		t.Fatalf("RegisterWorker failed: %v", err)
+	}
+
+	// 注销后，旧实例的迟到心跳不能复活
+	if err := registry.Deregister("worker-1"); err != nil {
+		t.Fatalf("Deregister failed: %v", err)
+	}
+	if err := registry.Heartbeat(profile); err != ErrStaleIncarnation {
+		t.Fatalf("Expected ErrStaleIncarnation for late heartbeat, got %v", err)
+	}
+	if _, ok := registry.Profile("worker-1"); ok {
+		t.Fatal("Late heartbeat must not resurrect the worker")
+	}
+
+	// 重启后的新实例可以重新加入
+	restarted := profile
+	restarted.Incarnation = 200
+	if err := registry.Heartbeat(restarted); err != nil {
+		t.Fatalf("Expected restarted incarnation to rejoin, got %v", err)
+	}
+
+	// 新实例在线时，旧实例的心跳被拒绝
+	if err := registry.Heartbeat(profile); err != ErrStaleIncarnation {
+		t.Fatalf("Expected ErrStaleIncarnation while newer incarnation is live, got %v", err)
+	}
+
+	if err := registry.Deregister("unknown"); err != ErrWorkerNotFound {
+		t.Errorf("Expected ErrWorkerNotFound, got %v", err)
+	}
+}
+
+func TestInMemoryRegistry_HeartbeatBatchAtomic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := NewInMemoryRegistry(ctx)
+
+	if err := registry.Heartbeat(WorkerProfile{WorkerID: "host-gpu1", Incarnation: 5}); err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+
+	// gpu1 的 incarnation 过旧，整批应被拒绝
+	err := registry.HeartbeatBatch([]WorkerProfile{
+		{WorkerID: "host-gpu0", Incarnation: 1, MaxTasks: 4},
+		{WorkerID: "host-gpu1", Incarnation: 4, MaxTasks: 4},
+	})
+	if !errors.Is(err, ErrStaleIncarnation) {
+		t.Fatalf("Expected ErrStaleIncarnation, got %v", err)
+	}
+	if _, ok := registry.Profile("host-gpu0"); ok {
+		t.Error("Expected host-gpu0 not to be registered after rejected batch")
+	}
+
+	err = registry.HeartbeatBatch([]WorkerProfile{
+		{WorkerID: "host-gpu0", Incarnation: 1, MaxTasks: 4},
+		{WorkerID: "host-gpu1", Incarnation: 5, MaxTasks: 4},
+	})
+	if err != nil {
+		t.Fatalf("HeartbeatBatch failed: %v", err)
+	}
+	for _, id := range []string{"host-gpu0", "host-gpu1"} {
+		profile, ok := registry.Profile(id)
+		if !ok || profile.MaxTasks != 4 {
+			t.Errorf("Expected %s to be updated, got %+v (found=%v)", id, profile, ok)
+		}
+	}
+}
+
+func TestInMemoryRegistry_HeartbeatEndpoint(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := NewInMemoryRegistry(ctx)
+	var built []string
+	var instances []*closableWorker
+	registry.SetWorkerFactory(func(profile WorkerProfile) Worker {
+		built = append(built, profile.Endpoint)
+		w := &closableWorker{MockWorker: MockWorker{id: profile.WorkerID}}
+		instances = append(instances, w)
+		return w
+	})
+
+	// 未携带 endpoint 的心跳仍然只记录 Profile
+	if err := registry.Heartbeat(WorkerProfile{WorkerID: "gpu-box"}); err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	if n := len(registry.GetAvailableWorkers()); n != 0 {
+		t.Fatalf("expected no schedulable worker without an endpoint, got %d", n)
+	}
+
+	// 首次携带 endpoint 时创建 Worker，之后的心跳复用
+	profile := WorkerProfile{WorkerID: "gpu-box", Endpoint: "http://10.0.0.5:8000/v1/chat/completions"}
+	for i := 0; i < 2; i++ {
+		if err := registry.Heartbeat(profile); err != nil {
+			t.Fatalf("Heartbeat failed: %v", err)
+		}
+	}
+	workers := registry.GetAvailableWorkers()
+	if len(workers) != 1 || workers[0].ID() != "gpu-box" || len(built) != 1 {
+		t.Fatalf("expected one worker built once, got %d workers and %d builds", len(workers), len(built))
+	}
+
+	// 心跳不能把已有 Worker 改指向其他 endpoint
+	original := profile.Endpoint
+	profile.Endpoint = "http://10.0.0.6:8000/v1/chat/completions"
+	registry.Heartbeat(profile)
+	if len(built) != 1 {
+		t.Errorf("expected the endpoint change to be ignored, got builds %v", built)
+	}
+	if got, _ := registry.Profile("gpu-box"); got.Endpoint != original {
+		t.Errorf("expected the profile to keep endpoint %s, got %s", original, got.Endpoint)
+	}
+
+	// 注销时释放由心跳创建的 Worker
+	registry.Deregister("gpu-box")
+	if !instances[0].closed {
+		t.Error("expected the heartbeat-built worker to be closed on deregistration")
+	}
+
+	// 手动注册的 Worker 不会被心跳中的 endpoint 替换
+	static := &MockWorker{id: "static"}
+	registry.RegisterWorker(static, WorkerProfile{WorkerID: "static"})
+	registry.Heartbeat(WorkerProfile{WorkerID: "static", Endpoint: "http://10.0.0.7:8000/v1/chat/completions"})
+	for _, w := range registry.GetAvailableWorkers() {
+		if w.ID() == "static" && w != Worker(static) {
+			t.Error("expected the manually registered worker to be kept")
+		}
+	}
+	if len(built) != 1 {
+		t.Errorf("expected no worker built for the static worker, got %v", built)
+	}
+}
+
+// closableWorker records whether the registry released it
+type closableWorker struct {
+	MockWorker
+	closed bool
+}
+
+func (w *closableWorker) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestInMemoryRegistry_Cordon(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	registry := NewInMemoryRegistry(ctx)
+	registry.RegisterWorker(&MockWorker{id: "worker-1"}, WorkerProfile{WorkerID: "worker-1"})
+	registry.RegisterWorker(&MockWorker{id: "worker-2"}, WorkerProfile{WorkerID: "worker-2"})
+
+	if err := registry.Cordon("worker-3"); !errors.Is(err, ErrWorkerNotFound) {
+		t.Fatalf("Expected ErrWorkerNotFound, got %v", err)
+	}
+	if err := registry.Cordon("worker-1"); err != nil {
+		t.Fatalf("Cordon failed: %v", err)
+	}
+	workers := registry.GetAvailableWorkers()
+	if len(workers) != 1 || workers[0].ID() != "worker-2" {
+		t.Fatalf("Expected only worker-2 to be schedulable, got %d workers", len(workers))
+	}
+	if _, ok := registry.Profile("worker-1"); !ok || !registry.Cordoned("worker-1") {
+		t.Error("Expected the cordoned worker to stay registered")
+	}
+
+	// 重新注册（如驱动升级后重启）不会解除封锁
+	registry.RegisterWorker(&MockWorker{id: "worker-1"}, WorkerProfile{WorkerID: "worker-1", Incarnation: 2})
+	if len(registry.GetAvailableWorkers()) != 1 {
+		t.Error("Expected the cordon to outlive re-registration")
+	}
+
+	if ok, _ := registry.Uncordon("worker-1"); !ok {
+		t.Fatal("Expected worker-1 to be uncordoned")
+	}
+	if ok, _ := registry.Uncordon("worker-1"); ok {
+		t.Error("Expected a second uncordon to report false")
+	}
+	if len(registry.GetAvailableWorkers()) != 2 {
+		t.Error("Expected both workers to be schedulable again")
+	}
+}
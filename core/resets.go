@@ -0,0 +1,202 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BalanceStore is implemented by rate limiters whose balances can be adjusted by operators
+type BalanceStore interface {
+	// SetBalance replaces the balance of a key
+	SetBalance(apiKey string, tokens int)
+	// AddBalance adds tokens to the balance of a key
+	AddBalance(apiKey string, tokens int)
+}
+
+// QuotaResetRule resets or tops up the balance of a key, or of every key on a plan, at each period boundary
+type QuotaResetRule struct {
+	Scope string // "key" or "plan"
+	ID    string
+	// TopUp adds Tokens instead of replacing the balance
+	TopUp  bool
+	Tokens int
+	Period ResetPeriod
+}
+
+// name identifies the rule in persisted state
+func (r QuotaResetRule) name() string {
+	return r.Scope + ":" + r.ID
+}
+
+// QuotaResetScheduler applies reset rules on period boundaries in a fixed time zone
+// The start of the last applied period of each rule is persisted so a restart never applies a period twice
+type QuotaResetScheduler struct {
+	rules     []QuotaResetRule
+	balances  BalanceStore
+	keys      *KeyDirectory
+	loc       *time.Location
+	statePath string
+
+	mu      sync.Mutex
+	applied map[string]time.Time
+	now     func() time.Time
+}
+
+// NewQuotaResetScheduler creates a scheduler; statePath is the JSON file recording applied periods
+// ("" keeps state in memory only)
+func NewQuotaResetScheduler(rules []QuotaResetRule, balances BalanceStore, keys *KeyDirectory, loc *time.Location, statePath string) (*QuotaResetScheduler, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	s := &QuotaResetScheduler{
+		rules:     rules,
+		balances:  balances,
+		keys:      keys,
+		loc:       loc,
+		statePath: statePath,
+		applied:   make(map[string]time.Time),
+		now:       time.Now,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Run applies due rules immediately and then once a minute until ctx is cancelled
+func (s *QuotaResetScheduler) Run(ctx context.Context) {
+	s.Tick()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Tick()
+		}
+	}
+}
+
+// Tick applies every rule whose current period has not been applied yet
+func (s *QuotaResetScheduler) Tick() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().In(s.loc)
+	changed := false
+	for _, rule := range s.rules {
+		start := rule.Period.Start(now)
+		if last, ok := s.applied[rule.name()]; ok && !last.Before(start) {
+			continue
+		}
+
+		for _, key := range s.targets(rule) {
+			if rule.TopUp {
+				s.balances.AddBalance(key, rule.Tokens)
+			} else {
+				s.balances.SetBalance(key, rule.Tokens)
+			}
+		}
+		s.applied[rule.name()] = start
+		changed = true
+		slog.Info("applied quota reset rule", "rule", rule.name(), "period", rule.Period, "period_start", start.Format(time.RFC3339))
+	}
+
+	if changed {
+		if err := s.save(); err != nil {
+			slog.Error("failed to persist quota reset state", "error", err)
+		}
+	}
+}
+
+// targets lists the API keys a rule applies to
+func (s *QuotaResetScheduler) targets(rule QuotaResetRule) []string {
+	if rule.Scope == "key" {
+		return []string{rule.ID}
+	}
+	var keys []string
+	for _, info := range s.keys.Plan(rule.ID) {
+		keys = append(keys, info.Key)
+	}
+	return keys
+}
+
+// load reads the applied periods from statePath, if it exists
+func (s *QuotaResetScheduler) load() error {
+	if s.statePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.statePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read quota reset state: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.applied); err != nil {
+		return fmt.Errorf("failed to parse quota reset state: %w", err)
+	}
+	return nil
+}
+
+// save writes the applied periods atomically (write to a temp file, then rename)
+func (s *QuotaResetScheduler) save() error {
+	if s.statePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.applied, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.statePath)
+}
+
+// ParseQuotaResetRules parses "scope:id=mode:tokens/period;..."
+// e.g. "key:test-key-123=reset:100000/monthly;plan:free=topup:10000/daily"
+func ParseQuotaResetRules(spec string) ([]QuotaResetRule, error) {
+	var rules []QuotaResetRule
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		target, rule, ok := strings.Cut(entry, "=")
+		scope, id, ok2 := strings.Cut(strings.TrimSpace(target), ":")
+		if !ok || !ok2 || (scope != "key" && scope != "plan") || id == "" {
+			return nil, fmt.Errorf("invalid quota reset %q: expected key:<api-key> or plan:<plan>=mode:tokens/period", entry)
+		}
+		mode, amount, ok := strings.Cut(strings.TrimSpace(rule), ":")
+		if !ok || (mode != "reset" && mode != "topup") {
+			return nil, fmt.Errorf("invalid quota reset %q: mode must be reset or topup", entry)
+		}
+		tokens, periodName, ok := strings.Cut(amount, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid quota reset %q: expected mode:tokens/period", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(tokens))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid quota reset %q: tokens must be a non-negative integer", entry)
+		}
+		period, err := ParseResetPeriod(periodName)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quota reset %q: %w", entry, err)
+		}
+
+		rules = append(rules, QuotaResetRule{Scope: scope, ID: id, TopUp: mode == "topup", Tokens: n, Period: period})
+	}
+	return rules, nil
+}
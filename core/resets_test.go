@@ -0,0 +1,46 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQuotaResetScheduler_PersistedPeriods(t *testing.T) {
+	keys, _ := ParseKeyDirectory("key-a=acme/free;key-b=acme/free;key-c=acme/pro")
+	rules, err := ParseQuotaResetRules("plan:free=topup:10/daily;key:key-c=reset:500/monthly")
+	if err != nil {
+		t.Fatalf("ParseQuotaResetRules failed: %v", err)
+	}
+	statePath := filepath.Join(t.TempDir(), "resets.json")
+	now := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+
+	balances := NewInMemoryRateLimiter()
+	newScheduler := func() *QuotaResetScheduler {
+		s, err := NewQuotaResetScheduler(rules, balances, keys, time.UTC, statePath)
+		if err != nil {
+			t.Fatalf("NewQuotaResetScheduler failed: %v", err)
+		}
+		s.now = func() time.Time { return now }
+		return s
+	}
+
+	newScheduler().Tick()
+	if balances.balances["key-a"] != 10 || balances.balances["key-b"] != 10 || balances.balances["key-c"] != 500 {
+		t.Fatalf("Unexpected balances after first tick: %v", balances.balances)
+	}
+
+	// 模拟重启：同一周期内不得重复执行
+	newScheduler().Tick()
+	if balances.balances["key-a"] != 10 {
+		t.Errorf("Expected restart not to top up twice, got %d", balances.balances["key-a"])
+	}
+
+	// 跨日后只执行日周期规则
+	now = now.Add(24 * time.Hour)
+	balances.SetBalance("key-c", 42)
+	newScheduler().Tick()
+	if balances.balances["key-a"] != 20 || balances.balances["key-c"] != 42 {
+		t.Errorf("Unexpected balances on next day: %v", balances.balances)
+	}
+}
@@ -0,0 +1,209 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session store defaults
+const (
+	DefaultSessionTTL      = 24 * time.Hour
+	DefaultMaxSessions     = 10000
+	DefaultMaxSessionBytes = 1 << 20
+)
+
+// ErrSessionTooLarge is returned when a session value exceeds the store's size cap
+var ErrSessionTooLarge = errors.New("session exceeds the size limit")
+
+// SessionStore keeps conversation state (threads, affinity bindings) keyed by session ID
+// Values are opaque bytes and expire after their TTL; a persistent backend keeps them across gateway restarts
+type SessionStore interface {
+	// Get returns the value of a session, false if it does not exist or has expired
+	Get(ctx context.Context, id string) ([]byte, bool, error)
+	// Put stores a session, replacing any previous value; ttl <= 0 uses the store's default TTL
+	Put(ctx context.Context, id string, value []byte, ttl time.Duration) error
+	// Delete removes a session; deleting a missing session is not an error
+	Delete(ctx context.Context, id string) error
+}
+
+// SessionLimits caps the sessions kept by a store
+type SessionLimits struct {
+	// TTL is the default lifetime of a session
+	TTL time.Duration
+	// MaxSessions is the largest number of sessions held in memory (the Redis backend relies on maxmemory instead)
+	MaxSessions int
+	// MaxBytes is the largest value accepted for one session
+	MaxBytes int
+}
+
+// DefaultSessionLimits returns the default session limits
+func DefaultSessionLimits() SessionLimits {
+	return SessionLimits{TTL: DefaultSessionTTL, MaxSessions: DefaultMaxSessions, MaxBytes: DefaultMaxSessionBytes}
+}
+
+func (l SessionLimits) withDefaults() SessionLimits {
+	def := DefaultSessionLimits()
+	if l.TTL <= 0 {
+		l.TTL = def.TTL
+	}
+	if l.MaxSessions <= 0 {
+		l.MaxSessions = def.MaxSessions
+	}
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = def.MaxBytes
+	}
+	return l
+}
+
+func (l SessionLimits) ttl(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return l.TTL
+	}
+	return ttl
+}
+
+func (l SessionLimits) check(value []byte) error {
+	if len(value) > l.MaxBytes {
+		return fmt.Errorf("%w of %d bytes", ErrSessionTooLarge, l.MaxBytes)
+	}
+	return nil
+}
+
+// OpenSessionStore creates a store from a spec: "memory" (default when empty) or a redis:// URL
+// A SQLite backend is planned as a follow-up (see the README roadmap) and rejected until then
+func OpenSessionStore(spec string, limits SessionLimits) (SessionStore, error) {
+	switch {
+	case strings.HasPrefix(spec, "sqlite:"):
+		return nil, fmt.Errorf("unsupported session store %q: the SQLite backend is not implemented yet, use memory or redis://host:port/db", spec)
+	case spec == "" || spec == "memory":
+		return NewMemorySessionStore(limits), nil
+	case strings.HasPrefix(spec, "redis://"):
+		client, err := NewRedisClient(spec)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisSessionStore(client, "zam:session:", limits), nil
+	}
+	return nil, fmt.Errorf("unsupported session store %q: expected memory or redis://host:port/db", spec)
+}
+
+type memorySession struct {
+	value   []byte
+	expires time.Time
+}
+
+// MemorySessionStore keeps sessions in process memory; they are lost on restart
+// When full, the session closest to expiry is evicted
+type MemorySessionStore struct {
+	limits SessionLimits
+
+	mu       sync.Mutex
+	sessions map[string]memorySession
+	now      func() time.Time
+}
+
+// NewMemorySessionStore creates an in-memory store
+func NewMemorySessionStore(limits SessionLimits) *MemorySessionStore {
+	return &MemorySessionStore{
+		limits:   limits.withDefaults(),
+		sessions: make(map[string]memorySession),
+		now:      time.Now,
+	}
+}
+
+func (s *MemorySessionStore) Get(_ context.Context, id string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, false, nil
+	}
+	if !s.now().Before(session.expires) {
+		delete(s.sessions, id)
+		return nil, false, nil
+	}
+	return append([]byte(nil), session.value...), true, nil
+}
+
+func (s *MemorySessionStore) Put(_ context.Context, id string, value []byte, ttl time.Duration) error {
+	if err := s.limits.check(value); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if _, exists := s.sessions[id]; !exists && len(s.sessions) >= s.limits.MaxSessions {
+		s.evict(now)
+	}
+	s.sessions[id] = memorySession{value: append([]byte(nil), value...), expires: now.Add(s.limits.ttl(ttl))}
+	return nil
+}
+
+func (s *MemorySessionStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+// evict drops expired sessions, or the one expiring soonest when none has expired
+func (s *MemorySessionStore) evict(now time.Time) {
+	var oldest string
+	var oldestExpires time.Time
+	for id, session := range s.sessions {
+		if !now.Before(session.expires) {
+			delete(s.sessions, id)
+			continue
+		}
+		if oldest == "" || session.expires.Before(oldestExpires) {
+			oldest, oldestExpires = id, session.expires
+		}
+	}
+	if len(s.sessions) >= s.limits.MaxSessions && oldest != "" {
+		delete(s.sessions, oldest)
+	}
+}
+
+// RedisSessionStore keeps sessions in Redis with native key expiry, so they survive gateway restarts
+// and are shared by every gateway instance
+type RedisSessionStore struct {
+	client *RedisClient
+	prefix string
+	limits SessionLimits
+}
+
+// NewRedisSessionStore creates a store whose keys are prefix+session ID
+func NewRedisSessionStore(client *RedisClient, prefix string, limits SessionLimits) *RedisSessionStore {
+	return &RedisSessionStore{client: client, prefix: prefix, limits: limits.withDefaults()}
+}
+
+func (s *RedisSessionStore) Get(ctx context.Context, id string) ([]byte, bool, error) {
+	reply, err := s.client.Do(ctx, "GET", s.prefix+id)
+	if err != nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	return value, ok, nil
+}
+
+func (s *RedisSessionStore) Put(ctx context.Context, id string, value []byte, ttl time.Duration) error {
+	if err := s.limits.check(value); err != nil {
+		return err
+	}
+	ms := s.limits.ttl(ttl).Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	_, err := s.client.Do(ctx, "SET", s.prefix+id, string(value), "PX", strconv.FormatInt(ms, 10))
+	return err
+}
+
+func (s *RedisSessionStore) Delete(ctx context.Context, id string) error {
+	_, err := s.client.Do(ctx, "DEL", s.prefix+id)
+	return err
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemorySessionStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore(SessionLimits{TTL: time.Minute, MaxSessions: 2, MaxBytes: 8})
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	if err := store.Put(ctx, "a", []byte("hello"), 0); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if value, ok, _ := store.Get(ctx, "a"); !ok || string(value) != "hello" {
+		t.Fatalf("Get = (%q, %v), want hello", value, ok)
+	}
+	if err := store.Put(ctx, "big", []byte("0123456789"), 0); !errors.Is(err, ErrSessionTooLarge) {
+		t.Fatalf("expected ErrSessionTooLarge, got %v", err)
+	}
+
+	// 超出容量时淘汰最早过期的会话
+	store.Put(ctx, "b", []byte("b"), time.Hour)
+	store.Put(ctx, "c", []byte("c"), time.Hour)
+	if _, ok, _ := store.Get(ctx, "a"); ok {
+		t.Error("session closest to expiry should be evicted")
+	}
+	if _, ok, _ := store.Get(ctx, "b"); !ok {
+		t.Error("session b should be kept")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, ok, _ := store.Get(ctx, "c"); ok {
+		t.Error("session should expire after its TTL")
+	}
+	store.Delete(ctx, "b")
+	if len(store.sessions) != 0 {
+		t.Errorf("expected no sessions left, got %d", len(store.sessions))
+	}
+}
+
+// fakeRedis serves GET/MGET/SET/DEL, HSET/HGETALL/HDEL and SADD/SMEMBERS/SREM from maps,
+// recording the PX argument of SET; keys never expire
+type fakeRedis struct {
+	mu     sync.Mutex
+	data   map[string]string
+	px     map[string]string
+	hashes map[string]map[string]string
+	sets   map[string]map[string]bool
+}
+
+func startFakeRedis(t *testing.T) (*fakeRedis, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	srv := &fakeRedis{data: map[string]string{}, px: map[string]string{}, hashes: map[string]map[string]string{}, sets: map[string]map[string]bool{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv, "redis://" + ln.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		reply, err := readRESP(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, item := range reply.([]interface{}) {
+			args = append(args, string(item.([]byte)))
+		}
+		f.mu.Lock()
+		switch args[0] {
+		case "GET":
+			if v, ok := f.data[args[1]]; ok {
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(v), v)
+			} else {
+				fmt.Fprint(conn, "$-1\r\n")
+			}
+		case "MGET":
+			fmt.Fprintf(conn, "*%d\r\n", len(args)-1)
+			for _, key := range args[1:] {
+				if v, ok := f.data[key]; ok {
+					fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(v), v)
+				} else {
+					fmt.Fprint(conn, "$-1\r\n")
+				}
+			}
+		case "SET":
+			if _, exists := f.data[args[1]]; exists && len(args) > 3 && args[len(args)-1] == "NX" {
+				fmt.Fprint(conn, "$-1\r\n")
+				break
+			}
+			f.data[args[1]] = args[2]
+			if len(args) >= 5 && args[3] == "PX" {
+				f.px[args[1]] = args[4]
+			}
+			fmt.Fprint(conn, "+OK\r\n")
+		case "DEL":
+			delete(f.data, args[1])
+			fmt.Fprint(conn, ":1\r\n")
+		case "HSET":
+			if f.hashes[args[1]] == nil {
+				f.hashes[args[1]] = map[string]string{}
+			}
+			f.hashes[args[1]][args[2]] = args[3]
+			fmt.Fprint(conn, ":1\r\n")
+		case "HGETALL":
+			hash := f.hashes[args[1]]
+			fmt.Fprintf(conn, "*%d\r\n", 2*len(hash))
+			for field, v := range hash {
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n$%d\r\n%s\r\n", len(field), field, len(v), v)
+			}
+		case "HDEL":
+			delete(f.hashes[args[1]], args[2])
+			fmt.Fprint(conn, ":1\r\n")
+		case "SADD":
+			if f.sets[args[1]] == nil {
+				f.sets[args[1]] = map[string]bool{}
+			}
+			f.sets[args[1]][args[2]] = true
+			fmt.Fprint(conn, ":1\r\n")
+		case "SMEMBERS":
+			set := f.sets[args[1]]
+			fmt.Fprintf(conn, "*%d\r\n", len(set))
+			for member := range set {
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(member), member)
+			}
+		case "SREM":
+			delete(f.sets[args[1]], args[2])
+			fmt.Fprint(conn, ":1\r\n")
+		default:
+			fmt.Fprintf(conn, "-ERR unknown command '%s'\r\n", args[0])
+		}
+		f.mu.Unlock()
+	}
+}
+
+func TestRedisSessionStore(t *testing.T) {
+	srv, url := startFakeRedis(t)
+	store, err := OpenSessionStore(url, SessionLimits{MaxBytes: 16})
+	if err != nil {
+		t.Fatalf("OpenSessionStore: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "s1", []byte("state\r\nwith crlf"), 1500*time.Millisecond); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	srv.mu.Lock()
+	px := srv.px["zam:session:s1"]
+	srv.mu.Unlock()
+	if px != "1500" {
+		t.Errorf("expected PX 1500, got %q", px)
+	}
+	if value, ok, err := store.Get(ctx, "s1"); err != nil || !ok || string(value) != "state\r\nwith crlf" {
+		t.Fatalf("Get = (%q, %v, %v)", value, ok, err)
+	}
+	if err := store.Put(ctx, "s2", make([]byte, 17), 0); !errors.Is(err, ErrSessionTooLarge) {
+		t.Fatalf("expected ErrSessionTooLarge, got %v", err)
+	}
+	store.Delete(ctx, "s1")
+	if _, ok, err := store.Get(ctx, "s1"); ok || err != nil {
+		t.Fatalf("deleted session should be missing, got (%v, %v)", ok, err)
+	}
+
+	client := store.(*RedisSessionStore).client
+	if _, err := client.Do(ctx, "FLUSHALL"); !errors.As(err, new(RedisError)) {
+		t.Errorf("expected a RedisError, got %v", err)
+	}
+}
+
+func TestOpenSessionStore_Invalid(t *testing.T) {
+	for _, spec := range []string{"sqlite:///tmp/s.db", "redis://", "redis://host/x"} {
+		if _, err := OpenSessionStore(spec, SessionLimits{}); err == nil {
+			t.Errorf("OpenSessionStore(%q) should fail", spec)
+		}
+	}
+}
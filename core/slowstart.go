@@ -0,0 +1,83 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultSlowStartWindow is how long a returning worker takes to reach its full traffic share
+const DefaultSlowStartWindow = 2 * time.Minute
+
+// DefaultSlowStartMinShare is the traffic share a returning worker starts from
+const DefaultSlowStartMinShare = 0.1
+
+// SlowStart ramps the traffic share of workers that come back after being removed
+// (heartbeat expiry, deregistration or quarantine) from MinShare to 1 over Window,
+// so a node still warming its model weights is not overloaded again right away
+type SlowStart struct {
+	Window   time.Duration
+	MinShare float64
+
+	mu sync.Mutex
+	// removed holds workers that left the pool; they ramp when they join again
+	removed map[string]bool
+	// ramping maps workers to the start of their ramp
+	ramping map[string]time.Time
+	now     func() time.Time
+}
+
+// NewSlowStart creates a SlowStart with the given ramp window
+func NewSlowStart(window time.Duration) *SlowStart {
+	if window <= 0 {
+		window = DefaultSlowStartWindow
+	}
+	return &SlowStart{
+		Window:   window,
+		MinShare: DefaultSlowStartMinShare,
+		removed:  make(map[string]bool),
+		ramping:  make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// Subscribe starts a ramp whenever a removed worker rejoins or a quarantined worker is readmitted
+func (s *SlowStart) Subscribe(bus *EventBus) {
+	bus.Subscribe(func(e Event) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch e.Type {
+		case EventWorkerExpired, EventWorkerDeregistered:
+			s.removed[e.WorkerID] = true
+			delete(s.ramping, e.WorkerID)
+		case EventWorkerJoined:
+			// 首次注册的 Worker 直接全量，只有"回归"的 Worker 需要慢启动
+			if s.removed[e.WorkerID] {
+				delete(s.removed, e.WorkerID)
+				s.ramping[e.WorkerID] = s.now()
+			}
+		case EventWorkerReadmitted:
+			s.ramping[e.WorkerID] = s.now()
+		}
+	})
+}
+
+// Share returns the fraction of its normal traffic a worker should receive, in (0, 1]
+// A nil SlowStart always returns 1
+func (s *SlowStart) Share(workerID string) float64 {
+	if s == nil {
+		return 1
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start, ok := s.ramping[workerID]
+	if !ok {
+		return 1
+	}
+	elapsed := s.now().Sub(start)
+	if elapsed >= s.Window {
+		delete(s.ramping, workerID)
+		return 1
+	}
+	return s.MinShare + (1-s.MinShare)*float64(elapsed)/float64(s.Window)
+}
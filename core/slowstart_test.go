@@ -0,0 +1,46 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlowStart_RampsReturningWorkers(t *testing.T) {
+	bus := NewEventBus()
+	s := NewSlowStart(100 * time.Second)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	s.Subscribe(bus)
+
+	// 首次加入的 Worker 不需要慢启动
+	bus.Publish(Event{Type: EventWorkerJoined, WorkerID: "gpu-1"})
+	if share := s.Share("gpu-1"); share != 1 {
+		t.Errorf("expected full share for a new worker, got %v", share)
+	}
+
+	bus.Publish(Event{Type: EventWorkerExpired, WorkerID: "gpu-1"})
+	bus.Publish(Event{Type: EventWorkerJoined, WorkerID: "gpu-1"})
+	if share := s.Share("gpu-1"); share != DefaultSlowStartMinShare {
+		t.Errorf("expected ramp to start at %v, got %v", DefaultSlowStartMinShare, share)
+	}
+
+	now = now.Add(50 * time.Second)
+	if share := s.Share("gpu-1"); share < 0.54 || share > 0.56 {
+		t.Errorf("expected share ~0.55 halfway through the ramp, got %v", share)
+	}
+
+	now = now.Add(50 * time.Second)
+	if share := s.Share("gpu-1"); share != 1 {
+		t.Errorf("expected full share after the window, got %v", share)
+	}
+
+	bus.Publish(Event{Type: EventWorkerReadmitted, WorkerID: "gpu-2"})
+	if share := s.Share("gpu-2"); share != DefaultSlowStartMinShare {
+		t.Errorf("expected readmitted worker to ramp, got %v", share)
+	}
+
+	var disabled *SlowStart
+	if disabled.Share("gpu-2") != 1 {
+		t.Error("nil SlowStart must not throttle")
+	}
+}
@@ -0,0 +1,234 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResetPeriod is a calendar period after which accumulated usage starts over
+type ResetPeriod string
+
+const (
+	ResetDaily   ResetPeriod = "daily"
+	ResetWeekly  ResetPeriod = "weekly"
+	ResetMonthly ResetPeriod = "monthly"
+)
+
+// Start returns the beginning of the period containing t, in t's location
+// Weeks start on Monday
+func (p ResetPeriod) Start(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch p {
+	case ResetDaily:
+		return day
+	case ResetWeekly:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// Next returns the beginning of the period following the one containing t
+func (p ResetPeriod) Next(t time.Time) time.Time {
+	start := p.Start(t)
+	switch p {
+	case ResetDaily:
+		return start.AddDate(0, 0, 1)
+	case ResetWeekly:
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+// ParseResetPeriod validates a reset period name
+func ParseResetPeriod(s string) (ResetPeriod, error) {
+	switch p := ResetPeriod(strings.ToLower(strings.TrimSpace(s))); p {
+	case ResetDaily, ResetWeekly, ResetMonthly:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid reset period %q: expected daily, weekly or monthly", s)
+}
+
+// ErrSpendCapExceeded is returned by SpendCapLimiter.Allow once a cap is used up
+var ErrSpendCapExceeded = errors.New("spend cap exceeded")
+
+// SpendCapError tells which cap blocked the request and when it resets
+type SpendCapError struct {
+	// Scope is "key" or "org"
+	Scope   string
+	ID      string
+	ResetAt time.Time
+}
+
+func (e *SpendCapError) Error() string {
+	return fmt.Sprintf("%s spend cap exceeded, resets at %s", e.Scope, e.ResetAt.Format(time.RFC3339))
+}
+
+func (e *SpendCapError) Unwrap() error {
+	return ErrSpendCapExceeded
+}
+
+// SpendCap is a hard token budget for a key or an organization
+type SpendCap struct {
+	Scope  string // "key" or "org"
+	ID     string
+	Tokens int64
+	Period ResetPeriod
+}
+
+// capUsage is the usage accumulated in the current period of one cap
+type capUsage struct {
+	periodStart time.Time
+	tokens      int64
+}
+
+// SpendCapLimiter layers per-key and per-org spend caps over another RateLimiter
+// Usage is counted per calendar period in the configured time zone and starts over at each boundary
+type SpendCapLimiter struct {
+	next RateLimiter
+	keys *KeyDirectory
+	loc  *time.Location
+
+	mu    sync.Mutex
+	caps  map[string]SpendCap // "scope:id" -> cap
+	usage map[string]*capUsage
+	now   func() time.Time
+}
+
+// NewSpendCapLimiter wraps next with the given caps; keys resolves API keys to organizations
+func NewSpendCapLimiter(next RateLimiter, keys *KeyDirectory, caps []SpendCap, loc *time.Location) *SpendCapLimiter {
+	if loc == nil {
+		loc = time.UTC
+	}
+	l := &SpendCapLimiter{
+		next:  next,
+		keys:  keys,
+		loc:   loc,
+		caps:  make(map[string]SpendCap),
+		usage: make(map[string]*capUsage),
+		now:   time.Now,
+	}
+	for _, c := range caps {
+		l.caps[c.Scope+":"+c.ID] = c
+	}
+	return l
+}
+
+// Allow rejects requests whose key or org has used up its cap, then defers to the wrapped limiter
+func (l *SpendCapLimiter) Allow(ctx context.Context, apiKey string) (bool, error) {
+	info := l.keys.Lookup(apiKey)
+
+	l.mu.Lock()
+	for _, scope := range l.scopes(info) {
+		c, ok := l.caps[scope]
+		if !ok {
+			continue
+		}
+		if l.usedLocked(scope, c) >= c.Tokens {
+			l.mu.Unlock()
+			return false, &SpendCapError{
+				Scope:   c.Scope,
+				ID:      c.ID,
+				ResetAt: c.Period.Next(l.now().In(l.loc)),
+			}
+		}
+	}
+	l.mu.Unlock()
+
+	return l.next.Allow(ctx, apiKey)
+}
+
+// Consume records the usage against every cap covering the key, then defers to the wrapped limiter
+func (l *SpendCapLimiter) Consume(ctx context.Context, apiKey string, actualTokens int) error {
+	info := l.keys.Lookup(apiKey)
+
+	l.mu.Lock()
+	for _, scope := range l.scopes(info) {
+		if c, ok := l.caps[scope]; ok {
+			l.usedLocked(scope, c)
+			l.usage[scope].tokens += int64(actualTokens)
+		}
+	}
+	l.mu.Unlock()
+
+	return l.next.Consume(ctx, apiKey, actualTokens)
+}
+
+// Usage returns the tokens used in the current period of a cap, e.g. Usage("org", "acme")
+func (l *SpendCapLimiter) Usage(scope, id string) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := scope + ":" + id
+	c, ok := l.caps[key]
+	if !ok {
+		return 0
+	}
+	return l.usedLocked(key, c)
+}
+
+// scopes lists the cap keys that apply to a key
+func (l *SpendCapLimiter) scopes(info KeyInfo) []string {
+	scopes := []string{"key:" + info.Key}
+	if info.Org != "" {
+		scopes = append(scopes, "org:"+info.Org)
+	}
+	return scopes
+}
+
+// usedLocked returns the usage of the current period, starting a new period when a boundary was crossed
+// Caller must hold l.mu
+func (l *SpendCapLimiter) usedLocked(scope string, c SpendCap) int64 {
+	start := c.Period.Start(l.now().In(l.loc))
+	u, ok := l.usage[scope]
+	if !ok {
+		u = &capUsage{periodStart: start}
+		l.usage[scope] = u
+	}
+	if !u.periodStart.Equal(start) {
+		u.periodStart, u.tokens = start, 0
+	}
+	return u.tokens
+}
+
+// ParseSpendCaps parses "scope:id=tokens[/period];..." e.g. "key:test-key-123=100000;org:acme=5000000/monthly"
+// The period defaults to monthly
+func ParseSpendCaps(spec string) ([]SpendCap, error) {
+	var caps []SpendCap
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		target, rule, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid spend cap %q: expected scope:id=tokens[/period]", entry)
+		}
+		scope, id, ok := strings.Cut(strings.TrimSpace(target), ":")
+		if !ok || (scope != "key" && scope != "org") || id == "" {
+			return nil, fmt.Errorf("invalid spend cap %q: scope must be key:<api-key> or org:<org>", entry)
+		}
+
+		limit, periodName, hasPeriod := strings.Cut(rule, "/")
+		tokens, err := strconv.ParseInt(strings.TrimSpace(limit), 10, 64)
+		if err != nil || tokens <= 0 {
+			return nil, fmt.Errorf("invalid spend cap %q: tokens must be a positive integer", entry)
+		}
+		period := ResetMonthly
+		if hasPeriod {
+			if period, err = ParseResetPeriod(periodName); err != nil {
+				return nil, fmt.Errorf("invalid spend cap %q: %w", entry, err)
+			}
+		}
+
+		caps = append(caps, SpendCap{Scope: scope, ID: id, Tokens: tokens, Period: period})
+	}
+	return caps, nil
+}
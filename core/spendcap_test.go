@@ -0,0 +1,59 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSpendCapLimiter_CutoffAndReset(t *testing.T) {
+	ctx := context.Background()
+	keys, err := ParseKeyDirectory("test-key-123=acme")
+	if err != nil {
+		t.Fatalf("ParseKeyDirectory failed: %v", err)
+	}
+	caps, err := ParseSpendCaps("org:acme=50/monthly")
+	if err != nil {
+		t.Fatalf("ParseSpendCaps failed: %v", err)
+	}
+
+	limiter := NewSpendCapLimiter(NewInMemoryRateLimiter(), keys, caps, time.UTC)
+	now := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	if ok, err := limiter.Allow(ctx, "test-key-123"); !ok || err != nil {
+		t.Fatalf("Expected first request to be allowed, got %v, %v", ok, err)
+	}
+	limiter.Consume(ctx, "test-key-123", 60)
+
+	// 本月额度已用尽
+	_, err = limiter.Allow(ctx, "test-key-123")
+	var capErr *SpendCapError
+	if !errors.As(err, &capErr) || capErr.Scope != "org" {
+		t.Fatalf("Expected org SpendCapError, got %v", err)
+	}
+	if want := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC); !capErr.ResetAt.Equal(want) {
+		t.Errorf("Expected reset at %v, got %v", want, capErr.ResetAt)
+	}
+
+	// 跨月后自动恢复
+	now = now.Add(2 * time.Hour)
+	if ok, err := limiter.Allow(ctx, "test-key-123"); !ok || err != nil {
+		t.Errorf("Expected request to be allowed after reset, got %v, %v", ok, err)
+	}
+	if used := limiter.Usage("org", "acme"); used != 0 {
+		t.Errorf("Expected usage to start over, got %d", used)
+	}
+}
+
+func TestResetPeriod_Weekly(t *testing.T) {
+	// 2026-10-15 是周四，周期从周一开始
+	ts := time.Date(2026, 10, 15, 13, 30, 0, 0, time.UTC)
+	if got, want := ResetWeekly.Start(ts), time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Start() = %v, want %v", got, want)
+	}
+	if got, want := ResetWeekly.Next(ts), time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+}
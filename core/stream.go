@@ -0,0 +1,30 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+// ErrStreamTruncated is returned by workers whose upstream stream ended without a finish_reason or [DONE]
+var ErrStreamTruncated = errors.New("upstream stream ended without finish_reason or [DONE]")
+
+// FinishReasonTruncated is sent to clients whose stream was cut short by the upstream worker
+const FinishReasonTruncated = "truncated"
+
+// ErrWorkerPanic wraps a panic recovered while a worker executed a request or its chunks were processed
+var ErrWorkerPanic = errors.New("panic during stream processing")
+
+// SafeExecute runs w.Execute and turns a panic on the calling goroutine, in the worker or in
+// sender, into an error wrapping ErrWorkerPanic so the caller can still finish the response
+func SafeExecute(ctx context.Context, w Worker, req *InferenceRequest, sender func(chunk StreamChunk) error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			slog.Error("panic while executing on worker", "trace_id", req.TraceID, "worker_id", w.ID(), "panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+			err = fmt.Errorf("%w: %v", ErrWorkerPanic, p)
+		}
+	}()
+	return w.Execute(ctx, req, sender)
+}
@@ -0,0 +1,28 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSafeExecute_RecoversSenderPanic(t *testing.T) {
+	sent := 0
+	err := SafeExecute(context.Background(), captureTestWorker{}, &InferenceRequest{TraceID: "test-panic"}, func(chunk StreamChunk) error {
+		sent++
+		var m map[string]int
+		m[chunk.Content]++ // nil map write
+		return nil
+	})
+	if !errors.Is(err, ErrWorkerPanic) {
+		t.Fatalf("expected ErrWorkerPanic, got %v", err)
+	}
+	if sent != 1 {
+		t.Errorf("expected the panic on the first chunk, got %d chunks", sent)
+	}
+
+	err = SafeExecute(context.Background(), captureTestWorker{}, &InferenceRequest{TraceID: "test-ok"}, func(StreamChunk) error { return nil })
+	if err != nil {
+		t.Errorf("expected no error without a panic, got %v", err)
+	}
+}
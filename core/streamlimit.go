@@ -0,0 +1,52 @@
+package core
+
+import "sync"
+
+// StreamLimiter caps the concurrent SSE streams of each client IP, independently of API keys,
+// so a single misconfigured client cannot hold thousands of connections open
+type StreamLimiter struct {
+	max int
+
+	mu     sync.Mutex
+	active map[string]int
+}
+
+// NewStreamLimiter creates a StreamLimiter allowing max concurrent streams per IP
+func NewStreamLimiter(max int) *StreamLimiter {
+	return &StreamLimiter{max: max, active: make(map[string]int)}
+}
+
+// Acquire reserves a stream slot for ip and reports whether one was free
+// The returned release func must be called exactly once when the stream ends; a nil StreamLimiter admits everything
+func (l *StreamLimiter) Acquire(ip string) (release func(), ok bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[ip] >= l.max {
+		return nil, false
+	}
+	l.active[ip]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.active[ip]--; l.active[ip] <= 0 {
+				delete(l.active, ip)
+			}
+		})
+	}, true
+}
+
+// Active returns the number of open streams of ip
+func (l *StreamLimiter) Active(ip string) int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active[ip]
+}
@@ -0,0 +1,37 @@
+package core
+
+import "testing"
+
+func TestStreamLimiter(t *testing.T) {
+	limiter := NewStreamLimiter(2)
+
+	r1, ok1 := limiter.Acquire("10.0.0.1")
+	r2, ok2 := limiter.Acquire("10.0.0.1")
+	if !ok1 || !ok2 {
+		t.Fatal("streams within the limit should be admitted")
+	}
+	if _, ok := limiter.Acquire("10.0.0.1"); ok {
+		t.Fatal("third concurrent stream should be refused")
+	}
+	if _, ok := limiter.Acquire("10.0.0.2"); !ok {
+		t.Error("another IP should not be limited")
+	}
+
+	// 重复释放只生效一次
+	r1()
+	r1()
+	if got := limiter.Active("10.0.0.1"); got != 1 {
+		t.Fatalf("active = %d after one release, want 1", got)
+	}
+	if _, ok := limiter.Acquire("10.0.0.1"); !ok {
+		t.Error("a released slot should be reusable")
+	}
+	r2()
+
+	var nilLimiter *StreamLimiter
+	release, ok := nilLimiter.Acquire("10.0.0.1")
+	if !ok {
+		t.Fatal("nil limiter should admit everything")
+	}
+	release()
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultThrottleCoolDown is used when a throttled upstream sends no usable Retry-After
+const DefaultThrottleCoolDown = 10 * time.Second
+
+// MaxThrottleCoolDown caps the cool-down taken from a Retry-After header
+const MaxThrottleCoolDown = 5 * time.Minute
+
+// ThrottledError is returned by workers whose upstream refused the request with 429
+// (rate_limit_exceeded or insufficient_quota)
+type ThrottledError struct {
+	// Code is the upstream error code, e.g. "insufficient_quota"
+	Code string
+	// RetryAfter is the upstream's requested back-off (0 if it sent none)
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("upstream throttled the request (%s)", e.Code)
+	}
+	return "upstream throttled the request"
+}
+
+// ParseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+// It returns 0 when the header is missing or invalid
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// ThrottleTracker keeps throttled workers out of routing until their cool-down expires
+type ThrottleTracker struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+	now   func() time.Time
+}
+
+// NewThrottleTracker creates an empty ThrottleTracker
+func NewThrottleTracker() *ThrottleTracker {
+	return &ThrottleTracker{until: make(map[string]time.Time), now: time.Now}
+}
+
+// Throttle puts a worker into cool-down for retryAfter, clamped to (0, MaxThrottleCoolDown]
+// and returns the cool-down actually applied
+func (t *ThrottleTracker) Throttle(workerID string, retryAfter time.Duration) time.Duration {
+	if retryAfter <= 0 {
+		retryAfter = DefaultThrottleCoolDown
+	}
+	if retryAfter > MaxThrottleCoolDown {
+		retryAfter = MaxThrottleCoolDown
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	until := t.now().Add(retryAfter)
+	if until.After(t.until[workerID]) {
+		t.until[workerID] = until
+	}
+	return retryAfter
+}
+
+// CoolingDown reports whether the worker is still in cool-down
+func (t *ThrottleTracker) CoolingDown(workerID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	until, ok := t.until[workerID]
+	if !ok {
+		return false
+	}
+	if !t.now().Before(until) {
+		delete(t.until, workerID)
+		return false
+	}
+	return true
+}
+
+// Filter removes workers in cool-down; a nil tracker returns workers unchanged
+func (t *ThrottleTracker) Filter(workers []Worker) []Worker {
+	if t == nil {
+		return workers
+	}
+	filtered := make([]Worker, 0, len(workers))
+	for _, w := range workers {
+		if !t.CoolingDown(w.ID()) {
+			filtered = append(filtered, w)
+		}
+	}
+	return filtered
+}
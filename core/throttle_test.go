@@ -0,0 +1,52 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"-5", 0},
+		{"Fri, 16 Oct 2026 12:01:00 GMT", time.Minute},
+		{"Fri, 16 Oct 2026 11:59:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := ParseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("ParseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestThrottleTracker(t *testing.T) {
+	tracker := NewThrottleTracker()
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+
+	if got := tracker.Throttle("cloud-fallback", 0); got != DefaultThrottleCoolDown {
+		t.Errorf("expected default cool-down without Retry-After, got %v", got)
+	}
+	if got := tracker.Throttle("cloud-backup", time.Hour); got != MaxThrottleCoolDown {
+		t.Errorf("expected cool-down capped at %v, got %v", MaxThrottleCoolDown, got)
+	}
+
+	workers := []Worker{captureTestWorker{}}
+	if !tracker.CoolingDown("cloud-fallback") || len(tracker.Filter(workers)) != 1 {
+		t.Error("expected only the throttled workers to cool down")
+	}
+
+	now = now.Add(DefaultThrottleCoolDown)
+	if tracker.CoolingDown("cloud-fallback") {
+		t.Error("expected cool-down to expire")
+	}
+	if !tracker.CoolingDown("cloud-backup") {
+		t.Error("expected longer cool-down to still apply")
+	}
+}
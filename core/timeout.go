@@ -0,0 +1,39 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RequestTimeoutHeader lets a client bound how long the gateway works on its request, in milliseconds
+const RequestTimeoutHeader = "X-Request-Timeout-Ms"
+
+// DefaultMaxRequestTimeout caps client-supplied timeouts unless configured otherwise
+const DefaultMaxRequestTimeout = 10 * time.Minute
+
+// TimeoutPolicy bounds the deadline of requests; zero values disable the respective limit
+type TimeoutPolicy struct {
+	// Default applies to requests without the header
+	Default time.Duration
+	// Max caps both the header and Default
+	Max time.Duration
+}
+
+// Resolve returns the timeout of a request from its RequestTimeoutHeader value, capped at Max
+// Zero means no deadline: requests without the header and without a Default run unbounded
+func (p TimeoutPolicy) Resolve(header string) (time.Duration, error) {
+	timeout := p.Default
+	if header = strings.TrimSpace(header); header != "" {
+		ms, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || ms <= 0 {
+			return 0, fmt.Errorf("invalid %s %q: expected a positive number of milliseconds", RequestTimeoutHeader, header)
+		}
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	if p.Max > 0 && timeout > p.Max {
+		timeout = p.Max
+	}
+	return timeout, nil
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeoutPolicy_Resolve(t *testing.T) {
+	policy := TimeoutPolicy{Default: 30 * time.Second, Max: time.Minute}
+	cases := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 30 * time.Second},
+		{"1500", 1500 * time.Millisecond},
+		// 超出服务端上限时截断
+		{"600000", time.Minute},
+	}
+	for _, tc := range cases {
+		got, err := policy.Resolve(tc.header)
+		if err != nil || got != tc.want {
+			t.Errorf("Resolve(%q) = %v, %v, want %v", tc.header, got, err, tc.want)
+		}
+	}
+	for _, header := range []string{"0", "-5", "1.5s", "soon"} {
+		if _, err := policy.Resolve(header); err == nil {
+			t.Errorf("Resolve(%q) should fail", header)
+		}
+	}
+
+	// 未配置默认值且未携带请求头时不设截止时间
+	if got, _ := (TimeoutPolicy{Max: time.Minute}).Resolve(""); got != 0 {
+		t.Errorf("Resolve without default = %v, want no deadline", got)
+	}
+}
@@ -0,0 +1,13 @@
+package core
+
+import (
+	"context"
+
+	"zam/openai"
+)
+
+// Tokenizer is implemented by workers whose backend can tokenize a conversation with the model's own tokenizer
+type Tokenizer interface {
+	// Tokenize returns the token IDs of messages rendered with model's chat template
+	Tokenize(ctx context.Context, model string, messages []openai.Message) ([]int, error)
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UserLimitPolicy caps how many requests one end user (OpenAI "user") of a key may send per Window
+type UserLimitPolicy struct {
+	Requests int
+	Window   time.Duration
+}
+
+// ParseUserLimitPolicy parses "requests/window", e.g. "60/1m"
+func ParseUserLimitPolicy(spec string) (UserLimitPolicy, error) {
+	requests, window, ok := strings.Cut(strings.TrimSpace(spec), "/")
+	if !ok {
+		return UserLimitPolicy{}, fmt.Errorf("invalid user rate limit %q: expected requests/window", spec)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n <= 0 {
+		return UserLimitPolicy{}, fmt.Errorf("invalid user rate limit %q: requests must be a positive integer", spec)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || d <= 0 {
+		return UserLimitPolicy{}, fmt.Errorf("invalid user rate limit %q: window must be a positive duration", spec)
+	}
+	return UserLimitPolicy{Requests: n, Window: d}, nil
+}
+
+// userWindow counts the requests of one key+user in the current window
+type userWindow struct {
+	start time.Time
+	count int
+}
+
+// UserLimiter is a secondary rate limit per end user within an API key, so one abusive user
+// of a shared application key cannot exhaust it for everyone else
+// Requests without a user are not limited here
+type UserLimiter struct {
+	policy UserLimitPolicy
+
+	mu      sync.Mutex
+	windows map[string]*userWindow
+	pruned  time.Time
+	now     func() time.Time
+}
+
+// NewUserLimiter creates a UserLimiter with the given policy
+func NewUserLimiter(policy UserLimitPolicy) *UserLimiter {
+	return &UserLimiter{policy: policy, windows: make(map[string]*userWindow), now: time.Now}
+}
+
+// Allow counts one request of user under apiKey and reports whether it is within the limit
+// When refused it also returns how long until the window resets; a nil UserLimiter admits everything
+func (l *UserLimiter) Allow(apiKey, user string) (bool, time.Duration) {
+	if l == nil || user == "" {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	key := apiKey + "\x00" + user
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.policy.Window {
+		if now.Sub(l.pruned) >= l.policy.Window {
+			l.pruneLocked(now)
+		}
+		w = &userWindow{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= l.policy.Requests {
+		return false, w.start.Add(l.policy.Window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// pruneLocked drops expired windows, at most once per window, so the map does not grow with
+// every user ever seen; caller must hold l.mu
+func (l *UserLimiter) pruneLocked(now time.Time) {
+	l.pruned = now
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.policy.Window {
+			delete(l.windows, key)
+		}
+	}
+}
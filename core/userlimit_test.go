@@ -0,0 +1,63 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseUserLimitPolicy(t *testing.T) {
+	policy, err := ParseUserLimitPolicy("60/1m")
+	if err != nil {
+		t.Fatalf("ParseUserLimitPolicy: %v", err)
+	}
+	if policy.Requests != 60 || policy.Window != time.Minute {
+		t.Fatalf("policy = %+v, want 60/1m", policy)
+	}
+	for _, spec := range []string{"60", "0/1m", "x/1m", "60/soon", "60/-1s"} {
+		if _, err := ParseUserLimitPolicy(spec); err == nil {
+			t.Errorf("ParseUserLimitPolicy(%q) should fail", spec)
+		}
+	}
+}
+
+func TestUserLimiter(t *testing.T) {
+	limiter := NewUserLimiter(UserLimitPolicy{Requests: 2, Window: time.Minute})
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow("key-a", "alice"); !ok {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+	}
+	ok, retryAfter := limiter.Allow("key-a", "alice")
+	if ok || retryAfter != time.Minute {
+		t.Fatalf("third request = (%v, %v), want refused with 1m retry", ok, retryAfter)
+	}
+
+	// 其他用户、其他 Key 下的同名用户、未携带 user 的请求互不影响
+	if ok, _ := limiter.Allow("key-a", "bob"); !ok {
+		t.Error("another user should not be limited")
+	}
+	if ok, _ := limiter.Allow("key-b", "alice"); !ok {
+		t.Error("the same user under another key should not be limited")
+	}
+	for i := 0; i < 5; i++ {
+		if ok, _ := limiter.Allow("key-a", ""); !ok {
+			t.Fatal("requests without a user should not be limited")
+		}
+	}
+
+	now = now.Add(time.Minute)
+	if ok, _ := limiter.Allow("key-a", "alice"); !ok {
+		t.Error("limit should reset after the window")
+	}
+	if len(limiter.windows) != 1 {
+		t.Errorf("expired windows should be pruned, have %d", len(limiter.windows))
+	}
+
+	var nilLimiter *UserLimiter
+	if ok, _ := nilLimiter.Allow("key-a", "alice"); !ok {
+		t.Error("nil limiter should allow")
+	}
+}
@@ -0,0 +1,207 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the queue holding requests that found no worker with capacity
+const (
+	DefaultQueueSize    = 64
+	DefaultQueueTimeout = 10 * time.Second
+)
+
+// waitQueueRecheck re-runs routing periodically even without notifications, e.g. for workers
+// probed in the background or leaving quarantine
+const waitQueueRecheck = 500 * time.Millisecond
+
+var (
+	// ErrNoAvailableWorkers is returned by routers when no worker passes the filters for a request
+	ErrNoAvailableWorkers = errors.New("no available workers")
+	// ErrQueueFull is returned by WaitQueue.Wait when too many requests for the model are waiting
+	ErrQueueFull = errors.New("request queue is full")
+	// ErrQueueTimeout is returned by WaitQueue.Wait when no capacity freed up within the timeout
+	ErrQueueTimeout = errors.New("timed out waiting for a worker")
+)
+
+// WaitQueue parks requests that found no worker with capacity and retries their routing when
+// capacity may have freed up (a request finished, a heartbeat arrived), so short GPU saturation
+// spikes delay clients instead of bouncing them with 503. Each model has its own bounded queue.
+// Waiters retry one at a time, higher priorities first and then in arrival order, and a round of
+// retries stops at the first success so the freed capacity goes to the best-ranked request
+type WaitQueue struct {
+	size    int
+	timeout time.Duration
+
+	mu      sync.Mutex
+	waiting map[string]int // lower-cased model -> waiting requests
+	waiters []*waiter      // 按优先级从高到低、同优先级按到达顺序排列
+	seq     uint64
+	round   []*waiter // 本轮尚未重试的等待者，队首持有重试权；nil 表示没有进行中的一轮
+	pending bool      // 一轮进行中又收到通知，本轮结束后再发起一轮
+}
+
+type waiter struct {
+	priority Priority
+	seq      uint64
+	turn     chan struct{} // 收到信号时轮到该等待者重试
+}
+
+// outranks reports whether w retries before other
+func (w *waiter) outranks(other *waiter) bool {
+	if w.priority != other.priority {
+		return w.priority > other.priority
+	}
+	return w.seq < other.seq
+}
+
+// NewWaitQueue creates a queue admitting up to size waiting requests per model for at most timeout each
+func NewWaitQueue(size int, timeout time.Duration) *WaitQueue {
+	return &WaitQueue{
+		size:    size,
+		timeout: timeout,
+		waiting: make(map[string]int),
+	}
+}
+
+// Wait queues a request for model and calls retry on its turn until retry reports success.
+// It returns ErrQueueFull when the model's queue is full, ErrQueueTimeout after the queue timeout,
+// or ctx.Err() when the client gives up first. After a success the caller claims the capacity
+// (e.g. counts the request in flight) and then calls Notify so the remaining waiters retry
+func (q *WaitQueue) Wait(ctx context.Context, model string, priority Priority, retry func() bool) error {
+	model = strings.ToLower(model)
+	q.mu.Lock()
+	if q.waiting[model] >= q.size {
+		q.mu.Unlock()
+		return ErrQueueFull
+	}
+	q.waiting[model]++
+	q.seq++
+	w := &waiter{priority: priority, seq: q.seq, turn: make(chan struct{}, 1)}
+	at := len(q.waiters)
+	for i, other := range q.waiters {
+		if w.outranks(other) {
+			at = i
+			break
+		}
+	}
+	q.waiters = append(q.waiters[:at], append([]*waiter{w}, q.waiters[at:]...)...)
+	q.mu.Unlock()
+	defer q.leave(model, w)
+
+	// 入队即发起一轮重试，排在前面的请求先重试
+	q.Notify()
+
+	deadline := time.NewTimer(q.timeout)
+	defer deadline.Stop()
+	recheck := time.NewTicker(waitQueueRecheck)
+	defer recheck.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return ErrQueueTimeout
+		case <-recheck.C:
+			q.Notify()
+		case <-w.turn:
+			if retry() {
+				q.mu.Lock()
+				q.round, q.pending = nil, false
+				q.mu.Unlock()
+				return nil
+			}
+			q.mu.Lock()
+			q.passTurnLocked(w)
+			q.mu.Unlock()
+		}
+	}
+}
+
+// leave removes a waiter, handing its turn on if it held one
+func (q *WaitQueue) leave(model string, w *waiter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.waiting[model]--; q.waiting[model] == 0 {
+		delete(q.waiting, model)
+	}
+	q.waiters = removeWaiter(q.waiters, w)
+	q.passTurnLocked(w)
+}
+
+// passTurnLocked drops w from the current round and gives the turn to the next waiter if w held it
+func (q *WaitQueue) passTurnLocked(w *waiter) {
+	if len(q.round) == 0 || q.round[0] != w {
+		q.round = removeWaiter(q.round, w)
+		return
+	}
+	q.round = q.round[1:]
+	if len(q.round) > 0 {
+		q.round[0].turn <- struct{}{}
+		return
+	}
+	q.round = nil
+	if q.pending {
+		q.pending = false
+		q.startRoundLocked()
+	}
+}
+
+func (q *WaitQueue) startRoundLocked() {
+	if len(q.waiters) == 0 {
+		return
+	}
+	q.round = append([]*waiter(nil), q.waiters...)
+	q.round[0].turn <- struct{}{}
+}
+
+func removeWaiter(waiters []*waiter, w *waiter) []*waiter {
+	for i, other := range waiters {
+		if other == w {
+			return append(waiters[:i:i], waiters[i+1:]...)
+		}
+	}
+	return waiters
+}
+
+// Notify lets the waiting requests retry their routing, one at a time in priority order
+// A nil queue ignores the call
+func (q *WaitQueue) Notify() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.round != nil {
+		q.pending = true
+		return
+	}
+	q.startRoundLocked()
+}
+
+// Outranked reports whether requests of a higher priority than priority are waiting, in which case
+// a new request should queue behind them rather than take freed capacity first
+// A nil queue reports false
+func (q *WaitQueue) Outranked(priority Priority) bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiters) > 0 && q.waiters[0].priority > priority
+}
+
+// Waiting returns the number of queued requests per model
+func (q *WaitQueue) Waiting() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	waiting := make(map[string]int, len(q.waiting))
+	for model, n := range q.waiting {
+		waiting[model] = n
+	}
+	return waiting
+}
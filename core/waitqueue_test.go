@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitQueue_Notify(t *testing.T) {
+	q := NewWaitQueue(1, 5*time.Second)
+	var free atomic.Bool
+	done := make(chan error, 1)
+	go func() {
+		done <- q.Wait(context.Background(), "Llama-8B", PriorityNormal, func() bool { return free.Load() })
+	}()
+
+	// 等待者进入队列后，同一模型的队列已满（模型名大小写不敏感）
+	deadline := time.Now().Add(time.Second)
+	for q.Waiting()["llama-8b"] == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := q.Wait(context.Background(), "llama-8b", PriorityNormal, func() bool { return true }); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Wait on a full queue = %v, want ErrQueueFull", err)
+	}
+
+	// 容量释放后通知，等待者重新路由成功
+	free.Store(true)
+	q.Notify()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Wait = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after Notify")
+	}
+	if len(q.Waiting()) != 0 {
+		t.Errorf("Waiting = %v, want empty", q.Waiting())
+	}
+}
+
+func TestWaitQueue_Timeout(t *testing.T) {
+	q := NewWaitQueue(4, 20*time.Millisecond)
+	err := q.Wait(context.Background(), "llama-8b", PriorityNormal, func() bool { return false })
+	if !errors.Is(err, ErrQueueTimeout) {
+		t.Fatalf("Wait = %v, want ErrQueueTimeout", err)
+	}
+
+	// 客户端先放弃时返回 ctx 的错误
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewWaitQueue(4, time.Second).Wait(ctx, "llama-8b", PriorityNormal, func() bool { return false }); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait with a cancelled context = %v, want context.Canceled", err)
+	}
+}
+
+func TestWaitQueue_Priority(t *testing.T) {
+	q := NewWaitQueue(4, 5*time.Second)
+	var slots atomic.Int32
+	served := make(chan string, 2)
+	enter := func(name string, priority Priority, waiting int) {
+		go q.Wait(context.Background(), "llama-8b", priority, func() bool {
+			if slots.Add(-1) >= 0 {
+				served <- name
+				return true
+			}
+			slots.Add(1)
+			return false
+		})
+		deadline := time.Now().Add(time.Second)
+		for q.Waiting()["llama-8b"] < waiting && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	enter("batch", PriorityLow, 1)
+	if !q.Outranked(PriorityLow-1) || q.Outranked(PriorityLow) {
+		t.Error("expected only lower priorities to be outranked by a low-priority waiter")
+	}
+	enter("premium", PriorityHigh, 2)
+	if !q.Outranked(PriorityNormal) {
+		t.Error("expected normal requests to be outranked by a high-priority waiter")
+	}
+
+	// 释放一个槽位：先到的低优先级请求不能抢在高优先级请求之前
+	for _, want := range []string{"premium", "batch"} {
+		slots.Store(1)
+		q.Notify()
+		select {
+		case got := <-served:
+			if got != want {
+				t.Fatalf("served %s, want %s", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s was not served", want)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRateLimitPlan is the plan whose policy applies to keys whose own plan has none
+const DefaultRateLimitPlan = "default"
+
+// RateLimitPolicy caps the requests (RPM) and tokens (TPM) a key may use per minute; 0 leaves a dimension unlimited
+type RateLimitPolicy struct {
+	RPM int64
+	TPM int64
+}
+
+// RateLimitStatus is the state of a key's request and token windows, reported to clients as X-RateLimit-* headers
+// Limits of 0 mean the dimension is not limited
+type RateLimitStatus struct {
+	RequestLimit     int64
+	RequestRemaining int64
+	// RequestReset is how long until the request window is fully replenished
+	RequestReset   time.Duration
+	TokenLimit     int64
+	TokenRemaining int64
+	TokenReset     time.Duration
+}
+
+// RateLimitReporter is implemented by rate limiters that can report the window status of a key
+type RateLimitReporter interface {
+	RateLimitStatus(apiKey string) (RateLimitStatus, bool)
+}
+
+// ErrRateLimited is returned by WindowLimiter.Allow once a key exhausted its requests or tokens per minute
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimitError tells which window refused the request and when the client may retry
+type RateLimitError struct {
+	// Window is "requests" or "tokens"
+	Window     string
+	Limit      int64
+	RetryAfter time.Duration
+	Status     RateLimitStatus
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit of %d %s per minute exceeded, retry after %s", e.Limit, e.Window, e.RetryAfter)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// rateBucket is a token bucket refilled continuously at limit per minute, capped at limit
+type rateBucket struct {
+	level   float64
+	updated time.Time
+}
+
+// refill brings the bucket up to now
+func (b *rateBucket) refill(limit int64, now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.level += float64(limit) * elapsed.Minutes()
+	}
+	// 限额被调低时同样截断
+	if b.level > float64(limit) {
+		b.level = float64(limit)
+	}
+	b.updated = now
+}
+
+// until returns how long the bucket needs to refill to level
+func (b *rateBucket) until(limit int64, level float64) time.Duration {
+	if b.level >= level {
+		return 0
+	}
+	return time.Duration((level - b.level) / float64(limit) * float64(time.Minute))
+}
+
+// remaining returns the whole units left in the bucket
+func (b *rateBucket) remaining() int64 {
+	if b.level < 0 {
+		return 0
+	}
+	return int64(b.level)
+}
+
+// keyWindows holds the request and token buckets of one key
+type keyWindows struct {
+	requests rateBucket
+	tokens   rateBucket
+}
+
+// WindowLimiter layers per-key requests-per-minute and tokens-per-minute limits over another RateLimiter
+// Both windows are token buckets refilled continuously, so capacity frees up gradually rather than at a
+// fixed boundary. Token usage is only known once a request completes: a request is admitted while the
+// key has any tokens left, and Consume may drive the bucket negative; the debt is paid back by the refill
+type WindowLimiter struct {
+	next RateLimiter
+	keys *KeyDirectory
+	// policies maps plans to policies, swapped atomically at runtime
+	policies atomic.Pointer[map[string]RateLimitPolicy]
+
+	mu      sync.Mutex
+	windows map[string]*keyWindows
+	pruned  time.Time
+	now     func() time.Time
+}
+
+// NewWindowLimiter wraps next with RPM/TPM limits per plan; keys resolves API keys to plans
+// The DefaultRateLimitPlan policy applies to keys whose plan has no policy of its own
+func NewWindowLimiter(next RateLimiter, keys *KeyDirectory, policies map[string]RateLimitPolicy) *WindowLimiter {
+	l := &WindowLimiter{
+		next:    next,
+		keys:    keys,
+		windows: make(map[string]*keyWindows),
+		now:     time.Now,
+	}
+	l.policies.Store(&policies)
+	return l
+}
+
+// SetPolicies replaces the per-plan policies; requests already admitted are not re-checked
+// Keys keep their current bucket levels, capped at the new limits on their next refill
+func (l *WindowLimiter) SetPolicies(policies map[string]RateLimitPolicy) {
+	l.policies.Store(&policies)
+}
+
+// Allow refuses with a *RateLimitError when the key is out of requests or tokens for now, otherwise it
+// defers to the wrapped limiter. Only admitted requests count against the request window
+func (l *WindowLimiter) Allow(ctx context.Context, apiKey string) (bool, error) {
+	policy, ok := l.policy(apiKey)
+	if !ok {
+		return l.next.Allow(ctx, apiKey)
+	}
+
+	l.mu.Lock()
+	w := l.windowsLocked(apiKey, policy)
+	if policy.RPM > 0 && w.requests.level < 1 {
+		err := &RateLimitError{Window: "requests", Limit: policy.RPM, RetryAfter: w.requests.until(policy.RPM, 1), Status: l.statusLocked(w, policy)}
+		l.mu.Unlock()
+		return false, err
+	}
+	if policy.TPM > 0 && w.tokens.level < 1 {
+		err := &RateLimitError{Window: "tokens", Limit: policy.TPM, RetryAfter: w.tokens.until(policy.TPM, 1), Status: l.statusLocked(w, policy)}
+		l.mu.Unlock()
+		return false, err
+	}
+	if policy.RPM > 0 {
+		w.requests.level--
+	}
+	l.mu.Unlock()
+
+	allowed, err := l.next.Allow(ctx, apiKey)
+	if (err != nil || !allowed) && policy.RPM > 0 {
+		// 被下游拒绝的请求不计入请求窗口
+		l.mu.Lock()
+		w := l.windowsLocked(apiKey, policy)
+		w.requests.level++
+		if w.requests.level > float64(policy.RPM) {
+			w.requests.level = float64(policy.RPM)
+		}
+		l.mu.Unlock()
+	}
+	return allowed, err
+}
+
+// Consume charges the tokens of a completed request to the key's token window and to the wrapped limiter
+func (l *WindowLimiter) Consume(ctx context.Context, apiKey string, actualTokens int) error {
+	if policy, ok := l.policy(apiKey); ok && policy.TPM > 0 {
+		l.mu.Lock()
+		l.windowsLocked(apiKey, policy).tokens.level -= float64(actualTokens)
+		l.mu.Unlock()
+	}
+	return l.next.Consume(ctx, apiKey, actualTokens)
+}
+
+// RateLimitStatus returns the current window status of a key; false when no policy applies to it
+func (l *WindowLimiter) RateLimitStatus(apiKey string) (RateLimitStatus, bool) {
+	policy, ok := l.policy(apiKey)
+	if !ok {
+		return RateLimitStatus{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.statusLocked(l.windowsLocked(apiKey, policy), policy), true
+}
+
+func (l *WindowLimiter) policy(apiKey string) (RateLimitPolicy, bool) {
+	policies := *l.policies.Load()
+	if policy, ok := policies[l.keys.Lookup(apiKey).Plan]; ok {
+		return policy, true
+	}
+	policy, ok := policies[DefaultRateLimitPlan]
+	return policy, ok
+}
+
+// windowsLocked refills the key's buckets up to now and returns them; caller must hold l.mu
+// New keys start with full buckets
+func (l *WindowLimiter) windowsLocked(apiKey string, policy RateLimitPolicy) *keyWindows {
+	now := l.now()
+	w, ok := l.windows[apiKey]
+	if !ok {
+		if now.Sub(l.pruned) >= time.Minute {
+			l.pruneLocked(now)
+		}
+		w = &keyWindows{
+			requests: rateBucket{level: float64(policy.RPM), updated: now},
+			tokens:   rateBucket{level: float64(policy.TPM), updated: now},
+		}
+		l.windows[apiKey] = w
+	}
+	w.requests.refill(policy.RPM, now)
+	w.tokens.refill(policy.TPM, now)
+	return w
+}
+
+// pruneLocked drops the buckets of keys idle for a full window, which are full again anyway,
+// at most once per minute; caller must hold l.mu
+func (l *WindowLimiter) pruneLocked(now time.Time) {
+	l.pruned = now
+	for key, w := range l.windows {
+		if now.Sub(w.requests.updated) >= time.Minute && now.Sub(w.tokens.updated) >= time.Minute {
+			delete(l.windows, key)
+		}
+	}
+}
+
+func (l *WindowLimiter) statusLocked(w *keyWindows, policy RateLimitPolicy) RateLimitStatus {
+	var s RateLimitStatus
+	if policy.RPM > 0 {
+		s.RequestLimit = policy.RPM
+		s.RequestRemaining = w.requests.remaining()
+		s.RequestReset = w.requests.until(policy.RPM, float64(policy.RPM))
+	}
+	if policy.TPM > 0 {
+		s.TokenLimit = policy.TPM
+		s.TokenRemaining = w.tokens.remaining()
+		s.TokenReset = w.tokens.until(policy.TPM, float64(policy.TPM))
+	}
+	return s
+}
+
+// ParseRateLimitPolicies parses "plan=rpm:N,tpm:N;..." e.g. "default=rpm:60,tpm:40000;pro=rpm:600"
+// The plan "default" applies to every key whose plan has no policy of its own
+func ParseRateLimitPolicies(spec string) (map[string]RateLimitPolicy, error) {
+	policies := make(map[string]RateLimitPolicy)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		plan, rules, ok := strings.Cut(entry, "=")
+		plan = strings.TrimSpace(plan)
+		if !ok || plan == "" {
+			return nil, fmt.Errorf("invalid rate limit %q: expected plan=rpm:N,tpm:N", entry)
+		}
+		var policy RateLimitPolicy
+		for _, rule := range strings.Split(rules, ",") {
+			name, value, ok := strings.Cut(strings.TrimSpace(rule), ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid rate limit %q: expected rpm:N or tpm:N, got %q", entry, rule)
+			}
+			n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid rate limit %q: %s must be a positive integer", entry, name)
+			}
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "rpm":
+				policy.RPM = n
+			case "tpm":
+				policy.TPM = n
+			default:
+				return nil, fmt.Errorf("invalid rate limit %q: unknown limit %q", entry, name)
+			}
+		}
+		policies[plan] = policy
+	}
+	return policies, nil
+}
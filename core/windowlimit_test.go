@@ -0,0 +1,132 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimitPolicies(t *testing.T) {
+	policies, err := ParseRateLimitPolicies("default=rpm:60,tpm:40000; pro=rpm:600")
+	if err != nil {
+		t.Fatalf("ParseRateLimitPolicies: %v", err)
+	}
+	if got := policies["default"]; got.RPM != 60 || got.TPM != 40000 {
+		t.Errorf("default = %+v, want rpm 60, tpm 40000", got)
+	}
+	if got := policies["pro"]; got.RPM != 600 || got.TPM != 0 {
+		t.Errorf("pro = %+v, want rpm 600 and no token limit", got)
+	}
+	for _, spec := range []string{"default", "=rpm:1", "pro=rpm", "pro=rpm:0", "pro=rpm:x", "pro=rps:5"} {
+		if _, err := ParseRateLimitPolicies(spec); err == nil {
+			t.Errorf("ParseRateLimitPolicies(%q) should fail", spec)
+		}
+	}
+}
+
+func TestWindowLimiter_Requests(t *testing.T) {
+	ctx := context.Background()
+	keys, _ := ParseKeyDirectory("test-key-123=acme/free")
+	limiter := NewWindowLimiter(NewInMemoryRateLimiter(), keys, map[string]RateLimitPolicy{"free": {RPM: 2}})
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, err := limiter.Allow(ctx, "test-key-123"); !ok || err != nil {
+			t.Fatalf("request %d = (%v, %v), want allowed", i+1, ok, err)
+		}
+	}
+	ok, err := limiter.Allow(ctx, "test-key-123")
+	var limitErr *RateLimitError
+	if ok || !errors.As(err, &limitErr) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("third request = (%v, %v), want a RateLimitError", ok, err)
+	}
+	if limitErr.Window != "requests" || limitErr.RetryAfter != 30*time.Second {
+		t.Errorf("error = %+v, want requests window with 30s retry", limitErr)
+	}
+	if s := limitErr.Status; s.RequestLimit != 2 || s.RequestRemaining != 0 || s.RequestReset != time.Minute || s.TokenLimit != 0 {
+		t.Errorf("status = %+v", s)
+	}
+
+	// 令牌桶连续回填：30 秒后恢复一个请求
+	now = now.Add(30 * time.Second)
+	if ok, err := limiter.Allow(ctx, "test-key-123"); !ok || err != nil {
+		t.Fatalf("request after refill = (%v, %v), want allowed", ok, err)
+	}
+
+	// 未配置策略的计划不受窗口限制
+	if _, ok := limiter.RateLimitStatus("unknown-key"); ok {
+		t.Error("expected no status for keys without a policy")
+	}
+}
+
+func TestWindowLimiter_Tokens(t *testing.T) {
+	ctx := context.Background()
+	base := NewInMemoryRateLimiter()
+	limiter := NewWindowLimiter(base, nil, map[string]RateLimitPolicy{DefaultRateLimitPlan: {RPM: 100, TPM: 1000}})
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	base.SetBalance("test-key-123", 10000)
+
+	if ok, _ := limiter.Allow(ctx, "test-key-123"); !ok {
+		t.Fatal("expected the first request to be allowed")
+	}
+	// 实际用量在请求完成后才知道，桶可以透支
+	limiter.Consume(ctx, "test-key-123", 1500)
+	if base.balances["test-key-123"] != 8500 {
+		t.Errorf("expected the wrapped limiter to be charged, balance %d", base.balances["test-key-123"])
+	}
+	s, _ := limiter.RateLimitStatus("test-key-123")
+	if s.TokenLimit != 1000 || s.TokenRemaining != 0 || s.RequestRemaining != 99 {
+		t.Errorf("status = %+v", s)
+	}
+
+	_, err := limiter.Allow(ctx, "test-key-123")
+	var limitErr *RateLimitError
+	if !errors.As(err, &limitErr) || limitErr.Window != "tokens" {
+		t.Fatalf("expected the tokens window to refuse, got %v", err)
+	}
+	// 透支 500，回填到 1 个 Token 需 30.06 秒
+	if limitErr.RetryAfter <= 30*time.Second || limitErr.RetryAfter > 31*time.Second {
+		t.Errorf("RetryAfter = %v, want just over 30s", limitErr.RetryAfter)
+	}
+	if s, _ := limiter.RateLimitStatus("test-key-123"); s.RequestRemaining != 99 {
+		t.Errorf("expected the refused request not to count, %d remaining", s.RequestRemaining)
+	}
+
+	// 被下游余额拒绝的请求同样不计入请求窗口
+	now = now.Add(time.Minute)
+	base.SetBalance("test-key-123", 0)
+	if ok, err := limiter.Allow(ctx, "test-key-123"); ok || err != nil {
+		t.Fatalf("expected the wrapped limiter to refuse, got (%v, %v)", ok, err)
+	}
+	if s, _ := limiter.RateLimitStatus("test-key-123"); s.RequestRemaining != 100 {
+		t.Errorf("expected a full request window, %d remaining", s.RequestRemaining)
+	}
+}
+
+func TestWindowLimiter_SetPolicies(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewWindowLimiter(NewInMemoryRateLimiter(), nil, nil)
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	// 没有策略时直接交给下游
+	if _, ok := limiter.RateLimitStatus("test-key-123"); ok {
+		t.Fatal("expected no status before policies are set")
+	}
+
+	limiter.SetPolicies(map[string]RateLimitPolicy{DefaultRateLimitPlan: {RPM: 10}})
+	if ok, err := limiter.Allow(ctx, "test-key-123"); !ok || err != nil {
+		t.Fatalf("Allow = (%v, %v), want allowed", ok, err)
+	}
+	// 调低限额后，已有的桶被截断到新上限
+	limiter.SetPolicies(map[string]RateLimitPolicy{DefaultRateLimitPlan: {RPM: 1}})
+	if ok, err := limiter.Allow(ctx, "test-key-123"); !ok || err != nil {
+		t.Fatalf("Allow after lowering = (%v, %v), want allowed", ok, err)
+	}
+	if ok, err := limiter.Allow(ctx, "test-key-123"); ok || !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected the lowered limit to apply, got (%v, %v)", ok, err)
+	}
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"sort"
+	"strings"
+
+	"zam/core"
+	"zam/openai"
+)
+
+// choiceBuilder accumulates the stream of one choice
+type choiceBuilder struct {
+	role         string
+	content      strings.Builder
+	reasoning    strings.Builder
+	toolCalls    toolCallSet
+	finishReason string
+}
+
+// responseAggregator assembles a worker stream into the choices of a non-streaming response:
+// role deltas, text, reasoning and tool call fragments are collected per choice index
+type responseAggregator struct {
+	choices map[int]*choiceBuilder
+}
+
+// add merges one chunk into its choice
+func (a *responseAggregator) add(chunk core.StreamChunk) {
+	if a.choices == nil {
+		a.choices = make(map[int]*choiceBuilder)
+	}
+	b, ok := a.choices[chunk.Index]
+	if !ok {
+		b = &choiceBuilder{}
+		a.choices[chunk.Index] = b
+	}
+	if chunk.Role != "" {
+		b.role = chunk.Role
+	}
+	b.content.WriteString(chunk.Content)
+	b.reasoning.WriteString(chunk.Reasoning)
+	b.toolCalls.add(chunk.ToolCalls)
+	if chunk.FinishReason != "" {
+		b.finishReason = chunk.FinishReason
+	}
+}
+
+// toolChoiceViolation checks every choice against the tool choice and returns the first violation
+func (a *responseAggregator) toolChoiceViolation(choice openai.ToolChoice) string {
+	if len(a.choices) == 0 {
+		return choice.Check(nil)
+	}
+	for _, index := range a.indexes() {
+		if violation := choice.Check(a.choices[index].toolCalls.calls); violation != "" {
+			return violation
+		}
+	}
+	return ""
+}
+
+// result returns the choices ordered by index; a stream without any chunk yields one empty choice
+func (a *responseAggregator) result() []openai.Choice {
+	if len(a.choices) == 0 {
+		return []openai.Choice{{Message: openai.Message{Role: "assistant"}, FinishReason: "stop"}}
+	}
+	choices := make([]openai.Choice, 0, len(a.choices))
+	for _, index := range a.indexes() {
+		b := a.choices[index]
+		role := b.role
+		if role == "" {
+			role = "assistant"
+		}
+		finishReason := b.finishReason
+		switch {
+		case len(b.toolCalls.calls) > 0 && (finishReason == "" || finishReason == "stop"):
+			// 部分后端输出工具调用后仍给出 "stop"
+			finishReason = "tool_calls"
+		case finishReason == "":
+			finishReason = "stop"
+		}
+		choices = append(choices, openai.Choice{
+			Index: index,
+			Message: openai.Message{
+				Role:             role,
+				Content:          b.content.String(),
+				ReasoningContent: b.reasoning.String(),
+				ToolCalls:        b.toolCalls.calls,
+			},
+			FinishReason: finishReason,
+		})
+	}
+	return choices
+}
+
+func (a *responseAggregator) indexes() []int {
+	indexes := make([]int, 0, len(a.choices))
+	for index := range a.choices {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// streamChoice is the state of one choice of a streaming response
+type streamChoice struct {
+	started      bool
+	tokens       int
+	toolCalls    toolCallSet
+	finishReason string
+}
+
+// streamChoices tracks every choice of a streaming response by index, so role deltas,
+// finish reasons, tool calls and token counts stay separate when n > 1
+type streamChoices struct {
+	choices map[int]*streamChoice
+}
+
+// get returns the state of a choice, creating it on first use
+func (s *streamChoices) get(index int) *streamChoice {
+	if s.choices == nil {
+		s.choices = make(map[int]*streamChoice)
+	}
+	c, ok := s.choices[index]
+	if !ok {
+		c = &streamChoice{}
+		s.choices[index] = c
+	}
+	return c
+}
+
+// tokens returns the completion tokens of all choices
+func (s *streamChoices) tokens() int {
+	total := 0
+	for _, c := range s.choices {
+		total += c.tokens
+	}
+	return total
+}
+
+// unfinished returns the started choices without a finish reason, ordered by index
+func (s *streamChoices) unfinished() []int {
+	var indexes []int
+	for _, index := range s.indexes() {
+		if c := s.choices[index]; c.started && c.finishReason == "" {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+// toolChoiceViolation checks every choice against the tool choice and returns the first violation
+func (s *streamChoices) toolChoiceViolation(choice openai.ToolChoice) string {
+	if len(s.choices) == 0 {
+		return choice.Check(nil)
+	}
+	for _, index := range s.indexes() {
+		if violation := choice.Check(s.choices[index].toolCalls.calls); violation != "" {
+			return violation
+		}
+	}
+	return ""
+}
+
+func (s *streamChoices) indexes() []int {
+	indexes := make([]int, 0, len(s.choices))
+	for index := range s.choices {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"zam/core"
+	"zam/openai"
+)
+
+func TestResponseAggregator(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []core.StreamChunk
+		want   []openai.Choice
+	}{
+		{
+			name: "空流返回一个空的 assistant 回复",
+			want: []openai.Choice{{Message: openai.Message{Role: "assistant"}, FinishReason: "stop"}},
+		},
+		{
+			name: "正文与思考内容按顺序拼接",
+			chunks: []core.StreamChunk{
+				{Role: "assistant", Reasoning: "think"},
+				{Content: "Hel"},
+				{Content: "lo", FinishReason: "length"},
+			},
+			want: []openai.Choice{{
+				Message:      openai.Message{Role: "assistant", Content: "Hello", ReasoningContent: "think"},
+				FinishReason: "length",
+			}},
+		},
+		{
+			name:   "缺少 finish_reason 时补为 stop",
+			chunks: []core.StreamChunk{{Content: "ok"}},
+			want:   []openai.Choice{{Message: openai.Message{Role: "assistant", Content: "ok"}, FinishReason: "stop"}},
+		},
+		{
+			name: "工具调用片段合并，stop 改为 tool_calls",
+			chunks: []core.StreamChunk{
+				{ToolCalls: []core.ToolCallDelta{{Index: 0, ID: "call_1", Name: "get_weather", Arguments: `{"city":`}}},
+				{ToolCalls: []core.ToolCallDelta{{Index: 0, Arguments: `"Paris"}`}}, FinishReason: "stop"},
+			},
+			want: []openai.Choice{{
+				Message: openai.Message{Role: "assistant", ToolCalls: []openai.ToolCall{{
+					ID:       "call_1",
+					Type:     "function",
+					Function: openai.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`},
+				}}},
+				FinishReason: "tool_calls",
+			}},
+		},
+		{
+			name: "多个 choice 交错到达时分别汇总并按 index 排序",
+			chunks: []core.StreamChunk{
+				{Index: 1, Content: "Bon"},
+				{Index: 0, Content: "Hel"},
+				{Index: 1, Content: "jour", FinishReason: "length"},
+				{Index: 0, Content: "lo", FinishReason: "stop"},
+			},
+			want: []openai.Choice{
+				{Index: 0, Message: openai.Message{Role: "assistant", Content: "Hello"}, FinishReason: "stop"},
+				{Index: 1, Message: openai.Message{Role: "assistant", Content: "Bonjour"}, FinishReason: "length"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var aggregate responseAggregator
+			for _, chunk := range tt.chunks {
+				aggregate.add(chunk)
+			}
+			if got := aggregate.result(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNonStream_UsageCountsEveryChoice(t *testing.T) {
+	w := &testWorker{id: "gpu-a", run: func(ctx context.Context, sender func(core.StreamChunk) error) error {
+		for _, chunk := range []core.StreamChunk{
+			{Index: 0, Content: "Hel"},
+			{Index: 1, Content: "Bon"},
+			{Index: 0, Content: "lo", FinishReason: "stop"},
+			{Index: 1, Content: "jour", FinishReason: "stop"},
+		} {
+			if err := sender(chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}
+	_, engine := newTestHandler(t, w)
+
+	rec := postChat(engine, false)
+	var resp openai.ChatCompletionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected a completion, got %d: %s", rec.Code, rec.Body)
+	}
+	if len(resp.Choices) != 2 || resp.Choices[0].Message.Content != "Hello" || resp.Choices[1].Message.Content != "Bonjour" {
+		t.Errorf("expected both choices aggregated, got %+v", resp.Choices)
+	}
+	// 输出 Token 为所有 choice 之和；输入为 "hi" 加每条消息的固定开销
+	want := openai.Usage{PromptTokens: 6, CompletionTokens: 12, TotalTokens: 18}
+	if resp.Usage == nil || resp.Usage.PromptTokens != want.PromptTokens || resp.Usage.CompletionTokens != want.CompletionTokens || resp.Usage.TotalTokens != want.TotalTokens {
+		t.Errorf("usage = %+v, want %+v", resp.Usage, want)
+	}
+}
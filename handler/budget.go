@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"zam/core"
+)
+
+// SetFallbackBudget charges requests served by priced fallback workers against the budget
+// the router checks before overflowing to them
+func (h *ChatHandler) SetFallbackBudget(budget *core.FallbackBudget) {
+	h.budget = budget
+}
+
+// chargeFallback records the cost of a request when a fallback worker with a price served it
+func (h *ChatHandler) chargeFallback(workerID string, promptTokens, completionTokens int) {
+	if h.budget == nil {
+		return
+	}
+	source, ok := h.registry.(core.ProfileSource)
+	if !ok {
+		return
+	}
+	profile, ok := source.Profile(workerID)
+	if !ok || !profile.IsFallback || profile.CostPer1KTokens <= 0 {
+		return
+	}
+	h.budget.Charge(core.RequestCost(profile.CostPer1KTokens, promptTokens, completionTokens))
+}
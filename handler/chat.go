@@ -2,16 +2,25 @@ package handler
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"zam/alert"
+	"zam/api"
 	"zam/core"
+	"zam/metrics"
 	"zam/openai"
+	"zam/usage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -21,7 +30,32 @@ import (
 type ChatHandler struct {
 	router   core.Router
 	registry core.WorkerRegistry
-	limiter  core.RateLimiter
+	limiter    core.RateLimiter
+	inflight   *core.InflightTracker
+	quarantine *core.Quarantine
+	meter      *usage.Meter
+	capture    *core.PayloadCapture
+	latency    *metrics.LatencyMetrics
+	alerts     *alert.Engine
+	events     *core.EventBus
+	brownout   *core.Brownout
+	throttles  *core.ThrottleTracker
+	users      *core.UserLimiter
+	catalog    core.ModelCatalog
+	aliases    atomic.Pointer[core.ModelAliases]
+	images     *core.ImageProxy
+	priorities *core.PriorityPolicy
+	experiment *core.Experiments
+	pacing     time.Duration
+	streams    *core.StreamLimiter
+	queue      *core.WaitQueue
+	affinity   *core.SessionAffinity
+	latencies  *core.LatencyTracker
+	budget     *core.FallbackBudget
+	embed      core.EmbedBatchOptions
+	timeouts   core.TimeoutPolicy
+	keyLimit   int
+	attempts   int
 }
 
 // NewChatHandler creates a new ChatHandler with static worker list
@@ -42,6 +76,171 @@ func NewChatHandlerWithRegistry(router core.Router, registry core.WorkerRegistry
 	}
 }
 
+// SetInflightTracker enables per-worker/per-tenant in-flight accounting
+func (h *ChatHandler) SetInflightTracker(tracker *core.InflightTracker) {
+	h.inflight = tracker
+}
+
+// SetQuarantine enables removing workers from routing after consecutive failures
+func (h *ChatHandler) SetQuarantine(quarantine *core.Quarantine) {
+	h.quarantine = quarantine
+}
+
+// SetMeter enables exporting per-request usage events to metering systems
+func (h *ChatHandler) SetMeter(meter *usage.Meter) {
+	h.meter = meter
+}
+
+// SetCapture enables sampling full request/response payloads for debugging
+func (h *ChatHandler) SetCapture(capture *core.PayloadCapture) {
+	h.capture = capture
+}
+
+// SetLatencyMetrics enables latency histograms with trace-ID exemplars
+func (h *ChatHandler) SetLatencyMetrics(m *metrics.LatencyMetrics) {
+	h.latency = m
+}
+
+// SetAlerts feeds request outcomes into the built-in alert engine
+func (h *ChatHandler) SetAlerts(engine *alert.Engine) {
+	h.alerts = engine
+}
+
+// SetEvents enables publishing quota cutoffs and shed requests to the event bus
+func (h *ChatHandler) SetEvents(bus *core.EventBus) {
+	h.events = bus
+}
+
+// SetBrownout enables shedding expensive requests under sustained overload
+func (h *ChatHandler) SetBrownout(brownout *core.Brownout) {
+	h.brownout = brownout
+}
+
+// SetUserLimiter enables the secondary rate limit per end user (the request's "user" field)
+func (h *ChatHandler) SetUserLimiter(limiter *core.UserLimiter) {
+	h.users = limiter
+}
+
+// SetModelCatalog enables per-model parameter limits (max_tokens, temperature)
+func (h *ChatHandler) SetModelCatalog(catalog core.ModelCatalog) {
+	h.catalog = catalog
+}
+
+// SetModelAliases maps client-facing model names to deployed models before routing
+// Responses still report the model name the client asked for. Safe to call at runtime:
+// requests already routed keep the model they resolved to
+func (h *ChatHandler) SetModelAliases(aliases core.ModelAliases) {
+	h.aliases.Store(&aliases)
+}
+
+// modelAliases returns the current aliases, nil when none are set
+func (h *ChatHandler) modelAliases() core.ModelAliases {
+	if aliases := h.aliases.Load(); aliases != nil {
+		return *aliases
+	}
+	return nil
+}
+
+// SetPriorities enables per-key maximums for the X-Priority header (normal when unset)
+func (h *ChatHandler) SetPriorities(policy *core.PriorityPolicy) {
+	h.priorities = policy
+}
+
+// SetExperiments enables A/B splitting of model traffic between experiment arms
+func (h *ChatHandler) SetExperiments(experiments *core.Experiments) {
+	h.experiment = experiments
+}
+
+// SetStreamPacing coalesces streamed text so SSE events are flushed at most once per interval (0 disables)
+func (h *ChatHandler) SetStreamPacing(interval time.Duration) {
+	h.pacing = interval
+}
+
+// SetTimeoutPolicy sets the default deadline of requests and caps the X-Request-Timeout-Ms header
+func (h *ChatHandler) SetTimeoutPolicy(policy core.TimeoutPolicy) {
+	h.timeouts = policy
+}
+
+// SetKeyConcurrency caps the concurrent requests of each API key (0 disables); it needs the in-flight
+// tracker, whose counts span every gateway replica in cluster mode
+func (h *ChatHandler) SetKeyConcurrency(max int) {
+	h.keyLimit = max
+}
+
+// SetWaitQueue queues requests that find no worker with capacity instead of rejecting them at once
+func (h *ChatHandler) SetWaitQueue(queue *core.WaitQueue) {
+	h.queue = queue
+}
+
+// SetSessionAffinity derives each request's session from X-Session-ID or its conversation prefix,
+// which the router uses to keep a conversation on the worker caching it
+func (h *ChatHandler) SetSessionAffinity(affinity *core.SessionAffinity) {
+	h.affinity = affinity
+}
+
+// SetLatencyTracker records the TTFT and completion latency of each worker for latency-aware routing
+func (h *ChatHandler) SetLatencyTracker(tracker *core.LatencyTracker) {
+	h.latencies = tracker
+}
+
+// SetStreamLimiter caps the concurrent SSE streams of each client IP
+func (h *ChatHandler) SetStreamLimiter(limiter *core.StreamLimiter) {
+	h.streams = limiter
+}
+
+// chargeUsage deducts the billed tokens of a completed request from the key's balance
+// and reports the usage to the meter
+func (h *ChatHandler) chargeUsage(ctx context.Context, req *core.InferenceRequest, apiKey, workerID string, completionTokens int) usage.Event {
+	e := h.meter.Record(usage.Event{
+		RequestID:        req.TraceID,
+		Key:              apiKey,
+		User:             req.User,
+		Model:            req.Model,
+		WorkerID:         workerID,
+		PromptTokens:     req.PromptTokens,
+		CompletionTokens: completionTokens,
+		Endpoint:         usage.EndpointChat,
+		Stream:           req.Stream,
+	})
+	_ = h.limiter.Consume(ctx, apiKey, e.BilledTokens)
+	h.chargeFallback(workerID, req.PromptTokens, completionTokens)
+	return e
+}
+
+// logUsage adds the worker that served the request and its token counts to the access log
+func logUsage(c *gin.Context, e usage.Event) {
+	api.SetLogFields(c, "worker_id", e.WorkerID, "prompt_tokens", e.PromptTokens, "completion_tokens", e.CompletionTokens)
+}
+
+// recordOutcome feeds an Execute result into worker quarantine and the alert engine
+// Client disconnects and gateway-side failures (gatewayErr) are not held against the worker
+func (h *ChatHandler) recordOutcome(ctx context.Context, req *core.InferenceRequest, workerID string, err, gatewayErr error) {
+	clientGone := (errors.Is(err, context.Canceled) && ctx.Err() != nil) || deadlineExceeded(ctx)
+	h.alerts.RecordRequest(req.Fallback, err != nil && !clientGone && (gatewayErr == nil || !errors.Is(err, gatewayErr)))
+	if h.quarantine == nil {
+		return
+	}
+	switch {
+	case err == nil:
+		h.quarantine.RecordSuccess(workerID)
+	case errors.Is(err, context.Canceled) && ctx.Err() != nil:
+		// 客户端主动断开，不计入 Worker 失败
+	case deadlineExceeded(ctx):
+		// 客户端设定的截止时间已到，不计入 Worker 失败
+	case gatewayErr != nil && errors.Is(err, gatewayErr):
+		// 网关侧熔断（配额/写失败），不计入 Worker 失败
+	case errors.As(err, new(*core.ThrottledError)):
+		// 上游限流由冷却处理，不计入 Worker 失败
+	default:
+		h.quarantine.RecordFailure(workerID)
+	}
+}
+
+// deadlineExceeded reports whether the request ran past its X-Request-Timeout-Ms deadline
+func deadlineExceeded(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
 // extractAPIKey extracts the API key from Authorization header
 // Expected format: "Bearer <api_key>"
 func (h *ChatHandler) extractAPIKey(c *gin.Context) string {
@@ -59,117 +258,430 @@ func (h *ChatHandler) extractAPIKey(c *gin.Context) string {
 	return parts[1]
 }
 
+// exemplarTraceID returns the trace ID attached to metric exemplars
+// The W3C traceparent trace ID is preferred so exemplars lead to the caller's OTel trace;
+// otherwise the gateway's own trace ID is used in the same 32-hex-digit form
+func exemplarTraceID(c *gin.Context, traceID string) string {
+	parts := strings.Split(c.GetHeader("traceparent"), "-")
+	if len(parts) == 4 && len(parts[1]) == 32 && parts[1] != strings.Repeat("0", 32) {
+		return parts[1]
+	}
+	return strings.ReplaceAll(traceID, "-", "")
+}
+
+// requestTraceID uses the request ID as the TraceID so clients can quote it in support requests
+// A fresh UUID is used when the request ID middleware is not installed
+func requestTraceID(c *gin.Context) string {
+	if id := api.RequestID(c); id != "" {
+		return id
+	}
+	return uuid.New().String()
+}
+
 // Handle is the Gin handler function for chat completion requests
 func (h *ChatHandler) Handle(c *gin.Context) {
+	start := time.Now()
+
 	// 0. 提取 API Key 并进行限流预检
 	apiKey := h.extractAPIKey(c)
 	if apiKey == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": gin.H{
-				"message": "Missing or invalid Authorization header",
-				"type":    "authentication_error",
-			},
-		})
+		api.WriteError(c, openai.NewAuthenticationError("Missing or invalid Authorization header"))
 		return
 	}
+	// 访问日志只记录 Key 的哈希，与 zam_key_* 指标的标签一致
+	api.SetLogFields(c, "api_key", metrics.HashKey(apiKey))
 
 	// 阶段一：限流预检
-	allowed, err := h.limiter.Allow(c.Request.Context(), apiKey)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": "Rate limiter error: " + err.Error(),
-				"type":    "server_error",
-			},
-		})
+	if !h.admitKey(c, apiKey) {
 		return
 	}
 
-	if !allowed {
-		c.JSON(http.StatusTooManyRequests, gin.H{
-			"error": gin.H{
-				"message": "Insufficient quota or invalid API key",
-				"type":    "insufficient_quota",
-			},
-		})
+	// 1. 解析并验证请求体
+	req, ok := bindChatRequest(c)
+	if !ok {
 		return
 	}
 
-	// 1. 解析请求体 - 使用 Gin 标准的 ShouldBindJSON
-	var req openai.ChatCompletionRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"message": "Invalid request body: " + err.Error(),
-				"type":    "invalid_request_error",
-			},
-		})
+	// 按客户端 IP 限制并发流数量，与 API Key 无关
+	if req.Stream {
+		release, ok := h.streams.Acquire(c.ClientIP())
+		if !ok {
+			api.WriteError(c, openai.NewError(http.StatusTooManyRequests, openai.RateLimitErrorType, "Too many concurrent streams from this IP address").WithCode("concurrent_stream_limit_exceeded"))
+			return
+		}
+		defer release()
+	}
+
+	// 按 API Key 限制并发请求数：准入时原子地检查并占用名额，排队中的请求同样计入；集群模式下包含其他网关副本（按同步周期最终一致）
+	if h.keyLimit > 0 && h.inflight != nil {
+		release, ok := h.inflight.TryAcquireTenant(apiKey, h.keyLimit)
+		if !ok {
+			api.WriteError(c, openai.NewError(http.StatusTooManyRequests, openai.RateLimitErrorType, "Too many concurrent requests for this API key").WithCode("concurrent_request_limit_exceeded"))
+			return
+		}
+		defer release()
+	}
+
+	// 校验图片大小与格式，按需由网关抓取远程图片并内联，供无法访问外网的局域网 Worker 使用
+	if req.HasImages() {
+		if err := resolveImages(c.Request.Context(), h.images, req.Messages); err != nil {
+			api.WriteError(c, err)
+			return
+		}
+	}
+
+	// 阶段二：按终端用户（user 字段）的二级限流，避免共享 Key 被单个用户耗尽
+	if ok, retryAfter := h.users.Allow(apiKey, req.User); !ok {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		api.WriteError(c, openai.NewError(http.StatusTooManyRequests, openai.RateLimitErrorType, "Rate limit exceeded for this user").WithParam("user").WithCode("user_rate_limit_exceeded"))
 		return
 	}
 
-	// 2. 验证必需参数
-	if req.Model == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"message": "model is required",
-				"type":    "invalid_request_error",
-			},
-		})
+	// 租户自定优先级，不得超过 Key 允许的上限；未指定时使用 Key 的优先级等级
+	priority, perr := api.RequestPriority(c, h.priorities, apiKey, h.priorities.Class(apiKey))
+	if perr != nil {
+		api.WriteError(c, perr)
 		return
 	}
 
-	if len(req.Messages) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"message": "messages is required",
-				"type":    "invalid_request_error",
-			},
-		})
+	// 客户端可通过 X-Request-Timeout-Ms 设定截止时间，路由与执行共用，受服务端上限约束
+	timeout, terr := api.RequestTimeout(c, h.timeouts)
+	if terr != nil {
+		api.WriteError(c, terr)
 		return
 	}
+	if timeout > 0 {
+		deadlineCtx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(deadlineCtx)
+	}
 
 	// 3. 构建推理请求
-	traceID := uuid.New().String()
-	inferenceReq := &core.InferenceRequest{
-		TraceID:     traceID,
-		Model:       req.Model,
-		Messages:    req.Messages,
-		Temperature: req.Temperature,
-		Stream:      req.Stream,
+	traceID := requestTraceID(c)
+	inferenceReq := h.newInferenceRequest(req, apiKey, traceID)
+	api.SetLogFields(c, "trace_id", traceID, "model", req.Model)
+	inferenceReq.Federated = c.GetHeader(core.FederationHeader) != ""
+	inferenceReq.Priority = priority
+	// 客户端可通过 X-Zam-Router 指定路由策略，便于对比不同调度策略
+	strategy, serr := api.RequestStrategy(c, h.router)
+	if serr != nil {
+		api.WriteError(c, serr)
+		return
 	}
+	if strategy != "" {
+		inferenceReq.Strategy = strategy
+		api.SetLogFields(c, "strategy", strategy)
+	}
+	// 会话亲和：同一会话的后续轮次优先路由到缓存了对话前缀的 Worker
+	inferenceReq.Session = h.affinity.Key(apiKey, c.GetHeader(core.SessionHeader), inferenceReq.Model, inferenceReq.Messages)
 
-	// 4. 获取 Workers 列表（从注册中心）
-	workers := h.registry.GetAvailableWorkers()
-	if len(workers) == 0 {
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": gin.H{
-				"message": "No workers available",
-				"type":    "server_error",
-			},
+	// A/B 实验：按比例把模型流量分到两个分组，携带 user 的请求按 Key + 用户固定分组
+	sticky := traceID
+	if req.User != "" {
+		sticky = apiKey + "\x00" + req.User
+	}
+	assignment, inExperiment := h.experiment.Assign(inferenceReq.Model, sticky)
+	if inExperiment {
+		assignment.Apply(inferenceReq)
+		c.Header(core.ExperimentHeader, assignment.Tag())
+	}
+
+	// 按模型目录截断或拒绝超出模型能力的参数
+	clamped, err := h.catalog.Enforce(inferenceReq)
+	var limitErr *core.ParamLimitError
+	if errors.As(err, &limitErr) {
+		api.WriteError(c, openai.NewInvalidRequestError(limitErr.Error()).WithParam(limitErr.Param))
+		return
+	}
+	if len(clamped) > 0 {
+		c.Header("X-Zam-Clamped", strings.Join(clamped, ","))
+		slog.Info("clamped request parameters", "trace_id", traceID, "model", inferenceReq.Model, "params", clamped)
+	}
+
+	// 降级模式：持续过载时拒绝大模型请求并限制 max_tokens，保证小模型流量
+	// 低优先级（批量）请求在降级期间最先被拒绝
+	admitted, maxTokens := h.brownout.Admit(inferenceReq.Model, inferenceReq.MaxTokens)
+	if priority < core.PriorityNormal && h.brownout.Active() {
+		admitted = false
+	}
+	if !admitted {
+		h.events.Publish(core.Event{
+			Type:    core.EventRequestShed,
+			Message: fmt.Sprintf("request %s for %s rejected: brownout", traceID, req.Model),
 		})
+		c.Header("Retry-After", "30")
+		api.WriteError(c, openai.NewServerError(http.StatusServiceUnavailable, fmt.Sprintf("Model %s is temporarily unavailable while the gateway is overloaded", req.Model)).WithCode("brownout"))
 		return
 	}
+	inferenceReq.MaxTokens = maxTokens
 
+	// 4. 获取 Workers 列表（从注册中心）并选择 Worker
 	baseCtx := c.Request.Context()
 	ctx := context.WithValue(baseCtx, core.TraceKey, traceID)
-	selectedWorker, err := h.router.Select(ctx, workers, inferenceReq)
+	var (
+		workers        []core.Worker
+		selectedWorker core.Worker
+	)
+	err = core.ErrNoAvailableWorkers
+	// 已有更高优先级的请求在排队时不插队，直接排到它们后面
+	if !h.queue.Outranked(priority) {
+		workers, selectedWorker, err = h.route(ctx, inferenceReq)
+	}
+	// 没有 Worker 有空闲容量时排队等待：请求完成或心跳到达时按优先级重新路由，超时后才返回 503
+	queued := errors.Is(err, core.ErrNoAvailableWorkers) && h.queue != nil
+	if queued {
+		if waitErr := h.queue.Wait(ctx, inferenceReq.Model, priority, func() bool {
+			workers, selectedWorker, err = h.route(ctx, inferenceReq)
+			return !errors.Is(err, core.ErrNoAvailableWorkers)
+		}); waitErr != nil && !errors.Is(err, core.ErrFallbackBudgetExceeded) {
+			// 兜底预算耗尽时报告预算错误，而不是排队超时
+			err = waitErr
+		}
+	}
+	if err != nil && deadlineExceeded(ctx) {
+		api.WriteError(c, openai.NewTimeoutError("Request timeout while selecting a worker"))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": gin.H{
-				"message": fmt.Sprintf("Failed to select worker: %v", err),
-				"type":    "server_error",
-			},
+		h.alerts.RecordRequest(false, true)
+		h.events.Publish(core.Event{
+			Type:    core.EventRequestShed,
+			Message: fmt.Sprintf("request %s for %s rejected: %v", traceID, req.Model, err),
 		})
+		var budgetErr *core.FallbackBudgetError
+		switch {
+		case errors.As(err, &budgetErr):
+			api.WriteError(c, openai.NewQuotaError(fmt.Sprintf("No local worker is available for model %s and the %s cloud fallback budget is exhausted until %s", req.Model, budgetErr.Period, budgetErr.ResetAt.Format(time.RFC3339))).WithCode("fallback_budget_exceeded"))
+		case errors.Is(err, core.ErrQueueFull):
+			c.Header("Retry-After", "1")
+			api.WriteError(c, openai.NewServerError(http.StatusServiceUnavailable, fmt.Sprintf("Too many requests for model %s are waiting for a worker, please retry later", req.Model)).WithCode("queue_full"))
+		case errors.Is(err, core.ErrQueueTimeout):
+			c.Header("Retry-After", "1")
+			api.WriteError(c, openai.NewServerError(http.StatusServiceUnavailable, fmt.Sprintf("No worker for model %s became available in time, please retry later", req.Model)).WithCode("queue_timeout"))
+		case len(workers) == 0:
+			api.WriteError(c, openai.NewServerError(http.StatusServiceUnavailable, "No workers available"))
+		default:
+			api.WriteError(c, openai.NewServerError(http.StatusServiceUnavailable, fmt.Sprintf("Failed to select worker: %v", err)))
+		}
 		return
 	}
 
+	// 审计字段：终端用户与选中的 Worker 随访问日志输出，便于滥用溯源
+	api.SetLogFields(c, "user", req.User, "priority", priority.String(), "worker_id", selectedWorker.ID())
+
 	c.Request = c.Request.WithContext(ctx)
+	defer func() {
+		h.latency.ObserveRequest(inferenceReq.Model, time.Since(start), exemplarTraceID(c, traceID))
+	}()
+
+	// 请求结束后唤醒排队中的请求重新路由
+	defer h.queue.Notify()
+
+	// 未输出任何内容前失败时换候选重试；上游 429 时同时冷却该 Worker
+	// 每次尝试在实际执行的 Worker 上计入在途，供租户反亲和与按模型并发槽位调度使用
+	// 排队的请求计入在途后，其余排队请求继续按优先级重试
+	selectedWorker = h.withFailover(selectedWorker, workers, inferenceReq.Fallback, apiKey, func() {
+		if queued {
+			h.queue.Notify()
+		}
+	})
+
+	// 按实验分组统计延迟、吞吐与错误率
+	if inExperiment {
+		selectedWorker = h.experiment.Instrument(selectedWorker, assignment)
+	}
+
+	// 记录首 token 与逐 token 延迟，按模型和 worker 分桶
+	selectedWorker = h.latency.Instrument(selectedWorker, exemplarTraceID(c, traceID))
+	// 按 Worker 维护首 token 与完成延迟的 EWMA，供路由打分
+	selectedWorker = h.latencies.Instrument(selectedWorker)
+
+	// 按采样率抓取完整的请求/响应，供排查异常输出
+	if h.capture.Sample() {
+		body, _ := json.Marshal(req)
+		selectedWorker = core.NewCaptureWorker(selectedWorker, h.capture, &core.CapturedPayload{
+			ID:      traceID,
+			Time:    time.Now(),
+			Key:     usage.MaskKey(apiKey),
+			User:    req.User,
+			Model:   req.Model,
+			Request: body,
+		})
+	}
+
+	// 合并细碎的流式分片，按固定节奏刷出
+	if req.Stream && h.pacing > 0 {
+		selectedWorker = core.NewPacedWorker(selectedWorker, h.pacing)
+	}
+	// 无需网关改写的流，允许 OpenAI 原生后端直接透传 SSE 字节
+	inferenceReq.Passthrough = h.passthrough(inferenceReq)
 
 	// 7. 根据是否流式执行请求
+	// tool_choice 已在 bindChatRequest 中校验
+	// 旧版 functions 接口的请求按 function_call 形式返回
+	toolChoice, _ := req.ParseToolChoice()
 	if req.Stream {
-		h.handleStreamRequest(c, selectedWorker, inferenceReq, apiKey)
+		h.handleStreamRequest(c, selectedWorker, inferenceReq, apiKey, toolChoice, req.LegacyFunctions())
 	} else {
-		h.handleNonStreamRequest(c, selectedWorker, inferenceReq, apiKey)
+		h.handleNonStreamRequest(c, selectedWorker, inferenceReq, apiKey, toolChoice, req.LegacyFunctions())
+	}
+}
+
+// admitKey runs the rate limit and spend cap pre-check of apiKey, writing the error response
+// when the request is refused
+func (h *ChatHandler) admitKey(c *gin.Context, apiKey string) bool {
+	allowed, err := h.limiter.Allow(c.Request.Context(), apiKey)
+	var capErr *core.SpendCapError
+	if errors.As(err, &capErr) {
+		capped := capErr.ID
+		if capErr.Scope == "key" {
+			capped = usage.MaskKey(capped)
+		}
+		h.events.Publish(core.Event{
+			Type:    core.EventQuotaCutoff,
+			Message: fmt.Sprintf("key %s refused: spend cap of %s %s exhausted until %s", usage.MaskKey(apiKey), capErr.Scope, capped, capErr.ResetAt.Format(time.RFC3339)),
+		})
+		api.WriteError(c, openai.NewQuotaError(fmt.Sprintf("You exceeded the %s spend cap for this period; it resets at %s", capErr.Scope, capErr.ResetAt.Format(time.RFC3339))))
+		return false
+	}
+	var windowErr *core.RateLimitError
+	if errors.As(err, &windowErr) {
+		api.WriteRateLimitError(c, windowErr)
+		return false
+	}
+	if err != nil {
+		api.WriteError(c, openai.NewServerError(http.StatusInternalServerError, "Rate limiter error: "+err.Error()))
+		return false
+	}
+	// 按分钟请求数/Token 数限流时，在响应头中告知剩余额度
+	if reporter, ok := h.limiter.(core.RateLimitReporter); ok {
+		if status, ok := reporter.RateLimitStatus(apiKey); ok {
+			api.SetRateLimitHeaders(c, status)
+		}
+	}
+
+	if !allowed {
+		api.WriteError(c, openai.NewQuotaError("Insufficient quota or invalid API key"))
+		return false
+	}
+	return true
+}
+
+// route collects the schedulable workers and selects one for req
+// core.ErrNoAvailableWorkers is returned when no worker is schedulable or has capacity for req
+func (h *ChatHandler) route(ctx context.Context, req *core.InferenceRequest) ([]core.Worker, core.Worker, error) {
+	workers := h.registry.GetAvailableWorkers()
+	if h.quarantine != nil {
+		// 剔除被隔离的 Worker
+		workers = h.quarantine.Filter(workers)
+	}
+	// 剔除被上游限流、仍在冷却中的 Worker
+	workers = h.throttles.Filter(workers)
+	if len(workers) == 0 {
+		return nil, nil, core.ErrNoAvailableWorkers
+	}
+	selected, err := selectDispatch(ctx, h.router, h.quarantine, workers, req)
+	return workers, selected, err
+}
+
+// selectDispatch selects a worker for req that is about to be dispatched, reserving its quarantine
+// probe; when another request took the probe in the meantime the worker is skipped and the
+// selection repeated among the rest
+func selectDispatch(ctx context.Context, r core.Router, quarantine *core.Quarantine, workers []core.Worker, req *core.InferenceRequest) (core.Worker, error) {
+	for {
+		selected, err := r.Select(ctx, workers, req)
+		if err != nil || quarantine == nil || quarantine.AcquireProbe(selected.ID()) {
+			return selected, err
+		}
+		if workers = excludeWorker(workers, selected.ID()); len(workers) == 0 {
+			return nil, core.ErrNoAvailableWorkers
+		}
+	}
+}
+
+// passthrough reports whether a stream may be forwarded verbatim from an OpenAI-native worker:
+// no pacing, no tools whose calls the gateway rewrites and no model rename to echo back
+func (h *ChatHandler) passthrough(req *core.InferenceRequest) bool {
+	return req.Stream && h.pacing == 0 && req.Tools == nil && req.RequestedModel == req.Model
+}
+
+// bindChatRequest parses and validates a chat completion request body
+// On failure the error response is written and ok is false
+func bindChatRequest(c *gin.Context) (*openai.ChatCompletionRequest, bool) {
+	// 使用 Gin 标准的 ShouldBindJSON
+	var req openai.ChatCompletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.WriteError(c, openai.NewInvalidRequestError("Invalid request body: "+err.Error()))
+		return nil, false
+	}
+
+	// 验证必需参数
+	if req.Model == "" {
+		api.WriteError(c, openai.NewInvalidRequestError("model is required").WithParam("model"))
+		return nil, false
+	}
+
+	if len(req.Messages) == 0 {
+		api.WriteError(c, openai.NewInvalidRequestError("messages is required").WithParam("messages"))
+		return nil, false
+	}
+
+	// 旧版 functions / function_call 改写为 tools / tool_choice
+	if err := req.UpgradeFunctions(); err != nil {
+		api.WriteError(c, err)
+		return nil, false
+	}
+
+	// 拒绝超出 OpenAI 取值范围的采样参数
+	if err := req.Validate(); err != nil {
+		api.WriteError(c, err)
+		return nil, false
+	}
+
+	return &req, true
+}
+
+// newInferenceRequest converts a validated client request into the internal inference request
+// Model aliases are resolved here; RequestedModel keeps the client's name to echo back
+func (h *ChatHandler) newInferenceRequest(req *openai.ChatCompletionRequest, apiKey, traceID string) *core.InferenceRequest {
+	model := req.Model
+	if target, ok := h.modelAliases().Resolve(model); ok {
+		model = target
+	}
+	// 支持 "base@adapter" 形式的 LoRA 模型名
+	baseModel, adapter := core.SplitAdapterModel(model)
+	return &core.InferenceRequest{
+		TraceID:          traceID,
+		Tenant:           apiKey,
+		User:             req.User,
+		RequestedModel:   req.Model,
+		Model:            baseModel,
+		Adapter:          adapter,
+		Messages:         req.Messages,
+		PromptTokens:     estimatePromptTokens(req.Messages),
+		Temperature:      req.Temperature,
+		Tools:            forwardedTools(req),
+		ToolChoice:       req.ToolChoice,
+		MaxTokens:        req.MaxTokens,
+		Stream:           req.Stream,
+		TopP:             req.TopP,
+		N:                req.N,
+		Stop:             req.Stop,
+		FrequencyPenalty: req.Frequency,
+		PresencePenalty:  req.Presence,
+		ResponseFormat:   req.ResponseFormat,
+		Needs:            requiredCapabilities(req),
+	}
+}
+
+// requiredCapabilities derives the worker capabilities a chat request needs
+func requiredCapabilities(req *openai.ChatCompletionRequest) core.Capabilities {
+	return core.Capabilities{
+		// tool_choice "none" 时后端无需输出 tool_calls
+		Tools:      len(req.Tools) > 0 && req.ToolChoice != "none",
+		Vision:     req.HasImages(),
+		JSONMode:   req.WantsJSON(),
+		MaxContext: estimatePromptTokens(req.Messages) + req.MaxTokens,
 	}
 }
 
@@ -178,124 +690,284 @@ func estimateTokens(text string) int {
 	return len([]rune(text))
 }
 
+// chunkTokens estimates the output tokens of a chunk; reasoning and tool call arguments count as output
+func chunkTokens(chunk core.StreamChunk) int {
+	return estimateTokens(chunk.Content) + estimateTokens(chunk.Reasoning) + toolCallTokens(chunk.ToolCalls)
+}
+
+// estimatePromptTokens estimates the prompt length of a conversation
+// Each message carries a small fixed overhead for role and framing tokens
+func estimatePromptTokens(messages []openai.Message) int {
+	const perMessageOverhead = 4
+	total := 0
+	for _, m := range messages {
+		total += estimateTokens(m.Content) + perMessageOverhead
+	}
+	return total
+}
+
 // handleStreamRequest handles streaming responses
-func (h *ChatHandler) handleStreamRequest(c *gin.Context, worker core.Worker, req *core.InferenceRequest, apiKey string) {
+// legacy renders tool calls as the function_call of the deprecated functions API
+func (h *ChatHandler) handleStreamRequest(c *gin.Context, worker core.Worker, req *core.InferenceRequest, apiKey string, toolChoice openai.ToolChoice, legacy bool) {
 	// 设置 SSE 响应头 - 使用 Gin 标准方式
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 	c.Header("X-Accel-Buffering", "no") // 禁用 Nginx 缓冲
+	c.Header("Trailer", UsageTrailer)
 
 	// 设置 HTTP 状态码
 	c.Status(http.StatusOK)
 
-	// Token 计数器
-	totalTokens := 0
+	// Token 上限，按所有 choice 的总和计算
 	maxAllowed := 50
 
+	// gatewayErr 记录由网关自身触发的中断，用于区分 Worker 故障
+	var gatewayErr error
+	// 按 choice 记录 role、finish_reason、工具调用片段与 Token 数，结束时校验 tool_choice
+	var choices streamChoices
+
+	// 同一个流的所有分片共用 id、created 与 system_fingerprint
+	// 直通流沿用后端首个分片的取值，使网关补发的分片与透传的分片一致
+	header := openai.ChatCompletionStreamResponse{
+		ID:                "chatcmpl-" + req.TraceID,
+		Object:            "chat.completion.chunk",
+		Created:           time.Now().Unix(),
+		Model:             req.RequestedModel,
+		SystemFingerprint: systemFingerprint(req.RequestedModel, worker.ID()),
+	}
+	// wrote 记录是否已向客户端写出分片，之后不再改变 header
+	wrote := false
+	newChunk := func(choices ...openai.StreamChoice) openai.ChatCompletionStreamResponse {
+		if choices == nil {
+			choices = []openai.StreamChoice{}
+		}
+		response := header
+		response.Choices = choices
+		return response
+	}
+	// 每个 choice 的首个分片只携带 role 与空 content，之后才是正文，与 OpenAI 的分片顺序一致
+	sendRole := func(index int) error {
+		state := choices.get(index)
+		if state.started {
+			return nil
+		}
+		state.started = true
+		wrote = true
+		return writeSSEEvent(c, "data", newChunk(openai.StreamChoice{Index: index, Delta: openai.Delta{Role: "assistant"}}))
+	}
+
 	// 创建 sender 回调 - 必须使用 c.Writer.Write() 和 c.Writer.Flush()
 	senderFunc := func(chunk core.StreamChunk) error {
 		// 检查错误
 		if chunk.Error != nil {
 			// 发送错误事件
-			errorData := map[string]interface{}{
-				"error": map[string]interface{}{
-					"message": chunk.Error.Error(),
-					"type":    "server_error",
-					"code":    "stream_error",
-				},
-			}
+			errorData := api.ErrorBody(c, openai.NewServerError(http.StatusBadGateway, chunk.Error.Error()).WithCode("stream_error"))
 			if err := writeSSEEvent(c, "error", errorData); err != nil {
-				return fmt.Errorf("failed to write error event: %w", err)
+				gatewayErr = fmt.Errorf("failed to write error event: %w", err)
+				return gatewayErr
 			}
 			return chunk.Error
 		}
 
+		// tool_choice 为 "none" 时丢弃后端仍然输出的工具调用
+		if applyToolChoice(toolChoice, &chunk) && chunk.Content == "" && chunk.Reasoning == "" && chunk.FinishReason == "" {
+			return nil
+		}
+		state := choices.get(chunk.Index)
+		state.toolCalls.add(chunk.ToolCalls)
+
 		// 累计 Token 数量（简单使用字符数估算）
-		totalTokens += estimateTokens(chunk.Content)
-		if totalTokens > maxAllowed {
+		state.tokens += chunkTokens(chunk)
+		if choices.tokens() > maxAllowed {
 			// 这里必须 return error！
 			// 这会将错误抛给底层的 Worker，触发 defer resp.Body.Close()，瞬间断网！
-			log.Printf("[网关拦截] 达到配额上限 %d，强行熔断连接！", maxAllowed)
+			slog.Warn("token quota reached, cutting off stream", "trace_id", req.TraceID, "worker_id", worker.ID(), "max_tokens", maxAllowed)
+			h.events.Publish(core.Event{
+				Type:     core.EventQuotaCutoff,
+				WorkerID: worker.ID(),
+				Message:  fmt.Sprintf("request %s of key %s cut off mid-stream at %d tokens", req.TraceID, usage.MaskKey(apiKey), maxAllowed),
+			})
 
 			// 优雅地给前端发一个错误事件，告诉用户没钱了
-			_ = writeSSEEvent(c, "error", map[string]interface{}{
-				"error": map[string]interface{}{
-					"message": "Token quota exceeded mid-stream",
-					"type":    "quota_error",
-				},
-			})
-			return fmt.Errorf("quota exceeded")
+			_ = writeSSEEvent(c, "error", api.ErrorBody(c, openai.NewQuotaError("Token quota exceeded mid-stream")))
+			gatewayErr = fmt.Errorf("quota exceeded")
+			return gatewayErr
 		}
-		
-		// 构建 OpenAI 标准 SSE 响应
-		response := openai.ChatCompletionStreamResponse{
-			ID:      "chatcmpl-" + req.TraceID,
-			Object:  "chat.completion.chunk",
-			Created: time.Now().Unix(),
-			Model:   req.Model,
-			Choices: []openai.StreamChoice{
-				{
-					Index: 0,
-					Delta: openai.Delta{
-						Content: chunk.Content,
-					},
-				},
-			},
+
+		// 直通：后端已输出标准 OpenAI 分片，原样转发，跳过反序列化再序列化
+		if chunk.Raw != nil {
+			if !wrote {
+				adoptStreamHeader(&header, chunk.Raw)
+			}
+			if chunk.Role != "" {
+				state.started = true
+			}
+			if err := sendRole(chunk.Index); err != nil {
+				gatewayErr = fmt.Errorf("failed to write chunk: %w", err)
+				return gatewayErr
+			}
+			wrote = true
+			if chunk.FinishReason != "" && state.finishReason == "" {
+				state.finishReason = chunk.FinishReason
+			}
+			if err := writeSSEData(c, chunk.Raw); err != nil {
+				gatewayErr = fmt.Errorf("failed to write chunk: %w", err)
+				return gatewayErr
+			}
+			return nil
 		}
 
-		// 如果有完成原因，设置 finish_reason
-		if chunk.FinishReason != "" {
-			response.Choices[0].FinishReason = &chunk.FinishReason
+		if err := sendRole(chunk.Index); err != nil {
+			gatewayErr = fmt.Errorf("failed to write chunk: %w", err)
+			return gatewayErr
 		}
 
-		// 序列化并发送
-		if err := writeSSEEvent(c, "data", response); err != nil {
-			return fmt.Errorf("failed to write chunk: %w", err)
+		// 构建 OpenAI 标准 SSE 响应；后端单独发送的 role 分片已由 sendRole 代替
+		delta := openai.Delta{
+			Content:          chunk.Content,
+			ReasoningContent: chunk.Reasoning,
+			ToolCalls:        toolCallDeltas(chunk.ToolCalls),
+		}
+		if legacy {
+			delta.ToolCalls, delta.FunctionCall = nil, legacyFunctionDelta(chunk.ToolCalls)
+		}
+		if delta.Content != "" || delta.ReasoningContent != "" || len(delta.ToolCalls) > 0 || delta.FunctionCall != nil {
+			response := newChunk(openai.StreamChoice{Index: chunk.Index, Delta: delta})
+			if err := writeSSEEvent(c, "data", response); err != nil {
+				gatewayErr = fmt.Errorf("failed to write chunk: %w", err)
+				return gatewayErr
+			}
+		}
+
+		// finish_reason 单独以空 delta 的分片发送；部分后端输出工具调用后仍给出 "stop"
+		if chunk.FinishReason != "" && state.finishReason == "" {
+			state.finishReason = chunk.FinishReason
+			if len(state.toolCalls.calls) > 0 && state.finishReason == "stop" {
+				state.finishReason = "tool_calls"
+			}
+			finishReason := state.finishReason
+			if legacy {
+				finishReason = legacyFinishReason(finishReason)
+			}
+			if err := writeSSEEvent(c, "data", newChunk(openai.StreamChoice{Index: chunk.Index, Delta: openai.Delta{}, FinishReason: &finishReason})); err != nil {
+				gatewayErr = fmt.Errorf("failed to write chunk: %w", err)
+				return gatewayErr
+			}
 		}
 
 		return nil
 	}
 
 	// 执行推理 - 透传 c.Request.Context()
-	if err := worker.Execute(c.Request.Context(), req, senderFunc); err != nil {
+	err := core.SafeExecute(c.Request.Context(), worker, req, senderFunc)
+	h.recordOutcome(c.Request.Context(), req, worker.ID(), err, gatewayErr)
+	if err != nil {
+		if errors.Is(err, core.ErrWorkerPanic) {
+			// 已推送给客户端的 Token 照常计费，再以错误事件 + [DONE] 收尾，避免留下半截流
+			e := h.chargeUsage(c.Request.Context(), req, apiKey, worker.ID(), choices.tokens())
+			logUsage(c, e)
+			_ = writeSSEEvent(c, "error", api.ErrorBody(c, openai.NewServerError(http.StatusInternalServerError, "Internal error while processing the stream").WithCode("internal_error")))
+			if trailer, err := json.Marshal(usageOf(e)); err == nil {
+				c.Writer.Header().Set(UsageTrailer, string(trailer))
+			}
+			_, _ = c.Writer.Write([]byte("data: [DONE]\n\n"))
+			c.Writer.Flush()
+			return
+		}
+
 		// 检查错误类型
-		if errors.Is(err, http.ErrHandlerTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		if errors.Is(err, http.ErrHandlerTimeout) || errors.Is(err, context.DeadlineExceeded) || deadlineExceeded(c.Request.Context()) {
 			// 超时错误
-			_ = writeSSEEvent(c, "error", map[string]interface{}{
-				"error": map[string]interface{}{
-					"message": "Request timeout",
-					"type":    "timeout_error",
-					"code":    "timeout",
-				},
-			})
+			_ = writeSSEEvent(c, "error", api.ErrorBody(c, openai.NewTimeoutError("Request timeout")))
+			return
+		}
+
+		var throttled *core.ThrottledError
+		if errors.As(err, &throttled) {
+			// 尚未输出任何分片时与非流式响应一致，以 503 与 Retry-After 告知客户端
+			if !c.Writer.Written() {
+				if throttled.RetryAfter > 0 {
+					c.Header("Retry-After", strconv.Itoa(int((throttled.RetryAfter+time.Second-1)/time.Second)))
+				}
+				c.Status(http.StatusServiceUnavailable)
+			}
+			_ = writeSSEEvent(c, "error", api.ErrorBody(c, openai.NewServerError(http.StatusServiceUnavailable, "All candidate workers are rate limited upstream, please retry later").WithCode("upstream_throttled")))
+			return
+		}
+
+		if errors.Is(err, core.ErrStreamTruncated) {
+			slog.Warn("worker stream truncated", "trace_id", req.TraceID, "worker_id", worker.ID())
+			// 上游流未正常结束：为每个未结束的 choice 给出独立的 finish_reason，再发送错误事件
+			unfinished := choices.unfinished()
+			if len(choices.choices) == 0 {
+				_ = sendRole(0)
+				unfinished = []int{0}
+			}
+			for _, index := range unfinished {
+				finishReason := core.FinishReasonTruncated
+				_ = writeSSEEvent(c, "data", newChunk(openai.StreamChoice{Index: index, Delta: openai.Delta{}, FinishReason: &finishReason}))
+			}
+			_ = writeSSEEvent(c, "error", api.ErrorBody(c, openai.NewServerError(http.StatusInternalServerError, "Upstream worker ended the stream before completion").WithCode("stream_truncated")))
 			return
 		}
 
 		// 其他错误
-		_ = writeSSEEvent(c, "error", map[string]interface{}{
-			"error": map[string]interface{}{
-				"message": err.Error(),
-				"type":    "server_error",
-				"code":    "internal_error",
-			},
-		})
+		_ = writeSSEEvent(c, "error", api.ErrorBody(c, openai.NewServerError(http.StatusInternalServerError, err.Error()).WithCode("internal_error")))
 		return
 	}
 
+	// 后端未遵守 tool_choice（如 "required" 却没有调用工具）：内容已下发无法撤回，以错误事件告知客户端
+	if violation := choices.toolChoiceViolation(toolChoice); violation != "" {
+		slog.Warn("worker violated tool_choice", "trace_id", req.TraceID, "worker_id", worker.ID(), "violation", violation)
+		_ = writeSSEEvent(c, "error", api.ErrorBody(c, openai.NewServerError(http.StatusBadGateway, violation).WithCode("tool_choice_violation")))
+	}
+
+	// 阶段二：请求完成后按端点倍率扣费，并上报用量
+	e := h.chargeUsage(c.Request.Context(), req, apiKey, worker.ID(), choices.tokens())
+	logUsage(c, e)
+
+	// 在 [DONE] 之前发送用量事件，并写入 X-Zam-Usage Trailer
+	usageInfo := usageOf(e)
+	usageChunk := newChunk()
+	usageChunk.Usage = usageInfo
+	_ = writeSSEEvent(c, "data", usageChunk)
+	if trailer, err := json.Marshal(usageInfo); err == nil {
+		c.Writer.Header().Set(UsageTrailer, string(trailer))
+	}
+
 	// 发送 [DONE] 标记
 	_, _ = c.Writer.Write([]byte("data: [DONE]\n\n"))
 
 	// 确保所有数据已刷新
 	c.Writer.Flush()
+}
 
-	// 阶段二：请求完成后扣费
-	_ = h.limiter.Consume(c.Request.Context(), apiKey, totalTokens)
+// systemFingerprint derives a stable system_fingerprint from the model and the worker serving it,
+// so clients can tell when responses come from a different backend configuration
+func systemFingerprint(model, workerID string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + workerID))
+	return "fp_" + hex.EncodeToString(sum[:5])
+}
+
+// UsageTrailer is the HTTP trailer carrying the usage of a streamed request
+const UsageTrailer = "X-Zam-Usage"
+
+// usageOf converts a usage event into the OpenAI usage object
+func usageOf(e usage.Event) *openai.Usage {
+	return &openai.Usage{
+		PromptTokens:     e.PromptTokens,
+		CompletionTokens: e.CompletionTokens,
+		TotalTokens:      e.TotalTokens,
+		Cost:             e.Cost,
+	}
 }
 
 // handleNonStreamRequest handles non-streaming responses
-func (h *ChatHandler) handleNonStreamRequest(c *gin.Context, worker core.Worker, req *core.InferenceRequest, apiKey string) {
-	var fullContent string
+// legacy renders tool calls as the function_call of the deprecated functions API
+func (h *ChatHandler) handleNonStreamRequest(c *gin.Context, worker core.Worker, req *core.InferenceRequest, apiKey string, toolChoice openai.ToolChoice, legacy bool) {
+	// 按 choice 汇总角色、正文、思考内容与工具调用片段
+	var aggregate responseAggregator
 	totalTokens := 0
 
 	// 创建 sender 回调，收集所有内容
@@ -303,29 +975,50 @@ func (h *ChatHandler) handleNonStreamRequest(c *gin.Context, worker core.Worker,
 		if chunk.Error != nil {
 			return chunk.Error
 		}
-		fullContent += chunk.Content
-		totalTokens += len(chunk.Content)
+		applyToolChoice(toolChoice, &chunk)
+		aggregate.add(chunk)
+		totalTokens += chunkTokens(chunk)
 		return nil
 	}
 
 	// 执行推理 - 透传 c.Request.Context()
-	if err := worker.Execute(c.Request.Context(), req, senderFunc); err != nil {
-		if errors.Is(err, http.ErrHandlerTimeout) || errors.Is(err, context.DeadlineExceeded) {
-			c.JSON(http.StatusRequestTimeout, gin.H{
-				"error": gin.H{
-					"message": "Request timeout",
-					"type":    "timeout_error",
-				},
-			})
+	err := core.SafeExecute(c.Request.Context(), worker, req, senderFunc)
+	h.recordOutcome(c.Request.Context(), req, worker.ID(), err, nil)
+	if err != nil {
+		// 非流式响应尚未下发任何内容，不计费
+		if errors.Is(err, core.ErrWorkerPanic) {
+			api.WriteError(c, openai.NewServerError(http.StatusInternalServerError, "Internal error while processing the response").WithCode("internal_error"))
 			return
 		}
 
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": err.Error(),
-				"type":    "server_error",
-			},
-		})
+		if errors.Is(err, http.ErrHandlerTimeout) || errors.Is(err, context.DeadlineExceeded) || deadlineExceeded(c.Request.Context()) {
+			api.WriteError(c, openai.NewTimeoutError("Request timeout"))
+			return
+		}
+
+		var throttled *core.ThrottledError
+		if errors.As(err, &throttled) {
+			if throttled.RetryAfter > 0 {
+				c.Header("Retry-After", strconv.Itoa(int((throttled.RetryAfter+time.Second-1)/time.Second)))
+			}
+			api.WriteError(c, openai.NewServerError(http.StatusServiceUnavailable, "All candidate workers are rate limited upstream, please retry later").WithCode("upstream_throttled"))
+			return
+		}
+
+		if errors.Is(err, core.ErrStreamTruncated) {
+			slog.Warn("worker stream truncated", "trace_id", req.TraceID, "worker_id", worker.ID())
+			api.WriteError(c, openai.NewServerError(http.StatusBadGateway, "Upstream worker ended the response before completion").WithCode("stream_truncated"))
+			return
+		}
+
+		api.WriteError(c, openai.NewServerError(http.StatusInternalServerError, err.Error()))
+		return
+	}
+
+	// 后端未遵守 tool_choice 时不返回不合规的结果，也不计费
+	if violation := aggregate.toolChoiceViolation(toolChoice); violation != "" {
+		slog.Warn("worker violated tool_choice", "trace_id", req.TraceID, "worker_id", worker.ID(), "violation", violation)
+		api.WriteError(c, openai.NewServerError(http.StatusBadGateway, violation).WithCode("tool_choice_violation"))
 		return
 	}
 
@@ -334,24 +1027,55 @@ func (h *ChatHandler) handleNonStreamRequest(c *gin.Context, worker core.Worker,
 		ID:      "chatcmpl-" + req.TraceID,
 		Object:  "chat.completion",
 		Created: time.Now().Unix(),
-		Model:   req.Model,
-		Choices: []openai.Choice{
-			{
-				Index: 0,
-				Message: openai.Message{
-					Role:    "assistant",
-					Content: fullContent,
-				},
-				FinishReason: "stop",
-			},
-		},
+		Model:   req.RequestedModel,
+		Choices: aggregate.result(),
+	}
+	if legacy {
+		legacyFunctionChoices(response.Choices)
 	}
+	response.SystemFingerprint = systemFingerprint(req.RequestedModel, worker.ID())
+
+	// 阶段二：请求完成后按端点倍率扣费，并上报用量
+	e := h.chargeUsage(c.Request.Context(), req, apiKey, worker.ID(), totalTokens)
+	logUsage(c, e)
+	response.Usage = usageOf(e)
 
 	// 使用 Gin 的 JSON 响应
 	c.JSON(http.StatusOK, response)
+}
+
+// adoptStreamHeader takes the id, created and system_fingerprint of a passthrough stream from its first chunk
+func adoptStreamHeader(header *openai.ChatCompletionStreamResponse, raw []byte) {
+	var upstream struct {
+		ID                string `json:"id"`
+		Created           int64  `json:"created"`
+		SystemFingerprint string `json:"system_fingerprint"`
+	}
+	if err := json.Unmarshal(raw, &upstream); err != nil {
+		return
+	}
+	if upstream.ID != "" {
+		header.ID = upstream.ID
+	}
+	if upstream.Created > 0 {
+		header.Created = upstream.Created
+	}
+	if upstream.SystemFingerprint != "" {
+		header.SystemFingerprint = upstream.SystemFingerprint
+	}
+}
 
-	// 阶段二：请求完成后扣费
-	_ = h.limiter.Consume(c.Request.Context(), apiKey, totalTokens)
+// writeSSEData writes an already encoded data payload as an SSE event
+func writeSSEData(c *gin.Context, data []byte) error {
+	buf := make([]byte, 0, len(data)+8)
+	buf = append(buf, "data: "...)
+	buf = append(buf, data...)
+	buf = append(buf, "\n\n"...)
+	if _, err := c.Writer.Write(buf); err != nil {
+		return fmt.Errorf("failed to write data: %w", err)
+	}
+	c.Writer.Flush()
+	return nil
 }
 
 // writeSSEEvent writes an SSE event to the Gin response writer
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"zam/core"
+	"zam/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testWorker serves every model; run decides each Execute, answering "ok" when nil
+type testWorker struct {
+	id string
+	// maxTasks is the reported capacity, 100 when zero
+	maxTasks int
+	calls    atomic.Int32
+	active   atomic.Int32
+	run      func(ctx context.Context, sender func(core.StreamChunk) error) error
+}
+
+func (w *testWorker) ID() string { return w.id }
+
+func (w *testWorker) Heartbeat(ctx context.Context) (core.WorkerProfile, error) {
+	maxTasks := w.maxTasks
+	if maxTasks == 0 {
+		maxTasks = 100
+	}
+	return core.WorkerProfile{
+		WorkerID:      w.id,
+		Supported:     []string{"*"},
+		TotalVRAM:     80 << 30,
+		AvailableVRAM: 80 << 30,
+		ActiveTasks:   int(w.active.Load()),
+		MaxTasks:      maxTasks,
+	}, nil
+}
+
+func (w *testWorker) Execute(ctx context.Context, req *core.InferenceRequest, sender func(core.StreamChunk) error) error {
+	w.calls.Add(1)
+	w.active.Add(1)
+	defer w.active.Add(-1)
+	if w.run != nil {
+		return w.run(ctx, sender)
+	}
+	return sender(core.StreamChunk{Content: "ok", FinishReason: "stop"})
+}
+
+// newTestHandler serves POST /v1/chat/completions with a ChatHandler routing to workers
+func newTestHandler(t *testing.T, workers ...core.Worker) (*ChatHandler, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	registry := core.NewInMemoryRegistry(ctx)
+	for _, w := range workers {
+		profile, _ := w.Heartbeat(ctx)
+		registry.RegisterWorker(w, profile)
+	}
+	limiter := core.NewInMemoryRateLimiter()
+	limiter.SetBalance("test-key-123", 1_000_000)
+
+	h := NewChatHandlerWithRegistry(router.NewScoreRouter(), registry, limiter)
+	engine := gin.New()
+	engine.POST("/v1/chat/completions", h.Handle)
+	return h, engine
+}
+
+// postChat sends a chat completion for llama-8b and returns the recorded response
+func postChat(engine *gin.Engine, stream bool) *httptest.ResponseRecorder {
+	body := `{"model":"llama-8b","messages":[{"role":"user","content":"hi"}]`
+	if stream {
+		body += `,"stream":true`
+	}
+	body += "}"
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-key-123")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestChatHandler_KeyConcurrency(t *testing.T) {
+	unblock := make(chan struct{})
+	w := &testWorker{id: "gpu-1", maxTasks: 1, run: func(ctx context.Context, sender func(core.StreamChunk) error) error {
+		<-unblock
+		return sender(core.StreamChunk{Content: "ok", FinishReason: "stop"})
+	}}
+	h, engine := newTestHandler(t, w)
+	h.SetInflightTracker(core.NewInflightTracker())
+	h.SetKeyConcurrency(2)
+	h.SetWaitQueue(core.NewWaitQueue(16, 5*time.Second))
+
+	// 同时发出的请求在准入时原子占用名额，排队中的请求同样计入，超过上限的立即返回 429
+	const burst = 8
+	statuses := make(chan int, burst)
+	var wg sync.WaitGroup
+	for i := 0; i < burst; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- postChat(engine, false).Code
+		}()
+	}
+
+	for i := 0; i < burst-2; i++ {
+		select {
+		case code := <-statuses:
+			if code != http.StatusTooManyRequests {
+				t.Errorf("expected requests over the cap to get 429, got %d", code)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %d rejections, got %d", burst-2, i)
+		}
+	}
+	close(unblock)
+	wg.Wait()
+	close(statuses)
+	for code := range statuses {
+		if code != http.StatusOK {
+			t.Errorf("expected the admitted requests to succeed, got %d", code)
+		}
+	}
+	// 一个直接执行，一个排队后执行
+	if n := w.calls.Load(); n != 2 {
+		t.Errorf("expected 2 requests to reach the worker, got %d", n)
+	}
+
+	// 名额在请求结束后释放
+	if rec := postChat(engine, false); rec.Code != http.StatusOK {
+		t.Errorf("expected a request after the burst to succeed, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestChat_ThrottledError(t *testing.T) {
+	const envelope = `{"error":{"message":"All candidate workers are rate limited upstream, please retry later","type":"server_error","param":null,"code":"upstream_throttled"}}`
+	tests := []struct {
+		name   string
+		stream bool
+		body   string
+	}{
+		{name: "非流式", stream: false, body: envelope},
+		{name: "流式", stream: true, body: "event: error\ndata: " + envelope + "\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &testWorker{id: "gpu-a", run: func(ctx context.Context, sender func(core.StreamChunk) error) error {
+				return &core.ThrottledError{Code: "rate_limit_exceeded", RetryAfter: 90 * time.Second}
+			}}
+			_, engine := newTestHandler(t, w)
+
+			// 所有候选都被上游限流时两种响应一致：503、Retry-After 与 OpenAI 格式的错误对象
+			rec := postChat(engine, tt.stream)
+			if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "90" {
+				t.Errorf("expected 503 with Retry-After 90, got %d and %q", rec.Code, rec.Header().Get("Retry-After"))
+			}
+			if body := rec.Body.String(); body != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"zam/api"
+	"zam/core"
+	"zam/metrics"
+	"zam/openai"
+	"zam/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetEmbedOptions sets how large embeddings requests are split into batches across workers
+func (h *ChatHandler) SetEmbedOptions(opts core.EmbedBatchOptions) {
+	h.embed = opts
+}
+
+// HandleEmbeddings serves the OpenAI embeddings API on workers that run embedding models
+// Inputs are split into batches no larger than each worker accepts and embedded in parallel
+func (h *ChatHandler) HandleEmbeddings(c *gin.Context) {
+	apiKey := h.extractAPIKey(c)
+	if apiKey == "" {
+		api.WriteError(c, openai.NewAuthenticationError("Missing or invalid Authorization header"))
+		return
+	}
+	api.SetLogFields(c, "api_key", metrics.HashKey(apiKey))
+	if !h.admitKey(c, apiKey) {
+		return
+	}
+
+	var req openai.EmbeddingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.WriteError(c, openai.NewInvalidRequestError("Invalid request body: "+err.Error()))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		api.WriteError(c, err)
+		return
+	}
+
+	model := req.Model
+	if target, ok := h.modelAliases().Resolve(model); ok {
+		model = target
+	}
+	traceID := requestTraceID(c)
+	api.SetLogFields(c, "trace_id", traceID, "model", req.Model)
+	ctx := context.WithValue(c.Request.Context(), core.TraceKey, traceID)
+
+	embedReq := &core.EmbedRequest{
+		TraceID:    traceID,
+		Tenant:     apiKey,
+		Model:      model,
+		Inputs:     req.Input,
+		Dimensions: req.Dimensions,
+	}
+	served := &servedWorkers{}
+	result, err := core.EmbedBatched(ctx, embedReq, h.embedPicker(served), h.embed)
+	if err != nil {
+		switch {
+		case errors.Is(err, context.Canceled) && ctx.Err() != nil:
+			// 客户端已断开，无需响应
+		case deadlineExceeded(ctx):
+			api.WriteError(c, openai.NewTimeoutError("Request timeout while embedding inputs"))
+		case errors.Is(err, core.ErrNoAvailableWorkers):
+			api.WriteError(c, openai.NewServerError(http.StatusServiceUnavailable, fmt.Sprintf("No worker serving embeddings for model %s is available", req.Model)))
+		default:
+			api.WriteError(c, openai.NewServerError(http.StatusBadGateway, fmt.Sprintf("Failed to embed inputs: %v", err)))
+		}
+		return
+	}
+
+	promptTokens := 0
+	for _, text := range req.Input {
+		promptTokens += estimateTokens(text)
+	}
+	e := h.meter.Record(usage.Event{
+		RequestID:    traceID,
+		Key:          apiKey,
+		User:         req.User,
+		Model:        model,
+		WorkerID:     served.String(),
+		PromptTokens: promptTokens,
+		Endpoint:     usage.EndpointEmbeddings,
+	})
+	_ = h.limiter.Consume(ctx, apiKey, e.BilledTokens)
+	logUsage(c, e)
+
+	resp := openai.EmbeddingResponse{
+		Object: "list",
+		Data:   make([]openai.Embedding, len(result.Vectors)),
+		Model:  req.Model,
+		Usage:  openai.EmbeddingUsage{PromptTokens: promptTokens, TotalTokens: promptTokens},
+	}
+	for i, vector := range result.Vectors {
+		resp.Data[i] = openai.Embedding{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: openai.EncodeEmbedding(vector, req.EncodingFormat),
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// embedPicker routes each batch through the router among the schedulable workers that serve
+// embeddings, skipping workers that already failed the batch
+func (h *ChatHandler) embedPicker(served *servedWorkers) core.EmbedPicker {
+	return func(ctx context.Context, batch *core.EmbedRequest, exclude map[string]bool) (core.Worker, int, error) {
+		workers := h.registry.GetAvailableWorkers()
+		if h.quarantine != nil {
+			workers = h.quarantine.Filter(workers)
+		}
+		var embedders []core.Worker
+		for _, w := range h.throttles.Filter(workers) {
+			if _, ok := w.(core.Embedder); ok && !exclude[w.ID()] {
+				embedders = append(embedders, w)
+			}
+		}
+		if len(embedders) == 0 {
+			return nil, 0, core.ErrNoAvailableWorkers
+		}
+		selected, err := selectDispatch(ctx, h.router, h.quarantine, embedders, &core.InferenceRequest{
+			TraceID: batch.TraceID,
+			Tenant:  batch.Tenant,
+			Model:   batch.Model,
+			Needs:   core.Capabilities{Embeddings: true},
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		profile, _ := h.workerProfile(ctx, selected)
+		return &embedWorker{Worker: selected, h: h, served: served}, profile.Capabilities.MaxBatchSize, nil
+	}
+}
+
+// embedWorker counts a batch as in flight on its worker and feeds the outcome into quarantine,
+// throttle cool-down and the fallback budget
+type embedWorker struct {
+	core.Worker
+	h      *ChatHandler
+	served *servedWorkers
+}
+
+func (w *embedWorker) Embed(ctx context.Context, req *core.EmbedRequest) ([][]float32, error) {
+	if w.h.inflight != nil {
+		release := w.h.inflight.AcquireModel(w.ID(), req.Tenant, req.Model)
+		defer release()
+	}
+	vectors, err := w.Worker.(core.Embedder).Embed(ctx, req)
+
+	var throttled *core.ThrottledError
+	switch {
+	case err == nil:
+		w.served.Add(w.ID())
+		if w.h.quarantine != nil {
+			w.h.quarantine.RecordSuccess(w.ID())
+		}
+		tokens := 0
+		for _, text := range req.Inputs {
+			tokens += estimateTokens(text)
+		}
+		w.h.chargeFallback(w.ID(), tokens, 0)
+	case ctx.Err() != nil:
+		// 客户端断开或批次已取消，不计入 Worker 失败
+	case errors.As(err, &throttled) && w.h.throttles != nil:
+		// 上游限流：冷却该 Worker，重试批次时不再选中
+		w.h.throttles.Throttle(w.ID(), throttled.RetryAfter)
+	case errors.As(err, &throttled):
+		// 未启用冷却时上游限流同样不计入 Worker 失败
+	case w.h.quarantine != nil:
+		w.h.quarantine.RecordFailure(w.ID())
+	}
+	return vectors, err
+}
+
+// servedWorkers collects the IDs of the workers that embedded batches of one request
+type servedWorkers struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (s *servedWorkers) Add(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.ids {
+		if existing == id {
+			return
+		}
+	}
+	s.ids = append(s.ids, id)
+}
+
+func (s *servedWorkers) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.Join(s.ids, ",")
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"zam/core"
+)
+
+// SetMaxAttempts lets a request whose worker fails before any output was sent be re-routed
+// to the next-best worker, up to maxAttempts executions in total (1 or less disables retries)
+func (h *ChatHandler) SetMaxAttempts(maxAttempts int) {
+	h.attempts = maxAttempts
+}
+
+// failover executes on the selected worker and re-routes the request among the remaining candidates
+// when it fails before any output was produced: a worker throttled upstream is put into cool-down,
+// any other failed worker is excluded for the rest of the request
+// Each attempt is counted in flight on the worker actually executing it
+// ID reports the worker currently serving the request
+type failover struct {
+	core.Worker
+	router      core.Router
+	throttles   *core.ThrottleTracker
+	quarantine  *core.Quarantine
+	inflight    *core.InflightTracker
+	tenant      string
+	candidates  []core.Worker
+	maxAttempts int
+	// dispatched is called once the first attempt is counted in flight (nil to skip)
+	dispatched func()
+}
+
+// withFailover wraps the selected worker for tenant; dispatched is called once the first attempt is
+// counted in flight. Without an in-flight tracker, throttle tracker or retries the worker is returned
+// unchanged and dispatched called right away, unless it is a fallback worker, which always fails over
+// to the next fallback
+func (h *ChatHandler) withFailover(selected core.Worker, candidates []core.Worker, fallback bool, tenant string, dispatched func()) core.Worker {
+	if h.inflight == nil && h.throttles == nil && h.attempts <= 1 && !fallback {
+		dispatched()
+		return selected
+	}
+	return &failover{
+		Worker:      selected,
+		router:      h.router,
+		throttles:   h.throttles,
+		quarantine:  h.quarantine,
+		inflight:    h.inflight,
+		tenant:      tenant,
+		candidates:  candidates,
+		maxAttempts: h.attempts,
+		dispatched:  dispatched,
+	}
+}
+
+func (f *failover) Execute(ctx context.Context, req *core.InferenceRequest, sender func(chunk core.StreamChunk) error) error {
+	attempts, throttleRetries := 1, 0
+	for {
+		sent := false
+		err := f.attempt(ctx, req, func(chunk core.StreamChunk) error {
+			sent = true
+			return sender(chunk)
+		})
+		// 客户端已收到内容或已断开时不能重试
+		if err == nil || sent || ctx.Err() != nil {
+			return err
+		}
+
+		var throttled *core.ThrottledError
+		if errors.As(err, &throttled) {
+			if f.throttles == nil {
+				return err
+			}
+			coolDown := f.throttles.Throttle(f.Worker.ID(), throttled.RetryAfter)
+			slog.Warn("worker throttled upstream", "trace_id", req.TraceID, "worker_id", f.Worker.ID(), "error", throttled, "cool_down", coolDown.String())
+			if throttleRetries >= maxThrottleRetries {
+				return err
+			}
+			throttleRetries++
+			// 换一个未处于冷却中的候选重试
+			f.candidates = f.throttles.Filter(f.candidates)
+		} else if req.Fallback {
+			// 兜底 Worker 失败时按优先级改用下一个兜底 Worker，不占用重试次数
+			slog.Warn("fallback worker failed before any output, trying the next fallback", "trace_id", req.TraceID, "worker_id", f.Worker.ID(), "error", err)
+			if f.quarantine != nil {
+				f.quarantine.RecordFailure(f.Worker.ID())
+			}
+			f.candidates = excludeWorker(f.candidates, f.Worker.ID())
+		} else {
+			if attempts >= f.maxAttempts {
+				return err
+			}
+			attempts++
+			slog.Warn("worker failed before any output, retrying on another worker", "trace_id", req.TraceID, "worker_id", f.Worker.ID(), "error", err)
+			// 最终结果由 recordOutcome 记录，被换下的 Worker 在这里计入失败
+			if f.quarantine != nil {
+				f.quarantine.RecordFailure(f.Worker.ID())
+			}
+			f.candidates = excludeWorker(f.candidates, f.Worker.ID())
+		}
+
+		if len(f.candidates) == 0 {
+			return err
+		}
+		// 由路由器按重新选择的结果标记是否为兜底
+		req.Fallback = false
+		next, selErr := selectDispatch(ctx, f.router, f.quarantine, f.candidates, req)
+		if selErr != nil {
+			return err
+		}
+		f.Worker = next
+	}
+}
+
+// attempt executes req on the current worker, counting it in flight there for the duration
+func (f *failover) attempt(ctx context.Context, req *core.InferenceRequest, sender func(chunk core.StreamChunk) error) error {
+	if f.inflight != nil {
+		release := f.inflight.AcquireModel(f.Worker.ID(), f.tenant, req.Model)
+		defer release()
+	}
+	if f.dispatched != nil {
+		f.dispatched()
+		f.dispatched = nil
+	}
+	return f.Worker.Execute(ctx, req, sender)
+}
+
+// excludeWorker returns the candidates other than workerID
+func excludeWorker(candidates []core.Worker, workerID string) []core.Worker {
+	remaining := make([]core.Worker, 0, len(candidates))
+	for _, w := range candidates {
+		if w.ID() != workerID {
+			remaining = append(remaining, w)
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"zam/core"
+)
+
+func TestFailover_RetriesBeforeFirstChunk(t *testing.T) {
+	inflight := core.NewInflightTracker()
+	// 第二次尝试时，在途计数应已从失败的 Worker 转到实际执行的 Worker
+	var failed string
+	var failedCount, servingCount int
+	run := func(w *testWorker) func(ctx context.Context, sender func(core.StreamChunk) error) error {
+		return func(ctx context.Context, sender func(core.StreamChunk) error) error {
+			if failed == "" {
+				failed = w.id
+				return errors.New("connection refused")
+			}
+			failedCount, servingCount = inflight.WorkerCount(failed), inflight.WorkerCount(w.id)
+			return sender(core.StreamChunk{Content: "ok", FinishReason: "stop"})
+		}
+	}
+	a, b := &testWorker{id: "gpu-a"}, &testWorker{id: "gpu-b"}
+	a.run, b.run = run(a), run(b)
+	h, engine := newTestHandler(t, a, b)
+	h.SetMaxAttempts(2)
+	h.SetInflightTracker(inflight)
+
+	rec := postChat(engine, false)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the retry to succeed, got %d: %s", rec.Code, rec.Body)
+	}
+	if a.calls.Load() != 1 || b.calls.Load() != 1 {
+		t.Errorf("expected one attempt on each worker, got %d and %d", a.calls.Load(), b.calls.Load())
+	}
+	if failedCount != 0 || servingCount != 1 {
+		t.Errorf("expected the retry counted on the serving worker only, got failed=%d serving=%d", failedCount, servingCount)
+	}
+	if n := inflight.WorkerCount("gpu-a") + inflight.WorkerCount("gpu-b"); n != 0 {
+		t.Errorf("expected every attempt released, got %d in flight", n)
+	}
+}
+
+func TestFailover_NoRetryAfterOutput(t *testing.T) {
+	run := func(ctx context.Context, sender func(core.StreamChunk) error) error {
+		if err := sender(core.StreamChunk{Content: "partial"}); err != nil {
+			return err
+		}
+		return errors.New("stream reset")
+	}
+	a, b := &testWorker{id: "gpu-a", run: run}, &testWorker{id: "gpu-b", run: run}
+	h, engine := newTestHandler(t, a, b)
+	h.SetMaxAttempts(3)
+
+	postChat(engine, true)
+	if n := a.calls.Load() + b.calls.Load(); n != 1 {
+		t.Errorf("expected no retry once output was streamed, got %d attempts", n)
+	}
+}
+
+func TestFailover_AttemptCap(t *testing.T) {
+	run := func(ctx context.Context, sender func(core.StreamChunk) error) error {
+		return errors.New("connection refused")
+	}
+	workers := []*testWorker{{id: "gpu-a", run: run}, {id: "gpu-b", run: run}, {id: "gpu-c", run: run}}
+	h, engine := newTestHandler(t, workers[0], workers[1], workers[2])
+	h.SetMaxAttempts(2)
+
+	if rec := postChat(engine, false); rec.Code == http.StatusOK {
+		t.Fatal("expected the request to fail once every attempt failed")
+	}
+	var attempts int32
+	for _, w := range workers {
+		attempts += w.calls.Load()
+	}
+	if attempts != 2 {
+		t.Errorf("expected the attempts capped at 2, got %d", attempts)
+	}
+}
+
+func TestFailover_ThrottleCoolDown(t *testing.T) {
+	var failed string
+	run := func(w *testWorker) func(ctx context.Context, sender func(core.StreamChunk) error) error {
+		return func(ctx context.Context, sender func(core.StreamChunk) error) error {
+			if failed == "" {
+				failed = w.id
+				return &core.ThrottledError{Code: "rate_limit_exceeded", RetryAfter: time.Minute}
+			}
+			return sender(core.StreamChunk{Content: "ok", FinishReason: "stop"})
+		}
+	}
+	a, b := &testWorker{id: "gpu-a"}, &testWorker{id: "gpu-b"}
+	a.run, b.run = run(a), run(b)
+	h, engine := newTestHandler(t, a, b)
+	throttles := core.NewThrottleTracker()
+	h.SetThrottleTracker(throttles)
+
+	// 上游 429 不占用重试次数：冷却该 Worker 后换候选执行
+	if rec := postChat(engine, false); rec.Code != http.StatusOK {
+		t.Fatalf("expected the throttled request to move to the other worker, got %d: %s", rec.Code, rec.Body)
+	}
+	if !throttles.CoolingDown(failed) {
+		t.Errorf("expected %s to cool down after a 429", failed)
+	}
+	// 冷却期间不再被选中
+	calls := a.calls.Load() + b.calls.Load()
+	if rec := postChat(engine, false); rec.Code != http.StatusOK {
+		t.Fatalf("expected a later request to succeed, got %d", rec.Code)
+	}
+	throttled := a
+	if failed == b.id {
+		throttled = b
+	}
+	if throttled.calls.Load() != 1 || a.calls.Load()+b.calls.Load() != calls+1 {
+		t.Errorf("expected the cooling-down worker to be skipped, got %d calls on it", throttled.calls.Load())
+	}
+}
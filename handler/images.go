@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"zam/core"
+	"zam/openai"
+)
+
+// SetImageProxy enables validation of image_url parts and, depending on its policy,
+// fetching remote images at the gateway
+func (h *ChatHandler) SetImageProxy(proxy *core.ImageProxy) {
+	h.images = proxy
+}
+
+// resolveImages validates every image part in place, replacing remote URLs with the data URIs
+// the proxy fetched; a nil proxy leaves the messages untouched
+func resolveImages(ctx context.Context, proxy *core.ImageProxy, messages []openai.Message) *openai.Error {
+	if proxy == nil {
+		return nil
+	}
+	for i := range messages {
+		for j := range messages[i].Parts {
+			part := &messages[i].Parts[j]
+			if part.Type != "image_url" || part.ImageURL == nil {
+				continue
+			}
+			resolved, err := proxy.Resolve(ctx, part.ImageURL.URL)
+			if err != nil {
+				return openai.NewInvalidRequestError(fmt.Sprintf("messages[%d].content[%d]: %v", i, j, err)).WithParam("messages").WithCode("invalid_image")
+			}
+			part.ImageURL.URL = resolved
+		}
+	}
+	return nil
+}
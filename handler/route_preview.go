@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+
+	"zam/api"
+	"zam/core"
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleRoutePreview runs the routing pipeline for a hypothetical request and returns
+// the chosen worker plus ranked candidates, without dispatching or billing
+func (h *ChatHandler) HandleRoutePreview(c *gin.Context) {
+	apiKey := h.extractAPIKey(c)
+	if apiKey == "" {
+		api.WriteError(c, openai.NewAuthenticationError("Missing or invalid Authorization header"))
+		return
+	}
+	if !h.admitKey(c, apiKey) {
+		return
+	}
+
+	previewer, ok := h.router.(core.RoutePreviewer)
+	if !ok {
+		api.WriteError(c, openai.NewError(http.StatusNotImplemented, openai.ServerErrorType, "Configured router does not support previews"))
+		return
+	}
+
+	req, ok := bindChatRequest(c)
+	if !ok {
+		return
+	}
+	inferenceReq := h.newInferenceRequest(req, apiKey, "preview-"+requestTraceID(c))
+	inferenceReq.Federated = c.GetHeader(core.FederationHeader) != ""
+	priority, perr := api.RequestPriority(c, h.priorities, apiKey, h.priorities.Class(apiKey))
+	if perr != nil {
+		api.WriteError(c, perr)
+		return
+	}
+	inferenceReq.Priority = priority
+	strategy, serr := api.RequestStrategy(c, h.router)
+	if serr != nil {
+		api.WriteError(c, serr)
+		return
+	}
+	inferenceReq.Strategy = strategy
+	inferenceReq.Session = h.affinity.Key(apiKey, c.GetHeader(core.SessionHeader), inferenceReq.Model, inferenceReq.Messages)
+
+	decision, _ := h.preview(c.Request.Context(), previewer, inferenceReq)
+	c.JSON(http.StatusOK, decision)
+}
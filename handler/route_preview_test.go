@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"zam/core"
+)
+
+func TestRoutePreview_AdmitsKeyAndSkipsQuarantined(t *testing.T) {
+	h, engine := newTestHandler(t, &testWorker{id: "gpu-a"}, &testWorker{id: "gpu-b"})
+	quarantine := core.NewQuarantine(core.QuarantinePolicy{MaxFailures: 1, CoolDown: time.Minute, ProbeSuccesses: 1}, nil)
+	quarantine.RecordFailure("gpu-a")
+	h.SetQuarantine(quarantine)
+	engine.POST("/v1/route/preview", h.HandleRoutePreview)
+
+	preview := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/route/preview", strings.NewReader(`{"model":"llama-8b","messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("Authorization", "Bearer "+key)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// 未知的 API Key 不能借预览枚举 Worker
+	if rec := preview("sk-unknown"); rec.Code != http.StatusTooManyRequests || strings.Contains(rec.Body.String(), "gpu-") {
+		t.Fatalf("expected an unknown key to be refused, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec := preview("test-key-123")
+	var decision core.RouteDecision
+	if err := json.Unmarshal(rec.Body.Bytes(), &decision); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected a route decision, got %d: %s", rec.Code, rec.Body)
+	}
+	if decision.Selected != "gpu-b" {
+		t.Errorf("expected the healthy worker gpu-b, got %q", decision.Selected)
+	}
+	var excluded string
+	for _, candidate := range decision.Candidates {
+		if candidate.WorkerID == "gpu-a" {
+			excluded = candidate.Excluded
+		}
+	}
+	if excluded != "quarantined" {
+		t.Errorf("expected gpu-a listed as quarantined, got %q", excluded)
+	}
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"zam/core"
+)
+
+// streamFrame renders the exact SSE data frame the handler writes for a chunk of the stream in body,
+// taking the id and created of the stream from its first frame
+func streamFrame(t *testing.T, body, choices, extra string) string {
+	t.Helper()
+	first, _, _ := strings.Cut(strings.TrimPrefix(body, "data: "), "\n")
+	var header struct {
+		ID      string `json:"id"`
+		Created int64  `json:"created"`
+	}
+	if err := json.Unmarshal([]byte(first), &header); err != nil {
+		t.Fatalf("expected a JSON chunk first, got %q", body)
+	}
+	return fmt.Sprintf(`data: {"id":%q,"object":"chat.completion.chunk","created":%d,"model":"llama-8b","choices":%s%s,"system_fingerprint":%q}`+"\n\n",
+		header.ID, header.Created, choices, extra, systemFingerprint("llama-8b", "gpu-a"))
+}
+
+func TestStream_Framing(t *testing.T) {
+	w := &testWorker{id: "gpu-a", run: func(ctx context.Context, sender func(core.StreamChunk) error) error {
+		if err := sender(core.StreamChunk{Content: "Hel"}); err != nil {
+			return err
+		}
+		return sender(core.StreamChunk{Content: "lo", FinishReason: "stop"})
+	}}
+	_, engine := newTestHandler(t, w)
+
+	body := postChat(engine, true).Body.String()
+	// 首个分片只带 role，finish_reason 以空 delta 单独发送，用量分片之后以 [DONE] 结束
+	want := streamFrame(t, body, `[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}]`, "") +
+		streamFrame(t, body, `[{"index":0,"delta":{"content":"Hel"},"finish_reason":null}]`, "") +
+		streamFrame(t, body, `[{"index":0,"delta":{"content":"lo"},"finish_reason":null}]`, "") +
+		streamFrame(t, body, `[{"index":0,"delta":{},"finish_reason":"stop"}]`, "") +
+		streamFrame(t, body, `[]`, `,"usage":{"prompt_tokens":6,"completion_tokens":5,"total_tokens":11}`) +
+		"data: [DONE]\n\n"
+	if body != want {
+		t.Errorf("stream frames =\n%q\nwant\n%q", body, want)
+	}
+}
+
+func TestStream_ErrorFrame(t *testing.T) {
+	w := &testWorker{id: "gpu-a", run: func(ctx context.Context, sender func(core.StreamChunk) error) error {
+		if err := sender(core.StreamChunk{Content: "Hel"}); err != nil {
+			return err
+		}
+		return errors.New("connection reset")
+	}}
+	_, engine := newTestHandler(t, w)
+
+	body := postChat(engine, true).Body.String()
+	// 输出开始后的失败以 error 事件结束，不发送 [DONE]
+	want := streamFrame(t, body, `[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}]`, "") +
+		streamFrame(t, body, `[{"index":0,"delta":{"content":"Hel"},"finish_reason":null}]`, "") +
+		"event: error\n" +
+		`data: {"error":{"message":"connection reset","type":"server_error","param":null,"code":"internal_error"}}` + "\n\n"
+	if body != want {
+		t.Errorf("stream frames =\n%q\nwant\n%q", body, want)
+	}
+}
+
+func TestStream_PerChoiceFraming(t *testing.T) {
+	w := &testWorker{id: "gpu-a", run: func(ctx context.Context, sender func(core.StreamChunk) error) error {
+		for _, chunk := range []core.StreamChunk{
+			{Index: 0, Content: "Hi"},
+			{Index: 1, Reasoning: "Hmm"},
+			{Index: 0, FinishReason: "length"},
+			{Index: 1, FinishReason: "stop"},
+		} {
+			if err := sender(chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}
+	_, engine := newTestHandler(t, w)
+
+	body := postChat(engine, true).Body.String()
+	// 每个 choice 各自发送 role 分片与 finish_reason，用量为所有 choice 之和
+	want := streamFrame(t, body, `[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}]`, "") +
+		streamFrame(t, body, `[{"index":0,"delta":{"content":"Hi"},"finish_reason":null}]`, "") +
+		streamFrame(t, body, `[{"index":1,"delta":{"role":"assistant","content":""},"finish_reason":null}]`, "") +
+		streamFrame(t, body, `[{"index":1,"delta":{"reasoning_content":"Hmm"},"finish_reason":null}]`, "") +
+		streamFrame(t, body, `[{"index":0,"delta":{},"finish_reason":"length"}]`, "") +
+		streamFrame(t, body, `[{"index":1,"delta":{},"finish_reason":"stop"}]`, "") +
+		streamFrame(t, body, `[]`, `,"usage":{"prompt_tokens":6,"completion_tokens":5,"total_tokens":11}`) +
+		"data: [DONE]\n\n"
+	if body != want {
+		t.Errorf("stream frames =\n%q\nwant\n%q", body, want)
+	}
+}
+
+func TestStream_UsageChunk(t *testing.T) {
+	_, engine := newTestHandler(t, &testWorker{id: "gpu-a"})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(
+		`{"model":"llama-8b","messages":[{"role":"user","content":"hi"}],"stream":true,"stream_options":{"include_usage":true}}`))
+	req.Header.Set("Authorization", "Bearer test-key-123")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+
+	// 最后一个数据分片在 [DONE] 之前，choices 为空并携带用量；同样的用量写入 Trailer
+	body := rec.Body.String()
+	frames := strings.Split(strings.TrimSuffix(body, "\n\n"), "\n\n")
+	if len(frames) < 2 || frames[len(frames)-1] != "data: [DONE]" {
+		t.Fatalf("expected the stream to end with [DONE], got %q", body)
+	}
+	usage := `,"usage":{"prompt_tokens":6,"completion_tokens":2,"total_tokens":8}`
+	if want := streamFrame(t, body, `[]`, usage); frames[len(frames)-2]+"\n\n" != want {
+		t.Errorf("usage chunk = %q, want %q", frames[len(frames)-2], want)
+	}
+	if trailer := rec.Result().Trailer.Get(UsageTrailer); trailer != `{"prompt_tokens":6,"completion_tokens":2,"total_tokens":8}` {
+		t.Errorf("expected the usage in the %s trailer, got %q", UsageTrailer, trailer)
+	}
+}
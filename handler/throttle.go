@@ -0,0 +1,12 @@
+package handler
+
+import "zam/core"
+
+// maxThrottleRetries bounds how many other candidates a throttled request is moved to
+const maxThrottleRetries = 2
+
+// SetThrottleTracker enables cooling down workers throttled upstream (429) and retrying
+// their requests on another candidate
+func (h *ChatHandler) SetThrottleTracker(tracker *core.ThrottleTracker) {
+	h.throttles = tracker
+}
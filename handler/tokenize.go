@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"zam/api"
+	"zam/core"
+	"zam/openai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleTokenize counts the tokens of a conversation for a model without running inference
+// The key is authenticated and rate limited like a completion. The worker a completion would be
+// routed to is previewed and its tokenizer used when it exposes one; otherwise the gateway
+// estimate is returned
+func (h *ChatHandler) HandleTokenize(c *gin.Context) {
+	apiKey := h.extractAPIKey(c)
+	if apiKey == "" {
+		api.WriteError(c, openai.NewAuthenticationError("Missing or invalid Authorization header"))
+		return
+	}
+	if !h.admitKey(c, apiKey) {
+		return
+	}
+
+	var req openai.TokenizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.WriteError(c, openai.NewInvalidRequestError("Invalid request body: "+err.Error()))
+		return
+	}
+	if req.Model == "" || len(req.Messages) == 0 {
+		api.WriteError(c, openai.NewInvalidRequestError("model and messages are required"))
+		return
+	}
+	if err := openai.ValidateMessages(req.Messages); err != nil {
+		api.WriteError(c, err)
+		return
+	}
+
+	chatReq := &openai.ChatCompletionRequest{Model: req.Model, Messages: req.Messages}
+	inferenceReq := h.newInferenceRequest(chatReq, apiKey, "tokenize-"+requestTraceID(c))
+	inferenceReq.Federated = c.GetHeader(core.FederationHeader) != ""
+	// 不按上下文长度过滤，以便告知客户端是否放得下
+	inferenceReq.Needs.MaxContext = 0
+
+	resp := openai.TokenizeResponse{
+		Object:    "tokenize",
+		Model:     req.Model,
+		Count:     inferenceReq.PromptTokens,
+		Estimated: true,
+	}
+
+	ctx := c.Request.Context()
+	if worker := h.previewWorker(ctx, inferenceReq); worker != nil {
+		if tokenizer, ok := worker.(core.Tokenizer); ok {
+			if ids, err := tokenizer.Tokenize(ctx, inferenceReq.Model, req.Messages); err == nil && len(ids) > 0 {
+				resp.Count, resp.Estimated = len(ids), false
+				if req.ReturnTokenIDs {
+					resp.Tokens = ids
+				}
+			}
+		}
+		if profile, ok := h.workerProfile(ctx, worker); ok && profile.Capabilities.MaxContext > 0 {
+			fits := resp.Count <= profile.Capabilities.MaxContext
+			resp.MaxContext, resp.Fits = profile.Capabilities.MaxContext, &fits
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// previewWorker returns the worker req would be routed to, or nil when the router cannot preview
+// or no worker fits. Unlike route it leaves no trace: no probe is reserved and no placement recorded
+func (h *ChatHandler) previewWorker(ctx context.Context, req *core.InferenceRequest) core.Worker {
+	previewer, ok := h.router.(core.RoutePreviewer)
+	if !ok {
+		return nil
+	}
+	decision, workers := h.preview(ctx, previewer, req)
+	for _, w := range workers {
+		if w.ID() == decision.Selected {
+			return w
+		}
+	}
+	return nil
+}
+
+// preview runs previewer over the available workers and returns its decision plus the workers
+// it considered. Workers in quarantine are not offered and appear as excluded candidates
+func (h *ChatHandler) preview(ctx context.Context, previewer core.RoutePreviewer, req *core.InferenceRequest) (core.RouteDecision, []core.Worker) {
+	// 预览不占用探测名额：隔离中的 Worker 直接标记为排除
+	var workers []core.Worker
+	var quarantined []core.RouteCandidate
+	for _, w := range h.registry.GetAvailableWorkers() {
+		if h.quarantine != nil && h.quarantine.State(w.ID()) == core.BreakerOpen {
+			quarantined = append(quarantined, core.RouteCandidate{WorkerID: w.ID(), Excluded: "quarantined"})
+			continue
+		}
+		workers = append(workers, w)
+	}
+	decision := previewer.Preview(ctx, workers, req)
+	decision.Candidates = append(decision.Candidates, quarantined...)
+	return decision, workers
+}
+
+// workerProfile returns the profile the registry cached for worker, or asks the worker itself
+// when the registry keeps no profiles
+func (h *ChatHandler) workerProfile(ctx context.Context, worker core.Worker) (core.WorkerProfile, bool) {
+	if source, ok := h.registry.(core.ProfileSource); ok {
+		return source.Profile(worker.ID())
+	}
+	profile, err := worker.Heartbeat(ctx)
+	return profile, err == nil
+}
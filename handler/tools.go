@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"zam/core"
+	"zam/openai"
+)
+
+// toolCallSet assembles the tool call fragments of a stream into complete calls
+type toolCallSet struct {
+	calls   []openai.ToolCall
+	byIndex map[int]int
+}
+
+// add merges fragments into their calls: the first fragment of an index opens the call,
+// later ones append to its arguments
+func (s *toolCallSet) add(deltas []core.ToolCallDelta) {
+	for _, d := range deltas {
+		if s.byIndex == nil {
+			s.byIndex = make(map[int]int)
+		}
+		pos, ok := s.byIndex[d.Index]
+		if !ok {
+			pos = len(s.calls)
+			s.byIndex[d.Index] = pos
+			s.calls = append(s.calls, openai.ToolCall{Type: "function"})
+		}
+		call := &s.calls[pos]
+		if d.ID != "" {
+			call.ID = d.ID
+		}
+		if d.Type != "" {
+			call.Type = d.Type
+		}
+		if d.Name != "" {
+			call.Function.Name = d.Name
+		}
+		call.Function.Arguments += d.Arguments
+	}
+}
+
+// toolCallDeltas converts worker fragments into the tool_calls of a stream delta
+func toolCallDeltas(deltas []core.ToolCallDelta) []openai.ToolCall {
+	if len(deltas) == 0 {
+		return nil
+	}
+	calls := make([]openai.ToolCall, len(deltas))
+	for i, d := range deltas {
+		index := d.Index
+		calls[i] = openai.ToolCall{
+			Index:    &index,
+			ID:       d.ID,
+			Type:     d.Type,
+			Function: openai.FunctionCall{Name: d.Name, Arguments: d.Arguments},
+		}
+	}
+	return calls
+}
+
+// applyToolChoice drops tool calls from a chunk when tool_choice is "none", for backends that
+// call tools regardless; it reports whether anything was dropped
+func applyToolChoice(choice openai.ToolChoice, chunk *core.StreamChunk) bool {
+	if choice.Mode != openai.ToolChoiceNone || len(chunk.ToolCalls) == 0 {
+		return false
+	}
+	chunk.ToolCalls = nil
+	if chunk.FinishReason == "tool_calls" {
+		chunk.FinishReason = "stop"
+	}
+	return true
+}
+
+// legacyFunctionDelta converts tool call fragments into the function_call delta of the deprecated functions API
+// Only the first call is kept, since that API allows one call per message
+func legacyFunctionDelta(deltas []core.ToolCallDelta) *openai.FunctionCall {
+	var call *openai.FunctionCall
+	for _, d := range deltas {
+		if d.Index != 0 {
+			continue
+		}
+		if call == nil {
+			call = &openai.FunctionCall{}
+		}
+		call.Name += d.Name
+		call.Arguments += d.Arguments
+	}
+	return call
+}
+
+// legacyFinishReason reports tool calls as "function_call" for the deprecated functions API
+func legacyFinishReason(reason string) string {
+	if reason == "tool_calls" {
+		return "function_call"
+	}
+	return reason
+}
+
+// legacyFunctionChoices rewrites the tool calls of non-streaming choices into function_call
+func legacyFunctionChoices(choices []openai.Choice) {
+	for i := range choices {
+		choice := &choices[i]
+		choice.Message.FunctionCall = openai.FunctionCallOf(choice.Message.ToolCalls)
+		choice.Message.ToolCalls = nil
+		choice.FinishReason = legacyFinishReason(choice.FinishReason)
+	}
+}
+
+// toolCallTokens estimates the output tokens of tool call fragments
+func toolCallTokens(deltas []core.ToolCallDelta) int {
+	n := 0
+	for _, d := range deltas {
+		n += estimateTokens(d.Name) + estimateTokens(d.Arguments)
+	}
+	return n
+}
+
+// forwardedTools returns the tools to forward to workers, nil when the request has none
+func forwardedTools(req *openai.ChatCompletionRequest) interface{} {
+	if len(req.Tools) == 0 {
+		return nil
+	}
+	return req.Tools
+}
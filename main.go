@@ -2,75 +2,613 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"zam/alert"
 	"zam/api"
+	"zam/config"
 	"zam/core"
 	"zam/handler"
+	"zam/metrics"
+	"zam/replay"
 	"zam/router"
+	"zam/usage"
 	"zam/worker"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	// `zam worker`：在 GPU 主机上运行的 Worker Agent
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		runWorkerAgent(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		runReplay(os.Args[2:])
+		return
+	}
+	fs := flag.NewFlagSet("zam", flag.ExitOnError)
+	configPath := fs.String("config", os.Getenv("ZAM_CONFIG"), "YAML or TOML config file (see config.example.yaml)")
+	fs.Parse(os.Args[1:])
+
+	// 结构化日志：LOG_LEVEL / LOG_FORMAT / LOG_OUTPUT，log 包的输出同样经由 slog
+	logger, err := newLogger(os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"), os.Getenv("LOG_OUTPUT"))
+	if err != nil {
+		log.Fatalf("Invalid logging config: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	// 启动配置：内置默认值，其上叠加配置文件，再叠加同名环境变量
+	cfg, err := config.Load(*configPath, os.Getenv)
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	if *configPath != "" {
+		slog.Info("loaded config file", "path", *configPath)
+	}
+
 	// 创建根 Context，用于优雅关闭所有后台协程
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// 1. 初始化注册中心
-	registry := core.NewInMemoryRegistry(ctx)
+	// 1. 初始化注册中心与事件总线
+	registry, registrySync, err := newRegistry(ctx)
+	if err != nil {
+		log.Fatalf("Invalid registry config: %v", err)
+	}
+	events := core.NewEventBus()
+	events.Subscribe(func(e core.Event) {
+		slog.Info("event", "type", e.Type, "worker_id", e.WorkerID, "message", e.Message)
+	})
+	registry.SetEvents(events)
+	// 心跳中携带 endpoint 的远程 Worker 自动创建 HTTP Worker，无需改代码即可加入调度
+	// 心跳决定用户请求发往何处，仅在设置 WORKER_TOKEN（心跳端点需鉴权）时启用
+	if os.Getenv("WORKER_TOKEN") != "" {
+		registry.SetWorkerFactory(func(profile core.WorkerProfile) core.Worker {
+			return NewHTTPWorkerFactory(profile.WorkerID, profile.Endpoint)
+		})
+	} else {
+		slog.Warn("WORKER_TOKEN is not set: endpoints reported in heartbeats are ignored")
+	}
+	// 多副本共享 Worker 视图：心跳写入 Redis 并带过期时间，各副本定期同步
+	if shared, ok := registry.(*core.RedisRegistry); ok {
+		go shared.Run(ctx, registrySync)
+	}
+
+	// 事件日志落盘，供事后复盘
+	eventLog, err := newEventLog()
+	if err != nil {
+		log.Fatalf("Invalid event log config: %v", err)
+	}
+	events.Subscribe(eventLog.Record)
+	go eventLog.Run(ctx)
 
-	// 2. 初始化 Mock Workers 并注册到注册中心
-	_ = initMockWorkers(ctx, registry)
+	// 2. 初始化 Mock Workers（由配置文件 mock_workers 声明）并注册到注册中心
+	initMockWorkers(registry, cfg.MockWorkers)
+	if err := initSimWorkers(ctx, registry); err != nil {
+		log.Fatalf("Invalid SIM_WORKERS: %v", err)
+	}
+	if err := initTGIWorkers(ctx, registry); err != nil {
+		log.Fatalf("Invalid TGI_WORKERS: %v", err)
+	}
+	if err := initTritonWorkers(ctx, registry); err != nil {
+		log.Fatalf("Invalid TRITON_WORKERS: %v", err)
+	}
+	if err := initLMStudioWorkers(ctx, registry); err != nil {
+		log.Fatalf("Invalid LMSTUDIO_WORKERS: %v", err)
+	}
+	if err := initOllamaWorkers(ctx, registry); err != nil {
+		log.Fatalf("Invalid OLLAMA_WORKERS: %v", err)
+	}
+	if err := initVLLMWorkers(ctx, registry); err != nil {
+		log.Fatalf("Invalid VLLM_WORKERS: %v", err)
+	}
+	if err := initLlamaCppWorkers(ctx, registry); err != nil {
+		log.Fatalf("Invalid LLAMACPP_WORKERS: %v", err)
+	}
+	if err := initCloudWorkers(ctx, registry); err != nil {
+		log.Fatalf("Invalid CLOUD_WORKERS: %v", err)
+	}
+
+	// 注册对等网关（联邦）
+	if err := initPeers(ctx, registry); err != nil {
+		log.Fatalf("Invalid FEDERATION_PEERS: %v", err)
+	}
 
 	// 3. 初始化路由器
-	scoreRouter := router.NewScoreRouter()
+	scoreRouter, err := router.NewScoreRouterWithConfig(cfg.Router.ScoreConfig())
+	if err != nil {
+		log.Fatalf("Invalid router config: %v", err)
+	}
+	if spec := os.Getenv("SPECULATIVE_PAIRS"); spec != "" {
+		pairs, err := router.ParseSpeculativePairs(spec)
+		if err != nil {
+			log.Fatalf("Invalid SPECULATIVE_PAIRS: %v", err)
+		}
+		scoreRouter.SetSpeculativePairs(pairs)
+	}
+	// 按模型的显存 / 上下文长度目录，未列出的模型按名称估算
+	if path := os.Getenv("MODEL_PROFILES_PATH"); path != "" {
+		profiles, err := router.LoadModelProfiles(path)
+		if err != nil {
+			log.Fatalf("Invalid MODEL_PROFILES_PATH: %v", err)
+		}
+		scoreRouter.SetModelProfiles(profiles)
+	}
+
+	// 心跳迟到的 Worker 降权而非立即剔除
+	scoreRouter.SetStateSource(registry)
+
+	// 拓扑感知：优先同 Zone，其次同 Region
+	scoreRouter.SetLocality(router.Locality{
+		Region: os.Getenv("ZAM_REGION"),
+		Zone:   os.Getenv("ZAM_ZONE"),
+	})
+
+	// 路由策略：score（默认）/ round_robin / least_loaded / random / consistent_hash，可由 X-Zam-Router 请求头按请求覆盖
+	if err := scoreRouter.SetDefaultStrategy(cfg.Router.Strategy); err != nil {
+		log.Fatalf("Invalid router strategy: %v", err)
+	}
+
+	// 租户反亲和：同一租户的并发请求分散到不同 Worker
+	inflight := core.NewInflightTracker()
+	if os.Getenv("TENANT_ANTI_AFFINITY") == "true" {
+		scoreRouter.SetTenantAntiAffinity(inflight)
+	}
+	// 按模型并发槽位：叠加网关侧在途计数，避免两次心跳之间超额调度
+	scoreRouter.SetModelCounter(inflight)
+	// 按注册中心缓存的 Worker 画像路由（由心跳与后台探测更新），不再每个请求逐个调用 Heartbeat
+	scoreRouter.SetProfileSource(registry)
+	scoreRouter.SetWorkerCounter(inflight)
+	// 延迟感知：按 Worker 首 token 与完成延迟的 EWMA 打分，权重由 ROUTER_CONFIG 的 latency 设置
+	latencyAlpha := core.DefaultLatencyAlpha
+	if v := os.Getenv("LATENCY_EWMA_ALPHA"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			log.Fatalf("Invalid LATENCY_EWMA_ALPHA: %v", err)
+		}
+		latencyAlpha = f
+	}
+	latencies, err := core.NewLatencyTracker(latencyAlpha)
+	if err != nil {
+		log.Fatalf("Invalid LATENCY_EWMA_ALPHA: %v", err)
+	}
+	scoreRouter.SetLatencySource(latencies)
+	// 云端兜底预算：按 Worker 上报的每 1K Token 价格计费，日 / 月上限用尽后不再兜底
+	fallbackBudget, err := newFallbackBudget()
+	if err != nil {
+		log.Fatalf("Invalid fallback budget: %v", err)
+	}
+	scoreRouter.SetFallbackBudget(fallbackBudget)
+
+	// Worker 隔离：连续失败后暂时移出路由，冷却后慢启动探测
+	quarantine, err := newQuarantine(registry, events)
+	if err != nil {
+		log.Fatalf("Invalid quarantine config: %v", err)
+	}
+	// 多个兜底 Worker 按 priority 从高到低使用，跳过熔断中的兜底 Worker
+	scoreRouter.SetBreakerSource(quarantine)
+
+	// 集群模式：多个网关副本通过 Redis 共享在途计数与熔断状态
+	if url := os.Getenv("CLUSTER_REDIS_URL"); url != "" {
+		cluster, interval, err := newClusterState(url, inflight, quarantine)
+		if err != nil {
+			log.Fatalf("Invalid cluster config: %v", err)
+		}
+		go cluster.Run(ctx, interval)
+	}
+
+	// 降级模式：持续过载时拒绝昂贵请求（如 70B 模型）、限制 max_tokens
+	brownout, err := newBrownout(events)
+	if err != nil {
+		log.Fatalf("Invalid BROWNOUT: %v", err)
+	}
+	if brownout != nil {
+		go brownout.Run(ctx, registry.Profiles, 5*time.Second)
+	}
+
+	// 慢启动：过期、注销或隔离后回归的 Worker 逐步恢复流量份额
+	slowStart, err := newSlowStart()
+	if err != nil {
+		log.Fatalf("Invalid SLOW_START_WINDOW: %v", err)
+	}
+	if slowStart != nil {
+		slowStart.Subscribe(events)
+		scoreRouter.SetRampSource(slowStart)
+	}
 
 	// 4. 初始化限流器
-	rateLimiter := core.NewInMemoryRateLimiter()
+	balances := core.NewInMemoryRateLimiter()
+	balances.SetBalances(cfg.RateLimits.Balances)
+	var rateLimiter core.RateLimiter = balances
+	keys, err := core.ParseKeyDirectory(os.Getenv("API_KEY_OWNERS"))
+	if err != nil {
+		log.Fatalf("Invalid API_KEY_OWNERS: %v", err)
+	}
+	if spec := os.Getenv("QUOTA_RESETS"); spec != "" {
+		scheduler, err := newQuotaResetScheduler(balances, keys, spec)
+		if err != nil {
+			log.Fatalf("Invalid quota reset config: %v", err)
+		}
+		go scheduler.Run(ctx)
+	}
+	if spec := os.Getenv("BURST_ALLOWANCE"); spec != "" {
+		policies, err := core.ParseBurstPolicies(spec)
+		if err != nil {
+			log.Fatalf("Invalid BURST_ALLOWANCE: %v", err)
+		}
+		rateLimiter = core.NewBurstLimiter(rateLimiter, keys, policies)
+	}
+	if spec := os.Getenv("SPEND_CAPS"); spec != "" {
+		rateLimiter, err = newSpendCapLimiter(rateLimiter, keys, spec)
+		if err != nil {
+			log.Fatalf("Invalid spend cap config: %v", err)
+		}
+	}
+	// 按计划的每分钟请求数/Token 数限流，放在最外层以便 Handler 输出 X-RateLimit-* 响应头
+	// 未配置计划时也创建，热加载配置后可直接生效
+	windows := core.NewWindowLimiter(rateLimiter, keys, cfg.RateLimits.RateLimitPolicies())
+	rateLimiter = windows
 
 	// 5. 初始化 Handler - 使用注册中心
 	chatHandler := handler.NewChatHandlerWithRegistry(scoreRouter, registry, rateLimiter)
+	chatHandler.SetLatencyTracker(latencies)
+	chatHandler.SetFallbackBudget(fallbackBudget)
+	chatHandler.SetInflightTracker(inflight)
+	chatHandler.SetQuarantine(quarantine)
+	ledger, err := usage.NewLedger(os.Getenv("USAGE_LEDGER_PATH"))
+	if err != nil {
+		log.Fatalf("Invalid USAGE_LEDGER_PATH: %v", err)
+	}
+	// 指标：按 API Key（哈希）统计请求、错误、并发与 Token
+	metricsRegistry := metrics.NewRegistry()
+	keyMetrics := metrics.NewKeyMetrics(metricsRegistry)
+	chatHandler.SetLatencyMetrics(metrics.NewLatencyMetrics(metricsRegistry))
+	// 路由决策：本地/对等网关/Fallback/无可用 Worker，以及各过滤条件的剔除次数
+	scoreRouter.SetDecisionObserver(metrics.NewRoutingMetrics(metricsRegistry))
+	meter, err := newMeter(ctx, keys, ledger, usage.ExporterFunc(func(e usage.Event) {
+		keyMetrics.Tokens(e.Key, e.PromptTokens, e.CompletionTokens)
+	}))
+	if err != nil {
+		log.Fatalf("Invalid usage export config: %v", err)
+	}
+	chatHandler.SetMeter(meter)
+
+	// 调试用的请求/响应抓取（默认采样率 0，可通过 Admin API 临时开启）
+	capture, err := newPayloadCapture()
+	if err != nil {
+		log.Fatalf("Invalid capture config: %v", err)
+	}
+	chatHandler.SetCapture(capture)
+	chatHandler.SetEvents(events)
+	chatHandler.SetBrownout(brownout)
+	// 上游 429：按 Retry-After 冷却该 Worker，并换候选重试
+	chatHandler.SetThrottleTracker(core.NewThrottleTracker())
+	// Worker 在输出任何内容前失败时换次优候选重试，默认共执行 2 次
+	maxAttempts := 2
+	if v := os.Getenv("RETRY_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatalf("Invalid RETRY_MAX_ATTEMPTS: must be a positive integer")
+		}
+		maxAttempts = n
+	}
+	chatHandler.SetMaxAttempts(maxAttempts)
+	// Embeddings 请求按 Worker 上报的批大小拆分并行执行
+	var embedOpts core.EmbedBatchOptions
+	if v := os.Getenv("EMBED_BATCH_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatalf("Invalid EMBED_BATCH_SIZE: must be a positive integer")
+		}
+		embedOpts.BatchSize = n
+	}
+	if v := os.Getenv("EMBED_PARALLELISM"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatalf("Invalid EMBED_PARALLELISM: must be a positive integer")
+		}
+		embedOpts.Parallelism = n
+	}
+	chatHandler.SetEmbedOptions(embedOpts)
+	// 图片校验与远程图片抓取代理
+	imageProxy, err := newImageProxy()
+	if err != nil {
+		log.Fatalf("Invalid image config: %v", err)
+	}
+	chatHandler.SetImageProxy(imageProxy)
+	// 按模型的参数上限（max_tokens / temperature）
+	catalog, err := core.ParseModelCatalog(os.Getenv("MODEL_CATALOG"))
+	if err != nil {
+		log.Fatalf("Invalid MODEL_CATALOG: %v", err)
+	}
+	chatHandler.SetModelCatalog(catalog)
+	// 模型别名：客户端使用 OpenAI 模型名时透明地路由到本地模型
+	aliases := cfg.Aliases()
+	chatHandler.SetModelAliases(aliases)
+	// 按终端用户（请求中的 user 字段）的二级限流
+	if spec := os.Getenv("USER_RATE_LIMIT"); spec != "" {
+		policy, err := core.ParseUserLimitPolicy(spec)
+		if err != nil {
+			log.Fatalf("Invalid USER_RATE_LIMIT: %v", err)
+		}
+		chatHandler.SetUserLimiter(core.NewUserLimiter(policy))
+	}
+	// 按客户端 IP 限制并发 SSE 流
+	if v := os.Getenv("STREAM_LIMIT_PER_IP"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatalf("Invalid STREAM_LIMIT_PER_IP: must be a positive integer")
+		}
+		chatHandler.SetStreamLimiter(core.NewStreamLimiter(n))
+	}
+	// 无 Worker 有空闲容量时按模型排队等待，queue_timeout 为 0 时立即返回 503
+	var waitQueue *core.WaitQueue
+	if queueTimeout := time.Duration(cfg.Server.QueueTimeout); queueTimeout > 0 {
+		waitQueue = core.NewWaitQueue(cfg.Server.QueueSize, queueTimeout)
+		chatHandler.SetWaitQueue(waitQueue)
+	}
+	// 会话亲和：同一会话（X-Session-ID 或对话前缀）的后续轮次路由到缓存了 KV 前缀的 Worker
+	affinityMode, err := core.ParseAffinityMode(os.Getenv("SESSION_AFFINITY"))
+	if err != nil {
+		log.Fatalf("Invalid SESSION_AFFINITY: %v", err)
+	}
+	if affinityMode != core.AffinityOff {
+		affinityTTL := core.DefaultAffinityTTL
+		if v := os.Getenv("SESSION_AFFINITY_TTL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Invalid SESSION_AFFINITY_TTL: must be a positive duration")
+			}
+			affinityTTL = d
+		}
+		// SESSION_STORE 为 redis:// 时绑定关系在网关重启后保留，并由所有副本共享
+		sessions, err := core.OpenSessionStore(os.Getenv("SESSION_STORE"), core.DefaultSessionLimits())
+		if err != nil {
+			log.Fatalf("Invalid SESSION_STORE: %v", err)
+		}
+		affinity := core.NewSessionAffinity(affinityMode, sessions, affinityTTL)
+		scoreRouter.SetSessionAffinity(affinity)
+		chatHandler.SetSessionAffinity(affinity)
+	}
+	// 按 API Key 限制并发请求（集群模式下跨副本计数）
+	if v := os.Getenv("KEY_MAX_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatalf("Invalid KEY_MAX_CONCURRENCY: must be a positive integer")
+		}
+		chatHandler.SetKeyConcurrency(n)
+	}
+	// X-Priority 请求头：按 Key / 组织 / 计划限制可申请的最高优先级
+	priorities, err := core.ParsePriorityPolicy(os.Getenv("PRIORITY_LIMITS"), keys)
+	if err != nil {
+		log.Fatalf("Invalid PRIORITY_LIMITS: %v", err)
+	}
+	// Key 的优先级等级：未携带 X-Priority 时的默认优先级，决定排队顺序与能否使用预留容量
+	if err := priorities.SetClasses(os.Getenv("PRIORITY_CLASSES")); err != nil {
+		log.Fatalf("Invalid PRIORITY_CLASSES: %v", err)
+	}
+	chatHandler.SetPriorities(priorities)
+	// A/B 实验：按比例把模型流量分到两个后端/配置
+	experimentList, err := core.ParseExperiments(os.Getenv("EXPERIMENTS"))
+	if err != nil {
+		log.Fatalf("Invalid EXPERIMENTS: %v", err)
+	}
+	experiments := core.NewExperiments(experimentList)
+	chatHandler.SetExperiments(experiments)
+	// 请求截止时间：request_timeout 为默认值，request_timeout_max 限制 X-Request-Timeout-Ms 请求头
+	chatHandler.SetTimeoutPolicy(core.TimeoutPolicy{
+		Default: time.Duration(cfg.Server.RequestTimeout),
+		Max:     time.Duration(cfg.Server.RequestTimeoutMax),
+	})
+	// 流式输出节奏：合并细碎分片，两次刷出之间至少间隔 STREAM_PACING
+	if v := os.Getenv("STREAM_PACING"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatalf("Invalid STREAM_PACING: must be a non-negative duration")
+		}
+		chatHandler.SetStreamPacing(d)
+	}
+
+	// 内置告警：Worker 宕机、Fallback 比例、错误率
+	alerts, err := newAlertEngine(registry, events)
+	if err != nil {
+		log.Fatalf("Invalid alert config: %v", err)
+	}
+	if alerts != nil {
+		chatHandler.SetAlerts(alerts)
+		go alerts.Run(ctx)
+	}
 
-	// 6. 初始化 Worker API
+	// 6. 初始化 Worker API 与 Admin API
 	workerAPI := api.NewWorkerAPI(registry)
+	workerAPI.SetDirectives(core.NewDirectiveStore(time.Duration(cfg.Server.HeartbeatInterval)))
+	workerAPI.SetEvents(events)
+	workerAPI.SetHealthSources(quarantine, inflight)
+	workerAPI.SetWaitQueue(waitQueue)
+	// 远程 Worker 通过 POST /v1/workers/register 自助注册，网关以其提供的 Token 调用该 Worker
+	// 注册决定用户请求发往何处，仅在设置 WORKER_TOKEN 时启用（见 mountWorkerRoutes）
+	if os.Getenv("WORKER_TOKEN") != "" {
+		workerAPI.SetRegistration(func(profile core.WorkerProfile, authToken string) core.Worker {
+			w := NewHTTPWorkerFactory(profile.WorkerID, profile.Endpoint)
+			if authToken != "" {
+				w.Headers = http.Header{}
+				w.Headers.Set("Authorization", "Bearer "+authToken)
+			}
+			return w
+		})
+	}
+	adminAPI := api.NewAdminAPI(scoreRouter)
+	gatewayID := os.Getenv("GATEWAY_ID")
+	if gatewayID == "" {
+		gatewayID = "zam-gateway"
+	}
+	federationAPI := api.NewFederationAPI(gatewayID, registry)
+	federationAPI.SetQuarantine(quarantine)
+	modelsAPI := api.NewModelsAPI(registry, registry)
+	modelsAPI.SetQuarantine(quarantine)
+	modelsAPI.SetModelAliases(aliases)
+	federationAPI.SetBrownout(brownout)
+	billingAPI := api.NewBillingAPI(ledger, keys, os.Getenv("ADMIN_TOKEN"))
 
 	// 7. 创建 Gin 路由引擎
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
+	// 仅信任指定代理的 X-Forwarded-For，避免客户端伪造 IP 绕过按 IP 的限制
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		if err := r.SetTrustedProxies(strings.Split(v, ",")); err != nil {
+			log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+		}
+	}
 
 	// 使用 Gin 的中间件
 	r.Use(gin.Recovery())
-	r.Use(gin.Logger())
+	// 请求 ID：沿用客户端的 X-Request-Id 或自动生成，回显在响应头并写入访问日志
+	r.Use(api.RequestIDMiddleware())
+	r.Use(api.AccessLog())
 
-	// OpenAI 兼容的 API 端点
-	r.POST("/v1/chat/completions", chatHandler.Handle)
+	// OpenAI 兼容的 API 端点；关闭时拒绝新请求，等待进行中的请求（含流式响应）完成
+	drainer := core.NewDrainer()
+	v1 := r.Group("/v1", api.DrainMiddleware(drainer), api.KeyMetricsMiddleware(keyMetrics))
+	// 故障注入：仅用于测试环境，验证客户端的重试逻辑
+	chaos, err := api.ParseChaos(os.Getenv("CHAOS"))
+	if err != nil {
+		log.Fatalf("Invalid CHAOS: %v", err)
+	}
+	if chaos.Enabled() {
+		slog.Warn("chaos mode enabled", "config", fmt.Sprintf("%+v", chaos))
+		v1.Use(api.ChaosMiddleware(chaos))
+	}
+	// 流量录制：匿名化后写入磁盘，供 `zam replay` 压测回放
+	if path := os.Getenv("RECORD_TRACES_PATH"); path != "" {
+		recorder, err := replay.NewRecorder(path)
+		if err != nil {
+			log.Fatalf("Invalid RECORD_TRACES_PATH: %v", err)
+		}
+		defer recorder.Close()
+		v1.POST("/chat/completions", api.RecordMiddleware(recorder), chatHandler.Handle)
+	} else {
+		v1.POST("/chat/completions", chatHandler.Handle)
+	}
+	// 异步任务：立即返回任务 ID，集群空闲时以低优先级执行
+	idleLoad, err := parseJobsIdleLoad(os.Getenv("JOBS_IDLE_LOAD"))
+	if err != nil {
+		log.Fatalf("Invalid JOBS_IDLE_LOAD: %v", err)
+	}
+	jobQueue := core.NewJobQueue(core.DefaultMaxQueuedJobs, core.DefaultJobRetention)
+	jobsAPI := api.NewJobsAPI(jobQueue, chatHandler.Handle)
+	jobsAPI.SetPriorities(priorities)
+	// 任务完成后回调 callback_url，载荷以 JOBS_WEBHOOK_SECRET 签名
+	jobQueue.SetNotifier(core.NewJobWebhooks(ctx, os.Getenv("JOBS_WEBHOOK_SECRET")).Notify)
+	go jobQueue.Run(ctx, jobsAPI.Execute, func() bool {
+		return core.ClusterLoad(registry.Profiles()) < idleLoad
+	}, 5*time.Second)
+	// 自动扩缩容信号：排队深度、等待时间、拒绝率与按模型积压，供 KEDA 等伸缩 GPU Worker
+	demand := core.NewDemandMonitor(registry.Profiles, inflight, jobQueue)
+	events.Subscribe(demand.ObserveEvent)
+	r.GET("/autoscale", api.RequireAdminToken(os.Getenv("AUTOSCALE_TOKEN")), api.NewAutoscaleAPI(demand).HandleSignals)
+	v1.POST("/jobs/completions", jobsAPI.HandleSubmit)
+	v1.GET("/jobs/:id", jobsAPI.HandleGet)
+	v1.POST("/route/preview", chatHandler.HandleRoutePreview)
+	v1.POST("/tokenize", chatHandler.HandleTokenize)
+	v1.POST("/embeddings", chatHandler.HandleEmbeddings)
+	v1.GET("/models", modelsAPI.HandleList)
+	v1.GET("/models/:id", modelsAPI.HandleGet)
+	r.GET("/v1/organizations/:id/billing", billingAPI.HandleBilling)
 
-	// Worker 心跳端点
-	r.POST("/v1/workers/heartbeat", workerAPI.HandleHeartbeat)
+	// Worker 心跳端点（WORKER_TOKEN 非空时要求鉴权）
+	mountWorkerRoutes(r, workerAPI, os.Getenv("WORKER_TOKEN"))
+
+	// 联邦端点：对等网关拉取本地聚合容量
+	r.GET("/v1/federation/profile", api.RequireAdminToken(os.Getenv("FEDERATION_TOKEN")), federationAPI.HandleProfile)
+	// 外部负载均衡：上报本网关的健康本地容量与建议权重
+	r.GET("/capacity", api.RequireAdminToken(os.Getenv("CAPACITY_TOKEN")), federationAPI.HandleCapacity)
+
+	// Admin 端点：运行时调整路由权重
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		slog.Warn("ADMIN_TOKEN not set, admin endpoints are unauthenticated")
+	}
+	admin := r.Group("/admin", api.RequireAdminToken(adminToken))
+	admin.GET("/router/weights", adminAPI.HandleGetWeights)
+	admin.PUT("/router/weights", adminAPI.HandlePutWeights)
+	admin.GET("/router/models", adminAPI.HandleListModelProfiles)
+	admin.PUT("/router/models/:model", adminAPI.HandlePutModelProfile)
+	admin.DELETE("/router/models/:model", adminAPI.HandleDeleteModelProfile)
+	admin.GET("/events", api.NewEventsAPI(eventLog).HandleList)
+	captureAPI := api.NewCaptureAPI(capture)
+	admin.GET("/captures", captureAPI.HandleList)
+	admin.GET("/captures/:id", captureAPI.HandleGet)
+	admin.DELETE("/captures", captureAPI.HandleClear)
+	admin.PUT("/captures/config", captureAPI.HandlePutConfig)
+	admin.GET("/experiments", api.NewExperimentsAPI(experiments).HandleList)
+	admin.GET("/workers", workerAPI.HandleList)
+	admin.GET("/workers/:id", workerAPI.HandleGet)
+	admin.POST("/workers/:id/drain", workerAPI.HandleDrain)
+	admin.POST("/workers/:id/uncordon", workerAPI.HandleUncordon)
+	admin.GET("/workers/:id/directives", workerAPI.HandleGetDirectives)
+	admin.PUT("/workers/:id/directives", workerAPI.HandlePutDirectives)
+
+	// Prometheus 指标端点（METRICS_TOKEN 非空时要求鉴权）
+	r.GET("/metrics", api.RequireAdminToken(os.Getenv("METRICS_TOKEN")), gin.WrapH(metricsRegistry.Handler()))
 
 	// 健康检查端点
 	r.GET("/health", func(c *gin.Context) {
 		workers := registry.GetAvailableWorkers()
-		c.JSON(http.StatusOK, gin.H{
+		health := gin.H{
 			"status":  "ok",
 			"workers": len(workers),
+		}
+		if brownout != nil {
+			status := brownout.Status()
+			if status.Active {
+				health["status"] = "degraded"
+			}
+			health["brownout"] = status
+		}
+		if waitQueue != nil {
+			if queued := waitQueue.Waiting(); len(queued) > 0 {
+				health["queued"] = queued
+			}
+		}
+		// 排空中返回 503，让负载均衡摘除本实例
+		if drainer.Draining() {
+			health["status"] = "draining"
+			health["in_flight"] = drainer.InFlight()
+			c.JSON(http.StatusServiceUnavailable, health)
+			return
+		}
+		c.JSON(http.StatusOK, health)
+	})
+
+	// Worker 遥测快照，供仪表盘使用
+	admin.GET("/workers/telemetry", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"workers": registry.Profiles(),
+			"latency": latencies.Snapshot(),
 		})
 	})
 
-	// 8. 启动服务器
-	port := "8080"
-	if envPort := os.Getenv("PORT"); envPort != "" {
-		port = envPort
-	}
+	// OpenAPI 文档：按实际注册的路由生成，供 SDK 生成与 API 网关导入
+	openAPI := api.NewOpenAPI("ZAM Gateway", "1.0.0")
+	api.DescribeGateway(openAPI)
+	r.GET("/openapi.json", openAPI.Handler(r))
 
-	addr := ":" + port
+	// 8. 启动服务器
+	addr := ":" + strconv.Itoa(cfg.Server.Port)
+	shutdownTimeout := time.Duration(cfg.Server.ShutdownTimeout)
 
 	// 创建 HTTP Server 用于优雅关闭
 	srv := &http.Server{
@@ -78,9 +616,60 @@ func main() {
 		Handler: r,
 	}
 
+	// 可选：把本网关注册到 Consul，权重随空闲容量更新（Consul DNS 以 SRV 记录下发权重）
+	if consulAddr := os.Getenv("CONSUL_ADDR"); consulAddr != "" {
+		service := os.Getenv("CONSUL_SERVICE")
+		if service == "" {
+			service = "zam-gateway"
+		}
+		publisher := core.NewConsulPublisher(consulAddr, os.Getenv("CONSUL_TOKEN"), core.ConsulService{
+			ID:      gatewayID,
+			Name:    service,
+			Address: os.Getenv("CONSUL_SERVICE_ADDRESS"),
+			Port:    cfg.Server.Port,
+		})
+		go publisher.Run(ctx, func() int { return federationAPI.Capacity().Weight }, 10*time.Second)
+	}
+
+	// SIGHUP 时重新读取配置文件，原地更新路由、别名、限流与 Mock Workers，不重启服务器、不中断流式响应
+	reloader := config.NewReloader(*configPath, os.Getenv, cfg, func(old, next config.Config) {
+		if next.Router != old.Router {
+			// 路由段未改动时保留通过 Admin API 调整过的权重
+			if err := scoreRouter.SetConfig(next.Router.ScoreConfig()); err != nil {
+				slog.Error("failed to apply router config", "error", err)
+			}
+			if err := scoreRouter.SetDefaultStrategy(next.Router.Strategy); err != nil {
+				slog.Error("failed to apply router strategy", "error", err)
+			}
+		}
+		aliases := next.Aliases()
+		chatHandler.SetModelAliases(aliases)
+		modelsAPI.SetModelAliases(aliases)
+		windows.SetPolicies(next.RateLimits.RateLimitPolicies())
+		// 从文件中删除的 Key 立即失效
+		changed, removedKeys := config.BalanceChanges(old.RateLimits.Balances, next.RateLimits.Balances)
+		for key, tokens := range changed {
+			balances.SetBalance(key, tokens)
+		}
+		for _, key := range removedKeys {
+			balances.RemoveBalance(key)
+		}
+		// 移除的 Mock Worker 不再接收新请求，已在执行的请求照常完成
+		upserted, removed := config.MockWorkerChanges(old.MockWorkers, next.MockWorkers)
+		for _, id := range removed {
+			registry.Deregister(id)
+		}
+		initMockWorkers(registry, upserted)
+		// 新的 Worker 与放宽的路由阈值可能让排队中的请求得到调度
+		waitQueue.Notify()
+	})
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go reloader.Run(ctx, hup)
+
 	// 在 goroutine 中启动服务器
 	go func() {
-		log.Printf("Starting server on %s", addr)
+		slog.Info("starting server", "addr", addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
@@ -91,86 +680,757 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
-
-	// 取消所有后台协程
-	cancel()
+	slog.Info("shutting down server", "timeout", shutdownTimeout.String())
 
 	// 设置超时上下文
-	ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), 30*time.Second)
+	ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancelShutdown()
 
+	// 先排空：新请求返回 503，进行中的流式响应继续输出直到完成；后台协程（心跳清理等）此时仍在运行
+	if err := drainer.Drain(ctxShutdown); err != nil {
+		slog.Warn("drain timed out", "in_flight", drainer.InFlight())
+	}
+
 	// 关闭服务器
 	if err := srv.Shutdown(ctxShutdown); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
-	log.Println("Server exited")
-}
-
-// initMockWorkers 初始化 Mock Workers 并注册到注册中心
-func initMockWorkers(ctx context.Context, registry *core.InMemoryRegistry) []core.Worker {
-	var workers []core.Worker
-
-	// 模拟 4070TiS Worker (12GB VRAM)
-	w1 := &MockWorker{
-		id:          "gpu-4070tis-01",
-		models:      []string{"gpt-3.5-turbo", "gpt-4", "llama-7b", "llama-13b"},
-		totalVRAM:   12 * 1024 * 1024 * 1024, // 12GB
-		maxTasks:    2,
-		activeTasks: 0,
-	}
-	workers = append(workers, w1)
-	profile1 := core.WorkerProfile{
-		WorkerID:      "gpu-4070tis-01",
-		Supported:     []string{"gpt-3.5-turbo", "gpt-4", "llama-7b", "llama-13b"},
-		TotalVRAM:     12 * 1024 * 1024 * 1024,
-		AvailableVRAM: 12 * 1024 * 1024 * 1024,
-		ActiveTasks:   0,
-		MaxTasks:      2,
-	}
-	registry.RegisterWorker(w1, profile1)
-
-	// 模拟 2060 Worker (6GB VRAM)
-	w2 := &MockWorker{
-		id:          "gpu-2060-01",
-		models:      []string{"gpt-3.5-turbo", "llama-7b"},
-		totalVRAM:   6 * 1024 * 1024 * 1024, // 6GB
-		maxTasks:    1,
-		activeTasks: 0,
-	}
-	workers = append(workers, w2)
-	profile2 := core.WorkerProfile{
-		WorkerID:      "gpu-2060-01",
-		Supported:     []string{"gpt-3.5-turbo", "llama-7b"},
-		TotalVRAM:     6 * 1024 * 1024 * 1024,
-		AvailableVRAM: 6 * 1024 * 1024 * 1024,
-		ActiveTasks:   0,
-		MaxTasks:      1,
-	}
-	registry.RegisterWorker(w2, profile2)
-
-	// 模拟 Fallback Cloud Worker
-	w3 := &MockWorker{
-		id:          "cloud-fallback",
-		models:      []string{"*"}, // 支持所有模型
-		totalVRAM:   0,              // 云端无 VRAM 限制
-		maxTasks:    100,
-		activeTasks: 0,
-		isFallback:  true,
-	}
-	workers = append(workers, w3)
-	profile3 := core.WorkerProfile{
-		WorkerID:      "cloud-fallback",
-		Supported:     []string{"*"},
-		TotalVRAM:     0,
-		AvailableVRAM: 0,
-		ActiveTasks:   0,
-		MaxTasks:      100,
-	}
-	registry.RegisterWorker(w3, profile3)
-
-	return workers
+	// 取消所有后台协程
+	cancel()
+
+	slog.Info("server exited")
+}
+
+// newLogger 创建结构化日志：level 为 debug / info / warn / error（默认 info），
+// format 为 json（默认）或 text，output 为 stderr（默认）、stdout 或追加写入的文件路径
+// mountWorkerRoutes mounts the /v1/workers endpoints, requiring token when it is set
+// Registration and reverse connections add workers that receive user traffic, so they are only
+// mounted when token is set
+func mountWorkerRoutes(r gin.IRouter, workerAPI *api.WorkerAPI, token string) {
+	workers := r.Group("/v1/workers", api.RequireWorkerToken(token))
+	workers.POST("/heartbeat", workerAPI.HandleHeartbeat)
+	workers.POST("/heartbeat/batch", workerAPI.HandleBatchHeartbeat)
+	workers.DELETE("/:id", workerAPI.HandleDeregister)
+	if token == "" {
+		slog.Warn("WORKER_TOKEN is not set: worker registration and reverse connections are disabled")
+		return
+	}
+	workers.POST("/register", workerAPI.HandleRegister)
+	// NAT 后的 Worker 主动建立 WebSocket 反向连接，请求经该连接下发
+	workers.GET("/connect", workerAPI.HandleConnect)
+}
+
+func newLogger(level, format, output string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("LOG_LEVEL: %w", err)
+		}
+	}
+
+	var w io.Writer
+	switch output {
+	case "", "stderr":
+		w = os.Stderr
+	case "stdout":
+		w = os.Stdout
+	default:
+		f, err := os.OpenFile(output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("LOG_OUTPUT: %w", err)
+		}
+		w = f
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "", "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("LOG_FORMAT: unknown format %q (want json or text)", format)
+	}
+}
+
+// initPeers 解析 FEDERATION_PEERS（格式 "id=url;id2=url2"）并注册对等网关
+// 对等网关无法主动推送心跳，由后台探测协程定期拉取其聚合容量
+func initPeers(ctx context.Context, registry gatewayRegistry) error {
+	for _, entry := range strings.Split(os.Getenv("FEDERATION_PEERS"), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, url, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || url == "" {
+			return fmt.Errorf("invalid peer %q: expected id=url", entry)
+		}
+
+		peer := worker.NewPeerWorker(id, url, os.Getenv("FEDERATION_API_KEY"), os.Getenv("FEDERATION_TOKEN"))
+		profile, err := peer.Heartbeat(ctx)
+		if err != nil {
+			slog.Warn("peer not reachable yet", "peer_id", id, "error", err)
+			profile = core.WorkerProfile{WorkerID: id, Peer: true}
+		}
+		registry.RegisterWorker(peer, profile)
+		go core.RunHeartbeatProbe(ctx, registry, peer, 5*time.Second)
+	}
+	return nil
+}
+
+// newQuotaResetScheduler 按日/周/月边界重置或充值余额，已执行的周期持久化到文件
+func newQuotaResetScheduler(balances core.BalanceStore, keys *core.KeyDirectory, spec string) (*core.QuotaResetScheduler, error) {
+	rules, err := core.ParseQuotaResetRules(spec)
+	if err != nil {
+		return nil, err
+	}
+	loc := time.UTC
+	if tz := os.Getenv("QUOTA_RESET_TZ"); tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("QUOTA_RESET_TZ: %w", err)
+		}
+	}
+	statePath := os.Getenv("QUOTA_RESET_STATE")
+	if statePath == "" {
+		statePath = "quota_resets.json"
+	}
+	return core.NewQuotaResetScheduler(rules, balances, keys, loc, statePath)
+}
+
+// newSpendCapLimiter 在限流器外层叠加按 Key / 组织的周期消费上限
+func newSpendCapLimiter(next core.RateLimiter, keys *core.KeyDirectory, spec string) (*core.SpendCapLimiter, error) {
+	caps, err := core.ParseSpendCaps(spec)
+	if err != nil {
+		return nil, err
+	}
+	loc := time.UTC
+	if tz := os.Getenv("SPEND_CAP_TZ"); tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("SPEND_CAP_TZ: %w", err)
+		}
+	}
+	return core.NewSpendCapLimiter(next, keys, caps, loc), nil
+}
+
+// newFallbackBudget 构建云端兜底 Worker 的日 / 月消费上限，均未设置时返回 nil（不限制）
+func newFallbackBudget() (*core.FallbackBudget, error) {
+	daily, err := core.ParseBudget(os.Getenv("FALLBACK_BUDGET_DAILY"))
+	if err != nil {
+		return nil, fmt.Errorf("FALLBACK_BUDGET_DAILY: %w", err)
+	}
+	monthly, err := core.ParseBudget(os.Getenv("FALLBACK_BUDGET_MONTHLY"))
+	if err != nil {
+		return nil, fmt.Errorf("FALLBACK_BUDGET_MONTHLY: %w", err)
+	}
+	if daily == 0 && monthly == 0 {
+		return nil, nil
+	}
+	loc := time.UTC
+	if tz := os.Getenv("SPEND_CAP_TZ"); tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("SPEND_CAP_TZ: %w", err)
+		}
+	}
+	return core.NewFallbackBudget(daily, monthly, loc), nil
+}
+
+// newMeter 构建用量计量器：按模型定价，并推送到 Webhook / NATS
+func newMeter(ctx context.Context, keys *core.KeyDirectory, ledger *usage.Ledger, extra ...usage.Exporter) (*usage.Meter, error) {
+	pricing, err := usage.ParsePricing(os.Getenv("USAGE_PRICING"))
+	if err != nil {
+		return nil, err
+	}
+
+	exporters := append([]usage.Exporter{ledger}, extra...)
+	if url := os.Getenv("USAGE_WEBHOOK_URL"); url != "" {
+		exporters = append(exporters, usage.NewWebhookExporter(ctx, url, os.Getenv("USAGE_WEBHOOK_TOKEN")))
+	}
+	if addr := os.Getenv("USAGE_NATS_URL"); addr != "" {
+		subject := os.Getenv("USAGE_NATS_SUBJECT")
+		if subject == "" {
+			subject = "zam.usage"
+		}
+		exporters = append(exporters, usage.NewNATSExporter(ctx, addr, subject))
+	}
+	meter := usage.NewMeter(pricing, keys, exporters...)
+
+	multipliers, err := usage.ParseMultipliers(os.Getenv("BILLING_MULTIPLIERS"))
+	if err != nil {
+		return nil, err
+	}
+	meter.SetMultipliers(multipliers)
+	return meter, nil
+}
+
+// newAlertEngine 根据 ALERT_RULES 构建告警引擎，未配置规则时返回 nil
+func newAlertEngine(registry gatewayRegistry, events *core.EventBus) (*alert.Engine, error) {
+	rules, err := alert.ParseRules(os.Getenv("ALERT_RULES"))
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+
+	var notifiers []alert.Notifier
+	if url := os.Getenv("ALERT_WEBHOOK_URL"); url != "" {
+		notifiers = append(notifiers, alert.NewWebhookNotifier(url, os.Getenv("ALERT_WEBHOOK_TOKEN")))
+	}
+	if url := os.Getenv("ALERT_SLACK_WEBHOOK_URL"); url != "" {
+		notifiers = append(notifiers, alert.NewSlackNotifier(url))
+	}
+	if len(notifiers) == 0 {
+		return nil, fmt.Errorf("ALERT_RULES requires ALERT_WEBHOOK_URL or ALERT_SLACK_WEBHOOK_URL")
+	}
+
+	opts := alert.DefaultOptions()
+	if v := os.Getenv("ALERT_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("ALERT_WINDOW must be a positive duration")
+		}
+		opts.Window = d
+	}
+
+	engine := alert.NewEngine(rules, opts, registry, notifiers...)
+	engine.Subscribe(events)
+	return engine, nil
+}
+
+// newBrownout builds the brownout policy from BROWNOUT; unset disables it
+func newBrownout(events *core.EventBus) (*core.Brownout, error) {
+	spec := os.Getenv("BROWNOUT")
+	if spec == "" {
+		return nil, nil
+	}
+	policy, err := core.ParseBrownoutPolicy(spec, core.DefaultBrownoutPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return core.NewBrownout(policy, events), nil
+}
+
+// newImageProxy 根据 IMAGE_MAX_BYTES / IMAGE_FETCH / IMAGE_CACHE_SIZE 构建图片校验与抓取代理
+func newImageProxy() (*core.ImageProxy, error) {
+	policy := core.DefaultImagePolicy()
+	if v := os.Getenv("IMAGE_MAX_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("IMAGE_MAX_BYTES must be a positive integer")
+		}
+		policy.MaxBytes = n
+	}
+	if v := os.Getenv("IMAGE_FETCH"); v != "" {
+		fetch, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("IMAGE_FETCH must be true or false")
+		}
+		policy.Fetch = fetch
+	}
+	if v := os.Getenv("IMAGE_CACHE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("IMAGE_CACHE_SIZE must be a non-negative integer")
+		}
+		policy.CacheSize = n
+	}
+	return core.NewImageProxy(policy), nil
+}
+
+// parseJobsIdleLoad parses JOBS_IDLE_LOAD, the cluster load below which async jobs run (default 50%)
+func parseJobsIdleLoad(v string) (float64, error) {
+	if v == "" {
+		return 0.5, nil
+	}
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+	if err != nil || pct <= 0 || pct > 100 {
+		return 0, fmt.Errorf("expected a percentage in (0, 100], got %q", v)
+	}
+	return pct / 100, nil
+}
+
+// newSlowStart builds the slow-start ramp from SLOW_START_WINDOW; "0" disables it
+func newSlowStart() (*core.SlowStart, error) {
+	window := core.DefaultSlowStartWindow
+	if v := os.Getenv("SLOW_START_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("expected a non-negative duration, got %q", v)
+		}
+		window = d
+	}
+	if window == 0 {
+		return nil, nil
+	}
+	return core.NewSlowStart(window), nil
+}
+
+// newEventLog 根据 EVENT_LOG_PATH / EVENT_LOG_RETENTION 打开事件日志，未配置路径时只保存在内存
+func newEventLog() (*core.EventLog, error) {
+	retention := core.DefaultEventRetention
+	if v := os.Getenv("EVENT_LOG_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("EVENT_LOG_RETENTION must be a positive duration")
+		}
+		retention = d
+	}
+	return core.NewEventLog(os.Getenv("EVENT_LOG_PATH"), retention)
+}
+
+// newPayloadCapture 根据 CAPTURE_SAMPLE_RATE / CAPTURE_BUFFER_SIZE 构建请求抓取缓冲区
+func newPayloadCapture() (*core.PayloadCapture, error) {
+	rate := 0.0
+	if v := os.Getenv("CAPTURE_SAMPLE_RATE"); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 0 || r > 1 {
+			return nil, fmt.Errorf("CAPTURE_SAMPLE_RATE must be a number between 0 and 1")
+		}
+		rate = r
+	}
+	size := core.DefaultCaptureSize
+	if v := os.Getenv("CAPTURE_BUFFER_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("CAPTURE_BUFFER_SIZE must be a positive integer")
+		}
+		size = n
+	}
+	return core.NewPayloadCapture(rate, size), nil
+}
+
+// gatewayRegistry 是网关依赖的注册中心能力，由 core.InMemoryRegistry 与 core.RedisRegistry 实现
+type gatewayRegistry interface {
+	core.WorkerRegistry
+	core.WorkerStateSource
+	RegisterWorker(worker core.Worker, profile core.WorkerProfile) error
+	Profile(workerID string) (core.WorkerProfile, bool)
+	Profiles() []core.WorkerProfile
+	Registered() []core.RegisteredWorker
+	Cordoned(workerID string) bool
+	SetEvents(bus *core.EventBus)
+	SetWorkerFactory(newWorker func(profile core.WorkerProfile) core.Worker)
+}
+
+// newRegistry 构建 Worker 注册中心；设置 REGISTRY_REDIS_URL 时使用 Redis 注册中心，并返回其同步周期
+func newRegistry(ctx context.Context) (gatewayRegistry, time.Duration, error) {
+	url := os.Getenv("REGISTRY_REDIS_URL")
+	if url == "" {
+		return core.NewInMemoryRegistry(ctx), 0, nil
+	}
+	client, err := core.NewRedisClient(url)
+	if err != nil {
+		return nil, 0, err
+	}
+	ttl := core.DefaultWorkerTTL
+	if v := os.Getenv("WORKER_TTL"); v != "" {
+		if ttl, err = time.ParseDuration(v); err != nil || ttl <= 0 {
+			return nil, 0, fmt.Errorf("WORKER_TTL must be a positive duration")
+		}
+	}
+	interval := time.Second
+	if v := os.Getenv("REGISTRY_SYNC_INTERVAL"); v != "" {
+		if interval, err = time.ParseDuration(v); err != nil || interval <= 0 {
+			return nil, 0, fmt.Errorf("REGISTRY_SYNC_INTERVAL must be a positive duration")
+		}
+	}
+	return core.NewRedisRegistry(client, ttl), interval, nil
+}
+
+// newClusterState 构建跨副本共享状态；副本 ID 取 GATEWAY_ID，未设置时使用主机名
+func newClusterState(url string, inflight *core.InflightTracker, quarantine *core.Quarantine) (*core.ClusterState, time.Duration, error) {
+	client, err := core.NewRedisClient(url)
+	if err != nil {
+		return nil, 0, err
+	}
+	interval := time.Second
+	if v := os.Getenv("CLUSTER_SYNC_INTERVAL"); v != "" {
+		if interval, err = time.ParseDuration(v); err != nil || interval <= 0 {
+			return nil, 0, fmt.Errorf("CLUSTER_SYNC_INTERVAL must be a positive duration")
+		}
+	}
+	nodeID := os.Getenv("GATEWAY_ID")
+	if nodeID == "" {
+		if nodeID, err = os.Hostname(); err != nil {
+			return nil, 0, fmt.Errorf("set GATEWAY_ID to a unique ID per replica: %w", err)
+		}
+	}
+	return core.NewClusterState(client, nodeID, inflight, quarantine), interval, nil
+}
+
+// newQuarantine 根据环境变量构建 Worker 隔离策略
+func newQuarantine(registry gatewayRegistry, events *core.EventBus) (*core.Quarantine, error) {
+	policy := core.DefaultQuarantinePolicy()
+	if v := os.Getenv("QUARANTINE_MAX_FAILURES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("QUARANTINE_MAX_FAILURES must be a positive integer")
+		}
+		policy.MaxFailures = n
+	}
+	if v := os.Getenv("QUARANTINE_COOLDOWN"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("QUARANTINE_COOLDOWN must be a positive duration")
+		}
+		policy.CoolDown = d
+	}
+
+	quarantine := core.NewQuarantine(policy, events)
+	quarantine.SetClassifier(func(workerID string) string {
+		profile, _ := registry.Profile(workerID)
+		return profile.Class
+	})
+
+	classPolicies, err := core.ParseQuarantinePolicies(os.Getenv("QUARANTINE_POLICIES"), policy)
+	if err != nil {
+		return nil, err
+	}
+	for class, p := range classPolicies {
+		quarantine.SetClassPolicy(class, p)
+	}
+	return quarantine, nil
+}
+
+// parseBackendEntry 解析 "id=url[,key=value...]" 形式的后端声明
+func parseBackendEntry(entry string) (id, url string, opts map[string]string, err error) {
+	id, rest, ok := strings.Cut(entry, "=")
+	fields := strings.Split(rest, ",")
+	id, url = strings.TrimSpace(id), strings.TrimSpace(fields[0])
+	if !ok || id == "" || url == "" {
+		return "", "", nil, fmt.Errorf("invalid backend %q: expected id=url[,key=value...]", entry)
+	}
+
+	opts = make(map[string]string)
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return "", "", nil, fmt.Errorf("invalid backend option %q in %q", field, entry)
+		}
+		opts[key] = value
+	}
+	return id, url, opts, nil
+}
+
+// registerBackend 注册后端 Worker 并持续探测其心跳；首次探测失败时以空 Profile 注册等待恢复
+func registerBackend(ctx context.Context, registry gatewayRegistry, w core.Worker) {
+	profile, err := w.Heartbeat(ctx)
+	if err != nil {
+		slog.Warn("worker not reachable yet", "worker_id", w.ID(), "error", err)
+		profile = core.WorkerProfile{WorkerID: w.ID()}
+	}
+	registry.RegisterWorker(w, profile)
+	go core.RunHeartbeatProbe(ctx, registry, w, 5*time.Second)
+}
+
+// initTGIWorkers 注册 Hugging Face TGI 后端，格式 "id=url[,models=a|b,shards=2,shard_vram_gb=24];..."
+func initTGIWorkers(ctx context.Context, registry gatewayRegistry) error {
+	for _, entry := range strings.Split(os.Getenv("TGI_WORKERS"), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, url, opts, err := parseBackendEntry(entry)
+		if err != nil {
+			return err
+		}
+		tgi := worker.NewTGIWorker(id, url)
+		if models := opts["models"]; models != "" {
+			tgi.Models = strings.Split(models, "|")
+		}
+		if v := opts["shards"]; v != "" {
+			if tgi.NumShards, err = strconv.Atoi(v); err != nil || tgi.NumShards < 1 {
+				return fmt.Errorf("%s: shards must be a positive integer", id)
+			}
+		}
+		if v := opts["shard_vram_gb"]; v != "" {
+			gb, err := strconv.ParseFloat(v, 64)
+			if err != nil || gb <= 0 {
+				return fmt.Errorf("%s: shard_vram_gb must be a positive number", id)
+			}
+			tgi.ShardVRAM = uint64(gb * 1024 * 1024 * 1024)
+		}
+		registerBackend(ctx, registry, tgi)
+	}
+	return nil
+}
+
+// initTritonWorkers 注册 Triton Inference Server 后端，格式 "id=url[,models=alias:model|...,vram_gb=80,max_tasks=16];..."
+func initTritonWorkers(ctx context.Context, registry gatewayRegistry) error {
+	for _, entry := range strings.Split(os.Getenv("TRITON_WORKERS"), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, url, opts, err := parseBackendEntry(entry)
+		if err != nil {
+			return err
+		}
+		triton := worker.NewTritonWorker(id, url)
+		if models := opts["models"]; models != "" {
+			triton.ModelMap = make(map[string]string)
+			for _, m := range strings.Split(models, "|") {
+				alias, target, ok := strings.Cut(m, ":")
+				if !ok || alias == "" || target == "" {
+					return fmt.Errorf("%s: models must be alias:model pairs", id)
+				}
+				triton.ModelMap[alias] = target
+			}
+		}
+		if v := opts["vram_gb"]; v != "" {
+			gb, err := strconv.ParseFloat(v, 64)
+			if err != nil || gb <= 0 {
+				return fmt.Errorf("%s: vram_gb must be a positive number", id)
+			}
+			triton.VRAM = uint64(gb * 1024 * 1024 * 1024)
+		}
+		if v := opts["max_tasks"]; v != "" {
+			if triton.MaxTasks, err = strconv.Atoi(v); err != nil || triton.MaxTasks < 1 {
+				return fmt.Errorf("%s: max_tasks must be a positive integer", id)
+			}
+		}
+		registerBackend(ctx, registry, triton)
+	}
+	return nil
+}
+
+// initLMStudioWorkers 注册 LM Studio 本地服务，格式 "id=url[,vram_gb=24,max_tasks=4,passthrough=true];..."
+func initLMStudioWorkers(ctx context.Context, registry gatewayRegistry) error {
+	for _, entry := range strings.Split(os.Getenv("LMSTUDIO_WORKERS"), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, url, opts, err := parseBackendEntry(entry)
+		if err != nil {
+			return err
+		}
+		lms := worker.NewLMStudioWorker(id, url)
+		if v := opts["vram_gb"]; v != "" {
+			gb, err := strconv.ParseFloat(v, 64)
+			if err != nil || gb <= 0 {
+				return fmt.Errorf("%s: vram_gb must be a positive number", id)
+			}
+			lms.VRAM = uint64(gb * 1024 * 1024 * 1024)
+		}
+		if v := opts["max_tasks"]; v != "" {
+			if lms.MaxTasks, err = strconv.Atoi(v); err != nil || lms.MaxTasks < 1 {
+				return fmt.Errorf("%s: max_tasks must be a positive integer", id)
+			}
+		}
+		// LM Studio 输出标准 OpenAI SSE，开启后无需改写的流直接透传
+		if v := opts["passthrough"]; v != "" {
+			if lms.Passthrough, err = strconv.ParseBool(v); err != nil {
+				return fmt.Errorf("%s: passthrough must be true or false", id)
+			}
+		}
+		registerBackend(ctx, registry, lms)
+	}
+	return nil
+}
+
+// initOllamaWorkers 注册 Ollama 服务，格式 "id=url[,vram_gb=24,max_tasks=4,keep_alive=30m];..."
+func initOllamaWorkers(ctx context.Context, registry gatewayRegistry) error {
+	for _, entry := range strings.Split(os.Getenv("OLLAMA_WORKERS"), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, url, opts, err := parseBackendEntry(entry)
+		if err != nil {
+			return err
+		}
+		ollama := worker.NewOllamaWorker(id, url)
+		if v := opts["vram_gb"]; v != "" {
+			gb, err := strconv.ParseFloat(v, 64)
+			if err != nil || gb <= 0 {
+				return fmt.Errorf("%s: vram_gb must be a positive number", id)
+			}
+			ollama.VRAM = uint64(gb * 1024 * 1024 * 1024)
+		}
+		if v := opts["max_tasks"]; v != "" {
+			if ollama.MaxTasks, err = strconv.Atoi(v); err != nil || ollama.MaxTasks < 1 {
+				return fmt.Errorf("%s: max_tasks must be a positive integer", id)
+			}
+		}
+		if v := opts["keep_alive"]; v != "" {
+			if _, err := time.ParseDuration(v); err != nil && v != "-1" {
+				return fmt.Errorf("%s: keep_alive must be a duration such as 30m, or -1", id)
+			}
+			ollama.KeepAlive = v
+		}
+		registerBackend(ctx, registry, ollama)
+	}
+	return nil
+}
+
+// initVLLMWorkers 注册 vLLM OpenAI 兼容服务，格式 "id=url[,vram_gb=24,max_tasks=256,passthrough=true];..."
+func initVLLMWorkers(ctx context.Context, registry gatewayRegistry) error {
+	for _, entry := range strings.Split(os.Getenv("VLLM_WORKERS"), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, url, opts, err := parseBackendEntry(entry)
+		if err != nil {
+			return err
+		}
+		vllm := worker.NewVLLMWorker(id, url)
+		if v := opts["vram_gb"]; v != "" {
+			gb, err := strconv.ParseFloat(v, 64)
+			if err != nil || gb <= 0 {
+				return fmt.Errorf("%s: vram_gb must be a positive number", id)
+			}
+			vllm.VRAM = uint64(gb * 1024 * 1024 * 1024)
+		}
+		if v := opts["max_tasks"]; v != "" {
+			if vllm.MaxTasks, err = strconv.Atoi(v); err != nil || vllm.MaxTasks < 1 {
+				return fmt.Errorf("%s: max_tasks must be a positive integer", id)
+			}
+		}
+		// vLLM 输出标准 OpenAI SSE，开启后无需改写的流直接透传
+		if v := opts["passthrough"]; v != "" {
+			if vllm.Passthrough, err = strconv.ParseBool(v); err != nil {
+				return fmt.Errorf("%s: passthrough must be true or false", id)
+			}
+		}
+		registerBackend(ctx, registry, vllm)
+	}
+	return nil
+}
+
+// initLlamaCppWorkers 注册 llama.cpp server 后端，格式 "id=url[,models=a|b,vram_gb=24];..."
+func initLlamaCppWorkers(ctx context.Context, registry gatewayRegistry) error {
+	for _, entry := range strings.Split(os.Getenv("LLAMACPP_WORKERS"), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, url, opts, err := parseBackendEntry(entry)
+		if err != nil {
+			return err
+		}
+		llama := worker.NewLlamaCppWorker(id, url)
+		if models := opts["models"]; models != "" {
+			llama.Models = strings.Split(models, "|")
+		}
+		if v := opts["vram_gb"]; v != "" {
+			gb, err := strconv.ParseFloat(v, 64)
+			if err != nil || gb <= 0 {
+				return fmt.Errorf("%s: vram_gb must be a positive number", id)
+			}
+			llama.VRAM = uint64(gb * 1024 * 1024 * 1024)
+		}
+		registerBackend(ctx, registry, llama)
+	}
+	return nil
+}
+
+// initCloudWorkers 注册云端兜底 Worker，格式
+// "id=url,provider=openai|azure|anthropic,key_env=OPENAI_API_KEY[,models=alias:model|...,priority=1,cost_per_1k=0.01,api_version=...,max_tasks=100];..."
+// API Key 从 key_env 指定的环境变量读取，避免明文出现在配置中
+func initCloudWorkers(ctx context.Context, registry gatewayRegistry) error {
+	for _, entry := range strings.Split(os.Getenv("CLOUD_WORKERS"), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, url, opts, err := parseBackendEntry(entry)
+		if err != nil {
+			return err
+		}
+		if opts["key_env"] == "" {
+			return fmt.Errorf("%s: key_env is required", id)
+		}
+		apiKey := os.Getenv(opts["key_env"])
+		if apiKey == "" {
+			return fmt.Errorf("%s: environment variable %s is empty", id, opts["key_env"])
+		}
+		cloud, err := worker.NewCloudWorker(id, opts["provider"], url, apiKey)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		if models := opts["models"]; models != "" {
+			cloud.ModelMap = make(map[string]string)
+			for _, m := range strings.Split(models, "|") {
+				alias, target, ok := strings.Cut(m, ":")
+				if !ok || alias == "" || target == "" {
+					return fmt.Errorf("%s: models must be alias:model pairs", id)
+				}
+				cloud.ModelMap[alias] = target
+			}
+		}
+		if v := opts["priority"]; v != "" {
+			if cloud.Priority, err = strconv.Atoi(v); err != nil {
+				return fmt.Errorf("%s: priority must be an integer", id)
+			}
+		}
+		if v := opts["cost_per_1k"]; v != "" {
+			if cloud.CostPer1KTokens, err = core.ParseBudget(v); err != nil {
+				return fmt.Errorf("%s: cost_per_1k must be a non-negative number", id)
+			}
+		}
+		if v := opts["api_version"]; v != "" {
+			cloud.APIVersion = v
+		}
+		if v := opts["max_tasks"]; v != "" {
+			if cloud.MaxTasks, err = strconv.Atoi(v); err != nil || cloud.MaxTasks < 1 {
+				return fmt.Errorf("%s: max_tasks must be a positive integer", id)
+			}
+		}
+		registerBackend(ctx, registry, cloud)
+	}
+	return nil
+}
+
+// initSimWorkers 注册故障注入 Worker（混沌测试模式），格式 "id:opt=v,...;id2:..."
+func initSimWorkers(ctx context.Context, registry gatewayRegistry) error {
+	spec := os.Getenv("SIM_WORKERS")
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, options, _ := strings.Cut(entry, ":")
+		config, err := worker.ParseSimConfig(options)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		sim, err := worker.NewSimWorker(strings.TrimSpace(id), config)
+		if err != nil {
+			return err
+		}
+		go func() {
+			<-ctx.Done()
+			sim.Close()
+		}()
+
+		registerBackend(ctx, registry, sim)
+		slog.Warn("chaos mode: registered simulated worker", "worker_id", sim.ID(), "options", options)
+	}
+	return nil
+}
+
+// initMockWorkers 按配置创建 Mock Workers 并注册到注册中心
+func initMockWorkers(registry gatewayRegistry, mocks []config.MockWorker) {
+	for _, m := range mocks {
+		w := &MockWorker{
+			id:         m.ID,
+			models:     m.Models,
+			totalVRAM:  uint64(m.VRAMGB * 1024 * 1024 * 1024),
+			maxTasks:   m.MaxTasks,
+			isFallback: m.Fallback,
+		}
+		profile, _ := w.Heartbeat(context.Background())
+		registry.RegisterWorker(w, profile)
+	}
 }
 
 // MockWorker 是一个用于测试的 Mock Worker 实现
@@ -207,6 +1467,7 @@ func (m *MockWorker) Heartbeat(ctx context.Context) (core.WorkerProfile, error)
 		AvailableVRAM: availableVRAM,
 		ActiveTasks:   m.activeTasks,
 		MaxTasks:      m.maxTasks,
+		IsFallback:    m.isFallback,
 	}, nil
 }
 
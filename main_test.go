@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"zam/api"
+	"zam/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMountWorkerRoutes_RegistrationNeedsToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	registry := core.NewInMemoryRegistry(ctx)
+
+	register := func(token, bearer string) int {
+		workerAPI := api.NewWorkerAPI(registry)
+		workerAPI.SetRegistration(func(profile core.WorkerProfile, _ string) core.Worker {
+			return NewHTTPWorkerFactory(profile.WorkerID, profile.Endpoint)
+		})
+		r := gin.New()
+		mountWorkerRoutes(r, workerAPI, token)
+
+		body := `{"worker_id":"evil","endpoint":"http://attacker.example/v1/chat/completions","models":["llama-8b"],"max_tasks":1}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/workers/register", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// 未设置 WORKER_TOKEN 时注册端点不挂载，任何人都无法注册 Worker
+	if code := register("", ""); code != http.StatusNotFound {
+		t.Errorf("expected registration to be unmounted without a worker token, got %d", code)
+	}
+	if len(registry.GetAvailableWorkers()) != 0 {
+		t.Fatal("expected no worker to be registered without a worker token")
+	}
+	if code := register("secret", "guess"); code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong worker token to be rejected, got %d", code)
+	}
+	if code := register("secret", "secret"); code != http.StatusOK {
+		t.Errorf("expected registration with the worker token to succeed, got %d", code)
+	}
+}
+
+// TestOpenAPI_CoversMainRoutes builds the OpenAPI document for every route main.go mounts and
+// checks that it parses and documents each of them
+func TestOpenAPI_CoversMainRoutes(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", nil, 0)
+	if err != nil {
+		t.Fatalf("parse main.go: %v", err)
+	}
+
+	// 按源码顺序记录路由组的前缀，再收集在引擎与各路由组上注册的路由
+	prefixes := map[string]string{"r": ""}
+	var routes gin.RoutesInfo
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if len(n.Lhs) != 1 || len(n.Rhs) != 1 {
+				return true
+			}
+			name, ok := n.Lhs[0].(*ast.Ident)
+			if !ok {
+				return true
+			}
+			if group, path, ok := routeCall(n.Rhs[0], prefixes); ok && group == "Group" {
+				prefixes[name.Name] = path
+			}
+		case *ast.CallExpr:
+			if method, path, ok := routeCall(n, prefixes); ok && method != "Group" {
+				routes = append(routes, gin.RouteInfo{Method: method, Path: path})
+			}
+		}
+		return true
+	})
+	if len(routes) < 20 {
+		t.Fatalf("expected the routes of main.go, found %d", len(routes))
+	}
+
+	openAPI := api.NewOpenAPI("ZAM Gateway", "1.0.0")
+	api.DescribeGateway(openAPI)
+	data, err := json.Marshal(openAPI.Document(routes))
+	if err != nil {
+		t.Fatalf("marshal document: %v", err)
+	}
+	var doc struct {
+		OpenAPI string                                         `json:"openapi"`
+		Paths   map[string]map[string]struct{ Summary string } `json:"paths"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil || !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatalf("expected an OpenAPI 3 document, got %.200s (%v)", data, err)
+	}
+	for _, route := range routes {
+		path := regexp.MustCompile(`[:*]([^/]+)`).ReplaceAllString(route.Path, "{$1}")
+		op, ok := doc.Paths[path][strings.ToLower(route.Method)]
+		if !ok {
+			t.Errorf("%s %s is missing from the document", route.Method, route.Path)
+			continue
+		}
+		if op.Summary == "Undocumented endpoint" {
+			t.Errorf("%s %s has no Operation in DescribeGateway", route.Method, route.Path)
+		}
+	}
+}
+
+// routeCall matches x.GET("/path", ...) and x.Group("/path", ...) on a known router x and
+// returns the method and the full path
+func routeCall(expr ast.Expr, prefixes map[string]string) (method, path string, ok bool) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return "", "", false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", "", false
+	}
+	recv, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "", "", false
+	}
+	prefix, known := prefixes[recv.Name]
+	lit, isLit := call.Args[0].(*ast.BasicLit)
+	if !known || !isLit || lit.Kind != token.STRING {
+		return "", "", false
+	}
+	switch sel.Sel.Name {
+	case "Group", http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch:
+	default:
+		return "", "", false
+	}
+	p, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", "", false
+	}
+	return sel.Sel.Name, prefix + p, true
+}
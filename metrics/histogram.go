@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are upper bounds in seconds suited to LLM request latencies
+var DefaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// Exemplar links one observation to the trace of the request that produced it
+type Exemplar struct {
+	TraceID string
+	Value   float64
+	Time    time.Time
+}
+
+// Histogram counts observations into cumulative buckets per label combination
+// Each bucket keeps the exemplar of its latest traced observation
+type Histogram struct {
+	metricName string
+	help       string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64 // per bucket, non-cumulative; the last entry is +Inf
+	exemplars   []*Exemplar
+	sum         float64
+	count       uint64
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds and label names
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &Histogram{metricName: name, help: help, labels: labels, buckets: sorted, series: make(map[string]*histogramSeries)}
+	r.register(h)
+	return h
+}
+
+func (h *Histogram) name() string {
+	return h.metricName
+}
+
+// Observe records v for the label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.ObserveWithExemplar(v, "", labelValues...)
+}
+
+// ObserveWithExemplar records v and, when traceID is set, keeps it as the exemplar of v's bucket
+func (h *Histogram) ObserveWithExemplar(v float64, traceID string, labelValues ...string) {
+	if len(labelValues) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", h.metricName, len(h.labels), len(labelValues)))
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := strings.Join(labelValues, "\xff")
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)+1),
+			exemplars:   make([]*Exemplar, len(h.buckets)+1),
+		}
+		h.series[key] = s
+	}
+
+	i := sort.SearchFloat64s(h.buckets, v)
+	s.counts[i]++
+	s.sum += v
+	s.count++
+	if traceID != "" {
+		s.exemplars[i] = &Exemplar{TraceID: traceID, Value: v, Time: time.Now()}
+	}
+}
+
+// Count returns the number of observations for the label values
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[strings.Join(labelValues, "\xff")]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer, openMetrics bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.metricName, h.help, "histogram")
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := h.series[k]
+		var cumulative uint64
+		for i := range s.counts {
+			cumulative += s.counts[i]
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = formatValue(h.buckets[i])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d", h.metricName, formatLabels(h.labels, s.labelValues, "le", le), cumulative)
+			// 只有 OpenMetrics 格式支持 Exemplar
+			if e := s.exemplars[i]; openMetrics && e != nil {
+				fmt.Fprintf(w, " # {trace_id=\"%s\"} %s %.3f", escapeLabel(e.TraceID), formatValue(e.Value), float64(e.Time.UnixNano())/1e9)
+			}
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, formatLabels(h.labels, s.labelValues), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, formatLabels(h.labels, s.labelValues), s.count)
+	}
+}
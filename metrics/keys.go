@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// HashKey turns an API key into a stable, non-reversible label value
+func HashKey(key string) string {
+	if key == "" {
+		return "anonymous"
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// KeyMetrics tracks traffic per (hashed) API key
+type KeyMetrics struct {
+	requests *Counter
+	errors   *Counter
+	tokens   *Counter
+	inflight *Gauge
+}
+
+// NewKeyMetrics registers the per-key metric families
+func NewKeyMetrics(r *Registry) *KeyMetrics {
+	return &KeyMetrics{
+		requests: r.NewCounter("zam_key_requests_total", "Requests per hashed API key and HTTP status code.", "key", "code"),
+		errors:   r.NewCounter("zam_key_errors_total", "Failed requests (HTTP status >= 400) per hashed API key.", "key"),
+		tokens:   r.NewCounter("zam_key_tokens_total", "Tokens processed per hashed API key, split into prompt and completion.", "key", "kind"),
+		inflight: r.NewGauge("zam_key_inflight_requests", "Requests currently in flight per hashed API key.", "key"),
+	}
+}
+
+// Start records a request of key entering the gateway
+// The returned func records its HTTP status and must be called exactly once
+func (m *KeyMetrics) Start(key string) (done func(status int)) {
+	label := HashKey(key)
+	m.inflight.Add(1, label)
+	return func(status int) {
+		m.inflight.Add(-1, label)
+		m.requests.Inc(label, strconv.Itoa(status))
+		if status >= 400 {
+			m.errors.Inc(label)
+		}
+	}
+}
+
+// Tokens records the tokens of a finished request
+func (m *KeyMetrics) Tokens(key string, prompt, completion int) {
+	label := HashKey(key)
+	if prompt > 0 {
+		m.tokens.Add(float64(prompt), label, "prompt")
+	}
+	if completion > 0 {
+		m.tokens.Add(float64(completion), label, "completion")
+	}
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"zam/core"
+)
+
+// DefaultTokenLatencyBuckets are upper bounds in seconds for the time per output token
+var DefaultTokenLatencyBuckets = []float64{0.005, 0.01, 0.02, 0.03, 0.05, 0.075, 0.1, 0.15, 0.25, 0.5, 1}
+
+// LatencyMetrics tracks request, prefill and per-token latency, with trace-ID exemplars
+type LatencyMetrics struct {
+	request  *Histogram
+	prefill  *Histogram
+	perToken *Histogram
+}
+
+// NewLatencyMetrics registers the latency histograms
+func NewLatencyMetrics(r *Registry) *LatencyMetrics {
+	return &LatencyMetrics{
+		request:  r.NewHistogram("zam_request_duration_seconds", "End-to-end chat completion latency per model.", DefaultLatencyBuckets, "model"),
+		prefill:  r.NewHistogram("zam_prefill_duration_seconds", "Time to first token per model and worker.", DefaultLatencyBuckets, "model", "worker"),
+		perToken: r.NewHistogram("zam_token_duration_seconds", "Mean time per output token after the first, per model and worker.", DefaultTokenLatencyBuckets, "model", "worker"),
+	}
+}
+
+// ObserveRequest records the latency of a finished request; traceID becomes the bucket's exemplar
+// A nil LatencyMetrics records nothing
+func (m *LatencyMetrics) ObserveRequest(model string, d time.Duration, traceID string) {
+	if m == nil {
+		return
+	}
+	m.request.ObserveWithExemplar(d.Seconds(), traceID, model)
+}
+
+// Instrument wraps a worker so the prefill and per-token latency of its next Execute are recorded
+// A nil LatencyMetrics returns the worker unchanged
+func (m *LatencyMetrics) Instrument(w core.Worker, traceID string) core.Worker {
+	if m == nil {
+		return w
+	}
+	return &timedWorker{Worker: w, metrics: m, traceID: traceID}
+}
+
+// timedWorker measures the stream of one Execute call
+type timedWorker struct {
+	core.Worker
+	metrics *LatencyMetrics
+	traceID string
+}
+
+// Execute forwards to the wrapped worker; every chunk with content counts as one token
+func (w *timedWorker) Execute(ctx context.Context, req *core.InferenceRequest, sender func(chunk core.StreamChunk) error) error {
+	start := time.Now()
+	var first, last time.Time
+	tokens := 0
+	err := w.Worker.Execute(ctx, req, func(chunk core.StreamChunk) error {
+		if chunk.Content != "" || chunk.Reasoning != "" {
+			last = time.Now()
+			if tokens == 0 {
+				first = last
+			}
+			tokens++
+		}
+		return sender(chunk)
+	})
+
+	// 只统计成功的请求，避免失败的慢请求污染分布
+	if err == nil && tokens > 0 {
+		workerID := w.Worker.ID()
+		w.metrics.prefill.ObserveWithExemplar(first.Sub(start).Seconds(), w.traceID, req.Model, workerID)
+		if tokens > 1 {
+			perToken := last.Sub(first).Seconds() / float64(tokens-1)
+			w.metrics.perToken.ObserveWithExemplar(perToken, w.traceID, req.Model, workerID)
+		}
+	}
+	return err
+}
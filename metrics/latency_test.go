@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"zam/core"
+)
+
+type slowWorker struct{}
+
+func (slowWorker) ID() string { return "gpu-1" }
+
+func (slowWorker) Heartbeat(ctx context.Context) (core.WorkerProfile, error) {
+	return core.WorkerProfile{WorkerID: "gpu-1"}, nil
+}
+
+func (slowWorker) Execute(ctx context.Context, req *core.InferenceRequest, sender func(chunk core.StreamChunk) error) error {
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if err := sender(core.StreamChunk{Content: "tok"}); err != nil {
+			return err
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return sender(core.StreamChunk{FinishReason: "stop"})
+}
+
+func TestLatencyMetrics_Instrument(t *testing.T) {
+	m := NewLatencyMetrics(NewRegistry())
+	w := m.Instrument(slowWorker{}, "trace-1")
+	if w.ID() != "gpu-1" {
+		t.Fatalf("expected wrapped worker ID, got %s", w.ID())
+	}
+
+	chunks := 0
+	err := w.Execute(context.Background(), &core.InferenceRequest{Model: "llama-8b"}, func(core.StreamChunk) error {
+		chunks++
+		return nil
+	})
+	if err != nil || chunks != 4 {
+		t.Fatalf("expected chunks to pass through, got %d, %v", chunks, err)
+	}
+	if m.prefill.Count("llama-8b", "gpu-1") != 1 || m.perToken.Count("llama-8b", "gpu-1") != 1 {
+		t.Error("expected one prefill and one per-token observation")
+	}
+
+	var nilMetrics *LatencyMetrics
+	if nilMetrics.Instrument(slowWorker{}, "") != (slowWorker{}) {
+		t.Error("nil metrics must not wrap the worker")
+	}
+}
@@ -0,0 +1,275 @@
+// Package metrics is a small Prometheus text-format registry, so the gateway can be scraped
+// without pulling in the Prometheus client library
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metric families and renders them in the Prometheus text exposition format
+type Registry struct {
+	mu       sync.RWMutex
+	families []family
+}
+
+// family is one named metric with its HELP/TYPE header
+// openMetrics selects the OpenMetrics text format, which additionally carries exemplars
+type family interface {
+	name() string
+	write(w io.Writer, openMetrics bool)
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.families {
+		if existing.name() == f.name() {
+			panic("metrics: duplicate metric " + f.name())
+		}
+	}
+	r.families = append(r.families, f)
+}
+
+// Write renders every family in the Prometheus text format, sorted by name
+func (r *Registry) Write(w io.Writer) {
+	r.write(w, false)
+}
+
+// WriteOpenMetrics renders every family in the OpenMetrics text format, including exemplars
+func (r *Registry) WriteOpenMetrics(w io.Writer) {
+	r.write(w, true)
+	fmt.Fprintln(w, "# EOF")
+}
+
+func (r *Registry) write(w io.Writer, openMetrics bool) {
+	r.mu.RLock()
+	families := append([]family(nil), r.families...)
+	r.mu.RUnlock()
+
+	sort.Slice(families, func(i, j int) bool { return families[i].name() < families[j].name() })
+	for _, f := range families {
+		f.write(w, openMetrics)
+	}
+}
+
+// Handler serves the registry for Prometheus scrapes
+// Scrapers asking for OpenMetrics (required for exemplars) get that format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text") {
+			w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+			r.WriteOpenMetrics(w)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// vec stores one value per label combination
+type vec struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]*sample
+}
+
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+func newVec(name, help, kind string, labels []string) *vec {
+	return &vec{metricName: name, help: help, kind: kind, labels: labels, values: make(map[string]*sample)}
+}
+
+func (v *vec) name() string {
+	return v.metricName
+}
+
+// sampleLocked returns the sample of the label values; caller must hold v.mu
+func (v *vec) sampleLocked(labelValues []string) *sample {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.metricName, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.values[key]
+	if !ok {
+		s = &sample{labelValues: append([]string(nil), labelValues...)}
+		v.values[key] = s
+	}
+	return s
+}
+
+func (v *vec) add(delta float64, labelValues []string) {
+	v.mu.Lock()
+	v.sampleLocked(labelValues).value += delta
+	v.mu.Unlock()
+}
+
+func (v *vec) set(value float64, labelValues []string) {
+	v.mu.Lock()
+	v.sampleLocked(labelValues).value = value
+	v.mu.Unlock()
+}
+
+func (v *vec) get(labelValues []string) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.sampleLocked(labelValues).value
+}
+
+func (v *vec) write(w io.Writer, openMetrics bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// OpenMetrics 的 counter 族名不带 _total 后缀
+	familyName := v.metricName
+	if openMetrics && v.kind == "counter" {
+		familyName = strings.TrimSuffix(familyName, "_total")
+	}
+	writeHeader(w, familyName, v.help, v.kind)
+	for _, s := range sortedSamples(v.values) {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, formatLabels(v.labels, s.labelValues), formatValue(s.value))
+	}
+}
+
+// Counter is a monotonically increasing value per label combination
+type Counter struct {
+	*vec
+}
+
+// NewCounter registers a counter with the given label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newVec(name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+// Inc adds 1 to the counter of the label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.add(1, labelValues)
+}
+
+// Add adds delta (which must not be negative) to the counter of the label values
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+	c.add(delta, labelValues)
+}
+
+// Value returns the current value of the label values
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.get(labelValues)
+}
+
+// Gauge is a value per label combination that can go up and down
+type Gauge struct {
+	*vec
+}
+
+// NewGauge registers a gauge with the given label names
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{newVec(name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+// Set replaces the gauge of the label values
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.set(value, labelValues)
+}
+
+// Add adds delta to the gauge of the label values
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.add(delta, labelValues)
+}
+
+// Value returns the current value of the label values
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.get(labelValues)
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, kind)
+}
+
+func sortedSamples(values map[string]*sample) []*sample {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	samples := make([]*sample, 0, len(keys))
+	for _, k := range keys {
+		samples = append(samples, values[k])
+	}
+	return samples
+}
+
+// formatLabels renders {a="x",b="y"}; extra pairs (e.g. le) are appended after the metric's own labels
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	write := func(name, value string) {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(value))
+		b.WriteByte('"')
+	}
+	for i, name := range names {
+		write(name, values[i])
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		write(extra[i], extra[i+1])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
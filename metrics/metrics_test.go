@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter("zam_requests_total", "Requests.", "model")
+	inflight := r.NewGauge("zam_inflight", "In flight.")
+
+	requests.Inc("llama-8b")
+	requests.Add(2, `we"ird`)
+	inflight.Add(3)
+	inflight.Add(-1)
+
+	var out strings.Builder
+	r.Write(&out)
+	want := `# HELP zam_inflight In flight.
+# TYPE zam_inflight gauge
+zam_inflight 2
+# HELP zam_requests_total Requests.
+# TYPE zam_requests_total counter
+zam_requests_total{model="llama-8b"} 1
+zam_requests_total{model="we\"ird"} 2
+`
+	if out.String() != want {
+		t.Errorf("unexpected exposition:\n%s", out.String())
+	}
+}
+
+func TestKeyMetrics(t *testing.T) {
+	r := NewRegistry()
+	m := NewKeyMetrics(r)
+
+	done := m.Start("sk-secret")
+	if got := m.inflight.Value(HashKey("sk-secret")); got != 1 {
+		t.Errorf("expected 1 in flight, got %v", got)
+	}
+	done(429)
+	m.Tokens("sk-secret", 10, 5)
+
+	label := HashKey("sk-secret")
+	if m.inflight.Value(label) != 0 || m.requests.Value(label, "429") != 1 || m.errors.Value(label) != 1 {
+		t.Error("expected finished request to be counted as an error")
+	}
+	if m.tokens.Value(label, "prompt") != 10 || m.tokens.Value(label, "completion") != 5 {
+		t.Error("expected prompt and completion tokens")
+	}
+
+	var out strings.Builder
+	r.Write(&out)
+	if strings.Contains(out.String(), "sk-secret") {
+		t.Error("raw API key leaked into metrics")
+	}
+}
+
+func TestHistogramExemplars(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("zam_latency_seconds", "Latency.", []float64{1, 0.5}, "model")
+	r.NewCounter("zam_hits_total", "Hits.").Inc()
+
+	h.Observe(0.2, "m")
+	h.ObserveWithExemplar(0.7, "4bf92f3577b34da6a3ce929d0e0e4736", "m")
+	h.Observe(3, "m")
+
+	var plain strings.Builder
+	r.Write(&plain)
+	if strings.Contains(plain.String(), "trace_id") {
+		t.Error("exemplars must only appear in OpenMetrics output")
+	}
+	for _, line := range []string{
+		`zam_latency_seconds_bucket{model="m",le="0.5"} 1`,
+		`zam_latency_seconds_bucket{model="m",le="1"} 2`,
+		`zam_latency_seconds_bucket{model="m",le="+Inf"} 3`,
+		`zam_latency_seconds_sum{model="m"} 3.9`,
+		`zam_latency_seconds_count{model="m"} 3`,
+		`# TYPE zam_hits_total counter`,
+	} {
+		if !strings.Contains(plain.String(), line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, plain.String())
+		}
+	}
+
+	var om strings.Builder
+	r.WriteOpenMetrics(&om)
+	if !strings.Contains(om.String(), `zam_latency_seconds_bucket{model="m",le="1"} 2 # {trace_id="4bf92f3577b34da6a3ce929d0e0e4736"} 0.7 `) {
+		t.Errorf("expected exemplar on the le=1 bucket:\n%s", om.String())
+	}
+	if !strings.Contains(om.String(), "# TYPE zam_hits counter\n") || !strings.HasSuffix(om.String(), "# EOF\n") {
+		t.Errorf("unexpected OpenMetrics framing:\n%s", om.String())
+	}
+}
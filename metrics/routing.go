@@ -0,0 +1,24 @@
+package metrics
+
+// RoutingMetrics counts routing outcomes and why workers were filtered out
+// It satisfies router.DecisionObserver
+type RoutingMetrics struct {
+	decisions  *Counter
+	exclusions *Counter
+}
+
+// NewRoutingMetrics registers the routing decision counters
+func NewRoutingMetrics(r *Registry) *RoutingMetrics {
+	return &RoutingMetrics{
+		decisions:  r.NewCounter("zam_routing_decisions_total", "Routing decisions by outcome (local, peer, fallback, no_workers).", "outcome"),
+		exclusions: r.NewCounter("zam_routing_exclusions_total", "Workers filtered out of routing decisions by reason.", "reason"),
+	}
+}
+
+// ObserveRouting records one routing decision
+func (m *RoutingMetrics) ObserveRouting(outcome string, excluded map[string]string) {
+	m.decisions.Inc(outcome)
+	for _, reason := range excluded {
+		m.exclusions.Inc(reason)
+	}
+}
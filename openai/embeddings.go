@@ -0,0 +1,105 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// MaxEmbeddingInputs is the largest input array accepted by the OpenAI embeddings API
+const MaxEmbeddingInputs = 2048
+
+// EmbeddingRequest represents an OpenAI embeddings request
+type EmbeddingRequest struct {
+	Model string         `json:"model"`
+	Input EmbeddingInput `json:"input"`
+	// EncodingFormat is "float" (default) or "base64"
+	EncodingFormat string `json:"encoding_format,omitempty"`
+	// Dimensions asks models that support it to shorten their vectors (0 = model default)
+	Dimensions int    `json:"dimensions,omitempty"`
+	User       string `json:"user,omitempty"`
+}
+
+// EmbeddingInput is the text to embed; the API accepts a single string or an array of strings
+// Pre-tokenized inputs (arrays of token IDs) are not supported
+type EmbeddingInput []string
+
+// UnmarshalJSON accepts both the string and the array form of input
+func (in *EmbeddingInput) UnmarshalJSON(data []byte) error {
+	raw := bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(raw, []byte("null")):
+		*in = nil
+		return nil
+	case len(raw) > 0 && raw[0] == '"':
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return err
+		}
+		*in = EmbeddingInput{text}
+		return nil
+	}
+	if err := json.Unmarshal(raw, (*[]string)(in)); err != nil {
+		return fmt.Errorf("input must be a string or an array of strings")
+	}
+	return nil
+}
+
+// Validate checks the request against the limits of the OpenAI embeddings API
+func (r *EmbeddingRequest) Validate() *Error {
+	switch {
+	case r.Model == "":
+		return NewInvalidRequestError("model is required").WithParam("model")
+	case len(r.Input) == 0:
+		return NewInvalidRequestError("input is required").WithParam("input")
+	case len(r.Input) > MaxEmbeddingInputs:
+		return NewInvalidRequestError(fmt.Sprintf("input accepts at most %d items, got %d", MaxEmbeddingInputs, len(r.Input))).WithParam("input")
+	case r.EncodingFormat != "" && r.EncodingFormat != "float" && r.EncodingFormat != "base64":
+		return NewInvalidRequestError(fmt.Sprintf("encoding_format must be float or base64, got %q", r.EncodingFormat)).WithParam("encoding_format")
+	case r.Dimensions < 0:
+		return NewInvalidRequestError(fmt.Sprintf("dimensions must be a positive integer, got %d", r.Dimensions)).WithParam("dimensions")
+	}
+	for i, text := range r.Input {
+		if text == "" {
+			return NewInvalidRequestError(fmt.Sprintf("input[%d] must not be empty", i)).WithParam(fmt.Sprintf("input[%d]", i))
+		}
+	}
+	return nil
+}
+
+// EmbeddingResponse is the response of POST /v1/embeddings
+type EmbeddingResponse struct {
+	Object string         `json:"object"`
+	Data   []Embedding    `json:"data"`
+	Model  string         `json:"model"`
+	Usage  EmbeddingUsage `json:"usage"`
+}
+
+// Embedding is the vector of one input
+type Embedding struct {
+	Object string `json:"object"`
+	Index  int    `json:"index"`
+	// Embedding is a []float32, or a base64 string of little-endian float32 values for encoding_format "base64"
+	Embedding interface{} `json:"embedding"`
+}
+
+// EmbeddingUsage reports the tokens of an embeddings request
+type EmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// EncodeEmbedding renders a vector in the requested encoding_format
+func EncodeEmbedding(vector []float32, format string) interface{} {
+	if format != "base64" {
+		return vector
+	}
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,93 @@
+package openai
+
+import "net/http"
+
+// Error types used in OpenAI-compatible error objects
+const (
+	InvalidRequestErrorType = "invalid_request_error"
+	AuthenticationErrorType = "authentication_error"
+	PermissionErrorType     = "permission_error"
+	InsufficientQuotaType   = "insufficient_quota"
+	RateLimitErrorType      = "rate_limit_error"
+	ServerErrorType         = "server_error"
+	TimeoutErrorType        = "timeout_error"
+)
+
+// Error is an OpenAI-compatible error object; param and code are always present and null when unset
+// Status is the HTTP status the error is rendered with
+type Error struct {
+	Status  int     `json:"-"`
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
+// ErrorBody is the {"error": {...}} envelope of HTTP error responses and SSE error events
+type ErrorBody struct {
+	Error *Error `json:"error"`
+	// RequestID lets clients quote the failing request in support requests (ZAM extension)
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// NewError creates an error object rendered with the given HTTP status
+func NewError(status int, errType, message string) *Error {
+	return &Error{Status: status, Message: message, Type: errType}
+}
+
+// NewInvalidRequestError reports a malformed or unsupported request (400)
+func NewInvalidRequestError(message string) *Error {
+	return NewError(http.StatusBadRequest, InvalidRequestErrorType, message)
+}
+
+// NewAuthenticationError reports a missing or invalid credential (401)
+func NewAuthenticationError(message string) *Error {
+	return NewError(http.StatusUnauthorized, AuthenticationErrorType, message)
+}
+
+// NewPermissionError reports a valid credential without access to the resource (403)
+func NewPermissionError(message string) *Error {
+	return NewError(http.StatusForbidden, PermissionErrorType, message)
+}
+
+// NewNotFoundError reports an unknown resource (404)
+func NewNotFoundError(message string) *Error {
+	return NewError(http.StatusNotFound, InvalidRequestErrorType, message)
+}
+
+// NewQuotaError reports an exhausted quota or spend cap (429, code insufficient_quota)
+func NewQuotaError(message string) *Error {
+	return NewError(http.StatusTooManyRequests, InsufficientQuotaType, message).WithCode("insufficient_quota")
+}
+
+// NewServerError reports a gateway or upstream failure with a 5xx status
+func NewServerError(status int, message string) *Error {
+	return NewError(status, ServerErrorType, message)
+}
+
+// NewTimeoutError reports a request that ran out of time (408)
+func NewTimeoutError(message string) *Error {
+	return NewError(http.StatusRequestTimeout, TimeoutErrorType, message).WithCode("timeout")
+}
+
+// WithParam names the request parameter the error refers to
+func (e *Error) WithParam(param string) *Error {
+	e.Param = &param
+	return e
+}
+
+// WithCode sets the machine-readable error code
+func (e *Error) WithCode(code string) *Error {
+	e.Code = &code
+	return e
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Body wraps the error in its response envelope
+func (e *Error) Body() ErrorBody {
+	return ErrorBody{Error: e}
+}
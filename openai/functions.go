@@ -0,0 +1,74 @@
+package openai
+
+import "fmt"
+
+// LegacyFunctions reports whether the request uses the deprecated functions/function_call API,
+// whose responses carry function_call instead of tool_calls
+func (r *ChatCompletionRequest) LegacyFunctions() bool {
+	return len(r.Functions) > 0
+}
+
+// UpgradeFunctions rewrites a request using the deprecated functions/function_call API into tools and
+// tool_choice, so workers only ever see tool calling. Legacy messages of the conversation are rewritten too:
+// an assistant function_call becomes a tool call with a generated ID, and the "function" message answering
+// it becomes a "tool" message linked to that ID
+func (r *ChatCompletionRequest) UpgradeFunctions() *Error {
+	if r.FunctionCall != nil && len(r.Functions) == 0 {
+		return NewInvalidRequestError("function_call is only allowed when functions are specified").WithParam("function_call")
+	}
+	if len(r.Functions) > 0 {
+		if len(r.Tools) > 0 || r.ToolChoice != nil {
+			return NewInvalidRequestError("functions cannot be combined with tools or tool_choice; use tools").WithParam("functions")
+		}
+		for _, f := range r.Functions {
+			r.Tools = append(r.Tools, Tool{Type: "function", Function: f})
+		}
+		switch v := r.FunctionCall.(type) {
+		case nil:
+		case string:
+			if v != ToolChoiceNone && v != ToolChoiceAuto {
+				return NewInvalidRequestError(`function_call must be "none", "auto" or {"name": ...}`).WithParam("function_call")
+			}
+			r.ToolChoice = v
+		case map[string]interface{}:
+			name, _ := v["name"].(string)
+			if name == "" {
+				return NewInvalidRequestError(`function_call must be "none", "auto" or {"name": ...}`).WithParam("function_call")
+			}
+			r.ToolChoice = map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": name}}
+		default:
+			return NewInvalidRequestError(`function_call must be "none", "auto" or {"name": ...}`).WithParam("function_call")
+		}
+	}
+
+	// 按函数名把 function 消息关联到最近一次同名调用
+	calls := make(map[string]string)
+	for i := range r.Messages {
+		m := &r.Messages[i]
+		switch {
+		case m.FunctionCall != nil:
+			id := fmt.Sprintf("call_%d", i)
+			m.ToolCalls = append(m.ToolCalls, ToolCall{ID: id, Type: "function", Function: *m.FunctionCall})
+			m.FunctionCall = nil
+			calls[m.ToolCalls[len(m.ToolCalls)-1].Function.Name] = id
+		case m.Role == "function":
+			id, ok := calls[m.Name]
+			if !ok {
+				param := fmt.Sprintf("messages[%d]", i)
+				return NewInvalidRequestError(fmt.Sprintf("%s answers function %q, which no earlier assistant message called", param, m.Name)).WithParam(param + ".name")
+			}
+			m.Role, m.ToolCallID, m.Name = "tool", id, ""
+		}
+	}
+	return nil
+}
+
+// FunctionCallOf returns the legacy function_call of a response carrying tool calls: the first call,
+// since the functions API allows only one per message
+func FunctionCallOf(calls []ToolCall) *FunctionCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	call := calls[0].Function
+	return &call
+}
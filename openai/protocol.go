@@ -1,5 +1,12 @@
 package openai
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 // ChatCompletionRequest represents a chat completion request
 type ChatCompletionRequest struct {
 	Model       string        `json:"model"`
@@ -9,9 +16,75 @@ type ChatCompletionRequest struct {
 	MaxTokens   int           `json:"max_tokens,omitempty"`
 	TopP        float32       `json:"top_p,omitempty"`
 	N           int           `json:"n,omitempty"`
-	Stop        []string      `json:"stop,omitempty"`
+	Stop        StopSequences `json:"stop,omitempty"`
 	Frequency   float32       `json:"frequency_penalty,omitempty"`
 	Presence    float32       `json:"presence_penalty,omitempty"`
+	// Tools, ToolChoice and ResponseFormat decide which worker capabilities the request needs
+	Tools          []Tool          `json:"tools,omitempty"`
+	ToolChoice     interface{}     `json:"tool_choice,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	// Functions and FunctionCall are the deprecated form of Tools and ToolChoice; see UpgradeFunctions
+	Functions    []ToolFunction `json:"functions,omitempty"`
+	FunctionCall interface{}    `json:"function_call,omitempty"`
+	// User identifies the client's end user for abuse attribution
+	User string `json:"user,omitempty"`
+}
+
+// Tool represents a tool the model may call
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction describes a callable function
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// StopSequences are the sequences ending generation; the API accepts a single string or an array
+type StopSequences []string
+
+// UnmarshalJSON accepts both the string and the array form of stop
+func (s *StopSequences) UnmarshalJSON(data []byte) error {
+	raw := bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(raw, []byte("null")):
+		*s = nil
+		return nil
+	case len(raw) > 0 && raw[0] == '"':
+		var stop string
+		if err := json.Unmarshal(raw, &stop); err != nil {
+			return err
+		}
+		*s = StopSequences{stop}
+		return nil
+	}
+	return json.Unmarshal(raw, (*[]string)(s))
+}
+
+// ResponseFormat requests structured output ("text", "json_object" or "json_schema")
+type ResponseFormat struct {
+	Type       string          `json:"type"`
+	JSONSchema json.RawMessage `json:"json_schema,omitempty"`
+}
+
+// WantsJSON reports whether the request asks for JSON-mode output
+func (r *ChatCompletionRequest) WantsJSON() bool {
+	return r.ResponseFormat != nil && r.ResponseFormat.Type != "" && r.ResponseFormat.Type != "text"
+}
+
+// HasImages reports whether any message carries an image part
+func (r *ChatCompletionRequest) HasImages() bool {
+	for _, m := range r.Messages {
+		for _, part := range m.Parts {
+			if part.Type == "image_url" {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // ChatCompletionResponse represents a non-streaming chat completion response
@@ -22,6 +95,8 @@ type ChatCompletionResponse struct {
 	Model   string  `json:"model"`
 	Choices []Choice `json:"choices"`
 	Usage   *Usage  `json:"usage,omitempty"`
+	// SystemFingerprint identifies the backend configuration that produced the response
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
 }
 
 // Choice represents a choice in non-streaming response
@@ -32,9 +107,104 @@ type Choice struct {
 }
 
 // Message represents a chat message
+// Content may be sent either as a string or as an array of parts (text / image_url)
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// ReasoningContent is the collected thinking of a reasoning model's reply
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	// ToolCalls are the calls requested by an assistant message
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID links a "tool" message to the call it answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// Name is the function a legacy "function" message answers
+	Name string `json:"name,omitempty"`
+	// FunctionCall is the call of an assistant message in the deprecated functions API
+	FunctionCall *FunctionCall `json:"function_call,omitempty"`
+	// Parts holds the array form of content; Content then carries the concatenated text parts
+	Parts []ContentPart `json:"-"`
+}
+
+// ToolCall is a function call requested by the model
+// In stream deltas Index identifies the call the fragment belongs to; it is omitted in full messages
+type ToolCall struct {
+	Index    *int         `json:"index,omitempty"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall names the function to call and carries its JSON-encoded arguments
+type FunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// ContentPart is one element of a multi-part message content
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL references an image by URL or data URI
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// messageAlias has Message's fields without its JSON methods
+type messageAlias Message
+
+// UnmarshalJSON accepts both the string and the array form of content
+func (m *Message) UnmarshalJSON(data []byte) error {
+	aux := struct {
+		*messageAlias
+		Content json.RawMessage `json:"content"`
+	}{messageAlias: (*messageAlias)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	m.Content, m.Parts = "", nil
+	raw := bytes.TrimSpace(aux.Content)
+	switch {
+	case len(raw) == 0 || bytes.Equal(raw, []byte("null")):
+	case raw[0] == '"':
+		return json.Unmarshal(raw, &m.Content)
+	case raw[0] == '[':
+		if err := json.Unmarshal(raw, &m.Parts); err != nil {
+			return err
+		}
+		var text strings.Builder
+		for _, part := range m.Parts {
+			if part.Type == "text" {
+				text.WriteString(part.Text)
+			}
+		}
+		m.Content = text.String()
+	default:
+		return fmt.Errorf("message content must be a string or an array of parts")
+	}
+	return nil
+}
+
+// MarshalJSON emits the array form of content when the message has parts,
+// and null content for tool-calling messages without text as the spec requires
+func (m Message) MarshalJSON() ([]byte, error) {
+	if len(m.Parts) == 0 && m.Content == "" && (len(m.ToolCalls) > 0 || m.FunctionCall != nil) {
+		return json.Marshal(struct {
+			messageAlias
+			Content *string `json:"content"`
+		}{messageAlias: (messageAlias)(m)})
+	}
+	if len(m.Parts) == 0 {
+		return json.Marshal((messageAlias)(m))
+	}
+	return json.Marshal(struct {
+		messageAlias
+		Content []ContentPart `json:"content"`
+	}{messageAlias: (messageAlias)(m), Content: m.Parts})
 }
 
 // ChatCompletionStreamResponse represents an OpenAI SSE streaming response
@@ -46,32 +216,43 @@ type ChatCompletionStreamResponse struct {
 	Choices []StreamChoice         `json:"choices"`
 	Usage   *Usage                 `json:"usage,omitempty"`
 	Error   *ErrorResponse         `json:"error,omitempty"`
+	// SystemFingerprint is the same on every chunk of a stream
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
 }
 
 // StreamChoice represents a choice in streaming response with delta content
+// finish_reason is null on every chunk but the last of a choice, as the spec requires
 type StreamChoice struct {
-	Index        int     `json:"index,omitempty"`
+	Index        int     `json:"index"`
 	Delta        Delta   `json:"delta"`
-	FinishReason *string `json:"finish_reason,omitempty"`
+	FinishReason *string `json:"finish_reason"`
 }
 
 // Delta represents the incremental content in streaming mode
 type Delta struct {
-	Content      string `json:"content,omitempty"`
+	Content string `json:"content,omitempty"`
+	// ReasoningContent is the thinking channel of reasoning models (DeepSeek-R1 style)
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	// Reasoning is the same channel as named by some backends (vLLM, OpenRouter); only read from upstream
+	Reasoning    string `json:"reasoning,omitempty"`
 	Role         string `json:"role,omitempty"`
-	FunctionCall *struct {
-		Name      string `json:"name,omitempty"`
-		Arguments string `json:"arguments,omitempty"`
-	} `json:"function_call,omitempty"`
-	ToolCalls []struct {
-		Index    int    `json:"index,omitempty"`
-		ID       string `json:"id,omitempty"`
-		Type     string `json:"type,omitempty"`
-		Function struct {
-			Name      string `json:"name,omitempty"`
-			Arguments string `json:"arguments,omitempty"`
-		} `json:"function,omitempty"`
-	} `json:"tool_calls,omitempty"`
+	FunctionCall *FunctionCall `json:"function_call,omitempty"`
+	ToolCalls    []ToolCall    `json:"tool_calls,omitempty"`
+}
+
+// deltaAlias has Delta's fields without its JSON methods
+type deltaAlias Delta
+
+// MarshalJSON emits an empty content next to the role of a choice's first chunk,
+// as OpenAI does: {"role":"assistant","content":""}
+func (d Delta) MarshalJSON() ([]byte, error) {
+	if d.Role == "" || d.Content != "" {
+		return json.Marshal((deltaAlias)(d))
+	}
+	return json.Marshal(struct {
+		deltaAlias
+		Content string `json:"content"`
+	}{deltaAlias: (deltaAlias)(d)})
 }
 
 // Usage represents token usage information
@@ -79,6 +260,8 @@ type Usage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	// Cost is the gateway-computed price of the request (ZAM extension)
+	Cost float64 `json:"cost,omitempty"`
 }
 
 // ErrorResponse represents an error response
@@ -87,3 +270,41 @@ type ErrorResponse struct {
 	Type    string `json:"type,omitempty"`
 	Code    string `json:"code,omitempty"`
 }
+
+// TokenizeRequest asks the gateway to count the tokens of a conversation
+type TokenizeRequest struct {
+	Model          string    `json:"model"`
+	Messages       []Message `json:"messages"`
+	ReturnTokenIDs bool      `json:"return_token_ids,omitempty"`
+}
+
+// TokenizeResponse reports the token count of a conversation
+type TokenizeResponse struct {
+	Object string `json:"object"`
+	Model  string `json:"model"`
+	Count  int    `json:"count"`
+	Tokens []int  `json:"tokens,omitempty"`
+	// Estimated is true when no worker could tokenize and the gateway's heuristic was used
+	Estimated bool `json:"estimated"`
+	// MaxContext is the context window of the worker the request would be routed to (0 = unknown)
+	MaxContext int   `json:"max_context,omitempty"`
+	Fits       *bool `json:"fits,omitempty"`
+}
+
+// Model is one entry of the model list
+type Model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+	// Workers lists the workers currently serving the model (ZAM extension)
+	Workers []string `json:"workers"`
+	// AliasOf names the deployed model an alias maps to (ZAM extension)
+	AliasOf string `json:"alias_of,omitempty"`
+}
+
+// ModelList is the response of GET /v1/models
+type ModelList struct {
+	Object string  `json:"object"`
+	Data   []Model `json:"data"`
+}
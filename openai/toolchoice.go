@@ -0,0 +1,75 @@
+package openai
+
+import "fmt"
+
+// Tool choice modes
+const (
+	ToolChoiceNone     = "none"
+	ToolChoiceAuto     = "auto"
+	ToolChoiceRequired = "required"
+	// ToolChoiceFunction forces a call to ToolChoice.Function
+	ToolChoiceFunction = "function"
+)
+
+// ToolChoice is the parsed form of the tool_choice parameter
+type ToolChoice struct {
+	Mode string
+	// Function is the forced function name when Mode is ToolChoiceFunction
+	Function string
+}
+
+// ParseToolChoice parses tool_choice: "none", "auto", "required" or {"type":"function","function":{"name":...}}
+// Without tool_choice the default is "auto" when tools are given and "none" otherwise
+func (r *ChatCompletionRequest) ParseToolChoice() (ToolChoice, *Error) {
+	switch v := r.ToolChoice.(type) {
+	case nil:
+		if len(r.Tools) > 0 {
+			return ToolChoice{Mode: ToolChoiceAuto}, nil
+		}
+		return ToolChoice{Mode: ToolChoiceNone}, nil
+	case string:
+		switch v {
+		case ToolChoiceNone, ToolChoiceAuto:
+			return ToolChoice{Mode: v}, nil
+		case ToolChoiceRequired:
+			if len(r.Tools) == 0 {
+				return ToolChoice{}, NewInvalidRequestError("tool_choice \"required\" is only allowed when tools are specified").WithParam("tool_choice")
+			}
+			return ToolChoice{Mode: v}, nil
+		}
+	case map[string]interface{}:
+		function, _ := v["function"].(map[string]interface{})
+		name, _ := function["name"].(string)
+		if v["type"] != "function" || name == "" {
+			break
+		}
+		for _, tool := range r.Tools {
+			if tool.Function.Name == name {
+				return ToolChoice{Mode: ToolChoiceFunction, Function: name}, nil
+			}
+		}
+		return ToolChoice{}, NewInvalidRequestError(fmt.Sprintf("tool_choice names function %q, which is not in tools", name)).WithParam("tool_choice")
+	}
+	return ToolChoice{}, NewInvalidRequestError(`tool_choice must be "none", "auto", "required" or {"type": "function", "function": {"name": ...}}`).WithParam("tool_choice")
+}
+
+// Check reports whether the tool calls of a response honor the tool choice
+// It returns a description of the violation, or "" when the response is acceptable
+func (t ToolChoice) Check(calls []ToolCall) string {
+	switch t.Mode {
+	case ToolChoiceRequired:
+		if len(calls) == 0 {
+			return "the model did not call a tool although tool_choice is \"required\""
+		}
+	case ToolChoiceFunction:
+		if len(calls) == 0 {
+			return fmt.Sprintf("the model did not call function %q required by tool_choice", t.Function)
+		}
+		for _, call := range calls {
+			if call.Function.Name != t.Function {
+				return fmt.Sprintf("the model called function %q although tool_choice requires %q", call.Function.Name, t.Function)
+			}
+		}
+	}
+	return ""
+}
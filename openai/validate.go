@@ -0,0 +1,74 @@
+package openai
+
+import "fmt"
+
+// Parameter ranges accepted by the OpenAI chat completions API
+const (
+	MaxTemperature = 2
+	MaxPenalty     = 2
+	MaxChoices     = 128
+	MaxStops       = 4
+)
+
+// Validate rejects sampling parameters outside the ranges of the OpenAI API
+// so invalid values are reported clearly instead of being forwarded to backends that reject them cryptically
+func (r *ChatCompletionRequest) Validate() *Error {
+	switch {
+	case r.Temperature < 0 || r.Temperature > MaxTemperature:
+		return outOfRange("temperature", r.Temperature, 0, MaxTemperature)
+	case r.TopP < 0 || r.TopP > 1:
+		return outOfRange("top_p", r.TopP, 0, 1)
+	case r.Frequency < -MaxPenalty || r.Frequency > MaxPenalty:
+		return outOfRange("frequency_penalty", r.Frequency, -MaxPenalty, MaxPenalty)
+	case r.Presence < -MaxPenalty || r.Presence > MaxPenalty:
+		return outOfRange("presence_penalty", r.Presence, -MaxPenalty, MaxPenalty)
+	case r.MaxTokens < 0:
+		return NewInvalidRequestError(fmt.Sprintf("max_tokens must be a positive integer, got %d", r.MaxTokens)).WithParam("max_tokens")
+	case r.N < 0 || r.N > MaxChoices:
+		return NewInvalidRequestError(fmt.Sprintf("n must be between 1 and %d, got %d", MaxChoices, r.N)).WithParam("n")
+	case len(r.Stop) > MaxStops:
+		return NewInvalidRequestError(fmt.Sprintf("stop accepts at most %d sequences, got %d", MaxStops, len(r.Stop))).WithParam("stop")
+	}
+	if err := ValidateMessages(r.Messages); err != nil {
+		return err
+	}
+	if _, err := r.ParseToolChoice(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// messageRoles are the roles a conversation may contain
+var messageRoles = map[string]bool{"system": true, "developer": true, "user": true, "assistant": true, "tool": true, "function": true}
+
+// ValidateMessages checks the structure of a conversation before it reaches the workers:
+// known roles, tool results linked to their call and well-formed content parts
+func ValidateMessages(messages []Message) *Error {
+	for i, m := range messages {
+		param := fmt.Sprintf("messages[%d]", i)
+		switch {
+		case !messageRoles[m.Role]:
+			return NewInvalidRequestError(fmt.Sprintf("%s.role must be one of system, developer, user, assistant, tool or function, got %q", param, m.Role)).WithParam(param + ".role")
+		case m.Role == "tool" && m.ToolCallID == "":
+			return NewInvalidRequestError(fmt.Sprintf("%s.tool_call_id is required for tool messages", param)).WithParam(param + ".tool_call_id")
+		case len(m.ToolCalls) > 0 && m.Role != "assistant":
+			return NewInvalidRequestError(fmt.Sprintf("%s.tool_calls is only allowed on assistant messages", param)).WithParam(param + ".tool_calls")
+		}
+		for j, part := range m.Parts {
+			partParam := fmt.Sprintf("%s.content[%d]", param, j)
+			switch {
+			case part.Type == "text":
+			case part.Type == "image_url" && part.ImageURL != nil && part.ImageURL.URL != "":
+			case part.Type == "image_url":
+				return NewInvalidRequestError(fmt.Sprintf("%s.image_url.url is required", partParam)).WithParam(partParam + ".image_url")
+			default:
+				return NewInvalidRequestError(fmt.Sprintf("%s.type must be text or image_url, got %q", partParam, part.Type)).WithParam(partParam + ".type")
+			}
+		}
+	}
+	return nil
+}
+
+func outOfRange(param string, value float32, min, max float32) *Error {
+	return NewInvalidRequestError(fmt.Sprintf("%s must be between %g and %g, got %g", param, min, max, value)).WithParam(param)
+}
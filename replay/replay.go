@@ -0,0 +1,184 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config controls how recorded traffic is sent back to a gateway
+type Config struct {
+	// GatewayURL is the base URL of the gateway under test
+	GatewayURL string
+	// Concurrency caps the number of requests in flight (default 8)
+	Concurrency int
+	// Speed scales the recorded inter-arrival times: 1 replays in real time, 2 twice as fast,
+	// 0 sends every request as soon as a slot is free
+	Speed float64
+	// Keys maps hashed trace keys to the API keys used for replay; DefaultKey covers the rest
+	Keys       map[string]string
+	DefaultKey string
+	HTTPClient *http.Client
+}
+
+// Result summarizes a replay run
+type Result struct {
+	Requests int `json:"requests"`
+	// Failed counts transport errors and non-2xx responses
+	Failed   int           `json:"failed"`
+	Statuses map[int]int   `json:"statuses"`
+	Duration time.Duration `json:"duration"`
+	P50      time.Duration `json:"p50"`
+	P95      time.Duration `json:"p95"`
+	P99      time.Duration `json:"p99"`
+}
+
+// String formats the result as a short report
+func (r Result) String() string {
+	codes := make([]int, 0, len(r.Statuses))
+	for code := range r.Statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	var statuses []string
+	for _, code := range codes {
+		statuses = append(statuses, fmt.Sprintf("%d=%d", code, r.Statuses[code]))
+	}
+	return fmt.Sprintf("requests=%d failed=%d duration=%s p50=%s p95=%s p99=%s statuses=[%s]",
+		r.Requests, r.Failed, r.Duration.Round(time.Millisecond), r.P50, r.P95, r.P99, strings.Join(statuses, " "))
+}
+
+// Run replays traces against the gateway and waits for every response to be read to the end
+func Run(ctx context.Context, cfg Config, traces []Trace) Result {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 8
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{}
+	}
+	url := strings.TrimRight(cfg.GatewayURL, "/") + "/v1/chat/completions"
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		latencies []time.Duration
+	)
+	result := Result{Statuses: make(map[int]int)}
+	slots := make(chan struct{}, cfg.Concurrency)
+	start := time.Now()
+
+	for i, trace := range traces {
+		// 按录制时的到达间隔（缩放后）发送
+		if cfg.Speed > 0 && i > 0 {
+			offset := time.Duration(float64(trace.Time.Sub(traces[0].Time)) / cfg.Speed)
+			if wait := time.Until(start.Add(offset)); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+				}
+			}
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(trace Trace) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			sent := time.Now()
+			status, err := send(ctx, client, url, cfg.keyFor(trace.Key), trace)
+			elapsed := time.Since(sent)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Requests++
+			if err != nil || status < 200 || status >= 300 {
+				result.Failed++
+			}
+			if status != 0 {
+				result.Statuses[status]++
+			}
+			latencies = append(latencies, elapsed)
+		}(trace)
+	}
+	wg.Wait()
+
+	result.Duration = time.Since(start)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.P50 = percentile(latencies, 0.50)
+	result.P95 = percentile(latencies, 0.95)
+	result.P99 = percentile(latencies, 0.99)
+	return result
+}
+
+// keyFor returns the replay API key for a hashed trace key
+func (cfg Config) keyFor(hashed string) string {
+	if key, ok := cfg.Keys[hashed]; ok {
+		return key
+	}
+	return cfg.DefaultKey
+}
+
+// send posts one trace and drains the response so streams are replayed in full
+func send(ctx context.Context, client *http.Client, url, apiKey string, trace Trace) (int, error) {
+	body, err := json.Marshal(trace.Request)
+	if err != nil {
+		return 0, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, err
+}
+
+// percentile returns the q-quantile of sorted latencies
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(q * float64(len(sorted)-1))
+	return sorted[idx]
+}
+
+// ParseKeyMap parses "hash=key,hash2=key2" into a replay key map
+func ParseKeyMap(spec string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		hash, key, ok := strings.Cut(entry, "=")
+		if !ok || hash == "" || key == "" {
+			return nil, fmt.Errorf("invalid key mapping %q: expected hash=key", entry)
+		}
+		keys[strings.TrimSpace(hash)] = strings.TrimSpace(key)
+	}
+	return keys, nil
+}
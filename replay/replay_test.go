@@ -0,0 +1,95 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"zam/metrics"
+	"zam/openai"
+)
+
+func TestAnonymize(t *testing.T) {
+	req := openai.ChatCompletionRequest{
+		Model:     "llama-8b",
+		MaxTokens: 64,
+		Messages: []openai.Message{
+			{Role: "system", Content: "You are Bob's assistant"},
+			{Role: "user", Content: "see image", Parts: []openai.ContentPart{
+				{Type: "text", Text: "see image"},
+				{Type: "image_url", ImageURL: &openai.ImageURL{URL: "https://private.example/scan.png"}},
+			}},
+		},
+	}
+
+	out := Anonymize(req)
+	if out.Messages[0].Content != "xxx xxx xxxxx xxxxxxxxx" {
+		t.Errorf("expected length-preserving filler, got %q", out.Messages[0].Content)
+	}
+	if out.Messages[1].Parts[1].ImageURL.URL != placeholderImage {
+		t.Errorf("expected image URL to be replaced, got %s", out.Messages[1].Parts[1].ImageURL.URL)
+	}
+	if req.Messages[0].Content != "You are Bob's assistant" || req.Messages[1].Parts[0].Text != "see image" {
+		t.Error("Anonymize must not modify the original request")
+	}
+	if out.Model != "llama-8b" || out.MaxTokens != 64 || out.Messages[1].Role != "user" {
+		t.Error("expected model, parameters and roles to be kept")
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	rec, err := NewRecorder(path)
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	for _, key := range []string{"sk-alice", "sk-bob", "sk-alice"} {
+		rec.Record(key, openai.ChatCompletionRequest{Model: "gemma-2b", Messages: []openai.Message{{Role: "user", Content: "secret"}}})
+	}
+	rec.Close()
+
+	traces, err := ReadTraces(path)
+	if err != nil || len(traces) != 3 {
+		t.Fatalf("expected 3 traces, got %d, %v", len(traces), err)
+	}
+	if traces[0].Key != metrics.HashKey("sk-alice") {
+		t.Errorf("expected hashed key, got %s", traces[0].Key)
+	}
+
+	var seen atomic.Int64
+	var bobRequests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen.Add(1)
+		var req openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if strings.Contains(req.Messages[0].Content, "secret") {
+			t.Error("replayed request leaked recorded content")
+		}
+		if r.Header.Get("Authorization") == "Bearer sk-replay-bob" {
+			bobRequests.Add(1)
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer srv.Close()
+
+	result := Run(context.Background(), Config{
+		GatewayURL:  srv.URL,
+		Concurrency: 2,
+		Keys:        map[string]string{metrics.HashKey("sk-bob"): "sk-replay-bob"},
+		DefaultKey:  "sk-replay",
+	}, traces)
+
+	if result.Requests != 3 || seen.Load() != 3 {
+		t.Fatalf("expected 3 replayed requests, got %d (server saw %d)", result.Requests, seen.Load())
+	}
+	if result.Failed != 1 || result.Statuses[http.StatusTooManyRequests] != 1 || bobRequests.Load() != 1 {
+		t.Errorf("expected bob's request to be throttled once, got %+v", result)
+	}
+}
@@ -0,0 +1,133 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"zam/metrics"
+	"zam/openai"
+)
+
+// placeholderImage is a 1x1 transparent PNG that stands in for recorded images
+const placeholderImage = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII="
+
+// Trace is one anonymized chat completion request as recorded by the gateway
+type Trace struct {
+	Time time.Time `json:"time"`
+	// Key is the hashed API key, so per-key limits can be reproduced without the secret
+	Key     string                       `json:"key"`
+	Request openai.ChatCompletionRequest `json:"request"`
+}
+
+// Anonymize returns a copy of req with every piece of free text replaced by filler of the same length
+// Roles, parameters, tool names and schemas are kept so routing and token estimates stay realistic
+func Anonymize(req openai.ChatCompletionRequest) openai.ChatCompletionRequest {
+	out := req
+	out.Messages = make([]openai.Message, len(req.Messages))
+	for i, m := range req.Messages {
+		m.Content = filler(m.Content)
+		m.ReasoningContent = filler(m.ReasoningContent)
+		if len(m.Parts) > 0 {
+			parts := make([]openai.ContentPart, len(m.Parts))
+			for j, part := range m.Parts {
+				part.Text = filler(part.Text)
+				if part.ImageURL != nil {
+					part.ImageURL = &openai.ImageURL{URL: placeholderImage, Detail: part.ImageURL.Detail}
+				}
+				parts[j] = part
+			}
+			m.Parts = parts
+		}
+		out.Messages[i] = m
+	}
+	if len(req.Tools) > 0 {
+		out.Tools = make([]openai.Tool, len(req.Tools))
+		for i, tool := range req.Tools {
+			tool.Function.Description = filler(tool.Function.Description)
+			out.Tools[i] = tool
+		}
+	}
+	return out
+}
+
+// filler replaces every word of s with "x"s of the same length, keeping whitespace
+func filler(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\n', '\t':
+			return r
+		}
+		return 'x'
+	}, s)
+}
+
+// Recorder appends anonymized traces to a JSON-lines file
+type Recorder struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewRecorder opens (or creates) the trace file at path in append mode
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Record anonymizes and appends one request; a nil Recorder records nothing
+func (r *Recorder) Record(apiKey string, req openai.ChatCompletionRequest) error {
+	if r == nil {
+		return nil
+	}
+	trace := Trace{
+		Time:    time.Now(),
+		Key:     metrics.HashKey(apiKey),
+		Request: Anonymize(req),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enc.Encode(trace)
+}
+
+// Close closes the trace file
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// ReadTraces loads every trace from a file written by Recorder, in recording order
+func ReadTraces(path string) ([]Trace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var traces []Trace
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var trace Trace
+		if err := json.Unmarshal(scanner.Bytes(), &trace); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		traces = append(traces, trace)
+	}
+	return traces, scanner.Err()
+}
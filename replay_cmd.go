@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"zam/replay"
+)
+
+// runReplay implements `zam replay [flags] traces.jsonl`, replaying recorded traffic against a gateway
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	gateway := fs.String("gateway", envOr("ZAM_GATEWAY_URL", "http://127.0.0.1:8080"), "gateway base URL")
+	key := fs.String("key", os.Getenv("ZAM_REPLAY_KEY"), "API key for traces without a -keys mapping")
+	keys := fs.String("keys", "", "per-key mapping of recorded key hashes to API keys, e.g. 3f2a9c1b0d4e=sk-a,...")
+	concurrency := fs.Int("concurrency", 8, "maximum requests in flight")
+	speed := fs.Float64("speed", 1, "replay speed relative to the recording (0 = as fast as possible)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: zam replay [flags] traces.jsonl")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	traces, err := replay.ReadTraces(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to read traces: %v", err)
+	}
+	keyMap, err := replay.ParseKeyMap(*keys)
+	if err != nil {
+		log.Fatalf("Invalid -keys: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	log.Printf("Replaying %d requests against %s (concurrency %d, speed %gx)", len(traces), *gateway, *concurrency, *speed)
+	result := replay.Run(ctx, replay.Config{
+		GatewayURL:  *gateway,
+		Concurrency: *concurrency,
+		Speed:       *speed,
+		Keys:        keyMap,
+		DefaultKey:  *key,
+	}, traces)
+	fmt.Println(result)
+}
@@ -0,0 +1,58 @@
+package router
+
+import "zam/core"
+
+// TenantCounter reports how many requests a tenant currently has in flight on a worker
+type TenantCounter interface {
+	Count(workerID, tenant string) int
+}
+
+// SetTenantAntiAffinity enables spreading a tenant's concurrent requests across distinct workers
+// Passing nil disables anti-affinity
+func (r *ScoreRouter) SetTenantAntiAffinity(counter TenantCounter) {
+	r.tenants = counter
+}
+
+// spreadTenant keeps only the candidates where the tenant has the fewest in-flight requests,
+// so a burst from one tenant fans out before stacking on a single GPU
+func (r *ScoreRouter) spreadTenant(candidates []workerScore, tenant string) []workerScore {
+	if r.tenants == nil || tenant == "" || len(candidates) < 2 {
+		return candidates
+	}
+
+	minCount := -1
+	counts := make([]int, len(candidates))
+	for i, c := range candidates {
+		counts[i] = r.tenants.Count(c.worker.ID(), tenant)
+		if minCount < 0 || counts[i] < minCount {
+			minCount = counts[i]
+		}
+	}
+
+	spread := make([]workerScore, 0, len(candidates))
+	for i, c := range candidates {
+		if counts[i] == minCount {
+			spread = append(spread, c)
+		}
+	}
+	return spread
+}
+
+// SetSessionAffinity routes the turns of a conversation to the worker that served its previous turns,
+// so backends with prefix caching skip most of the prefill. Passing nil disables session affinity
+func (r *ScoreRouter) SetSessionAffinity(sessions *core.SessionAffinity) {
+	r.sessions = sessions
+}
+
+// findCandidate returns the candidate with the given worker ID
+func findCandidate(candidates []workerScore, workerID string) (workerScore, bool) {
+	if workerID == "" {
+		return workerScore{}, false
+	}
+	for _, c := range candidates {
+		if c.worker.ID() == workerID {
+			return c, true
+		}
+	}
+	return workerScore{}, false
+}
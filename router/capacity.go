@@ -0,0 +1,70 @@
+package router
+
+import (
+	"strings"
+
+	"zam/core"
+)
+
+// MaxCapacityWeight caps the advertised weight, matching the 0-256 range of HAProxy/Envoy weights
+const MaxCapacityWeight = 256
+
+// GatewayCapacity is the healthy local capacity a gateway advertises to an upstream load balancer
+type GatewayCapacity struct {
+	GatewayID string `json:"gateway_id"`
+	// Healthy is false when no local worker can take a request, so the balancer should drain the gateway
+	Healthy bool `json:"healthy"`
+	// Workers counts the local workers whose breaker is not open
+	Workers     int `json:"workers"`
+	MaxTasks    int `json:"max_tasks"`
+	ActiveTasks int `json:"active_tasks"`
+	FreeSlots   int `json:"free_slots"`
+	// Weight is the suggested balancer weight: free slots capped at MaxCapacityWeight, 0 under brownout
+	Weight int `json:"weight"`
+	// Models maps each served model to its free slots across local workers
+	Models   map[string]int `json:"models"`
+	Brownout bool           `json:"brownout,omitempty"`
+}
+
+// LocalCapacity summarizes the free slots of local workers; peers, fallbacks and workers for which
+// available returns false (e.g. quarantined) are excluded since a balancer cannot rely on them
+func LocalCapacity(id string, profiles []core.WorkerProfile, available func(workerID string) bool, brownout bool) GatewayCapacity {
+	capacity := GatewayCapacity{GatewayID: id, Models: make(map[string]int), Brownout: brownout}
+	for _, p := range profiles {
+		if p.Peer || isFallbackWorker(p.WorkerID, p) || (available != nil && !available(p.WorkerID)) {
+			continue
+		}
+		capacity.Workers++
+		capacity.MaxTasks += p.MaxTasks
+		capacity.ActiveTasks += p.ActiveTasks
+
+		free := p.MaxTasks - p.ActiveTasks
+		if free < 0 {
+			free = 0
+		}
+		capacity.FreeSlots += free
+		for _, model := range p.Supported {
+			modelFree := free
+			// 按模型槽位进一步限制
+			if slots, ok := lookupModel(p.ModelSlots, model); ok {
+				active, _ := lookupModel(p.ActiveByModel, model)
+				if slots-active < modelFree {
+					modelFree = slots - active
+				}
+			}
+			if modelFree < 0 {
+				modelFree = 0
+			}
+			capacity.Models[strings.ToLower(model)] += modelFree
+		}
+	}
+
+	capacity.Healthy = capacity.Workers > 0
+	if !brownout {
+		capacity.Weight = capacity.FreeSlots
+		if capacity.Weight > MaxCapacityWeight {
+			capacity.Weight = MaxCapacityWeight
+		}
+	}
+	return capacity
+}
@@ -0,0 +1,131 @@
+package router
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Config tunes the filters and scoring of a ScoreRouter at startup
+// Weights can still be changed at runtime through SetWeights
+type Config struct {
+	Weights Weights `json:"weights"`
+	// VRAMHeadroom is the VRAM in bytes a worker must keep free on top of the model and KV-cache estimate
+	VRAMHeadroom uint64 `json:"vram_headroom"`
+	// VRAMHeadroomRatio adds a fraction of the estimate as safety margin, e.g. 0.1 for 10%
+	VRAMHeadroomRatio float64 `json:"vram_headroom_ratio"`
+	// KVCacheSaturation is the reported KV-cache usage (0-1) at which a worker stops admitting requests
+	KVCacheSaturation float64 `json:"kv_cache_saturation"`
+	// MaxLoad is the fraction of MaxTasks at which a worker counts as at capacity (0-1)
+	MaxLoad float64 `json:"max_load"`
+	// DegradedPenalty is the fraction of its score a worker with late heartbeats loses (0-1)
+	DegradedPenalty float64 `json:"degraded_penalty"`
+	// PriorityReserve is the fraction of each worker's slots (rounded up) only high-priority requests may take (0-1)
+	PriorityReserve float64 `json:"priority_reserve"`
+}
+
+// DefaultConfig returns the configuration used by NewScoreRouter
+func DefaultConfig() Config {
+	return Config{
+		Weights:           DefaultWeights(),
+		KVCacheSaturation: 0.95,
+		MaxLoad:           1,
+		DegradedPenalty:   0.5,
+	}
+}
+
+// Validate checks the weights and that every threshold is within its range
+func (c Config) Validate() error {
+	if err := c.Weights.Validate(); err != nil {
+		return err
+	}
+	switch {
+	case !(c.VRAMHeadroomRatio >= 0 && c.VRAMHeadroomRatio <= 1):
+		return fmt.Errorf("vram_headroom_ratio must be between 0 and 1")
+	case !(c.KVCacheSaturation > 0 && c.KVCacheSaturation <= 1):
+		return fmt.Errorf("kv_cache_saturation must be greater than 0 and at most 1")
+	case !(c.MaxLoad > 0 && c.MaxLoad <= 1):
+		return fmt.Errorf("max_load must be greater than 0 and at most 1")
+	case !(c.DegradedPenalty >= 0 && c.DegradedPenalty <= 1):
+		return fmt.Errorf("degraded_penalty must be between 0 and 1")
+	case !(c.PriorityReserve >= 0 && c.PriorityReserve < 1):
+		return fmt.Errorf("priority_reserve must be at least 0 and less than 1")
+	}
+	return nil
+}
+
+// withHeadroom adds the configured safety margin to a VRAM estimate
+func (c Config) withHeadroom(vram uint64) uint64 {
+	return vram + uint64(float64(vram)*c.VRAMHeadroomRatio) + c.VRAMHeadroom
+}
+
+// atCapacity reports whether a worker has reached the configured share of its task slots
+func (c Config) atCapacity(activeTasks, maxTasks int) bool {
+	return float64(activeTasks) >= c.MaxLoad*float64(maxTasks)
+}
+
+// inReserve reports whether a worker's free slots are all within the share reserved for high-priority requests
+func (c Config) inReserve(activeTasks, maxTasks int) bool {
+	if c.PriorityReserve == 0 {
+		return false
+	}
+	limit := c.MaxLoad * float64(maxTasks)
+	return float64(activeTasks) >= limit-math.Ceil(c.PriorityReserve*limit)
+}
+
+// ParseConfig parses "key=value,..." on top of DefaultConfig, e.g.
+// "vram=1,load=2,vram_headroom_gb=1.5,vram_headroom_ratio=0.1,kv_cache_saturation=0.9,max_load=0.8,degraded_penalty=0.3,priority_reserve=0.2"
+// Weight keys are vram, load, adapter, latency and cost
+func ParseConfig(spec string) (Config, error) {
+	return ParseConfigOn(DefaultConfig(), spec)
+}
+
+// ParseConfigOn parses the same "key=value,..." form on top of base, e.g. a config file's router section
+func ParseConfigOn(base Config, spec string) (Config, error) {
+	c := base
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return Config{}, fmt.Errorf("invalid router option %q: expected key=value", field)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return Config{}, fmt.Errorf("invalid router option %q: value must be a number", field)
+		}
+		switch strings.TrimSpace(key) {
+		case "vram":
+			c.Weights.VRAM = v
+		case "load":
+			c.Weights.Load = v
+		case "adapter":
+			c.Weights.Adapter = v
+		case "latency":
+			c.Weights.Latency = v
+		case "cost":
+			c.Weights.Cost = v
+		case "vram_headroom_gb":
+			if v < 0 {
+				return Config{}, fmt.Errorf("vram_headroom_gb must not be negative")
+			}
+			c.VRAMHeadroom = uint64(v * 1024 * 1024 * 1024)
+		case "vram_headroom_ratio":
+			c.VRAMHeadroomRatio = v
+		case "kv_cache_saturation":
+			c.KVCacheSaturation = v
+		case "max_load":
+			c.MaxLoad = v
+		case "degraded_penalty":
+			c.DegradedPenalty = v
+		case "priority_reserve":
+			c.PriorityReserve = v
+		default:
+			return Config{}, fmt.Errorf("unknown router option %q", key)
+		}
+	}
+	return c, c.Validate()
+}
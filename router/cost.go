@@ -0,0 +1,21 @@
+package router
+
+// scoreCost sets the cost score of the candidates from their WorkerProfile.CostPer1KTokens,
+// 100 for the cheapest and proportionally less for pricier ones
+// Unpriced workers cost nothing and score 100; when one is among the candidates priced workers score 0
+func scoreCost(candidates []workerScore) {
+	cheapest := -1.0
+	for _, c := range candidates {
+		if cost := c.profile.CostPer1KTokens; cheapest < 0 || cost < cheapest {
+			cheapest = cost
+		}
+	}
+	for i, c := range candidates {
+		cost := c.profile.CostPer1KTokens
+		if cost <= 0 {
+			candidates[i].costScore = 100
+			continue
+		}
+		candidates[i].costScore = cheapest / cost * 100
+	}
+}
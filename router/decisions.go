@@ -0,0 +1,51 @@
+package router
+
+import (
+	"zam/core"
+)
+
+// Routing outcomes reported to a DecisionObserver
+const (
+	OutcomeLocal     = "local"
+	OutcomePeer      = "peer"
+	OutcomeFallback  = "fallback"
+	OutcomeNoWorkers = "no_workers"
+)
+
+// DecisionObserver receives the outcome of every routing decision and the reasons
+// workers were filtered out, keyed by worker ID
+type DecisionObserver interface {
+	ObserveRouting(outcome string, excluded map[string]string)
+}
+
+// SetDecisionObserver reports routing decisions for capacity planning
+// Previews are not reported
+func (r *ScoreRouter) SetDecisionObserver(observer DecisionObserver) {
+	r.observer = observer
+}
+
+// observeDecision classifies a finished selection and reports it
+// Exclusions are recomputed for the model actually routed, like previews do
+func (r *ScoreRouter) observeDecision(probed []probedWorker, req *core.InferenceRequest, selected core.Worker, err error) {
+	if r.observer == nil {
+		return
+	}
+
+	outcome := OutcomeLocal
+	switch {
+	case err != nil:
+		outcome = OutcomeNoWorkers
+	case req.Fallback:
+		outcome = OutcomeFallback
+	default:
+		for _, p := range probed {
+			if p.worker.ID() == selected.ID() && p.profile.Peer {
+				outcome = OutcomePeer
+				break
+			}
+		}
+	}
+
+	pool := r.collectCandidates(probed, []string{req.Model}, r.requiredVRAM(req.Model, req.PromptTokens), req.Adapter, req.Needs, req.Priority)
+	r.observer.ObserveRouting(outcome, pool.excluded)
+}
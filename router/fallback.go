@@ -0,0 +1,47 @@
+package router
+
+import (
+	"sort"
+
+	"zam/core"
+)
+
+// BreakerSource reports the circuit breaker state of a worker
+type BreakerSource interface {
+	State(workerID string) core.BreakerState
+}
+
+// SetBreakerSource skips fallback workers whose circuit breaker is open, so overflow goes to the
+// next fallback in priority order
+func (r *ScoreRouter) SetBreakerSource(breakers BreakerSource) {
+	r.breakers = breakers
+}
+
+// fallbackExclusion returns why a fallback worker cannot take the request, empty when it can
+// Fallback workers reporting no MaxTasks are not capacity-limited
+func (r *ScoreRouter) fallbackExclusion(workerID string, profile core.WorkerProfile, models []string) string {
+	switch {
+	case r.breakers != nil && r.breakers.State(workerID) == core.BreakerOpen:
+		return ReasonQuarantined
+	case !supportsAll(models, profile.Supported):
+		return ReasonModelUnsupported
+	case profile.MaxTasks > 0 && r.config.Load().atCapacity(profile.ActiveTasks, profile.MaxTasks):
+		return ReasonAtCapacity
+	}
+	return ""
+}
+
+// sortFallbacks orders fallback workers by profile priority, highest first; ties keep registry order
+func sortFallbacks(fallbacks []probedWorker) {
+	sort.SliceStable(fallbacks, func(i, j int) bool {
+		return fallbacks[i].profile.Priority > fallbacks[j].profile.Priority
+	})
+}
+
+// fallback returns the first fallback worker to overflow to, nil when there is none
+func (p candidatePool) fallback() core.Worker {
+	if len(p.fallbacks) == 0 {
+		return nil
+	}
+	return p.fallbacks[0].worker
+}
@@ -0,0 +1,54 @@
+package router
+
+import (
+	"strings"
+
+	"zam/core"
+)
+
+// AggregateProfile summarizes the local (non-peer, non-fallback) workers into a single profile
+// that peer gateways use to route overflow to this gateway
+func AggregateProfile(id string, profiles []core.WorkerProfile) core.WorkerProfile {
+	aggregate := core.WorkerProfile{WorkerID: id}
+	seen := make(map[string]bool)
+	unboundedContext := false
+
+	for _, p := range profiles {
+		if p.Peer || isFallbackWorker(p.WorkerID, p) {
+			continue
+		}
+
+		for _, model := range p.Supported {
+			key := strings.ToLower(model)
+			if !seen[key] {
+				seen[key] = true
+				aggregate.Supported = append(aggregate.Supported, model)
+			}
+		}
+
+		// VRAM 取单节点最大余量：请求最终只会落在一个节点上
+		if p.AvailableVRAM > aggregate.AvailableVRAM {
+			aggregate.AvailableVRAM = p.AvailableVRAM
+			aggregate.TotalVRAM = p.TotalVRAM
+		}
+		aggregate.ActiveTasks += p.ActiveTasks
+		aggregate.MaxTasks += p.MaxTasks
+
+		// 能力取并集：只要有一个节点支持，请求就能在对端落地
+		caps := &aggregate.Capabilities
+		caps.Tools = caps.Tools || p.Capabilities.Tools
+		caps.Vision = caps.Vision || p.Capabilities.Vision
+		caps.JSONMode = caps.JSONMode || p.Capabilities.JSONMode
+		caps.Embeddings = caps.Embeddings || p.Capabilities.Embeddings
+		if p.Capabilities.MaxContext == 0 {
+			unboundedContext = true
+		} else if p.Capabilities.MaxContext > caps.MaxContext {
+			caps.MaxContext = p.Capabilities.MaxContext
+		}
+	}
+	if unboundedContext {
+		aggregate.Capabilities.MaxContext = 0
+	}
+
+	return aggregate
+}
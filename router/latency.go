@@ -0,0 +1,58 @@
+package router
+
+import (
+	"time"
+
+	"zam/core"
+)
+
+// LatencySource reports the moving-average latency observed on a worker
+type LatencySource interface {
+	Latency(workerID string) (core.WorkerLatency, bool)
+}
+
+// SetLatencySource enables the latency score: candidates are ranked against the fastest one
+// by their average time-to-first-token and completion latency
+func (r *ScoreRouter) SetLatencySource(latencies LatencySource) {
+	r.latencies = latencies
+}
+
+// scoreLatency sets the latency score of the candidates, 100 for the fastest and proportionally less
+// for slower ones; half comes from TTFT and half from completion latency
+// Workers without samples score 100 so new workers receive traffic and get measured
+func (r *ScoreRouter) scoreLatency(candidates []workerScore) {
+	if r.latencies == nil || len(candidates) == 0 {
+		return
+	}
+	observed := make([]core.WorkerLatency, len(candidates))
+	known := make([]bool, len(candidates))
+	var fastestTTFT, fastestCompletion time.Duration
+	for i, c := range candidates {
+		observed[i], known[i] = r.latencies.Latency(c.worker.ID())
+		if !known[i] {
+			continue
+		}
+		if fastestTTFT == 0 || observed[i].TTFT < fastestTTFT {
+			fastestTTFT = observed[i].TTFT
+		}
+		if fastestCompletion == 0 || observed[i].Completion < fastestCompletion {
+			fastestCompletion = observed[i].Completion
+		}
+	}
+	for i := range candidates {
+		if !known[i] {
+			candidates[i].latencyScore = 100
+			continue
+		}
+		candidates[i].latencyScore = latencyRatio(fastestTTFT, observed[i].TTFT)*50 +
+			latencyRatio(fastestCompletion, observed[i].Completion)*50
+	}
+}
+
+// latencyRatio returns fastest/d in [0, 1], 1 when d is not positive
+func latencyRatio(fastest, d time.Duration) float64 {
+	if d <= 0 || fastest <= 0 {
+		return 1
+	}
+	return float64(fastest) / float64(d)
+}
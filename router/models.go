@@ -0,0 +1,145 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// ModelProfile describes the resources one model needs on a worker
+// ScoreRouter consults it before falling back to guessing from the model name
+type ModelProfile struct {
+	// VRAMGB is the VRAM the loaded model occupies, weights and runtime overhead included
+	VRAMGB float64 `json:"vram_gb"`
+	// KVCacheKBPerToken is the KV-cache one token of context takes (0 = estimated from the name)
+	KVCacheKBPerToken float64 `json:"kv_cache_kb_per_token,omitempty"`
+	// ContextLength is the model's context window in tokens, applied to workers not reporting their own (0 = unknown)
+	ContextLength int `json:"context_length,omitempty"`
+	// Quantization is informational, e.g. "q4_k_m", "awq" or "fp16"
+	Quantization string `json:"quantization,omitempty"`
+}
+
+// Validate checks that the sizes are finite, not negative and that the VRAM requirement is set
+func (p ModelProfile) Validate() error {
+	switch {
+	case math.IsNaN(p.VRAMGB) || math.IsInf(p.VRAMGB, 0) || p.VRAMGB <= 0:
+		return fmt.Errorf("vram_gb must be a positive number")
+	case math.IsNaN(p.KVCacheKBPerToken) || math.IsInf(p.KVCacheKBPerToken, 0) || p.KVCacheKBPerToken < 0:
+		return fmt.Errorf("kv_cache_kb_per_token must not be negative")
+	case p.ContextLength < 0:
+		return fmt.Errorf("context_length must not be negative")
+	}
+	return nil
+}
+
+// ModelProfiles maps lower-cased model names to their profiles
+type ModelProfiles map[string]ModelProfile
+
+// LoadModelProfiles reads a JSON object of model name -> profile, e.g.
+// {"llama-3-70b-q4": {"vram_gb": 40, "kv_cache_kb_per_token": 320, "context_length": 8192, "quantization": "q4_k_m"}}
+func LoadModelProfiles(path string) (ModelProfiles, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]ModelProfile
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid model profiles %s: %w", path, err)
+	}
+	profiles := make(ModelProfiles, len(raw))
+	for model, profile := range raw {
+		if err := profile.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid model profile %q: %w", model, err)
+		}
+		profiles[strings.ToLower(model)] = profile
+	}
+	return profiles, nil
+}
+
+// SetModelProfiles replaces the model profiles consulted for VRAM and context requirements
+func (r *ScoreRouter) SetModelProfiles(profiles ModelProfiles) {
+	copied := make(ModelProfiles, len(profiles))
+	for model, profile := range profiles {
+		copied[strings.ToLower(model)] = profile
+	}
+	r.profiles.Store(&copied)
+}
+
+// ModelProfiles returns a copy of the configured model profiles
+func (r *ScoreRouter) ModelProfiles() ModelProfiles {
+	current := r.modelProfiles()
+	copied := make(ModelProfiles, len(current))
+	for model, profile := range current {
+		copied[model] = profile
+	}
+	return copied
+}
+
+// SetModelProfile validates and adds or replaces the profile of one model
+// In-flight Select calls keep using the profiles they started with
+func (r *ScoreRouter) SetModelProfile(model string, profile ModelProfile) error {
+	if strings.TrimSpace(model) == "" {
+		return fmt.Errorf("model name is required")
+	}
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+	profiles := r.ModelProfiles()
+	profiles[strings.ToLower(model)] = profile
+	r.profiles.Store(&profiles)
+	return nil
+}
+
+// DeleteModelProfile removes the profile of one model, which falls back to the name heuristic
+// It reports whether the model had a profile
+func (r *ScoreRouter) DeleteModelProfile(model string) bool {
+	profiles := r.ModelProfiles()
+	if _, ok := profiles[strings.ToLower(model)]; !ok {
+		return false
+	}
+	delete(profiles, strings.ToLower(model))
+	r.profiles.Store(&profiles)
+	return true
+}
+
+func (r *ScoreRouter) modelProfiles() ModelProfiles {
+	if p := r.profiles.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// modelVRAM returns the VRAM a model needs, from its profile or estimated from its name
+func (r *ScoreRouter) modelVRAM(model string) uint64 {
+	if p, ok := r.modelProfiles()[strings.ToLower(model)]; ok {
+		return uint64(p.VRAMGB * 1024 * 1024 * 1024)
+	}
+	return estimateModelVRAM(model)
+}
+
+// kvCacheVRAM returns the KV-cache VRAM promptTokens of context take on a model
+func (r *ScoreRouter) kvCacheVRAM(model string, promptTokens int) uint64 {
+	if p, ok := r.modelProfiles()[strings.ToLower(model)]; ok && p.KVCacheKBPerToken > 0 && promptTokens > 0 {
+		return uint64(float64(promptTokens) * p.KVCacheKBPerToken * 1024)
+	}
+	return estimateKVCacheVRAM(model, promptTokens)
+}
+
+// requiredVRAM returns the VRAM the requested model needs plus the KV-cache its prompt will occupy
+func (r *ScoreRouter) requiredVRAM(model string, promptTokens int) uint64 {
+	return r.modelVRAM(model) + r.kvCacheVRAM(model, promptTokens)
+}
+
+// modelContext returns the smallest context length configured for the given models (0 = unknown)
+func (r *ScoreRouter) modelContext(models []string) int {
+	profiles := r.modelProfiles()
+	context := 0
+	for _, model := range models {
+		if p, ok := profiles[strings.ToLower(model)]; ok && p.ContextLength > 0 && (context == 0 || p.ContextLength < context) {
+			context = p.ContextLength
+		}
+	}
+	return context
+}
@@ -0,0 +1,64 @@
+package router
+
+import (
+	"context"
+	"sort"
+
+	"zam/core"
+)
+
+// Preview runs the full filter/score pipeline without dispatching and explains the decision
+// The caller's request is left untouched
+func (r *ScoreRouter) Preview(ctx context.Context, workers []core.Worker, req *core.InferenceRequest) core.RouteDecision {
+	probed := r.probeWorkers(ctx, workers)
+
+	preview := *req
+	decision := core.RouteDecision{}
+	selected, err := r.selectProbed(probed, &preview, r.sessions.Worker(ctx, req.Session))
+	if err != nil {
+		decision.Error = err.Error()
+	} else {
+		decision.Selected = selected.ID()
+	}
+	decision.Model = preview.Model
+	decision.LoadAdapter = preview.LoadAdapter
+	decision.Speculative = preview.Speculative
+
+	// Rank the candidates for the model actually routed
+	weights := r.Weights()
+	pool := r.collectCandidates(probed, []string{preview.Model}, r.requiredVRAM(preview.Model, preview.PromptTokens), preview.Adapter, preview.Needs, preview.Priority)
+	for _, c := range pool.candidates {
+		decision.Candidates = append(decision.Candidates, core.RouteCandidate{
+			WorkerID: c.worker.ID(),
+			Score:    c.total(weights),
+		})
+	}
+	sort.SliceStable(decision.Candidates, func(i, j int) bool {
+		return decision.Candidates[i].Score > decision.Candidates[j].Score
+	})
+
+	for _, p := range pool.peers {
+		candidate := core.RouteCandidate{WorkerID: p.worker.ID(), Score: p.total(weights)}
+		if preview.Federated {
+			candidate.Score = 0
+			candidate.Excluded = ReasonFederationLoop
+		}
+		decision.Candidates = append(decision.Candidates, candidate)
+	}
+	for _, f := range pool.fallbacks {
+		decision.Candidates = append(decision.Candidates, core.RouteCandidate{
+			WorkerID: f.worker.ID(),
+			Fallback: true,
+		})
+	}
+	for _, p := range probed {
+		if reason, ok := pool.excluded[p.worker.ID()]; ok {
+			decision.Candidates = append(decision.Candidates, core.RouteCandidate{
+				WorkerID: p.worker.ID(),
+				Excluded: reason,
+			})
+		}
+	}
+
+	return decision
+}
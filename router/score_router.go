@@ -4,94 +4,355 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync/atomic"
 
 	"zam/core"
 )
 
 // ScoreRouter implements core.Router with dynamic scoring based routing
 type ScoreRouter struct {
-	// vramWeight defines the weight for VRAM in scoring (higher = more important)
-	vramWeight float64
-	// loadWeight defines the weight for active tasks in scoring (higher = more important)
-	loadWeight float64
+	// weights holds the current scoring weights, swapped atomically at runtime
+	weights atomic.Pointer[Weights]
+	// config holds the VRAM headroom and filter thresholds, swapped atomically at runtime
+	// Its Weights field is unused: the current weights live in weights
+	config atomic.Pointer[Config]
+	// profiles holds the per-model VRAM and context requirements, swapped atomically at runtime
+	profiles atomic.Pointer[ModelProfiles]
+	// pairs maps client-facing model names to speculative decoding pairs (keys are lower-cased)
+	pairs map[string]SpeculativePair
+	// tenants enables per-tenant anti-affinity when non-nil
+	tenants TenantCounter
+	// sessions binds conversations to the worker holding their KV-cache prefix when non-nil
+	sessions *core.SessionAffinity
+	// models adds gateway-side in-flight counts to per-model slot checks when non-nil
+	models ModelCounter
+	// locality is the gateway's own region/zone for topology-aware routing
+	locality Locality
+	// states reports stale-but-usable workers, which are scored with config.DegradedPenalty
+	states core.WorkerStateSource
+	// observer receives routing outcomes and exclusion reasons when non-nil
+	observer DecisionObserver
+	// ramp throttles workers that are slow-starting after rejoining when non-nil
+	ramp RampSource
+	// snapshots serves cached worker profiles; when nil, workers are probed with Heartbeat per request
+	snapshots core.ProfileSource
+	// breakers skips fallback workers with an open circuit breaker when non-nil
+	breakers BreakerSource
+	// budget stops overflow to the fallback once its spend cap is used up when non-nil
+	budget *core.FallbackBudget
+	// latencies feeds the latency score when non-nil
+	latencies LatencySource
+	// load adds gateway-side in-flight counts to the cached load of each worker when non-nil
+	load WorkerCounter
+	// strategies holds the registered placement strategies and strategy names the default one
+	strategies map[string]Strategy
+	strategy   atomic.Pointer[string]
+	// random is the slow-start coin flip, replaceable in tests (defaults to rand.Float64)
+	random func() float64
 }
 
-// NewScoreRouter creates a new ScoreRouter with default weights
+// SetFallbackBudget stops routing to fallback workers once their spend cap is used up,
+// returning a *core.FallbackBudgetError instead
+func (r *ScoreRouter) SetFallbackBudget(budget *core.FallbackBudget) {
+	r.budget = budget
+}
+
+// SetStateSource enables scoring penalties for workers whose heartbeats are late
+func (r *ScoreRouter) SetStateSource(states core.WorkerStateSource) {
+	r.states = states
+}
+
+// NewScoreRouter creates a new ScoreRouter with DefaultConfig and the score strategy
 func NewScoreRouter() *ScoreRouter {
-	return &ScoreRouter{
-		vramWeight: 1.0,
-		loadWeight: 1.0,
+	r, _ := NewScoreRouterWithConfig(DefaultConfig())
+	return r
+}
+
+// NewScoreRouterWithConfig creates a ScoreRouter with the given weights, VRAM headroom and filter thresholds
+func NewScoreRouterWithConfig(cfg Config) (*ScoreRouter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
+	r := &ScoreRouter{strategies: builtinStrategies()}
+	strategy := StrategyScore
+	r.strategy.Store(&strategy)
+	r.config.Store(&cfg)
+	w := cfg.Weights
+	r.weights.Store(&w)
+	return r, nil
+}
+
+// Config returns the current configuration, carrying the current weights
+func (r *ScoreRouter) Config() Config {
+	cfg := *r.config.Load()
+	cfg.Weights = r.Weights()
+	return cfg
+}
+
+// SetConfig validates and atomically swaps the weights, VRAM headroom and filter thresholds
+// In-flight Select calls keep using the configuration they started with
+func (r *ScoreRouter) SetConfig(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	r.config.Store(&cfg)
+	w := cfg.Weights
+	r.weights.Store(&w)
+	return nil
 }
 
 // Select chooses the best worker for the given request
 func (r *ScoreRouter) Select(ctx context.Context, workers []core.Worker, req *core.InferenceRequest) (core.Worker, error) {
-	var fallbackWorker core.Worker
-	var candidateWorkers []workerScore
+	probed := r.probeWorkers(ctx, workers)
+	bound := r.sessions.Worker(ctx, req.Session)
+	selected, err := r.selectProbed(probed, req, bound)
+	r.observeDecision(probed, req, selected, err)
+	if err == nil && !req.Fallback {
+		r.sessions.Bind(ctx, req.Session, selected.ID())
+	}
+	return selected, err
+}
+
+// selectProbed runs the filter/score pipeline on already probed workers
+// bound is the worker the request's session is bound to, preferred while it passes the filters
+func (r *ScoreRouter) selectProbed(probed []probedWorker, req *core.InferenceRequest, bound string) (core.Worker, error) {
+	// Requests pinned to a worker class only see workers of that class
+	if req.WorkerClass != "" {
+		probed = filterClass(probed, req.WorkerClass)
+	}
 
-	// Required VRAM for the requested model
-	requiredVRAM := estimateModelVRAM(req.Model)
+	// Speculative decoding pairs are routed as a unit
+	if pair, ok := r.requestPair(req); ok {
+		return r.selectSpeculative(probed, req, pair)
+	}
 
 	// Phase 1: Pre-filtering and collect candidates
+	pool := r.collectCandidates(probed, []string{req.Model}, r.requiredVRAM(req.Model, req.PromptTokens), req.Adapter, req.Needs, req.Priority)
+
+	// Phase 2: If no local candidates, overflow to a peer gateway, then return fallback
+	// Low-priority (batch) requests stay local rather than use remote or paid capacity
+	if len(pool.candidates) == 0 {
+		if req.Priority < core.PriorityNormal {
+			return nil, fmt.Errorf("%w locally for low-priority request", core.ErrNoAvailableWorkers)
+		}
+		if len(pool.peers) > 0 && !req.Federated {
+			// Peer gateways resolve adapters on their own
+			req.LoadAdapter = false
+			return selectBestWorker(pool.peers, r.Weights()).worker, nil
+		}
+		if fallback := pool.fallback(); fallback != nil {
+			// Paid fallback stops once its daily or monthly budget is spent
+			if err := r.budget.Check(); err != nil {
+				return nil, err
+			}
+			// Fallback workers are expected to resolve adapters on their own
+			req.LoadAdapter = false
+			req.Fallback = true
+			return fallback, nil
+		}
+		return nil, fmt.Errorf("%w for request", core.ErrNoAvailableWorkers)
+	}
+
+	// Phase 3: Score and select best worker, preferring the closest topology tier
+	// A session stays on its worker while it has capacity, reusing the cached conversation prefix
+	local := r.preferLocal(r.rampDown(pool.candidates))
+	best, ok := findCandidate(local, bound)
+	if !ok {
+		best = r.pick(req, local)
+	}
+
+	// Workers without the adapter resident are instructed to load it lazily
+	req.LoadAdapter = req.Adapter != "" && !core.HasAdapter(best.profile.LoadedAdapters, req.Adapter)
+	return best.worker, nil
+}
+
+// filterClass keeps the workers reporting the given class
+func filterClass(probed []probedWorker, class string) []probedWorker {
+	kept := make([]probedWorker, 0, len(probed))
+	for _, p := range probed {
+		if p.err == nil && strings.EqualFold(p.profile.Class, class) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// Exclusion reasons reported by routing previews
+const (
+	ReasonHeartbeatError   = "heartbeat_error"
+	ReasonModelUnsupported = "model_unsupported"
+	ReasonInsufficientVRAM = "insufficient_vram"
+	ReasonAtCapacity       = "at_capacity"
+	ReasonModelSlotsFull   = "model_slots_full"
+	ReasonKVCacheFull      = "kv_cache_full"
+	// ReasonReservedCapacity means the worker's free slots are held back for high-priority requests
+	ReasonReservedCapacity = "reserved_capacity"
+	ReasonFederationLoop   = "federation_loop"
+	// ReasonQuarantined means the fallback worker's circuit breaker is open
+	ReasonQuarantined = "quarantined"
+	// ReasonMissingCapability is suffixed with the missing capability, e.g. "missing_capability:tools"
+	ReasonMissingCapability = "missing_capability"
+)
+
+// probedWorker pairs a worker with the profile it reported for this routing decision
+type probedWorker struct {
+	worker  core.Worker
+	profile core.WorkerProfile
+	err     error
+}
+
+// probeWorkers fetches the current profile of every worker with a Heartbeat call
+func probeWorkers(ctx context.Context, workers []core.Worker) []probedWorker {
+	probed := make([]probedWorker, 0, len(workers))
 	for _, worker := range workers {
 		profile, err := worker.Heartbeat(ctx)
-		if err != nil {
-			// Skip worker on heartbeat error
+		probed = append(probed, probedWorker{worker: worker, profile: profile, err: err})
+	}
+	return probed
+}
+
+// candidatePool is the result of the hard-filter phase
+type candidatePool struct {
+	candidates []workerScore
+	// peers are federated gateways, used only when no local candidate exists
+	peers []workerScore
+	// fallbacks are the usable fallback workers, highest profile priority first
+	fallbacks []probedWorker
+	// excluded maps filtered-out worker IDs to the reason they were dropped
+	excluded map[string]string
+}
+
+// collectCandidates applies the hard filters and returns the scored local candidates
+// that can serve all of the given models with the needed capabilities, plus the usable fallback workers
+func (r *ScoreRouter) collectCandidates(probed []probedWorker, models []string, requiredVRAM uint64, adapter string, needs core.Capabilities, priority core.Priority) candidatePool {
+	pool := candidatePool{excluded: make(map[string]string)}
+	cfg := r.config.Load()
+	// 估算不含激活值等开销，按配置预留安全余量
+	requiredVRAM = cfg.withHeadroom(requiredVRAM)
+	// 未上报上下文长度的 Worker 按模型目录中的上下文长度过滤
+	modelContext := r.modelContext(models)
+
+	for _, p := range probed {
+		worker, profile := p.worker, p.profile
+
+		// Skip worker on heartbeat error
+		if p.err != nil {
+			pool.excluded[worker.ID()] = ReasonHeartbeatError
 			continue
 		}
 
-		// Identify fallback/cloud worker
-		if isFallbackWorker(worker.ID()) {
-			fallbackWorker = worker
+		// Fallback/cloud workers are set aside for overflow unless they cannot take the request
+		if isFallbackWorker(worker.ID(), profile) {
+			if reason := r.fallbackExclusion(worker.ID(), profile, models); reason != "" {
+				pool.excluded[worker.ID()] = reason
+			} else {
+				pool.fallbacks = append(pool.fallbacks, p)
+			}
 			continue
 		}
 
 		// Hard filter: check model support
-		if !isModelSupported(req.Model, profile.Supported) {
+		if !supportsAll(models, profile.Supported) {
+			pool.excluded[worker.ID()] = ReasonModelUnsupported
+			continue
+		}
+
+		// Hard filter: backend must support the request's features (tools, vision, context length...)
+		caps := profile.Capabilities
+		if caps.MaxContext == 0 {
+			caps.MaxContext = modelContext
+		}
+		if missing := caps.Missing(needs); missing != "" {
+			pool.excluded[worker.ID()] = ReasonMissingCapability + ":" + missing
 			continue
 		}
 
 		// Hard filter: check VRAM availability
 		if profile.AvailableVRAM < requiredVRAM {
+			pool.excluded[worker.ID()] = ReasonInsufficientVRAM
 			continue
 		}
 
 		// Hard filter: check if worker is at max capacity
-		if profile.ActiveTasks >= profile.MaxTasks {
+		if cfg.atCapacity(profile.ActiveTasks, profile.MaxTasks) {
+			pool.excluded[worker.ID()] = ReasonAtCapacity
 			continue
 		}
 
-		// Pass all filters, add to candidate pool
-		candidateWorkers = append(candidateWorkers, workerScore{
-			worker:    worker,
-			profile:   profile,
-			vramScore: calculateVRAMScore(profile.AvailableVRAM, profile.TotalVRAM),
-			loadScore: calculateLoadScore(profile.ActiveTasks, profile.MaxTasks),
-		})
-	}
+		// Hard filter: the remaining slots are reserved for high-priority requests
+		if priority < core.PriorityHigh && cfg.inReserve(profile.ActiveTasks, profile.MaxTasks) {
+			pool.excluded[worker.ID()] = ReasonReservedCapacity
+			continue
+		}
 
-	// Phase 2: If no local candidates, return fallback
-	if len(candidateWorkers) == 0 {
-		if fallbackWorker != nil {
-			return fallbackWorker, nil
+		// Hard filter: every per-model concurrency slot of the requested models is taken
+		if r.modelSlotsFull(worker.ID(), profile, models) {
+			pool.excluded[worker.ID()] = ReasonModelSlotsFull
+			continue
+		}
+
+		// Hard filter: reported KV-cache is saturated, new sequences would be preempted
+		if profile.KVCacheUsage >= cfg.KVCacheSaturation {
+			pool.excluded[worker.ID()] = ReasonKVCacheFull
+			continue
+		}
+
+		// Pass all filters, add to candidate pool
+		// Requests queued inside the worker count as load on top of the active ones
+		score := workerScore{
+			worker:       worker,
+			profile:      profile,
+			vramScore:    calculateVRAMScore(profile.AvailableVRAM, profile.TotalVRAM),
+			loadScore:    calculateLoadScore(profile.ActiveTasks+profile.QueueLength, profile.MaxTasks),
+			adapterScore: calculateAdapterScore(adapter, profile.LoadedAdapters),
+		}
+		// Stale-but-usable workers keep receiving traffic at a reduced weight
+		if r.states != nil && r.states.WorkerState(worker.ID()).Health == core.WorkerDegraded {
+			score.penalty = cfg.DegradedPenalty
+		}
+		if profile.Peer {
+			pool.peers = append(pool.peers, score)
+		} else {
+			pool.candidates = append(pool.candidates, score)
 		}
-		return nil, fmt.Errorf("no available workers for request")
 	}
 
-	// Phase 3: Score and select best worker
-	bestWorker := selectBestWorker(candidateWorkers, r.vramWeight, r.loadWeight)
-	return bestWorker, nil
+	sortFallbacks(pool.fallbacks)
+
+	// Soft signal: rank candidates by their observed latency
+	r.scoreLatency(pool.candidates)
+	r.scoreLatency(pool.peers)
+	scoreCost(pool.candidates)
+	scoreCost(pool.peers)
+
+	return pool
 }
 
 // workerScore holds a worker and its calculated scores
 type workerScore struct {
-	worker  core.Worker
-	profile core.WorkerProfile
-	vramScore float64
-	loadScore float64
+	worker       core.Worker
+	profile      core.WorkerProfile
+	vramScore    float64
+	loadScore    float64
+	adapterScore float64
+	// latencyScore and costScore are set by scoreLatency and scoreCost
+	latencyScore float64
+	costScore    float64
+	// penalty is the fraction of the combined score the worker loses (0 = none)
+	penalty float64
+}
+
+// total returns the combined weighted score
+func (s workerScore) total(w Weights) float64 {
+	score := s.vramScore*w.VRAM +
+		s.loadScore*w.Load +
+		s.adapterScore*w.Adapter +
+		s.latencyScore*w.Latency +
+		s.costScore*w.Cost
+	return score * (1 - s.penalty)
 }
 
-// estimateModelVRAM estimates required VRAM based on model name
+// estimateModelVRAM estimates required VRAM based on model name, for models without a ModelProfile
 func estimateModelVRAM(model string) uint64 {
 	modelLower := strings.ToLower(model)
 
@@ -113,6 +374,30 @@ func estimateModelVRAM(model string) uint64 {
 	return 2 * 1024 * 1024 * 1024 // 2GB
 }
 
+// estimateKVCacheVRAM estimates the KV-cache VRAM needed to hold promptTokens of context
+// Per-token sizes are conservative fp16 estimates for models without grouped-query attention
+func estimateKVCacheVRAM(model string, promptTokens int) uint64 {
+	if promptTokens <= 0 {
+		return 0
+	}
+
+	modelLower := strings.ToLower(model)
+	var bytesPerToken uint64 = 256 * 1024 // 256KB for small or unknown models
+
+	switch {
+	case strings.Contains(modelLower, "8b") || strings.Contains(modelLower, "7b"):
+		bytesPerToken = 512 * 1024 // 512KB
+	case strings.Contains(modelLower, "13b") || strings.Contains(modelLower, "14b"):
+		bytesPerToken = 800 * 1024 // 800KB
+	case strings.Contains(modelLower, "30b") || strings.Contains(modelLower, "34b") || strings.Contains(modelLower, "32b"):
+		bytesPerToken = 1600 * 1024 // 1.6MB
+	case strings.Contains(modelLower, "70b") || strings.Contains(modelLower, "72b") || strings.Contains(modelLower, "67b"):
+		bytesPerToken = 2560 * 1024 // 2.5MB
+	}
+
+	return uint64(promptTokens) * bytesPerToken
+}
+
 // isModelSupported checks if the model is in the supported list
 func isModelSupported(model string, supported []string) bool {
 	for _, s := range supported {
@@ -123,10 +408,36 @@ func isModelSupported(model string, supported []string) bool {
 	return false
 }
 
+// supportsAll checks that every model is in the supported list
+func supportsAll(models []string, supported []string) bool {
+	for _, model := range models {
+		if !isModelSupported(model, supported) {
+			return false
+		}
+	}
+	return true
+}
+
 // isFallbackWorker checks if the worker is a fallback/cloud worker
-func isFallbackWorker(workerID string) bool {
-	idLower := strings.ToLower(workerID)
-	return strings.Contains(idLower, "fallback") || strings.Contains(idLower, "cloud")
+// Profiles should set IsFallback; workers that do not are still classified by their ID
+func isFallbackWorker(workerID string, profile core.WorkerProfile) bool {
+	return profile.IsFallback || hasFallbackName(workerID)
+}
+
+// hasFallbackName reports whether a "-", "_" or "." separated part of the worker ID is "cloud" or "fallback",
+// so "cloud-gpt4" matches but "cloudlab-3090" does not
+//
+// Deprecated: kept for workers registered before WorkerProfile.IsFallback; set IsFallback instead
+func hasFallbackName(workerID string) bool {
+	parts := strings.FieldsFunc(strings.ToLower(workerID), func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	})
+	for _, part := range parts {
+		if part == "fallback" || part == "cloud" {
+			return true
+		}
+	}
+	return false
 }
 
 // calculateVRAMScore calculates score based on physical VRAM percentage
@@ -155,27 +466,39 @@ func calculateLoadScore(activeTasks, maxTasks int) float64 {
 	if activeTasks >= maxTasks {
 		return 0
 	}
-	availableCapacity := float64(maxTasks - activeTasks) / float64(maxTasks) * 100
+	availableCapacity := float64(maxTasks-activeTasks) / float64(maxTasks) * 100
 	if availableCapacity > 100 {
 		availableCapacity = 100
 	}
 	return availableCapacity
 }
 
-// selectBestWorker selects the worker with highest combined score
-func selectBestWorker(candidates []workerScore, vramWeight, loadWeight float64) core.Worker {
-	var bestWorker core.Worker
+// calculateAdapterScore returns 100 when the requested LoRA adapter is already resident, 0 otherwise
+// Requests without an adapter score 0 on every worker so the term does not affect ranking
+func calculateAdapterScore(adapter string, loaded []string) float64 {
+	if adapter == "" {
+		return 0
+	}
+	if core.HasAdapter(loaded, adapter) {
+		return 100
+	}
+	return 0
+}
+
+// selectBestWorker selects the candidate with highest combined score
+func selectBestWorker(candidates []workerScore, w Weights) workerScore {
+	var best workerScore
 	var bestScore float64 = -1
 
 	for _, candidate := range candidates {
 		// Combined weighted score
-		totalScore := candidate.vramScore*vramWeight + candidate.loadScore*loadWeight
+		totalScore := candidate.total(w)
 
 		if totalScore > bestScore {
 			bestScore = totalScore
-			bestWorker = candidate.worker
+			best = candidate
 		}
 	}
 
-	return bestWorker
+	return best
 }
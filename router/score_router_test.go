@@ -2,7 +2,12 @@ package router
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"zam/core"
 )
@@ -520,11 +525,12 @@ func TestIsFallbackWorker(t *testing.T) {
 		{"local-4070tis", false},
 		{"remote-edge", false},
 		{"CLOUD-UPPERCASE", true}, // Case insensitive
+		{"cloudlab-3090", false}, // Only whole ID parts match
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.workerID, func(t *testing.T) {
-			result := isFallbackWorker(tt.workerID)
+			result := isFallbackWorker(tt.workerID, core.WorkerProfile{WorkerID: tt.workerID})
 			if result != tt.expected {
 				t.Errorf("WorkerID %s: expected %v, got %v",
 					tt.workerID, tt.expected, result)
@@ -532,3 +538,1372 @@ func TestIsFallbackWorker(t *testing.T) {
 		})
 	}
 }
+
+// TestScoreRouter_AdapterAffinity tests that workers with the LoRA adapter resident are preferred
+func TestScoreRouter_AdapterAffinity(t *testing.T) {
+	workers := []core.Worker{
+		&mockWorker{
+			id: "local-idle",
+			profile: core.WorkerProfile{
+				WorkerID:      "local-idle",
+				Supported:     []string{"llama-8b"},
+				TotalVRAM:     16 * 1024 * 1024 * 1024,
+				AvailableVRAM: 14 * 1024 * 1024 * 1024,
+				ActiveTasks:   0,
+				MaxTasks:      4,
+			},
+		},
+		&mockWorker{
+			id: "local-lora",
+			profile: core.WorkerProfile{
+				WorkerID:       "local-lora",
+				Supported:      []string{"llama-8b"},
+				TotalVRAM:      16 * 1024 * 1024 * 1024,
+				AvailableVRAM:  10 * 1024 * 1024 * 1024,
+				ActiveTasks:    1,
+				MaxTasks:       4,
+				LoadedAdapters: []string{"sql-lora"},
+			},
+		},
+	}
+
+	router := NewScoreRouter()
+
+	req := &core.InferenceRequest{TraceID: "test-lora-1", Model: "llama-8b", Adapter: "sql-lora"}
+	selected, err := router.Select(context.Background(), workers, req)
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if selected.ID() != "local-lora" {
+		t.Errorf("expected worker with resident adapter, got %s", selected.ID())
+	}
+	if req.LoadAdapter {
+		t.Error("expected LoadAdapter=false when adapter is resident")
+	}
+
+	req = &core.InferenceRequest{TraceID: "test-lora-2", Model: "llama-8b", Adapter: "chat-lora"}
+	selected, err = router.Select(context.Background(), workers, req)
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if selected.ID() != "local-idle" {
+		t.Errorf("expected least loaded worker when no one has the adapter, got %s", selected.ID())
+	}
+	if !req.LoadAdapter {
+		t.Error("expected LoadAdapter=true when adapter must be loaded lazily")
+	}
+}
+
+// TestScoreRouter_SpeculativePair tests draft/verify placement for speculative decoding pairs
+func TestScoreRouter_SpeculativePair(t *testing.T) {
+	pairs, err := ParseSpeculativePairs("llama-70b-spec=llama-8b+llama-70b")
+	if err != nil {
+		t.Fatalf("ParseSpeculativePairs() error = %v", err)
+	}
+
+	big := &mockWorker{
+		id: "local-a100",
+		profile: core.WorkerProfile{
+			WorkerID:      "local-a100",
+			Supported:     []string{"llama-70b"},
+			TotalVRAM:     80 * 1024 * 1024 * 1024,
+			AvailableVRAM: 44 * 1024 * 1024 * 1024,
+			MaxTasks:      4,
+		},
+	}
+	small := &mockWorker{
+		id: "local-4070tis",
+		profile: core.WorkerProfile{
+			WorkerID:      "local-4070tis",
+			Supported:     []string{"llama-8b"},
+			TotalVRAM:     16 * 1024 * 1024 * 1024,
+			AvailableVRAM: 14 * 1024 * 1024 * 1024,
+			MaxTasks:      4,
+		},
+	}
+
+	router := NewScoreRouter()
+	router.SetSpeculativePairs(pairs)
+
+	// Split placement: verify on the big GPU, draft on the small one
+	req := &core.InferenceRequest{TraceID: "test-spec-1", RequestedModel: "llama-70b-spec", Model: "llama-70b-spec"}
+	selected, err := router.Select(context.Background(), []core.Worker{big, small}, req)
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if selected.ID() != "local-a100" || req.Model != "llama-70b" {
+		t.Errorf("expected target llama-70b on local-a100, got %s on %s", req.Model, selected.ID())
+	}
+	if req.Speculative == nil || req.Speculative.DraftWorkerID != "local-4070tis" {
+		t.Errorf("expected draft on local-4070tis, got %+v", req.Speculative)
+	}
+
+	// Selecting the same request again (queue retry, failover) places the pair afresh
+	other := &mockWorker{id: "local-3090", profile: small.profile}
+	other.profile.WorkerID = "local-3090"
+	selected, err = router.Select(context.Background(), []core.Worker{big, other}, req)
+	if err != nil {
+		t.Fatalf("second Select() error = %v", err)
+	}
+	if selected.ID() != "local-a100" || req.Model != "llama-70b" || req.Speculative == nil || req.Speculative.DraftWorkerID != "local-3090" {
+		t.Errorf("expected the pair re-placed with the draft on local-3090, got %s on %s with %+v", req.Model, selected.ID(), req.Speculative)
+	}
+
+	// Co-located placement: one worker serves both models
+	both := &mockWorker{
+		id: "local-h100",
+		profile: core.WorkerProfile{
+			WorkerID:      "local-h100",
+			Supported:     []string{"llama-8b", "llama-70b"},
+			TotalVRAM:     80 * 1024 * 1024 * 1024,
+			AvailableVRAM: 60 * 1024 * 1024 * 1024,
+			MaxTasks:      4,
+		},
+	}
+	req = &core.InferenceRequest{TraceID: "test-spec-2", RequestedModel: "llama-70b-spec", Model: "llama-70b-spec"}
+	selected, err = router.Select(context.Background(), []core.Worker{big, small, both}, req)
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if selected.ID() != "local-h100" || req.Speculative == nil || req.Speculative.DraftWorkerID != "local-h100" {
+		t.Errorf("expected co-located pair on local-h100, got %s with %+v", selected.ID(), req.Speculative)
+	}
+}
+
+// TestScoreRouter_SetWeights tests validation and atomic swap of router weights
+func TestScoreRouter_SetWeights(t *testing.T) {
+	router := NewScoreRouter()
+
+	invalid := []Weights{
+		{VRAM: -1, Load: 1},
+		{},
+	}
+	for _, w := range invalid {
+		if err := router.SetWeights(w); err == nil {
+			t.Errorf("expected error for weights %+v", w)
+		}
+	}
+	if router.Weights() != DefaultWeights() {
+		t.Errorf("rejected weights must not be applied, got %+v", router.Weights())
+	}
+
+	tuned := Weights{VRAM: 0.5, Load: 2, Latency: 1}
+	if err := router.SetWeights(tuned); err != nil {
+		t.Fatalf("SetWeights() error = %v", err)
+	}
+	if router.Weights() != tuned {
+		t.Errorf("expected %+v, got %+v", tuned, router.Weights())
+	}
+}
+
+// TestScoreRouter_TenantAntiAffinity tests that a tenant's burst is spread across distinct workers
+func TestScoreRouter_TenantAntiAffinity(t *testing.T) {
+	newWorker := func(id string, available uint64) *mockWorker {
+		return &mockWorker{
+			id: id,
+			profile: core.WorkerProfile{
+				WorkerID:      id,
+				Supported:     []string{"gemma-2b"},
+				TotalVRAM:     16 * 1024 * 1024 * 1024,
+				AvailableVRAM: available * 1024 * 1024 * 1024,
+				MaxTasks:      8,
+			},
+		}
+	}
+	workers := []core.Worker{newWorker("local-a", 14), newWorker("local-b", 10)}
+
+	inflight := core.NewInflightTracker()
+	router := NewScoreRouter()
+	router.SetTenantAntiAffinity(inflight)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		req := &core.InferenceRequest{TraceID: "test-tenant", Tenant: "key-a", Model: "gemma-2b"}
+		selected, err := router.Select(context.Background(), workers, req)
+		if err != nil {
+			t.Fatalf("Select() error = %v", err)
+		}
+		seen[selected.ID()] = true
+		defer inflight.Acquire(selected.ID(), "key-a")()
+	}
+
+	if len(seen) != 2 {
+		t.Errorf("expected burst spread over 2 workers, got %v", seen)
+	}
+}
+
+// TestScoreRouter_PromptLengthAware tests that long prompts are steered to workers with KV-cache headroom
+func TestScoreRouter_PromptLengthAware(t *testing.T) {
+	workers := []core.Worker{
+		&mockWorker{
+			id: "local-2060",
+			profile: core.WorkerProfile{
+				WorkerID:      "local-2060",
+				Supported:     []string{"llama-8b"},
+				TotalVRAM:     8 * 1024 * 1024 * 1024,
+				AvailableVRAM: 7 * 1024 * 1024 * 1024,
+				MaxTasks:      5,
+			},
+		},
+		&mockWorker{
+			id: "local-4090",
+			profile: core.WorkerProfile{
+				WorkerID:      "local-4090",
+				Supported:     []string{"llama-8b"},
+				TotalVRAM:     24 * 1024 * 1024 * 1024,
+				AvailableVRAM: 12 * 1024 * 1024 * 1024,
+				ActiveTasks:   4,
+				MaxTasks:      5,
+			},
+		},
+	}
+	router := NewScoreRouter()
+
+	// Short prompt: the idle 2060 wins on load and VRAM percentage
+	short := &core.InferenceRequest{TraceID: "test-prompt-1", Model: "llama-8b", PromptTokens: 100}
+	selected, err := router.Select(context.Background(), workers, short)
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if selected.ID() != "local-2060" {
+		t.Errorf("expected short prompt on local-2060, got %s", selected.ID())
+	}
+
+	// 8K prompt needs ~4GB of KV-cache on top of the 6GB weights: only the 4090 fits
+	long := &core.InferenceRequest{TraceID: "test-prompt-2", Model: "llama-8b", PromptTokens: 8000}
+	selected, err = router.Select(context.Background(), workers, long)
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if selected.ID() != "local-4090" {
+		t.Errorf("expected long prompt on local-4090, got %s", selected.ID())
+	}
+}
+
+// TestScoreRouter_Preview tests that previews rank candidates and explain exclusions without mutating the request
+func TestScoreRouter_Preview(t *testing.T) {
+	workers := []core.Worker{
+		&mockWorker{
+			id: "local-4070tis",
+			profile: core.WorkerProfile{
+				WorkerID:      "local-4070tis",
+				Supported:     []string{"llama-8b"},
+				TotalVRAM:     16 * 1024 * 1024 * 1024,
+				AvailableVRAM: 14 * 1024 * 1024 * 1024,
+				MaxTasks:      4,
+			},
+		},
+		&mockWorker{
+			id: "local-2060",
+			profile: core.WorkerProfile{
+				WorkerID:      "local-2060",
+				Supported:     []string{"llama-8b"},
+				TotalVRAM:     6 * 1024 * 1024 * 1024,
+				AvailableVRAM: 5 * 1024 * 1024 * 1024,
+				MaxTasks:      4,
+			},
+		},
+		&mockWorker{
+			id: "cloud-fallback",
+			profile: core.WorkerProfile{
+				WorkerID:  "cloud-fallback",
+				Supported: []string{"*"},
+			},
+		},
+	}
+
+	req := &core.InferenceRequest{TraceID: "test-preview", Model: "llama-8b", Adapter: "sql-lora"}
+	decision := NewScoreRouter().Preview(context.Background(), workers, req)
+
+	if decision.Selected != "local-4070tis" {
+		t.Errorf("expected local-4070tis selected, got %q (%s)", decision.Selected, decision.Error)
+	}
+	if req.LoadAdapter {
+		t.Error("preview must not mutate the caller's request")
+	}
+	if len(decision.Candidates) != 3 {
+		t.Fatalf("expected 3 candidates, got %+v", decision.Candidates)
+	}
+	if decision.Candidates[1].WorkerID != "cloud-fallback" || !decision.Candidates[1].Fallback {
+		t.Errorf("expected fallback listed after eligible workers, got %+v", decision.Candidates[1])
+	}
+	if decision.Candidates[2].WorkerID != "local-2060" || decision.Candidates[2].Excluded != ReasonInsufficientVRAM {
+		t.Errorf("expected local-2060 excluded for VRAM, got %+v", decision.Candidates[2])
+	}
+}
+
+// TestScoreRouter_Locality tests same-zone preference with cross-zone fallback
+func TestScoreRouter_Locality(t *testing.T) {
+	newWorker := func(id, region, zone string, available uint64) *mockWorker {
+		return &mockWorker{
+			id: id,
+			profile: core.WorkerProfile{
+				WorkerID:      id,
+				Supported:     []string{"gemma-2b"},
+				TotalVRAM:     16 * 1024 * 1024 * 1024,
+				AvailableVRAM: available * 1024 * 1024 * 1024,
+				MaxTasks:      4,
+				Region:        region,
+				Zone:          zone,
+			},
+		}
+	}
+	lan := newWorker("home-3090", "home", "lan", 4)
+	colo := newWorker("colo-a100", "home", "colo", 15)
+	remote := newWorker("eu-h100", "eu", "fra-1", 15)
+
+	router := NewScoreRouter()
+	router.SetLocality(Locality{Region: "home", Zone: "lan"})
+
+	tests := []struct {
+		name       string
+		workers    []core.Worker
+		expectedID string
+	}{
+		{"同Zone优先即使得分更低", []core.Worker{lan, colo, remote}, "home-3090"},
+		{"同Zone不可用时退到同Region", []core.Worker{colo, remote}, "colo-a100"},
+		{"同Region不可用时跨Region兜底", []core.Worker{remote}, "eu-h100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &core.InferenceRequest{TraceID: "test-zone", Model: "gemma-2b"}
+			selected, err := router.Select(context.Background(), tt.workers, req)
+			if err != nil {
+				t.Fatalf("Select() error = %v", err)
+			}
+			if selected.ID() != tt.expectedID {
+				t.Errorf("expected %s, got %s", tt.expectedID, selected.ID())
+			}
+		})
+	}
+}
+
+// TestScoreRouter_FederationOverflow tests local-first routing with peer overflow and loop prevention
+func TestScoreRouter_FederationOverflow(t *testing.T) {
+	local := &mockWorker{
+		id: "local-2060",
+		profile: core.WorkerProfile{
+			WorkerID:      "local-2060",
+			Supported:     []string{"gemma-2b"},
+			TotalVRAM:     6 * 1024 * 1024 * 1024,
+			AvailableVRAM: 5 * 1024 * 1024 * 1024,
+			ActiveTasks:   1,
+			MaxTasks:      1,
+		},
+	}
+	peer := &mockWorker{
+		id: "peer-colo",
+		profile: core.WorkerProfile{
+			WorkerID:      "peer-colo",
+			Supported:     []string{"gemma-2b"},
+			TotalVRAM:     24 * 1024 * 1024 * 1024,
+			AvailableVRAM: 20 * 1024 * 1024 * 1024,
+			MaxTasks:      8,
+			Peer:          true,
+		},
+	}
+	fallback := &mockWorker{
+		id:      "cloud-fallback",
+		profile: core.WorkerProfile{WorkerID: "cloud-fallback", Supported: []string{"*"}},
+	}
+	workers := []core.Worker{local, peer, fallback}
+	router := NewScoreRouter()
+
+	req := &core.InferenceRequest{TraceID: "test-fed-1", Model: "gemma-2b"}
+	selected, err := router.Select(context.Background(), workers, req)
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if selected.ID() != "peer-colo" {
+		t.Errorf("expected overflow to peer when local is saturated, got %s", selected.ID())
+	}
+
+	req = &core.InferenceRequest{TraceID: "test-fed-2", Model: "gemma-2b", Federated: true}
+	selected, err = router.Select(context.Background(), workers, req)
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if selected.ID() != "cloud-fallback" {
+		t.Errorf("expected federated request never forwarded to a peer again, got %s", selected.ID())
+	}
+
+	aggregate := AggregateProfile("gw", []core.WorkerProfile{local.profile, peer.profile, fallback.profile})
+	if len(aggregate.Supported) != 1 || aggregate.MaxTasks != 1 {
+		t.Errorf("expected aggregate to cover only local workers, got %+v", aggregate)
+	}
+}
+
+// staticStates implements core.WorkerStateSource for testing
+type staticStates map[string]core.HealthState
+
+func (s staticStates) WorkerState(workerID string) core.WorkerState {
+	if health, ok := s[workerID]; ok {
+		return core.WorkerState{Health: health}
+	}
+	return core.WorkerState{Health: core.WorkerHealthy}
+}
+
+// TestScoreRouter_DegradedPenalty tests that stale-but-usable workers lose weight but stay routable
+func TestScoreRouter_DegradedPenalty(t *testing.T) {
+	newWorker := func(id string, available uint64) *mockWorker {
+		return &mockWorker{
+			id: id,
+			profile: core.WorkerProfile{
+				WorkerID:      id,
+				Supported:     []string{"gemma-2b"},
+				TotalVRAM:     16 * 1024 * 1024 * 1024,
+				AvailableVRAM: available * 1024 * 1024 * 1024,
+				MaxTasks:      4,
+			},
+		}
+	}
+	stale := newWorker("local-stale", 15)
+	fresh := newWorker("local-fresh", 10)
+
+	router := NewScoreRouter()
+	router.SetStateSource(staticStates{"local-stale": core.WorkerDegraded})
+
+	req := &core.InferenceRequest{TraceID: "test-degraded-1", Model: "gemma-2b"}
+	selected, err := router.Select(context.Background(), []core.Worker{stale, fresh}, req)
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if selected.ID() != "local-fresh" {
+		t.Errorf("expected degraded worker to lose to a fresh one, got %s", selected.ID())
+	}
+
+	req = &core.InferenceRequest{TraceID: "test-degraded-2", Model: "gemma-2b"}
+	selected, err = router.Select(context.Background(), []core.Worker{stale}, req)
+	if err != nil || selected.ID() != "local-stale" {
+		t.Errorf("expected degraded worker to remain routable, got %v, %v", selected, err)
+	}
+}
+
+func TestScoreRouter_CapabilityFilter(t *testing.T) {
+	newWorker := func(id string, available uint64, caps core.Capabilities) *mockWorker {
+		return &mockWorker{
+			id: id,
+			profile: core.WorkerProfile{
+				WorkerID:      id,
+				Supported:     []string{"llama-8b"},
+				TotalVRAM:     16 * 1024 * 1024 * 1024,
+				AvailableVRAM: available * 1024 * 1024 * 1024,
+				MaxTasks:      4,
+				Capabilities:  caps,
+			},
+		}
+	}
+	// 显存更充裕的节点不支持 tool calling
+	plain := newWorker("local-plain", 15, core.Capabilities{MaxContext: 8192})
+	tools := newWorker("local-tools", 10, core.Capabilities{Tools: true, JSONMode: true, MaxContext: 4096})
+	workers := []core.Worker{plain, tools}
+	router := NewScoreRouter()
+
+	req := &core.InferenceRequest{TraceID: "test-caps-1", Model: "llama-8b", Needs: core.Capabilities{Tools: true}}
+	selected, err := router.Select(context.Background(), workers, req)
+	if err != nil || selected.ID() != "local-tools" {
+		t.Fatalf("expected tool request on local-tools, got %v, %v", selected, err)
+	}
+
+	req = &core.InferenceRequest{TraceID: "test-caps-2", Model: "llama-8b", Needs: core.Capabilities{Vision: true}}
+	if _, err := router.Select(context.Background(), workers, req); err == nil {
+		t.Error("expected vision request to fail without a vision-capable worker")
+	}
+
+	req = &core.InferenceRequest{TraceID: "test-caps-3", Model: "llama-8b", Needs: core.Capabilities{JSONMode: true, MaxContext: 6000}}
+	decision := router.Preview(context.Background(), workers, req)
+	if decision.Selected != "" {
+		t.Errorf("expected no worker to satisfy json_mode with 6000 tokens, got %s", decision.Selected)
+	}
+	for _, c := range decision.Candidates {
+		want := map[string]string{
+			"local-plain": ReasonMissingCapability + ":json_mode",
+			"local-tools": ReasonMissingCapability + ":max_context",
+		}[c.WorkerID]
+		if c.Excluded != want {
+			t.Errorf("%s: expected exclusion %q, got %q", c.WorkerID, want, c.Excluded)
+		}
+	}
+}
+
+// TestScoreRouter_ModelSlots tests per-model concurrency slots reported by workers and tracked by the gateway
+func TestScoreRouter_ModelSlots(t *testing.T) {
+	big := &mockWorker{
+		id: "local-big",
+		profile: core.WorkerProfile{
+			WorkerID:      "local-big",
+			Supported:     []string{"gemma-2b", "llama-70b"},
+			TotalVRAM:     80 * 1024 * 1024 * 1024,
+			AvailableVRAM: 60 * 1024 * 1024 * 1024,
+			MaxTasks:      8,
+			ModelSlots:    map[string]int{"gemma-2b": 4, "llama-70b": 1},
+			ActiveByModel: map[string]int{"gemma-2b": 4},
+		},
+	}
+	small := &mockWorker{
+		id: "local-small",
+		profile: core.WorkerProfile{
+			WorkerID:      "local-small",
+			Supported:     []string{"gemma-2b"},
+			TotalVRAM:     8 * 1024 * 1024 * 1024,
+			AvailableVRAM: 4 * 1024 * 1024 * 1024,
+			MaxTasks:      8,
+		},
+	}
+	workers := []core.Worker{big, small}
+
+	inflight := core.NewInflightTracker()
+	router := NewScoreRouter()
+	router.SetModelCounter(inflight)
+
+	// gemma-2b 的槽位已被 Worker 自报占满
+	req := &core.InferenceRequest{TraceID: "test-slots-1", Model: "gemma-2b"}
+	selected, err := router.Select(context.Background(), workers, req)
+	if err != nil || selected.ID() != "local-small" {
+		t.Fatalf("expected gemma-2b on local-small, got %v, %v", selected, err)
+	}
+
+	req = &core.InferenceRequest{TraceID: "test-slots-2", Model: "llama-70b"}
+	selected, err = router.Select(context.Background(), workers, req)
+	if err != nil || selected.ID() != "local-big" {
+		t.Fatalf("expected llama-70b on local-big, got %v, %v", selected, err)
+	}
+
+	// 网关侧在途计数在下一次心跳前即生效
+	release := inflight.AcquireModel("local-big", "key-a", "llama-70b")
+	decision := router.Preview(context.Background(), workers, &core.InferenceRequest{TraceID: "test-slots-3", Model: "llama-70b"})
+	if decision.Selected != "" {
+		t.Errorf("expected the single llama-70b slot to be taken, got %s", decision.Selected)
+	}
+	for _, c := range decision.Candidates {
+		if c.WorkerID == "local-big" && c.Excluded != ReasonModelSlotsFull {
+			t.Errorf("expected %s exclusion, got %q", ReasonModelSlotsFull, c.Excluded)
+		}
+	}
+
+	release()
+	if _, err := router.Select(context.Background(), workers, &core.InferenceRequest{TraceID: "test-slots-4", Model: "llama-70b"}); err != nil {
+		t.Errorf("expected slot to be free after release, got %v", err)
+	}
+}
+
+// recordingObserver collects routing decisions for assertions
+type recordingObserver struct {
+	outcomes []string
+	reasons  map[string]int
+}
+
+func (o *recordingObserver) ObserveRouting(outcome string, excluded map[string]string) {
+	o.outcomes = append(o.outcomes, outcome)
+	for _, reason := range excluded {
+		o.reasons[reason]++
+	}
+}
+
+// TestScoreRouter_DecisionObserver tests that outcomes and exclusion reasons are reported
+func TestScoreRouter_DecisionObserver(t *testing.T) {
+	local := &mockWorker{
+		id: "local-4090",
+		profile: core.WorkerProfile{
+			WorkerID:      "local-4090",
+			Supported:     []string{"gemma-2b"},
+			TotalVRAM:     24 * 1024 * 1024 * 1024,
+			AvailableVRAM: 20 * 1024 * 1024 * 1024,
+			MaxTasks:      4,
+		},
+	}
+	fallback := &mockWorker{
+		id: "cloud-fallback",
+		profile: core.WorkerProfile{
+			WorkerID:  "cloud-fallback",
+			Supported: []string{"*"},
+			MaxTasks:  100,
+		},
+	}
+	observer := &recordingObserver{reasons: make(map[string]int)}
+	router := NewScoreRouter()
+	router.SetDecisionObserver(observer)
+
+	workers := []core.Worker{local, fallback}
+	router.Select(context.Background(), workers, &core.InferenceRequest{TraceID: "test-observe-1", Model: "gemma-2b"})
+	router.Select(context.Background(), workers, &core.InferenceRequest{TraceID: "test-observe-2", Model: "llama-70b"})
+	router.Select(context.Background(), []core.Worker{local}, &core.InferenceRequest{TraceID: "test-observe-3", Model: "llama-70b"})
+	router.Preview(context.Background(), workers, &core.InferenceRequest{TraceID: "test-observe-4", Model: "gemma-2b"})
+
+	want := []string{OutcomeLocal, OutcomeFallback, OutcomeNoWorkers}
+	if len(observer.outcomes) != len(want) {
+		t.Fatalf("expected outcomes %v, got %v", want, observer.outcomes)
+	}
+	for i := range want {
+		if observer.outcomes[i] != want[i] {
+			t.Errorf("decision %d: expected %s, got %s", i, want[i], observer.outcomes[i])
+		}
+	}
+	if observer.reasons[ReasonModelUnsupported] != 2 {
+		t.Errorf("expected 2 %s exclusions, got %v", ReasonModelUnsupported, observer.reasons)
+	}
+}
+
+// fixedRamp reports a constant share for ramping workers
+type fixedRamp map[string]float64
+
+func (f fixedRamp) Share(workerID string) float64 {
+	if share, ok := f[workerID]; ok {
+		return share
+	}
+	return 1
+}
+
+// TestScoreRouter_SlowStart tests that ramping workers get a reduced share but are never starved out
+func TestScoreRouter_SlowStart(t *testing.T) {
+	newWorker := func(id string, available uint64) *mockWorker {
+		return &mockWorker{
+			id: id,
+			profile: core.WorkerProfile{
+				WorkerID:      id,
+				Supported:     []string{"gemma-2b"},
+				TotalVRAM:     16 * 1024 * 1024 * 1024,
+				AvailableVRAM: available * 1024 * 1024 * 1024,
+				MaxTasks:      8,
+			},
+		}
+	}
+	rejoined := newWorker("local-rejoined", 16)
+	steady := newWorker("local-steady", 8)
+
+	router := NewScoreRouter()
+	router.SetRampSource(fixedRamp{"local-rejoined": 0.25})
+	coin := []float64{0.1, 0.5, 0.9, 0.3}
+	calls := 0
+	router.random = func() float64 {
+		v := coin[calls%len(coin)]
+		calls++
+		return v
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < len(coin); i++ {
+		selected, err := router.Select(context.Background(), []core.Worker{rejoined, steady}, &core.InferenceRequest{TraceID: "test-ramp", Model: "gemma-2b"})
+		if err != nil {
+			t.Fatalf("Select() error = %v", err)
+		}
+		counts[selected.ID()]++
+	}
+	if counts["local-rejoined"] != 1 || counts["local-steady"] != 3 {
+		t.Errorf("expected a 1:3 split while ramping, got %v", counts)
+	}
+
+	// 唯一的候选即使处于慢启动也不会被剔除
+	selected, err := router.Select(context.Background(), []core.Worker{rejoined}, &core.InferenceRequest{TraceID: "test-ramp-only", Model: "gemma-2b"})
+	if err != nil || selected.ID() != "local-rejoined" {
+		t.Errorf("expected the only candidate to be kept, got %v, %v", selected, err)
+	}
+}
+
+func TestScoreRouter_LowPriorityStaysLocal(t *testing.T) {
+	busy := &mockWorker{
+		id: "local-busy",
+		profile: core.WorkerProfile{
+			WorkerID:      "local-busy",
+			Supported:     []string{"gemma-2b"},
+			TotalVRAM:     16 * 1024 * 1024 * 1024,
+			AvailableVRAM: 14 * 1024 * 1024 * 1024,
+			ActiveTasks:   8,
+			MaxTasks:      8,
+		},
+	}
+	fallback := &mockWorker{id: "cloud-openai-fallback", profile: core.WorkerProfile{WorkerID: "cloud-openai-fallback", Supported: []string{"*"}}}
+	workers := []core.Worker{busy, fallback}
+	router := NewScoreRouter()
+
+	selected, err := router.Select(context.Background(), workers, &core.InferenceRequest{TraceID: "test-normal", Model: "gemma-2b"})
+	if err != nil || selected.ID() != "cloud-openai-fallback" {
+		t.Fatalf("normal priority should spill over to the fallback, got %v, %v", selected, err)
+	}
+
+	// 批量请求不溢出到云端，等待本地容量
+	if selected, err := router.Select(context.Background(), workers, &core.InferenceRequest{TraceID: "test-low", Model: "gemma-2b", Priority: core.PriorityLow}); err == nil {
+		t.Errorf("low priority should not spill over, got %s", selected.ID())
+	}
+}
+
+func TestScoreRouter_WorkerClass(t *testing.T) {
+	newWorker := func(id, class string, available uint64) *mockWorker {
+		return &mockWorker{
+			id: id,
+			profile: core.WorkerProfile{
+				WorkerID:      id,
+				Supported:     []string{"gemma-2b"},
+				TotalVRAM:     16 * 1024 * 1024 * 1024,
+				AvailableVRAM: available * 1024 * 1024 * 1024,
+				MaxTasks:      8,
+				Class:         class,
+			},
+		}
+	}
+	workers := []core.Worker{newWorker("local-ollama", "ollama", 14), newWorker("local-vllm", "vllm", 4)}
+	router := NewScoreRouter()
+
+	selected, err := router.Select(context.Background(), workers, &core.InferenceRequest{TraceID: "test-class", Model: "gemma-2b", WorkerClass: "vllm"})
+	if err != nil || selected.ID() != "local-vllm" {
+		t.Fatalf("expected the vllm worker, got %v, %v", selected, err)
+	}
+	if _, err := router.Select(context.Background(), workers, &core.InferenceRequest{TraceID: "test-class-none", Model: "gemma-2b", WorkerClass: "tgi"}); err == nil {
+		t.Error("expected an error when no worker has the class")
+	}
+}
+
+func TestLocalCapacity(t *testing.T) {
+	profiles := []core.WorkerProfile{
+		{WorkerID: "gpu-1", Supported: []string{"gemma-2b", "llama-70b"}, MaxTasks: 8, ActiveTasks: 3, ModelSlots: map[string]int{"llama-70b": 1}, ActiveByModel: map[string]int{"llama-70b": 1}},
+		{WorkerID: "gpu-2", Supported: []string{"gemma-2b"}, MaxTasks: 4, ActiveTasks: 6},
+		{WorkerID: "gpu-3", Supported: []string{"gemma-2b"}, MaxTasks: 4},
+		{WorkerID: "peer-b", Supported: []string{"gemma-2b"}, MaxTasks: 100, Peer: true},
+		{WorkerID: "cloud-fallback", Supported: []string{"gemma-2b"}, MaxTasks: 100},
+	}
+	available := func(workerID string) bool { return workerID != "gpu-3" }
+
+	capacity := LocalCapacity("gw-a", profiles, available, false)
+	if !capacity.Healthy || capacity.Workers != 2 || capacity.MaxTasks != 12 || capacity.FreeSlots != 5 || capacity.Weight != 5 {
+		t.Fatalf("capacity = %+v, want 2 healthy workers with 5 free slots", capacity)
+	}
+	if capacity.Models["gemma-2b"] != 5 || capacity.Models["llama-70b"] != 0 {
+		t.Errorf("models = %v, want gemma-2b:5 llama-70b:0", capacity.Models)
+	}
+
+	if browned := LocalCapacity("gw-a", profiles, available, true); browned.Weight != 0 || !browned.Healthy {
+		t.Errorf("brownout capacity = %+v, want weight 0", browned)
+	}
+	if empty := LocalCapacity("gw-a", nil, nil, false); empty.Healthy || empty.Weight != 0 {
+		t.Errorf("capacity without workers = %+v, want unhealthy", empty)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig("")
+	if err != nil || cfg != DefaultConfig() {
+		t.Fatalf("empty spec = %+v, %v, want defaults", cfg, err)
+	}
+
+	cfg, err = ParseConfig("load=2, cost=0.5,vram_headroom_gb=1.5,vram_headroom_ratio=0.1,kv_cache_saturation=0.9,max_load=0.8,degraded_penalty=0.3")
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	want := Config{
+		Weights:           Weights{VRAM: 1, Load: 2, Adapter: 1, Cost: 0.5},
+		VRAMHeadroom:      1536 * 1024 * 1024,
+		VRAMHeadroomRatio: 0.1,
+		KVCacheSaturation: 0.9,
+		MaxLoad:           0.8,
+		DegradedPenalty:   0.3,
+	}
+	if cfg != want {
+		t.Errorf("ParseConfig() = %+v, want %+v", cfg, want)
+	}
+
+	for _, spec := range []string{"load", "load=x", "load=-1", "vram=0,load=0,adapter=0", "max_load=0", "kv_cache_saturation=1.5", "vram_headroom_gb=-1", "unknown=1"} {
+		if _, err := ParseConfig(spec); err == nil {
+			t.Errorf("ParseConfig(%q) expected error", spec)
+		}
+	}
+	if _, err := NewScoreRouterWithConfig(Config{Weights: DefaultWeights()}); err == nil {
+		t.Error("expected error for a config without thresholds")
+	}
+}
+
+func TestScoreRouter_Config(t *testing.T) {
+	gb := uint64(1024 * 1024 * 1024)
+	required := estimateModelVRAM("gemma-2b")
+	newWorker := func(id string, available uint64, active int, kvCache float64) *mockWorker {
+		return &mockWorker{
+			id: id,
+			profile: core.WorkerProfile{
+				WorkerID:      id,
+				Supported:     []string{"gemma-2b"},
+				TotalVRAM:     16 * gb,
+				AvailableVRAM: available,
+				ActiveTasks:   active,
+				MaxTasks:      10,
+				KVCacheUsage:  kvCache,
+			},
+		}
+	}
+	req := &core.InferenceRequest{TraceID: "test-config", Model: "gemma-2b"}
+
+	// 刚好够用的 Worker 在默认配置下可选，预留余量后被剔除
+	tight := []core.Worker{newWorker("tight", required, 0, 0)}
+	if _, err := NewScoreRouter().Select(context.Background(), tight, req); err != nil {
+		t.Fatalf("expected the tight worker without headroom, got %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.VRAMHeadroom = gb
+	router, err := NewScoreRouterWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewScoreRouterWithConfig() error = %v", err)
+	}
+	if _, err := router.Select(context.Background(), tight, req); err == nil {
+		t.Error("expected the tight worker to be excluded by the VRAM headroom")
+	}
+
+	cfg = DefaultConfig()
+	cfg.MaxLoad = 0.8
+	cfg.KVCacheSaturation = 0.5
+	router, err = NewScoreRouterWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewScoreRouterWithConfig() error = %v", err)
+	}
+	workers := []core.Worker{
+		newWorker("busy", 14*gb, 8, 0),
+		newWorker("kv-full", 14*gb, 0, 0.6),
+		newWorker("ok", 4*gb, 5, 0.2),
+	}
+	selected, err := router.Select(context.Background(), workers, req)
+	if err != nil || selected.ID() != "ok" {
+		t.Fatalf("expected ok, got %v, %v", selected, err)
+	}
+	if got := router.Config(); got != cfg {
+		t.Errorf("Config() = %+v, want %+v", got, cfg)
+	}
+
+	// 运行时替换配置：放宽阈值后 kv-full 重新可选
+	relaxed := DefaultConfig()
+	relaxed.Weights = Weights{VRAM: 1}
+	if err := router.SetConfig(relaxed); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+	selected, err = router.Select(context.Background(), workers[1:2], req)
+	if err != nil || selected.ID() != "kv-full" {
+		t.Fatalf("expected kv-full after SetConfig, got %v, %v", selected, err)
+	}
+	if got := router.Config(); got != relaxed {
+		t.Errorf("Config() = %+v, want %+v", got, relaxed)
+	}
+	if err := router.SetConfig(Config{}); err == nil {
+		t.Error("expected SetConfig to reject an invalid config")
+	}
+	if got := router.Config(); got != relaxed {
+		t.Errorf("expected a rejected config to leave %+v in place, got %+v", relaxed, got)
+	}
+}
+
+func TestScoreRouter_ExplicitFallback(t *testing.T) {
+	newWorker := func(id string, fallback bool, priority int) *mockWorker {
+		return &mockWorker{
+			id: id,
+			profile: core.WorkerProfile{
+				WorkerID:   id,
+				Supported:  []string{"*"},
+				MaxTasks:   100,
+				IsFallback: fallback,
+				Priority:   priority,
+			},
+		}
+	}
+	workers := []core.Worker{
+		newWorker("openai", true, 2),
+		newWorker("azure", true, 1),
+		newWorker("legacy-cloud", false, 0),
+	}
+	req := &core.InferenceRequest{TraceID: "test-explicit-fallback", Model: "llama-70b"}
+
+	selected, err := NewScoreRouter().Select(context.Background(), workers, req)
+	if err != nil || selected.ID() != "openai" {
+		t.Fatalf("expected the highest priority fallback, got %v, %v", selected, err)
+	}
+	if !req.Fallback {
+		t.Error("expected the request to be marked as fallback")
+	}
+
+	// An on-prem worker whose ID merely contains "cloud" is a regular candidate
+	onPrem := &mockWorker{
+		id: "cloudlab-3090",
+		profile: core.WorkerProfile{
+			WorkerID:      "cloudlab-3090",
+			Supported:     []string{"gemma-2b"},
+			TotalVRAM:     24 * 1024 * 1024 * 1024,
+			AvailableVRAM: 20 * 1024 * 1024 * 1024,
+			MaxTasks:      4,
+		},
+	}
+	req = &core.InferenceRequest{TraceID: "test-cloudlab", Model: "gemma-2b"}
+	selected, err = NewScoreRouter().Select(context.Background(), []core.Worker{onPrem, workers[0]}, req)
+	if err != nil || selected.ID() != "cloudlab-3090" || req.Fallback {
+		t.Fatalf("expected the on-prem worker, got %v, %v (fallback %v)", selected, err, req.Fallback)
+	}
+}
+
+func TestLoadModelProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	os.WriteFile(path, []byte(`{"Llama-3-70B-Q4": {"vram_gb": 40, "kv_cache_kb_per_token": 320, "context_length": 8192, "quantization": "q4_k_m"}}`), 0o644)
+	profiles, err := LoadModelProfiles(path)
+	if err != nil {
+		t.Fatalf("LoadModelProfiles() error = %v", err)
+	}
+	if p := profiles["llama-3-70b-q4"]; p.VRAMGB != 40 || p.ContextLength != 8192 || p.Quantization != "q4_k_m" {
+		t.Errorf("unexpected profile %+v", p)
+	}
+
+	for _, body := range []string{`{"m": {}}`, `{"m": {"vram_gb": 4, "context_length": -1}}`, `[]`} {
+		os.WriteFile(path, []byte(body), 0o644)
+		if _, err := LoadModelProfiles(path); err == nil {
+			t.Errorf("LoadModelProfiles(%s) expected error", body)
+		}
+	}
+}
+
+func TestScoreRouter_ModelProfiles(t *testing.T) {
+	gb := uint64(1024 * 1024 * 1024)
+	newWorker := func(id string, available uint64, maxContext int) *mockWorker {
+		return &mockWorker{
+			id: id,
+			profile: core.WorkerProfile{
+				WorkerID:      id,
+				Supported:     []string{"mixtral"},
+				TotalVRAM:     48 * gb,
+				AvailableVRAM: available,
+				MaxTasks:      4,
+				Capabilities:  core.Capabilities{MaxContext: maxContext},
+			},
+		}
+	}
+	req := &core.InferenceRequest{TraceID: "test-model-profiles", Model: "mixtral"}
+	small := []core.Worker{newWorker("small", 8*gb, 0)}
+
+	// 名称中没有参数量时按小模型估算
+	router := NewScoreRouter()
+	if _, err := router.Select(context.Background(), small, req); err != nil {
+		t.Fatalf("expected the name heuristic to admit the small worker, got %v", err)
+	}
+
+	// 目录中的显存需求优先于名称估算
+	if err := router.SetModelProfile("Mixtral", ModelProfile{VRAMGB: 26, ContextLength: 32768}); err != nil {
+		t.Fatalf("SetModelProfile() error = %v", err)
+	}
+	if _, err := router.Select(context.Background(), small, req); err == nil {
+		t.Error("expected the small worker to lack VRAM for the profiled model")
+	}
+	big := newWorker("big", 30*gb, 0)
+	if selected, err := router.Select(context.Background(), []core.Worker{small[0], big}, req); err != nil || selected.ID() != "big" {
+		t.Fatalf("expected the big worker, got %v, %v", selected, err)
+	}
+
+	// 每 Token KV Cache 按目录计算
+	router.SetModelProfile("mixtral", ModelProfile{VRAMGB: 26, KVCacheKBPerToken: 1024})
+	req.PromptTokens = 5 * 1024
+	if _, err := router.Select(context.Background(), []core.Worker{big}, req); err == nil {
+		t.Error("expected 5GB of KV-cache to exceed the big worker's free VRAM")
+	}
+	req.PromptTokens = 0
+
+	// 未上报上下文长度的 Worker 按目录中的上下文长度过滤，自行上报的以上报为准
+	router.SetModelProfile("mixtral", ModelProfile{VRAMGB: 26, ContextLength: 4096})
+	req.Needs = core.Capabilities{MaxContext: 8192}
+	if _, err := router.Select(context.Background(), []core.Worker{big}, req); err == nil {
+		t.Error("expected the model context length to exclude the request")
+	}
+	if _, err := router.Select(context.Background(), []core.Worker{newWorker("long", 30*gb, 16384)}, req); err != nil {
+		t.Errorf("expected the worker's own context length to win, got %v", err)
+	}
+
+	if err := router.SetModelProfile("mixtral", ModelProfile{}); err == nil {
+		t.Error("expected a profile without VRAM to be rejected")
+	}
+	if !router.DeleteModelProfile("MIXTRAL") || router.DeleteModelProfile("mixtral") {
+		t.Error("expected the profile to be deleted exactly once")
+	}
+	if len(router.ModelProfiles()) != 0 {
+		t.Errorf("expected no profiles left, got %v", router.ModelProfiles())
+	}
+}
+
+// cachedProfiles implements core.ProfileSource for testing
+type cachedProfiles map[string]core.WorkerProfile
+
+func (p cachedProfiles) Profile(workerID string) (core.WorkerProfile, bool) {
+	profile, ok := p[workerID]
+	return profile, ok
+}
+
+// staticCounts implements WorkerCounter for testing
+type staticCounts map[string]int
+
+func (c staticCounts) WorkerCount(workerID string) int {
+	return c[workerID]
+}
+
+func TestScoreRouter_ProfileSource(t *testing.T) {
+	gb := uint64(1024 * 1024 * 1024)
+	profile := func(id string, active int) core.WorkerProfile {
+		return core.WorkerProfile{WorkerID: id, Supported: []string{"llama-7b"}, TotalVRAM: 24 * gb, AvailableVRAM: 20 * gb, ActiveTasks: active, MaxTasks: 4}
+	}
+	// 实时心跳会失败：路由只能依赖缓存的画像
+	workers := []core.Worker{
+		&mockWorker{id: "a", heartbeatErr: errors.New("unreachable")},
+		&mockWorker{id: "b", heartbeatErr: errors.New("unreachable")},
+		&mockWorker{id: "unknown", profile: profile("unknown", 0)},
+	}
+	req := &core.InferenceRequest{TraceID: "test-profile-source", Model: "llama-7b"}
+
+	router := NewScoreRouter()
+	router.SetProfileSource(cachedProfiles{"a": profile("a", 0), "b": profile("b", 2)})
+	selected, err := router.Select(context.Background(), workers, req)
+	if err != nil || selected.ID() != "a" {
+		t.Fatalf("expected the idle cached worker a, got %v, %v", selected, err)
+	}
+	decision := router.Preview(context.Background(), workers, req)
+	for _, c := range decision.Candidates {
+		if c.WorkerID == "unknown" && c.Excluded != ReasonHeartbeatError {
+			t.Errorf("expected the worker without a cached profile to be excluded, got %+v", c)
+		}
+	}
+
+	// 两次心跳之间，网关侧在途请求计入缓存画像的负载
+	router.SetWorkerCounter(staticCounts{"a": 3})
+	if selected, err := router.Select(context.Background(), workers, req); err != nil || selected.ID() != "b" {
+		t.Errorf("expected in-flight requests to shift load to b, got %v, %v", selected, err)
+	}
+}
+
+func TestScoreRouter_PriorityReserve(t *testing.T) {
+	cfg, err := ParseConfig("priority_reserve=0.25")
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	if _, err := ParseConfig("priority_reserve=1"); err == nil {
+		t.Error("expected a reserve of every slot to be rejected")
+	}
+	router, _ := NewScoreRouterWithConfig(cfg)
+	workers := []core.Worker{&mockWorker{id: "gpu-1", profile: core.WorkerProfile{
+		WorkerID: "gpu-1", Supported: []string{"llama-7b"}, TotalVRAM: 24 << 30, AvailableVRAM: 20 << 30, ActiveTasks: 3, MaxTasks: 4,
+	}}}
+
+	// 最后一个槽位只留给高优先级请求
+	req := &core.InferenceRequest{TraceID: "test-priority-reserve", Model: "llama-7b"}
+	if _, err := router.Select(context.Background(), workers, req); err == nil {
+		t.Error("expected a normal request to be kept out of the reserved slot")
+	}
+	if decision := router.Preview(context.Background(), workers, req); len(decision.Candidates) != 1 || decision.Candidates[0].Excluded != ReasonReservedCapacity {
+		t.Errorf("candidates = %+v, want gpu-1 excluded as %s", decision.Candidates, ReasonReservedCapacity)
+	}
+	req.Priority = core.PriorityHigh
+	if selected, err := router.Select(context.Background(), workers, req); err != nil || selected.ID() != "gpu-1" {
+		t.Errorf("expected a high-priority request to use the reserved slot, got %v, %v", selected, err)
+	}
+}
+
+// TestScoreRouter_SessionAffinity tests that a session stays on its worker while it has capacity
+func TestScoreRouter_SessionAffinity(t *testing.T) {
+	gb := uint64(1024 * 1024 * 1024)
+	newWorker := func(id string, available uint64) *mockWorker {
+		return &mockWorker{
+			id: id,
+			profile: core.WorkerProfile{
+				WorkerID:      id,
+				Supported:     []string{"gemma-2b"},
+				TotalVRAM:     16 * gb,
+				AvailableVRAM: available * gb,
+				MaxTasks:      2,
+			},
+		}
+	}
+	a, b := newWorker("local-a", 8), newWorker("local-b", 14)
+	workers := []core.Worker{a, b}
+
+	affinity := core.NewSessionAffinity(core.AffinityHeader, core.NewMemorySessionStore(core.SessionLimits{}), 0)
+	router := NewScoreRouter()
+	router.SetSessionAffinity(affinity)
+	ctx := context.Background()
+	session := affinity.Key("key-a", "chat-1", "gemma-2b", nil)
+
+	// 首轮按评分选择并绑定会话
+	selected, err := router.Select(ctx, workers, &core.InferenceRequest{Tenant: "key-a", Model: "gemma-2b", Session: session})
+	if err != nil || selected.ID() != "local-b" {
+		t.Fatalf("Select() = %v, %v; want local-b", selected, err)
+	}
+
+	// 后续轮次即使评分更低也留在原 Worker
+	b.profile.AvailableVRAM = 4 * gb
+	selected, _ = router.Select(ctx, workers, &core.InferenceRequest{Tenant: "key-a", Model: "gemma-2b", Session: session})
+	if selected.ID() != "local-b" {
+		t.Errorf("expected the session to stay on local-b, got %s", selected.ID())
+	}
+	selected, _ = router.Select(ctx, workers, &core.InferenceRequest{Tenant: "key-a", Model: "gemma-2b"})
+	if selected.ID() != "local-a" {
+		t.Errorf("expected requests without a session to be scored, got %s", selected.ID())
+	}
+
+	// 绑定的 Worker 满载时改选其他 Worker 并重新绑定
+	b.profile.ActiveTasks = 2
+	selected, _ = router.Select(ctx, workers, &core.InferenceRequest{Tenant: "key-a", Model: "gemma-2b", Session: session})
+	if selected.ID() != "local-a" {
+		t.Fatalf("expected a full worker to be skipped, got %s", selected.ID())
+	}
+	if got := affinity.Worker(ctx, session); got != "local-a" {
+		t.Errorf("expected the session to be rebound to local-a, got %q", got)
+	}
+}
+
+// TestScoreRouter_Strategies tests the built-in strategies and per-request strategy selection
+func TestScoreRouter_Strategies(t *testing.T) {
+	gb := uint64(1024 * 1024 * 1024)
+	newWorker := func(id string, available uint64, active int) *mockWorker {
+		return &mockWorker{
+			id: id,
+			profile: core.WorkerProfile{
+				WorkerID:      id,
+				Supported:     []string{"gemma-2b"},
+				TotalVRAM:     16 * gb,
+				AvailableVRAM: available * gb,
+				ActiveTasks:   active,
+				MaxTasks:      4,
+			},
+		}
+	}
+	// local-a 显存最多但负载最高，local-c 负载最低
+	workers := []core.Worker{newWorker("local-c", 4, 0), newWorker("local-a", 15, 3), newWorker("local-b", 8, 1)}
+	router := NewScoreRouter()
+	router.SetWeights(Weights{VRAM: 1})
+	ctx := context.Background()
+	selectWith := func(strategy, session string) string {
+		selected, err := router.Select(ctx, workers, &core.InferenceRequest{Tenant: "key-a", Model: "gemma-2b", Strategy: strategy, Session: session})
+		if err != nil {
+			t.Fatalf("Select(%s) error = %v", strategy, err)
+		}
+		return selected.ID()
+	}
+
+	if got := selectWith("", ""); got != "local-a" {
+		t.Errorf("score strategy selected %s, want local-a", got)
+	}
+	if got := selectWith(StrategyLeastLoaded, ""); got != "local-c" {
+		t.Errorf("least_loaded selected %s, want local-c", got)
+	}
+	var rotation []string
+	for i := 0; i < 4; i++ {
+		rotation = append(rotation, selectWith(StrategyRoundRobin, ""))
+	}
+	if strings.Join(rotation, ",") != "local-a,local-b,local-c,local-a" {
+		t.Errorf("round_robin rotation = %v", rotation)
+	}
+	first := selectWith(StrategyConsistentHash, "chat-1")
+	for i := 0; i < 5; i++ {
+		if got := selectWith(StrategyConsistentHash, "chat-1"); got != first {
+			t.Fatalf("consistent_hash moved session from %s to %s", first, got)
+		}
+	}
+
+	// 默认策略与自定义策略
+	if err := router.SetDefaultStrategy("fastest"); err == nil {
+		t.Error("expected an unknown default strategy to be rejected")
+	}
+	router.RegisterStrategy("last", StrategyFunc(func(_ *core.InferenceRequest, candidates []Candidate) int { return len(candidates) - 1 }))
+	if err := router.SetDefaultStrategy("last"); err != nil {
+		t.Fatalf("SetDefaultStrategy() error = %v", err)
+	}
+	if got := selectWith("", ""); got != "local-b" {
+		t.Errorf("custom default strategy selected %s, want local-b", got)
+	}
+	if !router.HasStrategy(StrategyRandom) || router.HasStrategy("fastest") {
+		t.Errorf("unexpected registered strategies %v", router.Strategies())
+	}
+}
+
+// fixedLatencies serves preset worker latencies
+type fixedLatencies map[string]core.WorkerLatency
+
+func (f fixedLatencies) Latency(workerID string) (core.WorkerLatency, bool) {
+	l, ok := f[workerID]
+	return l, ok
+}
+
+// TestScoreRouter_LatencyScore tests that a slowed-down worker loses traffic to faster ones
+func TestScoreRouter_LatencyScore(t *testing.T) {
+	gb := uint64(1024 * 1024 * 1024)
+	newWorker := func(id string, available uint64) *mockWorker {
+		return &mockWorker{
+			id: id,
+			profile: core.WorkerProfile{
+				WorkerID:      id,
+				Supported:     []string{"gemma-2b"},
+				TotalVRAM:     16 * gb,
+				AvailableVRAM: available * gb,
+				MaxTasks:      4,
+			},
+		}
+	}
+	workers := []core.Worker{newWorker("local-hot", 12), newWorker("local-cool", 10), newWorker("local-new", 8)}
+	router := NewScoreRouter()
+	router.SetWeights(Weights{VRAM: 1, Latency: 1})
+	req := func() *core.InferenceRequest { return &core.InferenceRequest{Model: "gemma-2b"} }
+
+	// 没有延迟数据时按显存选择
+	if selected, _ := router.Select(context.Background(), workers, req()); selected.ID() != "local-hot" {
+		t.Fatalf("expected local-hot without latency data, got %s", selected.ID())
+	}
+
+	// 热降频的 Worker 首 token 与完成延迟翻倍，流量转向较快的 Worker
+	router.SetLatencySource(fixedLatencies{
+		"local-hot":  {TTFT: 400 * time.Millisecond, Completion: 8 * time.Second, Samples: 20},
+		"local-cool": {TTFT: 200 * time.Millisecond, Completion: 4 * time.Second, Samples: 20},
+	})
+	selected, err := router.Select(context.Background(), workers, req())
+	if err != nil || selected.ID() != "local-cool" {
+		t.Errorf("expected the faster worker local-cool, got %v (%v)", selected, err)
+	}
+
+	// 尚无样本的 Worker 记满分，从而获得流量并被测量
+	router.SetWeights(Weights{Latency: 1})
+	if selected, _ := router.Select(context.Background(), workers, req()); selected.ID() != "local-cool" {
+		t.Errorf("expected the first of the top-scored workers, got %s", selected.ID())
+	}
+	workers = []core.Worker{workers[2], workers[0]}
+	if selected, _ := router.Select(context.Background(), workers, req()); selected.ID() != "local-new" {
+		t.Errorf("expected an unmeasured worker to be preferred over a slow one, got %s", selected.ID())
+	}
+}
+
+// TestScoreRouter_CostScore tests that the cost weight prefers cheaper workers
+func TestScoreRouter_CostScore(t *testing.T) {
+	gb := uint64(1024 * 1024 * 1024)
+	newWorker := func(id string, costPer1K float64) *mockWorker {
+		return &mockWorker{
+			id: id,
+			profile: core.WorkerProfile{
+				WorkerID:        id,
+				Supported:       []string{"gemma-2b"},
+				TotalVRAM:       16 * gb,
+				AvailableVRAM:   12 * gb,
+				MaxTasks:        4,
+				CostPer1KTokens: costPer1K,
+			},
+		}
+	}
+	workers := []core.Worker{newWorker("rented-a100", 0.004), newWorker("rented-l4", 0.001)}
+	router := NewScoreRouter()
+	router.SetWeights(Weights{VRAM: 1, Load: 1, Cost: 1})
+
+	// 其他条件相同时选择更便宜的 Worker
+	selected, err := router.Select(context.Background(), workers, &core.InferenceRequest{Model: "gemma-2b"})
+	if err != nil || selected.ID() != "rented-l4" {
+		t.Errorf("expected the cheaper worker rented-l4, got %v (%v)", selected, err)
+	}
+
+	// 只配置 cost 权重时按价格排序，未定价的 Worker 视为免费
+	if err := router.SetWeights(Weights{Cost: 1}); err != nil {
+		t.Fatalf("SetWeights() error = %v", err)
+	}
+	workers = append(workers, newWorker("local-4090", 0))
+	selected, err = router.Select(context.Background(), workers, &core.InferenceRequest{Model: "gemma-2b"})
+	if err != nil || selected.ID() != "local-4090" {
+		t.Errorf("expected the unpriced worker local-4090 with only a cost weight, got %v (%v)", selected, err)
+	}
+}
+
+// TestScoreRouter_FallbackBudget tests that an exhausted fallback budget stops overflow to the cloud
+func TestScoreRouter_FallbackBudget(t *testing.T) {
+	workers := []core.Worker{
+		&mockWorker{
+			id: "local-2060",
+			profile: core.WorkerProfile{
+				WorkerID:      "local-2060",
+				Supported:     []string{"llama-8b"},
+				TotalVRAM:     6 * 1024 * 1024 * 1024,
+				AvailableVRAM: 6 * 1024 * 1024 * 1024,
+				ActiveTasks:   1,
+				MaxTasks:      1,
+			},
+		},
+		&mockWorker{
+			id: "gpt4",
+			profile: core.WorkerProfile{
+				WorkerID:        "gpt4",
+				Supported:       []string{"*"},
+				MaxTasks:        100,
+				IsFallback:      true,
+				CostPer1KTokens: 0.03,
+			},
+		},
+	}
+	budget := core.NewFallbackBudget(1, 0, nil)
+	router := NewScoreRouter()
+	router.SetFallbackBudget(budget)
+
+	selected, err := router.Select(context.Background(), workers, &core.InferenceRequest{Model: "llama-8b"})
+	if err != nil || selected.ID() != "gpt4" {
+		t.Fatalf("expected overflow to the fallback within budget, got %v (%v)", selected, err)
+	}
+
+	budget.Charge(1)
+	req := &core.InferenceRequest{Model: "llama-8b"}
+	_, err = router.Select(context.Background(), workers, req)
+	if !errors.Is(err, core.ErrFallbackBudgetExceeded) || req.Fallback {
+		t.Errorf("expected a fallback budget error once the cap is spent, got %v", err)
+	}
+}
+
+// fixedBreakers reports preset breaker states
+type fixedBreakers map[string]core.BreakerState
+
+func (f fixedBreakers) State(workerID string) core.BreakerState {
+	return f[workerID]
+}
+
+// TestScoreRouter_FallbackOrder tests that fallbacks are used by priority, skipping unusable ones
+func TestScoreRouter_FallbackOrder(t *testing.T) {
+	newFallback := func(id string, priority int, supported ...string) *mockWorker {
+		return &mockWorker{
+			id: id,
+			profile: core.WorkerProfile{
+				WorkerID:   id,
+				Supported:  supported,
+				IsFallback: true,
+				Priority:   priority,
+			},
+		}
+	}
+	full := newFallback("azure", 5, "*")
+	full.profile.ActiveTasks, full.profile.MaxTasks = 10, 10
+	workers := []core.Worker{
+		newFallback("anthropic", 1, "*"),
+		newFallback("openai", 10, "*"),
+		newFallback("gemini", 7, "gemma-2b"),
+		full,
+		newFallback("together", 1, "*"),
+	}
+	router := NewScoreRouter()
+	selectID := func() string {
+		req := &core.InferenceRequest{Model: "llama-8b"}
+		selected, err := router.Select(context.Background(), workers, req)
+		if err != nil || !req.Fallback {
+			t.Fatalf("Select() = %v, %v; want a fallback", selected, err)
+		}
+		return selected.ID()
+	}
+
+	if got := selectID(); got != "openai" {
+		t.Errorf("expected the highest-priority fallback, got %s", got)
+	}
+	// openai 熔断后跳过不支持模型的 gemini 与满载的 azure，同优先级按注册顺序
+	router.SetBreakerSource(fixedBreakers{"openai": core.BreakerOpen})
+	if got := selectID(); got != "anthropic" {
+		t.Errorf("expected the next usable fallback anthropic, got %s", got)
+	}
+
+	decision := router.Preview(context.Background(), workers, &core.InferenceRequest{Model: "llama-8b"})
+	var order []string
+	excluded := map[string]string{}
+	for _, c := range decision.Candidates {
+		if c.Fallback {
+			order = append(order, c.WorkerID)
+		}
+		if c.Excluded != "" {
+			excluded[c.WorkerID] = c.Excluded
+		}
+	}
+	if strings.Join(order, ",") != "anthropic,together" {
+		t.Errorf("preview fallback order = %v", order)
+	}
+	if excluded["openai"] != ReasonQuarantined || excluded["gemini"] != ReasonModelUnsupported || excluded["azure"] != ReasonAtCapacity {
+		t.Errorf("unexpected fallback exclusions %v", excluded)
+	}
+}
@@ -0,0 +1,52 @@
+package router
+
+import (
+	"strings"
+
+	"zam/core"
+)
+
+// ModelCounter reports how many requests for a model the gateway currently has in flight on a worker
+type ModelCounter interface {
+	ModelCount(workerID, model string) int
+}
+
+// SetModelCounter adds the gateway's own in-flight accounting to per-model slot checks,
+// so slots are respected between two heartbeats
+func (r *ScoreRouter) SetModelCounter(counter ModelCounter) {
+	r.models = counter
+}
+
+// modelSlotsFull reports whether any of the models has no free concurrency slot on the worker
+// The busier of the worker-reported and gateway-tracked counts is used
+func (r *ScoreRouter) modelSlotsFull(workerID string, profile core.WorkerProfile, models []string) bool {
+	for _, model := range models {
+		slots, ok := lookupModel(profile.ModelSlots, model)
+		if !ok {
+			continue
+		}
+		active, _ := lookupModel(profile.ActiveByModel, model)
+		if r.models != nil {
+			if n := r.models.ModelCount(workerID, model); n > active {
+				active = n
+			}
+		}
+		if active >= slots {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupModel finds a per-model value, matching names case-insensitively like model support
+func lookupModel(values map[string]int, model string) (int, bool) {
+	if v, ok := values[model]; ok {
+		return v, true
+	}
+	for name, v := range values {
+		if strings.EqualFold(name, model) {
+			return v, true
+		}
+	}
+	return 0, false
+}
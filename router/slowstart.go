@@ -0,0 +1,39 @@
+package router
+
+import (
+	"math/rand"
+)
+
+// RampSource reports the traffic share of workers ramping up after rejoining, in (0, 1]
+type RampSource interface {
+	Share(workerID string) float64
+}
+
+// SetRampSource enables slow-start for rejoining workers
+func (r *ScoreRouter) SetRampSource(ramp RampSource) {
+	r.ramp = ramp
+}
+
+// rampDown drops each ramping candidate with probability 1 - share, so over many requests it
+// receives roughly its share of the traffic it would otherwise win
+// Candidates are never all dropped: a ramping worker still beats falling back
+func (r *ScoreRouter) rampDown(candidates []workerScore) []workerScore {
+	if r.ramp == nil {
+		return candidates
+	}
+	random := r.random
+	if random == nil {
+		random = rand.Float64
+	}
+
+	kept := make([]workerScore, 0, len(candidates))
+	for _, c := range candidates {
+		if share := r.ramp.Share(c.worker.ID()); share >= 1 || random() < share {
+			kept = append(kept, c)
+		}
+	}
+	if len(kept) == 0 {
+		return candidates
+	}
+	return kept
+}
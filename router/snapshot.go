@@ -0,0 +1,49 @@
+package router
+
+import (
+	"context"
+
+	"zam/core"
+)
+
+// WorkerCounter reports how many requests the gateway currently has in flight on a worker
+type WorkerCounter interface {
+	WorkerCount(workerID string) int
+}
+
+// SetProfileSource makes Select and Preview route on cached profile snapshots instead of calling
+// Heartbeat on every worker for every request. Workers without a cached profile are excluded
+func (r *ScoreRouter) SetProfileSource(source core.ProfileSource) {
+	r.snapshots = source
+}
+
+// SetWorkerCounter adds the gateway's own in-flight accounting to the cached load of each worker,
+// so requests arriving between two heartbeats do not all pile onto the same worker
+func (r *ScoreRouter) SetWorkerCounter(counter WorkerCounter) {
+	r.load = counter
+}
+
+// probeWorkers pairs every worker with its profile: the cached snapshot when a ProfileSource is set,
+// otherwise a live Heartbeat call
+func (r *ScoreRouter) probeWorkers(ctx context.Context, workers []core.Worker) []probedWorker {
+	if r.snapshots == nil {
+		return probeWorkers(ctx, workers)
+	}
+
+	probed := make([]probedWorker, 0, len(workers))
+	for _, worker := range workers {
+		profile, ok := r.snapshots.Profile(worker.ID())
+		if !ok {
+			probed = append(probed, probedWorker{worker: worker, err: core.ErrWorkerNotFound})
+			continue
+		}
+		// The busier of the worker-reported and gateway-tracked counts is used
+		if r.load != nil {
+			if n := r.load.WorkerCount(worker.ID()); n > profile.ActiveTasks {
+				profile.ActiveTasks = n
+			}
+		}
+		probed = append(probed, probedWorker{worker: worker, profile: profile})
+	}
+	return probed
+}
@@ -0,0 +1,131 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+
+	"zam/core"
+)
+
+// SpeculativePair declares a draft/verify model pair that clients address by a single model name
+type SpeculativePair struct {
+	// Name is the client-facing model name
+	Name string
+	// DraftModel is the small model proposing tokens
+	DraftModel string
+	// TargetModel is the large model verifying the proposals
+	TargetModel string
+}
+
+// SetSpeculativePairs replaces the configured speculative decoding pairs
+// Select reads the pairs without locking, so they cannot be replaced while requests are routed
+func (r *ScoreRouter) SetSpeculativePairs(pairs []SpeculativePair) {
+	r.pairs = make(map[string]SpeculativePair, len(pairs))
+	for _, pair := range pairs {
+		r.pairs[strings.ToLower(pair.Name)] = pair
+	}
+}
+
+// speculativePair looks up the pair exposed under the given model name
+func (r *ScoreRouter) speculativePair(model string) (SpeculativePair, bool) {
+	pair, ok := r.pairs[strings.ToLower(model)]
+	return pair, ok
+}
+
+// requestPair returns the pair a request addresses. selectSpeculative rewrites Model to the target,
+// so a request selected again (queued, or failing over) is matched by the name the client sent
+func (r *ScoreRouter) requestPair(req *core.InferenceRequest) (SpeculativePair, bool) {
+	if pair, ok := r.speculativePair(req.Model); ok {
+		return pair, true
+	}
+	pair, ok := r.speculativePair(req.RequestedModel)
+	return pair, ok && pair.TargetModel == req.Model
+}
+
+// selectSpeculative routes a draft/verify pair as a unit:
+//  1. a single worker serving both models (with VRAM for both) is preferred
+//  2. otherwise the target runs on the best big worker and the draft on the best other worker
+//  3. without a draft worker the target runs alone; without a target worker the fallback serves it
+func (r *ScoreRouter) selectSpeculative(probed []probedWorker, req *core.InferenceRequest, pair SpeculativePair) (core.Worker, error) {
+	// 下游只认识真实的 Target 模型，对客户端仍回显 pair 名称
+	req.Model = pair.TargetModel
+	req.Speculative = nil
+
+	// 投机解码时 Draft 与 Target 各自持有一份 KV-Cache
+	targetVRAM := r.requiredVRAM(pair.TargetModel, req.PromptTokens)
+	draftVRAM := r.requiredVRAM(pair.DraftModel, req.PromptTokens)
+
+	// Phase 1: co-located draft + target on one worker
+	colocated := r.collectCandidates(probed, []string{pair.DraftModel, pair.TargetModel}, targetVRAM+draftVRAM, "", req.Needs, req.Priority)
+	if len(colocated.candidates) > 0 {
+		best := selectBestWorker(r.preferLocal(r.rampDown(colocated.candidates)), r.Weights())
+		req.Speculative = &core.SpeculativePlan{
+			DraftModel:    pair.DraftModel,
+			DraftWorkerID: best.worker.ID(),
+		}
+		return best.worker, nil
+	}
+
+	// Phase 2: target on the best worker that can host it
+	targets := r.collectCandidates(probed, []string{pair.TargetModel}, targetVRAM, "", req.Needs, req.Priority).candidates
+	if len(targets) == 0 {
+		if fallback := colocated.fallback(); fallback != nil {
+			if err := r.budget.Check(); err != nil {
+				return nil, err
+			}
+			req.Fallback = true
+			return fallback, nil
+		}
+		return nil, fmt.Errorf("%w for speculative pair %s", core.ErrNoAvailableWorkers, pair.Name)
+	}
+	target := selectBestWorker(r.spreadTenant(r.preferLocal(r.rampDown(targets)), req.Tenant), r.Weights())
+
+	// Phase 3: draft on a different worker; run the target alone if none is free
+	// The draft only proposes tokens, so it needs none of the request's capabilities
+	var drafts []workerScore
+	for _, c := range r.collectCandidates(probed, []string{pair.DraftModel}, draftVRAM, "", core.Capabilities{}, req.Priority).candidates {
+		if c.worker.ID() != target.worker.ID() {
+			drafts = append(drafts, c)
+		}
+	}
+	if len(drafts) > 0 {
+		draft := selectBestWorker(r.preferLocal(drafts), r.Weights())
+		req.Speculative = &core.SpeculativePlan{
+			DraftModel:    pair.DraftModel,
+			DraftWorkerID: draft.worker.ID(),
+		}
+	}
+
+	return target.worker, nil
+}
+
+// ParseSpeculativePairs parses a pair list of the form "name=draft+target;name2=draft2+target2"
+func ParseSpeculativePairs(spec string) ([]SpeculativePair, error) {
+	var pairs []SpeculativePair
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, models, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid speculative pair %q: expected name=draft+target", entry)
+		}
+		draft, target, ok := strings.Cut(models, "+")
+		if !ok {
+			return nil, fmt.Errorf("invalid speculative pair %q: expected name=draft+target", entry)
+		}
+
+		pair := SpeculativePair{
+			Name:        strings.TrimSpace(name),
+			DraftModel:  strings.TrimSpace(draft),
+			TargetModel: strings.TrimSpace(target),
+		}
+		if pair.Name == "" || pair.DraftModel == "" || pair.TargetModel == "" {
+			return nil, fmt.Errorf("invalid speculative pair %q: empty name or model", entry)
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, nil
+}
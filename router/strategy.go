@@ -0,0 +1,181 @@
+package router
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"zam/core"
+)
+
+// Built-in routing strategies
+const (
+	// StrategyScore picks the candidate with the best weighted VRAM/load/adapter score (the default)
+	StrategyScore = "score"
+	// StrategyRoundRobin cycles through the candidates in worker ID order
+	StrategyRoundRobin = "round_robin"
+	// StrategyLeastLoaded picks the candidate with the smallest share of its slots in use
+	StrategyLeastLoaded = "least_loaded"
+	// StrategyRandom picks a candidate uniformly at random
+	StrategyRandom = "random"
+	// StrategyConsistentHash maps each session (or tenant) to the same candidate while the pool is unchanged
+	StrategyConsistentHash = "consistent_hash"
+)
+
+// Candidate is a worker that passed the hard filters, offered to a Strategy
+type Candidate struct {
+	Worker  core.Worker
+	Profile core.WorkerProfile
+	// Score is the worker's weighted score under the current weights
+	Score float64
+}
+
+// Strategy places a request on one of the candidates that passed the hard filters
+// Pick returns the index of the chosen candidate; candidates is never empty.
+// Implementations must be safe for concurrent use
+type Strategy interface {
+	Pick(req *core.InferenceRequest, candidates []Candidate) int
+}
+
+// StrategyFunc adapts a function to the Strategy interface
+type StrategyFunc func(req *core.InferenceRequest, candidates []Candidate) int
+
+func (f StrategyFunc) Pick(req *core.InferenceRequest, candidates []Candidate) int {
+	return f(req, candidates)
+}
+
+// builtinStrategies returns a fresh set of the built-in strategies
+func builtinStrategies() map[string]Strategy {
+	return map[string]Strategy{
+		StrategyScore:          StrategyFunc(pickBestScore),
+		StrategyRoundRobin:     &roundRobin{},
+		StrategyLeastLoaded:    StrategyFunc(pickLeastLoaded),
+		StrategyRandom:         StrategyFunc(func(_ *core.InferenceRequest, candidates []Candidate) int { return rand.Intn(len(candidates)) }),
+		StrategyConsistentHash: StrategyFunc(pickConsistentHash),
+	}
+}
+
+// RegisterStrategy adds or replaces a named strategy, selectable as the default or per request
+// The strategy table is read without locking: register every strategy before the router serves requests
+func (r *ScoreRouter) RegisterStrategy(name string, strategy Strategy) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || strategy == nil {
+		return fmt.Errorf("strategy name and implementation are required")
+	}
+	r.strategies[name] = strategy
+	return nil
+}
+
+// SetDefaultStrategy selects the strategy for requests that do not name one; safe to call at runtime
+func (r *ScoreRouter) SetDefaultStrategy(name string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !r.HasStrategy(name) {
+		return fmt.Errorf("unknown routing strategy %q: expected one of %s", name, strings.Join(r.Strategies(), ", "))
+	}
+	r.strategy.Store(&name)
+	return nil
+}
+
+// HasStrategy reports whether a strategy of that name is registered
+func (r *ScoreRouter) HasStrategy(name string) bool {
+	_, ok := r.strategies[strings.ToLower(name)]
+	return ok
+}
+
+// Strategies returns the names of the registered strategies, sorted
+func (r *ScoreRouter) Strategies() []string {
+	names := make([]string, 0, len(r.strategies))
+	for name := range r.strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pick places req on one of the candidates with the strategy it names, or the default one
+func (r *ScoreRouter) pick(req *core.InferenceRequest, candidates []workerScore) workerScore {
+	strategy, ok := r.strategies[strings.ToLower(req.Strategy)]
+	if !ok {
+		strategy = r.strategies[*r.strategy.Load()]
+	}
+	candidates = r.spreadTenant(candidates, req.Tenant)
+	weights := r.Weights()
+	offered := make([]Candidate, len(candidates))
+	for i, c := range candidates {
+		offered[i] = Candidate{Worker: c.worker, Profile: c.profile, Score: c.total(weights)}
+	}
+	i := strategy.Pick(req, offered)
+	if i < 0 || i >= len(candidates) {
+		return selectBestWorker(candidates, weights)
+	}
+	return candidates[i]
+}
+
+// pickBestScore returns the first candidate with the highest score
+func pickBestScore(_ *core.InferenceRequest, candidates []Candidate) int {
+	best := 0
+	for i, c := range candidates {
+		if c.Score > candidates[best].Score {
+			best = i
+		}
+	}
+	return best
+}
+
+// roundRobin cycles through the candidates sorted by worker ID, so the rotation does not depend
+// on the order the registry lists workers in
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+func (s *roundRobin) Pick(_ *core.InferenceRequest, candidates []Candidate) int {
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return candidates[order[a]].Worker.ID() < candidates[order[b]].Worker.ID()
+	})
+	n := s.next.Add(1) - 1
+	return order[n%uint64(len(order))]
+}
+
+// pickLeastLoaded returns the candidate with the smallest share of its slots taken by active and
+// queued requests, breaking ties by score
+func pickLeastLoaded(_ *core.InferenceRequest, candidates []Candidate) int {
+	load := func(p core.WorkerProfile) float64 {
+		if p.MaxTasks <= 0 {
+			return float64(p.ActiveTasks + p.QueueLength)
+		}
+		return float64(p.ActiveTasks+p.QueueLength) / float64(p.MaxTasks)
+	}
+	best := 0
+	for i, c := range candidates {
+		l, bl := load(c.Profile), load(candidates[best].Profile)
+		if l < bl || (l == bl && c.Score > candidates[best].Score) {
+			best = i
+		}
+	}
+	return best
+}
+
+// pickConsistentHash uses rendezvous hashing on the request's session, or its tenant without one:
+// the same key keeps landing on the same worker, and only keys of a removed worker move
+func pickConsistentHash(req *core.InferenceRequest, candidates []Candidate) int {
+	key := req.Session
+	if key == "" {
+		key = req.Tenant
+	}
+	best, bestHash := 0, uint64(0)
+	for i, c := range candidates {
+		h := fnv.New64a()
+		h.Write([]byte(key + "\x00" + c.Worker.ID()))
+		if sum := h.Sum64(); i == 0 || sum > bestHash {
+			best, bestHash = i, sum
+		}
+	}
+	return best
+}
@@ -0,0 +1,43 @@
+package router
+
+import "strings"
+
+// Locality is the gateway's own position in the fleet topology
+type Locality struct {
+	Region string
+	Zone   string
+}
+
+// SetLocality makes the router prefer workers in the same zone, then the same region,
+// falling back to cross-region workers only when no closer candidate passes the filters
+func (r *ScoreRouter) SetLocality(locality Locality) {
+	r.locality = locality
+}
+
+// preferLocal narrows candidates to the closest topology tier that has any worker
+// Workers that do not report a region/zone are treated as remote
+func (r *ScoreRouter) preferLocal(candidates []workerScore) []workerScore {
+	if r.locality.Region == "" && r.locality.Zone == "" {
+		return candidates
+	}
+
+	var sameZone, sameRegion []workerScore
+	for _, c := range candidates {
+		if !strings.EqualFold(c.profile.Region, r.locality.Region) {
+			continue
+		}
+		sameRegion = append(sameRegion, c)
+		if r.locality.Zone != "" && strings.EqualFold(c.profile.Zone, r.locality.Zone) {
+			sameZone = append(sameZone, c)
+		}
+	}
+
+	switch {
+	case len(sameZone) > 0:
+		return sameZone
+	case len(sameRegion) > 0:
+		return sameRegion
+	default:
+		return candidates
+	}
+}
@@ -0,0 +1,70 @@
+package router
+
+import (
+	"fmt"
+	"math"
+)
+
+// Weights holds the scoring weights used by ScoreRouter (higher = more important)
+type Weights struct {
+	// VRAM weights the available VRAM percentage
+	VRAM float64 `json:"vram"`
+	// Load weights the free task capacity percentage
+	Load float64 `json:"load"`
+	// Adapter weights the bonus for workers with the requested LoRA adapter resident
+	Adapter float64 `json:"adapter"`
+	// Latency weights the worker latency score (EWMA of TTFT and completion latency)
+	Latency float64 `json:"latency"`
+	// Cost weights the worker cost score (cheaper CostPer1KTokens scores higher)
+	Cost float64 `json:"cost"`
+}
+
+// DefaultWeights returns the weights used by NewScoreRouter
+func DefaultWeights() Weights {
+	return Weights{
+		VRAM:    1.0,
+		Load:    1.0,
+		Adapter: 1.0,
+	}
+}
+
+// Validate checks that all weights are finite, non-negative and not all zero
+func (w Weights) Validate() error {
+	fields := map[string]float64{
+		"vram":    w.VRAM,
+		"load":    w.Load,
+		"adapter": w.Adapter,
+		"latency": w.Latency,
+		"cost":    w.Cost,
+	}
+
+	total := 0.0
+	for name, v := range fields {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("weight %s must be a finite number", name)
+		}
+		if v < 0 {
+			return fmt.Errorf("weight %s must not be negative", name)
+		}
+		total += v
+	}
+	if total == 0 {
+		return fmt.Errorf("at least one weight must be positive")
+	}
+	return nil
+}
+
+// Weights returns a copy of the current scoring weights
+func (r *ScoreRouter) Weights() Weights {
+	return *r.weights.Load()
+}
+
+// SetWeights validates and atomically swaps the scoring weights
+// In-flight Select calls keep using the weights they started with
+func (r *ScoreRouter) SetWeights(w Weights) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	r.weights.Store(&w)
+	return nil
+}
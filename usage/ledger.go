@@ -0,0 +1,186 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LineItem is the aggregated usage of one model and key
+type LineItem struct {
+	Model            string  `json:"model"`
+	Key              string  `json:"key"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	BilledTokens     int     `json:"billed_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// add accumulates one event into the line item
+func (li *LineItem) add(e Event) {
+	li.Requests++
+	li.PromptTokens += e.PromptTokens
+	li.CompletionTokens += e.CompletionTokens
+	li.BilledTokens += e.BilledTokens
+	li.Cost += e.Cost
+}
+
+// BillingSummary is the itemized usage of an organization over [Start, End)
+type BillingSummary struct {
+	Org   string     `json:"org"`
+	Start time.Time  `json:"period_start"`
+	End   time.Time  `json:"period_end"`
+	Items []LineItem `json:"items"`
+	Total LineItem   `json:"total"`
+}
+
+// ledgerKey buckets events per organization, UTC day, model and key
+type ledgerKey struct {
+	org   string
+	day   time.Time
+	model string
+	key   string
+}
+
+// Ledger is an Exporter that keeps daily per-organization usage aggregates for billing summaries
+// Aggregates are bounded by orgs x days x models x keys; raw events are optionally appended to a
+// JSON-lines file and replayed at startup so summaries survive restarts
+type Ledger struct {
+	mu      sync.RWMutex
+	buckets map[ledgerKey]*LineItem
+	file    *os.File
+}
+
+// NewLedger creates a ledger; path is the JSON-lines journal ("" keeps usage in memory only)
+func NewLedger(path string) (*Ledger, error) {
+	l := &Ledger{buckets: make(map[ledgerKey]*LineItem)}
+	if path == "" {
+		return l, nil
+	}
+
+	if err := l.replay(path); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	l.file = f
+	return l, nil
+}
+
+// Export records an event; events without an organization are not billed to anyone and are skipped
+func (l *Ledger) Export(e Event) {
+	if e.Org == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.addLocked(e)
+
+	if l.file != nil {
+		line, err := json.Marshal(e)
+		if err == nil {
+			_, err = l.file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			slog.Error("failed to journal usage event", "request_id", e.RequestID, "error", err)
+		}
+	}
+}
+
+// Summary returns the itemized usage of org for events in [start, end), at day granularity (UTC)
+func (l *Ledger) Summary(org string, start, end time.Time) BillingSummary {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	type itemKey struct{ model, key string }
+	items := make(map[itemKey]*LineItem)
+	for k, bucket := range l.buckets {
+		if k.org != org || k.day.Before(start.UTC().Truncate(24*time.Hour)) || !k.day.Before(end.UTC()) {
+			continue
+		}
+		ik := itemKey{k.model, k.key}
+		item, ok := items[ik]
+		if !ok {
+			item = &LineItem{Model: k.model, Key: k.key}
+			items[ik] = item
+		}
+		item.Requests += bucket.Requests
+		item.PromptTokens += bucket.PromptTokens
+		item.CompletionTokens += bucket.CompletionTokens
+		item.BilledTokens += bucket.BilledTokens
+		item.Cost += bucket.Cost
+	}
+
+	summary := BillingSummary{Org: org, Start: start, End: end, Items: make([]LineItem, 0, len(items))}
+	for _, item := range items {
+		summary.Items = append(summary.Items, *item)
+		summary.Total.Requests += item.Requests
+		summary.Total.PromptTokens += item.PromptTokens
+		summary.Total.CompletionTokens += item.CompletionTokens
+		summary.Total.BilledTokens += item.BilledTokens
+		summary.Total.Cost += item.Cost
+	}
+	sort.Slice(summary.Items, func(i, j int) bool {
+		if summary.Items[i].Model != summary.Items[j].Model {
+			return summary.Items[i].Model < summary.Items[j].Model
+		}
+		return summary.Items[i].Key < summary.Items[j].Key
+	})
+	return summary
+}
+
+// addLocked accumulates an event into its daily bucket; caller must hold l.mu
+func (l *Ledger) addLocked(e Event) {
+	k := ledgerKey{
+		org:   e.Org,
+		day:   e.Time.UTC().Truncate(24 * time.Hour),
+		model: e.Model,
+		key:   MaskKey(e.Key),
+	}
+	bucket, ok := l.buckets[k]
+	if !ok {
+		bucket = &LineItem{Model: e.Model, Key: k.key}
+		l.buckets[k] = bucket
+	}
+	bucket.add(e)
+}
+
+// replay loads the journal written by previous runs
+func (l *Ledger) replay(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// 进程崩溃可能留下半行，跳过即可
+			continue
+		}
+		l.addLocked(e)
+	}
+	return scanner.Err()
+}
+
+// MaskKey hides all but the last four characters of an API key
+func MaskKey(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
@@ -0,0 +1,132 @@
+package usage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSExporter publishes each usage event as a JSON message on a NATS subject
+// It speaks the plain-text NATS client protocol (CONNECT/PUB/PING) and reconnects on failure
+type NATSExporter struct {
+	addr    string
+	subject string
+	events  chan Event
+	// writeMu serializes PUBs with the PONG replies of the read loop
+	writeMu sync.Mutex
+}
+
+// NewNATSExporter starts an exporter publishing to subject on the server at addr (host:port or nats://host:port)
+func NewNATSExporter(ctx context.Context, addr, subject string) *NATSExporter {
+	n := &NATSExporter{
+		addr:    strings.TrimPrefix(addr, "nats://"),
+		subject: subject,
+		events:  make(chan Event, 1024),
+	}
+	go n.run(ctx)
+	return n
+}
+
+// Export queues an event without blocking
+func (n *NATSExporter) Export(e Event) {
+	select {
+	case n.events <- e:
+	default:
+		slog.Warn("usage NATS buffer full, dropping usage event", "request_id", e.RequestID)
+	}
+}
+
+func (n *NATSExporter) run(ctx context.Context) {
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-n.events:
+			if conn == nil {
+				c, err := n.connect(ctx)
+				if err != nil {
+					slog.Error("usage NATS connect failed, dropping usage event", "request_id", e.RequestID, "error", err)
+					continue
+				}
+				conn = c
+			}
+			if err := n.publish(conn, e); err != nil {
+				slog.Error("usage NATS publish failed, dropping usage event", "request_id", e.RequestID, "error", err)
+				conn.Close()
+				conn = nil
+			}
+		}
+	}
+}
+
+// connect dials the server, consumes its INFO line and sends CONNECT
+func (n *NATSExporter) connect(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected server greeting %q: %v", line, err)
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+
+	if _, err := conn.Write([]byte("CONNECT {\"verbose\":false,\"pedantic\":false,\"name\":\"zam-gateway\"}\r\n")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go n.readLoop(conn, reader)
+	return conn, nil
+}
+
+// readLoop answers server PINGs so the connection is not considered stale
+// It exits when the connection is closed
+func (n *NATSExporter) readLoop(conn net.Conn, reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch {
+		case strings.HasPrefix(line, "PING"):
+			n.writeMu.Lock()
+			_, err = conn.Write([]byte("PONG\r\n"))
+			n.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			slog.Error("usage NATS server error", "error", strings.TrimSpace(line))
+		}
+	}
+}
+
+func (n *NATSExporter) publish(conn net.Conn, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	n.writeMu.Lock()
+	defer n.writeMu.Unlock()
+	_ = conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	_, err = fmt.Fprintf(conn, "PUB %s %d\r\n%s\r\n", n.subject, len(payload), payload)
+	return err
+}
@@ -0,0 +1,182 @@
+package usage
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"zam/core"
+)
+
+// Event is the usage record of one completed request
+type Event struct {
+	RequestID string    `json:"request_id"`
+	Time      time.Time `json:"time"`
+	Key       string    `json:"key"`
+	// User is the client-supplied end-user ID, for abuse attribution
+	User             string `json:"user,omitempty"`
+	Org              string `json:"org,omitempty"`
+	Model            string `json:"model"`
+	WorkerID         string `json:"worker_id"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+	// Endpoint is the API family the request used; it selects the billing multiplier
+	Endpoint Endpoint `json:"endpoint"`
+	// BilledTokens is TotalTokens weighted by the endpoint multiplier, deducted from the key's balance
+	BilledTokens int `json:"billed_tokens"`
+	// Cost is in the currency of the configured pricing, 0 when the model has no price
+	Cost   float64 `json:"cost"`
+	Stream bool    `json:"stream"`
+}
+
+// Endpoint identifies a billable API family
+type Endpoint string
+
+const (
+	EndpointChat       Endpoint = "chat"
+	EndpointEmbeddings Endpoint = "embeddings"
+	EndpointAudio      Endpoint = "audio"
+)
+
+// Multipliers weight usage per endpoint so one balance can cover heterogeneous workloads
+// Endpoints without an entry are billed at 1
+type Multipliers map[Endpoint]float64
+
+// Of returns the multiplier of an endpoint
+func (m Multipliers) Of(endpoint Endpoint) float64 {
+	if v, ok := m[endpoint]; ok {
+		return v
+	}
+	return 1
+}
+
+// ParseMultipliers parses "endpoint=factor;..." e.g. "chat=1;embeddings=0.1;audio=2"
+func ParseMultipliers(spec string) (Multipliers, error) {
+	multipliers := make(Multipliers)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, factor, ok := strings.Cut(entry, "=")
+		endpoint := Endpoint(strings.ToLower(strings.TrimSpace(name)))
+		switch endpoint {
+		case EndpointChat, EndpointEmbeddings, EndpointAudio:
+		default:
+			return nil, fmt.Errorf("invalid multiplier %q: endpoint must be chat, embeddings or audio", entry)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(factor), 64)
+		if !ok || err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid multiplier %q: factor must be a non-negative number", entry)
+		}
+		multipliers[endpoint] = v
+	}
+	return multipliers, nil
+}
+
+// Exporter ships usage events to an external metering system
+// Export must not block the request path
+type Exporter interface {
+	Export(e Event)
+}
+
+// ExporterFunc adapts a function to the Exporter interface
+type ExporterFunc func(e Event)
+
+// Export calls f(e)
+func (f ExporterFunc) Export(e Event) {
+	f(e)
+}
+
+// Price is the cost per 1K tokens of a model
+type Price struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// Pricing maps lower-cased model names to prices; the "*" entry applies to unlisted models
+type Pricing map[string]Price
+
+// Cost returns the cost of the given token counts on model
+func (p Pricing) Cost(model string, promptTokens, completionTokens int) float64 {
+	price, ok := p[strings.ToLower(model)]
+	if !ok {
+		price = p["*"]
+	}
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1000
+}
+
+// ParsePricing parses "model=prompt/completion;..." with prices per 1K tokens, e.g. "llama-8b=0.1/0.2;*=0.5/1.5"
+func ParsePricing(spec string) (Pricing, error) {
+	pricing := make(Pricing)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		model, rule, ok := strings.Cut(entry, "=")
+		prompt, completion, ok2 := strings.Cut(rule, "/")
+		if !ok || !ok2 || strings.TrimSpace(model) == "" {
+			return nil, fmt.Errorf("invalid price %q: expected model=prompt/completion", entry)
+		}
+		p, err1 := strconv.ParseFloat(strings.TrimSpace(prompt), 64)
+		c, err2 := strconv.ParseFloat(strings.TrimSpace(completion), 64)
+		if err1 != nil || err2 != nil || p < 0 || c < 0 {
+			return nil, fmt.Errorf("invalid price %q: prices must be non-negative numbers", entry)
+		}
+		pricing[strings.ToLower(strings.TrimSpace(model))] = Price{Prompt: p, Completion: c}
+	}
+	return pricing, nil
+}
+
+// Meter prices usage events, attributes them to organizations and fans them out to exporters
+type Meter struct {
+	pricing     Pricing
+	multipliers Multipliers
+	keys        *core.KeyDirectory
+	exporters   []Exporter
+}
+
+// NewMeter creates a Meter; keys may be nil when keys are not mapped to organizations
+func NewMeter(pricing Pricing, keys *core.KeyDirectory, exporters ...Exporter) *Meter {
+	return &Meter{
+		pricing:   pricing,
+		keys:      keys,
+		exporters: exporters,
+	}
+}
+
+// SetMultipliers sets the per-endpoint billing weights
+func (m *Meter) SetMultipliers(multipliers Multipliers) {
+	m.multipliers = multipliers
+}
+
+// Record completes e with org, totals, billed tokens and cost, then exports it
+// The completed event is returned so callers can charge and report it; a nil Meter only computes totals
+func (m *Meter) Record(e Event) Event {
+	if m == nil {
+		m = &Meter{}
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Endpoint == "" {
+		e.Endpoint = EndpointChat
+	}
+	multiplier := m.multipliers.Of(e.Endpoint)
+
+	e.Org = m.keys.Lookup(e.Key).Org
+	e.TotalTokens = e.PromptTokens + e.CompletionTokens
+	e.BilledTokens = int(math.Ceil(float64(e.TotalTokens) * multiplier))
+	e.Cost = m.pricing.Cost(e.Model, e.PromptTokens, e.CompletionTokens) * multiplier
+
+	for _, exp := range m.exporters {
+		exp.Export(e)
+	}
+	return e
+}
@@ -0,0 +1,134 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"zam/core"
+)
+
+// recordingExporter collects exported events
+type recordingExporter struct {
+	events []Event
+}
+
+func (r *recordingExporter) Export(e Event) {
+	r.events = append(r.events, e)
+}
+
+func TestMeter_Record(t *testing.T) {
+	pricing, err := ParsePricing("llama-8b=0.1/0.2;*=1/2")
+	if err != nil {
+		t.Fatalf("ParsePricing failed: %v", err)
+	}
+	keys, _ := core.ParseKeyDirectory("test-key-123=acme")
+	rec := &recordingExporter{}
+	meter := NewMeter(pricing, keys, rec)
+
+	e := meter.Record(Event{RequestID: "req-1", Key: "test-key-123", Model: "Llama-8B", PromptTokens: 1000, CompletionTokens: 500})
+	if e.Org != "acme" || e.TotalTokens != 1500 {
+		t.Errorf("Expected org acme and 1500 tokens, got %+v", e)
+	}
+	if math.Abs(e.Cost-0.2) > 1e-9 {
+		t.Errorf("Expected cost 0.2, got %v", e.Cost)
+	}
+	if len(rec.events) != 1 || rec.events[0].RequestID != "req-1" {
+		t.Errorf("Expected event to be exported, got %+v", rec.events)
+	}
+
+	// 未定价模型使用 "*" 默认价
+	if e := meter.Record(Event{Key: "other", Model: "gemma-2b", PromptTokens: 1000}); e.Cost != 1 || e.Org != "" {
+		t.Errorf("Expected default price and no org, got %+v", e)
+	}
+}
+
+func TestWebhookExporter_Batches(t *testing.T) {
+	received := make(chan []Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Expected bearer token, got %q", r.Header.Get("Authorization"))
+		}
+		var batch []Event
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("Failed to decode batch: %v", err)
+		}
+		received <- batch
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exporter := NewWebhookExporter(ctx, server.URL, "secret")
+	exporter.Export(Event{RequestID: "req-1"})
+	exporter.Export(Event{RequestID: "req-2"})
+
+	select {
+	case batch := <-received:
+		if len(batch) != 2 {
+			t.Errorf("Expected 2 events in batch, got %d", len(batch))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for webhook delivery")
+	}
+}
+
+func TestMeter_Multipliers(t *testing.T) {
+	pricing, _ := ParsePricing("*=1/1")
+	multipliers, err := ParseMultipliers("embeddings=0.1;audio=2")
+	if err != nil {
+		t.Fatalf("ParseMultipliers failed: %v", err)
+	}
+	meter := NewMeter(pricing, nil)
+	meter.SetMultipliers(multipliers)
+
+	chat := meter.Record(Event{Model: "m", PromptTokens: 100, CompletionTokens: 100})
+	if chat.Endpoint != EndpointChat || chat.BilledTokens != 200 {
+		t.Errorf("Expected chat billed at 1x, got %+v", chat)
+	}
+	emb := meter.Record(Event{Model: "m", PromptTokens: 1000, Endpoint: EndpointEmbeddings})
+	if emb.BilledTokens != 100 || math.Abs(emb.Cost-0.1) > 1e-9 {
+		t.Errorf("Expected embeddings billed at 0.1x, got %+v", emb)
+	}
+
+	if _, err := ParseMultipliers("images=3"); err == nil {
+		t.Error("Expected unknown endpoint to be rejected")
+	}
+}
+
+func TestLedger_SummaryAndReplay(t *testing.T) {
+	path := t.TempDir() + "/ledger.jsonl"
+	ledger, err := NewLedger(path)
+	if err != nil {
+		t.Fatalf("NewLedger failed: %v", err)
+	}
+
+	oct := time.Date(2026, 10, 3, 12, 0, 0, 0, time.UTC)
+	ledger.Export(Event{Org: "acme", Key: "key-aaaa", Model: "llama-8b", PromptTokens: 10, CompletionTokens: 5, Cost: 1, Time: oct})
+	ledger.Export(Event{Org: "acme", Key: "key-aaaa", Model: "llama-8b", PromptTokens: 10, CompletionTokens: 5, Cost: 1, Time: oct})
+	ledger.Export(Event{Org: "acme", Key: "key-bbbb", Model: "gemma-2b", PromptTokens: 1, Cost: 0.5, Time: oct})
+	ledger.Export(Event{Org: "acme", Key: "key-aaaa", Model: "llama-8b", PromptTokens: 99, Time: oct.AddDate(0, 1, 0)})
+	ledger.Export(Event{Org: "other", Key: "key-cccc", Model: "llama-8b", PromptTokens: 99, Time: oct})
+
+	// 重启后从日志回放
+	replayed, err := NewLedger(path)
+	if err != nil {
+		t.Fatalf("NewLedger replay failed: %v", err)
+	}
+
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	summary := replayed.Summary("acme", start, start.AddDate(0, 1, 0))
+	if len(summary.Items) != 2 {
+		t.Fatalf("Expected 2 line items, got %+v", summary.Items)
+	}
+	if item := summary.Items[1]; item.Model != "llama-8b" || item.Key != "****aaaa" || item.Requests != 2 || item.PromptTokens != 20 {
+		t.Errorf("Unexpected llama-8b line item: %+v", item)
+	}
+	if summary.Total.Requests != 3 || math.Abs(summary.Total.Cost-2.5) > 1e-9 {
+		t.Errorf("Unexpected totals: %+v", summary.Total)
+	}
+}
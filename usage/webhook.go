@@ -0,0 +1,124 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// WebhookExporter POSTs batches of usage events as a JSON array to a URL
+// Events are buffered and sent from a background goroutine; when the buffer is full new events are dropped
+type WebhookExporter struct {
+	url        string
+	token      string
+	client     *http.Client
+	events     chan Event
+	batchSize  int
+	flushEvery time.Duration
+}
+
+// NewWebhookExporter starts an exporter that runs until ctx is cancelled
+// token, if set, is sent as a Bearer Authorization header
+func NewWebhookExporter(ctx context.Context, url, token string) *WebhookExporter {
+	w := &WebhookExporter{
+		url:        url,
+		token:      token,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		events:     make(chan Event, 1024),
+		batchSize:  100,
+		flushEvery: 2 * time.Second,
+	}
+	go w.run(ctx)
+	return w
+}
+
+// Export queues an event without blocking
+func (w *WebhookExporter) Export(e Event) {
+	select {
+	case w.events <- e:
+	default:
+		slog.Warn("usage webhook buffer full, dropping usage event", "request_id", e.RequestID)
+	}
+}
+
+// run batches queued events and flushes them by size or interval
+func (w *WebhookExporter) run(ctx context.Context) {
+	ticker := time.NewTicker(w.flushEvery)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, w.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.send(batch); err != nil {
+			slog.Error("failed to deliver usage events", "events", len(batch), "error", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// 退出前尽力投递剩余事件
+			for {
+				select {
+				case e := <-w.events:
+					batch = append(batch, e)
+				default:
+					flush()
+					return
+				}
+			}
+		case e := <-w.events:
+			batch = append(batch, e)
+			if len(batch) >= w.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// send delivers one batch, retrying once on failure
+func (w *WebhookExporter) send(batch []Event) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage events: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = w.post(body)
+		if err == nil || attempt == 1 {
+			return err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func (w *WebhookExporter) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"zam/core"
+	"zam/openai"
+)
+
+// DefaultAnthropicVersion is the anthropic-version header sent to the Messages API
+const DefaultAnthropicVersion = "2023-06-01"
+
+// anthropicMessage is a Messages API turn; roles alternate between user and assistant
+type anthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+// anthropicContent is a content block: text, tool_use (assistant) or tool_result (user)
+type anthropicContent struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// anthropicEvent is one event of the Messages API stream
+type anthropicEvent struct {
+	Type         string `json:"type"`
+	Index        int    `json:"index"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// executeAnthropic runs the request on the Messages API and converts its stream to StreamChunks
+func (w *CloudWorker) executeAnthropic(ctx context.Context, req *core.InferenceRequest, sender func(chunk core.StreamChunk) error) error {
+	traceID, _ := ctx.Value(core.TraceKey).(string)
+	slog.Debug("forwarding request to Anthropic /v1/messages", "worker_id", w.id, "trace_id", traceID, "model", req.Model)
+
+	system, messages := anthropicMessages(req.Messages)
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = w.MaxTokens
+	}
+	body := map[string]interface{}{
+		"model":      req.Model,
+		"messages":   messages,
+		"max_tokens": maxTokens,
+		"stream":     true,
+	}
+	if system != "" {
+		body["system"] = system
+	}
+	// Anthropic 的 temperature 取值 [0, 1]，OpenAI 为 [0, 2]
+	if req.Temperature > 1 {
+		body["temperature"] = 1
+	} else if req.Temperature > 0 {
+		body["temperature"] = req.Temperature
+	}
+	if req.TopP > 0 {
+		body["top_p"] = req.TopP
+	}
+	if len(req.Stop) > 0 {
+		body["stop_sequences"] = req.Stop
+	}
+	if tools := anthropicTools(req.Tools); len(tools) > 0 {
+		body["tools"] = tools
+		if choice := anthropicToolChoice(req.ToolChoice); choice != nil {
+			body["tool_choice"] = choice
+		}
+	}
+	if req.User != "" {
+		body["metadata"] = map[string]string{"user_id": req.User}
+	}
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(requestBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("anthropic-version", w.APIVersion)
+	for key, values := range w.Headers {
+		for _, v := range values {
+			httpReq.Header.Add(key, v)
+		}
+	}
+
+	resp, err := w.HTTPClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return throttledError(resp)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+	// content block 序号 -> 工具调用序号
+	toolCalls := make(map[int]int)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var event anthropicEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return fmt.Errorf("failed to parse Anthropic event: %w", err)
+		}
+
+		var chunk core.StreamChunk
+		switch event.Type {
+		case "error":
+			return fmt.Errorf("Anthropic error: %s: %s", event.Error.Type, event.Error.Message)
+		case "message_stop":
+			return nil
+		case "content_block_start":
+			if event.ContentBlock.Type != "tool_use" {
+				continue
+			}
+			index := len(toolCalls)
+			toolCalls[event.Index] = index
+			chunk.ToolCalls = []core.ToolCallDelta{{Index: index, ID: event.ContentBlock.ID, Type: "function", Name: event.ContentBlock.Name}}
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				chunk.Content = event.Delta.Text
+			case "thinking_delta":
+				chunk.Reasoning = event.Delta.Thinking
+			case "input_json_delta":
+				chunk.ToolCalls = []core.ToolCallDelta{{Index: toolCalls[event.Index], Arguments: event.Delta.PartialJSON}}
+			default:
+				continue
+			}
+		case "message_delta":
+			if event.Delta.StopReason == "" {
+				continue
+			}
+			chunk.FinishReason = anthropicFinishReason(event.Delta.StopReason)
+		default:
+			// message_start、content_block_stop、ping
+			continue
+		}
+		if err := sender(chunk); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to scan response: %w", err)
+	}
+	return fmt.Errorf("worker %s: %w", w.id, core.ErrStreamTruncated)
+}
+
+// anthropicMessages converts OpenAI messages to a system prompt and alternating Messages API turns
+// Tool results become tool_result blocks of a user turn; consecutive turns of one role are merged
+func anthropicMessages(messages []openai.Message) (string, []anthropicMessage) {
+	var system []string
+	var converted []anthropicMessage
+	appendTurn := func(role string, blocks ...anthropicContent) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(converted); n > 0 && converted[n-1].Role == role {
+			converted[n-1].Content = append(converted[n-1].Content, blocks...)
+			return
+		}
+		converted = append(converted, anthropicMessage{Role: role, Content: blocks})
+	}
+
+	for _, m := range messages {
+		switch m.Role {
+		case "system", "developer":
+			system = append(system, m.Content)
+		case "assistant":
+			var blocks []anthropicContent
+			if m.Content != "" {
+				blocks = append(blocks, anthropicContent{Type: "text", Text: m.Content})
+			}
+			for _, call := range m.ToolCalls {
+				input := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicContent{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: input})
+			}
+			appendTurn("assistant", blocks...)
+		case "tool":
+			appendTurn("user", anthropicContent{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content})
+		default:
+			appendTurn("user", anthropicContent{Type: "text", Text: m.Content})
+		}
+	}
+	return strings.Join(system, "\n\n"), converted
+}
+
+// anthropicTools converts OpenAI function tools to Anthropic tool definitions
+func anthropicTools(tools interface{}) []anthropicTool {
+	if tools == nil {
+		return nil
+	}
+	// Tools 原样透传为 interface{}，经 JSON 转回 OpenAI 格式再转换
+	raw, err := json.Marshal(tools)
+	if err != nil {
+		return nil
+	}
+	var defs []openai.Tool
+	if err := json.Unmarshal(raw, &defs); err != nil {
+		return nil
+	}
+	converted := make([]anthropicTool, 0, len(defs))
+	for _, t := range defs {
+		schema := t.Function.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		converted = append(converted, anthropicTool{Name: t.Function.Name, Description: t.Function.Description, InputSchema: schema})
+	}
+	return converted
+}
+
+// anthropicToolChoice maps an OpenAI tool_choice ("auto", "none", "required" or a named function)
+func anthropicToolChoice(choice interface{}) interface{} {
+	switch c := choice.(type) {
+	case nil:
+		return nil
+	case string:
+		switch c {
+		case "none":
+			return map[string]string{"type": "none"}
+		case "required":
+			return map[string]string{"type": "any"}
+		}
+		return map[string]string{"type": "auto"}
+	}
+	raw, err := json.Marshal(choice)
+	if err != nil {
+		return nil
+	}
+	var named struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if json.Unmarshal(raw, &named) != nil || named.Function.Name == "" {
+		return nil
+	}
+	return map[string]string{"type": "tool", "name": named.Function.Name}
+}
+
+// anthropicFinishReason maps Anthropic stop reasons onto OpenAI finish reasons
+func anthropicFinishReason(reason string) string {
+	switch reason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	}
+	// end_turn, stop_sequence
+	return "stop"
+}
@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// getJSON fetches a JSON document from a native backend API (TGI, Ollama, llama.cpp, Triton)
+func getJSON(ctx context.Context, client *http.Client, headers http.Header, url string, out interface{}) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	return doJSON(client, headers, httpReq, out)
+}
+
+// postJSON posts a JSON body to a native backend API and decodes the JSON response
+func postJSON(ctx context.Context, client *http.Client, headers http.Header, url string, body []byte, out interface{}) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	return doJSON(client, headers, httpReq, out)
+}
+
+func doJSON(client *http.Client, headers http.Header, httpReq *http.Request, out interface{}) error {
+	setHeaders(httpReq, headers)
+	path := httpReq.URL.Path
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code from %s: %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// setHeaders adds a backend's configured extra headers (e.g. Authorization) to httpReq
+func setHeaders(httpReq *http.Request, headers http.Header) {
+	for key, values := range headers {
+		for _, v := range values {
+			httpReq.Header.Add(key, v)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"zam/core"
+)
+
+// Cloud providers supported by CloudWorker
+const (
+	// CloudOpenAI is the OpenAI API or any OpenAI-compatible service (Bearer auth)
+	CloudOpenAI = "openai"
+	// CloudAzure is Azure OpenAI: models are deployments and the key goes in the api-key header
+	CloudAzure = "azure"
+	// CloudAnthropic is the Anthropic Messages API
+	CloudAnthropic = "anthropic"
+)
+
+// DefaultAzureAPIVersion is the Azure OpenAI api-version used when none is configured
+const DefaultAzureAPIVersion = "2024-06-01"
+
+// CloudWorker is a paid cloud API declared by the operator as a fallback for local workers
+// It authenticates the way the provider expects, renames models through ModelMap and reports a
+// static fallback profile, so it is only used when no local worker can take a request
+type CloudWorker struct {
+	*HTTPWorker
+	Provider string
+	BaseURL  string
+	// ModelMap maps routable model names to provider models (deployment names on Azure);
+	// when empty every model is accepted and forwarded under its own name
+	ModelMap map[string]string
+	// APIVersion is the Azure api-version query parameter or the anthropic-version header
+	APIVersion string
+	MaxTasks   int
+	// Priority orders fallback workers; the highest is used first
+	Priority int
+	// CostPer1KTokens prices the worker's tokens for the fallback budget
+	CostPer1KTokens float64
+	// MaxTokens is the completion limit sent to Anthropic when the request sets none; the Messages API requires one
+	MaxTokens int
+}
+
+// NewCloudWorker creates a CloudWorker for provider at baseURL, e.g. "https://api.openai.com/v1",
+// "https://my-resource.openai.azure.com" or "https://api.anthropic.com"
+func NewCloudWorker(id, provider, baseURL, apiKey string) (*CloudWorker, error) {
+	baseURL = strings.TrimRight(baseURL, "/")
+	if _, err := url.ParseRequestURI(baseURL); err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", baseURL, err)
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("an API key is required")
+	}
+
+	w := &CloudWorker{
+		Provider:  strings.ToLower(provider),
+		BaseURL:   baseURL,
+		MaxTasks:  100,
+		MaxTokens: 4096,
+	}
+	headers := make(http.Header)
+	switch w.Provider {
+	case CloudOpenAI:
+		w.HTTPWorker = NewHTTPWorker(id, baseURL+"/chat/completions")
+		headers.Set("Authorization", "Bearer "+apiKey)
+	case CloudAzure:
+		// Azure 的地址随部署名变化，在 Execute 中按模型生成
+		w.HTTPWorker = NewHTTPWorker(id, baseURL)
+		w.APIVersion = DefaultAzureAPIVersion
+		headers.Set("api-key", apiKey)
+	case CloudAnthropic:
+		w.HTTPWorker = NewHTTPWorker(id, baseURL+"/v1/messages")
+		w.APIVersion = DefaultAnthropicVersion
+		headers.Set("x-api-key", apiKey)
+	default:
+		return nil, fmt.Errorf("unsupported cloud provider %q: expected openai, azure or anthropic", provider)
+	}
+	w.Headers = headers
+	return w, nil
+}
+
+// Heartbeat reports the static fallback profile without calling the provider
+func (w *CloudWorker) Heartbeat(ctx context.Context) (core.WorkerProfile, error) {
+	supported := []string{"*"}
+	if len(w.ModelMap) > 0 {
+		supported = make([]string, 0, len(w.ModelMap))
+		for name := range w.ModelMap {
+			supported = append(supported, name)
+		}
+	}
+	caps := core.Capabilities{Tools: true}
+	if w.Provider != CloudAnthropic {
+		caps.Vision, caps.JSONMode = true, true
+	}
+	return core.WorkerProfile{
+		WorkerID:        w.id,
+		Supported:       supported,
+		MaxTasks:        w.MaxTasks,
+		Class:           "cloud",
+		IsFallback:      true,
+		Priority:        w.Priority,
+		CostPer1KTokens: w.CostPer1KTokens,
+		Capabilities:    caps,
+	}, nil
+}
+
+// Execute forwards the request to the provider under the mapped model name
+func (w *CloudWorker) Execute(ctx context.Context, req *core.InferenceRequest, sender func(chunk core.StreamChunk) error) error {
+	forwarded := *req
+	if model, ok := w.lookupModel(req.Model); ok {
+		forwarded.Model = model
+	}
+	// 云端不支持本地的 LoRA 与投机解码参数
+	forwarded.Adapter, forwarded.LoadAdapter, forwarded.Speculative = "", false, nil
+	// 共用 SSE 解析，始终以流式请求
+	forwarded.Stream = true
+
+	switch w.Provider {
+	case CloudAzure:
+		deployment := *w.HTTPWorker
+		deployment.URL = fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+			w.BaseURL, url.PathEscape(forwarded.Model), url.QueryEscape(w.APIVersion))
+		return deployment.Execute(ctx, &forwarded, sender)
+	case CloudAnthropic:
+		return w.executeAnthropic(ctx, &forwarded, sender)
+	}
+	return w.HTTPWorker.Execute(ctx, &forwarded, sender)
+}
+
+func (w *CloudWorker) lookupModel(name string) (string, bool) {
+	if model, ok := w.ModelMap[name]; ok {
+		return model, true
+	}
+	for alias, model := range w.ModelMap {
+		if strings.EqualFold(alias, name) {
+			return model, true
+		}
+	}
+	return "", false
+}
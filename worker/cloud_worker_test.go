@@ -0,0 +1,138 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"zam/core"
+	"zam/openai"
+)
+
+func TestCloudWorker_OpenAIAndAzure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			if r.Header.Get("Authorization") != "Bearer sk-test" || body.Model != "gpt-4o" {
+				t.Errorf("expected Bearer auth and mapped model, got %q / %q", r.Header.Get("Authorization"), body.Model)
+			}
+		case "/openai/deployments/gpt4o-prod/chat/completions":
+			if r.Header.Get("api-key") != "azure-key" || r.URL.Query().Get("api-version") != DefaultAzureAPIVersion {
+				t.Errorf("expected api-key header and api-version, got %v / %s", r.Header, r.URL.RawQuery)
+			}
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"},\"finish_reason\":\"stop\"}]}\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	openaiWorker, err := NewCloudWorker("cloud-openai", CloudOpenAI, server.URL+"/v1", "sk-test")
+	if err != nil {
+		t.Fatalf("NewCloudWorker failed: %v", err)
+	}
+	openaiWorker.ModelMap = map[string]string{"gpt-4": "gpt-4o"}
+	azureWorker, err := NewCloudWorker("cloud-azure", CloudAzure, server.URL, "azure-key")
+	if err != nil {
+		t.Fatalf("NewCloudWorker failed: %v", err)
+	}
+	azureWorker.ModelMap = map[string]string{"gpt-4": "gpt4o-prod"}
+
+	for _, w := range []*CloudWorker{openaiWorker, azureWorker} {
+		var content string
+		err := w.Execute(context.Background(), &core.InferenceRequest{
+			Model:    "gpt-4",
+			Messages: []openai.Message{{Role: "user", Content: "hi"}},
+		}, func(chunk core.StreamChunk) error {
+			content += chunk.Content
+			return nil
+		})
+		if err != nil || content != "Hi" {
+			t.Errorf("%s: expected Hi, got %q (%v)", w.ID(), content, err)
+		}
+	}
+
+	profile, _ := openaiWorker.Heartbeat(context.Background())
+	if !profile.IsFallback || len(profile.Supported) != 1 || profile.Supported[0] != "gpt-4" {
+		t.Errorf("expected a fallback profile serving the mapped models, got %+v", profile)
+	}
+	if _, err := NewCloudWorker("cloud-x", "bedrock", server.URL, "key"); err == nil {
+		t.Error("expected an unknown provider to be rejected")
+	}
+}
+
+func TestCloudWorker_Anthropic(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "ant-key" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		var body struct {
+			System    string             `json:"system"`
+			Messages  []anthropicMessage `json:"messages"`
+			MaxTokens int                `json:"max_tokens"`
+			Tools     []anthropicTool    `json:"tools"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.System != "be brief" || len(body.Messages) != 3 || body.MaxTokens != 4096 || len(body.Tools) != 1 {
+			t.Errorf("unexpected Messages API body: %+v", body)
+		}
+		if tr := body.Messages[2].Content[0]; body.Messages[2].Role != "user" || tr.Type != "tool_result" || tr.ToolUseID != "toolu_1" {
+			t.Errorf("expected the tool result in a user turn, got %+v", body.Messages[2])
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"id":"msg_1"}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking"}}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_2","name":"get_weather","input":{}}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"}}`,
+			`{"type":"message_stop"}`,
+		} {
+			w.Write([]byte("event: x\ndata: " + event + "\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	cloud, err := NewCloudWorker("cloud-claude", CloudAnthropic, server.URL, "ant-key")
+	if err != nil {
+		t.Fatalf("NewCloudWorker failed: %v", err)
+	}
+	var content, args, name, finish string
+	err = cloud.Execute(context.Background(), &core.InferenceRequest{
+		Model: "claude-sonnet",
+		Messages: []openai.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "weather in Paris?"},
+			{Role: "assistant", ToolCalls: []openai.ToolCall{{ID: "toolu_1", Type: "function", Function: openai.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}}}},
+			{Role: "tool", ToolCallID: "toolu_1", Content: "sunny"},
+		},
+		Tools: []openai.Tool{{Type: "function", Function: openai.ToolFunction{Name: "get_weather", Parameters: json.RawMessage(`{"type":"object"}`)}}},
+	}, func(chunk core.StreamChunk) error {
+		content += chunk.Content
+		for _, call := range chunk.ToolCalls {
+			name += call.Name
+			args += call.Arguments
+		}
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if content != "Checking" || name != "get_weather" || args != `{"city":"Paris"}` || finish != "tool_calls" {
+		t.Errorf("unexpected conversion: content=%q name=%q args=%q finish=%q", content, name, args, finish)
+	}
+}
@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"zam/core"
+)
+
+// embeddingsResponse is the body returned by OpenAI-compatible /v1/embeddings endpoints
+type embeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed calls the backend's OpenAI-compatible embeddings endpoint next to the chat endpoint
+// (".../v1/chat/completions" -> ".../v1/embeddings")
+func (w *HTTPWorker) Embed(ctx context.Context, req *core.EmbedRequest) ([][]float32, error) {
+	endpoint, err := w.embeddingsURL()
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"model": req.Model,
+		"input": req.Inputs,
+		// 网关统一按 float 下发，需要 base64 时由网关编码
+		"encoding_format": "float",
+	}
+	if req.Dimensions > 0 {
+		body["dimensions"] = req.Dimensions
+	}
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if req.TraceID != "" {
+		httpReq.Header.Set("X-Request-Id", req.TraceID)
+	}
+	for key, values := range w.Headers {
+		for _, v := range values {
+			httpReq.Header.Add(key, v)
+		}
+	}
+
+	resp, err := w.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, throttledError(resp)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var decoded embeddingsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode embeddings response: %w", err)
+	}
+	// 按 index 还原输入顺序，部分后端不保证返回顺序
+	sort.SliceStable(decoded.Data, func(i, j int) bool { return decoded.Data[i].Index < decoded.Data[j].Index })
+	vectors := make([][]float32, len(decoded.Data))
+	for i, d := range decoded.Data {
+		vectors[i] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// embeddingsURL derives the embeddings endpoint from the chat endpoint; workers whose URL is not
+// an OpenAI chat path get "/v1/embeddings" on the same host
+func (w *HTTPWorker) embeddingsURL() (string, error) {
+	endpoint, err := url.Parse(w.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid worker URL: %w", err)
+	}
+	base, ok := strings.CutSuffix(endpoint.Path, "/chat/completions")
+	if !ok {
+		base = "/v1"
+	}
+	endpoint.Path, endpoint.RawQuery = base+"/embeddings", ""
+	return endpoint.String(), nil
+}
@@ -5,17 +5,26 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 	"zam/core"
 	"zam/openai"
+
+	"github.com/google/uuid"
 )
 
 type HTTPWorker struct {
 	id         string
 	URL        string
 	HTTPClient *http.Client
+	// Headers are extra headers sent with every request (auth, federation markers)
+	Headers http.Header
+	// Passthrough marks the upstream as speaking exact OpenAI SSE, so streams needing no gateway
+	// transformation are forwarded verbatim instead of being re-encoded chunk by chunk
+	Passthrough bool
 }
 
 func NewHTTPWorker(id, url string) *HTTPWorker {
@@ -32,6 +41,12 @@ func (w *HTTPWorker) ID() string {
 	return w.id
 }
 
+// Close releases the idle upstream connections of a worker that left the pool; running requests finish
+func (w *HTTPWorker) Close() error {
+	w.HTTPClient.CloseIdleConnections()
+	return nil
+}
+
 func (w *HTTPWorker) Heartbeat(ctx context.Context) (core.WorkerProfile, error) {
 	// TODO: 实现心跳检测
 	profile := core.WorkerProfile{
@@ -50,15 +65,59 @@ func (w *HTTPWorker) Execute(ctx context.Context, req *core.InferenceRequest, se
 		traceID = "unknown"
 	}
 
-	log.Printf("[Worker %s] [TraceID: %s] 收到请求，开始物理调用...", w.ID, traceID)
+	slog.Debug("forwarding request to worker", "worker_id", w.ID(), "trace_id", traceID, "url", w.URL)
 
 	// 创建请求体
-	requestBody, err := json.Marshal(map[string]interface{}{
+	body := map[string]interface{}{
 		"model":       req.Model,
 		"messages":    req.Messages,
 		"temperature": req.Temperature,
 		"stream":      req.Stream,
-	})
+	}
+	if req.MaxTokens > 0 {
+		body["max_tokens"] = req.MaxTokens
+	}
+	if req.User != "" {
+		body["user"] = req.User
+	}
+	// 采样参数：零值表示沿用后端默认值，不下发
+	if req.TopP > 0 {
+		body["top_p"] = req.TopP
+	}
+	if req.N > 1 {
+		body["n"] = req.N
+	}
+	if len(req.Stop) > 0 {
+		body["stop"] = req.Stop
+	}
+	if req.FrequencyPenalty != 0 {
+		body["frequency_penalty"] = req.FrequencyPenalty
+	}
+	if req.PresencePenalty != 0 {
+		body["presence_penalty"] = req.PresencePenalty
+	}
+	if req.ResponseFormat != nil {
+		body["response_format"] = req.ResponseFormat
+	}
+	if req.Tools != nil {
+		body["tools"] = req.Tools
+		if req.ToolChoice != nil {
+			body["tool_choice"] = req.ToolChoice
+		}
+	}
+	// LoRA 适配器：未常驻时要求后端懒加载
+	if req.Adapter != "" {
+		body["lora_adapter"] = req.Adapter
+		body["load_adapter"] = req.LoadAdapter
+	}
+	// 投机解码：告知后端 Draft 模型及其所在节点
+	if req.Speculative != nil {
+		body["speculative_model"] = req.Speculative.DraftModel
+		if req.Speculative.DraftWorkerID != w.id {
+			body["draft_worker"] = req.Speculative.DraftWorkerID
+		}
+	}
+	requestBody, err := json.Marshal(body)
 	if err != nil {
 		return fmt.Errorf("failed to marshal request: %w", err)
 	}
@@ -71,6 +130,15 @@ func (w *HTTPWorker) Execute(ctx context.Context, req *core.InferenceRequest, se
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Accept", "text/event-stream")
 	httpReq.Header.Set("Cache-Control", "no-cache")
+	// 透传请求 ID，便于跨网关与后端关联日志
+	if traceID != "unknown" {
+		httpReq.Header.Set("X-Request-Id", traceID)
+	}
+	for key, values := range w.Headers {
+		for _, v := range values {
+			httpReq.Header.Add(key, v)
+		}
+	}
 
 	// 发送请求
 	resp, err := w.HTTPClient.Do(httpReq)
@@ -82,6 +150,10 @@ func (w *HTTPWorker) Execute(ctx context.Context, req *core.InferenceRequest, se
 	defer resp.Body.Close()
 
 	// 检查响应状态
+	if resp.StatusCode == http.StatusTooManyRequests {
+		// 上游限流（常见于云端 Fallback）：交由网关冷却该 Worker 并换候选重试
+		return throttledError(resp)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
@@ -92,6 +164,8 @@ func (w *HTTPWorker) Execute(ctx context.Context, req *core.InferenceRequest, se
 	buf := make([]byte, 1024*1024)
 	scanner.Buffer(buf, 8*1024*1024)
 	var lineBuffer []string
+	// 记录上游是否正常结束（finish_reason 或 [DONE]）
+	state := &streamState{passthrough: w.Passthrough && req.Passthrough}
 
 	// 主循环：处理 SSE 流
 	for scanner.Scan() {
@@ -108,7 +182,7 @@ func (w *HTTPWorker) Execute(ctx context.Context, req *core.InferenceRequest, se
 		// 空行表示消息结束
 		if line == "" {
 			if len(lineBuffer) > 0 {
-				if err := processSSEMessage(lineBuffer, sender); err != nil {
+				if err := processSSEMessage(lineBuffer, sender, state); err != nil {
 					// 背压熔断：sender 返回错误时立即停止
 					return err
 				}
@@ -127,16 +201,72 @@ func (w *HTTPWorker) Execute(ctx context.Context, req *core.InferenceRequest, se
 
 	// 处理最后一个未完成的消息
 	if len(lineBuffer) > 0 {
-		if err := processSSEMessage(lineBuffer, sender); err != nil {
+		if err := processSSEMessage(lineBuffer, sender, state); err != nil {
 			return err
 		}
 	}
 
+	// 流完整性校验：连接正常关闭但上游没有给出结束标记，视为截断
+	if !state.finished {
+		return fmt.Errorf("worker %s: %w", w.ID(), core.ErrStreamTruncated)
+	}
+
 	return nil
 }
 
+// streamState tracks whether an upstream SSE stream reached a proper end
+type streamState struct {
+	finished bool
+	// passthrough attaches the raw data payload to chunks instead of fully decoding them
+	passthrough bool
+	// functionCalls marks the choices whose legacy function_call was opened, so only its first fragment gets an ID
+	functionCalls map[int]bool
+}
+
+// rawStreamResponse is the subset of a stream chunk decoded on the passthrough path:
+// just enough to count tokens and detect the end of each choice
+type rawStreamResponse struct {
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Role             string          `json:"role"`
+			Content          string          `json:"content"`
+			ReasoningContent string          `json:"reasoning_content"`
+			Reasoning        string          `json:"reasoning"`
+			ToolCalls        json.RawMessage `json:"tool_calls"`
+			FunctionCall     json.RawMessage `json:"function_call"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// processRawMessage forwards a single-choice text chunk verbatim; ok is false when the chunk
+// needs the full decode (several choices, tool or function calls, reasoning under the non-standard "reasoning" field)
+func processRawMessage(data string, sender func(chunk core.StreamChunk) error, state *streamState) (ok bool, err error) {
+	var response rawStreamResponse
+	if err := json.Unmarshal([]byte(data), &response); err != nil {
+		return false, fmt.Errorf("failed to parse SSE data: %w", err)
+	}
+	if choices := response.Choices; len(choices) != 1 || len(choices[0].Delta.ToolCalls) > 0 || len(choices[0].Delta.FunctionCall) > 0 || choices[0].Delta.Reasoning != "" {
+		return false, nil
+	}
+	choice := response.Choices[0]
+	chunk := core.StreamChunk{
+		Index:     choice.Index,
+		Role:      choice.Delta.Role,
+		Content:   choice.Delta.Content,
+		Reasoning: choice.Delta.ReasoningContent,
+		Raw:       []byte(data),
+	}
+	if choice.FinishReason != nil {
+		chunk.FinishReason = *choice.FinishReason
+		state.finished = state.finished || chunk.FinishReason != ""
+	}
+	return true, sender(chunk)
+}
+
 // processSSEMessage 处理 SSE 消息并调用 sender
-func processSSEMessage(lines []string, sender func(chunk core.StreamChunk) error) error {
+func processSSEMessage(lines []string, sender func(chunk core.StreamChunk) error, state *streamState) error {
 	var data string
 
 	for _, line := range lines {
@@ -148,9 +278,17 @@ func processSSEMessage(lines []string, sender func(chunk core.StreamChunk) error
 
 	// 检查 [DONE] 标记 - 优雅退出
 	if data == "[DONE]" {
+		state.finished = true
 		return nil
 	}
 
+	// 直通模式：只解析计数所需的字段，原始字节由网关直接转发
+	if state.passthrough {
+		if ok, err := processRawMessage(data, sender, state); ok || err != nil {
+			return err
+		}
+	}
+
 	// 解析 JSON 响应
 	var response openai.ChatCompletionStreamResponse
 	if err := json.Unmarshal([]byte(data), &response); err != nil {
@@ -161,13 +299,48 @@ func processSSEMessage(lines []string, sender func(chunk core.StreamChunk) error
 	for _, choice := range response.Choices {
 		// 检查 Context 是否已取消
 		chunk := core.StreamChunk{
+			Index:        choice.Index,
+			Role:         choice.Delta.Role,
 			Content:      choice.Delta.Content,
+			Reasoning:    choice.Delta.ReasoningContent,
 			FinishReason: "",
 			Error:        nil,
 		}
+		if chunk.Reasoning == "" {
+			chunk.Reasoning = choice.Delta.Reasoning
+		}
+		for i, call := range choice.Delta.ToolCalls {
+			index := i
+			if call.Index != nil {
+				index = *call.Index
+			}
+			chunk.ToolCalls = append(chunk.ToolCalls, core.ToolCallDelta{
+				Index:     index,
+				ID:        call.ID,
+				Type:      call.Type,
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			})
+		}
+		// 旧版 function_call 后端：转换为单个工具调用，由网关按客户端使用的接口重新输出
+		if call := choice.Delta.FunctionCall; call != nil {
+			delta := core.ToolCallDelta{Name: call.Name, Arguments: call.Arguments}
+			if !state.functionCalls[choice.Index] {
+				if state.functionCalls == nil {
+					state.functionCalls = make(map[int]bool)
+				}
+				state.functionCalls[choice.Index] = true
+				delta.ID, delta.Type = "call_"+uuid.NewString(), "function"
+			}
+			chunk.ToolCalls = append(chunk.ToolCalls, delta)
+		}
 
 		if choice.FinishReason != nil {
 			chunk.FinishReason = *choice.FinishReason
+			if chunk.FinishReason == "function_call" {
+				chunk.FinishReason = "tool_calls"
+			}
+			state.finished = state.finished || chunk.FinishReason != ""
 		}
 
 		// 背压熔断：sender 返回错误时立即停止
@@ -178,3 +351,23 @@ func processSSEMessage(lines []string, sender func(chunk core.StreamChunk) error
 
 	return nil
 }
+
+// throttledError converts a 429 response into a core.ThrottledError, reading the OpenAI error code
+// (rate_limit_exceeded / insufficient_quota) and the Retry-After header
+func throttledError(resp *http.Response) error {
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&body)
+	code := body.Error.Code
+	if code == "" {
+		code = body.Error.Type
+	}
+	return &core.ThrottledError{
+		Code:       code,
+		RetryAfter: core.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
@@ -2,6 +2,8 @@ package worker
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +11,7 @@ import (
 	"time"
 
 	"zam/core"
+	"zam/openai"
 )
 
 func TestHTTPWorkerContextCancellation(t *testing.T) {
@@ -22,7 +25,7 @@ func TestHTTPWorkerContextCancellation(t *testing.T) {
 	req := &core.InferenceRequest{
 		TraceID:    "test-001",
 		Model:      "test-model",
-		Messages:   map[string]string{"role": "user", "content": "hello"},
+		Messages:   []openai.Message{{Role: "user", Content: "hello"}},
 		Temperature: 0.7,
 		Stream:     true,
 	}
@@ -85,7 +88,7 @@ func TestHTTPWorkerChaosCancellation(t *testing.T) {
 	err := worker.Execute(ctx, &core.InferenceRequest{
 		TraceID:    "test-chaos",
 		Model:      "gpt-3.5-turbo",
-		Messages:   []map[string]string{{"role": "user", "content": "hello"}},
+		Messages:   []openai.Message{{Role: "user", Content: "hello"}},
 		Temperature: 0.7,
 		Stream:     true,
 	}, func(chunk core.StreamChunk) error {
@@ -151,11 +154,12 @@ func TestHTTPWorkerGracefulExit(t *testing.T) {
 		}),
 	}
 
-	// 在 goroutine 中启动服务器
-	go func() {
-		listener, _ := net.Listen("tcp", ":18081")
-		server.Serve(listener)
-	}()
+	// 先完成监听再启动服务器，避免客户端抢跑导致 connection refused
+	listener, err := net.Listen("tcp", ":18081")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go server.Serve(listener)
 	defer server.Close()
 
 	// 创建 HTTPWorker 指向 mock 服务器
@@ -165,10 +169,10 @@ func TestHTTPWorkerGracefulExit(t *testing.T) {
 	defer cancel()
 
 	chunks := []core.StreamChunk{}
-	err := worker.Execute(ctx, &core.InferenceRequest{
+	err = worker.Execute(ctx, &core.InferenceRequest{
 		TraceID:    "test-003",
 		Model:      "test-model",
-		Messages:   map[string]string{"role": "user", "content": "hello"},
+		Messages:   []openai.Message{{Role: "user", Content: "hello"}},
 		Temperature: 0.7,
 		Stream:     true,
 	}, func(chunk core.StreamChunk) error {
@@ -202,4 +206,311 @@ type TestError struct {
 
 func (e *TestError) Error() string {
 	return e.message
-}
\ No newline at end of file
+}
+func TestHTTPWorkerTokenize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tokenize" {
+			t.Errorf("Expected /tokenize, got %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"count":3,"tokens":[1,2,3]}`))
+	}))
+	defer server.Close()
+
+	w := NewHTTPWorker("tokenize-worker", server.URL+"/v1/chat/completions")
+	ids, err := w.Tokenize(context.Background(), "llama-8b", []openai.Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("Tokenize failed: %v", err)
+	}
+	if len(ids) != 3 {
+		t.Errorf("Expected 3 tokens, got %v", ids)
+	}
+}
+
+func TestHTTPWorkerTruncatedStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		// 只发送内容，没有 finish_reason 也没有 [DONE]
+		w.Write([]byte("data: {\"id\":\"test\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"partial\"}}]}\n\n"))
+	}))
+	defer server.Close()
+
+	worker := NewHTTPWorker("truncating-worker", server.URL)
+	err := worker.Execute(context.Background(), &core.InferenceRequest{
+		TraceID: "test-truncated",
+		Model:   "test-model",
+		Stream:  true,
+	}, func(chunk core.StreamChunk) error {
+		return nil
+	})
+	if !errors.Is(err, core.ErrStreamTruncated) {
+		t.Fatalf("expected ErrStreamTruncated, got %v", err)
+	}
+}
+
+func TestHTTPWorkerReasoningContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"reasoning_content\":\"think \"}}]}\n\n"))
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"reasoning\":\"more\"}}]}\n\n"))
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"answer\"},\"finish_reason\":\"stop\"}]}\n\n"))
+	}))
+	defer server.Close()
+
+	var reasoning, content string
+	err := NewHTTPWorker("reasoning-worker", server.URL).Execute(context.Background(), &core.InferenceRequest{
+		TraceID: "test-reasoning",
+		Model:   "deepseek-r1",
+		Stream:  true,
+	}, func(chunk core.StreamChunk) error {
+		reasoning += chunk.Reasoning
+		content += chunk.Content
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if reasoning != "think more" || content != "answer" {
+		t.Errorf("expected reasoning and answer on separate channels, got %q / %q", reasoning, content)
+	}
+}
+
+func TestHTTPWorkerThrottled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "20")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"You exceeded your current quota","type":"insufficient_quota","code":"insufficient_quota"}}`))
+	}))
+	defer server.Close()
+
+	worker := NewHTTPWorker("cloud-fallback", server.URL)
+	err := worker.Execute(context.Background(), &core.InferenceRequest{
+		TraceID: "test-throttled",
+		Model:   "gpt-4o",
+		Stream:  true,
+	}, func(chunk core.StreamChunk) error {
+		return nil
+	})
+
+	var throttled *core.ThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("expected ThrottledError, got %v", err)
+	}
+	if throttled.Code != "insufficient_quota" || throttled.RetryAfter != 20*time.Second {
+		t.Errorf("expected insufficient_quota with 20s Retry-After, got %+v", throttled)
+	}
+}
+
+func TestHTTPWorkerToolCalls(t *testing.T) {
+	var forwarded map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&forwarded)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"call_1\",\"type\":\"function\",\"function\":{\"name\":\"get_weather\",\"arguments\":\"{\\\"city\\\":\"}}]}}]}\n\n"))
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"\\\"Paris\\\"}\"}}]},\"finish_reason\":\"tool_calls\"}]}\n\n"))
+	}))
+	defer server.Close()
+
+	var calls []core.ToolCallDelta
+	err := NewHTTPWorker("tools-worker", server.URL).Execute(context.Background(), &core.InferenceRequest{
+		TraceID:    "test-tools",
+		Model:      "llama-3-8b",
+		Stream:     true,
+		Tools:      []map[string]interface{}{{"type": "function", "function": map[string]interface{}{"name": "get_weather"}}},
+		ToolChoice: "required",
+	}, func(chunk core.StreamChunk) error {
+		calls = append(calls, chunk.ToolCalls...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if forwarded["tool_choice"] != "required" || forwarded["tools"] == nil {
+		t.Errorf("expected tools and tool_choice to be forwarded, got %v", forwarded)
+	}
+	if len(calls) != 2 || calls[0].ID != "call_1" || calls[0].Name != "get_weather" || calls[0].Arguments+calls[1].Arguments != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool call fragments: %+v", calls)
+	}
+}
+
+func TestHTTPWorkerLegacyFunctionCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"function_call\":{\"name\":\"get_weather\",\"arguments\":\"{\\\"city\\\":\"}}}]}\n\n"))
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"function_call\":{\"arguments\":\"\\\"Paris\\\"}\"}},\"finish_reason\":\"function_call\"}]}\n\n"))
+	}))
+	defer server.Close()
+
+	var calls []core.ToolCallDelta
+	var finishReason string
+	err := NewHTTPWorker("functions-worker", server.URL).Execute(context.Background(), &core.InferenceRequest{
+		TraceID: "test-functions",
+		Model:   "llama-3-8b",
+		Stream:  true,
+	}, func(chunk core.StreamChunk) error {
+		calls = append(calls, chunk.ToolCalls...)
+		if chunk.FinishReason != "" {
+			finishReason = chunk.FinishReason
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(calls) != 2 || calls[0].ID == "" || calls[1].ID != "" || calls[0].Name != "get_weather" || calls[0].Arguments+calls[1].Arguments != `{"city":"Paris"}` {
+		t.Errorf("expected function_call to become one tool call, got %+v", calls)
+	}
+	if finishReason != "tool_calls" {
+		t.Errorf("expected finish_reason tool_calls, got %q", finishReason)
+	}
+}
+
+func TestHTTPWorkerPassthrough(t *testing.T) {
+	events := []string{
+		`{"id":"chatcmpl-up","object":"chat.completion.chunk","created":1700000000,"model":"llama-3-8b","choices":[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}]}`,
+		`{"id":"chatcmpl-up","object":"chat.completion.chunk","created":1700000000,"model":"llama-3-8b","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":null}]}`,
+		`{"id":"chatcmpl-up","object":"chat.completion.chunk","created":1700000000,"model":"llama-3-8b","choices":[{"index":0,"delta":{"reasoning":"hmm"},"finish_reason":null}]}`,
+		`{"id":"chatcmpl-up","object":"chat.completion.chunk","created":1700000000,"model":"llama-3-8b","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, data := range events {
+			w.Write([]byte("data: " + data + "\n\n"))
+		}
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	worker := NewHTTPWorker("native-worker", server.URL)
+	worker.Passthrough = true
+	var chunks []core.StreamChunk
+	err := worker.Execute(context.Background(), &core.InferenceRequest{
+		TraceID:     "test-passthrough",
+		Model:       "llama-3-8b",
+		Stream:      true,
+		Passthrough: true,
+	}, func(chunk core.StreamChunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(chunks) != 4 {
+		t.Fatalf("expected 4 chunks, got %d", len(chunks))
+	}
+	if string(chunks[0].Raw) != events[0] || chunks[0].Role != "assistant" {
+		t.Errorf("expected the role chunk to be forwarded verbatim, got %+v", chunks[0])
+	}
+	if string(chunks[1].Raw) != events[1] || chunks[1].Content != "Hi" {
+		t.Errorf("expected raw bytes alongside the parsed content, got %+v", chunks[1])
+	}
+	// 非标准的 reasoning 字段需要归一化，回退到完整解析
+	if chunks[2].Raw != nil || chunks[2].Reasoning != "hmm" {
+		t.Errorf("expected the non-standard reasoning chunk to be decoded, got %+v", chunks[2])
+	}
+	if string(chunks[3].Raw) != events[3] || chunks[3].FinishReason != "stop" {
+		t.Errorf("expected the finish chunk to be forwarded verbatim, got %+v", chunks[3])
+	}
+
+	// 请求未标记直通时照常解析
+	chunks = nil
+	if err := worker.Execute(context.Background(), &core.InferenceRequest{TraceID: "test-decoded", Model: "llama-3-8b", Stream: true}, func(chunk core.StreamChunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	for _, chunk := range chunks {
+		if chunk.Raw != nil {
+			t.Fatalf("expected no raw bytes without a passthrough request, got %+v", chunk)
+		}
+	}
+}
+
+func TestHTTPWorkerSamplingParameters(t *testing.T) {
+	var forwarded map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&forwarded)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"ok\"},\"finish_reason\":\"stop\"}]}\n\n"))
+	}))
+	defer server.Close()
+
+	err := NewHTTPWorker("sampling-worker", server.URL).Execute(context.Background(), &core.InferenceRequest{
+		TraceID:          "test-sampling",
+		Model:            "llama-3-8b",
+		Stream:           true,
+		MaxTokens:        64,
+		TopP:             0.5,
+		N:                2,
+		Stop:             []string{"\n\n"},
+		FrequencyPenalty: 0.25,
+		PresencePenalty:  -0.5,
+		ResponseFormat:   &openai.ResponseFormat{Type: "json_object"},
+	}, func(chunk core.StreamChunk) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	expected := map[string]interface{}{
+		"max_tokens":        float64(64),
+		"top_p":             0.5,
+		"n":                 float64(2),
+		"frequency_penalty": 0.25,
+		"presence_penalty":  -0.5,
+	}
+	for key, value := range expected {
+		if forwarded[key] != value {
+			t.Errorf("expected %s=%v to be forwarded, got %v", key, value, forwarded[key])
+		}
+	}
+	if stop, _ := forwarded["stop"].([]interface{}); len(stop) != 1 || stop[0] != "\n\n" {
+		t.Errorf("expected stop to be forwarded, got %v", forwarded["stop"])
+	}
+	if format, _ := forwarded["response_format"].(map[string]interface{}); format["type"] != "json_object" {
+		t.Errorf("expected response_format to be forwarded, got %v", forwarded["response_format"])
+	}
+
+	// 未设置的参数不下发，保留后端默认值
+	forwarded = nil
+	NewHTTPWorker("sampling-worker", server.URL).Execute(context.Background(), &core.InferenceRequest{
+		TraceID: "test-defaults",
+		Model:   "llama-3-8b",
+		Stream:  true,
+	}, func(chunk core.StreamChunk) error {
+		return nil
+	})
+	for _, key := range []string{"top_p", "n", "stop", "frequency_penalty", "presence_penalty", "response_format"} {
+		if _, ok := forwarded[key]; ok {
+			t.Errorf("expected %s to be omitted when unset, got %v", key, forwarded[key])
+		}
+	}
+}
+
+func TestHTTPWorkerEmbed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("Expected /v1/embeddings, got %s", r.URL.Path)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["dimensions"] != float64(256) {
+			t.Errorf("Expected dimensions 256 to be forwarded, got %v", body["dimensions"])
+		}
+		w.Header().Set("Content-Type", "application/json")
+		// 故意乱序返回，验证按 index 还原
+		w.Write([]byte(`{"object":"list","data":[{"index":1,"embedding":[0.3,0.4]},{"index":0,"embedding":[0.1,0.2]}]}`))
+	}))
+	defer server.Close()
+
+	w := NewHTTPWorker("embed-worker", server.URL+"/v1/chat/completions")
+	vectors, err := w.Embed(context.Background(), &core.EmbedRequest{Model: "bge-m3", Inputs: []string{"a", "b"}, Dimensions: 256})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 0.1 || vectors[1][0] != 0.3 {
+		t.Errorf("Expected vectors in input order, got %v", vectors)
+	}
+}
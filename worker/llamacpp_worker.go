@@ -0,0 +1,201 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+
+	"zam/core"
+)
+
+// LlamaCppWorker talks to a llama.cpp server through its native /completion endpoint (SSE)
+// The server runs one model with a fixed number of slots; /props reports both, /slots the busy ones
+type LlamaCppWorker struct {
+	id         string
+	BaseURL    string
+	HTTPClient *http.Client
+	// Headers are extra headers sent with every request (e.g. Authorization for --api-key)
+	Headers http.Header
+	// Models are extra routable names of the served model; the GGUF file name from /props is always included
+	Models []string
+	// VRAM is the GPU memory of the host; llama.cpp does not report it
+	VRAM uint64
+	// Template renders chat messages into the prompt; /completion does not apply the model's chat template
+	Template ChatTemplate
+}
+
+// NewLlamaCppWorker creates a LlamaCppWorker for the server at baseURL (e.g. "http://10.0.0.7:8080")
+func NewLlamaCppWorker(id, baseURL string) *LlamaCppWorker {
+	return &LlamaCppWorker{
+		id:         id,
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{},
+		Template:   DefaultChatTemplate,
+	}
+}
+
+func (w *LlamaCppWorker) ID() string {
+	return w.id
+}
+
+// llamaCppProps is the subset of /props used for the profile
+type llamaCppProps struct {
+	ModelPath                 string `json:"model_path"`
+	TotalSlots                int    `json:"total_slots"`
+	DefaultGenerationSettings struct {
+		NCtx int `json:"n_ctx"`
+	} `json:"default_generation_settings"`
+}
+
+// llamaCppSlot is one entry of /slots; older servers report state 1 instead of is_processing
+type llamaCppSlot struct {
+	IsProcessing bool `json:"is_processing"`
+	State        int  `json:"state"`
+}
+
+// Heartbeat builds the profile from /props: the slot count is the worker's concurrency and the
+// per-slot context its context window. Busy slots come from /slots, which servers started with
+// --no-slots do not expose; they then report no active tasks
+func (w *LlamaCppWorker) Heartbeat(ctx context.Context) (core.WorkerProfile, error) {
+	var props llamaCppProps
+	if err := getJSON(ctx, w.HTTPClient, w.Headers, w.BaseURL+"/props", &props); err != nil {
+		return core.WorkerProfile{}, err
+	}
+
+	supported := append([]string{}, w.Models...)
+	if props.ModelPath != "" {
+		supported = append(supported, strings.TrimSuffix(path.Base(props.ModelPath), ".gguf"))
+	}
+	profile := core.WorkerProfile{
+		WorkerID:  w.id,
+		Supported: supported,
+		TotalVRAM: w.VRAM,
+		// 模型与各槽位的 KV 缓存在启动时已分配，这里按满额上报
+		AvailableVRAM: w.VRAM,
+		MaxTasks:      props.TotalSlots,
+		Capabilities: core.Capabilities{
+			MaxContext: props.DefaultGenerationSettings.NCtx,
+		},
+	}
+
+	var slots []llamaCppSlot
+	if err := getJSON(ctx, w.HTTPClient, w.Headers, w.BaseURL+"/slots", &slots); err != nil {
+		slog.Debug("llama.cpp slots unavailable", "worker_id", w.id, "error", err)
+		return profile, nil
+	}
+	for _, s := range slots {
+		if s.IsProcessing || s.State != 0 {
+			profile.ActiveTasks++
+		}
+	}
+	return profile, nil
+}
+
+// llamaCppStreamResponse is one /completion SSE event
+type llamaCppStreamResponse struct {
+	Content string `json:"content"`
+	Stop    bool   `json:"stop"`
+	// StopType is "eos", "word" or "limit"; older servers set StoppedLimit instead
+	StopType     string `json:"stop_type"`
+	StoppedLimit bool   `json:"stopped_limit"`
+	Error        *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (w *LlamaCppWorker) Execute(ctx context.Context, req *core.InferenceRequest, sender func(chunk core.StreamChunk) error) error {
+	traceID, _ := ctx.Value(core.TraceKey).(string)
+	slog.Debug("forwarding request to llama.cpp /completion", "worker_id", w.id, "trace_id", traceID)
+
+	body := map[string]interface{}{
+		"prompt": w.Template(req.Messages),
+		"stream": true,
+		// 复用同一槽位中已缓存的公共前缀
+		"cache_prompt": true,
+	}
+	if req.Temperature > 0 {
+		body["temperature"] = req.Temperature
+	}
+	if req.MaxTokens > 0 {
+		body["n_predict"] = req.MaxTokens
+	}
+	if req.TopP > 0 {
+		body["top_p"] = req.TopP
+	}
+	if len(req.Stop) > 0 {
+		body["stop"] = req.Stop
+	}
+	if req.FrequencyPenalty != 0 {
+		body["frequency_penalty"] = req.FrequencyPenalty
+	}
+	if req.PresencePenalty != 0 {
+		body["presence_penalty"] = req.PresencePenalty
+	}
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.BaseURL+"/completion", bytes.NewReader(requestBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	setHeaders(httpReq, w.Headers)
+
+	resp, err := w.HTTPClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// 流中出错时 llama.cpp 以 "error: {...}" 行报告
+		if strings.HasPrefix(line, "error:") {
+			return fmt.Errorf("llama.cpp error: %s", strings.TrimSpace(strings.TrimPrefix(line, "error:")))
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var event llamaCppStreamResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return fmt.Errorf("failed to parse llama.cpp event: %w", err)
+		}
+		if event.Error != nil {
+			return fmt.Errorf("llama.cpp error: %s", event.Error.Message)
+		}
+
+		chunk := core.StreamChunk{Content: event.Content}
+		if event.Stop {
+			chunk.FinishReason = "stop"
+			if event.StopType == "limit" || event.StoppedLimit {
+				chunk.FinishReason = "length"
+			}
+		}
+		if err := sender(chunk); err != nil {
+			return err
+		}
+		if event.Stop {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to scan response: %w", err)
+	}
+	return fmt.Errorf("worker %s: %w", w.id, core.ErrStreamTruncated)
+}
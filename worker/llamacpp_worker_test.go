@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"zam/core"
+	"zam/openai"
+)
+
+func TestLlamaCppWorker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/props":
+			w.Write([]byte(`{"model_path":"/models/Meta-Llama-3-8B-Instruct-Q4_K_M.gguf","total_slots":4,"default_generation_settings":{"n_ctx":8192}}`))
+		case "/slots":
+			w.Write([]byte(`[{"id":0,"is_processing":true},{"id":1,"is_processing":false},{"id":2,"state":1},{"id":3,"state":0}]`))
+		case "/completion":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["stream"] != true || body["n_predict"] != float64(2) || body["prompt"] != "user: hi\nassistant:" {
+				t.Errorf("unexpected /completion body: %v", body)
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: {\"content\":\"Hel\",\"stop\":false}\n\n"))
+			w.Write([]byte("data: {\"content\":\"lo\",\"stop\":false}\n\n"))
+			w.Write([]byte("data: {\"content\":\"\",\"stop\":true,\"stop_type\":\"limit\"}\n\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	llama := NewLlamaCppWorker("llamacpp-1", server.URL)
+	llama.Models = []string{"llama-8b"}
+	profile, err := llama.Heartbeat(context.Background())
+	if err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	if len(profile.Supported) != 2 || profile.Supported[1] != "Meta-Llama-3-8B-Instruct-Q4_K_M" {
+		t.Errorf("expected configured name and GGUF file name, got %v", profile.Supported)
+	}
+	if profile.MaxTasks != 4 || profile.ActiveTasks != 2 || profile.Capabilities.MaxContext != 8192 {
+		t.Errorf("expected 4 slots with 2 busy and 8192 context, got %+v", profile)
+	}
+
+	var content, finish string
+	err = llama.Execute(context.Background(), &core.InferenceRequest{
+		Model:     "llama-8b",
+		Messages:  []openai.Message{{Role: "user", Content: "hi"}},
+		MaxTokens: 2,
+	}, func(chunk core.StreamChunk) error {
+		content += chunk.Content
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if content != "Hello" || finish != "length" {
+		t.Errorf("expected Hello with finish length, got %q / %q", content, finish)
+	}
+}
@@ -0,0 +1,184 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"zam/core"
+)
+
+// LMStudioWorker targets the local server of LM Studio (default http://localhost:1234)
+// Chat is OpenAI compatible and shares the SSE parser with HTTPWorker; loaded models are discovered
+// through the REST API (/api/v0/models), falling back to /v1/models on older versions
+type LMStudioWorker struct {
+	*HTTPWorker
+	baseURL string
+	// VRAM is the GPU memory of the host; LM Studio does not report it
+	VRAM     uint64
+	MaxTasks int
+
+	mu sync.RWMutex
+	// names maps normalized model names to the ids LM Studio expects
+	names map[string]string
+}
+
+// NewLMStudioWorker creates an LMStudioWorker for the server at baseURL (e.g. "http://localhost:1234")
+func NewLMStudioWorker(id, baseURL string) *LMStudioWorker {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &LMStudioWorker{
+		HTTPWorker: NewHTTPWorker(id, baseURL+"/v1/chat/completions"),
+		baseURL:    baseURL,
+		MaxTasks:   4,
+		names:      make(map[string]string),
+	}
+}
+
+// lmStudioModel is one entry of /api/v0/models (or /v1/models, where only ID is set)
+type lmStudioModel struct {
+	ID               string `json:"id"`
+	Type             string `json:"type"`
+	State            string `json:"state"`
+	MaxContextLength int    `json:"max_context_length"`
+}
+
+// Heartbeat reports the models currently loaded in LM Studio
+// Each model is routable by both its LM Studio id and its normalized name
+func (w *LMStudioWorker) Heartbeat(ctx context.Context) (core.WorkerProfile, error) {
+	models, err := w.listModels(ctx, "/api/v0/models")
+	if err != nil {
+		// 旧版本没有 REST API，/v1/models 只返回 ID，视为全部已加载
+		if models, err = w.listModels(ctx, "/v1/models"); err != nil {
+			return core.WorkerProfile{}, err
+		}
+	}
+
+	names := make(map[string]string)
+	var supported []string
+	var caps core.Capabilities
+	for _, m := range models {
+		if m.State != "" && m.State != "loaded" {
+			continue
+		}
+		supported = append(supported, m.ID)
+		if name := NormalizeLMStudioModel(m.ID); name != m.ID {
+			if _, dup := names[name]; !dup {
+				names[name] = m.ID
+				supported = append(supported, name)
+			}
+		}
+
+		switch m.Type {
+		case "embeddings":
+			caps.Embeddings = true
+		case "vlm":
+			caps.Vision = true
+		}
+		// 多模型时取最小上下文，保证任一模型都能容纳
+		if m.MaxContextLength > 0 && (caps.MaxContext == 0 || m.MaxContextLength < caps.MaxContext) {
+			caps.MaxContext = m.MaxContextLength
+		}
+	}
+	w.setNames(names)
+
+	return core.WorkerProfile{
+		WorkerID:      w.id,
+		Supported:     supported,
+		TotalVRAM:     w.VRAM,
+		AvailableVRAM: w.VRAM,
+		MaxTasks:      w.MaxTasks,
+		Capabilities:  caps,
+	}, nil
+}
+
+// Execute forwards the request with the model name LM Studio expects
+func (w *LMStudioWorker) Execute(ctx context.Context, req *core.InferenceRequest, sender func(chunk core.StreamChunk) error) error {
+	forwarded := *req
+	if id, ok := w.lookupName(req.Model); ok {
+		forwarded.Model = id
+	}
+	// 共用 HTTPWorker 的 SSE 解析，始终以流式请求
+	forwarded.Stream = true
+	return w.HTTPWorker.Execute(ctx, &forwarded, sender)
+}
+
+// Embed forwards the request with the model name LM Studio expects
+func (w *LMStudioWorker) Embed(ctx context.Context, req *core.EmbedRequest) ([][]float32, error) {
+	forwarded := *req
+	if id, ok := w.lookupName(req.Model); ok {
+		forwarded.Model = id
+	}
+	return w.HTTPWorker.Embed(ctx, &forwarded)
+}
+
+// NormalizeLMStudioModel turns LM Studio ids like
+// "lmstudio-community/Meta-Llama-3-8B-Instruct-GGUF/Meta-Llama-3-8B-Instruct-Q4_K_M.gguf" or "qwen2.5-7b-instruct:2"
+// into plain lowercase names ("meta-llama-3-8b-instruct-q4_k_m", "qwen2.5-7b-instruct")
+func NormalizeLMStudioModel(id string) string {
+	name := path.Base(id)
+	name = strings.TrimSuffix(name, ".gguf")
+	// 同一模型加载多份时 LM Studio 会追加 ":N" 实例后缀
+	if base, suffix, ok := strings.Cut(name, ":"); ok && isDigits(suffix) {
+		name = base
+	}
+	return strings.ToLower(name)
+}
+
+func (w *LMStudioWorker) setNames(names map[string]string) {
+	w.mu.Lock()
+	w.names = names
+	w.mu.Unlock()
+}
+
+func (w *LMStudioWorker) lookupName(name string) (string, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	id, ok := w.names[name]
+	return id, ok
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *LMStudioWorker) listModels(ctx context.Context, path string) ([]lmStudioModel, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, w.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range w.Headers {
+		for _, v := range values {
+			httpReq.Header.Add(key, v)
+		}
+	}
+
+	resp, err := w.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code from %s: %d", path, resp.StatusCode)
+	}
+
+	var list struct {
+		Data []lmStudioModel `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return list.Data, nil
+}
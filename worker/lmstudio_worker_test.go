@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"zam/core"
+	"zam/openai"
+)
+
+func TestLMStudioWorker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v0/models":
+			w.Write([]byte(`{"data":[
+				{"id":"lmstudio-community/Meta-Llama-3-8B-Instruct-GGUF/Meta-Llama-3-8B-Instruct-Q4_K_M.gguf","type":"llm","state":"loaded","max_context_length":8192},
+				{"id":"qwen2-vl-7b-instruct","type":"vlm","state":"loaded","max_context_length":32768},
+				{"id":"mistral-7b-instruct","type":"llm","state":"not-loaded","max_context_length":32768}
+			]}`))
+		case "/v1/chat/completions":
+			var body struct {
+				Model  string `json:"model"`
+				Stream bool   `json:"stream"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.Model != "lmstudio-community/Meta-Llama-3-8B-Instruct-GGUF/Meta-Llama-3-8B-Instruct-Q4_K_M.gguf" || !body.Stream {
+				t.Errorf("expected LM Studio id and stream, got %+v", body)
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"},\"finish_reason\":\"stop\"}]}\n\n"))
+			w.Write([]byte("data: [DONE]\n\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	lms := NewLMStudioWorker("lmstudio-1", server.URL)
+	profile, err := lms.Heartbeat(context.Background())
+	if err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	if len(profile.Supported) != 3 || profile.Supported[1] != "meta-llama-3-8b-instruct-q4_k_m" {
+		t.Errorf("expected loaded models with normalized names, got %v", profile.Supported)
+	}
+	if !profile.Capabilities.Vision || profile.Capabilities.MaxContext != 8192 {
+		t.Errorf("unexpected capabilities: %+v", profile.Capabilities)
+	}
+
+	var content string
+	err = lms.Execute(context.Background(), &core.InferenceRequest{
+		Model:    "meta-llama-3-8b-instruct-q4_k_m",
+		Messages: []openai.Message{{Role: "user", Content: "hi"}},
+	}, func(chunk core.StreamChunk) error {
+		content += chunk.Content
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if content != "Hi" {
+		t.Errorf("expected Hi, got %q", content)
+	}
+}
+
+func TestLMStudioWorker_LegacyModelList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"data":[{"id":"qwen2.5-7b-instruct:2","object":"model"}]}`))
+	}))
+	defer server.Close()
+
+	profile, err := NewLMStudioWorker("lmstudio-1", server.URL).Heartbeat(context.Background())
+	if err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	if len(profile.Supported) != 2 || profile.Supported[1] != "qwen2.5-7b-instruct" {
+		t.Errorf("expected fallback listing with instance suffix stripped, got %v", profile.Supported)
+	}
+}
@@ -0,0 +1,266 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"zam/core"
+	"zam/openai"
+
+	"github.com/google/uuid"
+)
+
+// OllamaWorker talks to an Ollama server (default http://localhost:11434) through its native
+// /api/chat endpoint, which streams newline-delimited JSON; pulled models are discovered from /api/tags
+type OllamaWorker struct {
+	id         string
+	BaseURL    string
+	HTTPClient *http.Client
+	// Headers are extra headers sent with every request (e.g. Authorization behind a proxy)
+	Headers http.Header
+	// VRAM is the GPU memory of the host; Ollama does not report it
+	VRAM uint64
+	// MaxTasks should match the server's OLLAMA_NUM_PARALLEL
+	MaxTasks int
+	// KeepAlive is how long Ollama keeps a model loaded after a request (e.g. "30m"; empty = server default)
+	KeepAlive string
+
+	mu sync.RWMutex
+	// names maps routable names without the ":latest" tag to the names Ollama expects
+	names map[string]string
+}
+
+// NewOllamaWorker creates an OllamaWorker for the server at baseURL (e.g. "http://localhost:11434")
+func NewOllamaWorker(id, baseURL string) *OllamaWorker {
+	return &OllamaWorker{
+		id:         id,
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{},
+		MaxTasks:   4,
+		names:      make(map[string]string),
+	}
+}
+
+func (w *OllamaWorker) ID() string {
+	return w.id
+}
+
+// ollamaTags is the response of /api/tags
+type ollamaTags struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
+}
+
+// Heartbeat reports the models pulled on the server
+// Models tagged ":latest" are also routable by their bare name ("llama3:latest" -> "llama3")
+func (w *OllamaWorker) Heartbeat(ctx context.Context) (core.WorkerProfile, error) {
+	var tags ollamaTags
+	if err := getJSON(ctx, w.HTTPClient, w.Headers, w.BaseURL+"/api/tags", &tags); err != nil {
+		return core.WorkerProfile{}, err
+	}
+
+	names := make(map[string]string)
+	var supported []string
+	for _, m := range tags.Models {
+		supported = append(supported, m.Name)
+		if base, ok := strings.CutSuffix(m.Name, ":latest"); ok {
+			names[base] = m.Name
+			supported = append(supported, base)
+		}
+	}
+	w.mu.Lock()
+	w.names = names
+	w.mu.Unlock()
+
+	return core.WorkerProfile{
+		WorkerID:  w.id,
+		Supported: supported,
+		TotalVRAM: w.VRAM,
+		// Ollama 按需加载与卸载模型，这里按满额上报
+		AvailableVRAM: w.VRAM,
+		MaxTasks:      w.MaxTasks,
+	}, nil
+}
+
+// ollamaMessage is a chat message in Ollama's format; tool call arguments are JSON objects, not strings
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// ollamaChatResponse is one line of the /api/chat stream
+type ollamaChatResponse struct {
+	Message    ollamaMessage `json:"message"`
+	Done       bool          `json:"done"`
+	DoneReason string        `json:"done_reason"`
+	Error      string        `json:"error"`
+}
+
+func (w *OllamaWorker) Execute(ctx context.Context, req *core.InferenceRequest, sender func(chunk core.StreamChunk) error) error {
+	traceID, _ := ctx.Value(core.TraceKey).(string)
+	model := req.Model
+	w.mu.RLock()
+	if name, ok := w.names[model]; ok {
+		model = name
+	}
+	w.mu.RUnlock()
+	slog.Debug("forwarding request to Ollama /api/chat", "worker_id", w.id, "trace_id", traceID, "model", model)
+
+	options := map[string]interface{}{}
+	if req.Temperature > 0 {
+		options["temperature"] = req.Temperature
+	}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+	if req.TopP > 0 {
+		options["top_p"] = req.TopP
+	}
+	if len(req.Stop) > 0 {
+		options["stop"] = req.Stop
+	}
+	if req.FrequencyPenalty != 0 {
+		options["frequency_penalty"] = req.FrequencyPenalty
+	}
+	if req.PresencePenalty != 0 {
+		options["presence_penalty"] = req.PresencePenalty
+	}
+	body := map[string]interface{}{
+		"model":    model,
+		"messages": ollamaMessages(req.Messages),
+		"stream":   true,
+		"options":  options,
+	}
+	if req.Tools != nil {
+		body["tools"] = req.Tools
+	}
+	// Ollama 的 format 接受 "json" 或 JSON Schema
+	if rf := req.ResponseFormat; rf != nil && rf.Type != "" && rf.Type != "text" {
+		body["format"] = "json"
+		var schema struct {
+			Schema json.RawMessage `json:"schema"`
+		}
+		if rf.Type == "json_schema" && json.Unmarshal(rf.JSONSchema, &schema) == nil && len(schema.Schema) > 0 {
+			body["format"] = schema.Schema
+		}
+	}
+	if w.KeepAlive != "" {
+		body["keep_alive"] = w.KeepAlive
+	}
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.BaseURL+"/api/chat", bytes.NewReader(requestBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/x-ndjson")
+	setHeaders(httpReq, w.Headers)
+
+	resp, err := w.HTTPClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+	toolCalls := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var event ollamaChatResponse
+		if err := json.Unmarshal(line, &event); err != nil {
+			return fmt.Errorf("failed to parse Ollama response: %w", err)
+		}
+		if event.Error != "" {
+			return fmt.Errorf("Ollama error: %s", event.Error)
+		}
+
+		chunk := core.StreamChunk{
+			Content:   event.Message.Content,
+			Reasoning: event.Message.Thinking,
+		}
+		// Ollama 一次给出完整的工具调用，参数为 JSON 对象，需转换为字符串
+		for _, call := range event.Message.ToolCalls {
+			chunk.ToolCalls = append(chunk.ToolCalls, core.ToolCallDelta{
+				Index:     toolCalls,
+				ID:        "call_" + uuid.NewString(),
+				Type:      "function",
+				Name:      call.Function.Name,
+				Arguments: string(call.Function.Arguments),
+			})
+			toolCalls++
+		}
+		if event.Done {
+			chunk.FinishReason = ollamaFinishReason(event.DoneReason, toolCalls > 0)
+		}
+		if err := sender(chunk); err != nil {
+			return err
+		}
+		if event.Done {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to scan response: %w", err)
+	}
+	return fmt.Errorf("worker %s: %w", w.id, core.ErrStreamTruncated)
+}
+
+// ollamaMessages converts OpenAI messages to Ollama's format
+func ollamaMessages(messages []openai.Message) []ollamaMessage {
+	converted := make([]ollamaMessage, len(messages))
+	for i, m := range messages {
+		converted[i] = ollamaMessage{Role: m.Role, Content: m.Content}
+		for _, call := range m.ToolCalls {
+			var tc ollamaToolCall
+			tc.Function.Name = call.Function.Name
+			tc.Function.Arguments = json.RawMessage(call.Function.Arguments)
+			if !json.Valid(tc.Function.Arguments) {
+				tc.Function.Arguments = json.RawMessage("{}")
+			}
+			converted[i].ToolCalls = append(converted[i].ToolCalls, tc)
+		}
+	}
+	return converted
+}
+
+// ollamaFinishReason maps Ollama done reasons onto OpenAI finish reasons
+func ollamaFinishReason(reason string, toolCalls bool) string {
+	switch {
+	case toolCalls:
+		return "tool_calls"
+	case reason == "length":
+		return "length"
+	}
+	// stop, load, unload
+	return "stop"
+}
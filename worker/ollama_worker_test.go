@@ -0,0 +1,105 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"zam/core"
+	"zam/openai"
+)
+
+func TestOllamaWorker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			w.Write([]byte(`{"models":[{"name":"llama3:latest"},{"name":"qwen2.5:7b-instruct-q4_K_M"}]}`))
+		case "/api/chat":
+			var body struct {
+				Model   string                 `json:"model"`
+				Stream  bool                   `json:"stream"`
+				Options map[string]interface{} `json:"options"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.Model != "llama3:latest" || !body.Stream || body.Options["num_predict"] != float64(16) {
+				t.Errorf("expected tagged model, stream and num_predict, got %+v", body)
+			}
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Write([]byte(`{"model":"llama3:latest","message":{"role":"assistant","content":"Hel"},"done":false}` + "\n"))
+			w.Write([]byte(`{"model":"llama3:latest","message":{"role":"assistant","content":"lo"},"done":false}` + "\n"))
+			w.Write([]byte(`{"model":"llama3:latest","message":{"role":"assistant","content":""},"done":true,"done_reason":"length","eval_count":2}` + "\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ollama := NewOllamaWorker("ollama-1", server.URL)
+	profile, err := ollama.Heartbeat(context.Background())
+	if err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	if len(profile.Supported) != 3 || profile.Supported[1] != "llama3" {
+		t.Errorf("expected pulled models plus llama3 without :latest, got %v", profile.Supported)
+	}
+
+	var content, finish string
+	err = ollama.Execute(context.Background(), &core.InferenceRequest{
+		Model:     "llama3",
+		Messages:  []openai.Message{{Role: "user", Content: "hi"}},
+		MaxTokens: 16,
+	}, func(chunk core.StreamChunk) error {
+		content += chunk.Content
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if content != "Hello" || finish != "length" {
+		t.Errorf("expected Hello with finish length, got %q / %q", content, finish)
+	}
+}
+
+func TestOllamaWorker_ToolCallsAndTruncation(t *testing.T) {
+	done := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Paris"}}}]},"done":false}` + "\n"))
+		if done {
+			w.Write([]byte(`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}` + "\n"))
+		}
+	}))
+	defer server.Close()
+
+	ollama := NewOllamaWorker("ollama-1", server.URL)
+	var calls []core.ToolCallDelta
+	var finish string
+	err := ollama.Execute(context.Background(), &core.InferenceRequest{Model: "llama3.1"}, func(chunk core.StreamChunk) error {
+		calls = append(calls, chunk.ToolCalls...)
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(calls) != 1 || calls[0].Name != "get_weather" || calls[0].Arguments != `{"city":"Paris"}` || calls[0].ID == "" {
+		t.Errorf("expected one get_weather call with string arguments, got %+v", calls)
+	}
+	if finish != "tool_calls" {
+		t.Errorf("expected finish reason tool_calls, got %q", finish)
+	}
+
+	// 没有 done 行即断开视为截断
+	done = false
+	err = ollama.Execute(context.Background(), &core.InferenceRequest{Model: "llama3.1"}, func(chunk core.StreamChunk) error { return nil })
+	if !errors.Is(err, core.ErrStreamTruncated) {
+		t.Errorf("expected ErrStreamTruncated, got %v", err)
+	}
+}
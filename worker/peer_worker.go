@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"zam/core"
+	"zam/openai"
+)
+
+// PeerWorker exposes another ZAM gateway as a single "super worker"
+// Requests are forwarded to the peer's OpenAI endpoint and marked so the peer never forwards them again
+type PeerWorker struct {
+	*HTTPWorker
+	baseURL string
+	// token authenticates against the peer's federation profile endpoint
+	token string
+}
+
+// NewPeerWorker creates a PeerWorker for the gateway at baseURL (e.g. "http://10.0.0.2:8080")
+// apiKey is used for chat requests, token for the federation profile endpoint
+func NewPeerWorker(id, baseURL, apiKey, token string) *PeerWorker {
+	baseURL = strings.TrimRight(baseURL, "/")
+	w := &PeerWorker{
+		HTTPWorker: NewHTTPWorker(id, baseURL+"/v1/chat/completions"),
+		baseURL:    baseURL,
+		token:      token,
+	}
+	w.Headers = http.Header{}
+	w.Headers.Set("Authorization", "Bearer "+apiKey)
+	w.Headers.Set(core.FederationHeader, "1")
+	return w
+}
+
+// Heartbeat fetches the peer's aggregated local capacity
+func (w *PeerWorker) Heartbeat(ctx context.Context) (core.WorkerProfile, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, w.baseURL+"/v1/federation/profile", nil)
+	if err != nil {
+		return core.WorkerProfile{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	if w.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+w.token)
+	}
+
+	resp, err := w.HTTPClient.Do(httpReq)
+	if err != nil {
+		return core.WorkerProfile{}, fmt.Errorf("failed to fetch peer profile: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return core.WorkerProfile{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var profile core.WorkerProfile
+	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
+		return core.WorkerProfile{}, fmt.Errorf("failed to decode peer profile: %w", err)
+	}
+
+	// 以本地配置的 ID 注册，并标记为对等网关
+	profile.WorkerID = w.id
+	profile.Peer = true
+	return profile, nil
+}
+
+// Execute forwards the request to the peer gateway
+// The peer is always asked to stream because the SSE parser is shared with HTTPWorker
+func (w *PeerWorker) Execute(ctx context.Context, req *core.InferenceRequest, sender func(chunk core.StreamChunk) error) error {
+	forwarded := *req
+	forwarded.Model = req.RequestedModel
+	if forwarded.Model == "" {
+		forwarded.Model = req.Model
+	}
+	// 对端网关自行解析 LoRA 与投机解码
+	forwarded.Adapter = ""
+	forwarded.Speculative = nil
+	forwarded.Stream = true
+	return w.HTTPWorker.Execute(ctx, &forwarded, sender)
+}
+
+// Tokenize asks the peer gateway's /v1/tokenize endpoint, which resolves a worker on its side
+func (w *PeerWorker) Tokenize(ctx context.Context, model string, messages []openai.Message) ([]int, error) {
+	return w.postTokenize(ctx, w.baseURL+"/v1/tokenize", map[string]interface{}{
+		"model":            model,
+		"messages":         messages,
+		"return_token_ids": true,
+	})
+}
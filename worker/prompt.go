@@ -0,0 +1,24 @@
+package worker
+
+import (
+	"strings"
+
+	"zam/openai"
+)
+
+// ChatTemplate renders chat messages into a single prompt for completion-style backends
+type ChatTemplate func(messages []openai.Message) string
+
+// DefaultChatTemplate renders "role: content" lines followed by an open assistant turn
+// Backends with a model-specific template should be configured with their own ChatTemplate
+func DefaultChatTemplate(messages []openai.Message) string {
+	var b strings.Builder
+	for _, m := range messages {
+		b.WriteString(m.Role)
+		b.WriteString(": ")
+		b.WriteString(m.Content)
+		b.WriteString("\n")
+	}
+	b.WriteString("assistant:")
+	return b.String()
+}
@@ -0,0 +1,330 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"zam/core"
+	"zam/openai"
+
+	"github.com/google/uuid"
+	"golang.org/x/net/websocket"
+)
+
+// Frame types of the reverse-connection protocol. A worker behind NAT dials the gateway, sends
+// register and then heartbeat frames; the gateway answers each with directives and pushes execute
+// and cancel frames, which the worker answers with chunk frames and a final done frame per job
+const (
+	ReverseRegister   = "register"
+	ReverseHeartbeat  = "heartbeat"
+	ReverseDirectives = "directives"
+	ReverseExecute    = "execute"
+	ReverseCancel     = "cancel"
+	ReverseChunk      = "chunk"
+	ReverseDone       = "done"
+	// ReverseError rejects a registration before the gateway closes the connection
+	ReverseError = "error"
+)
+
+// ErrReverseDisconnected is returned for jobs whose reverse connection closed before they finished
+var ErrReverseDisconnected = errors.New("reverse connection closed")
+
+// ErrReverseJobOverflow is returned for jobs whose frames arrived faster than the caller consumed them
+var ErrReverseJobOverflow = errors.New("reverse job fell behind its worker")
+
+// reverseWriteTimeout bounds a single frame write; a worker that stops reading loses its connection
+const reverseWriteTimeout = 10 * time.Second
+
+// reverseJobBuffer is the number of frames buffered per job before the job is abandoned
+const reverseJobBuffer = 64
+
+// ReverseFrame is one JSON message of the reverse-connection protocol
+type ReverseFrame struct {
+	Type string `json:"type"`
+	// ID is the job an execute, cancel, chunk or done frame belongs to
+	ID         string                 `json:"id,omitempty"`
+	Profile    *core.WorkerProfile    `json:"profile,omitempty"`
+	Directives *core.WorkerDirectives `json:"directives,omitempty"`
+	Request    *ReverseRequest        `json:"request,omitempty"`
+	Chunk      *ReverseStreamChunk    `json:"chunk,omitempty"`
+	// Error is the failure of a job (done) or the reason a registration was rejected (error)
+	Error string `json:"error,omitempty"`
+}
+
+// ReverseRequest is the wire form of an InferenceRequest pushed to a reverse worker. It carries only
+// what the worker needs to generate: routing and tenant fields such as the caller's API key and
+// session never leave the gateway
+type ReverseRequest struct {
+	TraceID          string                 `json:"trace_id,omitempty"`
+	Model            string                 `json:"model"`
+	Adapter          string                 `json:"adapter,omitempty"`
+	LoadAdapter      bool                   `json:"load_adapter,omitempty"`
+	Messages         []openai.Message       `json:"messages"`
+	Temperature      float32                `json:"temperature,omitempty"`
+	TopP             float32                `json:"top_p,omitempty"`
+	N                int                    `json:"n,omitempty"`
+	Stop             []string               `json:"stop,omitempty"`
+	FrequencyPenalty float32                `json:"frequency_penalty,omitempty"`
+	PresencePenalty  float32                `json:"presence_penalty,omitempty"`
+	MaxTokens        int                    `json:"max_tokens,omitempty"`
+	Tools            interface{}            `json:"tools,omitempty"`
+	ToolChoice       interface{}            `json:"tool_choice,omitempty"`
+	ResponseFormat   *openai.ResponseFormat `json:"response_format,omitempty"`
+}
+
+// NewReverseRequest converts an InferenceRequest to its wire form
+func NewReverseRequest(req *core.InferenceRequest) *ReverseRequest {
+	return &ReverseRequest{
+		TraceID:          req.TraceID,
+		Model:            req.Model,
+		Adapter:          req.Adapter,
+		LoadAdapter:      req.LoadAdapter,
+		Messages:         req.Messages,
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		N:                req.N,
+		Stop:             req.Stop,
+		FrequencyPenalty: req.FrequencyPenalty,
+		PresencePenalty:  req.PresencePenalty,
+		MaxTokens:        req.MaxTokens,
+		Tools:            req.Tools,
+		ToolChoice:       req.ToolChoice,
+		ResponseFormat:   req.ResponseFormat,
+	}
+}
+
+// InferenceRequest converts the wire form back to an InferenceRequest
+func (r *ReverseRequest) InferenceRequest() *core.InferenceRequest {
+	return &core.InferenceRequest{
+		TraceID:          r.TraceID,
+		RequestedModel:   r.Model,
+		Model:            r.Model,
+		Adapter:          r.Adapter,
+		LoadAdapter:      r.LoadAdapter,
+		Messages:         r.Messages,
+		Temperature:      r.Temperature,
+		TopP:             r.TopP,
+		N:                r.N,
+		Stop:             r.Stop,
+		FrequencyPenalty: r.FrequencyPenalty,
+		PresencePenalty:  r.PresencePenalty,
+		MaxTokens:        r.MaxTokens,
+		Tools:            r.Tools,
+		ToolChoice:       r.ToolChoice,
+		ResponseFormat:   r.ResponseFormat,
+	}
+}
+
+// ReverseStreamChunk is the wire form of a StreamChunk; errors travel in the done frame and
+// streams are always re-encoded by the gateway, so Error and Raw are not carried
+type ReverseStreamChunk struct {
+	Index        int                  `json:"index,omitempty"`
+	Role         string               `json:"role,omitempty"`
+	Content      string               `json:"content,omitempty"`
+	Reasoning    string               `json:"reasoning,omitempty"`
+	ToolCalls    []core.ToolCallDelta `json:"tool_calls,omitempty"`
+	FinishReason string               `json:"finish_reason,omitempty"`
+}
+
+// NewReverseStreamChunk converts a StreamChunk to its wire form
+func NewReverseStreamChunk(chunk core.StreamChunk) *ReverseStreamChunk {
+	return &ReverseStreamChunk{
+		Index:        chunk.Index,
+		Role:         chunk.Role,
+		Content:      chunk.Content,
+		Reasoning:    chunk.Reasoning,
+		ToolCalls:    chunk.ToolCalls,
+		FinishReason: chunk.FinishReason,
+	}
+}
+
+// StreamChunk converts the wire form back to a StreamChunk
+func (c *ReverseStreamChunk) StreamChunk() core.StreamChunk {
+	return core.StreamChunk{
+		Index:        c.Index,
+		Role:         c.Role,
+		Content:      c.Content,
+		Reasoning:    c.Reasoning,
+		ToolCalls:    c.ToolCalls,
+		FinishReason: c.FinishReason,
+	}
+}
+
+// reverseJob routes the frames of one in-flight Execute; finished is closed when Execute returns,
+// overflow when Serve abandoned the job because its buffer was full
+type reverseJob struct {
+	frames   chan ReverseFrame
+	finished chan struct{}
+	overflow chan struct{}
+}
+
+// ReverseWorker is a worker that dialed the gateway over a WebSocket instead of being dialed
+// Jobs are multiplexed over the connection by ID; the profile is whatever the worker last reported
+type ReverseWorker struct {
+	id   string
+	conn *websocket.Conn
+	// writeMu serializes frames; a WebSocket connection allows one writer at a time
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	profile core.WorkerProfile
+	jobs    map[string]*reverseJob
+	closed  chan struct{}
+}
+
+// NewReverseWorker wraps the connection of a worker that registered with profile
+func NewReverseWorker(conn *websocket.Conn, profile core.WorkerProfile) *ReverseWorker {
+	return &ReverseWorker{
+		id:      profile.WorkerID,
+		conn:    conn,
+		profile: profile,
+		jobs:    make(map[string]*reverseJob),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (w *ReverseWorker) ID() string {
+	return w.id
+}
+
+// Heartbeat returns the profile from the worker's latest heartbeat frame
+func (w *ReverseWorker) Heartbeat(ctx context.Context) (core.WorkerProfile, error) {
+	select {
+	case <-w.closed:
+		return core.WorkerProfile{}, fmt.Errorf("worker %s: %w", w.id, ErrReverseDisconnected)
+	default:
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.profile, nil
+}
+
+// Send writes one frame to the worker, giving up after reverseWriteTimeout
+// A failed write may leave a partial frame behind, so the connection is closed and Serve ends
+func (w *ReverseWorker) Send(frame ReverseFrame) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	w.conn.SetWriteDeadline(time.Now().Add(reverseWriteTimeout))
+	if err := websocket.JSON.Send(w.conn, frame); err != nil {
+		w.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// Execute pushes the request to the worker and relays its chunk frames until the done frame
+// Cancelling ctx or a failing sender sends a cancel frame so the worker stops generating
+func (w *ReverseWorker) Execute(ctx context.Context, req *core.InferenceRequest, sender func(chunk core.StreamChunk) error) error {
+	id := uuid.NewString()
+	job := &reverseJob{
+		frames:   make(chan ReverseFrame, reverseJobBuffer),
+		finished: make(chan struct{}),
+		overflow: make(chan struct{}),
+	}
+	w.mu.Lock()
+	w.jobs[id] = job
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.jobs, id)
+		w.mu.Unlock()
+		close(job.finished)
+	}()
+
+	if err := w.Send(ReverseFrame{Type: ReverseExecute, ID: id, Request: NewReverseRequest(req)}); err != nil {
+		return fmt.Errorf("failed to send job to worker %s: %w", w.id, err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			w.cancel(id)
+			return ctx.Err()
+		case <-w.closed:
+			return fmt.Errorf("worker %s: %w", w.id, ErrReverseDisconnected)
+		case <-job.overflow:
+			return fmt.Errorf("worker %s: %w", w.id, ErrReverseJobOverflow)
+		case frame := <-job.frames:
+			if frame.Type == ReverseDone {
+				if frame.Error != "" {
+					return fmt.Errorf("worker %s: %s", w.id, frame.Error)
+				}
+				return nil
+			}
+			if frame.Chunk == nil {
+				continue
+			}
+			if err := sender(frame.Chunk.StreamChunk()); err != nil {
+				w.cancel(id)
+				return err
+			}
+		}
+	}
+}
+
+// Close drops the connection, which ends Serve and fails the in-flight jobs
+func (w *ReverseWorker) Close() error {
+	return w.conn.Close()
+}
+
+// cancel asks the worker to stop a job; failures are ignored since the job is abandoned either way
+func (w *ReverseWorker) cancel(id string) {
+	w.Send(ReverseFrame{Type: ReverseCancel, ID: id})
+}
+
+// abandon drops a job whose buffer is full: its Execute fails with ErrReverseJobOverflow and
+// the worker is told to stop generating. The cancel frame is sent off the read loop
+func (w *ReverseWorker) abandon(id string, job *reverseJob) {
+	w.mu.Lock()
+	delete(w.jobs, id)
+	w.mu.Unlock()
+	close(job.overflow)
+	go w.cancel(id)
+}
+
+// Serve reads frames until the connection fails, dispatching job frames to their Execute calls and
+// passing heartbeat frames to onHeartbeat, whose directives (if any) are sent back to the worker
+// When Serve returns, every in-flight job fails with ErrReverseDisconnected
+func (w *ReverseWorker) Serve(onHeartbeat func(profile core.WorkerProfile) *core.WorkerDirectives) error {
+	defer close(w.closed)
+	for {
+		var frame ReverseFrame
+		if err := websocket.JSON.Receive(w.conn, &frame); err != nil {
+			return err
+		}
+
+		switch frame.Type {
+		case ReverseHeartbeat:
+			if frame.Profile == nil {
+				continue
+			}
+			// 连接即身份，忽略上报中的 Worker ID
+			frame.Profile.WorkerID = w.id
+			w.mu.Lock()
+			w.profile = *frame.Profile
+			w.mu.Unlock()
+			if d := onHeartbeat(*frame.Profile); d != nil {
+				if err := w.Send(ReverseFrame{Type: ReverseDirectives, Directives: d}); err != nil {
+					return err
+				}
+			}
+		case ReverseChunk, ReverseDone:
+			w.mu.Lock()
+			job, ok := w.jobs[frame.ID]
+			w.mu.Unlock()
+			if !ok {
+				// 已取消的任务可能仍有在途的帧
+				continue
+			}
+			// 读循环由连接上的所有任务与心跳共用，不能被单个读取慢的客户端阻塞：缓冲区满时放弃该任务
+			select {
+			case job.frames <- frame:
+			case <-job.finished:
+			default:
+				w.abandon(frame.ID, job)
+			}
+		}
+	}
+}
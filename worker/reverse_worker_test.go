@@ -0,0 +1,135 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"zam/core"
+	"zam/openai"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestReverseWorker_SlowJobDoesNotBlockConnection(t *testing.T) {
+	workers := make(chan *ReverseWorker, 1)
+	heartbeats := make(chan core.WorkerProfile, 1)
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		w := NewReverseWorker(conn, core.WorkerProfile{WorkerID: "home-4090"})
+		workers <- w
+		w.Serve(func(profile core.WorkerProfile) *core.WorkerDirectives {
+			heartbeats <- profile
+			return nil
+		})
+	}))
+	defer server.Close()
+
+	client, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", server.URL)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer client.Close()
+	w := <-workers
+
+	// 客户端读取卡住的任务
+	unblock := make(chan struct{})
+	result := make(chan error, 1)
+	go func() {
+		result <- w.Execute(context.Background(), &core.InferenceRequest{Model: "llama-8b"}, func(core.StreamChunk) error {
+			<-unblock
+			return nil
+		})
+	}()
+	var execute ReverseFrame
+	if err := websocket.JSON.Receive(client, &execute); err != nil || execute.Type != ReverseExecute {
+		t.Fatalf("expected an execute frame, got %+v (%v)", execute, err)
+	}
+
+	// 超出缓冲的分片不阻塞读循环：之后的心跳照常处理
+	for i := 0; i < reverseJobBuffer+2; i++ {
+		websocket.JSON.Send(client, ReverseFrame{Type: ReverseChunk, ID: execute.ID, Chunk: &ReverseStreamChunk{Content: "x"}})
+	}
+	websocket.JSON.Send(client, ReverseFrame{Type: ReverseHeartbeat, Profile: &core.WorkerProfile{MaxTasks: 2}})
+	select {
+	case profile := <-heartbeats:
+		if profile.WorkerID != "home-4090" {
+			t.Errorf("expected the connection's worker ID, got %q", profile.WorkerID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the heartbeat to be served while a job is stuck")
+	}
+
+	// 被放弃的任务通知 Worker 取消，并以 ErrReverseJobOverflow 结束
+	var cancel ReverseFrame
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := websocket.JSON.Receive(client, &cancel); err != nil || cancel.Type != ReverseCancel || cancel.ID != execute.ID {
+		t.Fatalf("expected a cancel frame for the job, got %+v (%v)", cancel, err)
+	}
+	close(unblock)
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrReverseJobOverflow) {
+			t.Errorf("expected ErrReverseJobOverflow, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Execute to return once the job was abandoned")
+	}
+}
+
+func TestReverseWorker_ExecuteFrameOmitsTenant(t *testing.T) {
+	frames := make(chan string, 1)
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		w := NewReverseWorker(conn, core.WorkerProfile{WorkerID: "home-4090"})
+		go w.Serve(func(core.WorkerProfile) *core.WorkerDirectives { return nil })
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		w.Execute(ctx, &core.InferenceRequest{
+			TraceID:  "trace-1",
+			Tenant:   "sk-live-secret",
+			Session:  "session-1",
+			User:     "alice",
+			Model:    "llama-8b",
+			Messages: []openai.Message{{Role: "user", Content: "hi"}},
+			TopP:     0.9,
+		}, func(core.StreamChunk) error { return nil })
+	}))
+	defer server.Close()
+
+	client, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", server.URL)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer client.Close()
+	go func() {
+		var raw string
+		if websocket.Message.Receive(client, &raw) == nil {
+			frames <- raw
+		}
+	}()
+
+	var raw string
+	select {
+	case raw = <-frames:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an execute frame")
+	}
+	// 调用方的 API Key、会话与终端用户不下发给远程 Worker
+	for _, secret := range []string{"sk-live-secret", "session-1", "alice", "Tenant"} {
+		if strings.Contains(raw, secret) {
+			t.Errorf("expected %q to stay on the gateway, got frame %s", secret, raw)
+		}
+	}
+
+	var frame ReverseFrame
+	if err := json.Unmarshal([]byte(raw), &frame); err != nil || frame.Request == nil {
+		t.Fatalf("expected an execute frame with a request, got %s (%v)", raw, err)
+	}
+	req := frame.Request.InferenceRequest()
+	if req.Model != "llama-8b" || req.TraceID != "trace-1" || req.TopP != 0.9 || len(req.Messages) != 1 {
+		t.Errorf("expected the model, messages and sampling parameters on the wire, got %+v", req)
+	}
+}
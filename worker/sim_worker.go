@@ -0,0 +1,277 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zam/core"
+	"zam/openai"
+)
+
+// LatencyDist describes a latency distribution for simulated workers
+type LatencyDist struct {
+	// Kind is "fixed", "uniform" (Mean±Spread), "normal" (StdDev=Spread) or "exponential" (mean Mean)
+	Kind   string
+	Mean   time.Duration
+	Spread time.Duration
+}
+
+// Sample draws one latency from the distribution; results are never negative
+func (d LatencyDist) Sample(rng *rand.Rand) time.Duration {
+	var v float64
+	mean, spread := float64(d.Mean), float64(d.Spread)
+	switch d.Kind {
+	case "uniform":
+		v = mean - spread + rng.Float64()*2*spread
+	case "normal":
+		v = mean + rng.NormFloat64()*spread
+	case "exponential":
+		v = rng.ExpFloat64() * mean
+	default:
+		v = mean
+	}
+	return time.Duration(math.Max(v, 0))
+}
+
+// SimConfig controls the synthetic streams and faults produced by a SimWorker
+// Fault rates are per-request probabilities in [0, 1]
+type SimConfig struct {
+	Models []string
+	// FirstToken is the time to first token, TokenInterval the delay between tokens
+	FirstToken    LatencyDist
+	TokenInterval LatencyDist
+	Tokens        int
+	// FailureRate drops the connection mid-stream
+	FailureRate float64
+	// MalformedRate emits an invalid SSE data line mid-stream
+	MalformedRate float64
+	// StallRate stops sending mid-stream for StallFor before continuing
+	StallRate float64
+	StallFor  time.Duration
+	// ErrorRate answers with HTTP 500 before streaming
+	ErrorRate float64
+	Seed      int64
+}
+
+// DefaultSimConfig returns a well-behaved simulated worker: 20 tokens, ~100ms to first token
+func DefaultSimConfig() SimConfig {
+	return SimConfig{
+		Models:        []string{"*"},
+		FirstToken:    LatencyDist{Kind: "normal", Mean: 100 * time.Millisecond, Spread: 30 * time.Millisecond},
+		TokenInterval: LatencyDist{Kind: "fixed", Mean: 20 * time.Millisecond},
+		Tokens:        20,
+		StallFor:      30 * time.Second,
+		Seed:          time.Now().UnixNano(),
+	}
+}
+
+// SimWorker is a fault-injection worker: an in-process OpenAI-compatible SSE server driven by SimConfig,
+// called through the regular HTTPWorker so every gateway resilience path is exercised end-to-end
+type SimWorker struct {
+	*HTTPWorker
+	config SimConfig
+	server *http.Server
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewSimWorker starts a simulated backend on a loopback port; call Close to stop it
+func NewSimWorker(id string, config SimConfig) (*SimWorker, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	w := &SimWorker{
+		HTTPWorker: NewHTTPWorker(id, "http://"+listener.Addr().String()+"/v1/chat/completions"),
+		config:     config,
+		rng:        rand.New(rand.NewSource(config.Seed)),
+	}
+	w.server = &http.Server{Handler: http.HandlerFunc(w.serveChat)}
+	go w.server.Serve(listener)
+	return w, nil
+}
+
+// Close stops the simulated backend
+func (w *SimWorker) Close() error {
+	return w.server.Close()
+}
+
+// Heartbeat reports a roomy profile so the router never filters the simulated worker on capacity
+func (w *SimWorker) Heartbeat(ctx context.Context) (core.WorkerProfile, error) {
+	return core.WorkerProfile{
+		WorkerID:      w.ID(),
+		Supported:     w.config.Models,
+		TotalVRAM:     80 * 1024 * 1024 * 1024,
+		AvailableVRAM: 80 * 1024 * 1024 * 1024,
+		MaxTasks:      1000,
+		Class:         "sim",
+	}, nil
+}
+
+// simPlan is the fault schedule drawn for one request
+type simPlan struct {
+	status     int
+	firstToken time.Duration
+	intervals  []time.Duration
+	// failAt, malformedAt and stallAt are token indexes (-1 = never)
+	failAt, malformedAt, stallAt int
+}
+
+// plan draws the latencies and faults of one request under the lock guarding the RNG
+func (w *SimWorker) plan() simPlan {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cfg := w.config
+	p := simPlan{status: http.StatusOK, failAt: -1, malformedAt: -1, stallAt: -1}
+	if w.rng.Float64() < cfg.ErrorRate {
+		p.status = http.StatusInternalServerError
+		return p
+	}
+	p.firstToken = cfg.FirstToken.Sample(w.rng)
+	for i := 0; i < cfg.Tokens; i++ {
+		p.intervals = append(p.intervals, cfg.TokenInterval.Sample(w.rng))
+	}
+	pick := func(rate float64) int {
+		if cfg.Tokens > 0 && w.rng.Float64() < rate {
+			return w.rng.Intn(cfg.Tokens)
+		}
+		return -1
+	}
+	p.failAt, p.malformedAt, p.stallAt = pick(cfg.FailureRate), pick(cfg.MalformedRate), pick(cfg.StallRate)
+	return p
+}
+
+// serveChat streams a synthetic completion, injecting the planned faults
+func (w *SimWorker) serveChat(rw http.ResponseWriter, r *http.Request) {
+	p := w.plan()
+	if p.status != http.StatusOK {
+		http.Error(rw, "simulated upstream error", p.status)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.WriteHeader(http.StatusOK)
+	flusher, _ := rw.(http.Flusher)
+
+	sleep := func(d time.Duration) bool {
+		select {
+		case <-r.Context().Done():
+			return false
+		case <-time.After(d):
+			return true
+		}
+	}
+	send := func(payload string) {
+		fmt.Fprintf(rw, "data: %s\n\n", payload)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	if !sleep(p.firstToken) {
+		return
+	}
+	for i, interval := range p.intervals {
+		switch i {
+		case p.failAt:
+			// 模拟断流：直接关闭连接，不发送结束标记
+			return
+		case p.malformedAt:
+			send("{not json")
+		case p.stallAt:
+			if !sleep(w.config.StallFor) {
+				return
+			}
+		}
+
+		chunk, _ := json.Marshal(openai.ChatCompletionStreamResponse{
+			ID:      "sim",
+			Object:  "chat.completion.chunk",
+			Choices: []openai.StreamChoice{{Delta: openai.Delta{Content: "tok" + strconv.Itoa(i) + " "}}},
+		})
+		send(string(chunk))
+		if !sleep(interval) {
+			return
+		}
+	}
+
+	stop := "stop"
+	final, _ := json.Marshal(openai.ChatCompletionStreamResponse{
+		ID:      "sim",
+		Object:  "chat.completion.chunk",
+		Choices: []openai.StreamChoice{{FinishReason: &stop}},
+	})
+	send(string(final))
+	send("[DONE]")
+}
+
+// ParseSimConfig parses "key=value,..." over DefaultSimConfig, e.g.
+// "fail=0.1,malformed=0.05,stall=0.02,stall_for=10s,error=0.05,ttft=200ms,jitter=50ms,dist=normal,interval=30ms,tokens=50,seed=42"
+func ParseSimConfig(spec string) (SimConfig, error) {
+	cfg := DefaultSimConfig()
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return cfg, fmt.Errorf("invalid sim option %q: expected key=value", field)
+		}
+
+		var err error
+		switch key {
+		case "fail":
+			cfg.FailureRate, err = parseRate(value)
+		case "malformed":
+			cfg.MalformedRate, err = parseRate(value)
+		case "stall":
+			cfg.StallRate, err = parseRate(value)
+		case "error":
+			cfg.ErrorRate, err = parseRate(value)
+		case "stall_for":
+			cfg.StallFor, err = time.ParseDuration(value)
+		case "ttft":
+			cfg.FirstToken.Mean, err = time.ParseDuration(value)
+		case "jitter":
+			cfg.FirstToken.Spread, err = time.ParseDuration(value)
+		case "dist":
+			cfg.FirstToken.Kind = value
+		case "interval":
+			cfg.TokenInterval.Mean, err = time.ParseDuration(value)
+		case "tokens":
+			cfg.Tokens, err = strconv.Atoi(value)
+		case "seed":
+			cfg.Seed, err = strconv.ParseInt(value, 10, 64)
+		case "models":
+			cfg.Models = strings.Split(value, "|")
+		default:
+			return cfg, fmt.Errorf("unknown sim option %q", key)
+		}
+		if err != nil {
+			return cfg, fmt.Errorf("invalid sim option %q: %v", field, err)
+		}
+	}
+	return cfg, nil
+}
+
+// parseRate parses a probability in [0, 1]
+func parseRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("rate must be between 0 and 1")
+	}
+	return rate, nil
+}
@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"zam/core"
+)
+
+func TestSimWorkerFaults(t *testing.T) {
+	run := func(spec string) ([]core.StreamChunk, error) {
+		config, err := ParseSimConfig(spec)
+		if err != nil {
+			t.Fatalf("ParseSimConfig(%q) failed: %v", spec, err)
+		}
+		sim, err := NewSimWorker("sim", config)
+		if err != nil {
+			t.Fatalf("NewSimWorker failed: %v", err)
+		}
+		defer sim.Close()
+
+		var chunks []core.StreamChunk
+		err = sim.Execute(context.Background(), &core.InferenceRequest{TraceID: "sim", Model: "m", Stream: true},
+			func(chunk core.StreamChunk) error {
+				chunks = append(chunks, chunk)
+				return nil
+			})
+		return chunks, err
+	}
+
+	chunks, err := run("ttft=1ms,interval=0s,tokens=5,seed=1")
+	if err != nil {
+		t.Fatalf("expected clean stream, got %v", err)
+	}
+	if len(chunks) != 6 || chunks[5].FinishReason != "stop" {
+		t.Errorf("expected 5 tokens and a stop chunk, got %+v", chunks)
+	}
+
+	if _, err := run("ttft=1ms,interval=0s,tokens=5,fail=1,seed=1"); !errors.Is(err, core.ErrStreamTruncated) {
+		t.Errorf("expected truncated stream, got %v", err)
+	}
+	if _, err := run("ttft=1ms,interval=0s,tokens=5,malformed=1,seed=1"); err == nil {
+		t.Error("expected malformed SSE to fail the request")
+	}
+	if _, err := run("error=1"); err == nil {
+		t.Error("expected simulated HTTP error")
+	}
+
+	// 卡顿：在超时时间内无法完成
+	config, _ := ParseSimConfig("ttft=1ms,interval=0s,tokens=5,stall=1,stall_for=10s,seed=1")
+	sim, _ := NewSimWorker("sim-stall", config)
+	defer sim.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := sim.Execute(ctx, &core.InferenceRequest{TraceID: "sim", Model: "m", Stream: true},
+		func(core.StreamChunk) error { return nil }); err == nil {
+		t.Error("expected stalled stream to hit the deadline")
+	}
+}
@@ -0,0 +1,194 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"zam/core"
+)
+
+// TGIWorker talks to a Hugging Face Text Generation Inference server through /generate_stream
+// TGI serves exactly one model; Models lists the names the gateway routes to it
+type TGIWorker struct {
+	id         string
+	BaseURL    string
+	HTTPClient *http.Client
+	// Headers are extra headers sent with every request (e.g. Authorization for hosted endpoints)
+	Headers http.Header
+	// Models are the routable names of the served model; the model_id from /info is always included
+	Models []string
+	// NumShards and ShardVRAM describe a tensor-parallel deployment: one shard per GPU
+	NumShards int
+	ShardVRAM uint64
+	// Template renders chat messages into the prompt sent as "inputs"
+	Template ChatTemplate
+}
+
+// NewTGIWorker creates a TGIWorker for the server at baseURL (e.g. "http://10.0.0.5:8080")
+func NewTGIWorker(id, baseURL string) *TGIWorker {
+	return &TGIWorker{
+		id:         id,
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{},
+		NumShards:  1,
+		Template:   DefaultChatTemplate,
+	}
+}
+
+func (w *TGIWorker) ID() string {
+	return w.id
+}
+
+// tgiInfo is the subset of TGI's /info response used for the profile
+type tgiInfo struct {
+	ModelID               string `json:"model_id"`
+	MaxConcurrentRequests int    `json:"max_concurrent_requests"`
+	MaxTotalTokens        int    `json:"max_total_tokens"`
+	MaxBatchTotalTokens   int    `json:"max_batch_total_tokens"`
+}
+
+// Heartbeat builds the profile from /info
+// VRAM is the sum over shards: a sharded model needs every shard's GPU, so the worker is treated as one device
+func (w *TGIWorker) Heartbeat(ctx context.Context) (core.WorkerProfile, error) {
+	var info tgiInfo
+	if err := getJSON(ctx, w.HTTPClient, w.Headers, w.BaseURL+"/info", &info); err != nil {
+		return core.WorkerProfile{}, err
+	}
+
+	shards := w.NumShards
+	if shards < 1 {
+		shards = 1
+	}
+	supported := append([]string{}, w.Models...)
+	if info.ModelID != "" {
+		supported = append(supported, info.ModelID)
+	}
+
+	return core.WorkerProfile{
+		WorkerID:  w.id,
+		Supported: supported,
+		TotalVRAM: w.ShardVRAM * uint64(shards),
+		// 权重已常驻显存，剩余容量体现在 max_batch_total_tokens 上，这里按满额上报
+		AvailableVRAM: w.ShardVRAM * uint64(shards),
+		MaxTasks:      info.MaxConcurrentRequests,
+		Capabilities: core.Capabilities{
+			MaxContext: info.MaxTotalTokens,
+		},
+	}, nil
+}
+
+// tgiStreamResponse is one /generate_stream SSE event
+type tgiStreamResponse struct {
+	Token struct {
+		Text    string `json:"text"`
+		Special bool   `json:"special"`
+	} `json:"token"`
+	GeneratedText *string `json:"generated_text"`
+	Details       *struct {
+		FinishReason string `json:"finish_reason"`
+	} `json:"details"`
+	Error string `json:"error"`
+}
+
+func (w *TGIWorker) Execute(ctx context.Context, req *core.InferenceRequest, sender func(chunk core.StreamChunk) error) error {
+	traceID, _ := ctx.Value(core.TraceKey).(string)
+	slog.Debug("forwarding request to TGI /generate_stream", "worker_id", w.id, "trace_id", traceID)
+
+	parameters := map[string]interface{}{
+		"details": true,
+	}
+	// TGI 要求 temperature 严格为正
+	if req.Temperature > 0 {
+		parameters["temperature"] = req.Temperature
+		parameters["do_sample"] = true
+	}
+	if req.MaxTokens > 0 {
+		parameters["max_new_tokens"] = req.MaxTokens
+	}
+	// TGI 要求 top_p 位于 (0, 1) 开区间
+	if req.TopP > 0 && req.TopP < 1 {
+		parameters["top_p"] = req.TopP
+	}
+	if len(req.Stop) > 0 {
+		parameters["stop"] = req.Stop
+	}
+	if req.FrequencyPenalty != 0 {
+		parameters["frequency_penalty"] = req.FrequencyPenalty
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"inputs":     w.Template(req.Messages),
+		"parameters": parameters,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.BaseURL+"/generate_stream", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	setHeaders(httpReq, w.Headers)
+
+	resp, err := w.HTTPClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var event tgiStreamResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return fmt.Errorf("failed to parse TGI event: %w", err)
+		}
+		if event.Error != "" {
+			return fmt.Errorf("TGI error: %s", event.Error)
+		}
+
+		chunk := core.StreamChunk{}
+		if !event.Token.Special {
+			chunk.Content = event.Token.Text
+		}
+		if event.Details != nil {
+			chunk.FinishReason = tgiFinishReason(event.Details.FinishReason)
+		}
+		if err := sender(chunk); err != nil {
+			return err
+		}
+		// generated_text 只出现在最后一个事件中
+		if event.GeneratedText != nil {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to scan response: %w", err)
+	}
+	return fmt.Errorf("worker %s: %w", w.id, core.ErrStreamTruncated)
+}
+
+// tgiFinishReason maps TGI finish reasons onto OpenAI ones
+func tgiFinishReason(reason string) string {
+	if reason == "length" {
+		return "length"
+	}
+	// eos_token, stop_sequence
+	return "stop"
+}
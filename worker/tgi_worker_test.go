@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"zam/core"
+	"zam/openai"
+)
+
+func TestTGIWorker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/info":
+			w.Write([]byte(`{"model_id":"meta-llama/Llama-3-70B","max_concurrent_requests":128,"max_total_tokens":8192}`))
+		case "/generate_stream":
+			var body struct {
+				Inputs string `json:"inputs"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if !strings.HasSuffix(body.Inputs, "assistant:") {
+				t.Errorf("expected rendered chat prompt, got %q", body.Inputs)
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data:{\"token\":{\"text\":\"Hel\",\"special\":false},\"generated_text\":null,\"details\":null}\n\n"))
+			w.Write([]byte("data:{\"token\":{\"text\":\"lo\",\"special\":false},\"generated_text\":null,\"details\":null}\n\n"))
+			w.Write([]byte("data:{\"token\":{\"text\":\"</s>\",\"special\":true},\"generated_text\":\"Hello\",\"details\":{\"finish_reason\":\"eos_token\"}}\n\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tgi := NewTGIWorker("tgi-1", server.URL)
+	tgi.Models = []string{"llama-70b"}
+	tgi.NumShards = 4
+	tgi.ShardVRAM = 24 * 1024 * 1024 * 1024
+
+	profile, err := tgi.Heartbeat(context.Background())
+	if err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	if profile.TotalVRAM != 96*1024*1024*1024 || profile.MaxTasks != 128 || profile.Capabilities.MaxContext != 8192 {
+		t.Errorf("unexpected profile: %+v", profile)
+	}
+	if len(profile.Supported) != 2 || profile.Supported[1] != "meta-llama/Llama-3-70B" {
+		t.Errorf("expected alias and model_id to be supported, got %v", profile.Supported)
+	}
+
+	var content, finish string
+	err = tgi.Execute(context.Background(), &core.InferenceRequest{
+		Model:    "llama-70b",
+		Messages: []openai.Message{{Role: "user", Content: "hi"}},
+	}, func(chunk core.StreamChunk) error {
+		content += chunk.Content
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if content != "Hello" || finish != "stop" {
+		t.Errorf("expected Hello/stop, got %q/%q", content, finish)
+	}
+}
@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"zam/openai"
+)
+
+// tokenizeResponse is the body returned by vLLM / llama.cpp style /tokenize endpoints
+type tokenizeResponse struct {
+	Tokens []int `json:"tokens"`
+}
+
+// Tokenize calls the backend's /tokenize endpoint on the same host as the chat endpoint
+func (w *HTTPWorker) Tokenize(ctx context.Context, model string, messages []openai.Message) ([]int, error) {
+	endpoint, err := url.Parse(w.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid worker URL: %w", err)
+	}
+	endpoint.Path, endpoint.RawQuery = "/tokenize", ""
+
+	return w.postTokenize(ctx, endpoint.String(), map[string]interface{}{
+		"model":    model,
+		"messages": messages,
+	})
+}
+
+// postTokenize sends a tokenize request and decodes the token IDs
+func (w *HTTPWorker) postTokenize(ctx context.Context, endpoint string, body interface{}) ([]int, error) {
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for key, values := range w.Headers {
+		for _, v := range values {
+			httpReq.Header.Add(key, v)
+		}
+	}
+
+	resp, err := w.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var decoded tokenizeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode tokenize response: %w", err)
+	}
+	return decoded.Tokens, nil
+}
@@ -0,0 +1,164 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"zam/core"
+)
+
+// TritonWorker talks to NVIDIA Triton Inference Server through its generate extension
+// (POST /v2/models/{model}/generate_stream, SSE) and lists READY models from the model repository
+type TritonWorker struct {
+	id         string
+	BaseURL    string
+	HTTPClient *http.Client
+	// Headers are extra headers sent with every request
+	Headers http.Header
+	// ModelMap maps routable model names to Triton model names (e.g. "llama-8b" -> "ensemble");
+	// unmapped names are sent as-is
+	ModelMap map[string]string
+	// TextInput and TextOutput are the tensor names of the model's generate interface
+	TextInput  string
+	TextOutput string
+	// VRAM is the GPU memory of the server; Triton does not report it over HTTP
+	VRAM     uint64
+	MaxTasks int
+	Template ChatTemplate
+}
+
+// NewTritonWorker creates a TritonWorker for the server at baseURL (e.g. "http://10.0.0.6:8000")
+func NewTritonWorker(id, baseURL string) *TritonWorker {
+	return &TritonWorker{
+		id:         id,
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{},
+		TextInput:  "text_input",
+		TextOutput: "text_output",
+		MaxTasks:   16,
+		Template:   DefaultChatTemplate,
+	}
+}
+
+func (w *TritonWorker) ID() string {
+	return w.id
+}
+
+// tritonModel is one entry of the repository index
+type tritonModel struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// Heartbeat lists READY models from the repository index
+// Routable aliases in ModelMap are reported for every READY target
+func (w *TritonWorker) Heartbeat(ctx context.Context) (core.WorkerProfile, error) {
+	body, _ := json.Marshal(map[string]bool{"ready": true})
+	var models []tritonModel
+	if err := postJSON(ctx, w.HTTPClient, w.Headers, w.BaseURL+"/v2/repository/index", body, &models); err != nil {
+		return core.WorkerProfile{}, err
+	}
+
+	ready := make(map[string]bool)
+	var supported []string
+	for _, m := range models {
+		if m.State == "" || m.State == "READY" {
+			ready[m.Name] = true
+			supported = append(supported, m.Name)
+		}
+	}
+	for alias, target := range w.ModelMap {
+		if ready[target] {
+			supported = append(supported, alias)
+		}
+	}
+
+	return core.WorkerProfile{
+		WorkerID:      w.id,
+		Supported:     supported,
+		TotalVRAM:     w.VRAM,
+		AvailableVRAM: w.VRAM,
+		MaxTasks:      w.MaxTasks,
+	}, nil
+}
+
+func (w *TritonWorker) Execute(ctx context.Context, req *core.InferenceRequest, sender func(chunk core.StreamChunk) error) error {
+	traceID, _ := ctx.Value(core.TraceKey).(string)
+	model := req.Model
+	if target, ok := w.ModelMap[model]; ok {
+		model = target
+	}
+	slog.Debug("forwarding request to Triton", "worker_id", w.id, "trace_id", traceID, "model", model)
+
+	parameters := map[string]interface{}{"stream": true}
+	if req.Temperature > 0 {
+		parameters["temperature"] = req.Temperature
+	}
+	if req.MaxTokens > 0 {
+		parameters["max_tokens"] = req.MaxTokens
+	}
+	if req.TopP > 0 {
+		parameters["top_p"] = req.TopP
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		w.TextInput:  w.Template(req.Messages),
+		"parameters": parameters,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := w.BaseURL + "/v2/models/" + url.PathEscape(model) + "/generate_stream"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	setHeaders(httpReq, w.Headers)
+
+	resp, err := w.HTTPClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return fmt.Errorf("failed to parse Triton event: %w", err)
+		}
+		if msg, ok := event["error"].(string); ok {
+			return fmt.Errorf("Triton error: %s", msg)
+		}
+
+		text, _ := event[w.TextOutput].(string)
+		if err := sender(core.StreamChunk{Content: text}); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to scan response: %w", err)
+	}
+
+	// Triton 的 generate 扩展没有结束标记，连接正常关闭即表示生成完成
+	return sender(core.StreamChunk{FinishReason: "stop"})
+}
@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"zam/core"
+	"zam/openai"
+)
+
+func TestTritonWorker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/repository/index":
+			w.Write([]byte(`[{"name":"ensemble","version":"1","state":"READY"},{"name":"preprocessing","state":"UNAVAILABLE"}]`))
+		case "/v2/models/ensemble/generate_stream":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if _, ok := body["text_input"].(string); !ok {
+				t.Errorf("expected text_input in request, got %v", body)
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: {\"model_name\":\"ensemble\",\"text_output\":\"Hel\"}\n\n"))
+			w.Write([]byte("data: {\"model_name\":\"ensemble\",\"text_output\":\"lo\"}\n\n"))
+		case "/v2/models/broken/generate_stream":
+			w.Write([]byte("data: {\"error\":\"model crashed\"}\n\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	triton := NewTritonWorker("triton-1", server.URL)
+	triton.ModelMap = map[string]string{"llama-8b": "ensemble"}
+	triton.VRAM = 80 * 1024 * 1024 * 1024
+
+	profile, err := triton.Heartbeat(context.Background())
+	if err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	if len(profile.Supported) != 2 || profile.Supported[0] != "ensemble" || profile.Supported[1] != "llama-8b" {
+		t.Errorf("expected READY model and its alias, got %v", profile.Supported)
+	}
+	if profile.AvailableVRAM != triton.VRAM {
+		t.Errorf("expected configured VRAM, got %d", profile.AvailableVRAM)
+	}
+
+	var content, finish string
+	err = triton.Execute(context.Background(), &core.InferenceRequest{
+		Model:    "llama-8b",
+		Messages: []openai.Message{{Role: "user", Content: "hi"}},
+	}, func(chunk core.StreamChunk) error {
+		content += chunk.Content
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if content != "Hello" || finish != "stop" {
+		t.Errorf("expected Hello/stop, got %q/%q", content, finish)
+	}
+
+	err = triton.Execute(context.Background(), &core.InferenceRequest{Model: "broken"}, func(core.StreamChunk) error { return nil })
+	if err == nil {
+		t.Error("expected Triton error event to fail the request")
+	}
+}
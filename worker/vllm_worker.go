@@ -0,0 +1,172 @@
+package worker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"zam/core"
+)
+
+// VLLMWorker targets vLLM's OpenAI-compatible server. Chat shares HTTPWorker's SSE parser; the
+// profile comes from the server itself: liveness from /health, served models from /v1/models and
+// running/waiting requests and KV-cache usage from the Prometheus /metrics endpoint
+type VLLMWorker struct {
+	*HTTPWorker
+	baseURL string
+	// VRAM is the GPU memory of the host; vLLM does not report it
+	VRAM uint64
+	// MaxTasks should match the server's --max-num-seqs
+	MaxTasks int
+}
+
+// NewVLLMWorker creates a VLLMWorker for the server at baseURL (e.g. "http://10.0.0.5:8000")
+func NewVLLMWorker(id, baseURL string) *VLLMWorker {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &VLLMWorker{
+		HTTPWorker: NewHTTPWorker(id, baseURL+"/v1/chat/completions"),
+		baseURL:    baseURL,
+		MaxTasks:   256,
+	}
+}
+
+// vllmModel is one entry of /v1/models
+type vllmModel struct {
+	ID          string `json:"id"`
+	MaxModelLen int    `json:"max_model_len"`
+}
+
+// vllmMetrics are the scheduler gauges read from /metrics
+type vllmMetrics struct {
+	running      int
+	waiting      int
+	kvCacheUsage float64
+}
+
+// Heartbeat fails unless /health answers 200 and reports the served models with the scheduler's load
+// Metrics are best effort: a server whose /metrics cannot be read is still schedulable
+func (w *VLLMWorker) Heartbeat(ctx context.Context) (core.WorkerProfile, error) {
+	health, err := w.get(ctx, "/health")
+	if err != nil {
+		return core.WorkerProfile{}, err
+	}
+	health.Close()
+
+	body, err := w.get(ctx, "/v1/models")
+	if err != nil {
+		return core.WorkerProfile{}, err
+	}
+	var list struct {
+		Data []vllmModel `json:"data"`
+	}
+	err = json.NewDecoder(body).Decode(&list)
+	body.Close()
+	if err != nil {
+		return core.WorkerProfile{}, fmt.Errorf("failed to decode /v1/models: %w", err)
+	}
+
+	profile := core.WorkerProfile{
+		WorkerID: w.id,
+		// 权重已常驻显存，负载体现在 KV 缓存占用与排队数上，这里按满额上报
+		TotalVRAM:     w.VRAM,
+		AvailableVRAM: w.VRAM,
+		MaxTasks:      w.MaxTasks,
+	}
+	for _, m := range list.Data {
+		profile.Supported = append(profile.Supported, m.ID)
+		// 多个模型时取最小上下文，保证任一模型都能容纳
+		if m.MaxModelLen > 0 && (profile.Capabilities.MaxContext == 0 || m.MaxModelLen < profile.Capabilities.MaxContext) {
+			profile.Capabilities.MaxContext = m.MaxModelLen
+		}
+	}
+
+	metrics, err := w.get(ctx, "/metrics")
+	if err != nil {
+		slog.Debug("vLLM metrics unavailable", "worker_id", w.id, "error", err)
+		return profile, nil
+	}
+	defer metrics.Close()
+	m, err := parseVLLMMetrics(metrics)
+	if err != nil {
+		slog.Debug("failed to parse vLLM metrics", "worker_id", w.id, "error", err)
+		return profile, nil
+	}
+	profile.ActiveTasks = m.running
+	profile.QueueLength = m.waiting
+	profile.KVCacheUsage = m.kvCacheUsage
+	return profile, nil
+}
+
+// parseVLLMMetrics reads the scheduler gauges from the Prometheus text format
+// Counts are summed over models; KV-cache usage takes the fullest model. Both the V0 name
+// (gpu_cache_usage_perc) and the V1 name (kv_cache_usage_perc) are accepted; despite "perc" they are 0-1 fractions
+func parseVLLMMetrics(r io.Reader) (vllmMetrics, error) {
+	var m vllmMetrics
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		// name{labels} value [timestamp]
+		name, rest := line, ""
+		if i := strings.IndexByte(line, '{'); i >= 0 {
+			name = line[:i]
+			if j := strings.LastIndexByte(line, '}'); j > i {
+				rest = line[j+1:]
+			}
+		} else if i := strings.IndexByte(line, ' '); i >= 0 {
+			name, rest = line[:i], line[i:]
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+
+		switch name {
+		case "vllm:num_requests_running":
+			m.running += int(value)
+		case "vllm:num_requests_waiting":
+			m.waiting += int(value)
+		case "vllm:gpu_cache_usage_perc", "vllm:kv_cache_usage_perc":
+			if value > m.kvCacheUsage {
+				m.kvCacheUsage = value
+			}
+		}
+	}
+	return m, scanner.Err()
+}
+
+// get fetches path from the server; the caller closes the returned body
+func (w *VLLMWorker) get(ctx context.Context, path string) (io.ReadCloser, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, w.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range w.Headers {
+		for _, v := range values {
+			httpReq.Header.Add(key, v)
+		}
+	}
+
+	resp, err := w.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code from %s: %d", path, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
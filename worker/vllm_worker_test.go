@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVLLMWorkerHeartbeat(t *testing.T) {
+	healthy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			if !healthy {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		case "/v1/models":
+			w.Write([]byte(`{"object":"list","data":[{"id":"meta-llama/Meta-Llama-3-8B-Instruct","object":"model","max_model_len":8192}]}`))
+		case "/metrics":
+			w.Write([]byte(`# HELP vllm:num_requests_running Number of requests currently running on GPU.
+# TYPE vllm:num_requests_running gauge
+vllm:num_requests_running{model_name="meta-llama/Meta-Llama-3-8B-Instruct"} 3.0
+vllm:num_requests_waiting{model_name="meta-llama/Meta-Llama-3-8B-Instruct"} 2.0
+vllm:gpu_cache_usage_perc{model_name="meta-llama/Meta-Llama-3-8B-Instruct"} 0.42
+vllm:prompt_tokens_total{model_name="meta-llama/Meta-Llama-3-8B-Instruct"} 12345.0
+`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	vllm := NewVLLMWorker("vllm-1", server.URL)
+	vllm.VRAM = 24 << 30
+	profile, err := vllm.Heartbeat(context.Background())
+	if err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	if len(profile.Supported) != 1 || profile.Supported[0] != "meta-llama/Meta-Llama-3-8B-Instruct" || profile.Capabilities.MaxContext != 8192 {
+		t.Errorf("unexpected models: %v / %+v", profile.Supported, profile.Capabilities)
+	}
+	if profile.ActiveTasks != 3 || profile.QueueLength != 2 || profile.KVCacheUsage != 0.42 {
+		t.Errorf("expected scheduler load from /metrics, got active=%d queue=%d kv=%v", profile.ActiveTasks, profile.QueueLength, profile.KVCacheUsage)
+	}
+	if profile.TotalVRAM != 24<<30 || profile.MaxTasks != 256 {
+		t.Errorf("unexpected capacity: %+v", profile)
+	}
+
+	healthy = false
+	if _, err := vllm.Heartbeat(context.Background()); err == nil {
+		t.Error("expected heartbeat to fail while /health is unhealthy")
+	}
+}